@@ -0,0 +1,219 @@
+package analytics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/uncles"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+// Outcomes of a bid
+const (
+	BidWon    = "won"    // bundle mined in the canonical chain
+	BidFailed = "failed" // bundle mined, but with a failed tx
+	BidUncled = "uncled" // bundle mined in an uncle block
+)
+
+// Bids older than this are dropped
+var DefaultBidRetention = 30 * 24 * time.Hour
+
+// Bid is a bundle of a searcher, with the effective gas price it paid (or offered) to the miner
+type Bid struct {
+	Time        time.Time `json:"time"`
+	Block       int64     `json:"block"`
+	BundleIndex int64     `json:"bundle_index"` // -1 for uncle bundles
+	Outcome     string    `json:"outcome"`
+	GasPrice    *big.Int  `json:"gas_price"` // miner reward / gas used (estimated for uncle bundles), wei
+}
+
+// BidSeriesPoint are the bids of a searcher in one interval of a series. Gas prices are nil without bids.
+type BidSeriesPoint struct {
+	Start  time.Time `json:"start"`
+	Won    int       `json:"won"`
+	Failed int       `json:"failed"`
+	Uncled int       `json:"uncled"`
+
+	MedianWonGasPrice  *big.Int `json:"median_won_gas_price"`
+	MedianLostGasPrice *big.Int `json:"median_lost_gas_price"` // failed and uncled
+	MaxGasPrice        *big.Int `json:"max_gas_price"`
+}
+
+// BidHistory keeps the bids of each searcher (bundle EOA) over time, to analyze how their bidding evolves
+type BidHistory struct {
+	Searchers map[string][]Bid `json:"searchers"` // by lowercase EOA address, sorted by time
+
+	Retention time.Duration `json:"-"` // 0 = keep forever
+}
+
+func NewBidHistory() *BidHistory {
+	return &BidHistory{
+		Searchers: make(map[string][]Bid),
+		Retention: DefaultBidRetention,
+	}
+}
+
+// Add adds a bid of the searcher
+func (h *BidHistory) Add(searcher string, bid Bid) {
+	searcher = strings.ToLower(searcher)
+	bids := append(h.Searchers[searcher], bid)
+	if len(bids) > 1 && bid.Time.Before(bids[len(bids)-2].Time) { // usually added in order
+		sort.SliceStable(bids, func(i, j int) bool { return bids[i].Time.Before(bids[j].Time) })
+	}
+	h.Searchers[searcher] = bids
+	h.prune(bid.Time)
+}
+
+// AddCheck adds the bundles of a checked block as won or failed bids, for every searcher of the bundle
+func (h *BidHistory) AddCheck(check *blockcheck.BlockCheck) {
+	t := time.Unix(int64(check.EthBlock.Time()), 0).UTC()
+	for _, bundle := range check.Bundles {
+		outcome := BidWon
+		searchers := make(map[string]bool)
+		for _, tx := range bundle.Transactions {
+			searchers[strings.ToLower(tx.EoaAddress)] = true
+			if _, failed := check.FailedTx[tx.Hash]; failed {
+				outcome = BidFailed
+			}
+		}
+
+		for searcher := range searchers {
+			h.Add(searcher, Bid{Time: t, Block: check.Number, BundleIndex: bundle.Index, Outcome: outcome, GasPrice: new(big.Int).Set(bundle.RewardDivGasUsed)})
+		}
+	}
+}
+
+// AddUncleBundle adds a bundle of an uncle block as lost bid of the sender of its first tx
+func (h *BidHistory) AddUncleBundle(bundle *uncles.UncleBundle) {
+	if len(bundle.Transactions) == 0 {
+		return
+	}
+	sender, err := utils.GetTxSender(bundle.Transactions[0])
+	if err != nil {
+		return
+	}
+
+	t := time.Unix(int64(bundle.UncleTime), 0).UTC()
+	h.Add(sender.Hex(), Bid{Time: t, Block: bundle.UncleNumber, BundleIndex: -1, Outcome: BidUncled, GasPrice: bundle.EstimatedGasPrice()})
+}
+
+// RemoveBlock removes the won and failed bids of a block (eg. a block replaced in a reorg)
+func (h *BidHistory) RemoveBlock(block int64) {
+	for searcher, bids := range h.Searchers {
+		kept := bids[:0]
+		for _, bid := range bids {
+			if bid.Block != block || bid.Outcome == BidUncled {
+				kept = append(kept, bid)
+			}
+		}
+		if len(kept) == 0 {
+			delete(h.Searchers, searcher)
+		} else {
+			h.Searchers[searcher] = kept
+		}
+	}
+}
+
+func (h *BidHistory) prune(now time.Time) {
+	if h.Retention <= 0 {
+		return
+	}
+	for searcher, bids := range h.Searchers {
+		i := sort.Search(len(bids), func(i int) bool { return now.Sub(bids[i].Time) <= h.Retention })
+		if i == len(bids) {
+			delete(h.Searchers, searcher)
+		} else if i > 0 {
+			h.Searchers[searcher] = bids[i:]
+		}
+	}
+}
+
+// Series returns the bids of the searcher in the time range [from, to), in intervals of the given length (eg. for
+// charts). Intervals without bids are included.
+func (h *BidHistory) Series(searcher string, from time.Time, to time.Time, interval time.Duration) (series []*BidSeriesPoint) {
+	from = from.UTC().Truncate(interval)
+	for start := from; start.Before(to); start = start.Add(interval) {
+		series = append(series, &BidSeriesPoint{Start: start})
+	}
+
+	won := make([][]*big.Int, len(series))
+	lost := make([][]*big.Int, len(series))
+	for _, bid := range h.Searchers[strings.ToLower(searcher)] {
+		if bid.Time.Before(from) || !bid.Time.Before(to) {
+			continue
+		}
+
+		i := int(bid.Time.Sub(from) / interval)
+		point := series[i]
+		switch bid.Outcome {
+		case BidWon:
+			point.Won += 1
+			won[i] = append(won[i], bid.GasPrice)
+		case BidFailed:
+			point.Failed += 1
+			lost[i] = append(lost[i], bid.GasPrice)
+		case BidUncled:
+			point.Uncled += 1
+			lost[i] = append(lost[i], bid.GasPrice)
+		}
+		if point.MaxGasPrice == nil || bid.GasPrice.Cmp(point.MaxGasPrice) == 1 {
+			point.MaxGasPrice = bid.GasPrice
+		}
+	}
+
+	for i, point := range series {
+		point.MedianWonGasPrice = median(won[i])
+		point.MedianLostGasPrice = median(lost[i])
+	}
+	return series
+}
+
+// median returns the median of the values, nil if there are none
+func median(values []*big.Int) *big.Int {
+	if len(values) == 0 {
+		return nil
+	}
+	sorted := make([]*big.Int, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) == -1 })
+	return sorted[len(sorted)/2]
+}
+
+// Save writes the bid history to a JSON file
+func (h *BidHistory) Save(filename string) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	tmpFile := filename + ".tmp"
+	err = os.WriteFile(tmpFile, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, filename)
+}
+
+// LoadBidHistory reads the bid history from a JSON file, or returns an empty history if the file doesn't exist
+func LoadBidHistory(filename string) (*BidHistory, error) {
+	h := NewBidHistory()
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, h)
+	if err != nil {
+		return nil, fmt.Errorf("bid history %s: %w", filename, err)
+	}
+	return h, nil
+}
@@ -0,0 +1,62 @@
+package analytics
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBidHistory(t *testing.T) {
+	day := time.Date(2021, 8, 20, 0, 0, 0, 0, time.UTC)
+	h := NewBidHistory()
+	h.Retention = 0
+	h.Add("0xAAA", Bid{Time: day.Add(10 * time.Minute), Block: 1, Outcome: BidWon, GasPrice: big.NewInt(10)})
+	h.Add("0xAAA", Bid{Time: day.Add(20 * time.Minute), Block: 2, Outcome: BidWon, GasPrice: big.NewInt(30)})
+	h.Add("0xAAA", Bid{Time: day.Add(5 * time.Minute), Block: 0, Outcome: BidUncled, GasPrice: big.NewInt(5)})
+	h.Add("0xaaa", Bid{Time: day.Add(70 * time.Minute), Block: 3, Outcome: BidFailed, GasPrice: big.NewInt(50)})
+
+	bids := h.Searchers["0xaaa"]
+	if len(bids) != 4 || bids[0].Block != 0 {
+		t.Fatal("Bids should be sorted by time:", bids)
+	}
+
+	series := h.Series("0xAAA", day, day.Add(3*time.Hour), time.Hour)
+	if len(series) != 3 {
+		t.Fatal("Wrong number of intervals:", len(series))
+	}
+	if p := series[0]; p.Won != 2 || p.Uncled != 1 || p.MedianWonGasPrice.Int64() != 30 || p.MedianLostGasPrice.Int64() != 5 || p.MaxGasPrice.Int64() != 30 {
+		t.Errorf("Unexpected first interval: %+v", p)
+	}
+	if p := series[1]; p.Failed != 1 || p.MedianWonGasPrice != nil || p.MedianLostGasPrice.Int64() != 50 {
+		t.Errorf("Unexpected second interval: %+v", p)
+	}
+	if p := series[2]; p.Won+p.Failed+p.Uncled != 0 || p.MaxGasPrice != nil {
+		t.Errorf("Unexpected empty interval: %+v", p)
+	}
+
+	h.RemoveBlock(2)
+	if len(h.Searchers["0xaaa"]) != 3 {
+		t.Error("Expected the bid of the reorged block to be removed:", h.Searchers["0xaaa"])
+	}
+
+	// Retention
+	h.Retention = time.Hour
+	h.Add("0xBBB", Bid{Time: day.Add(90 * time.Minute), Outcome: BidWon, GasPrice: big.NewInt(1)})
+	if len(h.Searchers["0xaaa"]) != 1 {
+		t.Error("Expected old bids to be pruned:", h.Searchers["0xaaa"])
+	}
+
+	// Save and load
+	filename := filepath.Join(t.TempDir(), "bids.json")
+	if err := h.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBidHistory(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Searchers) != 2 || loaded.Searchers["0xbbb"][0].GasPrice.Int64() != 1 {
+		t.Error("Unexpected loaded history:", loaded.Searchers)
+	}
+}
@@ -0,0 +1,325 @@
+package analytics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+// Sort orders of the census top lists
+const (
+	CensusByBundles = "bundles"
+	CensusByReward  = "reward"
+)
+
+// Census days older than this are dropped
+var DefaultCensusRetention = 90 * 24 * time.Hour
+
+// CensusStats are the bundles which touched a contract or protocol. The miner reward is the total of these bundles
+// (a bundle touching several contracts counts fully for each).
+type CensusStats struct {
+	Bundles     int64    `json:"bundles"`
+	Tx          int64    `json:"tx"`
+	MinerReward *big.Int `json:"miner_reward"`
+}
+
+func newCensusStats() *CensusStats {
+	return &CensusStats{MinerReward: new(big.Int)}
+}
+
+func (s *CensusStats) add(bundles int64, tx int64, reward *big.Int, sign int64) {
+	s.Bundles += sign * bundles
+	s.Tx += sign * tx
+	s.MinerReward.Add(s.MinerReward, new(big.Int).Mul(reward, big.NewInt(sign)))
+}
+
+// CensusContract is a contract touched by bundles (the "to" address of bundle tx)
+type CensusContract struct {
+	CensusStats
+	Protocol string `json:"protocol,omitempty"` // if the address is in blockcheck.ProtocolRegistry
+	Contract string `json:"contract,omitempty"` // contract name in the registry
+}
+
+// CensusDay are the contracts and protocols touched by the bundles of one day (UTC)
+type CensusDay struct {
+	Start   time.Time `json:"start"`
+	Bundles int64     `json:"bundles"`
+
+	Contracts map[string]*CensusContract `json:"contracts"` // by lowercase address
+	Protocols map[string]*CensusStats    `json:"protocols"` // by protocol name, see blockcheck.ProtocolRegistry
+}
+
+func NewCensusDay(start time.Time) *CensusDay {
+	return &CensusDay{
+		Start:     start,
+		Contracts: make(map[string]*CensusContract),
+		Protocols: make(map[string]*CensusStats),
+	}
+}
+
+// CensusBundle is a bundle added to the census
+type CensusBundle struct {
+	Contracts   map[string]int64 // tx by lowercase "to" address
+	Protocols   map[string]int   // tx by protocol
+	MinerReward *big.Int
+}
+
+// CensusBlock are the bundles of a checked block
+type CensusBlock struct {
+	Number  int64
+	Time    time.Time
+	Bundles []CensusBundle
+}
+
+func NewCensusBlock(check *blockcheck.BlockCheck) CensusBlock {
+	b := CensusBlock{Number: check.Number, Time: time.Unix(int64(check.EthBlock.Time()), 0)}
+	for _, bundle := range check.Bundles {
+		censusBundle := CensusBundle{Contracts: make(map[string]int64), Protocols: bundle.Protocols, MinerReward: bundle.TotalMinerReward}
+		for _, tx := range bundle.Transactions {
+			if tx.ToAddress != "" {
+				censusBundle.Contracts[strings.ToLower(tx.ToAddress)] += 1
+			}
+		}
+		b.Bundles = append(b.Bundles, censusBundle)
+	}
+	return b
+}
+
+// add adds the bundles of a block, or removes them with sign -1 (eg. after a reorg)
+func (d *CensusDay) add(b CensusBlock, sign int64) {
+	for _, bundle := range b.Bundles {
+		d.Bundles += sign
+		for address, tx := range bundle.Contracts {
+			contract, found := d.Contracts[address]
+			if !found {
+				contract = &CensusContract{CensusStats: *newCensusStats()}
+				if blockcheck.ProtocolRegistry != nil {
+					if match := blockcheck.ProtocolRegistry.Decode(address, nil); match.Contract != "" {
+						contract.Protocol, contract.Contract = match.Protocol, match.Contract
+					}
+				}
+				d.Contracts[address] = contract
+			}
+			contract.add(1, tx, bundle.MinerReward, sign)
+			if contract.Bundles <= 0 {
+				delete(d.Contracts, address)
+			}
+		}
+
+		for name, tx := range bundle.Protocols {
+			protocol, found := d.Protocols[name]
+			if !found {
+				protocol = newCensusStats()
+				d.Protocols[name] = protocol
+			}
+			protocol.add(1, int64(tx), bundle.MinerReward, sign)
+			if protocol.Bundles <= 0 {
+				delete(d.Protocols, name)
+			}
+		}
+	}
+}
+
+// merge adds all stats of another day
+func (d *CensusDay) merge(other *CensusDay) {
+	d.Bundles += other.Bundles
+	for address, c := range other.Contracts {
+		contract, found := d.Contracts[address]
+		if !found {
+			contract = &CensusContract{CensusStats: *newCensusStats(), Protocol: c.Protocol, Contract: c.Contract}
+			d.Contracts[address] = contract
+		}
+		contract.add(c.Bundles, c.Tx, c.MinerReward, 1)
+	}
+	for name, p := range other.Protocols {
+		protocol, found := d.Protocols[name]
+		if !found {
+			protocol = newCensusStats()
+			d.Protocols[name] = protocol
+		}
+		protocol.add(p.Bundles, p.Tx, p.MinerReward, 1)
+	}
+}
+
+// CensusEntry is a contract or protocol in a top list
+type CensusEntry struct {
+	Name     string `json:"name"`               // address of a contract, or protocol name
+	Protocol string `json:"protocol,omitempty"` // of a contract
+	Contract string `json:"contract,omitempty"`
+	CensusStats
+}
+
+func sortCensusEntries(entries []CensusEntry, by string, n int) []CensusEntry {
+	sort.Slice(entries, func(i, j int) bool {
+		if by == CensusByReward {
+			if cmp := entries[i].MinerReward.Cmp(entries[j].MinerReward); cmp != 0 {
+				return cmp == 1
+			}
+		} else if entries[i].Bundles != entries[j].Bundles {
+			return entries[i].Bundles > entries[j].Bundles
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// TopContracts returns the n contracts (0 = all) with the most bundles, or the highest miner reward
+func (d *CensusDay) TopContracts(n int, by string) []CensusEntry {
+	entries := make([]CensusEntry, 0, len(d.Contracts))
+	for address, c := range d.Contracts {
+		entries = append(entries, CensusEntry{Name: address, Protocol: c.Protocol, Contract: c.Contract, CensusStats: c.CensusStats})
+	}
+	return sortCensusEntries(entries, by, n)
+}
+
+// TopProtocols returns the n protocols (0 = all) with the most bundles, or the highest miner reward
+func (d *CensusDay) TopProtocols(n int, by string) []CensusEntry {
+	entries := make([]CensusEntry, 0, len(d.Protocols))
+	for name, p := range d.Protocols {
+		entries = append(entries, CensusEntry{Name: name, CensusStats: *p})
+	}
+	return sortCensusEntries(entries, by, n)
+}
+
+// String returns the totals, and the top 10 protocols and contracts by bundles
+func (d *CensusDay) String() (ret string) {
+	ret = fmt.Sprintf("bundles: %d, contracts: %d, protocols: %d\n", d.Bundles, len(d.Contracts), len(d.Protocols))
+	if len(d.Protocols) > 0 {
+		ret += "Top protocols:\n"
+	}
+	for _, p := range d.TopProtocols(10, CensusByBundles) {
+		ret += fmt.Sprintf("- %-30s bundles=%-6d tx=%-6d minerReward=%10s ETH\n", p.Name, p.Bundles, p.Tx, utils.WeiBigIntToEthString(p.MinerReward, 4))
+	}
+	if len(d.Contracts) > 0 {
+		ret += "Top contracts:\n"
+	}
+	for _, c := range d.TopContracts(10, CensusByBundles) {
+		name := c.Name
+		if c.Contract != "" {
+			name += fmt.Sprintf(" (%s %s)", c.Protocol, c.Contract)
+		}
+		ret += fmt.Sprintf("- %-66s bundles=%-6d tx=%-6d minerReward=%10s ETH\n", name, c.Bundles, c.Tx, utils.WeiBigIntToEthString(c.MinerReward, 4))
+	}
+	return ret
+}
+
+// Census keeps the contracts and protocols touched by bundles per day, updated incrementally with every block. It's
+// safe for concurrent use.
+type Census struct {
+	Days map[int64]*CensusDay `json:"days"` // by unix timestamp of the start of the day (UTC)
+
+	Retention time.Duration `json:"-"` // 0 = keep forever
+
+	lock sync.Mutex
+}
+
+func NewCensus() *Census {
+	return &Census{
+		Days:      make(map[int64]*CensusDay),
+		Retention: DefaultCensusRetention,
+	}
+}
+
+func (c *Census) update(b CensusBlock, sign int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	start := rollupStart(RollupDay, b.Time)
+	day, found := c.Days[start.Unix()]
+	if !found {
+		day = NewCensusDay(start)
+		c.Days[start.Unix()] = day
+	}
+	day.add(b, sign)
+
+	if c.Retention > 0 {
+		for start := range c.Days {
+			if b.Time.Sub(time.Unix(start, 0)) > c.Retention {
+				delete(c.Days, start)
+			}
+		}
+	}
+}
+
+// Add adds the bundles of a block to the census of its day
+func (c *Census) Add(b CensusBlock) {
+	c.update(b, 1)
+}
+
+// Remove removes a previously added block (eg. a block replaced in a reorg)
+func (c *Census) Remove(b CensusBlock) {
+	c.update(b, -1)
+}
+
+// AddCheck adds a checked block
+func (c *Census) AddCheck(check *blockcheck.BlockCheck) {
+	c.Add(NewCensusBlock(check))
+}
+
+// RemoveCheck removes a previously added check (eg. of a block replaced in a reorg)
+func (c *Census) RemoveCheck(check *blockcheck.BlockCheck) {
+	c.Remove(NewCensusBlock(check))
+}
+
+// Query sums the days in the time range [from, to). from and to are rounded down to the day.
+func (c *Census) Query(from time.Time, to time.Time) *CensusDay {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	fromStart := rollupStart(RollupDay, from)
+	toStart := rollupStart(RollupDay, to)
+	result := NewCensusDay(fromStart)
+	for start, day := range c.Days {
+		if start >= fromStart.Unix() && start < toStart.Unix() {
+			result.merge(day)
+		}
+	}
+	return result
+}
+
+// Save writes the census to a JSON file
+func (c *Census) Save(filename string) error {
+	c.lock.Lock()
+	data, err := json.Marshal(c)
+	c.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so the census is never half-written
+	tmpFile := filename + ".tmp"
+	err = os.WriteFile(tmpFile, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, filename)
+}
+
+// LoadCensus reads the census from a JSON file, or returns an empty census if the file doesn't exist
+func LoadCensus(filename string) (*Census, error) {
+	c := NewCensus()
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return nil, fmt.Errorf("census %s: %w", filename, err)
+	}
+	return c, nil
+}
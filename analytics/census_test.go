@@ -0,0 +1,75 @@
+package analytics
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCensus(t *testing.T) {
+	day := time.Date(2021, 8, 20, 0, 0, 0, 0, time.UTC)
+	router := "0x7a250d5630b4cf539739df2c5dacb4c659f2488d" // Uniswap V2 Router02
+	blocks := []CensusBlock{
+		{Number: 1, Time: day.Add(10 * time.Minute), Bundles: []CensusBundle{
+			{Contracts: map[string]int64{router: 2}, Protocols: map[string]int{"Uniswap V2": 2}, MinerReward: big.NewInt(1000)},
+			{Contracts: map[string]int64{router: 1, "0xccc": 1}, Protocols: map[string]int{"Uniswap V2": 1, "unknown": 1}, MinerReward: big.NewInt(5000)},
+		}},
+		{Number: 2, Time: day.Add(2 * time.Hour), Bundles: []CensusBundle{
+			{Contracts: map[string]int64{"0xddd": 1}, Protocols: map[string]int{"unknown": 1}, MinerReward: big.NewInt(100)},
+		}},
+		{Number: 3, Time: day.Add(25 * time.Hour), Bundles: []CensusBundle{
+			{Contracts: map[string]int64{router: 1}, Protocols: map[string]int{"Uniswap V2": 1}, MinerReward: big.NewInt(200)},
+		}},
+	}
+
+	c := NewCensus()
+	c.Retention = 0
+	for _, b := range blocks {
+		c.Add(b)
+	}
+	if len(c.Days) != 2 {
+		t.Fatal("Wrong number of days:", len(c.Days))
+	}
+
+	first := c.Query(day, day.Add(24*time.Hour))
+	if first.Bundles != 3 || len(first.Contracts) != 3 || len(first.Protocols) != 2 {
+		t.Fatal("Unexpected first day:", first)
+	}
+	contract := first.Contracts[router]
+	if contract.Bundles != 2 || contract.Tx != 3 || contract.MinerReward.Int64() != 6000 || contract.Protocol != "Uniswap V2" || contract.Contract != "Router02" {
+		t.Error("Unexpected router stats:", contract)
+	}
+
+	if top := first.TopProtocols(1, CensusByBundles); len(top) != 1 || top[0].Name != "Uniswap V2" {
+		t.Error("Unexpected top protocols by bundles:", top)
+	}
+	if top := first.TopContracts(0, CensusByReward); len(top) != 3 || top[0].Name != router || top[1].Name != "0xccc" || top[2].Name != "0xddd" {
+		t.Error("Unexpected top contracts by reward:", top)
+	}
+
+	// Remove block 2 again (reorg): its contract is no longer touched
+	c.Remove(blocks[1])
+	first = c.Query(day, day.Add(24*time.Hour))
+	if first.Bundles != 2 || first.Contracts["0xddd"] != nil || first.Protocols["unknown"].Bundles != 1 {
+		t.Error("Unexpected first day after remove:", first)
+	}
+
+	all := c.Query(day, day.Add(48*time.Hour))
+	if all.Bundles != 3 || all.Contracts[router].Bundles != 3 || all.Protocols["Uniswap V2"].MinerReward.Int64() != 6200 {
+		t.Error("Unexpected total:", all)
+	}
+
+	// Save and load
+	filename := filepath.Join(t.TempDir(), "census.json")
+	if err := c.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCensus(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded := loaded.Query(day, day.Add(48*time.Hour)); loaded.Bundles != 3 || loaded.Contracts[router].Contract != "Router02" {
+		t.Error("Unexpected loaded census:", loaded)
+	}
+}
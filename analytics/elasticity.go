@@ -0,0 +1,177 @@
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/flashbots/labels"
+)
+
+// Miners with fewer ranked bundles are not included in the elasticity report
+var MinElasticityBundles = 30
+
+// bundleTip is a regular bundle of a block with at least two bundles
+type bundleTip struct {
+	gasPrice float64 // miner reward / gas used, wei
+	position float64 // 0 = first bundle of the block, 1 = last
+}
+
+// MinerTipElasticity estimates how the bundle tip (miner reward per gas) influences the position of bundles in the
+// blocks of a miner. Only included bundles are known from the API, so the "probability" is the probability of being
+// placed first among the bundles of the block, not of being included at all.
+type MinerTipElasticity struct {
+	Miner      string
+	NumBundles int // ranked bundles (regular bundles in blocks with at least two bundles)
+
+	// OLS slope of the relative position (0 = first, 1 = last bundle) on log2(tip): the position change when
+	// doubling the tip. Negative if higher tips are placed higher.
+	PositionPerDoubling float64
+
+	QuartileTips     [4]float64 // median tip of each tip quartile, wei per gas
+	QuartileTopShare [4]float64 // share of the bundles of each tip quartile which were placed first
+
+	// Arc elasticity of the top placement probability between the lowest and highest tip quartile: % change of the
+	// probability per % change of the tip
+	Elasticity float64
+}
+
+// TipElasticityAnalytics collects the bundles of each miner across a block range
+type TipElasticityAnalytics struct {
+	bundles map[string][]bundleTip // by lowercase miner address
+
+	StartBlock int64
+	EndBlock   int64
+}
+
+func NewTipElasticityAnalytics() *TipElasticityAnalytics {
+	return &TipElasticityAnalytics{bundles: make(map[string][]bundleTip)}
+}
+
+// AddBlock adds the regular bundles of a block with at least two of them (megabundles are placed as a whole)
+func (a *TipElasticityAnalytics) AddBlock(block api.FlashbotsBlock) {
+	if a.StartBlock == 0 || block.BlockNumber < a.StartBlock {
+		a.StartBlock = block.BlockNumber
+	}
+	if block.BlockNumber > a.EndBlock {
+		a.EndBlock = block.BlockNumber
+	}
+
+	type bundleSum struct {
+		firstTxIndex int64
+		reward       *big.Int
+		gasUsed      int64
+	}
+	sums := make(map[int64]*bundleSum)
+	for _, tx := range block.Transactions {
+		if tx.BundleType == api.BundleTypeMegabundle {
+			continue
+		}
+		sum, found := sums[tx.BundleIndex]
+		if !found {
+			sum = &bundleSum{firstTxIndex: tx.TxIndex, reward: new(big.Int)}
+			sums[tx.BundleIndex] = sum
+		}
+		if tx.TxIndex < sum.firstTxIndex {
+			sum.firstTxIndex = tx.TxIndex
+		}
+		sum.reward.Add(sum.reward, common.StrToBigInt(tx.TotalMinerReward))
+		sum.gasUsed += tx.GasUsed
+	}
+	if len(sums) < 2 {
+		return
+	}
+
+	bundles := make([]*bundleSum, 0, len(sums))
+	for _, sum := range sums {
+		if sum.gasUsed > 0 {
+			bundles = append(bundles, sum)
+		}
+	}
+	sort.Slice(bundles, func(i, j int) bool { return bundles[i].firstTxIndex < bundles[j].firstTxIndex })
+
+	miner := strings.ToLower(block.Miner)
+	for rank, bundle := range bundles {
+		reward, _ := new(big.Float).SetInt(bundle.reward).Float64()
+		gasPrice := reward / float64(bundle.gasUsed)
+		if gasPrice <= 0 { // no log for 0-fee bundles
+			continue
+		}
+		a.bundles[miner] = append(a.bundles[miner], bundleTip{gasPrice: gasPrice, position: float64(rank) / float64(len(bundles)-1)})
+	}
+}
+
+// Miners returns the estimates of all miners with at least MinElasticityBundles ranked bundles, most bundles first
+func (a *TipElasticityAnalytics) Miners() (ret []*MinerTipElasticity) {
+	for miner, bundles := range a.bundles {
+		if len(bundles) >= MinElasticityBundles {
+			ret = append(ret, newMinerTipElasticity(miner, bundles))
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].NumBundles > ret[j].NumBundles })
+	return ret
+}
+
+func newMinerTipElasticity(miner string, bundles []bundleTip) *MinerTipElasticity {
+	e := &MinerTipElasticity{Miner: miner, NumBundles: len(bundles)}
+
+	// OLS of the position on log2(tip)
+	var meanX, meanY float64
+	for _, b := range bundles {
+		meanX += math.Log2(b.gasPrice)
+		meanY += b.position
+	}
+	meanX /= float64(len(bundles))
+	meanY /= float64(len(bundles))
+	var cov, varX float64
+	for _, b := range bundles {
+		dx := math.Log2(b.gasPrice) - meanX
+		cov += dx * (b.position - meanY)
+		varX += dx * dx
+	}
+	if varX > 0 {
+		e.PositionPerDoubling = cov / varX
+	}
+
+	// Top placement by tip quartile
+	sorted := make([]bundleTip, len(bundles))
+	copy(sorted, bundles)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].gasPrice < sorted[j].gasPrice })
+	for q := 0; q < 4; q++ {
+		quartile := sorted[len(sorted)*q/4 : len(sorted)*(q+1)/4]
+		if len(quartile) == 0 { // less than 4 bundles
+			continue
+		}
+		top := 0
+		for _, b := range quartile {
+			if b.position == 0 {
+				top += 1
+			}
+		}
+		e.QuartileTips[q] = quartile[len(quartile)/2].gasPrice
+		e.QuartileTopShare[q] = (float64(top) + 0.5) / (float64(len(quartile)) + 1) // smoothed, no log(0)
+	}
+
+	tipRatio := math.Log(e.QuartileTips[3] / e.QuartileTips[0])
+	if e.QuartileTips[0] > 0 && tipRatio > 0 {
+		e.Elasticity = math.Log(e.QuartileTopShare[3]/e.QuartileTopShare[0]) / tipRatio
+	}
+	return e
+}
+
+// String returns a report of the estimates of all miners
+func (a *TipElasticityAnalytics) String() (ret string) {
+	ret = fmt.Sprintf("Bundle tip elasticity by miner, blocks %d ... %d (min. %d ranked bundles):\n", a.StartBlock, a.EndBlock, MinElasticityBundles)
+	for _, e := range a.Miners() {
+		ret += fmt.Sprintf("%s \t bundles=%-6d positionPerDoubling=%+.3f elasticity=%.2f \t top share by tip quartile:", labels.Default.Format(e.Miner), e.NumBundles, e.PositionPerDoubling, e.Elasticity)
+		for q := 0; q < 4; q++ {
+			ret += fmt.Sprintf(" %.1f gwei=%.0f%%", e.QuartileTips[q]/1e9, e.QuartileTopShare[q]*100)
+		}
+		ret += "\n"
+	}
+	return ret
+}
@@ -0,0 +1,47 @@
+package analytics
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/metachris/flashbots/api"
+)
+
+func TestTipElasticityAnalytics(t *testing.T) {
+	MinElasticityBundles = 8
+	defer func() { MinElasticityBundles = 30 }()
+
+	// Miner A always places the higher tip first, miner B the other way around
+	a := NewTipElasticityAnalytics()
+	for i := int64(0); i < 4; i++ {
+		low := fmt.Sprint(1000 * (i + 1))
+		high := fmt.Sprint(10000 * (i + 1))
+		a.AddBlock(api.FlashbotsBlock{BlockNumber: 100 + i, Miner: "0xA", Transactions: []api.FlashbotsTransaction{
+			{TxIndex: 0, BundleIndex: 0, GasUsed: 1, TotalMinerReward: high},
+			{TxIndex: 1, BundleIndex: 1, GasUsed: 1, TotalMinerReward: low},
+		}})
+		a.AddBlock(api.FlashbotsBlock{BlockNumber: 200 + i, Miner: "0xB", Transactions: []api.FlashbotsTransaction{
+			{TxIndex: 0, BundleIndex: 0, GasUsed: 1, TotalMinerReward: low},
+			{TxIndex: 1, BundleIndex: 1, GasUsed: 1, TotalMinerReward: high},
+		}})
+	}
+
+	// Single bundle: no ranking
+	a.AddBlock(api.FlashbotsBlock{BlockNumber: 300, Miner: "0xA", Transactions: []api.FlashbotsTransaction{{TxIndex: 0, GasUsed: 1, TotalMinerReward: "1"}}})
+
+	miners := a.Miners()
+	if len(miners) != 2 || a.StartBlock != 100 || a.EndBlock != 300 {
+		t.Fatal("Unexpected miners:", miners, a.StartBlock, a.EndBlock)
+	}
+	for _, e := range miners {
+		if e.NumBundles != 8 {
+			t.Error("Unexpected number of bundles:", e.Miner, e.NumBundles)
+		}
+		if e.Miner == "0xa" && (e.PositionPerDoubling >= 0 || e.Elasticity <= 0) {
+			t.Errorf("Expected higher tips placed higher for miner A: %+v", e)
+		}
+		if e.Miner == "0xb" && (e.PositionPerDoubling <= 0 || e.Elasticity >= 0) {
+			t.Errorf("Expected higher tips placed lower for miner B: %+v", e)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/go-ethutils/blockswithtx"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+// A miner's balance grew less than expected by more than this share of the expected income (percent)
+var ThresholdPayoutDiscrepancyPercent float64 = 1
+
+// Hardforks which changed the static block reward
+const (
+	blockByzantium      = 4_370_000
+	blockConstantinople = 7_280_000
+	blockMerge          = 15_537_394
+)
+
+// BlockReward returns the static block reward of the miner (without uncle inclusion rewards, 0 after the merge)
+func BlockReward(blockNumber int64) *big.Int {
+	switch {
+	case blockNumber >= blockMerge:
+		return big.NewInt(0)
+	case blockNumber >= blockConstantinople:
+		return new(big.Int).Mul(big.NewInt(2), common.Ether)
+	case blockNumber >= blockByzantium:
+		return new(big.Int).Mul(big.NewInt(3), common.Ether)
+	default:
+		return new(big.Int).Mul(big.NewInt(5), common.Ether)
+	}
+}
+
+// BalanceReader is implemented by ethclient.Client
+type BalanceReader interface {
+	BalanceAt(ctx context.Context, account ethcommon.Address, blockNumber *big.Int) (*big.Int, error)
+}
+
+// MinerPayouts reconciles the income of a miner (as reported by the Flashbots API and the blocks) with the growth
+// of its coinbase balance
+type MinerPayouts struct {
+	Miner     string
+	MinerName string
+	Blocks    uint64
+
+	StartBlock   int64    // first block of the miner, StartBalance is the balance before it
+	StartBalance *big.Int // coinbase balance
+	EndBalance   *big.Int // coinbase balance at the last added block (set by Reconcile)
+
+	BundleRewards  *big.Int // total miner reward of the bundles, as reported by the Flashbots API
+	ExpectedIncome *big.Int // block rewards, priority fees, coinbase transfers (API for Flashbots tx) and direct transfers to the coinbase
+	KnownPayouts   *big.Int // value and gas fees of tx sent from the coinbase (eg. pool payouts)
+}
+
+// ExpectedGrowth is the expected income minus the known payouts
+func (m *MinerPayouts) ExpectedGrowth() *big.Int {
+	return new(big.Int).Sub(m.ExpectedIncome, m.KnownPayouts)
+}
+
+// Discrepancy is the actual minus the expected balance growth (nil before Reconcile). Negative if the miner received
+// less than expected, eg. because the API misreported coinbase transfers.
+func (m *MinerPayouts) Discrepancy() *big.Int {
+	if m.EndBalance == nil {
+		return nil
+	}
+	actual := new(big.Int).Sub(m.EndBalance, m.StartBalance)
+	return new(big.Int).Sub(actual, m.ExpectedGrowth())
+}
+
+// IsFlagged returns true if the balance grew less than expected by more than ThresholdPayoutDiscrepancyPercent of the
+// expected income
+func (m *MinerPayouts) IsFlagged() bool {
+	discrepancy := m.Discrepancy()
+	if discrepancy == nil || discrepancy.Sign() >= 0 {
+		return false
+	}
+
+	missing := new(big.Float).SetInt(new(big.Int).Neg(discrepancy))
+	threshold := new(big.Float).Mul(new(big.Float).SetInt(m.ExpectedIncome), big.NewFloat(ThresholdPayoutDiscrepancyPercent/100))
+	return missing.Cmp(threshold) == 1
+}
+
+// PayoutReconciler sums the rewards of every miner over a period (eg. a week), and reconciles them with the
+// coinbase balance growth. Every mined block must be added with AddBlock when it's received (also those without
+// Flashbots tx, and those which are never checked, eg. skipped because the API didn't index them in time): else their
+// income and payouts are missing while the balances change. The checks add the coinbase transfers reported by the
+// Flashbots API. Blocks must be added while their parent state is available on the node (ie. live, or with an archive
+// node). It's safe for concurrent use.
+type PayoutReconciler struct {
+	Client BalanceReader
+	Miners map[string]*MinerPayouts // by lowercase coinbase address
+
+	StartBlock int64
+	EndBlock   int64
+
+	lock          sync.Mutex
+	blocks        map[ethcommon.Hash]payoutDeltas // added blocks, with the amounts they added (see RemoveBlock)
+	checks        map[ethcommon.Hash]bool         // blocks whose API coinbase transfers were added
+	reconciledEnd int64                           // blocks up to this one belong to the previous period (see Reset)
+}
+
+// payoutDeltas are the amounts a block added to the miners
+type payoutDeltas map[*MinerPayouts]*MinerPayouts
+
+// of returns the amounts added to the miner
+func (d payoutDeltas) of(miner *MinerPayouts) *MinerPayouts {
+	if d[miner] == nil {
+		d[miner] = &MinerPayouts{BundleRewards: new(big.Int), ExpectedIncome: new(big.Int), KnownPayouts: new(big.Int)}
+	}
+	return d[miner]
+}
+
+// add adds the amounts of d to the miners (sign 1), or subtracts them (sign -1), and to total if set
+func (d payoutDeltas) add(sign int64, total payoutDeltas) {
+	for miner, delta := range d {
+		targets := []*MinerPayouts{miner}
+		if total != nil {
+			targets = append(targets, total.of(miner))
+		}
+		for _, m := range targets {
+			m.Blocks = uint64(int64(m.Blocks) + sign*int64(delta.Blocks))
+			m.BundleRewards.Add(m.BundleRewards, new(big.Int).Mul(delta.BundleRewards, big.NewInt(sign)))
+			m.ExpectedIncome.Add(m.ExpectedIncome, new(big.Int).Mul(delta.ExpectedIncome, big.NewInt(sign)))
+			m.KnownPayouts.Add(m.KnownPayouts, new(big.Int).Mul(delta.KnownPayouts, big.NewInt(sign)))
+		}
+	}
+}
+
+func NewPayoutReconciler(client BalanceReader) *PayoutReconciler {
+	return &PayoutReconciler{
+		Client: client,
+		Miners: make(map[string]*MinerPayouts),
+		blocks: make(map[ethcommon.Hash]payoutDeltas),
+		checks: make(map[ethcommon.Hash]bool),
+	}
+}
+
+// AddBlock adds the rewards of the block's miner (without the coinbase transfers of contract calls, see AddCheck), and
+// the payouts and transfers of all known miners in this block. Blocks which were added already are ignored.
+func (r *PayoutReconciler) AddBlock(block *blockswithtx.BlockWithTxReceipts) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if block.Block.Number().Int64() <= r.reconciledEnd {
+		return nil
+	}
+	_, err := r.addBlock(block)
+	return err
+}
+
+// AddCheck adds the coinbase transfers of the Flashbots tx as reported by the API (includes internal transfers), and
+// the block if it wasn't added yet
+func (r *PayoutReconciler) AddCheck(check *blockcheck.BlockCheck) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	hash := check.EthBlock.Hash()
+	if r.checks[hash] || check.Number <= r.reconciledEnd {
+		return nil
+	}
+	miner, err := r.addBlock(check.BlockWithTxReceipts)
+	if err != nil {
+		return err
+	}
+	r.checks[hash] = true
+	if miner.MinerName == "" {
+		miner.MinerName = check.MinerName
+	}
+
+	deltas := make(payoutDeltas)
+	delta := deltas.of(miner)
+	for _, bundle := range check.Bundles {
+		delta.BundleRewards.Add(delta.BundleRewards, bundle.TotalMinerReward)
+	}
+
+	for _, fbTx := range check.FlashbotsTransactions {
+		transfer, ok := new(big.Int).SetString(fbTx.CoinbaseTransfer, 10)
+		if ok {
+			delta.ExpectedIncome.Add(delta.ExpectedIncome, transfer)
+		}
+	}
+
+	// The direct transfers of Flashbots tx to the miner are included in the API coinbase transfers
+	for _, tx := range check.EthBlock.Transactions() {
+		receipt := check.BlockWithTxReceipts.TxReceipts[tx.Hash()]
+		if receipt != nil && isDirectTransfer(tx, receipt, check.EthBlock.Coinbase()) && check.IsFlashbotsTx(tx.Hash().Hex()) {
+			delta.ExpectedIncome.Sub(delta.ExpectedIncome, tx.Value())
+		}
+	}
+	deltas.add(1, r.blocks[hash])
+	return nil
+}
+
+// RemoveBlock removes the amounts a block and its check added (eg. after a reorg replaced the block)
+func (r *PayoutReconciler) RemoveBlock(hash ethcommon.Hash) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if deltas, found := r.blocks[hash]; found {
+		deltas.add(-1, nil)
+		delete(r.blocks, hash)
+		delete(r.checks, hash)
+	}
+}
+
+// isDirectTransfer returns true if the tx successfully sent value to the address
+func isDirectTransfer(tx *types.Transaction, receipt *types.Receipt, to ethcommon.Address) bool {
+	return tx.To() != nil && *tx.To() == to && receipt.Status == 1 && tx.Value().Sign() == 1
+}
+
+// addBlock adds the block if it wasn't added yet, and returns its miner
+func (r *PayoutReconciler) addBlock(block *blockswithtx.BlockWithTxReceipts) (*MinerPayouts, error) {
+	number := block.Block.Number().Int64()
+	coinbase := strings.ToLower(block.Block.Coinbase().Hex())
+	miner, found := r.Miners[coinbase]
+	if r.blocks[block.Block.Hash()] != nil {
+		return miner, nil
+	}
+
+	if r.StartBlock == 0 || number < r.StartBlock {
+		r.StartBlock = number
+	}
+	if number > r.EndBlock {
+		r.EndBlock = number
+	}
+
+	// The block's miner: start tracking with the balance before its first block
+	if !found {
+		balance, err := r.Client.BalanceAt(context.Background(), block.Block.Coinbase(), big.NewInt(number-1))
+		if err != nil {
+			return nil, fmt.Errorf("error getting balance of %s at block %d: %w", coinbase, number-1, err)
+		}
+
+		miner = &MinerPayouts{
+			Miner:          block.Block.Coinbase().Hex(), // the balance of the coinbase is reconciled
+			StartBlock:     number,
+			StartBalance:   balance,
+			BundleRewards:  new(big.Int),
+			ExpectedIncome: new(big.Int),
+			KnownPayouts:   new(big.Int),
+		}
+		r.Miners[coinbase] = miner
+	}
+
+	deltas := make(payoutDeltas)
+	delta := deltas.of(miner)
+	delta.Blocks = 1
+	delta.ExpectedIncome.Add(delta.ExpectedIncome, BlockReward(number))
+	uncleReward := new(big.Int).Div(BlockReward(number), big.NewInt(32))
+	delta.ExpectedIncome.Add(delta.ExpectedIncome, new(big.Int).Mul(uncleReward, big.NewInt(int64(len(block.Block.Uncles())))))
+
+	baseFee := block.Block.BaseFee()
+	for _, tx := range block.Block.Transactions() {
+		receipt := block.TxReceipts[tx.Hash()]
+		if receipt == nil {
+			continue
+		}
+		gasUsed := new(big.Int).SetUint64(receipt.GasUsed)
+
+		// Priority fees go to the block's miner
+		delta.ExpectedIncome.Add(delta.ExpectedIncome, new(big.Int).Mul(gasUsed, common.TxPriorityFee(tx, baseFee)))
+
+		// Direct transfers to a known miner
+		if tx.To() != nil {
+			if recipient, found := r.Miners[strings.ToLower(tx.To().Hex())]; found && isDirectTransfer(tx, receipt, *tx.To()) {
+				deltas.of(recipient).ExpectedIncome.Add(deltas.of(recipient).ExpectedIncome, tx.Value())
+			}
+		}
+
+		// Payouts: tx sent from a known miner
+		sender, err := utils.GetTxSender(tx)
+		if err != nil {
+			continue
+		}
+		if payer, found := r.Miners[strings.ToLower(sender.Hex())]; found {
+			payerDelta := deltas.of(payer)
+			payerDelta.KnownPayouts.Add(payerDelta.KnownPayouts, new(big.Int).Mul(gasUsed, common.TxEffectiveGasPrice(tx, baseFee)))
+			if receipt.Status == 1 {
+				payerDelta.KnownPayouts.Add(payerDelta.KnownPayouts, tx.Value())
+			}
+		}
+	}
+
+	r.blocks[block.Block.Hash()] = make(payoutDeltas)
+	deltas.add(1, r.blocks[block.Block.Hash()])
+	return miner, nil
+}
+
+// Reconcile fetches the balances of all miners at the last added block
+func (r *PayoutReconciler) Reconcile(ctx context.Context) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for address, miner := range r.Miners {
+		balance, err := r.Client.BalanceAt(ctx, ethcommon.HexToAddress(address), big.NewInt(r.EndBlock))
+		if err != nil {
+			return fmt.Errorf("error getting balance of %s at block %d: %w", address, r.EndBlock, err)
+		}
+		miner.EndBalance = balance
+	}
+	return nil
+}
+
+// Flagged returns the miners whose balance grew less than expected (after Reconcile)
+func (r *PayoutReconciler) Flagged() (miners []*MinerPayouts) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, miner := range r.sorted() {
+		if miner.IsFlagged() {
+			miners = append(miners, miner)
+		}
+	}
+	return miners
+}
+
+func (r *PayoutReconciler) sorted() []*MinerPayouts {
+	miners := make([]*MinerPayouts, 0, len(r.Miners))
+	for _, miner := range r.Miners {
+		miners = append(miners, miner)
+	}
+	sort.Slice(miners, func(i, j int) bool {
+		return miners[i].Blocks > miners[j].Blocks
+	})
+	return miners
+}
+
+// Reset starts a new period. Blocks and checks up to the last added block are ignored from now on, the balances of
+// the new period start after them.
+func (r *PayoutReconciler) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.Miners = make(map[string]*MinerPayouts)
+	r.blocks = make(map[ethcommon.Hash]payoutDeltas)
+	r.checks = make(map[ethcommon.Hash]bool)
+	if r.EndBlock > r.reconciledEnd {
+		r.reconciledEnd = r.EndBlock
+	}
+	r.StartBlock = 0
+	r.EndBlock = 0
+}
+
+// String returns the reconciliation report of the miners with Flashbots bundles (after Reconcile)
+func (r *PayoutReconciler) String() (ret string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, m := range r.sorted() {
+		if m.BundleRewards.Sign() == 0 || m.EndBalance == nil {
+			continue
+		}
+
+		name := m.MinerName
+		if name == "" {
+			name = m.Miner
+		}
+		flag := ""
+		if m.IsFlagged() {
+			flag = " <-- discrepancy"
+		}
+		actual := new(big.Int).Sub(m.EndBalance, m.StartBalance)
+		ret += fmt.Sprintf("%-42s blocks=%-5d bundleRewards=%10s ETH \t expectedGrowth=%10s ETH \t actualGrowth=%10s ETH \t diff=%10s ETH%s\n", name, m.Blocks, utils.WeiBigIntToEthString(m.BundleRewards, 4), utils.WeiBigIntToEthString(m.ExpectedGrowth(), 4), utils.WeiBigIntToEthString(actual, 4), utils.WeiBigIntToEthString(m.Discrepancy(), 4), flag)
+	}
+
+	if ret != "" {
+		ret = fmt.Sprintf("Miner payout reconciliation, blocks %d ... %d:\n", r.StartBlock, r.EndBlock) + ret
+	}
+	return ret
+}
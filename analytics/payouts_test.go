@@ -0,0 +1,153 @@
+package analytics
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+func TestMinerPayoutsDiscrepancy(t *testing.T) {
+	m := &MinerPayouts{
+		StartBalance:   big.NewInt(1000),
+		ExpectedIncome: big.NewInt(500),
+		KnownPayouts:   big.NewInt(200),
+	}
+	if m.Discrepancy() != nil || m.IsFlagged() {
+		t.Error("Discrepancy should be unknown before Reconcile")
+	}
+
+	// Expected growth 300, actual 298: within 1% of the expected income
+	m.EndBalance = big.NewInt(1298)
+	if m.Discrepancy().Int64() != -2 || m.IsFlagged() {
+		t.Error("Unexpected discrepancy:", m.Discrepancy(), m.IsFlagged())
+	}
+
+	// Actual 250: 50 missing
+	m.EndBalance = big.NewInt(1250)
+	if !m.IsFlagged() {
+		t.Error("Should be flagged:", m.Discrepancy())
+	}
+
+	// More than expected is not flagged (eg. untracked internal transfers)
+	m.EndBalance = big.NewInt(2000)
+	if m.IsFlagged() {
+		t.Error("Should not be flagged:", m.Discrepancy())
+	}
+}
+
+func TestBlockReward(t *testing.T) {
+	eth := big.NewInt(1e18)
+	if BlockReward(13_000_000).Cmp(new(big.Int).Mul(big.NewInt(2), eth)) != 0 {
+		t.Error("Unexpected block reward:", BlockReward(13_000_000))
+	}
+	if BlockReward(16_000_000).Sign() != 0 {
+		t.Error("Block reward should be 0 after the merge")
+	}
+}
+
+type zeroBalances struct{}
+
+func (zeroBalances) BalanceAt(ctx context.Context, account ethcommon.Address, blockNumber *big.Int) (*big.Int, error) {
+	return big.NewInt(0), nil
+}
+
+func newPayoutsTestBlock(number int64, coinbase ethcommon.Address, txs ...*types.Transaction) *blockswithtx.BlockWithTxReceipts {
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number), Coinbase: coinbase}).WithBody(txs, nil)
+	receipts := make(map[ethcommon.Hash]*types.Receipt)
+	for _, tx := range txs {
+		receipts[tx.Hash()] = &types.Receipt{Status: 1, GasUsed: 21000, TxHash: tx.Hash()}
+	}
+	return &blockswithtx.BlockWithTxReceipts{Block: block, TxReceipts: receipts}
+}
+
+func TestPayoutReconcilerAddsAllBlocks(t *testing.T) {
+	coinbase := ethcommon.HexToAddress("0x0000000000000000000000000000000000000001")
+	other := ethcommon.HexToAddress("0x0000000000000000000000000000000000000002")
+	gwei := big.NewInt(1e9)
+	eth := big.NewInt(1e18)
+
+	fbTx := types.NewTransaction(0, coinbase, eth, 21000, big.NewInt(0), nil) // direct coinbase transfer
+	checked := newPayoutsTestBlock(13_000_000, coinbase, fbTx, types.NewTransaction(1, other, nil, 21000, gwei, nil))
+	skipped := newPayoutsTestBlock(13_000_001, coinbase, types.NewTransaction(2, other, nil, 21000, gwei, nil))
+
+	r := NewPayoutReconciler(zeroBalances{})
+	for _, block := range []*blockswithtx.BlockWithTxReceipts{checked, skipped, checked} {
+		if err := r.AddBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The API reports 1.5 ETH coinbase transfer of the Flashbots tx (1 ETH direct, 0.5 ETH internal)
+	check := &blockcheck.BlockCheck{
+		Number:                13_000_000,
+		EthBlock:              checked.Block,
+		BlockWithTxReceipts:   checked,
+		MinerName:             "miner",
+		FlashbotsTransactions: []api.FlashbotsTransaction{{Hash: fbTx.Hash().Hex(), CoinbaseTransfer: "1500000000000000000"}},
+	}
+	for i := 0; i < 2; i++ {
+		if err := r.AddCheck(check); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 2 block rewards of 2 ETH, 1.5 ETH coinbase transfer, 2 * 21000 gwei priority fees
+	miner := r.Miners[strings.ToLower(coinbase.Hex())]
+	expected := new(big.Int).Add(new(big.Int).Mul(big.NewInt(5.5e9), gwei), new(big.Int).Mul(big.NewInt(42000), gwei))
+	if miner == nil || miner.Blocks != 2 || miner.ExpectedIncome.Cmp(expected) != 0 || miner.MinerName != "miner" {
+		t.Fatalf("unexpected miner payouts: %+v", miner)
+	}
+	if r.StartBlock != 13_000_000 || r.EndBlock != 13_000_001 {
+		t.Error("unexpected period:", r.StartBlock, r.EndBlock)
+	}
+
+	// The blocks of the previous period are ignored after Reset
+	r.Reset()
+	if err := r.AddCheck(check); err != nil || len(r.Miners) != 0 {
+		t.Error("expected the check of the previous period to be ignored", r.Miners, err)
+	}
+}
+
+func TestPayoutReconcilerReorg(t *testing.T) {
+	coinbase := ethcommon.HexToAddress("0x0000000000000000000000000000000000000001")
+	other := ethcommon.HexToAddress("0x0000000000000000000000000000000000000002")
+	gwei := big.NewInt(1e9)
+
+	block := newPayoutsTestBlock(13_000_000, coinbase, types.NewTransaction(0, other, nil, 21000, gwei, nil))
+	orphan := newPayoutsTestBlock(13_000_001, coinbase, types.NewTransaction(1, other, nil, 21000, new(big.Int).Mul(big.NewInt(100), gwei), nil))
+	canonical := newPayoutsTestBlock(13_000_001, coinbase, types.NewTransaction(2, other, nil, 21000, gwei, nil))
+
+	r := NewPayoutReconciler(zeroBalances{})
+	check := &blockcheck.BlockCheck{Number: 13_000_000, EthBlock: block.Block, BlockWithTxReceipts: block, Bundles: []*common.Bundle{{TotalMinerReward: big.NewInt(5)}}}
+	if err := r.AddCheck(check); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddBlock(orphan); err != nil {
+		t.Fatal(err)
+	}
+
+	// The orphan is replaced, and the checked block re-checked
+	r.RemoveBlock(orphan.Block.Hash())
+	if err := r.AddBlock(canonical); err != nil {
+		t.Fatal(err)
+	}
+	r.RemoveBlock(block.Block.Hash())
+	if err := r.AddCheck(check); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 block rewards of 2 ETH, 2 * 21000 gwei priority fees
+	miner := r.Miners[strings.ToLower(coinbase.Hex())]
+	expected := new(big.Int).Add(new(big.Int).Mul(big.NewInt(4e9), gwei), new(big.Int).Mul(big.NewInt(42000), gwei))
+	if miner.Blocks != 2 || miner.ExpectedIncome.Cmp(expected) != 0 || miner.BundleRewards.Int64() != 5 {
+		t.Errorf("unexpected miner payouts after the reorg: blocks %d, expected income %s, bundle rewards %s", miner.Blocks, miner.ExpectedIncome, miner.BundleRewards)
+	}
+}
@@ -0,0 +1,97 @@
+package analytics
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/flashbots/labels"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+// SearcherRefund are the fees a searcher paid for failed Flashbots and 0-gas transactions. With revert protection
+// (eg. sending via Flashbots Protect), failed transactions are not included, so these fees would have been saved.
+type SearcherRefund struct {
+	Address string
+
+	NumFailedTx  uint64
+	GasUsed      uint64
+	GasFees      *big.Int // gas_used * effective gas price (base fee + priority fee)
+	PriorityFees *big.Int // gas_used * priority fee (the part paid to the miner)
+}
+
+// RefundEstimator aggregates the potential savings of revert protection per searcher (advisory)
+type RefundEstimator struct {
+	Searchers map[string]*SearcherRefund
+
+	StartBlock int64
+	EndBlock   int64
+}
+
+func NewRefundEstimator() *RefundEstimator {
+	return &RefundEstimator{
+		Searchers: make(map[string]*SearcherRefund),
+	}
+}
+
+// AddCheck adds the failed transactions of a checked block
+func (r *RefundEstimator) AddCheck(check *blockcheck.BlockCheck) {
+	if r.StartBlock == 0 || check.Number < r.StartBlock {
+		r.StartBlock = check.Number
+	}
+	if check.Number > r.EndBlock {
+		r.EndBlock = check.Number
+	}
+
+	baseFee := check.EthBlock.BaseFee()
+	for _, failedTx := range check.FailedTx {
+		txHash := ethcommon.HexToHash(failedTx.Hash)
+		tx := check.EthBlock.Transaction(txHash)
+		receipt := check.BlockWithTxReceipts.TxReceipts[txHash]
+		if tx == nil || receipt == nil {
+			continue
+		}
+
+		address := strings.ToLower(failedTx.From)
+		searcher, found := r.Searchers[address]
+		if !found {
+			searcher = &SearcherRefund{Address: address, GasFees: new(big.Int), PriorityFees: new(big.Int)}
+			r.Searchers[address] = searcher
+		}
+
+		gasUsed := new(big.Int).SetUint64(receipt.GasUsed)
+		searcher.NumFailedTx += 1
+		searcher.GasUsed += receipt.GasUsed
+		searcher.GasFees.Add(searcher.GasFees, new(big.Int).Mul(gasUsed, common.TxEffectiveGasPrice(tx, baseFee)))
+		searcher.PriorityFees.Add(searcher.PriorityFees, new(big.Int).Mul(gasUsed, common.TxPriorityFee(tx, baseFee)))
+	}
+}
+
+// Top returns the n searchers with the highest potential savings (all if n <= 0)
+func (r *RefundEstimator) Top(n int) []*SearcherRefund {
+	searchers := make([]*SearcherRefund, 0, len(r.Searchers))
+	for _, s := range r.Searchers {
+		searchers = append(searchers, s)
+	}
+	sort.Slice(searchers, func(i, j int) bool {
+		return searchers[i].GasFees.Cmp(searchers[j].GasFees) == 1
+	})
+
+	if n > 0 && len(searchers) > n {
+		searchers = searchers[:n]
+	}
+	return searchers
+}
+
+// String returns an advisory report of the top n searchers
+func (r *RefundEstimator) String(n int) (ret string) {
+	ret = fmt.Sprintf("Potential savings with revert protection (failed tx fees), blocks %d ... %d:\n", r.StartBlock, r.EndBlock)
+	for i, s := range r.Top(n) {
+		ret += fmt.Sprintf("%3d. %s \t failedTx=%-5d gasUsed=%-11d gasFees=%10s ETH \t priorityFees=%10s ETH\n", i+1, labels.SearcherDisclosure.Format(s.Address), s.NumFailedTx, s.GasUsed, utils.WeiBigIntToEthString(s.GasFees, 4), utils.WeiBigIntToEthString(s.PriorityFees, 4))
+	}
+	return ret
+}
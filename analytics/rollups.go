@@ -0,0 +1,402 @@
+package analytics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/flashbots/labels"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+// Rollup granularities
+const (
+	RollupHour = "hour"
+	RollupDay  = "day"
+)
+
+// Hourly rollups older than this are dropped (daily rollups are kept)
+var DefaultHourlyRetention = 90 * 24 * time.Hour
+
+// RollupMinerStats are the stats of a miner in a rollup
+type RollupMinerStats struct {
+	MinerName   string           `json:"miner_name,omitempty"`
+	Blocks      int64            `json:"blocks"`
+	ErrorBlocks int64            `json:"error_blocks"`
+	Bundles     int64            `json:"bundles"`
+	Errors      map[string]int64 `json:"errors"` // by error type
+}
+
+// RollupSearcherStats are the stats of a searcher (bundle EOA) in a rollup
+type RollupSearcherStats struct {
+	Bundles     int64    `json:"bundles"`
+	Tx          int64    `json:"tx"`
+	MinerReward *big.Int `json:"miner_reward"`
+}
+
+// Rollup aggregates all blocks of one hour or day (or, as result of Query, of a time range)
+type Rollup struct {
+	Start time.Time `json:"start"`
+
+	Blocks      int64 `json:"blocks"`
+	ErrorBlocks int64 `json:"error_blocks"`
+	Bundles     int64 `json:"bundles"`
+
+	Miners     map[string]*RollupMinerStats    `json:"miners"`      // by miner address
+	Searchers  map[string]*RollupSearcherStats `json:"searchers"`   // by lowercase EOA address
+	ErrorTypes map[string]int64                `json:"error_types"` // blocks by error type
+}
+
+func NewRollup(start time.Time) *Rollup {
+	return &Rollup{
+		Start:      start,
+		Miners:     make(map[string]*RollupMinerStats),
+		Searchers:  make(map[string]*RollupSearcherStats),
+		ErrorTypes: make(map[string]int64),
+	}
+}
+
+// RollupBlock is the data of a checked block which is added to the rollups
+type RollupBlock struct {
+	Number       int64
+	Time         time.Time
+	Miner        string
+	MinerName    string
+	HasErrors    bool
+	Errors       map[string]uint64 // counts by error type
+	Transactions []api.FlashbotsTransaction
+}
+
+func NewRollupBlock(check *blockcheck.BlockCheck) RollupBlock {
+	return RollupBlock{
+		Number:       check.Number,
+		Time:         time.Unix(int64(check.EthBlock.Time()), 0),
+		Miner:        check.Miner,
+		MinerName:    check.MinerName,
+		HasErrors:    check.HasSeriousErrors() || check.HasLessSeriousErrors(),
+		Errors:       check.ErrorCounter.Map(),
+		Transactions: check.FlashbotsTransactions,
+	}
+}
+
+// add adds the block to the rollup, or removes it with sign -1 (eg. after a reorg)
+func (r *Rollup) add(b RollupBlock, sign int64) {
+	miner, found := r.Miners[b.Miner]
+	if !found {
+		miner = &RollupMinerStats{MinerName: b.MinerName, Errors: make(map[string]int64)}
+		r.Miners[b.Miner] = miner
+	}
+
+	r.Blocks += sign
+	miner.Blocks += sign
+	if b.HasErrors {
+		r.ErrorBlocks += sign
+		miner.ErrorBlocks += sign
+		for errorType, count := range b.Errors {
+			r.ErrorTypes[errorType] += sign
+			miner.Errors[errorType] += sign * int64(count)
+		}
+	}
+
+	bundlesCounted := make(map[string]bool)
+	for _, tx := range b.Transactions {
+		address := strings.ToLower(tx.EoaAddress)
+		searcher, found := r.Searchers[address]
+		if !found {
+			searcher = &RollupSearcherStats{MinerReward: new(big.Int)}
+			r.Searchers[address] = searcher
+		}
+
+		// A bundle counts once for the block, and once per searcher
+		bundleKey := fmt.Sprintf("%s-%d", tx.BundleType, tx.BundleIndex)
+		if !bundlesCounted[bundleKey] {
+			r.Bundles += sign
+			miner.Bundles += sign
+			bundlesCounted[bundleKey] = true
+		}
+		if !bundlesCounted[bundleKey+address] {
+			searcher.Bundles += sign
+			bundlesCounted[bundleKey+address] = true
+		}
+
+		searcher.Tx += sign
+		reward := new(big.Int).Mul(common.StrToBigInt(tx.TotalMinerReward), big.NewInt(sign))
+		searcher.MinerReward.Add(searcher.MinerReward, reward)
+	}
+}
+
+// merge adds all stats of another rollup
+func (r *Rollup) merge(other *Rollup) {
+	r.Blocks += other.Blocks
+	r.ErrorBlocks += other.ErrorBlocks
+	r.Bundles += other.Bundles
+	for errorType, count := range other.ErrorTypes {
+		r.ErrorTypes[errorType] += count
+	}
+
+	for address, m := range other.Miners {
+		miner, found := r.Miners[address]
+		if !found {
+			miner = &RollupMinerStats{MinerName: m.MinerName, Errors: make(map[string]int64)}
+			r.Miners[address] = miner
+		}
+		miner.Blocks += m.Blocks
+		miner.ErrorBlocks += m.ErrorBlocks
+		miner.Bundles += m.Bundles
+		for errorType, count := range m.Errors {
+			miner.Errors[errorType] += count
+		}
+	}
+
+	for address, s := range other.Searchers {
+		searcher, found := r.Searchers[address]
+		if !found {
+			searcher = &RollupSearcherStats{MinerReward: new(big.Int)}
+			r.Searchers[address] = searcher
+		}
+		searcher.Bundles += s.Bundles
+		searcher.Tx += s.Tx
+		searcher.MinerReward.Add(searcher.MinerReward, s.MinerReward)
+	}
+}
+
+// RollupMiner is a miner with its stats in a rollup (see TopMiners)
+type RollupMiner struct {
+	Address string `json:"address"`
+	RollupMinerStats
+}
+
+// TopMiners returns the n miners with the most bundles (all with n <= 0), by blocks if equal
+func (r *Rollup) TopMiners(n int) []RollupMiner {
+	miners := make([]RollupMiner, 0, len(r.Miners))
+	for address, m := range r.Miners {
+		miners = append(miners, RollupMiner{Address: address, RollupMinerStats: *m})
+	}
+	sort.Slice(miners, func(i, j int) bool {
+		if miners[i].Bundles != miners[j].Bundles {
+			return miners[i].Bundles > miners[j].Bundles
+		}
+		if miners[i].Blocks != miners[j].Blocks {
+			return miners[i].Blocks > miners[j].Blocks
+		}
+		return miners[i].Address < miners[j].Address
+	})
+	if n > 0 && len(miners) > n {
+		miners = miners[:n]
+	}
+	return miners
+}
+
+// String returns the totals, the miners with errors and the top 10 searchers
+func (r *Rollup) String() (ret string) {
+	ret = fmt.Sprintf("blocks: %d, error blocks: %d, bundles: %d\n", r.Blocks, r.ErrorBlocks, r.Bundles)
+
+	errorTypes := make([]string, 0, len(r.ErrorTypes))
+	for errorType := range r.ErrorTypes {
+		errorTypes = append(errorTypes, errorType)
+	}
+	sort.Strings(errorTypes)
+	for _, errorType := range errorTypes {
+		ret += fmt.Sprintf("- %-40s blocks=%d\n", errorType, r.ErrorTypes[errorType])
+	}
+
+	miners := make([]string, 0, len(r.Miners))
+	for address, m := range r.Miners {
+		if m.ErrorBlocks > 0 {
+			miners = append(miners, address)
+		}
+	}
+	sort.Slice(miners, func(i, j int) bool { return r.Miners[miners[i]].ErrorBlocks > r.Miners[miners[j]].ErrorBlocks })
+	if len(miners) > 0 {
+		ret += "Miners with errors:\n"
+	}
+	for _, address := range miners {
+		m := r.Miners[address]
+		minerId := address
+		if m.MinerName != "" {
+			minerId += fmt.Sprintf(" (%s)", m.MinerName)
+		}
+		ret += fmt.Sprintf("- %-66s errorBlocks=%d/%d \t bundles=%d\n", minerId, m.ErrorBlocks, m.Blocks, m.Bundles)
+	}
+
+	searchers := make([]string, 0, len(r.Searchers))
+	for address := range r.Searchers {
+		searchers = append(searchers, address)
+	}
+	sort.Slice(searchers, func(i, j int) bool {
+		return r.Searchers[searchers[i]].MinerReward.Cmp(r.Searchers[searchers[j]].MinerReward) == 1
+	})
+	if len(searchers) > 10 {
+		searchers = searchers[:10]
+	}
+	if len(searchers) > 0 {
+		ret += "Top searchers by miner reward:\n"
+	}
+	for _, address := range searchers {
+		s := r.Searchers[address]
+		ret += fmt.Sprintf("- %s \t bundles=%-6d tx=%-6d minerReward=%10s ETH\n", labels.SearcherDisclosure.Format(address), s.Bundles, s.Tx, utils.WeiBigIntToEthString(s.MinerReward, 4))
+	}
+	return ret
+}
+
+// Rollups maintains pre-aggregated hourly and daily stats, updated incrementally with every block. Queries over
+// long time ranges only sum the daily rollups, instead of processing every block. It's safe for concurrent use.
+type Rollups struct {
+	Hourly map[int64]*Rollup `json:"hourly"` // by unix timestamp of the start of the hour (UTC)
+	Daily  map[int64]*Rollup `json:"daily"`  // by unix timestamp of the start of the day (UTC)
+
+	HourlyRetention time.Duration `json:"-"` // 0 = keep forever
+
+	lock sync.Mutex
+}
+
+func NewRollups() *Rollups {
+	return &Rollups{
+		Hourly:          make(map[int64]*Rollup),
+		Daily:           make(map[int64]*Rollup),
+		HourlyRetention: DefaultHourlyRetention,
+	}
+}
+
+// rollupStart returns the start of the hour or day of t (UTC)
+func rollupStart(granularity string, t time.Time) time.Time {
+	if granularity == RollupDay {
+		return t.UTC().Truncate(24 * time.Hour)
+	}
+	return t.UTC().Truncate(time.Hour)
+}
+
+func (r *Rollups) rollups(granularity string) map[int64]*Rollup {
+	if granularity == RollupDay {
+		return r.Daily
+	}
+	return r.Hourly
+}
+
+func (r *Rollups) update(b RollupBlock, sign int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, granularity := range []string{RollupHour, RollupDay} {
+		start := rollupStart(granularity, b.Time)
+		rollups := r.rollups(granularity)
+		rollup, found := rollups[start.Unix()]
+		if !found {
+			rollup = NewRollup(start)
+			rollups[start.Unix()] = rollup
+		}
+		rollup.add(b, sign)
+	}
+	r.prune(b.Time)
+}
+
+// Add adds a block to its hourly and daily rollup
+func (r *Rollups) Add(b RollupBlock) {
+	r.update(b, 1)
+}
+
+// Remove removes a previously added block (eg. a block replaced in a reorg)
+func (r *Rollups) Remove(b RollupBlock) {
+	r.update(b, -1)
+}
+
+// AddCheck adds a checked block (should be called for every block, not only blocks with errors)
+func (r *Rollups) AddCheck(check *blockcheck.BlockCheck) {
+	r.Add(NewRollupBlock(check))
+}
+
+// RemoveCheck removes a previously added check (eg. of a block replaced in a reorg)
+func (r *Rollups) RemoveCheck(check *blockcheck.BlockCheck) {
+	r.Remove(NewRollupBlock(check))
+}
+
+func (r *Rollups) prune(now time.Time) {
+	if r.HourlyRetention <= 0 {
+		return
+	}
+	for start := range r.Hourly {
+		if now.Sub(time.Unix(start, 0)) > r.HourlyRetention {
+			delete(r.Hourly, start)
+		}
+	}
+}
+
+// Query sums the hourly or daily rollups in the time range [from, to). from and to are rounded down to the
+// granularity.
+func (r *Rollups) Query(granularity string, from time.Time, to time.Time) *Rollup {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	fromStart := rollupStart(granularity, from)
+	toStart := rollupStart(granularity, to)
+
+	result := NewRollup(fromStart)
+	for start, rollup := range r.rollups(granularity) {
+		if start >= fromStart.Unix() && start < toStart.Unix() {
+			result.merge(rollup)
+		}
+	}
+	return result
+}
+
+// Series returns copies of the hourly or daily rollups in the time range [from, to), sorted by time (eg. for charts)
+func (r *Rollups) Series(granularity string, from time.Time, to time.Time) (series []*Rollup) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	fromStart := rollupStart(granularity, from)
+	toStart := rollupStart(granularity, to)
+	for start, rollup := range r.rollups(granularity) {
+		if start >= fromStart.Unix() && start < toStart.Unix() {
+			copied := NewRollup(rollup.Start)
+			copied.merge(rollup)
+			series = append(series, copied)
+		}
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Start.Before(series[j].Start) })
+	return series
+}
+
+// Save writes the rollups to a JSON file
+func (r *Rollups) Save(filename string) error {
+	r.lock.Lock()
+	data, err := json.Marshal(r)
+	r.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so the rollups are never half-written
+	tmpFile := filename + ".tmp"
+	err = os.WriteFile(tmpFile, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, filename)
+}
+
+// LoadRollups reads the rollups from a JSON file, or returns empty rollups if the file doesn't exist
+func LoadRollups(filename string) (*Rollups, error) {
+	r := NewRollups()
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, r)
+	if err != nil {
+		return nil, fmt.Errorf("rollups %s: %w", filename, err)
+	}
+	return r, nil
+}
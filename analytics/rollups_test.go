@@ -0,0 +1,83 @@
+package analytics
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/metachris/flashbots/api"
+)
+
+func TestRollups(t *testing.T) {
+	day := time.Date(2021, 8, 20, 0, 0, 0, 0, time.UTC)
+	blocks := []RollupBlock{
+		{Number: 1, Time: day.Add(10 * time.Minute), Miner: "0xM1", Transactions: []api.FlashbotsTransaction{
+			{BundleIndex: 0, EoaAddress: "0xAAA", TotalMinerReward: "1000"},
+			{BundleIndex: 0, EoaAddress: "0xAAA", TotalMinerReward: "1000"},
+			{BundleIndex: 1, EoaAddress: "0xBBB", TotalMinerReward: "500"},
+		}},
+		{Number: 2, Time: day.Add(70 * time.Minute), Miner: "0xM1", HasErrors: true, Errors: map[string]uint64{"bundle-order": 1}},
+		{Number: 3, Time: day.Add(25 * time.Hour), Miner: "0xM2", HasErrors: true, Errors: map[string]uint64{"failed-tx": 2}},
+	}
+
+	r := NewRollups()
+	r.HourlyRetention = 0
+	for _, b := range blocks {
+		r.Add(b)
+	}
+
+	if len(r.Hourly) != 3 || len(r.Daily) != 2 {
+		t.Fatal("Wrong number of rollups:", len(r.Hourly), len(r.Daily))
+	}
+
+	first := r.Query(RollupHour, day, day.Add(time.Hour))
+	if first.Blocks != 1 || first.Bundles != 2 || first.Searchers["0xaaa"].Bundles != 1 || first.Searchers["0xaaa"].Tx != 2 || first.Searchers["0xaaa"].MinerReward.Int64() != 2000 {
+		t.Error("Unexpected first hour:", first)
+	}
+
+	all := r.Query(RollupDay, day, day.Add(48*time.Hour))
+	if all.Blocks != 3 || all.ErrorBlocks != 2 || all.ErrorTypes["failed-tx"] != 1 || all.Miners["0xM2"].Errors["failed-tx"] != 2 || all.Miners["0xM1"].Blocks != 2 {
+		t.Error("Unexpected total:", all)
+	}
+
+	// Remove the block with errors again (reorg)
+	r.Remove(blocks[1])
+	all = r.Query(RollupDay, day, day.Add(48*time.Hour))
+	if all.Blocks != 2 || all.ErrorBlocks != 1 || all.ErrorTypes["bundle-order"] != 0 {
+		t.Error("Unexpected total after remove:", all)
+	}
+
+	if series := r.Series(RollupDay, day, day.Add(48*time.Hour)); len(series) != 2 || !series[0].Start.Equal(day) {
+		t.Error("Unexpected series:", series)
+	}
+
+	if top := all.TopMiners(1); len(top) != 1 || top[0].Address != "0xM1" || top[0].Bundles != 2 || top[0].Blocks != 1 {
+		t.Error("Unexpected top miners:", top)
+	}
+
+	// Save and load
+	filename := filepath.Join(t.TempDir(), "rollups.json")
+	if err := r.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadRollups(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.Add(blocks[1])
+	if q := loaded.Query(RollupDay, day, day.Add(48*time.Hour)); q.Blocks != 3 || q.Searchers["0xbbb"].MinerReward.Int64() != 500 {
+		t.Error("Unexpected total after load:", q)
+	}
+}
+
+func TestRollupsHourlyRetention(t *testing.T) {
+	now := time.Date(2021, 8, 20, 12, 0, 0, 0, time.UTC)
+	r := NewRollups()
+	r.HourlyRetention = 24 * time.Hour
+	r.Add(RollupBlock{Number: 1, Time: now.Add(-48 * time.Hour), Miner: "0xM1"})
+	r.Add(RollupBlock{Number: 2, Time: now, Miner: "0xM1"})
+
+	if len(r.Hourly) != 1 || len(r.Daily) != 2 {
+		t.Error("Wrong number of rollups:", len(r.Hourly), len(r.Daily))
+	}
+}
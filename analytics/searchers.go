@@ -0,0 +1,189 @@
+// Package analytics aggregates mev-blocks API data, eg. per searcher.
+package analytics
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/flashbots/labels"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+// Searchers can be identified by the EOA sending the bundle transactions, or the contract they call
+const (
+	GroupByEoa      = "eoa"
+	GroupByContract = "contract"
+)
+
+type SearcherStats struct {
+	Address string
+
+	NumBundles   uint64
+	NumTx        uint64
+	NumFailedTx  uint64 // only counted if the tx status is known
+	NumTxChecked uint64 // transactions with known status
+	GasUsed      uint64
+
+	MinerPayments     *big.Int // total_miner_reward (gas fees + coinbase transfers)
+	CoinbaseTransfers *big.Int
+	GasSpend          *big.Int // gas_used * gas_price
+
+	// Bundles below common.MinBundleRewardEth, and their miner payments (included in the totals above)
+	NumDustBundles    uint64
+	DustMinerPayments *big.Int
+}
+
+func NewSearcherStats(address string) *SearcherStats {
+	return &SearcherStats{
+		Address:           address,
+		MinerPayments:     new(big.Int),
+		CoinbaseTransfers: new(big.Int),
+		GasSpend:          new(big.Int),
+		DustMinerPayments: new(big.Int),
+	}
+}
+
+// FilteredMinerPayments returns the miner payments without dust bundles
+func (s *SearcherStats) FilteredMinerPayments() *big.Int {
+	return new(big.Int).Sub(s.MinerPayments, s.DustMinerPayments)
+}
+
+// SuccessRate returns the share of successful transactions, or -1 if no tx status is known
+func (s *SearcherStats) SuccessRate() float64 {
+	if s.NumTxChecked == 0 {
+		return -1
+	}
+	return float64(s.NumTxChecked-s.NumFailedTx) / float64(s.NumTxChecked)
+}
+
+type SearcherAnalytics struct {
+	GroupBy   string
+	Searchers map[string]*SearcherStats
+
+	StartBlock int64
+	EndBlock   int64
+}
+
+func NewSearcherAnalytics(groupBy string) *SearcherAnalytics {
+	return &SearcherAnalytics{
+		GroupBy:   groupBy,
+		Searchers: make(map[string]*SearcherStats),
+	}
+}
+
+func (a *SearcherAnalytics) searcherAddress(tx api.FlashbotsTransaction) string {
+	if a.GroupBy == GroupByContract {
+		return strings.ToLower(tx.ToAddress)
+	}
+	return strings.ToLower(tx.EoaAddress)
+}
+
+// AddBlock adds all bundles of a block. txStatus (optional) contains the success of transactions by hash.
+func (a *SearcherAnalytics) AddBlock(block api.FlashbotsBlock, txStatus map[string]bool) {
+	if a.StartBlock == 0 || block.BlockNumber < a.StartBlock {
+		a.StartBlock = block.BlockNumber
+	}
+	if block.BlockNumber > a.EndBlock {
+		a.EndBlock = block.BlockNumber
+	}
+
+	// Dust is decided by the reward of the whole bundle
+	bundleRewards := make(map[string]*big.Int)
+	for _, tx := range block.Transactions {
+		bundleKey := fmt.Sprintf("%s-%d", tx.BundleType, tx.BundleIndex)
+		if bundleRewards[bundleKey] == nil {
+			bundleRewards[bundleKey] = new(big.Int)
+		}
+		bundleRewards[bundleKey].Add(bundleRewards[bundleKey], common.StrToBigInt(tx.TotalMinerReward))
+	}
+
+	bundlesCounted := make(map[string]bool) // a bundle counts once per searcher
+	for _, tx := range block.Transactions {
+		address := a.searcherAddress(tx)
+		searcher, found := a.Searchers[address]
+		if !found {
+			searcher = NewSearcherStats(address)
+			a.Searchers[address] = searcher
+		}
+
+		bundleKey := fmt.Sprintf("%s-%d", tx.BundleType, tx.BundleIndex)
+		isDust := common.IsDustReward(bundleRewards[bundleKey])
+		if !bundlesCounted[bundleKey+address] {
+			searcher.NumBundles += 1
+			if isDust {
+				searcher.NumDustBundles += 1
+			}
+			bundlesCounted[bundleKey+address] = true
+		}
+
+		searcher.NumTx += 1
+		searcher.GasUsed += uint64(tx.GasUsed)
+		searcher.MinerPayments.Add(searcher.MinerPayments, common.StrToBigInt(tx.TotalMinerReward))
+		if isDust {
+			searcher.DustMinerPayments.Add(searcher.DustMinerPayments, common.StrToBigInt(tx.TotalMinerReward))
+		}
+		searcher.CoinbaseTransfers.Add(searcher.CoinbaseTransfers, common.StrToBigInt(tx.CoinbaseTransfer))
+		searcher.GasSpend.Add(searcher.GasSpend, new(big.Int).Mul(big.NewInt(tx.GasUsed), common.StrToBigInt(tx.GasPrice)))
+
+		if success, known := txStatus[tx.Hash]; known {
+			searcher.NumTxChecked += 1
+			if !success {
+				searcher.NumFailedTx += 1
+			}
+		}
+	}
+}
+
+// Top returns the n searchers with the highest miner payments (all if n <= 0). With filterDust, searchers are
+// ranked by the miner payments without dust bundles, and searchers with only dust bundles are left out.
+func (a *SearcherAnalytics) Top(n int, filterDust bool) []*SearcherStats {
+	searchers := make([]*SearcherStats, 0, len(a.Searchers))
+	for _, s := range a.Searchers {
+		if filterDust && s.NumDustBundles == s.NumBundles {
+			continue
+		}
+		searchers = append(searchers, s)
+	}
+	sort.Slice(searchers, func(i, j int) bool {
+		if filterDust {
+			return searchers[i].FilteredMinerPayments().Cmp(searchers[j].FilteredMinerPayments()) == 1
+		}
+		return searchers[i].MinerPayments.Cmp(searchers[j].MinerPayments) == 1
+	})
+
+	if n > 0 && len(searchers) > n {
+		searchers = searchers[:n]
+	}
+	return searchers
+}
+
+// String returns a report of the top n searchers, and if common.MinBundleRewardEth is set, also without dust bundles
+func (a *SearcherAnalytics) String(n int) (ret string) {
+	ret = fmt.Sprintf("Top searchers by miner payments (by %s), blocks %d ... %d:\n", a.GroupBy, a.StartBlock, a.EndBlock)
+	ret += a.leaderboard(n, false)
+	if common.MinBundleRewardEth > 0 {
+		ret += fmt.Sprintf("\nWithout dust bundles (miner reward < %v ETH):\n", common.MinBundleRewardEth)
+		ret += a.leaderboard(n, true)
+	}
+	return ret
+}
+
+func (a *SearcherAnalytics) leaderboard(n int, filterDust bool) (ret string) {
+	for i, s := range a.Top(n, filterDust) {
+		minerPayments, numBundles := s.MinerPayments, s.NumBundles
+		if filterDust {
+			minerPayments, numBundles = s.FilteredMinerPayments(), s.NumBundles-s.NumDustBundles
+		}
+
+		successRate := "-"
+		if rate := s.SuccessRate(); rate >= 0 {
+			successRate = fmt.Sprintf("%.2f%%", rate*100)
+		}
+		ret += fmt.Sprintf("%3d. %s \t bundles=%-6d dust=%-6d tx=%-6d minerPayments=%10s ETH \t coinbaseTransfers=%10s ETH \t gasSpend=%10s ETH \t gasUsed=%-11d success=%s\n", i+1, labels.SearcherDisclosure.Format(s.Address), numBundles, s.NumDustBundles, s.NumTx, utils.WeiBigIntToEthString(minerPayments, 4), utils.WeiBigIntToEthString(s.CoinbaseTransfers, 4), utils.WeiBigIntToEthString(s.GasSpend, 4), s.GasUsed, successRate)
+	}
+	return ret
+}
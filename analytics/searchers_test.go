@@ -0,0 +1,76 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/common"
+)
+
+func TestSearcherAnalytics(t *testing.T) {
+	block := api.FlashbotsBlock{
+		BlockNumber: 100,
+		Transactions: []api.FlashbotsTransaction{
+			{Hash: "0x1", BundleIndex: 0, EoaAddress: "0xAAA", ToAddress: "0xC1", GasUsed: 100, GasPrice: "0", CoinbaseTransfer: "1000", TotalMinerReward: "1000"},
+			{Hash: "0x2", BundleIndex: 0, EoaAddress: "0xAAA", ToAddress: "0xC1", GasUsed: 100, GasPrice: "10", CoinbaseTransfer: "0", TotalMinerReward: "1000"},
+			{Hash: "0x3", BundleIndex: 1, EoaAddress: "0xBBB", ToAddress: "0xC2", GasUsed: 50, GasPrice: "0", CoinbaseTransfer: "500", TotalMinerReward: "500"},
+		},
+	}
+
+	a := NewSearcherAnalytics(GroupByEoa)
+	a.AddBlock(block, map[string]bool{"0x1": true, "0x2": false})
+
+	top := a.Top(1, false)
+	if len(top) != 1 || top[0].Address != "0xaaa" {
+		t.Fatal("Unexpected top searcher:", top)
+	}
+
+	s := top[0]
+	if s.NumBundles != 1 || s.NumTx != 2 || s.GasUsed != 200 {
+		t.Error("Unexpected counts:", s.NumBundles, s.NumTx, s.GasUsed)
+	}
+	if s.MinerPayments.Int64() != 2000 || s.CoinbaseTransfers.Int64() != 1000 || s.GasSpend.Int64() != 1000 {
+		t.Error("Unexpected payments:", s.MinerPayments, s.CoinbaseTransfers, s.GasSpend)
+	}
+	if s.SuccessRate() != 0.5 {
+		t.Error("Unexpected success rate:", s.SuccessRate())
+	}
+	if a.Searchers["0xbbb"].SuccessRate() != -1 {
+		t.Error("Success rate should be unknown")
+	}
+}
+
+func TestSearcherAnalyticsDust(t *testing.T) {
+	common.MinBundleRewardEth = 0.01
+	defer func() { common.MinBundleRewardEth = 0 }()
+
+	block := api.FlashbotsBlock{
+		BlockNumber: 100,
+		Transactions: []api.FlashbotsTransaction{
+			// Bundle 0 of 0xAAA pays 0.02 ETH in total, bundle 1 only 0.005 ETH
+			{Hash: "0x1", BundleIndex: 0, EoaAddress: "0xAAA", GasUsed: 100, GasPrice: "0", TotalMinerReward: "10000000000000000"},
+			{Hash: "0x2", BundleIndex: 0, EoaAddress: "0xAAA", GasUsed: 100, GasPrice: "0", TotalMinerReward: "10000000000000000"},
+			{Hash: "0x3", BundleIndex: 1, EoaAddress: "0xAAA", GasUsed: 100, GasPrice: "0", TotalMinerReward: "5000000000000000"},
+			// 0xBBB only sends dust bundles
+			{Hash: "0x4", BundleIndex: 2, EoaAddress: "0xBBB", GasUsed: 100, GasPrice: "0", TotalMinerReward: "9000000000000000"},
+			{Hash: "0x5", BundleIndex: 3, EoaAddress: "0xBBB", GasUsed: 100, GasPrice: "0", TotalMinerReward: "9000000000000000"},
+		},
+	}
+
+	a := NewSearcherAnalytics(GroupByEoa)
+	a.AddBlock(block, nil)
+
+	s := a.Searchers["0xaaa"]
+	if s.NumBundles != 2 || s.NumDustBundles != 1 || s.DustMinerPayments.Int64() != 5e15 || s.FilteredMinerPayments().Int64() != 2e16 {
+		t.Error("Unexpected dust stats:", s.NumBundles, s.NumDustBundles, s.DustMinerPayments, s.FilteredMinerPayments())
+	}
+
+	// Unfiltered: all searchers, by total payments
+	if top := a.Top(0, false); len(top) != 2 || top[0].Address != "0xaaa" || top[1].NumDustBundles != 2 {
+		t.Error("Unexpected unfiltered leaderboard:", top)
+	}
+	// Filtered: searchers with only dust bundles are left out
+	if top := a.Top(0, true); len(top) != 1 || top[0].Address != "0xaaa" {
+		t.Error("Unexpected filtered leaderboard:", top)
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Seconds per slot of the beacon chain
+var SecondsPerSlot int64 = 12
+
+// BeaconBlock is the proposer and the execution payload of a slot, from the beacon node (all numbers are strings)
+type BeaconBlock struct {
+	Slot          string
+	ProposerIndex string
+	BlockNumber   string // of the execution payload
+	BlockHash     string
+	FeeRecipient  string // coinbase of the execution payload (the builder's address for blocks from a relay)
+}
+
+// BeaconClient queries the REST API of a beacon node (https://ethereum.github.io/beacon-APIs/), with the same timeouts
+// and retries as the mev-blocks Client
+type BeaconClient struct {
+	client *Client
+
+	lock        sync.Mutex
+	genesisTime int64 // cached
+}
+
+func NewBeaconClient(baseUrl string) *BeaconClient {
+	client := NewClient()
+	client.BaseUrl = strings.TrimSuffix(baseUrl, "/")
+	client.apiName = "beacon node api"
+	return &BeaconClient{client: client}
+}
+
+// GenesisTime returns the unix timestamp of the beacon chain genesis
+func (c *BeaconClient) GenesisTime(ctx context.Context) (int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.genesisTime > 0 {
+		return c.genesisTime, nil
+	}
+
+	var response struct {
+		Data struct {
+			GenesisTime string `json:"genesis_time"`
+		} `json:"data"`
+	}
+	if err := c.client.getJson(ctx, c.client.BaseUrl+"/eth/v1/beacon/genesis", &response); err != nil {
+		return 0, err
+	}
+	genesisTime, err := strconv.ParseInt(response.Data.GenesisTime, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid genesis time '%s': %w", response.Data.GenesisTime, err)
+	}
+	c.genesisTime = genesisTime
+	return genesisTime, nil
+}
+
+// SlotAt returns the slot of an execution block timestamp
+func (c *BeaconClient) SlotAt(ctx context.Context, timestamp uint64) (int64, error) {
+	genesisTime, err := c.GenesisTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if int64(timestamp) < genesisTime {
+		return 0, fmt.Errorf("timestamp %d is before the beacon chain genesis", timestamp)
+	}
+	return (int64(timestamp) - genesisTime) / SecondsPerSlot, nil
+}
+
+// GetBlock returns the block of a slot. The error matches ErrNotFound if the slot was missed.
+func (c *BeaconClient) GetBlock(ctx context.Context, slot int64) (*BeaconBlock, error) {
+	var response struct {
+		Data struct {
+			Message struct {
+				Slot          string `json:"slot"`
+				ProposerIndex string `json:"proposer_index"`
+				Body          struct {
+					ExecutionPayload struct {
+						BlockNumber  string `json:"block_number"`
+						BlockHash    string `json:"block_hash"`
+						FeeRecipient string `json:"fee_recipient"`
+					} `json:"execution_payload"`
+				} `json:"body"`
+			} `json:"message"`
+		} `json:"data"`
+	}
+	url := fmt.Sprintf("%s/eth/v2/beacon/blocks/%d", c.client.BaseUrl, slot)
+	if err := c.client.getJson(ctx, url, &response); err != nil {
+		return nil, err
+	}
+
+	message := response.Data.Message
+	return &BeaconBlock{
+		Slot:          message.Slot,
+		ProposerIndex: message.ProposerIndex,
+		BlockNumber:   message.Body.ExecutionPayload.BlockNumber,
+		BlockHash:     message.Body.ExecutionPayload.BlockHash,
+		FeeRecipient:  message.Body.ExecutionPayload.FeeRecipient,
+	}, nil
+}
+
+// GetValidatorPubkey returns the public key of a validator, by index (from the head state, the keys don't change)
+func (c *BeaconClient) GetValidatorPubkey(ctx context.Context, index string) (string, error) {
+	var response struct {
+		Data struct {
+			Validator struct {
+				Pubkey string `json:"pubkey"`
+			} `json:"validator"`
+		} `json:"data"`
+	}
+	url := fmt.Sprintf("%s/eth/v1/beacon/states/head/validators/%s", c.client.BaseUrl, index)
+	if err := c.client.getJson(ctx, url, &response); err != nil {
+		return "", err
+	}
+	return response.Data.Validator.Pubkey, nil
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBeaconClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/eth/v1/beacon/genesis":
+			fmt.Fprint(w, `{"data": {"genesis_time": "1606824023"}}`)
+		case "/eth/v2/beacon/blocks/4700567":
+			fmt.Fprint(w, `{"data": {"message": {"slot": "4700567", "proposer_index": "42", "body": {"execution_payload": {"block_number": "15537940", "block_hash": "0x12", "fee_recipient": "0xab"}}}}}`)
+		case "/eth/v1/beacon/states/head/validators/42":
+			fmt.Fprint(w, `{"data": {"index": "42", "validator": {"pubkey": "0xpk"}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	beacon := NewBeaconClient(server.URL + "/")
+	slot, err := beacon.SlotAt(context.Background(), 1606824023+4700567*12+5)
+	if err != nil || slot != 4700567 {
+		t.Fatal("Wrong slot:", slot, err)
+	}
+
+	block, err := beacon.GetBlock(context.Background(), slot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.ProposerIndex != "42" || block.BlockHash != "0x12" || block.FeeRecipient != "0xab" {
+		t.Errorf("Wrong block: %+v", block)
+	}
+
+	if pubkey, err := beacon.GetValidatorPubkey(context.Background(), block.ProposerIndex); err != nil || pubkey != "0xpk" {
+		t.Error("Wrong pubkey:", pubkey, err)
+	}
+
+	if _, err = beacon.GetBlock(context.Background(), slot+1); !errors.Is(err, ErrNotFound) {
+		t.Error("Expected ErrNotFound for a missed slot:", err)
+	}
+}
@@ -1,9 +1,8 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
 	"strings"
 )
 
@@ -62,6 +61,11 @@ func (b GetBlocksOptions) ToUriQuery() string {
 	return s
 }
 
+// isSingleBlock returns true if the options only select a block number
+func (b GetBlocksOptions) isSingleBlock() bool {
+	return b.BlockNumber > 0 && b.Miner == "" && b.From == "" && b.Before == 0 && b.Limit == 0
+}
+
 type GetBlocksResponse struct {
 	LatestBlockNumber int64            `json:"latest_block_number"`
 	Blocks            []FlashbotsBlock `json:"blocks"`
@@ -89,27 +93,10 @@ func (r *GetBlocksResponse) HasTx(hash string) bool {
 // part of the flashbots bundle.
 // https://blocks.flashbots.net/v1/blocks
 func GetBlocks(options *GetBlocksOptions) (response GetBlocksResponse, err error) {
-	url := "https://blocks.flashbots.net/v1/blocks"
-	if options != nil {
-		url = url + options.ToUriQuery()
-	}
-
-	resp, err := http.Get(url)
-	if err != nil {
-		err := fmt.Errorf("mev-blocks api request error: %s - %w", url, err)
-		return response, err
-	}
-
-	if resp.StatusCode >= 400 {
-		err := fmt.Errorf("mev-blocks api response status code error: %s - %s", resp.Status, url)
-		return response, err
-	}
-
-	err = json.NewDecoder(resp.Body).Decode(&response)
-	if err != nil {
-		err := fmt.Errorf("mev-blocks api response decode error: %s - %w", url, err)
-		return response, err
-	}
+	return DefaultClient.GetBlocks(context.Background(), options)
+}
 
-	return response, nil
+// GetBlocksWithContext is GetBlocks with a context, to cancel long-running requests (including retries)
+func GetBlocksWithContext(ctx context.Context, options *GetBlocksOptions) (response GetBlocksResponse, err error) {
+	return DefaultClient.GetBlocks(ctx, options)
 }
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Cache is an LRU cache with TTL for the single-block responses of GetBlocks (see Client.Cache). Only responses
+// of blocks which the API has already processed are cached. With Dir set, responses are also stored on disk and
+// survive restarts.
+type Cache struct {
+	MaxEntries int           // max. number of blocks in memory (0 = unlimited)
+	TTL        time.Duration // max. age of an entry, in memory and on disk (0 = no expiry)
+	Dir        string        // directory for the on-disk cache (empty = memory only)
+
+	lock    sync.Mutex
+	lru     *list.List // front = most recently used
+	entries map[int64]*list.Element
+	stats   CacheStats
+}
+
+type cacheEntry struct {
+	blockNumber int64
+	response    GetBlocksResponse
+	added       time.Time
+}
+
+// CacheStats are the hit and miss counts of a cache. Disk hits are counted as hits.
+type CacheStats struct {
+	Hits      uint64
+	DiskHits  uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// HitRate returns the share of lookups that were served from the cache
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func (s CacheStats) String() string {
+	return fmt.Sprintf("hits: %d (disk: %d), misses: %d, hit rate: %.1f%%, evictions: %d", s.Hits, s.DiskHits, s.Misses, s.HitRate()*100, s.Evictions)
+}
+
+func NewCache(maxEntries int, ttl time.Duration) *Cache {
+	return &Cache{
+		MaxEntries: maxEntries,
+		TTL:        ttl,
+		lru:        list.New(),
+		entries:    make(map[int64]*list.Element),
+	}
+}
+
+// Get returns the cached response for a block
+func (c *Cache) Get(blockNumber int64) (response GetBlocksResponse, found bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if el, ok := c.entries[blockNumber]; ok {
+		entry := el.Value.(*cacheEntry)
+		if !c.isExpired(entry.added) {
+			c.lru.MoveToFront(el)
+			c.stats.Hits += 1
+			return entry.response, true
+		}
+		c.lru.Remove(el)
+		delete(c.entries, blockNumber)
+	}
+
+	if c.Dir != "" {
+		if response, added, err := c.readFile(blockNumber); err == nil && !c.isExpired(added) {
+			c.add(blockNumber, response, added)
+			c.stats.Hits += 1
+			c.stats.DiskHits += 1
+			return response, true
+		}
+	}
+
+	c.stats.Misses += 1
+	return response, false
+}
+
+// Add caches the response for a block (and writes it to disk if Dir is set)
+func (c *Cache) Add(blockNumber int64, response GetBlocksResponse) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.add(blockNumber, response, time.Now())
+	if c.Dir != "" {
+		return c.writeFile(blockNumber, response)
+	}
+	return nil
+}
+
+// Remove drops the cached response for a block, in memory and on disk (eg. after a reorg replaced the block)
+func (c *Cache) Remove(blockNumber int64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if el, ok := c.entries[blockNumber]; ok {
+		c.lru.Remove(el)
+		delete(c.entries, blockNumber)
+	}
+	if c.Dir != "" {
+		if err := os.Remove(c.filename(blockNumber)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cache) add(blockNumber int64, response GetBlocksResponse, added time.Time) {
+	if el, ok := c.entries[blockNumber]; ok {
+		el.Value = &cacheEntry{blockNumber, response, added}
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.entries[blockNumber] = c.lru.PushFront(&cacheEntry{blockNumber, response, added})
+	for c.MaxEntries > 0 && c.lru.Len() > c.MaxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).blockNumber)
+		c.stats.Evictions += 1
+	}
+}
+
+func (c *Cache) isExpired(added time.Time) bool {
+	return c.TTL > 0 && time.Since(added) > c.TTL
+}
+
+// Stats returns the hit and miss counts since the cache was created
+func (c *Cache) Stats() CacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.stats
+}
+
+// Len returns the number of blocks in memory
+func (c *Cache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+func (c *Cache) filename(blockNumber int64) string {
+	return filepath.Join(c.Dir, fmt.Sprintf("block-%d.json", blockNumber))
+}
+
+func (c *Cache) readFile(blockNumber int64) (response GetBlocksResponse, modTime time.Time, err error) {
+	filename := c.filename(blockNumber)
+	info, err := os.Stat(filename)
+	if err != nil {
+		return response, modTime, err
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return response, modTime, err
+	}
+
+	err = json.Unmarshal(data, &response)
+	return response, info.ModTime(), err
+}
+
+func (c *Cache) writeFile(blockNumber int64, response GetBlocksResponse) error {
+	err := os.MkdirAll(c.Dir, 0755)
+	if err != nil {
+		return fmt.Errorf("error creating cache dir: %w", err)
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first, so concurrent readers never see a partial file
+	tmpFilename := c.filename(blockNumber) + ".tmp"
+	err = os.WriteFile(tmpFilename, data, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing cache file: %w", err)
+	}
+	return os.Rename(tmpFilename, c.filename(blockNumber))
+}
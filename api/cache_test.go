@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCacheLRU(t *testing.T) {
+	c := NewCache(2, 0)
+	c.Add(1, GetBlocksResponse{LatestBlockNumber: 1})
+	c.Add(2, GetBlocksResponse{LatestBlockNumber: 2})
+	c.Get(1) // 2 is now the least recently used
+	c.Add(3, GetBlocksResponse{LatestBlockNumber: 3})
+
+	if _, found := c.Get(2); found {
+		t.Error("block 2 should have been evicted")
+	}
+	if res, found := c.Get(1); !found || res.LatestBlockNumber != 1 {
+		t.Error("block 1 should be cached")
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Evictions != 1 {
+		t.Error("Unexpected stats:", stats)
+	}
+}
+
+func TestCacheTTLAndDisk(t *testing.T) {
+	c := NewCache(10, time.Hour)
+	c.Dir = t.TempDir()
+	err := c.Add(1, GetBlocksResponse{LatestBlockNumber: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A new cache with the same directory reads the entry from disk
+	c2 := NewCache(10, time.Hour)
+	c2.Dir = c.Dir
+	if res, found := c2.Get(1); !found || res.LatestBlockNumber != 5 || c2.Stats().DiskHits != 1 {
+		t.Error("block 1 should be read from disk")
+	}
+
+	// A removed block is neither in memory nor on disk
+	if err := c2.Remove(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := c2.Get(1); found {
+		t.Error("block 1 should be removed")
+	}
+	if err := c2.Remove(1); err != nil {
+		t.Error("removing a missing block should not fail:", err)
+	}
+
+	c3 := NewCache(10, time.Nanosecond)
+	c3.Add(2, GetBlocksResponse{})
+	time.Sleep(time.Millisecond)
+	if _, found := c3.Get(2); found {
+		t.Error("block 2 should be expired")
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		fmt.Fprint(w, `{"latest_block_number": 123, "blocks": []}`)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL)
+	client.Cache = NewCache(10, 0)
+	for i := 0; i < 2; i++ {
+		client.GetBlocks(context.Background(), &GetBlocksOptions{BlockNumber: 123})
+		client.GetBlocks(context.Background(), &GetBlocksOptions{BlockNumber: 124}) // not yet processed by the API
+		client.GetBlocks(context.Background(), &GetBlocksOptions{BlockNumber: 123, Limit: 5})
+	}
+
+	if requests != 5 {
+		t.Error("Wrong amount of requests:", requests, "wanted:", 5)
+	}
+}
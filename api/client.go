@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/metachris/flashbots/logging"
+)
+
+const DefaultBaseUrl = "https://blocks.flashbots.net"
+
+var log = logging.Module("api")
+
+// Client for the mev-blocks API, with timeouts and retries on transient errors (network errors, 429 and 5xx responses)
+type Client struct {
+	HttpClient *http.Client
+	BaseUrl    string
+
+	MaxAttempts int           // total number of attempts per request (1 = no retries)
+	MinBackoff  time.Duration // wait time before the first retry, doubled on every further retry
+	MaxBackoff  time.Duration // upper limit for the wait time between retries (also caps Retry-After)
+
+	Cache *Cache // cache for single-block GetBlocks requests (nil = disabled)
+
+	apiName string // used in error messages
+}
+
+// Default cache of DefaultClient: blocks are queried repeatedly by the checks, but rarely after a few hours
+const (
+	DefaultCacheSize = 1000
+	DefaultCacheTTL  = 6 * time.Hour
+)
+
+// DefaultClient is used by the package-level functions (GetBlocks, GetTransactions)
+var DefaultClient = newDefaultClient()
+
+func newDefaultClient() *Client {
+	client := NewClient()
+	client.Cache = NewCache(DefaultCacheSize, DefaultCacheTTL)
+	return client
+}
+
+func NewClient() *Client {
+	return &Client{
+		HttpClient:  &http.Client{Timeout: 30 * time.Second},
+		BaseUrl:     DefaultBaseUrl,
+		MaxAttempts: 5,
+		MinBackoff:  500 * time.Millisecond,
+		MaxBackoff:  30 * time.Second,
+		apiName:     "mev-blocks api",
+	}
+}
+
+// GetBlocks queries https://blocks.flashbots.net/v1/blocks, see the package-level GetBlocks. Requests for a single
+// block are served from the Cache, if set.
+func (c *Client) GetBlocks(ctx context.Context, options *GetBlocksOptions) (response GetBlocksResponse, err error) {
+	isCacheable := c.Cache != nil && options != nil && options.isSingleBlock()
+	if isCacheable {
+		if response, found := c.Cache.Get(options.BlockNumber); found {
+			return response, nil
+		}
+	}
+
+	url := c.BaseUrl + "/v1/blocks"
+	if options != nil {
+		url = url + options.ToUriQuery()
+	}
+
+	err = c.getJson(ctx, url, &response)
+	if err != nil {
+		return response, err
+	}
+
+	// Don't cache blocks the API hasn't processed yet
+	if isCacheable && response.LatestBlockNumber >= options.BlockNumber {
+		if err := c.Cache.Add(options.BlockNumber, response); err != nil { // a failed disk write still caches in memory
+			log.Warn("error writing to the cache", "block", options.BlockNumber, "err", err)
+		}
+	}
+	return response, nil
+}
+
+// GetTransactions queries https://blocks.flashbots.net/v1/transactions, see the package-level GetTransactions
+func (c *Client) GetTransactions(ctx context.Context, options *GetTransactionsOptions) (response TransactionsResponse, err error) {
+	url := c.BaseUrl + "/v1/transactions"
+	if options != nil {
+		url = url + options.ToUriQuery()
+	}
+
+	err = c.getJson(ctx, url, &response)
+	return response, err
+}
+
+// getJson requests the url and decodes the JSON response into v, retrying on transient errors
+func (c *Client) getJson(ctx context.Context, url string, v interface{}) (err error) {
+	for attempt := 1; ; attempt++ {
+		var retryAfter time.Duration
+		var retry bool
+		retry, retryAfter, err = c.doGetJson(ctx, url, v)
+		if err == nil || !retry || attempt >= c.MaxAttempts {
+			return err
+		}
+
+		wait := c.backoff(attempt)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		if c.MaxBackoff > 0 && wait > c.MaxBackoff {
+			wait = c.MaxBackoff
+		}
+		log.Debug("request failed, retrying", "api", c.apiName, "attempt", attempt, "wait", wait, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s request error: %s - %w", c.apiName, url, ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+// doGetJson does a single request. Returns whether the error is transient, and the wait time requested by the server
+func (c *Client) doGetJson(ctx context.Context, url string, v interface{}) (retry bool, retryAfter time.Duration, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, 0, fmt.Errorf("%s request error: %s - %w", c.apiName, url, err)
+	}
+
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, 0, fmt.Errorf("%s request error: %s - %w", c.apiName, url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		statusErr := newStatusError(c.apiName, url, resp)
+		retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		return statusErr.IsTemporary(), retryAfter, statusErr
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return false, 0, fmt.Errorf("%s response decode error: %s - %w", c.apiName, url, err)
+	}
+
+	return false, 0, nil
+}
+
+// backoff returns the exponential backoff for the given attempt, with jitter (between 50% and 100% of the full value)
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.MinBackoff
+	for i := 1; i < attempt && (c.MaxBackoff <= 0 || d < c.MaxBackoff); i++ {
+		d *= 2
+	}
+	if c.MaxBackoff > 0 && d > c.MaxBackoff {
+		d = c.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// parseRetryAfter supports both formats of the Retry-After header (seconds and HTTP date)
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if sec, err := strconv.Atoi(value); err == nil && sec > 0 {
+		return time.Duration(sec) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestClient(url string) *Client {
+	c := NewClient()
+	c.BaseUrl = url
+	c.MinBackoff = time.Millisecond
+	c.MaxBackoff = 10 * time.Millisecond
+	return c
+}
+
+func TestClientRetry(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		if requests < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"latest_block_number": 123, "blocks": [{"block_number": 123}]}`)
+	}))
+	defer server.Close()
+
+	res, err := newTestClient(server.URL).GetBlocks(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 3 {
+		t.Error("Wrong amount of requests:", requests, "wanted:", 3)
+	}
+	if res.LatestBlockNumber != 123 || len(res.Blocks) != 1 {
+		t.Error("Unexpected response:", res)
+	}
+}
+
+func TestClientNoRetryOnClientError(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	_, err := newTestClient(server.URL).GetTransactions(context.Background(), nil)
+	if err == nil {
+		t.Error("Expected an error")
+	}
+	if requests != 1 {
+		t.Error("Wrong amount of requests:", requests, "wanted:", 1)
+	}
+}
+
+func TestClientMaxAttempts(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := newTestClient(server.URL)
+	c.MaxAttempts = 2
+	_, err := c.GetBlocks(context.Background(), nil)
+	if err == nil {
+		t.Error("Expected an error")
+	}
+	if requests != 2 {
+		t.Error("Wrong amount of requests:", requests, "wanted:", 2)
+	}
+}
+
+func TestClientTypedErrors(t *testing.T) {
+	statusCode := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+		fmt.Fprint(w, `{"error": "details"}`)
+	}))
+	defer server.Close()
+
+	c := newTestClient(server.URL)
+	c.MaxAttempts = 1
+	for code, expected := range map[int]error{404: ErrNotFound, 429: ErrRateLimited, 503: ErrServerError, 400: ErrClientError} {
+		statusCode = code
+		_, err := c.GetBlocks(context.Background(), nil)
+		if !errors.Is(err, expected) {
+			t.Errorf("Expected %v for status %d, got %v", expected, code, err)
+		}
+
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != code || statusErr.Body != `{"error": "details"}` {
+			t.Errorf("Unexpected StatusError for status %d: %+v", code, statusErr)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("5"); d != 5*time.Second {
+		t.Error("Wrong Retry-After duration:", d)
+	}
+	if d := parseRetryAfter(""); d != 0 {
+		t.Error("Wrong Retry-After duration:", d)
+	}
+	if d := parseRetryAfter(time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)); d <= 0 || d > time.Minute {
+		t.Error("Wrong Retry-After duration:", d)
+	}
+}
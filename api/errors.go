@@ -0,0 +1,66 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Errors for unsuccessful responses, match them with errors.Is (eg. errors.Is(err, api.ErrRateLimited)). The
+// returned error is a *StatusError with the status code and the response body.
+var (
+	ErrRateLimited = errors.New("rate limited")   // 429
+	ErrNotFound    = errors.New("not found")      // 404
+	ErrServerError = errors.New("server error")   // 5xx
+	ErrClientError = errors.New("request failed") // all other 4xx
+)
+
+// Response bodies are truncated to this size in StatusError
+const maxErrorBodySize = 1024
+
+// StatusError is returned for responses with a status code >= 400 (use errors.As to get the details)
+type StatusError struct {
+	Api        string
+	Url        string
+	StatusCode int
+	Status     string // eg. "429 Too Many Requests"
+	Body       string
+}
+
+func newStatusError(apiName string, url string, resp *http.Response) *StatusError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	return &StatusError{
+		Api:        apiName,
+		Url:        url,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       strings.TrimSpace(string(body)),
+	}
+}
+
+// Error keeps the message of the previous untyped errors
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s response status code error: %s - %s", e.Api, e.Status, e.Url)
+}
+
+// Is matches ErrRateLimited, ErrNotFound, ErrServerError or ErrClientError, depending on the status code
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrServerError:
+		return e.StatusCode >= 500
+	case ErrClientError:
+		return e.StatusCode >= 400 && e.StatusCode < 500 && e.StatusCode != http.StatusTooManyRequests && e.StatusCode != http.StatusNotFound
+	}
+	return false
+}
+
+// IsTemporary returns true if the request may succeed when retried later (rate limit and server errors)
+func (e *StatusError) IsTemporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// MEV-Share endpoints of Flashbots (https://docs.flashbots.net/flashbots-mev-share/searchers/event-stream)
+const (
+	DefaultMevShareStreamUrl  = "https://mev-share.flashbots.net"
+	DefaultMevShareHistoryUrl = "https://mev-share-data.flashbots.net"
+)
+
+// Max. size of one line of the event stream
+const maxMevShareEventSize = 1024 * 1024
+
+// MevShareHint is an event of the MEV-Share stream: the hints about a pending transaction or bundle, with the fields
+// the user chose to share
+type MevShareHint struct {
+	Hash        string           `json:"hash"` // tx hash, or bundle hash for bundle hints
+	Logs        []MevShareLog    `json:"logs"`
+	Txs         []MevShareHintTx `json:"txs"`
+	MevGasPrice string           `json:"mevGasPrice,omitempty"` // hex
+	GasUsed     string           `json:"gasUsed,omitempty"`     // hex
+}
+
+type MevShareLog struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
+}
+
+type MevShareHintTx struct {
+	Hash             string `json:"hash,omitempty"`
+	To               string `json:"to,omitempty"`
+	FunctionSelector string `json:"functionSelector,omitempty"`
+	CallData         string `json:"callData,omitempty"`
+}
+
+// TxHashes returns the hash of the hint (the tx hash of a tx hint) and the shared tx hashes of a bundle, lowercase
+func (h MevShareHint) TxHashes() []string {
+	hashes := []string{}
+	if h.Hash != "" {
+		hashes = append(hashes, strings.ToLower(h.Hash))
+	}
+	for _, tx := range h.Txs {
+		if tx.Hash != "" && !strings.EqualFold(tx.Hash, h.Hash) {
+			hashes = append(hashes, strings.ToLower(tx.Hash))
+		}
+	}
+	return hashes
+}
+
+// MevShareHistoricalHint is a hint of the history API, with the block and time it was received
+type MevShareHistoricalHint struct {
+	Block     int64        `json:"block"`
+	Timestamp int64        `json:"timestamp"`
+	Hint      MevShareHint `json:"hint"`
+}
+
+// MevShareHistoryInfo describes the data of the history API
+type MevShareHistoryInfo struct {
+	Count        int64 `json:"count"`
+	MinBlock     int64 `json:"minBlock"`
+	MaxBlock     int64 `json:"maxBlock"`
+	MinTimestamp int64 `json:"minTimestamp"`
+	MaxTimestamp int64 `json:"maxTimestamp"`
+	MaxLimit     int64 `json:"maxLimit"`
+}
+
+type GetMevShareHistoryOptions struct {
+	BlockStart     int64
+	BlockEnd       int64
+	TimestampStart int64
+	TimestampEnd   int64
+	Limit          int64
+	Offset         int64
+}
+
+func (o GetMevShareHistoryOptions) ToUriQuery() string {
+	args := []string{}
+	if o.BlockStart > 0 {
+		args = append(args, fmt.Sprintf("blockStart=%d", o.BlockStart))
+	}
+	if o.BlockEnd > 0 {
+		args = append(args, fmt.Sprintf("blockEnd=%d", o.BlockEnd))
+	}
+	if o.TimestampStart > 0 {
+		args = append(args, fmt.Sprintf("timestampStart=%d", o.TimestampStart))
+	}
+	if o.TimestampEnd > 0 {
+		args = append(args, fmt.Sprintf("timestampEnd=%d", o.TimestampEnd))
+	}
+	if o.Limit > 0 {
+		args = append(args, fmt.Sprintf("limit=%d", o.Limit))
+	}
+	if o.Offset > 0 {
+		args = append(args, fmt.Sprintf("offset=%d", o.Offset))
+	}
+
+	s := strings.Join(args, "&")
+	if len(s) > 0 {
+		s = "?" + s
+	}
+	return s
+}
+
+// MevShareClient receives the hints of the MEV-Share event stream, and queries the historical hints. The history
+// requests have the same timeouts and retries as the mev-blocks Client.
+type MevShareClient struct {
+	StreamUrl  string
+	HttpClient *http.Client // for the stream, without timeout
+
+	client *Client // history API
+}
+
+func NewMevShareClient(streamUrl string) *MevShareClient {
+	client := NewClient()
+	client.BaseUrl = DefaultMevShareHistoryUrl
+	client.apiName = "mev-share history api"
+	return &MevShareClient{
+		StreamUrl:  strings.TrimSuffix(streamUrl, "/"),
+		HttpClient: &http.Client{},
+		client:     client,
+	}
+}
+
+// SetHistoryUrl replaces the url of the history API
+func (c *MevShareClient) SetHistoryUrl(url string) {
+	c.client.BaseUrl = strings.TrimSuffix(url, "/")
+}
+
+// GetHistory returns the hints received in a block or time range, oldest first
+func (c *MevShareClient) GetHistory(ctx context.Context, options *GetMevShareHistoryOptions) (response []MevShareHistoricalHint, err error) {
+	url := c.client.BaseUrl + "/api/v1/history"
+	if options != nil {
+		url = url + options.ToUriQuery()
+	}
+
+	err = c.client.getJson(ctx, url, &response)
+	return response, err
+}
+
+// GetHistoryInfo returns the range and size of the history, and the max. limit of GetHistory
+func (c *MevShareClient) GetHistoryInfo(ctx context.Context) (response MevShareHistoryInfo, err error) {
+	err = c.client.getJson(ctx, c.client.BaseUrl+"/api/v1/history/info", &response)
+	return response, err
+}
+
+// Subscribe connects to the event stream and calls onHint for every hint until the context is cancelled (returns nil)
+// or the connection fails. It doesn't reconnect, that's up to the caller.
+func (c *MevShareClient) Subscribe(ctx context.Context, onHint func(hint MevShareHint)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.StreamUrl, nil)
+	if err != nil {
+		return fmt.Errorf("mev-share stream error: %s - %w", c.StreamUrl, err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("mev-share stream error: %s - %w", c.StreamUrl, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return newStatusError("mev-share stream", c.StreamUrl, resp)
+	}
+
+	err = readServerSentEvents(resp.Body, onHint)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("mev-share stream error: %s - %w", c.StreamUrl, err)
+	}
+	return fmt.Errorf("mev-share stream closed: %s", c.StreamUrl)
+}
+
+// readServerSentEvents decodes the data lines of the stream as hints, comments (eg. ":ping") and invalid events are
+// skipped
+func readServerSentEvents(r io.Reader, onHint func(hint MevShareHint)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMevShareEventSize)
+	data := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "data:") {
+			data += strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+			continue
+		}
+		if line != "" || data == "" { // the event ends with an empty line
+			continue
+		}
+
+		var hint MevShareHint
+		if err := json.Unmarshal([]byte(data), &hint); err != nil {
+			log.Debug("invalid mev-share event", "data", data, "err", err)
+		} else {
+			onHint(hint)
+		}
+		data = ""
+	}
+	return scanner.Err()
+}
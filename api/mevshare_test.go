@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMevShareSubscribe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "text/event-stream" {
+			t.Error("Unexpected accept header:", r.Header.Get("Accept"))
+		}
+		fmt.Fprint(w, ":ping\n\n")
+		fmt.Fprint(w, "data: {\"hash\":\"0xAB\",\"logs\":[],\"txs\":[{\"to\":\"0x12\",\"functionSelector\":\"0x38ed1739\"}]}\n\n")
+		fmt.Fprint(w, "data: invalid\n\n")
+		fmt.Fprint(w, "data: {\"hash\":\"0xbundle\",\"txs\":[{\"hash\":\"0xCD\"},{\"hash\":\"0xef\"}]}\n\n")
+	}))
+	defer server.Close()
+
+	hints := []MevShareHint{}
+	err := NewMevShareClient(server.URL+"/").Subscribe(context.Background(), func(hint MevShareHint) { hints = append(hints, hint) })
+	if err == nil {
+		t.Error("Expected an error when the stream is closed")
+	}
+	if len(hints) != 2 || hints[0].Txs[0].FunctionSelector != "0x38ed1739" {
+		t.Fatalf("Wrong hints: %+v", hints)
+	}
+	if hashes := hints[0].TxHashes(); len(hashes) != 1 || hashes[0] != "0xab" {
+		t.Error("Wrong tx hashes of the tx hint:", hashes)
+	}
+	if hashes := hints[1].TxHashes(); len(hashes) != 3 || hashes[1] != "0xcd" {
+		t.Error("Wrong tx hashes of the bundle hint:", hashes)
+	}
+
+	// Cancelling the context ends the subscription without error
+	blocking := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer blocking.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := NewMevShareClient(blocking.URL).Subscribe(ctx, func(hint MevShareHint) {}); err != nil {
+		t.Error("Expected no error on cancel:", err)
+	}
+}
+
+func TestMevShareGetHistory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/history" || r.URL.RawQuery != "blockStart=17000000&blockEnd=17000001&limit=100" {
+			t.Error("Unexpected request:", r.URL.String())
+		}
+		fmt.Fprint(w, `[{"block": 17000000, "timestamp": 1681000000, "hint": {"hash": "0xab", "txs": null, "logs": null}}]`)
+	}))
+	defer server.Close()
+
+	client := NewMevShareClient(DefaultMevShareStreamUrl)
+	client.SetHistoryUrl(server.URL)
+	hints, err := client.GetHistory(context.Background(), &GetMevShareHistoryOptions{BlockStart: 17000000, BlockEnd: 17000001, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hints) != 1 || hints[0].Block != 17000000 || hints[0].Hint.Hash != "0xab" {
+		t.Errorf("Wrong response: %+v", hints)
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// Defaults for paging through the blocks API. Large pages hit the API limits and time out.
+var (
+	DefaultPageSize    int64         = 1000
+	DefaultMinInterval time.Duration = 200 * time.Millisecond
+)
+
+// PageOptions control how BlocksIterator pages through the blocks API
+type PageOptions struct {
+	PageSize    int64         // blocks per request (0 = DefaultPageSize)
+	MinInterval time.Duration // min. time between two requests, to stay below the API rate limit (0 = DefaultMinInterval)
+	StopBlock   int64         // stop at blocks lower than this one (0 = no lower bound)
+}
+
+// BlocksIterator pages backwards through the blocks API, using the before and limit parameters. Usage:
+//
+//	it := client.IterateBlocks(&api.GetBlocksOptions{Before: 13000000, Limit: 50_000}, nil)
+//	for it.Next(ctx) {
+//		for _, block := range it.Page() { ... }
+//	}
+//	if it.Err() != nil { ... }
+type BlocksIterator struct {
+	client  *Client
+	options GetBlocksOptions // Before is the cursor, Limit the total number of blocks (0 = unlimited)
+	page    PageOptions
+
+	fetched     int64
+	latest      int64
+	blocks      []FlashbotsBlock
+	lastRequest time.Time
+	done        bool
+	err         error
+}
+
+// IterateBlocks returns an iterator over the blocks matching the options, newest first. options.Before is the start
+// (exclusive), options.Limit the total number of blocks (0 = until the API has no more blocks or StopBlock is reached).
+func (c *Client) IterateBlocks(options *GetBlocksOptions, pageOptions *PageOptions) *BlocksIterator {
+	it := &BlocksIterator{client: c}
+	if options != nil {
+		it.options = *options
+	}
+	if pageOptions != nil {
+		it.page = *pageOptions
+	}
+	if it.page.PageSize <= 0 {
+		it.page.PageSize = DefaultPageSize
+	}
+	if it.page.MinInterval <= 0 {
+		it.page.MinInterval = DefaultMinInterval
+	}
+	return it
+}
+
+// Next fetches the next page. Returns false when all blocks were fetched, or on error (see Err).
+func (it *BlocksIterator) Next(ctx context.Context) bool {
+	if it.done {
+		return false
+	}
+
+	limit := it.page.PageSize
+	if it.options.Limit > 0 && it.options.Limit-it.fetched < limit {
+		limit = it.options.Limit - it.fetched
+	}
+
+	// Rate limit
+	if wait := it.page.MinInterval - time.Since(it.lastRequest); wait > 0 {
+		select {
+		case <-ctx.Done():
+			it.err = ctx.Err()
+			it.done = true
+			return false
+		case <-time.After(wait):
+		}
+	}
+
+	opts := it.options
+	opts.Limit = limit
+	it.lastRequest = time.Now()
+	response, err := it.client.GetBlocks(ctx, &opts)
+	if err != nil {
+		it.err = err
+		it.done = true
+		return false
+	}
+
+	it.latest = response.LatestBlockNumber
+
+	// Blocks are returned newest first. Keep only blocks above StopBlock, and move the cursor to the lowest block.
+	it.blocks = make([]FlashbotsBlock, 0, len(response.Blocks))
+	for _, block := range response.Blocks {
+		if block.BlockNumber < it.page.StopBlock {
+			it.done = true
+			continue
+		}
+		it.blocks = append(it.blocks, block)
+		if it.options.Before == 0 || block.BlockNumber < it.options.Before {
+			it.options.Before = block.BlockNumber
+		}
+	}
+
+	it.fetched += int64(len(it.blocks))
+	if int64(len(response.Blocks)) < limit || (it.options.Limit > 0 && it.fetched >= it.options.Limit) {
+		it.done = true
+	}
+	return len(it.blocks) > 0 || !it.done
+}
+
+// Page returns the blocks of the current page
+func (it *BlocksIterator) Page() []FlashbotsBlock {
+	return it.blocks
+}
+
+// LatestBlockNumber returns the latest block processed by the API, as of the last request
+func (it *BlocksIterator) LatestBlockNumber() int64 {
+	return it.latest
+}
+
+// Err returns the error which stopped the iteration, if any
+func (it *BlocksIterator) Err() error {
+	return it.err
+}
+
+// GetBlocksPaged returns all blocks matching the options (see IterateBlocks), fetched in pages
+func (c *Client) GetBlocksPaged(ctx context.Context, options *GetBlocksOptions, pageOptions *PageOptions) (blocks []FlashbotsBlock, err error) {
+	it := c.IterateBlocks(options, pageOptions)
+	for it.Next(ctx) {
+		blocks = append(blocks, it.Page()...)
+	}
+	return blocks, it.Err()
+}
+
+// StreamBlocks sends all blocks matching the options (see IterateBlocks) to the returned channel, for large
+// historical pulls. The channel is closed when done. The error channel receives at most one error.
+func (c *Client) StreamBlocks(ctx context.Context, options *GetBlocksOptions, pageOptions *PageOptions) (<-chan FlashbotsBlock, <-chan error) {
+	blocks := make(chan FlashbotsBlock, DefaultPageSize)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(blocks)
+		defer close(errc)
+
+		it := c.IterateBlocks(options, pageOptions)
+		for it.Next(ctx) {
+			for _, block := range it.Page() {
+				select {
+				case blocks <- block:
+				case <-ctx.Done():
+					errc <- ctx.Err()
+					return
+				}
+			}
+		}
+		if it.Err() != nil {
+			errc <- it.Err()
+		}
+	}()
+
+	return blocks, errc
+}
+
+// GetBlocksPaged uses the DefaultClient, see Client.GetBlocksPaged
+func GetBlocksPaged(ctx context.Context, options *GetBlocksOptions, pageOptions *PageOptions) ([]FlashbotsBlock, error) {
+	return DefaultClient.GetBlocksPaged(ctx, options, pageOptions)
+}
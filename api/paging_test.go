@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newPagingTestServer serves the blocks 1..100, newest first, respecting before and limit
+func newPagingTestServer(requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests += 1
+		before, _ := strconv.ParseInt(r.URL.Query().Get("before"), 10, 64)
+		limit, _ := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+		if before == 0 {
+			before = 101
+		}
+
+		response := GetBlocksResponse{LatestBlockNumber: 100}
+		for n := before - 1; n > 0 && int64(len(response.Blocks)) < limit; n-- {
+			response.Blocks = append(response.Blocks, FlashbotsBlock{BlockNumber: n})
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+}
+
+func TestGetBlocksPaged(t *testing.T) {
+	requests := 0
+	server := newPagingTestServer(&requests)
+	defer server.Close()
+
+	client := newTestClient(server.URL)
+	pageOptions := &PageOptions{PageSize: 30, MinInterval: time.Millisecond}
+
+	// All blocks: 4 pages (30, 30, 30, 10)
+	blocks, err := client.GetBlocksPaged(context.Background(), nil, pageOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 100 || blocks[0].BlockNumber != 100 || blocks[99].BlockNumber != 1 || requests != 4 {
+		t.Error("Unexpected result:", len(blocks), requests)
+	}
+
+	// Total limit and start
+	blocks, _ = client.GetBlocksPaged(context.Background(), &GetBlocksOptions{Before: 90, Limit: 40}, pageOptions)
+	if len(blocks) != 40 || blocks[0].BlockNumber != 89 || blocks[39].BlockNumber != 50 {
+		t.Error("Unexpected result with limit:", len(blocks))
+	}
+
+	// Stop block
+	pageOptions.StopBlock = 75
+	blocks, _ = client.GetBlocksPaged(context.Background(), nil, pageOptions)
+	if len(blocks) != 26 || blocks[25].BlockNumber != 75 {
+		t.Error("Unexpected result with stop block:", len(blocks))
+	}
+}
+
+func TestStreamBlocks(t *testing.T) {
+	requests := 0
+	server := newPagingTestServer(&requests)
+	defer server.Close()
+
+	blocks, errc := newTestClient(server.URL).StreamBlocks(context.Background(), &GetBlocksOptions{Limit: 55}, &PageOptions{PageSize: 20, MinInterval: time.Millisecond})
+	count := 0
+	for range blocks {
+		count += 1
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if count != 55 {
+		t.Error("Unexpected number of blocks:", count)
+	}
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Public mev-boost relays, by name
+var KnownRelays = map[string]string{
+	"flashbots":  "https://boost-relay.flashbots.net",
+	"ultrasound": "https://relay.ultrasound.money",
+	"bloxroute":  "https://bloxroute.max-profit.blxrbdn.com",
+	"agnostic":   "https://agnostic-relay.net",
+	"aestus":     "https://mainnet.aestus.live",
+	"eden":       "https://relay.edennetwork.io",
+}
+
+// BidTrace is a bid of a builder for a slot, as returned by the relay Data API (all numbers are strings)
+type BidTrace struct {
+	Slot                 string `json:"slot"`
+	ParentHash           string `json:"parent_hash"`
+	BlockHash            string `json:"block_hash"`
+	BuilderPubkey        string `json:"builder_pubkey"`
+	ProposerPubkey       string `json:"proposer_pubkey"`
+	ProposerFeeRecipient string `json:"proposer_fee_recipient"`
+	GasLimit             string `json:"gas_limit"`
+	GasUsed              string `json:"gas_used"`
+	Value                string `json:"value"` // payment to the proposer fee recipient (wei)
+	NumTx                string `json:"num_tx"`
+	BlockNumber          string `json:"block_number"`
+
+	// Only for builder_blocks_received
+	Timestamp   string `json:"timestamp,omitempty"`
+	TimestampMs string `json:"timestamp_ms,omitempty"`
+}
+
+type ValidatorRegistration struct {
+	Message struct {
+		FeeRecipient string `json:"fee_recipient"`
+		GasLimit     string `json:"gas_limit"`
+		Timestamp    string `json:"timestamp"`
+		Pubkey       string `json:"pubkey"`
+	} `json:"message"`
+	Signature string `json:"signature"`
+}
+
+type GetBidTracesOptions struct {
+	Slot           int64
+	Cursor         int64 // proposer_payload_delivered only: return bids of slots up to this one
+	Limit          int64
+	BlockHash      string
+	BlockNumber    int64
+	ProposerPubkey string // proposer_payload_delivered only
+	BuilderPubkey  string
+}
+
+func (o GetBidTracesOptions) ToUriQuery() string {
+	args := []string{}
+	if o.Slot > 0 {
+		args = append(args, fmt.Sprintf("slot=%d", o.Slot))
+	}
+	if o.Cursor > 0 {
+		args = append(args, fmt.Sprintf("cursor=%d", o.Cursor))
+	}
+	if o.Limit > 0 {
+		args = append(args, fmt.Sprintf("limit=%d", o.Limit))
+	}
+	if o.BlockHash != "" {
+		args = append(args, fmt.Sprintf("block_hash=%s", o.BlockHash))
+	}
+	if o.BlockNumber > 0 {
+		args = append(args, fmt.Sprintf("block_number=%d", o.BlockNumber))
+	}
+	if o.ProposerPubkey != "" {
+		args = append(args, fmt.Sprintf("proposer_pubkey=%s", o.ProposerPubkey))
+	}
+	if o.BuilderPubkey != "" {
+		args = append(args, fmt.Sprintf("builder_pubkey=%s", o.BuilderPubkey))
+	}
+
+	s := strings.Join(args, "&")
+	if len(s) > 0 {
+		s = "?" + s
+	}
+	return s
+}
+
+// RelayClient queries the Data API of a mev-boost relay (https://flashbots.github.io/relay-specs/), with the same
+// timeouts and retries as the mev-blocks Client
+type RelayClient struct {
+	Name   string
+	client *Client
+}
+
+func NewRelayClient(name string, baseUrl string) *RelayClient {
+	client := NewClient()
+	client.BaseUrl = strings.TrimSuffix(baseUrl, "/")
+	client.apiName = "relay " + name + " data api"
+	return &RelayClient{
+		Name:   name,
+		client: client,
+	}
+}
+
+// SetHttpClient replaces the http client of the relay (eg. with a chaos transport)
+func (r *RelayClient) SetHttpClient(httpClient *http.Client) {
+	r.client.HttpClient = httpClient
+}
+
+// NewRelayClients returns clients for a comma-separated list of relay names (see KnownRelays) or URLs. "all" returns
+// clients for all known relays.
+func NewRelayClients(relays string) (clients []*RelayClient, err error) {
+	if relays == "all" {
+		for name, url := range KnownRelays {
+			clients = append(clients, NewRelayClient(name, url))
+		}
+		return clients, nil
+	}
+
+	for _, relay := range strings.Split(relays, ",") {
+		relay = strings.TrimSpace(relay)
+		if relay == "" {
+			continue
+		}
+
+		if url, found := KnownRelays[relay]; found {
+			clients = append(clients, NewRelayClient(relay, url))
+		} else if strings.HasPrefix(relay, "http") {
+			clients = append(clients, NewRelayClient(relay, relay))
+		} else {
+			return nil, fmt.Errorf("unknown relay: %s", relay)
+		}
+	}
+	return clients, nil
+}
+
+// GetProposerPayloadsDelivered returns the bids which were delivered to proposers (ie. the relay's blocks on chain)
+func (c *RelayClient) GetProposerPayloadsDelivered(ctx context.Context, options *GetBidTracesOptions) (response []BidTrace, err error) {
+	url := c.client.BaseUrl + "/relay/v1/data/bidtraces/proposer_payload_delivered"
+	if options != nil {
+		url = url + options.ToUriQuery()
+	}
+
+	err = c.client.getJson(ctx, url, &response)
+	return response, err
+}
+
+// GetBuilderBlocksReceived returns the bids which builders submitted to the relay
+func (c *RelayClient) GetBuilderBlocksReceived(ctx context.Context, options *GetBidTracesOptions) (response []BidTrace, err error) {
+	url := c.client.BaseUrl + "/relay/v1/data/bidtraces/builder_blocks_received"
+	if options != nil {
+		url = url + options.ToUriQuery()
+	}
+
+	err = c.client.getJson(ctx, url, &response)
+	return response, err
+}
+
+// GetValidatorRegistration returns the latest registration of a validator at the relay
+func (c *RelayClient) GetValidatorRegistration(ctx context.Context, pubkey string) (response ValidatorRegistration, err error) {
+	url := c.client.BaseUrl + "/relay/v1/data/validator_registration?pubkey=" + pubkey
+	err = c.client.getJson(ctx, url, &response)
+	return response, err
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRelayGetProposerPayloadsDelivered(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/relay/v1/data/bidtraces/proposer_payload_delivered" || r.URL.RawQuery != "block_number=15537940" {
+			t.Error("Unexpected request:", r.URL.String())
+		}
+		fmt.Fprint(w, `[{"slot": "4700567", "block_hash": "0x12", "proposer_fee_recipient": "0xab", "value": "123", "block_number": "15537940"}]`)
+	}))
+	defer server.Close()
+
+	relay := NewRelayClient("test", server.URL+"/")
+	bids, err := relay.GetProposerPayloadsDelivered(context.Background(), &GetBidTracesOptions{BlockNumber: 15537940})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bids) != 1 || bids[0].Value != "123" || bids[0].ProposerFeeRecipient != "0xab" {
+		t.Errorf("Wrong response: %+v", bids)
+	}
+}
+
+func TestNewRelayClients(t *testing.T) {
+	clients, err := NewRelayClients("flashbots, https://relay.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clients) != 2 || clients[0].client.BaseUrl != KnownRelays["flashbots"] || clients[1].Name != "https://relay.example.com" {
+		t.Errorf("Wrong clients: %+v", clients)
+	}
+
+	_, err = NewRelayClients("unknown")
+	if err == nil {
+		t.Error("Expected error for unknown relay")
+	}
+}
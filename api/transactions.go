@@ -1,15 +1,15 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
 	"strings"
 )
 
 const (
-	BundleTypeFlashbots = "flashbots"
-	BundleTypeRogue     = "rogue"
+	BundleTypeFlashbots  = "flashbots"
+	BundleTypeRogue      = "rogue"
+	BundleTypeMegabundle = "mega_bundle"
 )
 
 type FlashbotsTransaction struct {
@@ -57,20 +57,10 @@ type TransactionsResponse struct {
 // filter to transactions before a given block number.
 // https://blocks.flashbots.net/#api-Flashbots-GetV1Transactions
 func GetTransactions(options *GetTransactionsOptions) (response TransactionsResponse, err error) {
-	url := "https://blocks.flashbots.net/v1/transactions"
-	if options != nil {
-		url = url + options.ToUriQuery()
-	}
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return response, err
-	}
-
-	err = json.NewDecoder(resp.Body).Decode(&response)
-	if err != nil {
-		return response, err
-	}
+	return DefaultClient.GetTransactions(context.Background(), options)
+}
 
-	return response, nil
+// GetTransactionsWithContext is GetTransactions with a context, to cancel long-running requests (including retries)
+func GetTransactionsWithContext(ctx context.Context, options *GetTransactionsOptions) (response TransactionsResponse, err error) {
+	return DefaultClient.GetTransactions(ctx, options)
 }
@@ -0,0 +1,169 @@
+// Package audit keeps an append-only log of what happened to each block: when it was mined, received from the node,
+// published by the mev-blocks API and checked, which alerts were sent where, and acknowledgements. The timeline of an
+// incident is reconstructed from it.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	EventMined            = "mined"         // block timestamp
+	EventReceived         = "received"      // new head from the node
+	EventPublished        = "api-published" // first seen in the mev-blocks API (polled, so up to a few seconds late)
+	EventChecked          = "checked"
+	EventAlertQueued      = "alert-queued"
+	EventAlertDropped     = "alert-dropped" // duplicate or rate limit
+	EventAlertSent        = "alert-sent"
+	EventAlertFailed      = "alert-failed" // failed attempt, retried or sent to the next notifier
+	EventAlertUndelivered = "alert-undelivered"
+	EventAck              = "ack"
+)
+
+type Event struct {
+	Time     time.Time `json:"time"`
+	Block    int64     `json:"block"`
+	Type     string    `json:"type"`
+	Notifier string    `json:"notifier,omitempty"`
+	Severity string    `json:"severity,omitempty"`
+	User     string    `json:"user,omitempty"` // acks
+	Message  string    `json:"message,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Log appends the events to a JSON-lines file. All methods of a nil Log do nothing, so callers don't need to check
+// whether auditing is enabled.
+type Log struct {
+	Filename string
+	lock     sync.Mutex
+}
+
+func NewLog(filename string) *Log {
+	return &Log{Filename: filename}
+}
+
+// Add appends the event, with the current time if not set
+func (l *Log) Add(event Event) error {
+	if l == nil {
+		return nil
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Time = event.Time.UTC()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return appendLine(l.Filename, event)
+}
+
+// appendLine appends the value as JSON line to the file
+func appendLine(filename string, v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Events returns all events of the block, oldest first
+func (l *Log) Events(block int64) (events []Event, err error) {
+	if l == nil {
+		return nil, nil
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	err = readLines(l.Filename, func(line int, data []byte) error {
+		var event Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("%s line %d: %w", l.Filename, line, err)
+		}
+		if event.Block == block {
+			events = append(events, event)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events, nil
+}
+
+// readLines calls fn with each line of the JSON-lines file (numbered from 1). A missing file has no lines.
+func readLines(filename string, fn func(line int, data []byte) error) error {
+	f, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // alerts can be long
+	for line := 1; scanner.Scan(); line++ {
+		if err = fn(line, scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Timeline returns the events one per line, with the time since the block was mined
+func Timeline(events []Event) (ret string) {
+	var mined time.Time
+	for _, event := range events {
+		if event.Type == EventMined {
+			mined = event.Time
+		}
+	}
+
+	for _, event := range events {
+		since := ""
+		if !mined.IsZero() {
+			if d := event.Time.Sub(mined).Round(time.Second); d >= 0 {
+				since = "+" + d.String()
+			} else {
+				since = d.String()
+			}
+		}
+		line := fmt.Sprintf("%s %9s  %-17s", event.Time.Format("2006-01-02 15:04:05"), since, event.Type)
+		if event.Notifier != "" {
+			line += " " + event.Notifier
+		}
+		if event.Severity != "" {
+			line += " " + event.Severity
+		}
+		if event.User != "" {
+			line += " by " + event.User
+		}
+		if event.Message != "" {
+			line += ": " + event.Message
+		}
+		if event.Error != "" {
+			line += " (" + event.Error + ")"
+		}
+		ret += strings.TrimRight(line, " ") + "\n"
+	}
+	return ret
+}
@@ -0,0 +1,45 @@
+package audit
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLog(t *testing.T) {
+	var nilLog *Log
+	if err := nilLog.Add(Event{Block: 1, Type: EventReceived}); err != nil {
+		t.Fatal("nil log should do nothing:", err)
+	}
+
+	l := NewLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if events, err := l.Events(100); err != nil || len(events) != 0 {
+		t.Fatal("expected no events without file", events, err)
+	}
+
+	mined := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	for _, event := range []Event{
+		{Time: mined.Add(2 * time.Second), Block: 100, Type: EventReceived},
+		{Time: mined, Block: 100, Type: EventMined},
+		{Time: mined.Add(3 * time.Second), Block: 101, Type: EventReceived},
+		{Time: mined.Add(40 * time.Second), Block: 100, Type: EventAlertSent, Notifier: "discord"},
+		{Time: mined.Add(90 * time.Second), Block: 100, Type: EventAck, User: "alice", Message: "miner contacted"},
+	} {
+		if err := l.Add(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := l.Events(100)
+	if err != nil || len(events) != 4 || events[0].Type != EventMined || events[3].Type != EventAck {
+		t.Fatal("unexpected events", events, err)
+	}
+
+	timeline := Timeline(events)
+	for _, expected := range []string{"+2s  received", "+40s  alert-sent        discord", "+1m30s  ack               by alice: miner contacted"} {
+		if !strings.Contains(timeline, expected) {
+			t.Errorf("timeline should contain '%s':\n%s", expected, timeline)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notification statuses
+const (
+	NotificationDelivered = "delivered"
+	NotificationFailed    = "failed" // all attempts failed, the alert was sent to the next channel or saved as undelivered
+)
+
+// Notification is one message sent to a channel (eg. discord, discord-fallback, discord-ops), with the outcome of
+// the delivery
+type Notification struct {
+	Time      time.Time `json:"time"`
+	Channel   string    `json:"channel"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	Blocks    []int64   `json:"blocks,omitempty"`
+	Incidents []string  `json:"incidents,omitempty"` // IDs of the multi-block incidents
+	Payload   string    `json:"payload"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// NotificationLog appends every notification to a JSON-lines file, so what was alerted and when can be audited
+// independent of the retention of the chat platform. The file is rotated like watcher.RotatingFile: when it exceeds
+// MaxSize it's renamed with a timestamp (eg. notifications-20211016T120000.000000000.jsonl), and only the newest
+// MaxBackups rotated files are kept. All methods of a nil NotificationLog do nothing.
+type NotificationLog struct {
+	Filename   string
+	MaxSize    int64 // bytes, 0 = never rotate
+	MaxBackups int   // 0 = keep all rotated files
+
+	lock       sync.Mutex
+	rotateLock sync.RWMutex // renaming and removing files, and opening them for queries
+}
+
+func NewNotificationLog(filename string, maxSize int64, maxBackups int) *NotificationLog {
+	return &NotificationLog{Filename: filename, MaxSize: maxSize, MaxBackups: maxBackups}
+}
+
+// Add appends the notification, with the current time if not set
+func (l *NotificationLog) Add(n Notification) error {
+	if l == nil {
+		return nil
+	}
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	n.Time = n.Time.UTC()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if err := l.rotate(); err != nil {
+		return err
+	}
+	return appendLine(l.Filename, n)
+}
+
+// backupPrefix and backupSuffix of the rotated files, eg. "notifications-" and ".jsonl"
+func (l *NotificationLog) backupPrefix() (prefix string, suffix string) {
+	suffix = filepath.Ext(l.Filename)
+	return strings.TrimSuffix(l.Filename, suffix) + "-", suffix
+}
+
+// Backups returns the rotated files, oldest first
+func (l *NotificationLog) Backups() ([]string, error) {
+	prefix, suffix := l.backupPrefix()
+	files, err := filepath.Glob(prefix + "*" + suffix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files) // timestamps sort chronologically
+	return files, nil
+}
+
+// rotate renames the file if it reached MaxSize, and removes the oldest rotated files
+func (l *NotificationLog) rotate() error {
+	if l.MaxSize <= 0 {
+		return nil
+	}
+	info, err := os.Stat(l.Filename)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && info.Size() < l.MaxSize) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	l.rotateLock.Lock()
+	defer l.rotateLock.Unlock()
+
+	prefix, suffix := l.backupPrefix()
+	if err := os.Rename(l.Filename, prefix+time.Now().UTC().Format("20060102T150405.000000000")+suffix); err != nil {
+		return err
+	}
+	if l.MaxBackups <= 0 {
+		return nil
+	}
+	backups, err := l.Backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > l.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// NotificationQuery selects notifications. Zero values match all.
+type NotificationQuery struct {
+	Since    time.Time
+	Until    time.Time // exclusive
+	Channel  string
+	Status   string
+	Block    int64
+	Incident string
+	Limit    int // the newest
+}
+
+func (q NotificationQuery) Matches(n Notification) bool {
+	if (!q.Since.IsZero() && n.Time.Before(q.Since)) || (!q.Until.IsZero() && !n.Time.Before(q.Until)) {
+		return false
+	}
+	if (q.Channel != "" && n.Channel != q.Channel) || (q.Status != "" && n.Status != q.Status) {
+		return false
+	}
+	if q.Block != 0 && !containsBlock(n.Blocks, q.Block) {
+		return false
+	}
+	if q.Incident != "" && !containsString(n.Incidents, q.Incident) {
+		return false
+	}
+	return true
+}
+
+func containsBlock(blocks []int64, block int64) bool {
+	for _, b := range blocks {
+		if b == block {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Query returns the matching notifications, newest first. The files are read from the end and only until Limit
+// notifications match, without blocking Add.
+func (l *NotificationLog) Query(q NotificationQuery) (notifications []Notification, err error) {
+	if l == nil {
+		return nil, nil
+	}
+
+	files, err := l.openFiles()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	for _, f := range files {
+		err = readLinesReverse(f, func(data []byte) (bool, error) {
+			var n Notification
+			if err := json.Unmarshal(data, &n); err != nil {
+				return false, fmt.Errorf("%s: %w", f.Name(), err)
+			}
+			if q.Matches(n) {
+				notifications = append(notifications, n)
+			}
+			return q.Limit <= 0 || len(notifications) < q.Limit, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if q.Limit > 0 && len(notifications) >= q.Limit {
+			break
+		}
+	}
+	return notifications, nil
+}
+
+// openFiles opens the current file and the rotated files, newest first. Once open, they can be read while new
+// notifications are added and the files are rotated.
+func (l *NotificationLog) openFiles() (files []*os.File, err error) {
+	l.rotateLock.RLock()
+	defer l.rotateLock.RUnlock()
+
+	backups, err := l.Backups()
+	if err != nil {
+		return nil, err
+	}
+	filenames := []string{l.Filename}
+	for i := len(backups) - 1; i >= 0; i-- {
+		filenames = append(filenames, backups[i])
+	}
+
+	for _, filename := range filenames {
+		f, err := os.Open(filename)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// readChunkSize of readLinesReverse
+var readChunkSize = 64 * 1024
+
+// readLinesReverse calls fn for every line of the file, last line first, until fn returns false. An incomplete last
+// line (being appended) is skipped.
+func readLinesReverse(f *os.File, fn func(data []byte) (bool, error)) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	var data []byte   // read and not yet passed to fn
+	complete := false // data ends with a newline
+	for pos := info.Size(); pos > 0 || len(data) > 0; {
+		if pos > 0 {
+			n := int64(readChunkSize)
+			if n > pos {
+				n = pos
+			}
+			pos -= n
+			chunk := make([]byte, n, n+int64(len(data)))
+			if _, err := f.ReadAt(chunk, pos); err != nil {
+				return err
+			}
+			data = append(chunk, data...)
+		}
+
+		if !complete {
+			i := bytes.LastIndexByte(data, '\n')
+			if i < 0 {
+				if pos == 0 {
+					return nil
+				}
+				continue
+			}
+			data, complete = data[:i+1], true
+		}
+
+		// The last line of data is complete if a newline precedes it, or at the start of the file
+		for len(data) > 0 {
+			i := bytes.LastIndexByte(data[:len(data)-1], '\n')
+			if i < 0 && pos > 0 {
+				break
+			}
+			line := data[i+1 : len(data)-1]
+			data = data[:i+1]
+			if len(line) == 0 {
+				continue
+			}
+			if more, err := fn(line); err != nil || !more {
+				return err
+			}
+		}
+	}
+	return nil
+}
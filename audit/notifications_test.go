@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNotificationLog(t *testing.T) {
+	var nilLog *NotificationLog
+	if err := nilLog.Add(Notification{Channel: "discord"}); err != nil {
+		t.Fatal("nil log should do nothing:", err)
+	}
+
+	l := NewNotificationLog(filepath.Join(t.TempDir(), "notifications.jsonl"), 0, 0)
+	if notifications, err := l.Query(NotificationQuery{}); err != nil || len(notifications) != 0 {
+		t.Fatal("expected no notifications without file", notifications, err)
+	}
+
+	start := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	for _, n := range []Notification{
+		{Time: start, Channel: "discord", Status: NotificationFailed, Attempts: 3, Blocks: []int64{100}, Payload: "block 100", Error: "timeout"},
+		{Time: start.Add(time.Second), Channel: "discord-fallback", Status: NotificationDelivered, Attempts: 1, Blocks: []int64{100}, Payload: "block 100"},
+		{Time: start.Add(time.Minute), Channel: "discord", Status: NotificationDelivered, Attempts: 1, Blocks: []int64{105}, Incidents: []string{"miner-0xabc-bundlePaysMore-101"}, Payload: "incident"},
+		{Time: start.Add(time.Hour), Channel: "discord-ops", Status: NotificationDelivered, Attempts: 1, Payload: "restarted"},
+	} {
+		if err := l.Add(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := l.Query(NotificationQuery{})
+	if err != nil || len(all) != 4 || all[0].Channel != "discord-ops" {
+		t.Fatal("expected all notifications, newest first", all, err)
+	}
+
+	for name, test := range map[string]struct {
+		query    NotificationQuery
+		expected int
+	}{
+		"block":    {NotificationQuery{Block: 100}, 2},
+		"status":   {NotificationQuery{Status: NotificationFailed}, 1},
+		"channel":  {NotificationQuery{Channel: "discord"}, 2},
+		"incident": {NotificationQuery{Incident: "miner-0xabc-bundlePaysMore-101"}, 1},
+		"time":     {NotificationQuery{Since: start.Add(time.Second), Until: start.Add(time.Hour)}, 2},
+		"limit":    {NotificationQuery{Limit: 3}, 3},
+	} {
+		if notifications, err := l.Query(test.query); err != nil || len(notifications) != test.expected {
+			t.Errorf("%s: expected %d notifications, got %d (%v)", name, test.expected, len(notifications), err)
+		}
+	}
+}
+
+func TestNotificationLogRotation(t *testing.T) {
+	chunkSize := readChunkSize
+	readChunkSize = 100 // lines span chunks
+	defer func() { readChunkSize = chunkSize }()
+
+	l := NewNotificationLog(filepath.Join(t.TempDir(), "notifications.jsonl"), 1000, 2)
+	start := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 50; i++ {
+		if err := l.Add(Notification{Time: start.Add(time.Duration(i) * time.Second), Channel: "discord", Blocks: []int64{int64(i)}, Payload: fmt.Sprintf("block %d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if backups, err := l.Backups(); err != nil || len(backups) != 2 {
+		t.Fatal("expected 2 rotated files", backups, err)
+	}
+
+	notifications, err := l.Query(NotificationQuery{Limit: 10})
+	if err != nil || len(notifications) != 10 {
+		t.Fatal("expected 10 notifications", notifications, err)
+	}
+	for i, n := range notifications {
+		if n.Blocks[0] != int64(49-i) {
+			t.Fatalf("notification %d: expected block %d, got %d", i, 49-i, n.Blocks[0])
+		}
+	}
+
+	// Older notifications are in the rotated files, the oldest were removed
+	all, err := l.Query(NotificationQuery{})
+	if err != nil || len(all) <= 10 || len(all) >= 50 || all[len(all)-1].Blocks[0] == 0 {
+		t.Fatal("expected the notifications of the current and the rotated files", len(all), err)
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Blocks[0] != all[i-1].Blocks[0]-1 {
+			t.Fatalf("expected the notifications newest first, got block %d after %d", all[i].Blocks[0], all[i-1].Blocks[0])
+		}
+	}
+
+	// A line being appended is skipped
+	f, err := os.OpenFile(l.Filename, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"time":"2021-09-01T13:00:00Z","channel":"disc`)
+	f.Close()
+	if notifications, err := l.Query(NotificationQuery{Limit: 1}); err != nil || len(notifications) != 1 || notifications[0].Blocks[0] != 49 {
+		t.Fatal("expected the incomplete line to be skipped", notifications, err)
+	}
+}
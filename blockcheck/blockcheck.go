@@ -1,21 +1,27 @@
 package blockcheck
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/metachris/flashbots/api"
 	"github.com/metachris/flashbots/common"
+	"github.com/metachris/flashbots/logging"
 	"github.com/metachris/go-ethutils/addresslookup"
 	"github.com/metachris/go-ethutils/blockswithtx"
 	"github.com/metachris/go-ethutils/utils"
 )
 
+var log = logging.Module("blockcheck")
+
 var (
 	ErrFlashbotsApiDoesntHaveThatBlockYet = errors.New("flashbots API latest height < requested block height")
 )
@@ -28,6 +34,9 @@ var AddressesUpdated time.Time
 
 var FlashbotsBlockCache map[int64]api.FlashbotsBlock = make(map[int64]api.FlashbotsBlock)
 
+// If true, blocks without Flashbots transactions and without 0-gas (0 priority fee) transactions are not checked
+var SkipLowActivityBlocks bool
+
 type ErrorCounts struct {
 	FailedFlashbotsTx                  uint64
 	Failed0GasTx                       uint64
@@ -35,6 +44,16 @@ type ErrorCounts struct {
 	BundleHasLowerFeeThanLowestNonFbTx uint64
 	BundleHas0Fee                      uint64
 	BundleHasNegativeFee               uint64
+
+	BundleHasLowerPriorityFeeThanLowestNonFbTx uint64 // after London, replaces BundleHasLowerFeeThanLowestNonFbTx
+	CoinbaseTransferMismatch                   uint64 // traced coinbase transfer differs from the API (only with TraceRpcClient)
+	MegabundleNotFirst                         uint64 // a regular bundle is placed before the megabundle
+	MegabundleNotContiguous                    uint64 // regular transactions are placed between megabundle transactions
+	BundleNotAtTop                             uint64 // a bundle is placed after non-Flashbots transactions
+	RelayPaymentMismatch                       uint64 // on-chain proposer payment is lower than the relay bid (only with RelayClients)
+	ProposerPaymentMissed                      uint64 // the proposer's fee recipient wasn't paid (only with BeaconClient)
+	ProposerPaymentMisdirected                 uint64 // a relay delivered the payload with another fee recipient than registered (only with BeaconClient)
+	BuilderKeptLargeShare                      uint64 // builder kept more than ThresholdBuilderKeptSharePercent of the block value
 }
 
 func (ec *ErrorCounts) Add(counts ErrorCounts) {
@@ -44,12 +63,86 @@ func (ec *ErrorCounts) Add(counts ErrorCounts) {
 	ec.BundleHasLowerFeeThanLowestNonFbTx += counts.BundleHasLowerFeeThanLowestNonFbTx
 	ec.BundleHas0Fee += counts.BundleHas0Fee
 	ec.BundleHasNegativeFee += counts.BundleHasNegativeFee
+	ec.BundleHasLowerPriorityFeeThanLowestNonFbTx += counts.BundleHasLowerPriorityFeeThanLowestNonFbTx
+	ec.CoinbaseTransferMismatch += counts.CoinbaseTransferMismatch
+	ec.MegabundleNotFirst += counts.MegabundleNotFirst
+	ec.MegabundleNotContiguous += counts.MegabundleNotContiguous
+	ec.BundleNotAtTop += counts.BundleNotAtTop
+	ec.RelayPaymentMismatch += counts.RelayPaymentMismatch
+	ec.ProposerPaymentMissed += counts.ProposerPaymentMissed
+	ec.ProposerPaymentMisdirected += counts.ProposerPaymentMisdirected
+	ec.BuilderKeptLargeShare += counts.BuilderKeptLargeShare
+}
+
+func (ec *ErrorCounts) Sub(counts ErrorCounts) {
+	ec.FailedFlashbotsTx -= counts.FailedFlashbotsTx
+	ec.Failed0GasTx -= counts.Failed0GasTx
+	ec.BundlePaysMoreThanPrevBundle -= counts.BundlePaysMoreThanPrevBundle
+	ec.BundleHasLowerFeeThanLowestNonFbTx -= counts.BundleHasLowerFeeThanLowestNonFbTx
+	ec.BundleHas0Fee -= counts.BundleHas0Fee
+	ec.BundleHasNegativeFee -= counts.BundleHasNegativeFee
+	ec.BundleHasLowerPriorityFeeThanLowestNonFbTx -= counts.BundleHasLowerPriorityFeeThanLowestNonFbTx
+	ec.CoinbaseTransferMismatch -= counts.CoinbaseTransferMismatch
+	ec.MegabundleNotFirst -= counts.MegabundleNotFirst
+	ec.MegabundleNotContiguous -= counts.MegabundleNotContiguous
+	ec.BundleNotAtTop -= counts.BundleNotAtTop
+	ec.RelayPaymentMismatch -= counts.RelayPaymentMismatch
+	ec.ProposerPaymentMissed -= counts.ProposerPaymentMissed
+	ec.ProposerPaymentMisdirected -= counts.ProposerPaymentMisdirected
+	ec.BuilderKeptLargeShare -= counts.BuilderKeptLargeShare
+}
+
+type namedErrorCount struct {
+	name  string
+	count uint64
+}
+
+func (ec *ErrorCounts) namedCounts() []namedErrorCount {
+	return []namedErrorCount{
+		{ErrorFailedFlashbotsTx, ec.FailedFlashbotsTx},
+		{ErrorFailed0GasTx, ec.Failed0GasTx},
+		{ErrorBundlePaysMore, ec.BundlePaysMoreThanPrevBundle},
+		{ErrorBundleTooLowFee, ec.BundleHasLowerFeeThanLowestNonFbTx},
+		{ErrorBundleTooLowPriorityFee, ec.BundleHasLowerPriorityFeeThanLowestNonFbTx},
+		{ErrorBundleHas0Fee, ec.BundleHas0Fee},
+		{ErrorBundleHasNegativeFee, ec.BundleHasNegativeFee},
+		{ErrorCoinbaseTransferMismatch, ec.CoinbaseTransferMismatch},
+		{ErrorMegabundleNotFirst, ec.MegabundleNotFirst},
+		{ErrorMegabundleNotContiguous, ec.MegabundleNotContiguous},
+		{ErrorBundleNotAtTop, ec.BundleNotAtTop},
+		{ErrorRelayPaymentMismatch, ec.RelayPaymentMismatch},
+		{ErrorProposerPaymentMissed, ec.ProposerPaymentMissed},
+		{ErrorProposerPaymentMisdirected, ec.ProposerPaymentMisdirected},
+		{ErrorBuilderKeptLargeShare, ec.BuilderKeptLargeShare},
+	}
+}
+
+// Types returns the names of all error types with a count > 0
+func (ec *ErrorCounts) Types() (types []string) {
+	for _, c := range ec.namedCounts() {
+		if c.count > 0 {
+			types = append(types, c.name)
+		}
+	}
+	return types
+}
+
+// Map returns the counts of all error types with a count > 0, by name (see Types)
+func (ec *ErrorCounts) Map() map[string]uint64 {
+	counts := make(map[string]uint64)
+	for _, c := range ec.namedCounts() {
+		if c.count > 0 {
+			counts[c.name] = c.count
+		}
+	}
+	return counts
 }
 
 type BlockCheck struct {
 	Number           int64
-	Miner            string
+	Miner            string // the pool: the coinbase, or the pool it pays if it's a known payment splitter (see PaymentSplitters)
 	MinerName        string
+	Coinbase         string // the coinbase of the block
 	SkipFlashbotsApi bool
 
 	BlockWithTxReceipts   *blockswithtx.BlockWithTxReceipts
@@ -57,10 +150,44 @@ type BlockCheck struct {
 	FlashbotsApiBlock     *api.FlashbotsBlock
 	FlashbotsTransactions []api.FlashbotsTransaction
 	Bundles               []*common.Bundle
+	BundleGroups          []*BundleGroup // in block order, see GroupBundles
+
+	// Collection of errors: the messages, and the typed errors with the same messages
+	Errors      []string
+	CheckErrors []*CheckError
+	FailedTx    map[string]*FailedTx
+
+	// Informational findings, not counted as errors
+	Sandwiches              []*Sandwich
+	PrivateOrderFlowBundles []*PrivateOrderFlowBundle
+	WatchlistMatches        []*WatchlistMatch // watched addresses in Flashbots transactions (see WatchedAddresses)
+
+	// Inferred source of the block template: TemplateSourceFlashbots, TemplateSourceModified, or "" without bundles
+	TemplateSource  string
+	TemplateSignals []string // deviations from the Flashbots ordering
+
+	// Bids delivered by the relays for this block (only with RelayClients)
+	RelayBids []*RelayBid
+
+	// Proposer, fee recipient and payment of post-merge blocks (only with BeaconClient)
+	ProposerAudit *ProposerAudit
 
-	// Collection of errors
-	Errors   []string
-	FailedTx map[string]*FailedTx
+	// Value sent to the coinbase by each tx, including internal calls, by tx hash (nil if not traced, see TraceRpcClient)
+	CoinbaseTransfers map[ethcommon.Hash]*big.Int
+
+	// Gas prices (priority fees after London) of the non-Flashbots tx, set by the bundle-fee check
+	GasPrices *GasPriceDistribution
+
+	// Builder profitability, set for blocks with a proposer payment
+	BlockValue              *big.Int // priority fees and coinbase transfers
+	ProposerPaymentValue    *big.Int
+	BuilderKeptSharePercent float32
+
+	// Additional miner revenue with the correct bundle order, set for blocks with bundle order errors (only with SimulationRpc)
+	OrderingCost *big.Int
+
+	// Bundles with errors simulated on top of the parent block (see VerifyBundles)
+	BundleSimulations []*BundleSimulation
 
 	// Helpers to filter later in user code
 	BiggestBundlePercentPriceDiff             float32 // on order error, max % difference to previous bundle
@@ -74,41 +201,66 @@ type BlockCheck struct {
 	ManualHasSeriousError  bool // manually set by specific error conditions
 
 	ErrorCounter ErrorCounts
+
+	AddedToSummary bool // set by user code when the errors were counted in an ErrorSummary
+	IsLowActivity  bool // no Flashbots or 0-gas transactions, checks were skipped (only with SkipLowActivityBlocks)
+
+	ShedExpensiveSteps bool     // skip the expensive steps (see CheckBlockFast)
+	ShedSteps          []string // expensive steps which were skipped, the block should be checked again later
+
+	// The neighbouring blocks, set by the watcher for alerts with serious errors (nil if not available)
+	PreviousBlock *BlockSummary
+	NextBlock     *BlockSummary
 }
 
-func CheckBlock(blockWithTx *blockswithtx.BlockWithTxReceipts, skipFlashbotsApi bool) (blockCheck *BlockCheck, err error) {
-	// Init / update AddressLookup service
+// NewBlockCheck prepares the check of a block: queries the Flashbots API and creates the bundles, without running
+// the checks (see Steps)
+func NewBlockCheck(blockWithTx *blockswithtx.BlockWithTxReceipts, skipFlashbotsApi bool) (blockCheck *BlockCheck, err error) {
+	err = updateAddressLookup()
+	if err != nil {
+		return blockCheck, err
+	}
+	return newBlockCheck(blockWithTx, skipFlashbotsApi)
+}
+
+// updateAddressLookup initializes the AddressLookup service, and updates its addresses after 5 minutes
+func updateAddressLookup() error {
 	if AddressLookup == nil {
 		AddressLookup = addresslookup.NewAddressLookupService(nil)
-		err = AddressLookup.AddAllAddresses()
+		err := AddressLookup.AddAllAddresses()
 		if err != nil {
-			return blockCheck, err
+			return err
 		}
 		AddressesUpdated = time.Now()
-	} else { // after 5 minutes, update addresses
-		timeSinceAddressUpdate := time.Since(AddressesUpdated)
-		if timeSinceAddressUpdate.Seconds() > 60*5 {
-			AddressLookup.ClearCache()
-			AddressLookup.AddAllAddresses()
-			AddressesUpdated = time.Now()
-		}
+	} else if time.Since(AddressesUpdated).Seconds() > 60*5 {
+		AddressLookup.ClearCache()
+		AddressLookup.AddAllAddresses()
+		AddressesUpdated = time.Now()
 	}
+	return nil
+}
 
-	// Lookup miner details
-	minerAddr, _ := AddressLookup.GetAddressDetail(blockWithTx.Block.Coinbase().Hex())
-
-	// Create check result
+// newBlockCheck is NewBlockCheck without the AddressLookup service (miner names only from the labels)
+func newBlockCheck(blockWithTx *blockswithtx.BlockWithTxReceipts, skipFlashbotsApi bool) (blockCheck *BlockCheck, err error) {
+	// Create check result (the stats of coinbases which are payment splitters are counted for their pool)
+	coinbase := blockWithTx.Block.Coinbase().Hex()
+	miner, splitterName := PaymentSplitters.Resolve(coinbase)
 	check := BlockCheck{
 		BlockWithTxReceipts:   blockWithTx,
 		EthBlock:              blockWithTx.Block,
 		FlashbotsTransactions: make([]api.FlashbotsTransaction, 0),
 		SkipFlashbotsApi:      skipFlashbotsApi,
+		Number:                blockWithTx.Block.Number().Int64(),
+		Miner:                 miner,
+		MinerName:             minerName(miner),
+		Coinbase:              coinbase,
+		Bundles:               make([]*common.Bundle, 0),
+		FailedTx:              make(map[string]*FailedTx),
+		ErrorCounter:          ErrorCounts{},
+	}
 
-		Number:       blockWithTx.Block.Number().Int64(),
-		Miner:        blockWithTx.Block.Coinbase().Hex(),
-		MinerName:    minerAddr.Name,
-		Bundles:      make([]*common.Bundle, 0),
-		ErrorCounter: ErrorCounts{},
+	if check.MinerName == "" {
+		check.MinerName = splitterName
 	}
 
 	err = check.QueryFlashbotsApi()
@@ -117,16 +269,66 @@ func CheckBlock(blockWithTx *blockswithtx.BlockWithTxReceipts, skipFlashbotsApi
 	}
 
 	check.CreateBundles()
-	check.Check()
+	check.decodeBundleProtocols()
+	check.labelBundles()
 	return &check, nil
 }
 
-func (b *BlockCheck) AddError(msg string) {
-	b.Errors = append(b.Errors, msg)
+func CheckBlock(blockWithTx *blockswithtx.BlockWithTxReceipts, skipFlashbotsApi bool) (blockCheck *BlockCheck, err error) {
+	return checkBlock(blockWithTx, skipFlashbotsApi, false)
+}
+
+// CheckBlockFast is CheckBlock without the expensive steps (traces and simulations), for when checks have to keep up
+// with the chain. The skipped steps are listed in ShedSteps.
+func CheckBlockFast(blockWithTx *blockswithtx.BlockWithTxReceipts, skipFlashbotsApi bool) (blockCheck *BlockCheck, err error) {
+	return checkBlock(blockWithTx, skipFlashbotsApi, true)
+}
+
+func checkBlock(blockWithTx *blockswithtx.BlockWithTxReceipts, skipFlashbotsApi bool, shedExpensiveSteps bool) (blockCheck *BlockCheck, err error) {
+	check, err := NewBlockCheck(blockWithTx, skipFlashbotsApi)
+	if err != nil {
+		return blockCheck, err
+	}
+	if err = check.run(shedExpensiveSteps); err != nil {
+		return blockCheck, err
+	}
+	return check, nil
+}
+
+// run runs the enabled steps (see Steps), and skips the expensive ones if shedExpensiveSteps is set
+func (b *BlockCheck) run(shedExpensiveSteps bool) (err error) {
+	b.ShedExpensiveSteps = shedExpensiveSteps
+
+	blockLog := log.With("block", b.Number, "miner", b.Miner)
+	if SkipLowActivityBlocks && b.isLowActivity() {
+		b.IsLowActivity = true
+		blockLog.Debug("low activity block, checks skipped")
+		return nil
+	}
+
+	for _, step := range b.Steps() {
+		if !step.Enabled {
+			continue
+		}
+		if step.Expensive && b.ShedExpensiveSteps {
+			b.ShedSteps = append(b.ShedSteps, step.Name)
+			continue
+		}
+
+		blockLog.Debug("running check", "check", step.Name)
+		err = step.Run()
+		if err != nil {
+			blockLog.Warn("check failed", "check", step.Name, "err", err)
+			return err
+		}
+	}
+
+	blockLog.Debug("block checked", "bundles", len(b.Bundles), "errors", len(b.Errors), "shed", b.ShedSteps)
+	return nil
 }
 
 func (b *BlockCheck) HasErrors() bool {
-	return len(b.Errors) > 0
+	return len(b.Errors) > 0 || len(b.CheckErrors) > 0
 }
 
 func (b *BlockCheck) HasSeriousErrors() bool {
@@ -139,6 +341,21 @@ func (b *BlockCheck) HasSeriousErrors() bool {
 		return true
 	}
 
+	// Proposer was paid less than the relay bid
+	if b.ErrorCounter.RelayPaymentMismatch > 0 {
+		return true
+	}
+
+	// Proposer's fee recipient wasn't paid, or a relay used another fee recipient
+	if b.ErrorCounter.ProposerPaymentMissed > 0 || b.ErrorCounter.ProposerPaymentMisdirected > 0 {
+		return true
+	}
+
+	// Serious error of a custom check
+	if b.hasCustomCheckErrors(SeveritySerious) {
+		return true
+	}
+
 	// Bundle percent price diff
 	if b.BiggestBundlePercentPriceDiff >= ThresholdBiggestBundlePercentPriceDiff {
 		return true
@@ -159,24 +376,42 @@ func (b *BlockCheck) HasLessSeriousErrors() bool {
 	}
 
 	// Bundle percent price diff
-	if b.BiggestBundlePercentPriceDiff >= 25 {
+	if b.BiggestBundlePercentPriceDiff >= ThresholdLessSeriousBiggestBundlePercentPriceDiff {
 		return true
 	}
 
 	// Bundle lower than lowest non-fb tx
-	if b.BundleIsPayingLessThanLowestTxPercentDiff >= 25 {
+	if b.BundleIsPayingLessThanLowestTxPercentDiff >= ThresholdLessSeriousBundleIsPayingLessThanLowestTxPercentDiff {
+		return true
+	}
+
+	// Traced coinbase transfers don't match the API
+	if b.ErrorCounter.CoinbaseTransferMismatch > 0 {
+		return true
+	}
+
+	// Builder kept an unusually large share of the block value
+	if b.ErrorCounter.BuilderKeptLargeShare > 0 {
+		return true
+	}
+
+	// Any error of a custom check
+	if b.hasCustomCheckErrors("") {
 		return true
 	}
 
 	return false
 }
 
-// AddBundle adds the bundle and sorts them by Index
+// AddBundle adds the bundle and sorts them by Index (megabundles first on equal index)
 func (b *BlockCheck) AddBundle(bundle *common.Bundle) {
 	b.Bundles = append(b.Bundles, bundle)
 
 	// Bring bundles into order
 	sort.SliceStable(b.Bundles, func(i, j int) bool {
+		if b.Bundles[i].Index == b.Bundles[j].Index {
+			return b.Bundles[i].IsMegabundle() && !b.Bundles[j].IsMegabundle()
+		}
 		return b.Bundles[i].Index < b.Bundles[j].Index
 	})
 }
@@ -228,15 +463,20 @@ func (b *BlockCheck) CreateBundles() {
 	// Clear old bundles
 	b.Bundles = make([]*common.Bundle, 0)
 
-	// Create the bundles from all Flashbots transactions in this block
-	bundles := make(map[int64]*common.Bundle)
+	// Create the bundles from all Flashbots transactions in this block (megabundles and regular bundles have separate indexes)
+	type bundleKey struct {
+		isMegabundle bool
+		index        int64
+	}
+	bundles := make(map[bundleKey]*common.Bundle)
 	for _, tx := range b.FlashbotsApiBlock.Transactions {
-		bundleIndex := tx.BundleIndex
-		bundle, exists := bundles[bundleIndex]
+		key := bundleKey{isMegabundle: tx.BundleType == api.BundleTypeMegabundle, index: tx.BundleIndex}
+		bundle, exists := bundles[key]
 		if !exists {
 			bundle = common.NewBundle()
-			bundle.Index = bundleIndex
-			bundles[bundleIndex] = bundle
+			bundle.Index = tx.BundleIndex
+			bundle.BundleType = tx.BundleType
+			bundles[key] = bundle
 		}
 
 		// Update bundle information
@@ -260,8 +500,52 @@ func (b *BlockCheck) CreateBundles() {
 
 	// Add bundles to the block
 	for _, bundle := range bundles {
+		bundle.UpdateHash()
 		b.AddBundle(bundle)
 	}
+	b.BundleGroups = GroupBundles(b.Bundles)
+}
+
+// isLowActivity returns true if the block has no Flashbots transactions and no 0-gas transactions
+func (b *BlockCheck) isLowActivity() bool {
+	if len(b.FlashbotsTransactions) > 0 {
+		return false
+	}
+
+	for _, tx := range b.EthBlock.Transactions() {
+		if common.IsZeroPriorityFeeTx(tx, b.EthBlock.BaseFee()) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetBundle returns the regular bundle (not a megabundle) with the given index, or nil if not found. A megabundle can
+// have the same index as a regular bundle, see FindBundle.
+func (b *BlockCheck) GetBundle(index int64) *common.Bundle {
+	return b.FindBundle(index, false)
+}
+
+// FindBundle returns the megabundle or the regular bundle with the given index, or nil if not found
+func (b *BlockCheck) FindBundle(index int64, megabundle bool) *common.Bundle {
+	for _, bundle := range b.Bundles {
+		if bundle.Index == index && bundle.IsMegabundle() == megabundle {
+			return bundle
+		}
+	}
+	return nil
+}
+
+// GetBundleOfTx returns the bundle which contains the transaction, or nil if it isn't in a bundle
+func (b *BlockCheck) GetBundleOfTx(hash string) *common.Bundle {
+	for _, bundle := range b.Bundles {
+		for _, tx := range bundle.Transactions {
+			if tx.Hash == hash {
+				return bundle
+			}
+		}
+	}
+	return nil
 }
 
 func (b *BlockCheck) IsFlashbotsTx(hash string) bool {
@@ -273,47 +557,73 @@ func (b *BlockCheck) IsFlashbotsTx(hash string) bool {
 	return false
 }
 
-// Check analyzes the Flashbots bundles and adds errors when issues are found
+// Check analyzes the Flashbots bundles and adds errors when issues are found (only the enabled checks are run)
 func (b *BlockCheck) Check() {
-	// fmt.Println(b.Number, 1, b.FlashbotsApiBlock, len(b.FlashbotsTransactions))
-	numBundles := len(b.Bundles)
+	b.FailedTx = make(map[string]*FailedTx)
+	for _, step := range b.bundleSteps() {
+		if step.Enabled {
+			step.Run()
+		}
+	}
+}
 
-	// Check 0: contains failed Flashbots or 0-gas tx
-	b.checkBlockForFailedTx()
-	// fmt.Println(b.Number, 2, len(b.Errors))
+func (b *BlockCheck) checkMissingBundles() {
+	numBundles := len(b.Bundles)
 
 	// Check 1: do all bundles exists or are there gaps?
 	for i := 0; i < numBundles; i++ {
 		if b.Bundles[int64(i)] == nil {
-			b.AddError(fmt.Sprintf("- error: missing bundle # %d in block %d", i, b.Number))
+			b.addError(&CheckError{Check: CheckMissingBundle, Kind: ErrorMissingBundle, BundleIndex: int64(i), Message: fmt.Sprintf("- error: missing bundle # %d in block %d", i, b.Number)})
 		}
 	}
-	// fmt.Println(b.Number, 3, len(b.Errors))
+}
+
+func (b *BlockCheck) checkBundleOrder() {
+	numBundles := len(b.Bundles)
+
+	// Check 2a: megabundle precedence
+	b.checkMegabundleOrder()
 
-	// Check 2: are the bundles in the correct order?
+	// Check 2b: all bundles at the top of the block
+	b.checkBundlesAtTop()
+
+	// Check 2: are the bundles in the correct order? (megabundles are not compared with regular bundles). Bundles
+	// with the same effective gas price can be in any order.
 	lastCoinbaseDivGasused := big.NewInt(-1)
 	lastRewardDivGasused := big.NewInt(-1)
+	lastGroupIndex := -1
+	lastIndex := int64(-1)
+	var lastTxIndexes []int64
 	for i := 0; i < numBundles; i++ {
 		bundle := b.Bundles[int64(i)]
+		if bundle.IsMegabundle() {
+			continue
+		}
 
 		// if not first bundle, and value larger than from last bundle, print the error
 		if lastCoinbaseDivGasused.Int64() == -1 {
 			// nothing to do on the first bundle
+		} else if bundle.RewardDivGasUsed.Cmp(lastRewardDivGasused) == 0 {
+			// tie: no error, and no division by zero for two bundles without reward
+			bundle.PercentPriceDiff = new(big.Float)
 		} else {
-			percentDiff := new(big.Float).Quo(new(big.Float).SetInt(bundle.RewardDivGasUsed), new(big.Float).SetInt(lastRewardDivGasused))
-			percentDiff = new(big.Float).Sub(percentDiff, big.NewFloat(1))
-			percentDiff = new(big.Float).Mul(percentDiff, big.NewFloat(100))
+			percentDiff := common.PercentDiff(bundle.RewardDivGasUsed, lastRewardDivGasused)
 			bundle.PercentPriceDiff = percentDiff
 
 			if bundle.CoinbaseDivGasUsed.Cmp(lastCoinbaseDivGasused) == 1 &&
 				bundle.RewardDivGasUsed.Cmp(lastRewardDivGasused) == 1 &&
 				bundle.CoinbaseDivGasUsed.Cmp(lastRewardDivGasused) == 1 {
 
-				msg := fmt.Sprintf("bundle %d pays %v%s more than previous bundle\n", bundle.Index, percentDiff.Text('f', 2), "%")
-				b.AddError(msg)
+				mergedNote := ""
+				if bundle.GroupIndex == lastGroupIndex {
+					mergedNote = " (merged)"
+				}
+				msg := fmt.Sprintf("bundle %d (%s) at %s pays %s more than previous bundle %d at %s%s\n", bundle.Index, bundle.ShortHash(), txIndexesString(bundle.TxIndexes(), 0), common.FormatPercent(percentDiff), lastIndex, txIndexesString(lastTxIndexes, 0), mergedNote)
+				diffFloat, _ := percentDiff.Float32()
+				severity := percentSeverity(diffFloat, ThresholdBiggestBundlePercentPriceDiff, ThresholdLessSeriousBiggestBundlePercentPriceDiff)
+				b.addError(&CheckError{Check: CheckBundleOrder, Kind: ErrorBundlePaysMore, Severity: severity, BundleIndex: bundle.Index, Value: float64(diffFloat), Message: msg})
 				b.ErrorCounter.BundlePaysMoreThanPrevBundle += 1
 				bundle.IsOutOfOrder = true
-				diffFloat, _ := percentDiff.Float32()
 				if diffFloat > b.BiggestBundlePercentPriceDiff {
 					b.BiggestBundlePercentPriceDiff = diffFloat
 				}
@@ -322,69 +632,216 @@ func (b *BlockCheck) Check() {
 
 		lastCoinbaseDivGasused = bundle.CoinbaseDivGasUsed
 		lastRewardDivGasused = bundle.RewardDivGasUsed
+		lastGroupIndex = bundle.GroupIndex
+		lastIndex = bundle.Index
+		lastTxIndexes = bundle.TxIndexes()
+	}
+}
+
+// checkMegabundleOrder checks that megabundle transactions are at the top of the block, without regular transactions in
+// between. All megabundle transactions are checked as one group, the order inside the megabundle is not checked.
+func (b *BlockCheck) checkMegabundleOrder() {
+	megabundle := megabundleGroup(b.Bundles)
+	if megabundle == nil {
+		return
+	}
+
+	megaMin, megaMax := megabundle.TxIndexRange()
+	for _, bundle := range b.Bundles {
+		if bundle.IsMegabundle() {
+			continue
+		}
+
+		bundleMin, _ := bundle.TxIndexRange()
+		if bundleMin < megaMin {
+			msg := fmt.Sprintf("bundle %d (%s) is placed before megabundle %s\n", bundle.Index, bundle.ShortHash(), megabundle.Name())
+			b.addError(&CheckError{Check: CheckBundleOrder, Kind: ErrorMegabundleNotFirst, Severity: SeveritySerious, BundleIndex: bundle.Index, Message: msg})
+			b.ErrorCounter.MegabundleNotFirst += 1
+			bundle.IsOutOfOrder = true
+			b.ManualHasSeriousError = true
+		}
 	}
-	// fmt.Println(b.Number, 4, len(b.Errors))
 
-	// Check 3: bundle effective gas price > lowest tx gas price
-	// step 1. find lowest non-fb-tx gas price
-	lowestGasPrice := big.NewInt(-1)
-	lowestGasPriceTxHash := ""
+	if megaMax-megaMin+1 != megabundle.NumTx() {
+		msg := fmt.Sprintf("megabundle %s is not contiguous: %d transactions at tx index %d to %d\n", megabundle.Name(), megabundle.NumTx(), megaMin, megaMax)
+		b.addError(&CheckError{Check: CheckBundleOrder, Kind: ErrorMegabundleNotContiguous, Severity: SeveritySerious, BundleIndex: -1, Message: msg})
+		b.ErrorCounter.MegabundleNotContiguous += 1
+		for _, bundle := range megabundle.Bundles {
+			bundle.IsOutOfOrder = true
+		}
+		b.ManualHasSeriousError = true
+	}
+}
+
+// checkBundlesAtTop checks that all bundles are at the top of the block: a bundle placed after non-Flashbots
+// transactions is placed mid-block. The error lists the tx indexes of the bundle and of the non-Flashbots tx before it.
+func (b *BlockCheck) checkBundlesAtTop() {
+	isFlashbotsTx := make(map[int64]bool)
+	for _, tx := range b.FlashbotsTransactions {
+		isFlashbotsTx[tx.TxIndex] = true
+	}
+	for _, bundle := range b.Bundles {
+		for _, tx := range bundle.Transactions {
+			isFlashbotsTx[tx.TxIndex] = true
+		}
+	}
+
+	for _, bundle := range b.Bundles {
+		bundleMin, _ := bundle.TxIndexRange()
+		var before []int64
+		for i := int64(0); i < bundleMin; i++ {
+			if !isFlashbotsTx[i] {
+				before = append(before, i)
+			}
+		}
+		if len(before) == 0 {
+			continue
+		}
+
+		msg := fmt.Sprintf("bundle %d (%s) at %s is placed mid-block, after non-Flashbots %s\n", bundle.Index, bundle.ShortHash(), txIndexesString(bundle.TxIndexes(), 0), txIndexesString(before, maxListedTxIndexes))
+		b.addError(&CheckError{Check: CheckBundleOrder, Kind: ErrorBundleNotAtTop, Severity: SeveritySerious, BundleIndex: bundle.Index, Value: float64(len(before)), Message: msg})
+		b.ErrorCounter.BundleNotAtTop += 1
+		bundle.IsOutOfOrder = true
+		b.ManualHasSeriousError = true
+	}
+}
+
+// Max. tx indexes listed in errors, the others are counted
+const maxListedTxIndexes = 5
+
+// txIndexesString returns the tx indexes as "tx 3" or "tx 3, 4, 7", with at most max indexes (0 = all)
+func txIndexesString(indexes []int64, max int) string {
+	if len(indexes) == 0 {
+		return "no tx"
+	}
+	listed := indexes
+	if max > 0 && len(listed) > max {
+		listed = listed[:max]
+	}
+	parts := make([]string, 0, len(listed))
+	for _, index := range listed {
+		parts = append(parts, strconv.FormatInt(index, 10))
+	}
+	ret := "tx " + strings.Join(parts, ", ")
+	if len(listed) < len(indexes) {
+		ret += fmt.Sprintf(" and %d more", len(indexes)-len(listed))
+	}
+	return ret
+}
+
+// LowestNonFlashbotsTxGasPrice returns the lowest gas price (priority fee after London) of the transactions which are
+// neither Flashbots nor Flashbots-like, and its tx hash. The gas price is -1 if there is no such transaction.
+func (b *BlockCheck) LowestNonFlashbotsTxGasPrice() (gasPrice *big.Int, txHash string) {
+	baseFee := b.EthBlock.BaseFee()
+	gasPrice = big.NewInt(-1)
 	for _, tx := range b.EthBlock.Transactions() {
 		isFlashbotsTx := b.IsFlashbotsTx(tx.Hash().String())
 		if isFlashbotsTx {
 			continue
 		}
 
-		if lowestGasPrice.Int64() == -1 || tx.GasPrice().Cmp(lowestGasPrice) == -1 {
-			if utils.IsBigIntZero(tx.GasPrice()) && len(tx.Data()) > 0 { // don't count Flashbots-like tx
-				continue
-			}
-			lowestGasPrice = tx.GasPrice()
-			lowestGasPriceTxHash = tx.Hash().Hex()
+		if common.IsFlashbotsLikeTx(tx, b.EthBlock, b.CoinbaseTransfers) { // don't count Flashbots-like tx
+			continue
+		}
+
+		txGasPrice := common.TxPriorityFee(tx, baseFee)
+		if gasPrice.Int64() == -1 || txGasPrice.Cmp(gasPrice) == -1 {
+			gasPrice = txGasPrice
+			txHash = tx.Hash().Hex()
 		}
 	}
+	return gasPrice, txHash
+}
 
-	// step 2. check gas prices and fees
+func (b *BlockCheck) checkBundleFees() {
+	// Check 3: bundle effective gas price > reference tx gas price (lowest tx, or p1/p5/p10)
+	// After London the priority fees are compared (the part of the gas price that goes to the miner)
+	baseFee := b.EthBlock.BaseFee()
+	isLondon := baseFee != nil
+
+	// step 1. find the reference gas price of the non-fb-tx (priority fee after London)
+	referenceGasPrice, referenceTxHash := b.ReferenceGasPrice()
+	referenceName := referenceTxName()
+	if referenceGasPrice.Sign() >= 0 {
+		for _, bundle := range b.Bundles {
+			bundle.TailGasPrice = referenceGasPrice
+		}
+	}
+
+	// step 2. check gas prices and fees (a megabundle is checked as a whole, it may pay only in one of its sub-bundles)
+	bundles := make([]*common.Bundle, 0, len(b.Bundles))
 	for _, bundle := range b.Bundles {
+		if !bundle.IsMegabundle() {
+			bundles = append(bundles, bundle)
+		}
+	}
+	if megabundle := megabundleGroup(b.Bundles); megabundle != nil {
+		bundles = append([]*common.Bundle{megabundle.Combined()}, bundles...)
+	}
+
+	for _, bundle := range bundles {
 		if bundle.RewardDivGasUsed.Cmp(ethcommon.Big0) == -1 { // negative fee
 			bundle.IsNegativeEffectiveGasPrice = true
-			msg := fmt.Sprintf("bundle %d has negative effective-gas-price (%v)\n", bundle.Index, common.BigIntToEString(bundle.RewardDivGasUsed, 4))
-			b.AddError(msg)
+			msg := fmt.Sprintf("bundle %d (%s) has negative effective-gas-price (%s)\n", bundle.Index, bundle.ShortHash(), common.FormatGwei(bundle.RewardDivGasUsed))
+			b.addError(&CheckError{Check: CheckBundleFee, Kind: ErrorBundleHasNegativeFee, Severity: SeveritySerious, BundleIndex: bundle.Index, Message: msg})
 			b.ErrorCounter.BundleHasNegativeFee += 1
 			b.ManualHasSeriousError = true
 
 		} else if utils.IsBigIntZero(bundle.RewardDivGasUsed) { // 0 fee
 			bundle.Is0EffectiveGasPrice = true
-			msg := fmt.Sprintf("bundle %d has 0 effective-gas-price\n", bundle.Index)
-			b.AddError(msg)
+			msg := fmt.Sprintf("bundle %d (%s) has 0 effective-gas-price\n", bundle.Index, bundle.ShortHash())
+			b.addError(&CheckError{Check: CheckBundleFee, Kind: ErrorBundleHas0Fee, Severity: SeveritySerious, BundleIndex: bundle.Index, Message: msg})
 			b.ErrorCounter.BundleHas0Fee += 1
 			b.HasBundleWith0EffectiveGasPrice = true
 			b.ManualHasSeriousError = true
 
-		} else if bundle.RewardDivGasUsed.Cmp(lowestGasPrice) == -1 { // lower fee than lowest non-fb TX
+		} else if bundle.RewardDivGasUsed.Cmp(referenceGasPrice) == -1 { // lower fee than the reference non-fb TX
 			bundle.IsPayingLessThanLowestTx = true
 
-			// calculate percent difference:
-			fCur := new(big.Float).SetInt(bundle.RewardDivGasUsed)
-			fLow := new(big.Float).SetInt(lowestGasPrice)
-			diffPercent1 := new(big.Float).Quo(fCur, fLow)
-			diffPercent2 := new(big.Float).Sub(big.NewFloat(1), diffPercent1)
-			diffPercent := new(big.Float).Mul(diffPercent2, big.NewFloat(100))
+			// how many percent lower than the reference
+			diffPercent := new(big.Float).Neg(common.PercentDiff(bundle.RewardDivGasUsed, referenceGasPrice))
+			diffFloat, _ := diffPercent.Float32()
+			severity := percentSeverity(diffFloat, ThresholdBundleIsPayingLessThanLowestTxPercentDiff, ThresholdLessSeriousBundleIsPayingLessThanLowestTxPercentDiff)
+
+			// position of the bundle in the gas prices of the non-fb tx of the block
+			gasPrices := b.gasPriceDistribution()
+			bundle.FeePercentile = gasPrices.Percentile(bundle.RewardDivGasUsed)
+			belowMaxPercentile := ThresholdBundleLowFeeMaxPercentile <= 0 || bundle.FeePercentile < ThresholdBundleLowFeeMaxPercentile
+			if !belowMaxPercentile {
+				severity = ""
+			}
+			percentileNote := fmt.Sprintf(", %.0f%% of the block's non-fb tx pay less (%s)", bundle.FeePercentile, gasPrices)
+
+			if isLondon {
+				msg := fmt.Sprintf("bundle %d (%s) has %s lower priority-fee (%s) than [%s](<%s>) (%s)%s\n", bundle.Index, bundle.ShortHash(), common.FormatPercent(diffPercent), common.FormatGwei(bundle.RewardDivGasUsed), referenceName, common.TxUrl(referenceTxHash), common.FormatGwei(referenceGasPrice), percentileNote)
+				b.addError(&CheckError{Check: CheckBundleFee, Kind: ErrorBundleTooLowPriorityFee, Severity: severity, BundleIndex: bundle.Index, TxHash: referenceTxHash, Value: float64(diffFloat), Percentile: bundle.FeePercentile, Message: msg})
+				b.ErrorCounter.BundleHasLowerPriorityFeeThanLowestNonFbTx += 1
+			} else {
+				msg := fmt.Sprintf("bundle %d (%s) has %s lower effective-gas-price (%s) than [%s](<%s>) (%s)%s\n", bundle.Index, bundle.ShortHash(), common.FormatPercent(diffPercent), common.FormatGwei(bundle.RewardDivGasUsed), referenceName, common.TxUrl(referenceTxHash), common.FormatGwei(referenceGasPrice), percentileNote)
+				b.addError(&CheckError{Check: CheckBundleFee, Kind: ErrorBundleTooLowFee, Severity: severity, BundleIndex: bundle.Index, TxHash: referenceTxHash, Value: float64(diffFloat), Percentile: bundle.FeePercentile, Message: msg})
+				b.ErrorCounter.BundleHasLowerFeeThanLowestNonFbTx += 1
+			}
+			if belowMaxPercentile {
+				b.BundleIsPayingLessThanLowestTxPercentDiff = diffFloat
+			}
+		}
+	}
 
-			msg := fmt.Sprintf("bundle %d has %s%s lower effective-gas-price (%v) than [lowest non-fb transaction](<https://etherscan.io/tx/%s>) (%v)\n", bundle.Index, diffPercent.Text('f', 2), "%", common.BigIntToEString(bundle.RewardDivGasUsed, 4), lowestGasPriceTxHash, common.BigIntToEString(lowestGasPrice, 4))
-			b.AddError(msg)
-			b.ErrorCounter.BundleHasLowerFeeThanLowestNonFbTx += 1
-			b.BundleIsPayingLessThanLowestTxPercentDiff, _ = diffPercent.Float32()
+	// Mark the sub-bundles of a combined megabundle
+	if megabundle := megabundleGroup(b.Bundles); megabundle != nil && len(megabundle.Bundles) > 1 {
+		combined := bundles[0]
+		for _, bundle := range megabundle.Bundles {
+			bundle.IsNegativeEffectiveGasPrice = combined.IsNegativeEffectiveGasPrice
+			bundle.Is0EffectiveGasPrice = combined.Is0EffectiveGasPrice
+			bundle.IsPayingLessThanLowestTx = combined.IsPayingLessThanLowestTx
 		}
 	}
-	// fmt.Println(b.Number, 5, len(b.Errors))
 }
 
 func (b *BlockCheck) SprintHeader(color bool, markdown bool) (msg string) {
-	minerAddr, found := AddressLookup.GetAddressDetail(b.Miner)
-	minerStr := fmt.Sprintf("[%s](<https://etherscan.io/address/%s>)", b.Miner, b.Miner)
-	if found {
-		minerStr = fmt.Sprintf("[%s](<https://etherscan.io/address/%s>)", minerAddr.Name, b.Miner)
+	minerStr := fmt.Sprintf("[%s](<%s>)", b.Miner, common.AddressUrl(b.Miner))
+	if b.MinerName != "" {
+		minerStr = fmt.Sprintf("[%s](<%s>)", b.MinerName, common.AddressUrl(b.Miner))
 	}
 
 	numTx := len(b.BlockWithTxReceipts.Block.Transactions())
@@ -392,7 +849,11 @@ func (b *BlockCheck) SprintHeader(color bool, markdown bool) (msg string) {
 	numBundles := len(b.Bundles)
 
 	if markdown {
-		msg = fmt.Sprintf("Block [%d](<https://etherscan.io/block/%d>) ([bundle explorer](<https://flashbots-explorer.marto.lol/?block=%d>)), miner: %s - tx: %d, fb-tx: %d, bundles: %d", b.Number, b.Number, b.Number, minerStr, numTx, numFbTx, numBundles)
+		bundleExplorer := "" // if the chain has one
+		if url := common.BundleUrl(b.Number); url != "" {
+			bundleExplorer = fmt.Sprintf(" ([bundle explorer](<%s>))", url)
+		}
+		msg = fmt.Sprintf("Block [%d](<%s>)%s, miner: %s - tx: %d, fb-tx: %d, bundles: %d", b.Number, common.BlockUrl(b.Number), bundleExplorer, minerStr, numTx, numFbTx, numBundles)
 	} else {
 		msg = fmt.Sprintf("Block %d, miner %s - tx: %d, fb-tx: %d, bundles: %d", b.Number, minerStr, numTx, numFbTx, numBundles)
 	}
@@ -405,14 +866,46 @@ func (b *BlockCheck) Sprint(color bool, markdown bool, includeBundles bool) (msg
 
 	// Print errors
 	for _, err := range b.Errors {
-		err = "- error: " + err
+		line := "- error: " + err
 		if color {
-			msg += fmt.Sprintf(utils.WarningColor, err)
+			msg += fmt.Sprintf(utils.WarningColor, line)
 		} else {
-			msg += err
+			msg += line
 		}
 	}
 
+	if b.OrderingCost != nil {
+		msg += fmt.Sprintf("- ordering cost: %s (simulated with the correct bundle order)\n", common.FormatEth(b.OrderingCost))
+	}
+	for _, sim := range b.BundleSimulations {
+		msg += "- " + sim.String() + "\n"
+	}
+
+	// Print informational findings
+	for _, sandwich := range b.Sandwiches {
+		msg += "- info: " + sandwich.String() + "\n"
+	}
+	for _, bundle := range b.PrivateOrderFlowBundles {
+		msg += "- info: " + bundle.String() + "\n"
+	}
+	for _, match := range b.WatchlistMatches {
+		msg += "- info: " + match.String() + "\n"
+	}
+	if b.TemplateSource == TemplateSourceModified {
+		msg += "- info: " + b.TemplateString() + "\n"
+	}
+	if len(b.ShedSteps) > 0 {
+		msg += fmt.Sprintf("- info: partial check, skipped: %s (checked completely later)\n", strings.Join(b.ShedSteps, ", "))
+	}
+
+	// Print the neighbouring blocks, to see whether the errors are isolated
+	if b.PreviousBlock != nil {
+		msg += "- previous " + b.PreviousBlock.String() + "\n"
+	}
+	if b.NextBlock != nil {
+		msg += "- next " + b.NextBlock.String() + "\n"
+	}
+
 	if !includeBundles {
 		return msg
 	}
@@ -421,6 +914,14 @@ func (b *BlockCheck) Sprint(color bool, markdown bool, includeBundles bool) (msg
 		msg += "```"
 	}
 
+	// Print groups of bundles which were placed as one unit
+	for _, group := range b.BundleGroups {
+		if len(group.Bundles) > 1 {
+			min, max := group.TxIndexRange()
+			msg += fmt.Sprintf("- group %d (%s): %s, tx %d to %d, reward/gasused: %s\n", group.Index, group.Type, group.Name(), min, max, common.FormatGwei(group.Combined().RewardDivGasUsed))
+		}
+	}
+
 	// Print bundles
 	for _, bundle := range b.Bundles {
 		// Build string for percent(gasprice difference to previous bundle)
@@ -431,7 +932,17 @@ func (b *BlockCheck) Sprint(color bool, markdown bool, includeBundles bool) (msg
 			percentPart = fmt.Sprintf("(+%5s%s)", bundle.PercentPriceDiff.Text('f', 2), "%")
 		}
 
-		msg += fmt.Sprintf("- bundle %d: tx: %d, gasUsed: %7d \t coinbase_transfer: %13v, total_miner_reward: %13v \t coinbase/gasused: %13v, reward/gasused: %13v %v", bundle.Index, len(bundle.Transactions), bundle.TotalGasUsed, common.BigIntToEString(bundle.TotalCoinbaseTransfer, 4), common.BigIntToEString(bundle.TotalMinerReward, 4), common.BigIntToEString(bundle.CoinbaseDivGasUsed, 4), common.BigIntToEString(bundle.RewardDivGasUsed, 4), percentPart)
+		msg += fmt.Sprintf("- bundle %d %s: tx: %d, gasUsed: %7d \t coinbase_transfer: %13v, total_miner_reward: %13v \t coinbase/gasused: %13v, reward/gasused: %13v %v", bundle.Index, bundle.Hash, len(bundle.Transactions), bundle.TotalGasUsed, common.FormatEth(bundle.TotalCoinbaseTransfer), common.FormatEth(bundle.TotalMinerReward), common.FormatGwei(bundle.CoinbaseDivGasUsed), common.FormatGwei(bundle.RewardDivGasUsed), percentPart)
+		msg += fmt.Sprintf(" \t gas_fees: %s (coinbase share %.0f%%)", common.FormatEth(bundle.GasFees()), bundle.CoinbaseShare()*100)
+		if margin, ok := bundle.TailGasPriceMargin(); ok {
+			msg += fmt.Sprintf(", margin: %+.2f%% over the tail gas price (%s)", margin, common.FormatGwei(bundle.TailGasPrice))
+		}
+		if len(bundle.Protocols) > 0 {
+			msg += " \t protocols: " + bundle.ProtocolsString()
+		}
+		if bundle.SearcherName != "" {
+			msg += " \t searcher: " + bundle.SearcherName
+		}
 		if bundle.IsOutOfOrder || bundle.IsPayingLessThanLowestTx {
 			msg += " <--"
 		}
@@ -456,17 +967,26 @@ func (b *BlockCheck) checkBlockForFailedTx() (failedTransactions []FailedTx) {
 		}
 
 		if receipt.Status == 0 { // failed Flashbots TX
-			b.FailedTx[fbTx.Hash] = &FailedTx{
-				Hash:        fbTx.Hash,
-				IsFlashbots: true,
-				From:        fbTx.EoaAddress,
-				To:          fbTx.ToAddress,
-				Block:       uint64(fbTx.BlockNumber),
+			bundleHash := ""
+			if bundle := b.GetBundleOfTx(fbTx.Hash); bundle != nil {
+				bundleHash = bundle.Hash
 			}
 
-			msg := fmt.Sprintf("failed %s tx [%s](<https://etherscan.io/tx/%s>) in bundle %d (from [%s](<https://etherscan.io/address/%s>))\n", fbTx.BundleType, fbTx.Hash, fbTx.Hash, fbTx.BundleIndex, fbTx.EoaAddress, fbTx.EoaAddress)
+			tx := b.EthBlock.Transaction(receipt.TxHash)
+			if tx == nil {
+				continue
+			}
+			failedTx := newFailedTx(tx, receipt, b.EthBlock.BaseFee(), b.traceRevertReasons())
+			failedTx.IsFlashbots = true
+			failedTx.From = fbTx.EoaAddress
+			failedTx.To = fbTx.ToAddress
+			failedTx.Block = uint64(fbTx.BlockNumber)
+			failedTx.BundleHash = bundleHash
+			b.FailedTx[fbTx.Hash] = failedTx
+
+			msg := fmt.Sprintf("failed %s tx [%s](<%s>) in bundle %d (%.10s) (from %s), %s\n", fbTx.BundleType, fbTx.Hash, common.TxUrl(fbTx.Hash), fbTx.BundleIndex, bundleHash, searcherLink(fbTx.EoaAddress), failedTx.Summary())
 			b.ErrorCounter.FailedFlashbotsTx += 1
-			b.AddError(msg)
+			b.addError(&CheckError{Check: CheckFailedTx, Kind: ErrorFailedFlashbotsTx, Severity: SeveritySerious, BundleIndex: fbTx.BundleIndex, TxHash: fbTx.Hash, Message: msg})
 			b.HasFailedFlashbotsTx = true
 			if fbTx.BundleType == api.BundleTypeFlashbots { // alert only for type=flashbots
 				b.TriggerAlertOnFailedTx = true
@@ -481,7 +1001,7 @@ func (b *BlockCheck) checkBlockForFailedTx() (failedTransactions []FailedTx) {
 			continue
 		}
 
-		if utils.IsBigIntZero(tx.GasPrice()) && len(tx.Data()) > 0 {
+		if common.IsZeroPriorityFeeTx(tx, b.EthBlock.BaseFee()) {
 			if receipt.Status == 0 { // failed tx
 				if _, exists := b.FailedTx[tx.Hash().String()]; exists {
 					// Already known (Flashbots TX)
@@ -493,16 +1013,14 @@ func (b *BlockCheck) checkBlockForFailedTx() (failedTransactions []FailedTx) {
 				if tx.To() != nil {
 					to = tx.To().String()
 				}
-				b.FailedTx[tx.Hash().String()] = &FailedTx{
-					Hash:        tx.Hash().String(),
-					IsFlashbots: false,
-					From:        from.String(),
-					To:          to,
-					Block:       uint64(b.Number),
-				}
-
-				msg := fmt.Sprintf("failed 0-gas tx [%s](<https://etherscan.io/tx/%s>) from [%s](<https://etherscan.io/address/%s>)\n", tx.Hash(), tx.Hash(), from, from)
-				b.AddError(msg)
+				failedTx := newFailedTx(tx, receipt, b.EthBlock.BaseFee(), b.traceRevertReasons())
+				failedTx.From = from.String()
+				failedTx.To = to
+				failedTx.Block = uint64(b.Number)
+				b.FailedTx[tx.Hash().String()] = failedTx
+
+				msg := fmt.Sprintf("failed 0-gas tx [%s](<%s>) from %s, %s\n", tx.Hash(), common.TxUrl(tx.Hash().Hex()), searcherLink(from.Hex()), failedTx.Summary())
+				b.addError(&CheckError{Check: CheckFailedTx, Kind: ErrorFailed0GasTx, Severity: SeveritySerious, BundleIndex: -1, TxHash: tx.Hash().Hex(), Message: msg})
 				b.ErrorCounter.Failed0GasTx += 1
 				b.HasFailed0GasTx = true
 				b.TriggerAlertOnFailedTx = true
@@ -510,71 +1028,25 @@ func (b *BlockCheck) checkBlockForFailedTx() (failedTransactions []FailedTx) {
 		}
 	}
 
+	if len(b.FailedTx) > 0 && TraceRpcClient != nil && b.ShedExpensiveSteps {
+		b.ShedSteps = append(b.ShedSteps, StepTraceRevertReason)
+	}
 	return failedTransactions
 }
 
+// CacheFlashbotsBlocks fetches the Flashbots blocks from startBlock to endBlock (inclusive) into FlashbotsBlockCache
 func CacheFlashbotsBlocks(startBlock int64, endBlock int64) error {
-	numBlocks := endBlock - startBlock
-	limit1 := int64(10_000)
-	if numBlocks < 10_000 {
-		limit1 = numBlocks
-	}
-
-	// API call to flashbots
-	opts := api.GetBlocksOptions{
-		Before: endBlock + 1,
-		Limit:  limit1,
-	}
-
-	flashbotsResponse, err := api.GetBlocks(&opts)
-	if err != nil {
-		return err
-	}
-
-	// Return an error if API doesn't have the block yet
-	if flashbotsResponse.LatestBlockNumber < endBlock {
-		return ErrFlashbotsApiDoesntHaveThatBlockYet
-	}
-
-	// Cache now
-	lowestBlock := endBlock
-	for _, block := range flashbotsResponse.Blocks {
-		FlashbotsBlockCache[block.BlockNumber] = block
-		if block.BlockNumber < lowestBlock {
-			lowestBlock = block.BlockNumber
-		}
-	}
-
-	// If necessary, fetch more
-	for {
-		// fmt.Println("check2. lowestCurrent:", lowestBlock, "start", startBlock)
-		if lowestBlock <= startBlock {
-			return nil
-		}
-
-		numBlocks = lowestBlock - startBlock
-		limit1 := int64(10_000)
-		if numBlocks < 10_000 {
-			limit1 = numBlocks
+	opts := api.GetBlocksOptions{Before: endBlock + 1}
+	it := api.DefaultClient.IterateBlocks(&opts, &api.PageOptions{StopBlock: startBlock})
+	for it.Next(context.Background()) {
+		// Return an error if API doesn't have the block yet
+		if it.LatestBlockNumber() < endBlock {
+			return ErrFlashbotsApiDoesntHaveThatBlockYet
 		}
 
-		opts := api.GetBlocksOptions{
-			Before: lowestBlock,
-			Limit:  limit1,
-		}
-
-		flashbotsResponse, err = api.GetBlocks(&opts)
-		if err != nil {
-			return err
-		}
-
-		// fmt.Println("cached before", len(FlashbotsBlockCache))
-		for _, block := range flashbotsResponse.Blocks {
+		for _, block := range it.Page() {
 			FlashbotsBlockCache[block.BlockNumber] = block
-			if block.BlockNumber < lowestBlock {
-				lowestBlock = block.BlockNumber
-			}
 		}
-		// fmt.Println("cached after", len(FlashbotsBlockCache))
 	}
+	return it.Err()
 }
@@ -0,0 +1,291 @@
+package blockcheck
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/common"
+)
+
+func newTestBundle(bundleType string, index int64, txIndexes ...int64) *common.Bundle {
+	bundle := common.NewBundle()
+	bundle.Index = index
+	bundle.BundleType = bundleType
+	for _, txIndex := range txIndexes {
+		bundle.Transactions = append(bundle.Transactions, api.FlashbotsTransaction{TxIndex: txIndex, BundleType: bundleType, BundleIndex: index})
+	}
+	return bundle
+}
+
+func TestCheckMegabundleOrder(t *testing.T) {
+	// Correct: megabundle first, then regular bundles
+	check := BlockCheck{}
+	check.AddBundle(newTestBundle(api.BundleTypeFlashbots, 0, 3, 4))
+	check.AddBundle(newTestBundle(api.BundleTypeMegabundle, 0, 0, 1, 2))
+	check.checkMegabundleOrder()
+	if check.HasErrors() {
+		t.Error("Unexpected errors:", check.Errors)
+	}
+	if !check.Bundles[0].IsMegabundle() {
+		t.Error("Megabundle should be sorted first on equal index")
+	}
+
+	// Regular bundle before the megabundle
+	check = BlockCheck{}
+	check.AddBundle(newTestBundle(api.BundleTypeFlashbots, 0, 0, 1))
+	check.AddBundle(newTestBundle(api.BundleTypeMegabundle, 0, 2, 3))
+	check.checkMegabundleOrder()
+	if check.ErrorCounter.MegabundleNotFirst != 1 {
+		t.Error("Expected MegabundleNotFirst error, got:", check.Errors)
+	}
+	if err := check.CheckErrors[0]; err.Kind != ErrorMegabundleNotFirst || err.Check != CheckBundleOrder || err.Severity != SeveritySerious || err.BundleIndex != 0 {
+		t.Errorf("Unexpected typed error: %+v", err)
+	}
+
+	// Megabundle with a gap
+	check = BlockCheck{}
+	check.AddBundle(newTestBundle(api.BundleTypeMegabundle, 0, 0, 2))
+	check.checkMegabundleOrder()
+	if check.ErrorCounter.MegabundleNotContiguous != 1 {
+		t.Error("Expected MegabundleNotContiguous error, got:", check.Errors)
+	}
+}
+
+func TestFindBundle(t *testing.T) {
+	// A megabundle and a regular bundle with the same index
+	check := BlockCheck{}
+	regular := newTestBundle(api.BundleTypeFlashbots, 0, 3, 4)
+	regular.Transactions[1].Hash = "0x04"
+	mega := newTestBundle(api.BundleTypeMegabundle, 0, 0, 1, 2)
+	mega.Transactions[0].Hash = "0x00"
+	check.AddBundle(regular)
+	check.AddBundle(mega)
+
+	if bundle := check.GetBundle(0); bundle != regular {
+		t.Error("Expected the regular bundle, got:", bundle)
+	}
+	if bundle := check.FindBundle(0, true); bundle != mega {
+		t.Error("Expected the megabundle, got:", bundle)
+	}
+	if bundle := check.GetBundleOfTx("0x04"); bundle != regular {
+		t.Error("Expected the regular bundle of the tx, got:", bundle)
+	}
+	if bundle := check.GetBundleOfTx("0x00"); bundle != mega {
+		t.Error("Expected the megabundle of the tx, got:", bundle)
+	}
+	if bundle := check.GetBundleOfTx("0x05"); bundle != nil {
+		t.Error("Expected no bundle, got:", bundle)
+	}
+}
+
+func TestCheckBundlesAtTop(t *testing.T) {
+	// Bundles at tx 0-2, a non-Flashbots tx at 3, then a bundle mid-block at 4
+	check := BlockCheck{}
+	check.AddBundle(newTestBundle(api.BundleTypeFlashbots, 0, 0, 1))
+	check.AddBundle(newTestBundle(api.BundleTypeFlashbots, 1, 2))
+	check.AddBundle(newTestBundle(api.BundleTypeFlashbots, 2, 4))
+	check.checkBundlesAtTop()
+	if check.ErrorCounter.BundleNotAtTop != 1 || len(check.Errors) != 1 {
+		t.Fatal("Expected 1 BundleNotAtTop error, got:", check.Errors)
+	}
+	if err := check.CheckErrors[0]; err.Kind != ErrorBundleNotAtTop || err.BundleIndex != 2 || !strings.Contains(err.Message, "at tx 4 is placed mid-block, after non-Flashbots tx 3") {
+		t.Errorf("Unexpected error: %+v", err)
+	}
+	if !check.Bundles[2].IsOutOfOrder || !check.HasSeriousErrors() {
+		t.Error("Expected a serious error for the bundle")
+	}
+
+	if s := txIndexesString([]int64{1, 2, 3, 4, 5, 6, 7}, 5); s != "tx 1, 2, 3, 4, 5 and 2 more" {
+		t.Error("Unexpected tx indexes:", s)
+	}
+}
+
+func TestCheckBundleOrderTies(t *testing.T) {
+	newBundle := func(index int64, reward int64, txIndex int64) *common.Bundle {
+		bundle := newTestBundle(api.BundleTypeFlashbots, index, txIndex)
+		bundle.CoinbaseDivGasUsed = big.NewInt(reward)
+		bundle.RewardDivGasUsed = big.NewInt(reward)
+		return bundle
+	}
+
+	// Equal effective gas prices (also 0) are no error, a higher one after them is
+	check := BlockCheck{}
+	check.AddBundle(newBundle(0, 0, 0))
+	check.AddBundle(newBundle(1, 0, 1))
+	check.AddBundle(newBundle(2, 10, 2))
+	check.AddBundle(newBundle(3, 10, 3))
+	check.AddBundle(newBundle(4, 20, 4))
+	check.checkBundleOrder()
+	errors := check.ErrorsOfKind(ErrorBundlePaysMore)
+	if len(errors) != 2 || errors[0].BundleIndex != 2 || errors[1].BundleIndex != 4 {
+		t.Fatal("Unexpected errors:", check.Errors)
+	}
+	if !strings.Contains(errors[1].Message, "at tx 4 pays 100.00% more than previous bundle 3 at tx 3") {
+		t.Error("Unexpected message:", errors[1].Message)
+	}
+}
+
+func TestGroupBundles(t *testing.T) {
+	check := BlockCheck{}
+	check.AddBundle(newTestBundle(api.BundleTypeMegabundle, 0, 0, 1))
+	check.AddBundle(newTestBundle(api.BundleTypeMegabundle, 1, 2))
+	check.AddBundle(newTestBundle(api.BundleTypeFlashbots, 0, 3, 4))
+	check.AddBundle(newTestBundle(api.BundleTypeFlashbots, 1, 5))
+	check.AddBundle(newTestBundle(api.BundleTypeFlashbots, 2, 8))
+
+	groups := GroupBundles(check.Bundles)
+	if len(groups) != 3 {
+		t.Fatal("Unexpected number of groups:", len(groups))
+	}
+	if groups[0].Type != BundleGroupMegabundle || len(groups[0].Bundles) != 2 || groups[0].NumTx() != 3 {
+		t.Error("Unexpected megabundle group:", groups[0].Type, len(groups[0].Bundles))
+	}
+	if groups[1].Type != BundleGroupMerged || len(groups[1].Bundles) != 2 || groups[2].Type != BundleGroupSingle {
+		t.Error("Unexpected regular groups:", groups[1].Type, groups[2].Type)
+	}
+
+	// A megabundle with several bundle indexes is checked as one unit
+	check.checkMegabundleOrder()
+	if check.HasErrors() {
+		t.Error("Unexpected errors:", check.Errors)
+	}
+}
+
+func TestPercentSeverity(t *testing.T) {
+	if s := percentSeverity(60, 50, 25); s != SeveritySerious {
+		t.Error("Expected serious, got", s)
+	}
+	if s := percentSeverity(30, 50, 25); s != SeverityLessSerious {
+		t.Error("Expected less serious, got", s)
+	}
+	if s := percentSeverity(10, 50, 25); s != "" {
+		t.Error("Expected no severity, got", s)
+	}
+}
+
+func TestReorderedTxIndexes(t *testing.T) {
+	bundle0 := newTestBundle(api.BundleTypeFlashbots, 0, 2, 3)
+	bundle0.RewardDivGasUsed = big.NewInt(10)
+	bundle1 := newTestBundle(api.BundleTypeFlashbots, 1, 4)
+	bundle1.RewardDivGasUsed = big.NewInt(20)
+	megabundle := newTestBundle(api.BundleTypeMegabundle, 0, 0, 1)
+	megabundle.RewardDivGasUsed = big.NewInt(5)
+
+	check := BlockCheck{}
+	check.AddBundle(bundle0)
+	check.AddBundle(bundle1)
+	check.AddBundle(megabundle)
+
+	expected := []int{0, 1, 4, 2, 3, 5, 6}
+	indexes := reorderedTxIndexes(check.Bundles, 7)
+	if fmt.Sprint(indexes) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, indexes)
+	}
+}
+
+func TestSimulationVerdict(t *testing.T) {
+	tests := []struct {
+		onChainReverts, simulatedReverts int
+		onChainReward, simulatedReward   int64
+		expected                         string
+	}{
+		{0, 0, 100, 105, SimulationMatches},
+		{1, 1, 100, 100, SimulationSearcherError},
+		{1, 0, 10, 100, SimulationMinerError},
+		{0, 0, 100, 150, SimulationMinerError},
+		{0, 1, 100, 100, SimulationMismatch},
+		{0, 0, 100, 50, SimulationMismatch},
+		{0, 0, 0, 1, SimulationMinerError},
+	}
+	for _, test := range tests {
+		verdict := simulationVerdict(test.onChainReverts, test.simulatedReverts, big.NewInt(test.onChainReward), big.NewInt(test.simulatedReward))
+		if verdict != test.expected {
+			t.Errorf("%+v: expected %s, got %s", test, test.expected, verdict)
+		}
+	}
+}
+
+func TestGasPriceDistribution(t *testing.T) {
+	prices := []*big.Int{}
+	for _, p := range []int64{5, 1, 4, 2, 3} {
+		prices = append(prices, big.NewInt(p))
+	}
+
+	d := NewGasPriceDistribution(prices)
+	if d.Min.Int64() != 1 || d.P25.Int64() != 2 || d.Median.Int64() != 3 || d.P75.Int64() != 4 || d.Max.Int64() != 5 {
+		t.Errorf("Unexpected distribution: %+v", d)
+	}
+	if p := d.Percentile(big.NewInt(1)); p != 0 {
+		t.Error("Expected percentile 0, got", p)
+	}
+	if p := d.Percentile(big.NewInt(3)); p != 40 {
+		t.Error("Expected percentile 40, got", p)
+	}
+	if NewGasPriceDistribution(nil) != nil {
+		t.Error("Expected nil for a block without tx")
+	}
+}
+
+func TestGasPriceDistributionWithoutFlashbotsTx(t *testing.T) {
+	to := ethcommon.HexToAddress("0x0000000000000000000000000000000000000001")
+	gwei := func(n int64) *big.Int { return new(big.Int).Mul(big.NewInt(n), big.NewInt(1e9)) }
+	fbTx := types.NewTransaction(0, to, nil, 21000, gwei(1), nil)
+	txs := []*types.Transaction{
+		fbTx,
+		types.NewTransaction(1, to, nil, 100000, big.NewInt(0), []byte{1}), // flashbots-like: 0-gas contract call
+		types.NewTransaction(2, to, nil, 21000, gwei(2), nil),
+		types.NewTransaction(3, to, nil, 21000, gwei(3), nil),
+		types.NewTransaction(4, to, nil, 21000, gwei(4), nil),
+	}
+	check := &BlockCheck{
+		EthBlock:              types.NewBlockWithHeader(&types.Header{Number: big.NewInt(100)}).WithBody(txs, nil),
+		FlashbotsTransactions: []api.FlashbotsTransaction{{Hash: fbTx.Hash().String()}},
+	}
+
+	d := check.gasPriceDistribution()
+	if d.Min.Cmp(gwei(2)) != 0 || d.Max.Cmp(gwei(4)) != 0 {
+		t.Errorf("Expected the distribution of the non-Flashbots tx: %+v", d)
+	}
+	if p := d.Percentile(gwei(3)); int(p) != 33 {
+		t.Error("Expected percentile 33, got", p)
+	}
+}
+
+func TestReferenceGasPrice(t *testing.T) {
+	txs := []txGasPrice{}
+	for i, p := range []int64{1, 50, 40, 30, 20, 10, 60, 70, 80, 90} {
+		txs = append(txs, txGasPrice{hash: fmt.Sprint(i), gasPrice: big.NewInt(p)})
+	}
+	if tx, ok := gasPriceAtPercentile(txs, 0); !ok || tx.gasPrice.Int64() != 1 {
+		t.Error("Expected the lowest tx, got", tx)
+	}
+	if tx, _ := gasPriceAtPercentile(txs, 10); tx.gasPrice.Int64() != 1 {
+		t.Error("Expected the p10 of 10 tx to be the lowest, got", tx)
+	}
+	if tx, _ := gasPriceAtPercentile(txs, 50); tx.gasPrice.Int64() != 40 || tx.hash != "2" {
+		t.Error("Expected the median tx, got", tx)
+	}
+	if _, ok := gasPriceAtPercentile(nil, 5); ok {
+		t.Error("Expected no reference tx without tx")
+	}
+
+	from := ethcommon.HexToAddress("0x1")
+	to := ethcommon.HexToAddress("0x2")
+	dust := types.NewTransaction(0, to, big.NewInt(1e12), 21000, big.NewInt(1), nil)
+	transfer := types.NewTransaction(0, to, big.NewInt(1e17), 21000, big.NewInt(1), nil)
+	call := types.NewTransaction(0, to, big.NewInt(0), 100000, big.NewInt(1), []byte{1, 2, 3, 4})
+	self := types.NewTransaction(0, from, big.NewInt(0), 21000, big.NewInt(1), nil)
+	for _, test := range []struct {
+		tx       *types.Transaction
+		expected bool
+	}{{dust, true}, {transfer, false}, {call, false}, {self, true}} {
+		if isDustOrSelfTransfer(test.tx, from) != test.expected {
+			t.Errorf("Expected %t for tx to %s with value %s", test.expected, test.tx.To(), test.tx.Value())
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package blockcheck
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BlockSummary is a one-line summary of a checked block, to show the neighbouring blocks in alerts
+type BlockSummary struct {
+	Number     int64
+	Miner      string
+	MinerName  string
+	NumBundles int
+	ErrorTypes []string // sorted
+}
+
+func NewBlockSummary(check *BlockCheck) *BlockSummary {
+	errorTypes := check.ErrorCounter.Types()
+	sort.Strings(errorTypes)
+	return &BlockSummary{
+		Number:     check.Number,
+		Miner:      check.Miner,
+		MinerName:  check.MinerName,
+		NumBundles: len(check.Bundles),
+		ErrorTypes: errorTypes,
+	}
+}
+
+func (s *BlockSummary) String() string {
+	miner := s.Miner
+	if s.MinerName != "" {
+		miner = s.MinerName
+	}
+	errors := "no errors"
+	if len(s.ErrorTypes) > 0 {
+		errors = "errors: " + strings.Join(s.ErrorTypes, ", ")
+	}
+	return fmt.Sprintf("block %d, miner %s: %d bundles, %s", s.Number, miner, s.NumBundles, errors)
+}
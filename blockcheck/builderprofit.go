@@ -0,0 +1,83 @@
+package blockcheck
+
+import (
+	"fmt"
+	"math/big"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+// Blocks where the builder kept more than this share (%) of the block value are flagged
+var ThresholdBuilderKeptSharePercent float32 = 50
+
+// Blocks with a lower value (ETH) are not flagged, the share is too noisy for small blocks
+var ThresholdBuilderProfitMinBlockValue float64 = 0.05
+
+// lastTxProposerPayment returns the recipient and value of the last tx, if it's a plain transfer from the block's
+// coinbase (builder) to another address, which is how builders pay the proposer
+func (b *BlockCheck) lastTxProposerPayment() (feeRecipient ethcommon.Address, value *big.Int, found bool) {
+	txs := b.EthBlock.Transactions()
+	if len(txs) == 0 {
+		return feeRecipient, nil, false
+	}
+
+	lastTx := txs[len(txs)-1]
+	if lastTx.To() == nil || *lastTx.To() == b.EthBlock.Coinbase() || len(lastTx.Data()) > 0 || lastTx.Value().Sign() == 0 {
+		return feeRecipient, nil, false
+	}
+
+	sender, err := utils.GetTxSender(lastTx)
+	if err != nil || sender != b.EthBlock.Coinbase() {
+		return feeRecipient, nil, false
+	}
+	return *lastTx.To(), lastTx.Value(), true
+}
+
+// checkBuilderProfit computes the block value (priority fees and coinbase transfers, without the payment tx) and
+// compares it with the payment to the proposer (relay bid if known, else the last tx payment). Blocks built by the
+// proposer itself (no payment) are skipped.
+func (b *BlockCheck) checkBuilderProfit(transfers map[ethcommon.Hash]*big.Int) {
+	feeRecipient, payment, found := b.lastTxProposerPayment()
+	for _, relayBid := range b.RelayBids { // relay data takes precedence
+		feeRecipient = ethcommon.HexToAddress(relayBid.Bid.ProposerFeeRecipient)
+		payment = relayBid.ProposerPayment
+		found = feeRecipient != b.EthBlock.Coinbase()
+	}
+	if !found {
+		return
+	}
+
+	txs := b.EthBlock.Transactions()
+	blockValue := new(big.Int)
+	for i, tx := range txs {
+		if i == len(txs)-1 && tx.To() != nil && *tx.To() == feeRecipient { // payment tx
+			continue
+		}
+
+		receipt := b.BlockWithTxReceipts.TxReceipts[tx.Hash()]
+		if receipt == nil {
+			continue
+		}
+		gasFees := new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), common.TxPriorityFee(tx, b.EthBlock.BaseFee()))
+		blockValue.Add(blockValue, gasFees)
+		blockValue.Add(blockValue, b.txCoinbaseTransfer(tx, transfers))
+	}
+
+	b.BlockValue = blockValue
+	b.ProposerPaymentValue = payment
+	if blockValue.Sign() <= 0 {
+		return
+	}
+
+	builderProfit := new(big.Int).Sub(blockValue, payment)
+	share, _ := new(big.Float).Quo(new(big.Float).SetInt(builderProfit), new(big.Float).SetInt(blockValue)).Float32()
+	b.BuilderKeptSharePercent = share * 100
+
+	if b.BuilderKeptSharePercent > ThresholdBuilderKeptSharePercent && common.WeiToEthFloat64(blockValue) >= ThresholdBuilderProfitMinBlockValue {
+		msg := fmt.Sprintf("builder kept %.2f%% of the block value %s (proposer payment %s to [%s](<%s>))\n", b.BuilderKeptSharePercent, common.FormatEth(blockValue), common.FormatEth(payment), feeRecipient.Hex(), common.AddressUrl(feeRecipient.Hex()))
+		b.addError(&CheckError{Check: CheckBuilderProfit, Kind: ErrorBuilderKeptLargeShare, Severity: SeverityLessSerious, BundleIndex: -1, Value: float64(b.BuilderKeptSharePercent), Message: msg})
+		b.ErrorCounter.BuilderKeptLargeShare += 1
+	}
+}
@@ -0,0 +1,172 @@
+package blockcheck
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/metachris/flashbots/common"
+)
+
+// Kinds of BundleChange
+const (
+	BundleUnchanged  = "unchanged"
+	BundleMoved      = "moved"      // same transactions at another position
+	BundleRepriced   = "repriced"   // the miner reward per gas changed
+	BundleRecomposed = "recomposed" // some of the transactions are in both blocks
+	BundleVanished   = "vanished"   // only in block A
+	BundleAppeared   = "appeared"   // only in block B
+)
+
+// BundleChange is a bundle of block A and its counterpart in block B. A bundle can be moved and repriced at the same
+// time, Kinds lists all changes.
+type BundleChange struct {
+	Kinds []string
+	A     *common.Bundle // nil if the bundle appeared
+	B     *common.Bundle // nil if the bundle vanished
+}
+
+func (c *BundleChange) Is(kind string) bool {
+	for _, k := range c.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *BundleChange) String() string {
+	describe := func(b *common.Bundle) string {
+		if b == nil {
+			return "-"
+		}
+		minIndex, maxIndex := b.TxIndexRange()
+		return fmt.Sprintf("%s bundle %d (%.10s, tx %d-%d, %d tx, %s, %s/gas)", b.BundleType, b.Index, b.Hash, minIndex, maxIndex, len(b.Transactions), common.FormatEth(b.TotalMinerReward), common.FormatGwei(b.RewardDivGasUsed))
+	}
+	return fmt.Sprintf("%-10s %s -> %s", c.Kinds[0], describe(c.A), describe(c.B))
+}
+
+// BundleDiff compares the bundles of two blocks, eg. a canonical block and its uncle, or the blocks of a height
+// before and after a reorg
+type BundleDiff struct {
+	A       *BlockCheck
+	B       *BlockCheck
+	Changes []*BundleChange // bundles of A in block order, then the appeared bundles of B
+}
+
+// DiffBundles matches the bundles of the blocks by their hash (same transactions), else by shared transactions, and
+// classifies what changed
+func DiffBundles(a *BlockCheck, b *BlockCheck) *BundleDiff {
+	diff := &BundleDiff{A: a, B: b}
+	matched := make(map[*common.Bundle]bool)
+
+	bundleOfTx := make(map[string]*common.Bundle)
+	byHash := make(map[string]*common.Bundle)
+	for _, bundle := range b.Bundles {
+		byHash[bundle.Hash] = bundle
+		for _, tx := range bundle.Transactions {
+			bundleOfTx[tx.Hash] = bundle
+		}
+	}
+
+	for _, bundleA := range a.Bundles {
+		change := &BundleChange{A: bundleA}
+		if bundleB, found := byHash[bundleA.Hash]; found && !matched[bundleB] {
+			change.B = bundleB
+		} else {
+			for _, tx := range bundleA.Transactions {
+				if bundleB, found := bundleOfTx[tx.Hash]; found && !matched[bundleB] {
+					change.B = bundleB
+					change.Kinds = append(change.Kinds, BundleRecomposed)
+					break
+				}
+			}
+		}
+
+		if change.B == nil {
+			change.Kinds = append(change.Kinds, BundleVanished)
+		} else {
+			matched[change.B] = true
+			minA, _ := bundleA.TxIndexRange()
+			minB, _ := change.B.TxIndexRange()
+			if minA != minB || bundleA.Index != change.B.Index {
+				change.Kinds = append(change.Kinds, BundleMoved)
+			}
+			if bundleA.RewardDivGasUsed.Cmp(change.B.RewardDivGasUsed) != 0 {
+				change.Kinds = append(change.Kinds, BundleRepriced)
+			}
+			if len(change.Kinds) == 0 {
+				change.Kinds = append(change.Kinds, BundleUnchanged)
+			}
+		}
+		diff.Changes = append(diff.Changes, change)
+	}
+
+	for _, bundleB := range b.Bundles {
+		if !matched[bundleB] {
+			diff.Changes = append(diff.Changes, &BundleChange{Kinds: []string{BundleAppeared}, B: bundleB})
+		}
+	}
+	return diff
+}
+
+// Counts returns the number of bundles by kind of change (a bundle is counted for each of its kinds)
+func (d *BundleDiff) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, change := range d.Changes {
+		for _, kind := range change.Kinds {
+			counts[kind] += 1
+		}
+	}
+	return counts
+}
+
+// HasChanges returns true if any bundle isn't unchanged
+func (d *BundleDiff) HasChanges() bool {
+	for _, change := range d.Changes {
+		if !change.Is(BundleUnchanged) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sprint returns the readable diff: both blocks, the summary, and one line per bundle (unchanged bundles only if
+// verbose)
+func (d *BundleDiff) Sprint(verbose bool) string {
+	block := func(label string, check *BlockCheck) string {
+		return fmt.Sprintf("%s: block %d %s, miner %s, %d bundles\n", label, check.Number, check.EthBlock.Hash().Hex(), check.MinerName, len(check.Bundles))
+	}
+	ret := block("A", d.A) + block("B", d.B)
+
+	counts := d.Counts()
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return bundleChangeOrder(kinds[i]) < bundleChangeOrder(kinds[j]) })
+	for _, kind := range kinds {
+		ret += fmt.Sprintf("%s: %d  ", kind, counts[kind])
+	}
+	ret += "\n"
+
+	for _, change := range d.Changes {
+		if verbose || !change.Is(BundleUnchanged) {
+			ret += fmt.Sprintf("- %s", change)
+			if len(change.Kinds) > 1 {
+				ret += fmt.Sprintf(" %v", change.Kinds[1:])
+			}
+			ret += "\n"
+		}
+	}
+	return ret
+}
+
+// bundleChangeOrder is the position of the kind in the summary
+func bundleChangeOrder(kind string) int {
+	for i, k := range []string{BundleUnchanged, BundleMoved, BundleRepriced, BundleRecomposed, BundleVanished, BundleAppeared} {
+		if k == kind {
+			return i
+		}
+	}
+	return -1
+}
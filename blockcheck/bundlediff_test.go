@@ -0,0 +1,65 @@
+package blockcheck
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/metachris/flashbots/api"
+)
+
+// newDiffCheck returns a check with one bundle per list of tx hashes, at consecutive tx indexes, all paying 1 gwei/gas
+func newDiffCheck(bundles ...[]string) *BlockCheck {
+	check := &BlockCheck{}
+	txIndex := int64(0)
+	for i, hashes := range bundles {
+		bundle := newTestBundle(api.BundleTypeFlashbots, int64(i))
+		for _, hash := range hashes {
+			bundle.Transactions = append(bundle.Transactions, api.FlashbotsTransaction{Hash: hash, TxIndex: txIndex, BundleIndex: int64(i)})
+			txIndex += 1
+		}
+		bundle.RewardDivGasUsed = big.NewInt(1e9)
+		bundle.UpdateHash()
+		check.AddBundle(bundle)
+	}
+	return check
+}
+
+func TestDiffBundles(t *testing.T) {
+	a := newDiffCheck([]string{"0x01", "0x02"}, []string{"0x03"}, []string{"0x04", "0x05"}, []string{"0x06"})
+	b := newDiffCheck([]string{"0x03"}, []string{"0x01", "0x02"}, []string{"0x04", "0x07"}, []string{"0x06"}, []string{"0x08"})
+	b.Bundles[3].RewardDivGasUsed = big.NewInt(2e9)
+
+	diff := DiffBundles(a, b)
+	if len(diff.Changes) != 5 || !diff.HasChanges() {
+		t.Fatal("Unexpected changes:", diff.Changes)
+	}
+	expected := [][]string{
+		{BundleMoved},
+		{BundleMoved},
+		{BundleRecomposed},
+		{BundleRepriced},
+		{BundleAppeared},
+	}
+	for i, kinds := range expected {
+		change := diff.Changes[i]
+		if len(change.Kinds) != len(kinds) {
+			t.Errorf("Change %d: expected %v, got %v", i, kinds, change.Kinds)
+			continue
+		}
+		for _, kind := range kinds {
+			if !change.Is(kind) {
+				t.Errorf("Change %d: expected %v, got %v", i, kinds, change.Kinds)
+			}
+		}
+	}
+	if diff.Changes[0].B != b.Bundles[1] || diff.Changes[4].A != nil {
+		t.Error("Wrong matches:", diff.Changes)
+	}
+
+	// The same bundles at the same position are unchanged, a missing bundle vanished
+	b = newDiffCheck([]string{"0x01", "0x02"}, []string{"0x03"})
+	diff = DiffBundles(a, b)
+	if counts := diff.Counts(); counts[BundleUnchanged] != 2 || counts[BundleVanished] != 2 {
+		t.Error("Unexpected counts:", counts)
+	}
+}
@@ -0,0 +1,136 @@
+package blockcheck
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/metachris/flashbots/common"
+)
+
+// Types of bundle groups
+const (
+	BundleGroupSingle     = "single"
+	BundleGroupMerged     = "merged"     // regular bundles placed directly after each other (merged by the miner)
+	BundleGroupMegabundle = "megabundle" // all megabundle transactions, possibly with several bundle indexes
+)
+
+// BundleGroup is a set of bundles which the miner placed as one unit
+type BundleGroup struct {
+	Index   int
+	Type    string
+	Bundles []*common.Bundle // in block order
+}
+
+// TxIndexRange returns the lowest and highest tx index of all bundles in the group
+func (g *BundleGroup) TxIndexRange() (min int64, max int64) {
+	for i, bundle := range g.Bundles {
+		bundleMin, bundleMax := bundle.TxIndexRange()
+		if i == 0 || bundleMin < min {
+			min = bundleMin
+		}
+		if i == 0 || bundleMax > max {
+			max = bundleMax
+		}
+	}
+	return min, max
+}
+
+func (g *BundleGroup) NumTx() (n int64) {
+	for _, bundle := range g.Bundles {
+		n += int64(len(bundle.Transactions))
+	}
+	return n
+}
+
+// Name returns the bundle index and hash for a single bundle (eg. "0 (0x12345678)"), else the bundle indexes
+func (g *BundleGroup) Name() string {
+	if len(g.Bundles) == 1 {
+		return fmt.Sprintf("%d (%s)", g.Bundles[0].Index, g.Bundles[0].ShortHash())
+	}
+
+	indexes := make([]string, len(g.Bundles))
+	for i, bundle := range g.Bundles {
+		indexes[i] = fmt.Sprint(bundle.Index)
+	}
+	return "bundles " + strings.Join(indexes, ", ")
+}
+
+// Combined returns the bundle of a single-bundle group, else a bundle with the transactions and totals of all
+// bundles, which is evaluated as a whole (eg. a megabundle which pays the miner only in its last sub-bundle)
+func (g *BundleGroup) Combined() *common.Bundle {
+	if len(g.Bundles) == 1 {
+		return g.Bundles[0]
+	}
+
+	combined := common.NewBundle()
+	combined.Index = g.Bundles[0].Index
+	combined.BundleType = g.Bundles[0].BundleType
+	for _, bundle := range g.Bundles {
+		combined.Transactions = append(combined.Transactions, bundle.Transactions...)
+		combined.TotalMinerReward.Add(combined.TotalMinerReward, bundle.TotalMinerReward)
+		combined.TotalCoinbaseTransfer.Add(combined.TotalCoinbaseTransfer, bundle.TotalCoinbaseTransfer)
+		combined.TotalGasUsed.Add(combined.TotalGasUsed, bundle.TotalGasUsed)
+	}
+	if combined.TotalGasUsed.Sign() > 0 {
+		combined.CoinbaseDivGasUsed = new(big.Int).Div(combined.TotalCoinbaseTransfer, combined.TotalGasUsed)
+		combined.RewardDivGasUsed = new(big.Int).Div(combined.TotalMinerReward, combined.TotalGasUsed)
+	}
+	combined.UpdateHash()
+	return combined
+}
+
+// GroupBundles groups the bundles of the block: all megabundle transactions form one group, and regular bundles
+// without other transactions between them are merged into one group. Sets the GroupIndex of the bundles.
+func GroupBundles(bundles []*common.Bundle) (groups []*BundleGroup) {
+	sorted := make([]*common.Bundle, len(bundles))
+	copy(sorted, bundles)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		minI, _ := sorted[i].TxIndexRange()
+		minJ, _ := sorted[j].TxIndexRange()
+		return minI < minJ
+	})
+
+	var megabundle *BundleGroup
+	var current *BundleGroup
+	lastTxIndex := int64(-2)
+	for _, bundle := range sorted {
+		if bundle.IsMegabundle() {
+			if megabundle == nil {
+				megabundle = &BundleGroup{Type: BundleGroupMegabundle}
+				groups = append(groups, megabundle)
+			}
+			megabundle.Bundles = append(megabundle.Bundles, bundle)
+			continue
+		}
+
+		min, max := bundle.TxIndexRange()
+		if current != nil && min == lastTxIndex+1 {
+			current.Type = BundleGroupMerged
+			current.Bundles = append(current.Bundles, bundle)
+		} else {
+			current = &BundleGroup{Type: BundleGroupSingle, Bundles: []*common.Bundle{bundle}}
+			groups = append(groups, current)
+		}
+		lastTxIndex = max
+	}
+
+	for i, group := range groups {
+		group.Index = i
+		for _, bundle := range group.Bundles {
+			bundle.GroupIndex = i
+		}
+	}
+	return groups
+}
+
+// megabundleGroup returns the group of all megabundle transactions, or nil if there are none
+func megabundleGroup(bundles []*common.Bundle) *BundleGroup {
+	for _, group := range GroupBundles(bundles) {
+		if group.Type == BundleGroupMegabundle {
+			return group
+		}
+	}
+	return nil
+}
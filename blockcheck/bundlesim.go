@@ -0,0 +1,145 @@
+package blockcheck
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/common"
+)
+
+// Name of the step which re-simulates the bundles with errors (see VerifyBundles)
+const StepVerifyBundles = "verify-bundles"
+
+// If true (and SimulationRpc is set), the bundles with errors are re-simulated on top of the parent block (eth_callBundle
+// of a relay or an archive mev-geth node), and the simulation is compared with the on-chain outcome
+var VerifyBundles bool
+
+// Max. number of bundles simulated per block
+var MaxBundleSimulations = 5
+
+// Simulated and on-chain miner rewards within this difference (percent) match
+var ThresholdSimulationRewardDiffPercent = 10.0
+
+// Verdicts of a BundleSimulation
+const (
+	SimulationMatches       = "matches"  // same reverts and reward: the bundle behaved as the searcher simulated it
+	SimulationSearcherError = "searcher" // reverts in the simulation too: the bundle was broken at the top of the block
+	SimulationMinerError    = "miner"    // reverted or paid less on-chain than simulated: caused by its position in the block
+	SimulationMismatch      = "mismatch" // differs otherwise (eg. reverts only in the simulation)
+)
+
+// BundleSimulation compares the on-chain outcome of a bundle with its simulation on top of the parent block
+type BundleSimulation struct {
+	BundleIndex      int64
+	Verdict          string
+	OnChainReverts   []string // tx hashes
+	SimulatedReverts []string
+	OnChainReward    *big.Int
+	SimulatedReward  *big.Int
+}
+
+func (s *BundleSimulation) String() string {
+	return fmt.Sprintf("bundle %d simulation: %s (reverts on-chain %d, simulated %d; miner reward on-chain %s, simulated %s)", s.BundleIndex, s.Verdict, len(s.OnChainReverts), len(s.SimulatedReverts), common.FormatEth(s.OnChainReward), common.FormatEth(s.SimulatedReward))
+}
+
+// simulationVerdict classifies the difference between the on-chain outcome and the simulation
+func simulationVerdict(onChainReverts int, simulatedReverts int, onChainReward *big.Int, simulatedReward *big.Int) string {
+	switch {
+	case simulatedReverts > 0 && onChainReverts > 0:
+		return SimulationSearcherError
+	case simulatedReverts > 0:
+		return SimulationMismatch
+	case onChainReverts > 0:
+		return SimulationMinerError
+	}
+
+	diff := new(big.Int).Sub(simulatedReward, onChainReward)
+	if onChainReward.Sign() != 0 {
+		percent, _ := new(big.Float).Quo(new(big.Float).SetInt(diff), new(big.Float).SetInt(onChainReward)).Float64()
+		percent *= 100
+		if percent > ThresholdSimulationRewardDiffPercent {
+			return SimulationMinerError
+		} else if percent < -ThresholdSimulationRewardDiffPercent {
+			return SimulationMismatch
+		}
+	} else if diff.Sign() > 0 {
+		return SimulationMinerError
+	} else if diff.Sign() < 0 {
+		return SimulationMismatch
+	}
+	return SimulationMatches
+}
+
+// bundleTransactions returns the transactions of the bundle, in block order
+func (b *BlockCheck) bundleTransactions(bundle *common.Bundle) (txs types.Transactions) {
+	blockTxs := b.EthBlock.Transactions()
+	indexes := make([]int, 0, len(bundle.Transactions))
+	for _, tx := range bundle.Transactions {
+		if tx.TxIndex >= 0 && int(tx.TxIndex) < len(blockTxs) {
+			indexes = append(indexes, int(tx.TxIndex))
+		}
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		txs = append(txs, blockTxs[i])
+	}
+	return txs
+}
+
+// simulateBundle simulates the bundle alone on top of the parent block, and compares it with the on-chain outcome
+func (b *BlockCheck) simulateBundle(bundle *common.Bundle) (*BundleSimulation, error) {
+	txs := b.bundleTransactions(bundle)
+	block := types.NewBlockWithHeader(b.EthBlock.Header()).WithBody(txs, nil)
+
+	result, err := simulateBlock(block)
+	if err != nil {
+		return nil, err
+	}
+	simulatedReward, ok := new(big.Int).SetString(result.CoinbaseDiff, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid coinbaseDiff '%s'", result.CoinbaseDiff)
+	}
+
+	sim := &BundleSimulation{BundleIndex: bundle.Index, OnChainReward: bundle.TotalMinerReward, SimulatedReward: simulatedReward}
+	for _, tx := range txs {
+		if receipt := b.BlockWithTxReceipts.TxReceipts[tx.Hash()]; receipt != nil && receipt.Status == types.ReceiptStatusFailed {
+			sim.OnChainReverts = append(sim.OnChainReverts, tx.Hash().String())
+		}
+	}
+	for _, txResult := range result.Results {
+		if txResult.Error != "" || txResult.Revert != "" {
+			sim.SimulatedReverts = append(sim.SimulatedReverts, txResult.TxHash)
+		}
+	}
+	sim.Verdict = simulationVerdict(len(sim.OnChainReverts), len(sim.SimulatedReverts), sim.OnChainReward, sim.SimulatedReward)
+	return sim, nil
+}
+
+// verifyBundles simulates the bundles with errors (up to MaxBundleSimulations) and sets BundleSimulations. Simulation
+// errors are only logged, the verification is optional.
+func (b *BlockCheck) verifyBundles() error {
+	simulated := make(map[int64]bool)
+	for _, checkErr := range b.CheckErrors {
+		if checkErr.BundleIndex < 0 || simulated[checkErr.BundleIndex] || len(simulated) >= MaxBundleSimulations {
+			continue
+		}
+		simulated[checkErr.BundleIndex] = true
+
+		for _, bundle := range b.Bundles {
+			if bundle.Index != checkErr.BundleIndex {
+				continue
+			}
+
+			sim, err := b.simulateBundle(bundle)
+			if err != nil {
+				log.Warn("bundle simulation failed", "block", b.Number, "bundle", bundle.Index, "err", err)
+				break
+			}
+			log.Debug("bundle simulated", "block", b.Number, "bundle", bundle.Index, "verdict", sim.Verdict)
+			b.BundleSimulations = append(b.BundleSimulations, sim)
+		}
+	}
+	return nil
+}
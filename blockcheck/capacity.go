@@ -0,0 +1,113 @@
+package blockcheck
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Blocks using at least this share of the gas limit are counted as full
+var FullBlockGasLimitShare = 0.95
+
+// CapacityStats collects gas-limit pressure statistics per day (UTC): how often blocks are full, and how much of it
+// is used by bundles. The mempool demand isn't measured, only the pending pool size is sampled in watch mode.
+type CapacityStats struct {
+	Days map[string]*DailyCapacityStats // key: yyyy-mm-dd
+}
+
+type DailyCapacityStats struct {
+	Date string
+
+	NumBlocks                uint64
+	NumFullBlocks            uint64
+	NumFullBlocksWithBundles uint64
+
+	GasUsed       uint64
+	GasLimit      uint64
+	BundleGasUsed uint64
+	DisplacedGas  uint64 // gas used by bundles in full blocks: an upper bound of the gas mempool tx could have used instead
+
+	// Pending pool size samples (only available in watch mode)
+	NumPendingTxSamples uint64
+	SumPendingTx        uint64
+}
+
+func NewCapacityStats() CapacityStats {
+	return CapacityStats{
+		Days: make(map[string]*DailyCapacityStats),
+	}
+}
+
+func (cs *CapacityStats) getDay(t time.Time) *DailyCapacityStats {
+	date := t.UTC().Format("2006-01-02")
+	day, found := cs.Days[date]
+	if !found {
+		day = &DailyCapacityStats{Date: date}
+		cs.Days[date] = day
+	}
+	return day
+}
+
+// AddCheck adds the gas usage of a checked block
+func (cs *CapacityStats) AddCheck(check *BlockCheck) {
+	block := check.EthBlock
+	day := cs.getDay(time.Unix(int64(block.Time()), 0))
+
+	var bundleGasUsed uint64
+	for _, bundle := range check.Bundles {
+		bundleGasUsed += bundle.TotalGasUsed.Uint64()
+	}
+
+	day.NumBlocks += 1
+	day.GasUsed += block.GasUsed()
+	day.GasLimit += block.GasLimit()
+	day.BundleGasUsed += bundleGasUsed
+
+	isFull := float64(block.GasUsed()) >= float64(block.GasLimit())*FullBlockGasLimitShare
+	if isFull {
+		day.NumFullBlocks += 1
+		if bundleGasUsed > 0 {
+			day.NumFullBlocksWithBundles += 1
+			day.DisplacedGas += bundleGasUsed
+		}
+	}
+}
+
+// AddPendingTxCount adds a sample of the node's pending transaction pool size
+func (cs *CapacityStats) AddPendingTxCount(t time.Time, count uint) {
+	day := cs.getDay(t)
+	day.NumPendingTxSamples += 1
+	day.SumPendingTx += uint64(count)
+}
+
+func (cs *CapacityStats) Reset() {
+	cs.Days = make(map[string]*DailyCapacityStats)
+}
+
+func (cs *CapacityStats) String() (ret string) {
+	dates := make([]string, 0, len(cs.Days))
+	for date := range cs.Days {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	for _, date := range dates {
+		ret += cs.Days[date].String() + "\n"
+	}
+	return ret
+}
+
+func (d *DailyCapacityStats) String() string {
+	percent := func(a uint64, b uint64) float64 {
+		if b == 0 {
+			return 0
+		}
+		return float64(a) / float64(b) * 100
+	}
+
+	msg := fmt.Sprintf("%s blocks=%d \t full=%d (%.2f%%) \t fullWithBundles=%d (%.2f%%) \t gasUsed=%.2f%% \t bundleGas=%.2f%% \t displacedGas=%d", d.Date, d.NumBlocks, d.NumFullBlocks, percent(d.NumFullBlocks, d.NumBlocks), d.NumFullBlocksWithBundles, percent(d.NumFullBlocksWithBundles, d.NumBlocks), percent(d.GasUsed, d.GasLimit), percent(d.BundleGasUsed, d.GasUsed), d.DisplacedGas)
+	if d.NumPendingTxSamples > 0 {
+		msg += fmt.Sprintf(" \t avgPendingTx=%d", d.SumPendingTx/d.NumPendingTxSamples)
+	}
+	return msg
+}
@@ -0,0 +1,85 @@
+package blockcheck
+
+import "strings"
+
+// Kinds of CheckError, the same names as the ErrorCounts types
+const (
+	ErrorFailedFlashbotsTx          = "failedFbTx"
+	ErrorFailed0GasTx               = "failed0gas"
+	ErrorBundlePaysMore             = "bundlePaysMore"
+	ErrorBundleTooLowFee            = "bundleTooLowFee"
+	ErrorBundleTooLowPriorityFee    = "bundleTooLowPriorityFee"
+	ErrorBundleHas0Fee              = "has0fee"
+	ErrorBundleHasNegativeFee       = "hasNegativeFee"
+	ErrorCoinbaseTransferMismatch   = "coinbaseTransferMismatch"
+	ErrorMegabundleNotFirst         = "megabundleNotFirst"
+	ErrorMegabundleNotContiguous    = "megabundleNotContiguous"
+	ErrorBundleNotAtTop             = "bundleNotAtTop"
+	ErrorRelayPaymentMismatch       = "relayPaymentMismatch"
+	ErrorProposerPaymentMissed      = "proposerPaymentMissed"
+	ErrorProposerPaymentMisdirected = "proposerPaymentMisdirected"
+	ErrorBuilderKeptLargeShare      = "builderKeptLargeShare"
+	ErrorMissingBundle              = "missingBundle" // not counted in ErrorCounts
+)
+
+// CheckError is an error found by one of the checks. Message is the text of the alerts (with markdown links), the
+// other fields allow handling specific errors without parsing it.
+type CheckError struct {
+	Check       string  // name of the check, see AllChecks
+	Kind        string  // see the Error* constants
+	Severity    string  // SeveritySerious or SeverityLessSerious, empty if below the less-serious thresholds
+	BundleIndex int64   // -1 if the error isn't about a single bundle
+	TxHash      string  // the failed or mismatching tx, if any
+	Value       float64 // numeric detail: percent difference for price errors, kept share for builderKeptLargeShare
+	Percentile  float64 // bundleTooLowFee, bundleTooLowPriorityFee: percentage of the block's tx paying less than the bundle
+	Message     string
+}
+
+func (e *CheckError) String() string {
+	return e.Message
+}
+
+// Text is the message without the trailing newline
+func (e *CheckError) Text() string {
+	return strings.TrimSpace(e.Message)
+}
+
+// percentSeverity classifies a percent difference with the serious and less-serious thresholds
+func percentSeverity(percent float32, seriousThreshold float32, lessSeriousThreshold float32) string {
+	if percent >= seriousThreshold {
+		return SeveritySerious
+	} else if percent >= lessSeriousThreshold {
+		return SeverityLessSerious
+	}
+	return ""
+}
+
+// addError adds an error found by a check, to CheckErrors and its message to Errors
+func (b *BlockCheck) addError(err *CheckError) {
+	b.CheckErrors = append(b.CheckErrors, err)
+	b.Errors = append(b.Errors, err.Message)
+}
+
+// AddError adds an error with only a message (kind and severity unknown)
+func (b *BlockCheck) AddError(msg string) {
+	b.addError(&CheckError{BundleIndex: -1, Message: msg})
+}
+
+// ErrorsOfKind returns the errors of this kind (see the Error* constants)
+func (b *BlockCheck) ErrorsOfKind(kind string) (errors []*CheckError) {
+	for _, err := range b.CheckErrors {
+		if err.Kind == kind {
+			errors = append(errors, err)
+		}
+	}
+	return errors
+}
+
+// ErrorMessages returns the messages of all errors, without trailing newlines
+func (b *BlockCheck) ErrorMessages() []string {
+	messages := make([]string, 0, len(b.CheckErrors))
+	for _, err := range b.CheckErrors {
+		messages = append(messages, err.Text())
+	}
+	return messages
+}
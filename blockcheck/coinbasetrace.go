@@ -0,0 +1,97 @@
+package blockcheck
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/metachris/flashbots/common"
+)
+
+// If set, CheckBlock traces (debug_traceBlockByNumber with the callTracer) all blocks with Flashbots transactions and compares the coinbase transfers with the API values
+var TraceRpcClient *rpc.Client
+
+type callFrame struct {
+	Type  string      `json:"type"`
+	From  string      `json:"from"`
+	To    string      `json:"to"`
+	Value string      `json:"value"`
+	Error string      `json:"error"`
+	Calls []callFrame `json:"calls"`
+}
+
+type txTraceResult struct {
+	Result callFrame `json:"result"`
+	Error  string    `json:"error"`
+}
+
+// TraceCoinbaseTransfers returns the value sent to the block's coinbase by each transaction (including internal calls), by tx hash
+func TraceCoinbaseTransfers(client *rpc.Client, block *types.Block) (transfers map[ethcommon.Hash]*big.Int, err error) {
+	var results []txTraceResult
+	tracerConfig := map[string]string{"tracer": "callTracer"}
+	err = client.CallContext(context.Background(), &results, "debug_traceBlockByNumber", hexutil.EncodeBig(block.Number()), tracerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("debug_traceBlockByNumber error: %w", err)
+	}
+
+	txs := block.Transactions()
+	if len(results) != len(txs) {
+		return nil, fmt.Errorf("debug_traceBlockByNumber: got %d results for %d transactions", len(results), len(txs))
+	}
+
+	coinbase := strings.ToLower(block.Coinbase().Hex())
+	transfers = make(map[ethcommon.Hash]*big.Int)
+	for i, result := range results {
+		transfers[txs[i].Hash()] = sumTransfersTo(&result.Result, coinbase)
+	}
+	return transfers, nil
+}
+
+// sumTransfersTo adds up the value of all successful calls to the address
+func sumTransfersTo(frame *callFrame, address string) *big.Int {
+	sum := new(big.Int)
+	if frame.Error != "" { // reverted, including all subcalls
+		return sum
+	}
+
+	if strings.ToLower(frame.To) == address && frame.Type != "DELEGATECALL" && frame.Type != "STATICCALL" && frame.Value != "" {
+		if value, err := hexutil.DecodeBig(frame.Value); err == nil {
+			sum.Add(sum, value)
+		}
+	}
+
+	for i := range frame.Calls {
+		sum.Add(sum, sumTransfersTo(&frame.Calls[i], address))
+	}
+	return sum
+}
+
+// checkCoinbaseTransfers compares traced coinbase transfers and the resulting miner reward with the API values
+func (b *BlockCheck) checkCoinbaseTransfers(transfers map[ethcommon.Hash]*big.Int) {
+	baseFee := b.EthBlock.BaseFee()
+	for _, fbTx := range b.FlashbotsTransactions {
+		txHash := ethcommon.HexToHash(fbTx.Hash)
+		tracedTransfer, found := transfers[txHash]
+		tx := b.EthBlock.Transaction(txHash)
+		receipt := b.BlockWithTxReceipts.TxReceipts[txHash]
+		if !found || tx == nil || receipt == nil {
+			continue
+		}
+
+		apiTransfer := common.StrToBigInt(fbTx.CoinbaseTransfer)
+		apiReward := common.StrToBigInt(fbTx.TotalMinerReward)
+		gasFees := new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), common.TxPriorityFee(tx, baseFee))
+		tracedReward := new(big.Int).Add(gasFees, tracedTransfer)
+
+		if apiTransfer.Cmp(tracedTransfer) != 0 || apiReward.Cmp(tracedReward) != 0 {
+			msg := fmt.Sprintf("tx [%s](<%s>) in bundle %d: api coinbase_transfer=%s, miner_reward=%s differs from trace coinbase_transfer=%s, miner_reward=%s\n", fbTx.Hash, common.TxUrl(fbTx.Hash), fbTx.BundleIndex, common.FormatEth(apiTransfer), common.FormatEth(apiReward), common.FormatEth(tracedTransfer), common.FormatEth(tracedReward))
+			b.addError(&CheckError{Check: CheckCoinbaseTransfers, Kind: ErrorCoinbaseTransferMismatch, Severity: SeverityLessSerious, BundleIndex: fbTx.BundleIndex, TxHash: fbTx.Hash, Message: msg})
+			b.ErrorCounter.CoinbaseTransferMismatch += 1
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package blockcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/flashbots/labels"
+)
+
+// Names of the individual checks, used to enable/disable them
+const (
+	CheckFailedTx          = "failed-tx"
+	CheckMissingBundle     = "missing-bundle"
+	CheckBundleOrder       = "bundle-order"
+	CheckBundleFee         = "bundle-fee"
+	CheckCoinbaseTransfers = "coinbase-transfers"
+	CheckSandwich          = "sandwich"           // informational
+	CheckPrivateOrderFlow  = "private-order-flow" // informational
+	CheckRelayPayment      = "relay-payment"
+	CheckProposerPayment   = "proposer-payment" // only with BeaconClient and RelayClients
+	CheckBuilderProfit     = "builder-profit"
+	CheckWatchlist         = "watchlist"       // informational, only with WatchedAddresses
+	CheckTemplateSource    = "template-source" // informational
+)
+
+var AllChecks = []string{CheckFailedTx, CheckMissingBundle, CheckBundleOrder, CheckBundleFee, CheckCoinbaseTransfers, CheckSandwich, CheckPrivateOrderFlow, CheckRelayPayment, CheckProposerPayment, CheckBuilderProfit, CheckWatchlist, CheckTemplateSource}
+
+// Severities, used to route alerts to notifiers
+const (
+	SeveritySerious     = "serious"
+	SeverityLessSerious = "less-serious"
+)
+
+var ThresholdLessSeriousBiggestBundlePercentPriceDiff float32 = 25
+var ThresholdLessSeriousBundleIsPayingLessThanLowestTxPercentDiff float32 = 25
+
+var DisabledChecks = make(map[string]bool)
+
+func IsCheckEnabled(name string) bool {
+	return !DisabledChecks[name]
+}
+
+// Config holds the check thresholds, enabled checks and which severities are sent to which notifiers.
+// It's loaded from a JSON file, all values that are not set keep their defaults.
+type Config struct {
+	DisabledChecks []string `json:"disabled_checks"`
+	CustomChecks   []string `json:"custom_checks"` // registered custom checks to run, see RegisterCheck
+
+	// Skip the checks for blocks without Flashbots and 0-gas transactions (they are still counted)
+	SkipLowActivityBlocks bool `json:"skip_low_activity_blocks"`
+
+	Thresholds struct {
+		BundlePercentPriceDiff                 float32 `json:"bundle_percent_price_diff"`
+		BundleLowerThanLowestTxPercentDiff     float32 `json:"bundle_lower_than_lowest_tx_percent_diff"`
+		LessSeriousBundlePercentPriceDiff      float32 `json:"less_serious_bundle_percent_price_diff"`
+		LessSeriousBundleLowerThanLowestTxDiff float32 `json:"less_serious_bundle_lower_than_lowest_tx_percent_diff"`
+		BuilderKeptSharePercent                float32 `json:"builder_kept_share_percent"`
+		BuilderProfitMinBlockValue             float64 `json:"builder_profit_min_block_value_eth"`
+		MinBundleReward                        float64 `json:"min_bundle_reward_eth"`           // dust bundles below are left out of the filtered stats
+		BundleFeeReferencePercentile           float64 `json:"bundle_fee_reference_percentile"` // 0 (lowest tx), 1, 5 or 10
+		DustTransferMax                        float64 `json:"dust_transfer_max_eth"`           // plain transfers below are not used as reference tx
+	} `json:"thresholds"`
+
+	// Notifiers by severity, eg. {"serious": ["terminal", "discord"], "less-serious": ["terminal"]}
+	Notifiers map[string][]string `json:"notifiers"`
+
+	// Block explorers by chain ID or network name, in addition to the built-in ones or replacing them (eg.
+	// {"5": "https://goerli.etherscan.io"}). The explorer of the connected chain is used for all links.
+	Explorers map[string]ExplorerConfig `json:"explorers"`
+
+	// Max. alerts per miner and error type per hour (0 = unlimited). Suppressed alerts are summarized in the next digest.
+	MaxAlertsPerMinerErrorPerHour int `json:"max_alerts_per_miner_error_per_hour"`
+
+	// Identical Discord alerts (same miner and error types) within this window are dropped (0 = no deduplication)
+	AlertDedupWindowSec int `json:"alert_dedup_window_sec"`
+
+	// Max. Discord messages per minute (0 = unlimited). Messages over the limit are reported in one overflow message.
+	MaxAlertsPerMinute int `json:"max_alerts_per_minute"`
+
+	// How searcher addresses are shown in alerts, digests, check results and the APIs: "full", "truncated" or
+	// "pseudonym" (see labels.SearcherDisclosure). The salt of the pseudonyms should be kept secret.
+	SearcherDisclosure    string `json:"searcher_disclosure"`
+	SearcherPseudonymSalt string `json:"searcher_pseudonym_salt"`
+}
+
+func DefaultConfig() *Config {
+	config := &Config{
+		DisabledChecks: []string{},
+		CustomChecks:   []string{},
+		Notifiers: map[string][]string{
+			SeveritySerious:     {"terminal"},
+			SeverityLessSerious: {},
+		},
+		AlertDedupWindowSec: 600,
+		MaxAlertsPerMinute:  10,
+		SearcherDisclosure:  labels.DisclosureFull,
+	}
+	config.Thresholds.BundlePercentPriceDiff = ThresholdBiggestBundlePercentPriceDiff
+	config.Thresholds.BundleLowerThanLowestTxPercentDiff = ThresholdBundleIsPayingLessThanLowestTxPercentDiff
+	config.Thresholds.LessSeriousBundlePercentPriceDiff = ThresholdLessSeriousBiggestBundlePercentPriceDiff
+	config.Thresholds.LessSeriousBundleLowerThanLowestTxDiff = ThresholdLessSeriousBundleIsPayingLessThanLowestTxPercentDiff
+	config.Thresholds.BuilderKeptSharePercent = ThresholdBuilderKeptSharePercent
+	config.Thresholds.BuilderProfitMinBlockValue = ThresholdBuilderProfitMinBlockValue
+	config.Thresholds.MinBundleReward = common.MinBundleRewardEth
+	config.Thresholds.BundleFeeReferencePercentile = ThresholdBundleFeeReferencePercentile
+	config.Thresholds.DustTransferMax = DustTransferMaxEth
+	return config
+}
+
+// LoadConfig reads a JSON config file on top of the defaults. YAML and TOML are not supported (they would add a
+// dependency for a handful of settings), such files are rejected with an error instead of a JSON syntax error.
+func LoadConfig(filename string) (*Config, error) {
+	config := DefaultConfig()
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml", ".toml":
+		return nil, fmt.Errorf("config %s: only JSON config files are supported", filename)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", filename, err)
+	}
+
+	for _, name := range config.DisabledChecks {
+		if !isKnownCheck(name) {
+			return nil, fmt.Errorf("config %s: unknown check '%s'", filename, name)
+		}
+	}
+
+	for _, name := range config.CustomChecks {
+		if !isCustomCheck(name) {
+			return nil, fmt.Errorf("config %s: unknown custom check '%s' (registered: %v)", filename, name, CustomCheckNames())
+		}
+	}
+
+	if !isValidReferencePercentile(config.Thresholds.BundleFeeReferencePercentile) {
+		return nil, fmt.Errorf("config %s: bundle_fee_reference_percentile must be one of %v", filename, BundleFeeReferencePercentiles)
+	}
+
+	for chainID, explorer := range config.Explorers {
+		if _, err := explorerChainID(chainID); err != nil {
+			return nil, fmt.Errorf("config %s: invalid explorer chain id '%s'", filename, chainID)
+		}
+		if err := explorer.Validate(explorer.BaseUrl); err != nil {
+			return nil, fmt.Errorf("config %s: explorer %s: %w", filename, chainID, err)
+		}
+	}
+
+	if !labels.IsValidDisclosureMode(config.SearcherDisclosure) {
+		return nil, fmt.Errorf("config %s: searcher_disclosure must be one of %v", filename, labels.DisclosureModes)
+	}
+	if config.SearcherDisclosure == labels.DisclosurePseudonym && config.SearcherPseudonymSalt == "" {
+		return nil, fmt.Errorf("config %s: searcher_pseudonym_salt is required for pseudonyms", filename)
+	}
+
+	return config, nil
+}
+
+// ExplorerConfig is the base url of an Etherscan or Blockscout style explorer, or an object with the url templates
+// (see common.ExplorerTemplates), eg. {"name": "Otterscan", "tx": "http://localhost:5100/tx/{hash}", ...}. Templates
+// replace the urls of the base url where set.
+type ExplorerConfig struct {
+	Name    string `json:"name"`
+	BaseUrl string `json:"base_url"`
+	common.ExplorerTemplates
+}
+
+func (c *ExplorerConfig) UnmarshalJSON(data []byte) error {
+	var baseUrl string
+	if err := json.Unmarshal(data, &baseUrl); err == nil {
+		*c = ExplorerConfig{BaseUrl: baseUrl}
+		return nil
+	}
+	type explorerConfig ExplorerConfig // without this method
+	return json.Unmarshal(data, (*explorerConfig)(c))
+}
+
+// explorerChainID returns the chain ID of a key of the explorers config: a chain ID or a known network name
+func explorerChainID(key string) (int64, error) {
+	if chain, err := common.GetChainConfig(key); err == nil {
+		return chain.ChainID, nil
+	}
+	return strconv.ParseInt(key, 10, 64)
+}
+
+func isValidReferencePercentile(percentile float64) bool {
+	for _, valid := range BundleFeeReferencePercentiles {
+		if percentile == valid {
+			return true
+		}
+	}
+	return false
+}
+
+func isKnownCheck(name string) bool {
+	for _, check := range AllChecks {
+		if check == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply sets the thresholds and enabled checks of the blockcheck package
+func (c *Config) Apply() {
+	ThresholdBiggestBundlePercentPriceDiff = c.Thresholds.BundlePercentPriceDiff
+	ThresholdBundleIsPayingLessThanLowestTxPercentDiff = c.Thresholds.BundleLowerThanLowestTxPercentDiff
+	ThresholdLessSeriousBiggestBundlePercentPriceDiff = c.Thresholds.LessSeriousBundlePercentPriceDiff
+	ThresholdLessSeriousBundleIsPayingLessThanLowestTxPercentDiff = c.Thresholds.LessSeriousBundleLowerThanLowestTxDiff
+	ThresholdBuilderKeptSharePercent = c.Thresholds.BuilderKeptSharePercent
+	ThresholdBuilderProfitMinBlockValue = c.Thresholds.BuilderProfitMinBlockValue
+	common.MinBundleRewardEth = c.Thresholds.MinBundleReward
+	ThresholdBundleFeeReferencePercentile = c.Thresholds.BundleFeeReferencePercentile
+	DustTransferMaxEth = c.Thresholds.DustTransferMax
+
+	SkipLowActivityBlocks = c.SkipLowActivityBlocks
+
+	DisabledChecks = make(map[string]bool)
+	for _, name := range c.DisabledChecks {
+		DisabledChecks[name] = true
+	}
+	setEnabledCustomChecks(c.CustomChecks)
+
+	for chainID, explorer := range c.Explorers {
+		id, _ := explorerChainID(chainID) // validated in LoadConfig
+		templates := explorer.ExplorerTemplates
+		if templates.Bundle == "" { // the bundle explorer doesn't depend on the block explorer
+			templates.Bundle = common.Explorers[id].Templates.Bundle
+		}
+		common.SetExplorer(common.NewExplorer(id, explorer.Name, explorer.BaseUrl, templates))
+	}
+
+	labels.SearcherDisclosure = labels.NewDisclosure(c.SearcherDisclosure, c.SearcherPseudonymSalt, labels.Default)
+}
+
+// HasNotifier returns true if alerts of this severity should be sent to the notifier
+func (c *Config) HasNotifier(severity string, notifier string) bool {
+	for _, n := range c.Notifiers[severity] {
+		if n == notifier {
+			return true
+		}
+	}
+	return false
+}
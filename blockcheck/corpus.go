@@ -0,0 +1,137 @@
+package blockcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// CorpusCase is a block of the regression corpus (see testdata/corpus): the inputs of its check, and the expected
+// outcome of the check suite
+type CorpusCase struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description"` // what makes the block interesting, eg. "megabundle after a regular bundle"
+	Input       *CheckInput   `json:"input"`
+	Expected    CorpusOutcome `json:"expected"`
+}
+
+// CorpusOutcome is what the corpus compares: the typed errors and the severity of the block, without the messages
+type CorpusOutcome struct {
+	Errors               []CorpusError     `json:"errors"` // sorted
+	ErrorCounts          map[string]uint64 `json:"error_counts"`
+	HasSeriousErrors     bool              `json:"has_serious_errors"`
+	HasLessSeriousErrors bool              `json:"has_less_serious_errors"`
+}
+
+type CorpusError struct {
+	Kind        string `json:"kind"`
+	Severity    string `json:"severity"`
+	BundleIndex int64  `json:"bundle_index"`
+	TxHash      string `json:"tx_hash,omitempty"`
+}
+
+func (e CorpusError) String() string {
+	return fmt.Sprintf("%s (severity=%s bundle=%d tx=%s)", e.Kind, e.Severity, e.BundleIndex, e.TxHash)
+}
+
+// NewCorpusOutcome returns the outcome of the check
+func NewCorpusOutcome(check *BlockCheck) CorpusOutcome {
+	outcome := CorpusOutcome{
+		Errors:               make([]CorpusError, 0, len(check.CheckErrors)),
+		ErrorCounts:          check.ErrorCounter.Map(),
+		HasSeriousErrors:     check.HasSeriousErrors(),
+		HasLessSeriousErrors: check.HasLessSeriousErrors(),
+	}
+	for _, err := range check.CheckErrors {
+		outcome.Errors = append(outcome.Errors, CorpusError{Kind: err.Kind, Severity: err.Severity, BundleIndex: err.BundleIndex, TxHash: strings.ToLower(err.TxHash)})
+	}
+	sort.Slice(outcome.Errors, func(i, j int) bool { return outcome.Errors[i].String() < outcome.Errors[j].String() })
+	return outcome
+}
+
+// Diff returns the differences to the expected outcome, empty if they match
+func (o CorpusOutcome) Diff(expected CorpusOutcome) (diffs []string) {
+	for _, err := range corpusErrorsDifference(o.Errors, expected.Errors) {
+		diffs = append(diffs, "unexpected error "+err.String())
+	}
+	for _, err := range corpusErrorsDifference(expected.Errors, o.Errors) {
+		diffs = append(diffs, "missing error "+err.String())
+	}
+	if len(o.ErrorCounts)+len(expected.ErrorCounts) > 0 && !reflect.DeepEqual(o.ErrorCounts, expected.ErrorCounts) {
+		diffs = append(diffs, fmt.Sprintf("error counts %v, expected %v", o.ErrorCounts, expected.ErrorCounts))
+	}
+	if o.HasSeriousErrors != expected.HasSeriousErrors {
+		diffs = append(diffs, fmt.Sprintf("has serious errors: %v, expected %v", o.HasSeriousErrors, expected.HasSeriousErrors))
+	}
+	if o.HasLessSeriousErrors != expected.HasLessSeriousErrors {
+		diffs = append(diffs, fmt.Sprintf("has less-serious errors: %v, expected %v", o.HasLessSeriousErrors, expected.HasLessSeriousErrors))
+	}
+	return diffs
+}
+
+// corpusErrorsDifference returns the errors of a which aren't in b (each error of b matches once)
+func corpusErrorsDifference(a []CorpusError, b []CorpusError) (ret []CorpusError) {
+	counts := make(map[CorpusError]int)
+	for _, err := range b {
+		counts[err] += 1
+	}
+	for _, err := range a {
+		if counts[err] > 0 {
+			counts[err] -= 1
+			continue
+		}
+		ret = append(ret, err)
+	}
+	return ret
+}
+
+// Run replays the block of the case (see Replay), and returns the differences to the expected outcome
+func (c *CorpusCase) Run() (outcome CorpusOutcome, diffs []string, err error) {
+	check, err := Replay(c.Input)
+	if err != nil {
+		return outcome, nil, err
+	}
+	outcome = NewCorpusOutcome(check)
+	return outcome, outcome.Diff(c.Expected), nil
+}
+
+// Save writes the case to <dir>/<name>.json
+func (c *CorpusCase) Save(dir string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, c.Name+".json"), append(data, '\n'), 0644)
+}
+
+// LoadCorpus reads all cases (*.json) of the directory, sorted by name
+func LoadCorpus(dir string) (cases []*CorpusCase, err error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	for _, filename := range files {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		c := new(CorpusCase)
+		if err = json.Unmarshal(data, c); err != nil {
+			return nil, fmt.Errorf("corpus case %s: %w", filename, err)
+		}
+		if c.Input == nil {
+			return nil, fmt.Errorf("corpus case %s: no input", filename)
+		}
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
@@ -0,0 +1,241 @@
+package blockcheck
+
+import (
+	"encoding/json"
+	"flag"
+	"math/big"
+	"testing"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/metachris/flashbots/api"
+)
+
+var updateCorpus = flag.Bool("update-corpus", false, "rewrite the expected outcomes of the corpus files with the current results")
+
+// Historical blocks of the corpus, added with flashbots-replay -corpus
+const corpusDir = "testdata/corpus"
+
+// corpusTx is a tx of a synthetic corpus block, all tx use 21000 gas
+type corpusTx struct {
+	bundleType       string // empty for mempool tx
+	bundleIndex      int64
+	gasPriceGwei     int64
+	coinbaseTransfer int64  // gwei per gas
+	minerReward      *int64 // gwei per gas reported by the API, default gas price + coinbase transfer (eg. negative)
+	failed           bool
+}
+
+func gwei(i int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(i), big.NewInt(1e9))
+}
+
+// newCorpusInput returns the inputs of a pre-London block with the transactions, signed by one test key
+func newCorpusInput(t *testing.T, number int64, txs []corpusTx) *CheckInput {
+	key, err := crypto.ToECDSA(ethcommon.LeftPadBytes([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	contract := ethcommon.HexToAddress("0x00000000000000000000000000000000000c0de1")
+	coinbase := ethcommon.HexToAddress("0x000000000000000000000000000000000000c0b5")
+	gasUsed := int64(21000)
+
+	var ethTxs []*types.Transaction
+	var receipts []*types.Receipt
+	fbBlock := &api.FlashbotsBlock{BlockNumber: number, Miner: coinbase.Hex()}
+	for i, tx := range txs {
+		ethTx, err := types.SignTx(types.NewTransaction(uint64(i), contract, big.NewInt(0), uint64(gasUsed), gwei(tx.gasPriceGwei), []byte{1}), types.HomesteadSigner{}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: uint64(gasUsed * int64(i+1)), GasUsed: uint64(gasUsed), TxHash: ethTx.Hash(), Logs: []*types.Log{}}
+		if tx.failed {
+			receipt.Status = types.ReceiptStatusFailed
+		}
+		ethTxs = append(ethTxs, ethTx)
+		receipts = append(receipts, receipt)
+
+		if tx.bundleType == "" {
+			continue
+		}
+		reward := tx.gasPriceGwei + tx.coinbaseTransfer
+		if tx.minerReward != nil {
+			reward = *tx.minerReward
+		}
+		fbBlock.Transactions = append(fbBlock.Transactions, api.FlashbotsTransaction{
+			Hash:             ethTx.Hash().Hex(),
+			TxIndex:          int64(i),
+			BundleType:       tx.bundleType,
+			BundleIndex:      tx.bundleIndex,
+			BlockNumber:      number,
+			EoaAddress:       sender.Hex(),
+			ToAddress:        contract.Hex(),
+			GasUsed:          gasUsed,
+			GasPrice:         gwei(tx.gasPriceGwei).String(),
+			CoinbaseTransfer: new(big.Int).Mul(gwei(tx.coinbaseTransfer), big.NewInt(gasUsed)).String(),
+			TotalMinerReward: new(big.Int).Mul(gwei(reward), big.NewInt(gasUsed)).String(),
+		})
+	}
+
+	header := &types.Header{Number: big.NewInt(number), GasLimit: 15000000, Difficulty: big.NewInt(1), Coinbase: coinbase, Time: 1630000000}
+	block := types.NewBlock(header, ethTxs, nil, receipts, trie.NewStackTrie(nil))
+	data, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := &CheckInput{BlockNumber: number, Block: hexutil.Encode(data), Receipts: receipts}
+	if len(fbBlock.Transactions) > 0 {
+		input.FlashbotsBlock = fbBlock
+	}
+	return input
+}
+
+// syntheticCorpus are the edge cases of the checks as synthetic blocks, with hand-written expected outcomes
+func syntheticCorpus(t *testing.T) []*CorpusCase {
+	fb, mega := api.BundleTypeFlashbots, api.BundleTypeMegabundle
+	negative := int64(-1)
+	serious := func(kind string, bundleIndex int64) CorpusError {
+		return CorpusError{Kind: kind, Severity: SeveritySerious, BundleIndex: bundleIndex}
+	}
+
+	cases := []*CorpusCase{
+		{
+			Name:        "synthetic-clean",
+			Description: "two bundles in order at the top, paying more than the mempool tx",
+			Input:       newCorpusInput(t, 1, []corpusTx{{bundleType: fb, bundleIndex: 0, gasPriceGwei: 50}, {bundleType: fb, bundleIndex: 1, gasPriceGwei: 40}, {gasPriceGwei: 10}}),
+			Expected:    CorpusOutcome{},
+		},
+		{
+			Name:        "synthetic-bundle-pays-more",
+			Description: "the second bundle pays 200% more by coinbase transfer than the first",
+			Input:       newCorpusInput(t, 2, []corpusTx{{bundleType: fb, bundleIndex: 0, gasPriceGwei: 20}, {bundleType: fb, bundleIndex: 1, coinbaseTransfer: 60}, {gasPriceGwei: 10}}),
+			Expected: CorpusOutcome{
+				Errors:               []CorpusError{serious(ErrorBundlePaysMore, 1)},
+				ErrorCounts:          map[string]uint64{ErrorBundlePaysMore: 1},
+				HasSeriousErrors:     true,
+				HasLessSeriousErrors: true,
+			},
+		},
+		{
+			Name:        "synthetic-negative-fee",
+			Description: "a bundle with a negative miner reward",
+			Input:       newCorpusInput(t, 3, []corpusTx{{bundleType: fb, bundleIndex: 0, gasPriceGwei: 1, minerReward: &negative}, {gasPriceGwei: 10}}),
+			Expected: CorpusOutcome{
+				Errors:           []CorpusError{serious(ErrorBundleHasNegativeFee, 0)},
+				ErrorCounts:      map[string]uint64{ErrorBundleHasNegativeFee: 1},
+				HasSeriousErrors: true,
+			},
+		},
+		{
+			Name:        "synthetic-zero-fee",
+			Description: "a bundle paying neither gas price nor coinbase transfer",
+			Input:       newCorpusInput(t, 4, []corpusTx{{bundleType: fb, bundleIndex: 0}, {gasPriceGwei: 10}}),
+			Expected: CorpusOutcome{
+				Errors:           []CorpusError{serious(ErrorBundleHas0Fee, 0)},
+				ErrorCounts:      map[string]uint64{ErrorBundleHas0Fee: 1},
+				HasSeriousErrors: true,
+			},
+		},
+		{
+			Name:        "synthetic-megabundle-not-first",
+			Description: "a regular bundle placed before the megabundle",
+			Input:       newCorpusInput(t, 5, []corpusTx{{bundleType: fb, bundleIndex: 0, gasPriceGwei: 50}, {bundleType: mega, bundleIndex: 0, gasPriceGwei: 60}, {bundleType: mega, bundleIndex: 0, gasPriceGwei: 60}, {gasPriceGwei: 10}}),
+			Expected: CorpusOutcome{
+				Errors:           []CorpusError{serious(ErrorMegabundleNotFirst, 0)},
+				ErrorCounts:      map[string]uint64{ErrorMegabundleNotFirst: 1},
+				HasSeriousErrors: true,
+			},
+		},
+		{
+			Name:        "synthetic-bundle-not-at-top",
+			Description: "a bundle placed after a mempool tx",
+			Input:       newCorpusInput(t, 6, []corpusTx{{bundleType: fb, bundleIndex: 0, gasPriceGwei: 50}, {gasPriceGwei: 10}, {bundleType: fb, bundleIndex: 1, gasPriceGwei: 40}}),
+			Expected: CorpusOutcome{
+				Errors:           []CorpusError{serious(ErrorBundleNotAtTop, 1)},
+				ErrorCounts:      map[string]uint64{ErrorBundleNotAtTop: 1},
+				HasSeriousErrors: true,
+			},
+		},
+		{
+			Name:        "synthetic-failed-flashbots-tx",
+			Description: "a reverted bundle tx",
+			Input:       newCorpusInput(t, 7, []corpusTx{{bundleType: fb, bundleIndex: 0, gasPriceGwei: 50, failed: true}, {gasPriceGwei: 10}}),
+		},
+		{
+			Name:        "synthetic-failed-0-gas-tx",
+			Description: "a reverted 0 gas price tx in a block without bundles",
+			Input:       newCorpusInput(t, 8, []corpusTx{{failed: true}, {gasPriceGwei: 10}}),
+		},
+	}
+
+	// The expected failed tx need their hashes
+	failedFbTx := cases[6].Input.Receipts[0].TxHash.Hex()
+	cases[6].Expected = CorpusOutcome{
+		Errors:               []CorpusError{{Kind: ErrorFailedFlashbotsTx, Severity: SeveritySerious, BundleIndex: 0, TxHash: failedFbTx}},
+		ErrorCounts:          map[string]uint64{ErrorFailedFlashbotsTx: 1},
+		HasSeriousErrors:     true,
+		HasLessSeriousErrors: true,
+	}
+	failed0GasTx := cases[7].Input.Receipts[0].TxHash.Hex()
+	cases[7].Expected = CorpusOutcome{
+		Errors:               []CorpusError{{Kind: ErrorFailed0GasTx, Severity: SeveritySerious, BundleIndex: -1, TxHash: failed0GasTx}},
+		ErrorCounts:          map[string]uint64{ErrorFailed0GasTx: 1},
+		HasSeriousErrors:     true,
+		HasLessSeriousErrors: true,
+	}
+	return cases
+}
+
+// TestGolden runs the check suite over the corpus: the synthetic edge cases and the historical blocks of
+// testdata/corpus. After an intended change of the results, update the historical blocks with
+// go test ./blockcheck -run TestGolden -update-corpus (and review the diff).
+func TestGolden(t *testing.T) {
+	fileCases, err := LoadCorpus(corpusDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range syntheticCorpus(t) {
+		// Through JSON like the corpus files
+		data, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := new(CorpusCase)
+		if err = json.Unmarshal(data, decoded); err != nil {
+			t.Fatal(c.Name, err)
+		}
+		runCorpusCase(t, decoded, false)
+	}
+	if len(fileCases) == 0 {
+		t.Log("no historical blocks in", corpusDir)
+	}
+	for _, c := range fileCases {
+		runCorpusCase(t, c, *updateCorpus)
+	}
+}
+
+func runCorpusCase(t *testing.T, c *CorpusCase, update bool) {
+	outcome, diffs, err := c.Run()
+	if err != nil {
+		t.Errorf("%s: %v", c.Name, err)
+		return
+	}
+	if update && len(diffs) > 0 {
+		c.Expected = outcome
+		if err = c.Save(corpusDir); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%s: updated (%v)", c.Name, diffs)
+		return
+	}
+	for _, diff := range diffs {
+		t.Errorf("%s (%s): %s", c.Name, c.Description, diff)
+	}
+}
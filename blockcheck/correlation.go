@@ -0,0 +1,298 @@
+package blockcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/metachris/flashbots/labels"
+)
+
+// What the errors of a MultiBlockIncident have in common, besides the error type
+const (
+	CorrelateMiner    = "miner"
+	CorrelateSearcher = "searcher"
+)
+
+// Max. error messages kept as evidence of a MultiBlockIncident (the blocks are all kept)
+var MaxIncidentEvidence = 100
+
+// MultiBlockIncident groups the errors of the same type by the same miner or searcher in nearby blocks
+type MultiBlockIncident struct {
+	Correlation string  `json:"correlation"` // CorrelateMiner or CorrelateSearcher
+	Subject     string  `json:"subject"`     // miner or searcher address (lowercase, searchers as disclosed by labels.SearcherDisclosure)
+	SubjectName string  `json:"subject_name,omitempty"`
+	ErrorKind   string  `json:"error_kind"` // see the Error* constants
+	Severity    string  `json:"severity"`   // highest severity of the errors
+	FirstBlock  int64   `json:"first_block"`
+	LastBlock   int64   `json:"last_block"`
+	Blocks      []int64 `json:"blocks"`
+
+	Errors    []string `json:"errors"` // "block <n>: <message>", at most MaxIncidentEvidence
+	TxHashes  []string `json:"tx_hashes"`
+	Addresses []string `json:"addresses"` // miners of the blocks, searchers and contracts of the bundles
+
+	Alerted bool `json:"alerted"` // reported when it reached Correlator.MinBlocks
+
+	seen map[string]bool
+}
+
+// ID identifies the incident, eg. "miner-0xabc...-bundlePaysMore-13000000"
+func (i *MultiBlockIncident) ID() string {
+	return fmt.Sprintf("%s-%s-%s-%d", i.Correlation, i.Subject, i.ErrorKind, i.FirstBlock)
+}
+
+func (i *MultiBlockIncident) key() string {
+	return correlationKey(i.Correlation, i.Subject, i.ErrorKind)
+}
+
+func correlationKey(correlation string, subject string, errorKind string) string {
+	return correlation + " " + subject + " " + errorKind
+}
+
+func (i *MultiBlockIncident) subjectString() string {
+	if i.SubjectName != "" && i.SubjectName != i.Subject {
+		return fmt.Sprintf("%s %s (%s)", i.Correlation, i.SubjectName, i.Subject)
+	}
+	return fmt.Sprintf("%s %s", i.Correlation, i.Subject)
+}
+
+func (i *MultiBlockIncident) String() string {
+	return fmt.Sprintf("%s incident: %s in %d blocks (%d to %d) by %s", i.Severity, i.ErrorKind, len(i.Blocks), i.FirstBlock, i.LastBlock, i.subjectString())
+}
+
+// Sprint returns the summary with the combined evidence (the first maxErrors error messages, 0 = all)
+func (i *MultiBlockIncident) Sprint(maxErrors int) string {
+	msg := i.String() + "\n"
+	errors := i.Errors
+	if maxErrors > 0 && len(errors) > maxErrors {
+		errors = errors[:maxErrors]
+	}
+	for _, err := range errors {
+		msg += "- " + err + "\n"
+	}
+	if len(errors) < len(i.Errors) {
+		msg += fmt.Sprintf("- and %d more errors\n", len(i.Errors)-len(errors))
+	}
+	msg += fmt.Sprintf("%d transactions, %d addresses involved\n", len(i.TxHashes), len(i.Addresses))
+	return msg
+}
+
+func (i *MultiBlockIncident) add(check *BlockCheck, err *CheckError, addresses []string) {
+	blockKey := fmt.Sprintf("block %d", check.Number)
+	if !i.seen[blockKey] {
+		i.Blocks = append(i.Blocks, check.Number)
+		i.seen[blockKey] = true
+	}
+	if check.Number > i.LastBlock {
+		i.LastBlock = check.Number
+	}
+	if i.Severity != SeveritySerious && err.Severity != "" {
+		i.Severity = err.Severity
+	}
+	if len(i.Errors) < MaxIncidentEvidence {
+		i.Errors = append(i.Errors, fmt.Sprintf("block %d: %s", check.Number, err.Text()))
+	}
+	if err.TxHash != "" && !i.seen[err.TxHash] {
+		i.TxHashes = append(i.TxHashes, err.TxHash)
+		i.seen[err.TxHash] = true
+	}
+	for _, address := range addresses {
+		address = strings.ToLower(address)
+		if address != "" && !i.seen[address] {
+			i.Addresses = append(i.Addresses, address)
+			i.seen[address] = true
+		}
+	}
+}
+
+// WriteFile writes the incident as JSON (incident-<id>.json), for post-mortems
+func (i *MultiBlockIncident) WriteFile(dir string) (filename string, err error) {
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	filename = filepath.Join(dir, fmt.Sprintf("incident-%s.json", i.ID()))
+	return filename, os.WriteFile(filename, data, 0644)
+}
+
+// Correlator groups the errors of checked blocks into multi-block incidents: errors of the same type by the same
+// miner, or in bundles of the same searcher, join an open incident if they are at most Window blocks after its last
+// block. An incident is reported once when it spans MinBlocks blocks, and closed when no error joined it for Window
+// blocks. It's safe for concurrent use.
+type Correlator struct {
+	Window    int64 // blocks
+	MinBlocks int
+
+	lock   sync.Mutex
+	open   map[string]*MultiBlockIncident // by correlationKey
+	closed []*MultiBlockIncident          // replaced by a new incident with the same key, until the next Expire
+}
+
+func NewCorrelator(window int64, minBlocks int) *Correlator {
+	return &Correlator{
+		Window:    window,
+		MinBlocks: minBlocks,
+		open:      make(map[string]*MultiBlockIncident),
+	}
+}
+
+// correlatedError is an error of a check with what it can be correlated by
+type correlatedError struct {
+	err          *CheckError
+	searcher     string
+	searcherName string
+	addresses    []string // involved addresses: miner, searcher, contract
+}
+
+// correlatedErrors returns the typed errors of the check with their searcher (the sender of the failed tx, or the
+// EOA of the bundle) and the involved addresses
+func (b *BlockCheck) correlatedErrors() (errors []correlatedError) {
+	for _, err := range b.CheckErrors {
+		if err.Kind == "" {
+			continue
+		}
+
+		e := correlatedError{err: err, addresses: []string{b.Miner}}
+		if failedTx, found := b.FailedTx[err.TxHash]; err.TxHash != "" && found {
+			e.searcher = failedTx.From
+			e.addresses = append(e.addresses, labels.SearcherDisclosure.Address(failedTx.From), failedTx.To)
+		} else if err.BundleIndex >= 0 {
+			for _, bundle := range b.Bundles {
+				if bundle.Index != err.BundleIndex || bundle.IsMegabundle() || len(bundle.Transactions) == 0 {
+					continue
+				}
+				e.searcher = bundle.Transactions[0].EoaAddress
+				e.searcherName = bundle.SearcherName
+				for _, tx := range bundle.Transactions {
+					e.addresses = append(e.addresses, labels.SearcherDisclosure.Address(tx.EoaAddress), tx.ToAddress)
+				}
+				break
+			}
+		}
+		errors = append(errors, e)
+	}
+	return errors
+}
+
+// Add adds the errors of a checked block, and returns the incidents which now span MinBlocks blocks (reported once)
+func (c *Correlator) Add(check *BlockCheck) (reached []*MultiBlockIncident) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, e := range check.correlatedErrors() {
+		subjects := [][3]string{{CorrelateMiner, strings.ToLower(check.Miner), check.MinerName}}
+		if e.searcher != "" {
+			subjects = append(subjects, [3]string{CorrelateSearcher, strings.ToLower(e.searcher), e.searcherName})
+		}
+
+		for _, subject := range subjects {
+			key := correlationKey(subject[0], subject[1], e.err.Kind)
+			incident, found := c.open[key]
+			if !found || check.Number > incident.LastBlock+c.Window {
+				if found {
+					c.closed = append(c.closed, incident)
+				}
+				incident = &MultiBlockIncident{
+					Correlation: subject[0],
+					Subject:     subject[1],
+					SubjectName: subject[2],
+					ErrorKind:   e.err.Kind,
+					FirstBlock:  check.Number,
+					LastBlock:   check.Number,
+					TxHashes:    make([]string, 0),
+					Addresses:   make([]string, 0),
+					seen:        make(map[string]bool),
+				}
+				if subject[0] == CorrelateSearcher {
+					incident.Subject = strings.ToLower(labels.SearcherDisclosure.Address(subject[1]))
+				}
+				c.open[key] = incident
+			}
+
+			incident.add(check, e.err, e.addresses)
+			if !incident.Alerted && c.MinBlocks > 0 && len(incident.Blocks) >= c.MinBlocks {
+				incident.Alerted = true
+				reached = append(reached, incident)
+			}
+		}
+	}
+	return reached
+}
+
+// Covers returns the reported incident an error of the check belongs to, if all its errors belong to reported
+// incidents (the alert of the block can be skipped), else nil
+func (c *Correlator) Covers(check *BlockCheck) *MultiBlockIncident {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var covering *MultiBlockIncident
+	errors := check.correlatedErrors()
+	if len(errors) == 0 || len(errors) != len(check.CheckErrors) {
+		return nil
+	}
+	for _, e := range errors {
+		covered := false
+		for _, subject := range [][2]string{{CorrelateMiner, strings.ToLower(check.Miner)}, {CorrelateSearcher, strings.ToLower(e.searcher)}} {
+			incident, found := c.open[correlationKey(subject[0], subject[1], e.err.Kind)]
+			if found && incident.Alerted && check.Number >= incident.FirstBlock && check.Number <= incident.LastBlock+c.Window {
+				covered = true
+				covering = incident
+				break
+			}
+		}
+		if !covered {
+			return nil
+		}
+	}
+	return covering
+}
+
+// Expire closes and returns the incidents without errors in the Window blocks up to the given block, sorted by first
+// block
+func (c *Correlator) Expire(number int64) (closed []*MultiBlockIncident) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	closed = c.closed
+	c.closed = nil
+	for key, incident := range c.open {
+		if number > incident.LastBlock+c.Window {
+			closed = append(closed, incident)
+			delete(c.open, key)
+		}
+	}
+	sort.Slice(closed, func(i, j int) bool {
+		if closed[i].FirstBlock != closed[j].FirstBlock {
+			return closed[i].FirstBlock < closed[j].FirstBlock
+		}
+		return closed[i].key() < closed[j].key()
+	})
+	return closed
+}
+
+// Open returns the open incidents, sorted by first block
+func (c *Correlator) Open() (incidents []*MultiBlockIncident) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, incident := range c.open {
+		incidents = append(incidents, incident)
+	}
+	sort.Slice(incidents, func(i, j int) bool {
+		if incidents[i].FirstBlock != incidents[j].FirstBlock {
+			return incidents[i].FirstBlock < incidents[j].FirstBlock
+		}
+		return incidents[i].key() < incidents[j].key()
+	})
+	return incidents
+}
@@ -0,0 +1,64 @@
+package blockcheck
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/common"
+)
+
+func TestCorrelator(t *testing.T) {
+	newCheck := func(number int64, miner string, searcher string) *BlockCheck {
+		bundle := newTestBundle(api.BundleTypeFlashbots, 0, 0)
+		bundle.Transactions[0].EoaAddress = searcher
+		bundle.Transactions[0].Hash = "0xtx" + searcher
+		check := &BlockCheck{Number: number, Miner: miner, Bundles: []*common.Bundle{bundle}}
+		check.addError(&CheckError{Check: CheckBundleOrder, Kind: ErrorBundlePaysMore, Severity: SeveritySerious, BundleIndex: 0, Message: "bundle 0 pays more\n"})
+		return check
+	}
+
+	c := NewCorrelator(10, 3)
+	if reached := c.Add(newCheck(100, "0xM1", "0xS1")); len(reached) != 0 {
+		t.Fatal("unexpected incidents", reached)
+	}
+	c.Add(newCheck(105, "0xM1", "0xS2"))
+	if c.Covers(newCheck(106, "0xM1", "0xS1")) != nil {
+		t.Error("the incident isn't reported yet")
+	}
+
+	// The third block of the miner reaches MinBlocks, the searchers only have 1 and 2 blocks
+	reached := c.Add(newCheck(112, "0xM1", "0xS1"))
+	if len(reached) != 1 || reached[0].Correlation != CorrelateMiner || reached[0].Subject != "0xm1" || len(reached[0].Blocks) != 3 || reached[0].FirstBlock != 100 || reached[0].LastBlock != 112 {
+		t.Fatal("expected a miner incident", reached)
+	}
+	if incident := reached[0]; len(incident.Errors) != 3 || incident.Severity != SeveritySerious || len(incident.Addresses) != 3 {
+		t.Errorf("unexpected evidence %+v", incident)
+	}
+	if c.Add(newCheck(113, "0xM1", "0xS1")) != nil {
+		t.Error("the incident is only reported once")
+	}
+	if c.Covers(newCheck(114, "0xM1", "0xS3")) != reached[0] {
+		t.Error("expected the block to be covered by the incident")
+	}
+	if c.Covers(newCheck(114, "0xM2", "0xS3")) != nil {
+		t.Error("another miner isn't covered")
+	}
+
+	// Closed after Window blocks without errors (the first incident of searcher 1 was replaced in block 112)
+	if closed := c.Expire(120); len(closed) != 2 || closed[0].Subject != "0xs1" || closed[0].LastBlock != 100 || closed[1].Subject != "0xs2" {
+		t.Fatal("expected the incidents of searcher 1 and 2 to be closed", closed)
+	}
+	closed := c.Expire(124)
+	if len(closed) != 2 || closed[0].Subject != "0xm1" || closed[1].Subject != "0xs1" || len(closed[0].Blocks) != 4 {
+		t.Fatal("expected the miner and searcher 1 incidents to be closed", closed)
+	}
+	if len(c.Open()) != 0 {
+		t.Error("expected no open incidents")
+	}
+
+	filename, err := closed[0].WriteFile(t.TempDir())
+	if err != nil || filepath.Base(filename) != "incident-miner-0xm1-bundlePaysMore-100.json" {
+		t.Error("unexpected file", filename, err)
+	}
+}
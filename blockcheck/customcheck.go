@@ -0,0 +1,148 @@
+package blockcheck
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/metachris/flashbots/api"
+)
+
+// Check is a custom check, registered with RegisterCheck to run after the built-in bundle checks (eg. "bundle touches
+// a blacklisted contract"). Custom checks only run when enabled by name, see EnableCustomCheck and
+// Config.CustomChecks.
+type Check interface {
+	Name() string
+	Severity() string // SeveritySerious or SeverityLessSerious, the default of the returned errors
+
+	// Run returns the errors found in the block. fbBlock is the Flashbots API data of the block (check.Bundles has
+	// the bundles created from it). Check, Kind and Severity of the errors default to the name and severity of the
+	// check.
+	Run(check *BlockCheck, fbBlock *api.FlashbotsBlock) []*CheckError
+}
+
+// CheckFunc is a Check defined by a function
+type CheckFunc struct {
+	CheckName     string
+	CheckSeverity string
+	RunFunc       func(check *BlockCheck, fbBlock *api.FlashbotsBlock) []*CheckError
+}
+
+func (f CheckFunc) Name() string     { return f.CheckName }
+func (f CheckFunc) Severity() string { return f.CheckSeverity }
+func (f CheckFunc) Run(check *BlockCheck, fbBlock *api.FlashbotsBlock) []*CheckError {
+	return f.RunFunc(check, fbBlock)
+}
+
+var customChecksLock sync.RWMutex
+var customChecks = make(map[string]Check)       // by name
+var enabledCustomChecks = make(map[string]bool) // by name
+
+// RegisterCheck adds a custom check to the registry. The name must not be used by a built-in or another custom check.
+func RegisterCheck(check Check) error {
+	name := check.Name()
+	if name == "" {
+		return fmt.Errorf("custom check without name")
+	}
+	if check.Severity() != SeveritySerious && check.Severity() != SeverityLessSerious {
+		return fmt.Errorf("custom check '%s': invalid severity '%s'", name, check.Severity())
+	}
+	if isKnownCheck(name) {
+		return fmt.Errorf("custom check '%s': name of a built-in check", name)
+	}
+
+	customChecksLock.Lock()
+	defer customChecksLock.Unlock()
+	if _, found := customChecks[name]; found {
+		return fmt.Errorf("custom check '%s' is already registered", name)
+	}
+	customChecks[name] = check
+	return nil
+}
+
+// CustomCheckNames returns the names of the registered custom checks, sorted
+func CustomCheckNames() []string {
+	customChecksLock.RLock()
+	defer customChecksLock.RUnlock()
+	names := make([]string, 0, len(customChecks))
+	for name := range customChecks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isCustomCheck(name string) bool {
+	customChecksLock.RLock()
+	defer customChecksLock.RUnlock()
+	_, found := customChecks[name]
+	return found
+}
+
+// EnableCustomCheck enables a registered custom check
+func EnableCustomCheck(name string) error {
+	if !isCustomCheck(name) {
+		return fmt.Errorf("unknown custom check '%s' (registered: %v)", name, CustomCheckNames())
+	}
+	customChecksLock.Lock()
+	defer customChecksLock.Unlock()
+	enabledCustomChecks[name] = true
+	return nil
+}
+
+// setEnabledCustomChecks enables only these custom checks (unknown names are ignored)
+func setEnabledCustomChecks(names []string) {
+	customChecksLock.Lock()
+	defer customChecksLock.Unlock()
+	enabledCustomChecks = make(map[string]bool)
+	for _, name := range names {
+		if _, found := customChecks[name]; found {
+			enabledCustomChecks[name] = true
+		}
+	}
+}
+
+// enabledChecks returns the enabled custom checks, sorted by name
+func enabledChecks() (checks []Check) {
+	customChecksLock.RLock()
+	defer customChecksLock.RUnlock()
+	for name := range enabledCustomChecks {
+		checks = append(checks, customChecks[name])
+	}
+	sort.Slice(checks, func(i, j int) bool { return checks[i].Name() < checks[j].Name() })
+	return checks
+}
+
+// customSteps are the steps of the enabled custom checks
+func (b *BlockCheck) customSteps() (steps []Step) {
+	for _, check := range enabledChecks() {
+		check := check
+		steps = append(steps, Step{check.Name(), true, false, func() error { b.runCustomCheck(check); return nil }})
+	}
+	return steps
+}
+
+func (b *BlockCheck) runCustomCheck(check Check) {
+	for _, err := range check.Run(b, b.FlashbotsApiBlock) {
+		if err.Check == "" {
+			err.Check = check.Name()
+		}
+		if err.Kind == "" {
+			err.Kind = check.Name()
+		}
+		if err.Severity == "" {
+			err.Severity = check.Severity()
+		}
+		b.addError(err)
+	}
+}
+
+// hasCustomCheckErrors returns true if a custom check found an error of this severity (any severity if empty)
+func (b *BlockCheck) hasCustomCheckErrors(severity string) bool {
+	for _, err := range b.CheckErrors {
+		if (severity == "" || err.Severity == severity) && isCustomCheck(err.Check) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package blockcheck
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/metachris/flashbots/api"
+)
+
+func TestCustomCheck(t *testing.T) {
+	defer func() {
+		customChecks = make(map[string]Check)
+		enabledCustomChecks = make(map[string]bool)
+	}()
+
+	blacklisted := CheckFunc{CheckName: "blacklisted-contract", CheckSeverity: SeveritySerious, RunFunc: func(check *BlockCheck, fbBlock *api.FlashbotsBlock) (errors []*CheckError) {
+		for _, tx := range fbBlock.Transactions {
+			if tx.ToAddress == "0xbad" {
+				errors = append(errors, &CheckError{BundleIndex: tx.BundleIndex, TxHash: tx.Hash, Message: "bundle touches a blacklisted contract\n"})
+			}
+		}
+		return errors
+	}}
+	if err := RegisterCheck(blacklisted); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterCheck(blacklisted); err == nil {
+		t.Error("expected an error when registering a name twice")
+	}
+	if err := RegisterCheck(CheckFunc{CheckName: CheckFailedTx, CheckSeverity: SeveritySerious}); err == nil {
+		t.Error("expected an error for the name of a built-in check")
+	}
+	if err := EnableCustomCheck("unknown"); err == nil || !strings.Contains(err.Error(), "blacklisted-contract") {
+		t.Error("expected an error listing the registered checks", err)
+	}
+
+	fbBlock := &api.FlashbotsBlock{Transactions: []api.FlashbotsTransaction{
+		{Hash: "0x1", BundleIndex: 0, ToAddress: "0xgood"},
+		{Hash: "0x2", BundleIndex: 1, ToAddress: "0xbad"},
+	}}
+	check := &BlockCheck{FlashbotsApiBlock: fbBlock}
+
+	// Registered checks only run when enabled
+	if steps := check.customSteps(); len(steps) != 0 {
+		t.Fatal("expected no steps before enabling", steps)
+	}
+	if err := EnableCustomCheck("blacklisted-contract"); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range check.customSteps() {
+		if err := step.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(check.CheckErrors) != 1 || len(check.Errors) != 1 {
+		t.Fatal("expected 1 error, got", check.CheckErrors)
+	}
+	err := check.CheckErrors[0]
+	if err.Check != "blacklisted-contract" || err.Kind != "blacklisted-contract" || err.Severity != SeveritySerious || err.BundleIndex != 1 {
+		t.Errorf("unexpected error %+v", err)
+	}
+	if !check.HasSeriousErrors() || !check.HasLessSeriousErrors() {
+		t.Error("expected the custom error to be serious")
+	}
+
+	// Config.Apply replaces the enabled checks
+	config := DefaultConfig()
+	config.Apply()
+	if steps := check.customSteps(); len(steps) != 0 {
+		t.Error("expected no steps after applying a config without custom checks", steps)
+	}
+}
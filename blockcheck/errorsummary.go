@@ -2,8 +2,11 @@ package blockcheck
 
 import (
 	"fmt"
+	"math/big"
 	"sort"
 	"time"
+
+	"github.com/metachris/go-ethutils/utils"
 )
 
 type ErrorSummary struct {
@@ -34,7 +37,10 @@ func (es *ErrorSummary) String() (ret string) {
 		if minerErrors.MinerName != "" {
 			minerId += fmt.Sprintf(" (%s)", minerErrors.MinerName)
 		}
-		ret += fmt.Sprintf("%-66s errorBlocks=%d \t failed0gas=%d \t failedFbTx=%d \t bundlePaysMore=%d \t bundleTooLowFee=%d \t has0fee=%d \t hasNegativeFee=%d\n", minerId, len(minerErrors.Blocks), minerErrors.ErrorCounts.Failed0GasTx, minerErrors.ErrorCounts.FailedFlashbotsTx, minerErrors.ErrorCounts.BundlePaysMoreThanPrevBundle, minerErrors.ErrorCounts.BundleHasLowerFeeThanLowestNonFbTx, minerErrors.ErrorCounts.BundleHas0Fee, minerErrors.ErrorCounts.BundleHasNegativeFee)
+		ret += fmt.Sprintf("%-66s errorBlocks=%d \t failed0gas=%d \t failedFbTx=%d \t bundlePaysMore=%d \t bundleTooLowFee=%d \t bundleTooLowPriorityFee=%d \t has0fee=%d \t hasNegativeFee=%d \t coinbaseTransferMismatch=%d \t megabundleOrder=%d \t bundleNotAtTop=%d \t relayPaymentMismatch=%d \t proposerPayment=%d \t builderKeptLargeShare=%d \t failedTxCost=%s ETH\n", minerId, len(minerErrors.Blocks), minerErrors.ErrorCounts.Failed0GasTx, minerErrors.ErrorCounts.FailedFlashbotsTx, minerErrors.ErrorCounts.BundlePaysMoreThanPrevBundle, minerErrors.ErrorCounts.BundleHasLowerFeeThanLowestNonFbTx, minerErrors.ErrorCounts.BundleHasLowerPriorityFeeThanLowestNonFbTx, minerErrors.ErrorCounts.BundleHas0Fee, minerErrors.ErrorCounts.BundleHasNegativeFee, minerErrors.ErrorCounts.CoinbaseTransferMismatch, minerErrors.ErrorCounts.MegabundleNotFirst+minerErrors.ErrorCounts.MegabundleNotContiguous, minerErrors.ErrorCounts.BundleNotAtTop, minerErrors.ErrorCounts.RelayPaymentMismatch, minerErrors.ErrorCounts.ProposerPaymentMissed+minerErrors.ErrorCounts.ProposerPaymentMisdirected, minerErrors.ErrorCounts.BuilderKeptLargeShare, utils.WeiBigIntToEthString(minerErrors.FailedTxCost, 4))
+	}
+	if cost := es.FailedTxCost(); cost.Sign() > 0 {
+		ret += fmt.Sprintf("ETH wasted on failed tx: %s ETH\n", utils.WeiBigIntToEthString(cost, 4))
 	}
 	return ret
 }
@@ -42,11 +48,10 @@ func (es *ErrorSummary) String() (ret string) {
 func (es *ErrorSummary) AddErrorCounts(MinerHash string, MinerName string, block int64, errors ErrorCounts) {
 	_, found := es.MinerErrors[MinerHash]
 	if !found {
-		es.MinerErrors[MinerHash] = &MinerErrors{
-			MinerHash: MinerHash,
-			MinerName: MinerName,
-			Blocks:    make(map[int64]bool),
-		}
+		minerErrors := NewMinerErrorCounter()
+		minerErrors.MinerHash = MinerHash
+		minerErrors.MinerName = MinerName
+		es.MinerErrors[MinerHash] = &minerErrors
 	}
 
 	es.MinerErrors[MinerHash].AddErrorCounts(block, errors)
@@ -57,6 +62,38 @@ func (es *ErrorSummary) AddErrorCounts(MinerHash string, MinerName string, block
 
 func (es *ErrorSummary) AddCheckErrors(check *BlockCheck) {
 	es.AddErrorCounts(check.Miner, check.MinerName, check.Number, check.ErrorCounter)
+	es.MinerErrors[check.Miner].AddFailedTxCost(check.Number, check.FailedTxCost())
+}
+
+// MinerFailedTxCost returns the gas burned by failed transactions in the blocks of the miner
+func (es *ErrorSummary) MinerFailedTxCost(miner string) *big.Int {
+	if minerErrors, found := es.MinerErrors[miner]; found {
+		return new(big.Int).Set(minerErrors.FailedTxCost)
+	}
+	return new(big.Int)
+}
+
+// FailedTxCost returns the gas burned by failed transactions in the blocks of all miners
+func (es *ErrorSummary) FailedTxCost() *big.Int {
+	sum := new(big.Int)
+	for _, minerErrors := range es.MinerErrors {
+		sum.Add(sum, minerErrors.FailedTxCost)
+	}
+	return sum
+}
+
+// RemoveCheckErrors removes the errors of a previously added check (eg. because the block was reorged)
+func (es *ErrorSummary) RemoveCheckErrors(check *BlockCheck) {
+	minerErrors, found := es.MinerErrors[check.Miner]
+	if !found {
+		return
+	}
+
+	minerErrors.RemoveErrorCounts(check.Number, check.ErrorCounter)
+	minerErrors.RemoveFailedTxCost(check.Number)
+	if len(minerErrors.Blocks) == 0 {
+		delete(es.MinerErrors, check.Miner)
+	}
 }
 
 func (es *ErrorSummary) Reset() {
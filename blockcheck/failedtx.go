@@ -1,11 +1,131 @@
 // Representation of a failed Flashbots or other 0-gas transaction (used in webserver)
 package blockcheck
 
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/metachris/flashbots/common"
+)
+
 // FailedTx contains information about a failed 0-gas or Flashbots tx
 type FailedTx struct {
-	Hash        string
-	IsFlashbots bool
-	From        string
-	To          string
-	Block       uint64
+	Hash         string
+	IsFlashbots  bool
+	From         string
+	To           string
+	Block        uint64
+	BundleHash   string // only set for Flashbots tx
+	GasUsed      uint64
+	GasCost      *big.Int // gasUsed * effective gas price, burned by the failed tx
+	RevertReason string   // only set if TraceRpcClient is set and the reason could be decoded
+}
+
+// newFailedTx fills in the gas cost of the failed tx, and the revert reason if traceRevertReason is set
+func newFailedTx(tx *types.Transaction, receipt *types.Receipt, baseFee *big.Int, traceRevertReason bool) *FailedTx {
+	failedTx := &FailedTx{
+		Hash:    tx.Hash().String(),
+		GasUsed: receipt.GasUsed,
+		GasCost: new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), common.TxEffectiveGasPrice(tx, baseFee)),
+	}
+	if traceRevertReason {
+		reason, err := TraceRevertReason(TraceRpcClient, tx.Hash())
+		if err != nil {
+			log.Debug("couldn't get revert reason", "tx", tx.Hash(), "err", err)
+		}
+		failedTx.RevertReason = reason
+	}
+	return failedTx
+}
+
+// traceRevertReasons returns true if the failed tx are traced for their revert reason (expensive, see CheckBlockFast)
+func (b *BlockCheck) traceRevertReasons() bool {
+	return TraceRpcClient != nil && !b.ShedExpensiveSteps
+}
+
+// Summary returns the burned gas cost and the revert reason, for alert messages
+func (tx *FailedTx) Summary() string {
+	ret := fmt.Sprintf("burned %s gas", common.FormatEth(tx.GasCost))
+	if reason := strings.TrimSpace(tx.RevertReason); reason != "" {
+		if len(reason) > 100 { // keep alerts readable with long revert strings
+			reason = reason[:100] + "..."
+		}
+		ret += ", reverted: " + reason
+	}
+	return ret
+}
+
+// TraceRevertReason traces the tx with the callTracer and decodes the revert reason from its output
+func TraceRevertReason(client *rpc.Client, txHash ethcommon.Hash) (string, error) {
+	var frame struct {
+		Error  string `json:"error"`
+		Output string `json:"output"`
+	}
+	tracerConfig := map[string]string{"tracer": "callTracer"}
+	err := client.CallContext(context.Background(), &frame, "debug_traceTransaction", txHash, tracerConfig)
+	if err != nil {
+		return "", fmt.Errorf("debug_traceTransaction error: %w", err)
+	}
+
+	if output, err := hexutil.Decode(frame.Output); err == nil && len(output) > 0 {
+		return DecodeRevertReason(output), nil
+	}
+	return frame.Error, nil // eg. "out of gas"
+}
+
+// Selectors of the Solidity revert errors
+var (
+	selectorError = []byte{0x08, 0xc3, 0x79, 0xa0} // Error(string)
+	selectorPanic = []byte{0x4e, 0x48, 0x7b, 0x71} // Panic(uint256)
+)
+
+// DecodeRevertReason decodes the return data of a reverted call: the message of Error(string), the code of
+// Panic(uint256), or the selector of a custom error. Returns an empty string for empty data.
+func DecodeRevertReason(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	if len(data) < 4 {
+		return hexutil.Encode(data)
+	}
+
+	selector, args := data[:4], data[4:]
+	switch {
+	case bytes.Equal(selector, selectorError) && len(args) >= 64:
+		offset := new(big.Int).SetBytes(args[:32])
+		if !offset.IsUint64() || offset.Uint64()+32 > uint64(len(args)) {
+			break
+		}
+		start := offset.Uint64() + 32
+		length := new(big.Int).SetBytes(args[offset.Uint64():start])
+		if !length.IsUint64() || start+length.Uint64() > uint64(len(args)) {
+			break
+		}
+		return string(args[start : start+length.Uint64()])
+
+	case bytes.Equal(selector, selectorPanic) && len(args) >= 32:
+		code := binary.BigEndian.Uint64(args[24:32])
+		return fmt.Sprintf("panic 0x%02x", code)
+	}
+
+	return "custom error " + hexutil.Encode(selector)
+}
+
+// FailedTxCost returns the gas cost burned by all failed transactions of the block
+func (b *BlockCheck) FailedTxCost() *big.Int {
+	sum := new(big.Int)
+	for _, tx := range b.FailedTx {
+		if tx.GasCost != nil {
+			sum.Add(sum, tx.GasCost)
+		}
+	}
+	return sum
 }
@@ -0,0 +1,67 @@
+package blockcheck
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestDecodeRevertReason(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected string
+	}{
+		{"0x", ""},
+		// Error("Too little received")
+		{"0x08c379a0" +
+			"0000000000000000000000000000000000000000000000000000000000000020" +
+			"0000000000000000000000000000000000000000000000000000000000000013" +
+			"546f6f206c6974746c6520726563656976656400000000000000000000000000", "Too little received"},
+		// Panic(0x11), arithmetic overflow
+		{"0x4e487b71" + "0000000000000000000000000000000000000000000000000000000000000011", "panic 0x11"},
+		// Custom error, eg. InsufficientOutput()
+		{"0x42301c23", "custom error 0x42301c23"},
+		// Error(string) with a broken length
+		{"0x08c379a0" +
+			"0000000000000000000000000000000000000000000000000000000000000020" +
+			"00000000000000000000000000000000000000000000000000000000000000ff", "custom error 0x08c379a0"},
+	}
+
+	for _, test := range tests {
+		if reason := DecodeRevertReason(hexutil.MustDecode(test.data)); reason != test.expected {
+			t.Errorf("%.20s: expected '%s', got '%s'", test.data, test.expected, reason)
+		}
+	}
+}
+
+func TestFailedTxCost(t *testing.T) {
+	check := newReportTestCheck(100, time.Now(), 0)
+	check.Miner = "0xMiner"
+	check.FailedTx = map[string]*FailedTx{
+		"0x1": {Hash: "0x1", GasCost: big.NewInt(2e15)},
+		"0x2": {Hash: "0x2", GasCost: big.NewInt(3e15)},
+	}
+	if cost := check.FailedTxCost(); cost.Cmp(big.NewInt(5e15)) != 0 {
+		t.Error("Unexpected failed tx cost:", cost)
+	}
+
+	report := NewReport(ReportDaily, time.Now())
+	report.AddCheck(check)
+	if report.FailedTxCost.Cmp(big.NewInt(5e15)) != 0 || report.FailedTxCostByMiner["0xMiner"].Cmp(big.NewInt(5e15)) != 0 {
+		t.Error("Unexpected report failed tx cost:", report.FailedTxCost, report.FailedTxCostByMiner)
+	}
+
+	// Counted once per block, and reverted on reorg
+	summary := NewErrorSummary()
+	summary.AddCheckErrors(check)
+	summary.AddCheckErrors(check)
+	if cost := summary.MinerFailedTxCost("0xMiner"); cost.Cmp(big.NewInt(5e15)) != 0 {
+		t.Error("Unexpected summary failed tx cost:", cost)
+	}
+	summary.RemoveCheckErrors(check)
+	if cost := summary.FailedTxCost(); cost.Sign() != 0 {
+		t.Error("Expected no failed tx cost after removing the block:", cost)
+	}
+}
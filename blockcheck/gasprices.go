@@ -0,0 +1,148 @@
+package blockcheck
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+// If > 0, bundles paying less than the reference tx only trigger alerts if their gas price is below this percentile
+// of the block's non-Flashbots transactions (eg. 10: only bundles in the lowest 10% of the block)
+var ThresholdBundleLowFeeMaxPercentile float64
+
+// Bundles have to pay at least this percentile of the gas prices of the non-Flashbots tx (bundle-fee check). 0 is
+// the lowest tx, 1, 5 or 10 the p1, p5 or p10, which ignore single tx with an odd low gas price.
+var ThresholdBundleFeeReferencePercentile float64 = 5
+
+// Valid values of ThresholdBundleFeeReferencePercentile
+var BundleFeeReferencePercentiles = []float64{0, 1, 5, 10}
+
+// Plain ETH transfers (without calldata) of less than this are dust, and not used as reference tx for the bundle fees
+var DustTransferMaxEth = 0.001
+
+// GasPriceDistribution of the transactions of a block (priority fees after London)
+type GasPriceDistribution struct {
+	Min    *big.Int
+	P25    *big.Int
+	Median *big.Int
+	P75    *big.Int
+	Max    *big.Int
+
+	prices []*big.Int // sorted ascending
+}
+
+// NewGasPriceDistribution returns the distribution of the gas prices, nil if there are none
+func NewGasPriceDistribution(prices []*big.Int) *GasPriceDistribution {
+	if len(prices) == 0 {
+		return nil
+	}
+
+	sorted := make([]*big.Int, len(prices))
+	copy(sorted, prices)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Cmp(sorted[j]) == -1 })
+
+	at := func(p int) *big.Int { return sorted[(len(sorted)-1)*p/100] }
+	return &GasPriceDistribution{
+		Min:    sorted[0],
+		P25:    at(25),
+		Median: at(50),
+		P75:    at(75),
+		Max:    sorted[len(sorted)-1],
+		prices: sorted,
+	}
+}
+
+// Percentile returns the percentage of transactions paying a lower gas price (0 for a nil distribution)
+func (d *GasPriceDistribution) Percentile(gasPrice *big.Int) float64 {
+	if d == nil {
+		return 0
+	}
+	lower := sort.Search(len(d.prices), func(i int) bool { return d.prices[i].Cmp(gasPrice) != -1 })
+	return float64(lower) / float64(len(d.prices)) * 100
+}
+
+func (d *GasPriceDistribution) String() string {
+	return fmt.Sprintf("min %s, 25p %s, median %s, 75p %s", common.FormatGwei(d.Min), common.FormatGwei(d.P25), common.FormatGwei(d.Median), common.FormatGwei(d.P75))
+}
+
+// gasPriceDistribution sets GasPrices from the non-Flashbots transactions of the block, without Flashbots-like tx (as
+// ReferenceGasPrice), if not yet done. Nil if there are none.
+func (b *BlockCheck) gasPriceDistribution() *GasPriceDistribution {
+	if b.GasPrices == nil {
+		baseFee := b.EthBlock.BaseFee()
+		prices := make([]*big.Int, 0, len(b.EthBlock.Transactions()))
+		for _, tx := range b.EthBlock.Transactions() {
+			if b.IsFlashbotsTx(tx.Hash().String()) || common.IsFlashbotsLikeTx(tx, b.EthBlock, b.CoinbaseTransfers) {
+				continue
+			}
+			prices = append(prices, common.TxPriorityFee(tx, baseFee))
+		}
+		b.GasPrices = NewGasPriceDistribution(prices)
+	}
+	return b.GasPrices
+}
+
+// txGasPrice is a tx with its gas price (priority fee after London)
+type txGasPrice struct {
+	hash     string
+	gasPrice *big.Int
+}
+
+// gasPriceAtPercentile returns the tx at the percentile of the gas prices (0 = the lowest), false if there are none
+func gasPriceAtPercentile(txs []txGasPrice, percentile float64) (txGasPrice, bool) {
+	if len(txs) == 0 {
+		return txGasPrice{}, false
+	}
+	sorted := make([]txGasPrice, len(txs))
+	copy(sorted, txs)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].gasPrice.Cmp(sorted[j].gasPrice) == -1 })
+	return sorted[int(float64(len(sorted)-1)*percentile/100)], true
+}
+
+// isDustOrSelfTransfer returns true for plain ETH transfers below DustTransferMaxEth, and tx to the sender itself
+// (eg. to cancel a pending tx)
+func isDustOrSelfTransfer(tx *types.Transaction, from ethcommon.Address) bool {
+	if tx.To() != nil && *tx.To() == from {
+		return true
+	}
+	if tx.To() == nil || len(tx.Data()) > 0 {
+		return false
+	}
+	return common.WeiToEthFloat64(tx.Value()) < DustTransferMaxEth
+}
+
+// ReferenceGasPrice returns the gas price (priority fee after London) which bundles have to pay at least: the one at
+// ThresholdBundleFeeReferencePercentile of the non-Flashbots tx, without Flashbots-like tx, dust and self-transfers.
+// Returns -1 if there are no such tx.
+func (b *BlockCheck) ReferenceGasPrice() (gasPrice *big.Int, txHash string) {
+	baseFee := b.EthBlock.BaseFee()
+	txs := make([]txGasPrice, 0, len(b.EthBlock.Transactions()))
+	for _, tx := range b.EthBlock.Transactions() {
+		if b.IsFlashbotsTx(tx.Hash().String()) || common.IsFlashbotsLikeTx(tx, b.EthBlock, b.CoinbaseTransfers) {
+			continue
+		}
+		if from, err := utils.GetTxSender(tx); err == nil && isDustOrSelfTransfer(tx, from) {
+			continue
+		}
+		txs = append(txs, txGasPrice{hash: tx.Hash().Hex(), gasPrice: common.TxPriorityFee(tx, baseFee)})
+	}
+
+	reference, ok := gasPriceAtPercentile(txs, ThresholdBundleFeeReferencePercentile)
+	if !ok {
+		return big.NewInt(-1), ""
+	}
+	return reference.gasPrice, reference.hash
+}
+
+// referenceTxName describes the reference tx of the bundle fees in alerts
+func referenceTxName() string {
+	if ThresholdBundleFeeReferencePercentile <= 0 {
+		return "lowest non-fb transaction"
+	}
+	return fmt.Sprintf("p%g non-fb transaction", ThresholdBundleFeeReferencePercentile)
+}
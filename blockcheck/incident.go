@@ -0,0 +1,93 @@
+package blockcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/metachris/flashbots/labels"
+)
+
+// Incident lists all transactions and addresses involved in the errors of a block, for block explorers or tracing tools
+type Incident struct {
+	BlockNumber int64    `json:"block_number"`
+	Miner       string   `json:"miner"`
+	MinerName   string   `json:"miner_name"`
+	Errors      []string `json:"errors"`
+	TxHashes    []string `json:"tx_hashes"`
+	Addresses   []string `json:"addresses"`
+}
+
+// Incident returns the tx hashes and addresses of failed transactions and of bundles with errors
+func (b *BlockCheck) Incident() *Incident {
+	incident := &Incident{
+		BlockNumber: b.Number,
+		Miner:       b.Miner,
+		MinerName:   b.MinerName,
+		TxHashes:    make([]string, 0),
+		Addresses:   make([]string, 0),
+	}
+
+	seen := make(map[string]bool)
+	addTx := func(hash string) {
+		if hash != "" && !seen[hash] {
+			incident.TxHashes = append(incident.TxHashes, hash)
+			seen[hash] = true
+		}
+	}
+	addAddress := func(address string) {
+		address = strings.ToLower(address)
+		if address != "" && !seen[address] {
+			incident.Addresses = append(incident.Addresses, address)
+			seen[address] = true
+		}
+	}
+
+	incident.Errors = b.ErrorMessages()
+
+	addAddress(b.Miner)
+	for _, failedTx := range b.FailedTx {
+		addTx(failedTx.Hash)
+		addAddress(labels.SearcherDisclosure.Address(failedTx.From))
+		addAddress(failedTx.To)
+	}
+
+	for _, bundle := range b.Bundles {
+		if !bundle.IsOutOfOrder && !bundle.IsPayingLessThanLowestTx && !bundle.Is0EffectiveGasPrice && !bundle.IsNegativeEffectiveGasPrice {
+			continue
+		}
+
+		for _, tx := range bundle.Transactions {
+			addTx(tx.Hash)
+			addAddress(labels.SearcherDisclosure.Address(tx.EoaAddress))
+			addAddress(tx.ToAddress)
+		}
+	}
+
+	return incident
+}
+
+// WriteFiles writes the incident as JSON (block-<number>.json) and as plain list of tx hashes (block-<number>-txs.txt)
+func (i *Incident) WriteFiles(dir string) (jsonFilename string, txFilename string, err error) {
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", "", err
+	}
+
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return "", "", err
+	}
+
+	jsonFilename = filepath.Join(dir, fmt.Sprintf("block-%d.json", i.BlockNumber))
+	err = os.WriteFile(jsonFilename, data, 0644)
+	if err != nil {
+		return "", "", err
+	}
+
+	txFilename = filepath.Join(dir, fmt.Sprintf("block-%d-txs.txt", i.BlockNumber))
+	err = os.WriteFile(txFilename, []byte(strings.Join(i.TxHashes, "\n")+"\n"), 0644)
+	return jsonFilename, txFilename, err
+}
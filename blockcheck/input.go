@@ -0,0 +1,149 @@
+package blockcheck
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+// CheckInput is the data a check got from the node and the Flashbots API, so the block can be checked again later
+// without them (see Replay)
+type CheckInput struct {
+	BlockNumber    int64               `json:"block_number"`
+	Block          string              `json:"block"`                     // RLP of the block with its transactions, hex
+	Receipts       []*types.Receipt    `json:"receipts"`                  // in tx order
+	FlashbotsBlock *api.FlashbotsBlock `json:"flashbots_block,omitempty"` // nil if the API didn't have the block
+}
+
+// NewCheckInput returns the inputs of the check
+func NewCheckInput(check *BlockCheck) (*CheckInput, error) {
+	block, err := rlp.EncodeToBytes(check.EthBlock)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding block %d: %w", check.Number, err)
+	}
+
+	input := &CheckInput{
+		BlockNumber:    check.Number,
+		Block:          hexutil.Encode(block),
+		Receipts:       make([]*types.Receipt, 0, len(check.EthBlock.Transactions())),
+		FlashbotsBlock: check.FlashbotsApiBlock,
+	}
+	for _, tx := range check.EthBlock.Transactions() {
+		if receipt := check.BlockWithTxReceipts.TxReceipts[tx.Hash()]; receipt != nil {
+			input.Receipts = append(input.Receipts, receipt)
+		}
+	}
+	return input, nil
+}
+
+// BlockWithTxReceipts decodes the block and its receipts
+func (i *CheckInput) BlockWithTxReceipts() (*blockswithtx.BlockWithTxReceipts, error) {
+	data, err := hexutil.Decode(i.Block)
+	if err != nil {
+		return nil, fmt.Errorf("block %d: %w", i.BlockNumber, err)
+	}
+	block := new(types.Block)
+	if err = rlp.DecodeBytes(data, block); err != nil {
+		return nil, fmt.Errorf("error decoding block %d: %w", i.BlockNumber, err)
+	}
+
+	receipts := make(map[ethcommon.Hash]*types.Receipt, len(i.Receipts))
+	for _, receipt := range i.Receipts {
+		receipts[receipt.TxHash] = receipt
+	}
+	return &blockswithtx.BlockWithTxReceipts{Block: block, TxReceipts: receipts}, nil
+}
+
+// Replay checks the block of the input again with the current checks and thresholds, without requests to the node,
+// the Flashbots API or the AddressLookup service (miner names only from the labels). Steps which need other services
+// (traces, simulations) only run if their clients are set.
+func Replay(input *CheckInput) (*BlockCheck, error) {
+	blockWithTx, err := input.BlockWithTxReceipts()
+	if err != nil {
+		return nil, err
+	}
+
+	if input.FlashbotsBlock != nil {
+		FlashbotsBlockCache[input.BlockNumber] = *input.FlashbotsBlock
+		defer delete(FlashbotsBlockCache, input.BlockNumber)
+	}
+	check, err := newBlockCheck(blockWithTx, true)
+	if err != nil {
+		return nil, err
+	}
+	if err = check.run(false); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// WriteFile saves the input as a JSON fixture (see LoadCheckInputs)
+func (i *CheckInput) WriteFile(path string) error {
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadCheckInputs reads check inputs from a JSON fixture (see WriteFile), a JSON lines file (eg. the inputs.jsonl
+// archive of watcher.JSONLSink), or a directory of .json fixtures. Returns them by block number.
+func LoadCheckInputs(path string) (inputs []*CheckInput, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		if files, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+			return nil, err
+		}
+	}
+	for _, file := range files {
+		fileInputs, err := readCheckInputs(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		inputs = append(inputs, fileInputs...)
+	}
+	sort.SliceStable(inputs, func(i, j int) bool { return inputs[i].BlockNumber < inputs[j].BlockNumber })
+	return inputs, nil
+}
+
+// readCheckInputs reads an indented JSON fixture, or one input per line
+func readCheckInputs(file string) (inputs []*CheckInput, err error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	input := new(CheckInput)
+	if err = json.Unmarshal(data, input); err == nil {
+		return []*CheckInput{input}, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		input := new(CheckInput)
+		if err := json.Unmarshal(scanner.Bytes(), input); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs, scanner.Err()
+}
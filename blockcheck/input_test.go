@@ -0,0 +1,97 @@
+package blockcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+func TestCheckInput(t *testing.T) {
+	to := ethcommon.HexToAddress("0x0000000000000000000000000000000000000001")
+	tx := types.NewTransaction(0, to, big.NewInt(1), 21000, big.NewInt(1e9), nil)
+	receipt := &types.Receipt{Status: 1, CumulativeGasUsed: 21000, GasUsed: 21000, TxHash: tx.Hash(), Logs: []*types.Log{}}
+	header := &types.Header{Number: big.NewInt(100), GasLimit: 30000000, Difficulty: big.NewInt(1), Coinbase: to}
+	block := types.NewBlock(header, []*types.Transaction{tx}, nil, []*types.Receipt{receipt}, trie.NewStackTrie(nil))
+
+	check := &BlockCheck{
+		Number:              100,
+		EthBlock:            block,
+		BlockWithTxReceipts: &blockswithtx.BlockWithTxReceipts{Block: block, TxReceipts: map[ethcommon.Hash]*types.Receipt{tx.Hash(): receipt}},
+		FlashbotsApiBlock:   &api.FlashbotsBlock{BlockNumber: 100},
+	}
+	input, err := NewCheckInput(check)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Archived as JSON
+	data, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(CheckInput)
+	if err = json.Unmarshal(data, decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	blockWithTx, err := decoded.BlockWithTxReceipts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blockWithTx.Block.Hash() != block.Hash() || len(blockWithTx.Block.Transactions()) != 1 {
+		t.Error("unexpected block", blockWithTx.Block.Hash(), block.Hash())
+	}
+	if r := blockWithTx.TxReceipts[tx.Hash()]; r == nil || r.GasUsed != 21000 || r.Status != 1 {
+		t.Error("unexpected receipt", r)
+	}
+	if decoded.FlashbotsBlock == nil || decoded.FlashbotsBlock.BlockNumber != 100 {
+		t.Error("expected the Flashbots block", decoded.FlashbotsBlock)
+	}
+}
+
+func TestLoadCheckInputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, number := range []int64{102, 101} {
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number), Difficulty: big.NewInt(1)})
+		check := &BlockCheck{Number: number, EthBlock: block, BlockWithTxReceipts: &blockswithtx.BlockWithTxReceipts{Block: block}}
+		input, err := NewCheckInput(check)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = input.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.json", number))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inputs, err := LoadCheckInputs(dir)
+	if err != nil || len(inputs) != 2 || inputs[0].BlockNumber != 101 || inputs[1].BlockNumber != 102 {
+		t.Fatal("unexpected fixtures", inputs, err)
+	}
+
+	// JSON lines, as archived by the JSONL sink
+	var lines []byte
+	for _, input := range []*CheckInput{inputs[1], inputs[0]} {
+		data, _ := json.Marshal(input)
+		lines = append(append(lines, data...), '\n')
+	}
+	file := filepath.Join(t.TempDir(), "inputs.jsonl")
+	if err = os.WriteFile(file, lines, 0644); err != nil {
+		t.Fatal(err)
+	}
+	inputs, err = LoadCheckInputs(file)
+	if err != nil || len(inputs) != 2 || inputs[0].BlockNumber != 101 {
+		t.Error("unexpected inputs", inputs, err)
+	}
+	if _, err = inputs[0].BlockWithTxReceipts(); err != nil {
+		t.Error(err)
+	}
+}
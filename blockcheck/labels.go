@@ -0,0 +1,51 @@
+package blockcheck
+
+import (
+	"fmt"
+
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/flashbots/labels"
+)
+
+// minerName returns the label of the miner (see labels.Default), or the name of the AddressLookup service
+func minerName(address string) string {
+	if name := labels.Default.Name(address); name != "" {
+		return name
+	}
+	if AddressLookup != nil {
+		if detail, found := AddressLookup.GetAddressDetail(address); found {
+			return detail.Name
+		}
+	}
+	return ""
+}
+
+// labelBundles sets the searcher name of the bundles with a labeled EOA (the first labeled tx sender), as disclosed by
+// labels.SearcherDisclosure (in pseudonym mode the cluster ID of the searcher, also for unlabeled EOAs)
+func (b *BlockCheck) labelBundles() {
+	disclosure := labels.SearcherDisclosure
+	for _, bundle := range b.Bundles {
+		searcher := ""
+		for _, tx := range bundle.Transactions {
+			if labels.Default.Name(tx.EoaAddress) != "" {
+				searcher = tx.EoaAddress
+				break
+			}
+		}
+		if searcher == "" && disclosure.Mode == labels.DisclosurePseudonym && len(bundle.Transactions) > 0 {
+			searcher = bundle.Transactions[0].EoaAddress
+		}
+		if searcher != "" {
+			bundle.SearcherName = disclosure.Searcher(searcher)
+		}
+	}
+}
+
+// searcherLink returns the searcher address as markdown link, or as disclosed by labels.SearcherDisclosure (without
+// link, which would reveal the address)
+func searcherLink(address string) string {
+	if labels.SearcherDisclosure.IsFull() {
+		return fmt.Sprintf("[%s](<%s>)", address, common.AddressUrl(address))
+	}
+	return labels.SearcherDisclosure.Address(address)
+}
@@ -0,0 +1,172 @@
+package blockcheck
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MinerLeaderboard tracks the checked blocks per miner, to compute error rates over sliding time windows
+// (eg. 1h, 24h, 7d). Blocks older than the largest window are dropped.
+type MinerLeaderboard struct {
+	MaxWindow time.Duration
+	entries   []leaderboardEntry
+}
+
+type leaderboardEntry struct {
+	Block     int64
+	Time      time.Time
+	Miner     string
+	MinerName string
+	HasErrors bool
+	Errors    ErrorCounts
+}
+
+// MinerWindowStats are the stats of a miner in a time window
+type MinerWindowStats struct {
+	Miner       string
+	MinerName   string
+	Blocks      uint64
+	ErrorBlocks uint64
+	ErrorCounts ErrorCounts
+}
+
+// ErrorRate returns the share of blocks with errors
+func (s *MinerWindowStats) ErrorRate() float64 {
+	if s.Blocks == 0 {
+		return 0
+	}
+	return float64(s.ErrorBlocks) / float64(s.Blocks)
+}
+
+// LeaderboardWindows are the time windows of the error summary (see MinerLeaderboard.Summary)
+var LeaderboardWindows = []time.Duration{time.Hour, 24 * time.Hour, 7 * 24 * time.Hour}
+
+func NewMinerLeaderboard(maxWindow time.Duration) *MinerLeaderboard {
+	return &MinerLeaderboard{
+		MaxWindow: maxWindow,
+		entries:   make([]leaderboardEntry, 0),
+	}
+}
+
+// AddCheck adds a checked block (should be called for every block, not only blocks with errors)
+func (l *MinerLeaderboard) AddCheck(check *BlockCheck) {
+	entry := leaderboardEntry{
+		Block:     check.Number,
+		Time:      time.Unix(int64(check.EthBlock.Time()), 0),
+		Miner:     check.Miner,
+		MinerName: check.MinerName,
+		HasErrors: check.HasSeriousErrors() || check.HasLessSeriousErrors(),
+		Errors:    check.ErrorCounter,
+	}
+
+	// Keep the entries sorted by time (blocks are not always checked in order)
+	i := sort.Search(len(l.entries), func(i int) bool { return l.entries[i].Time.After(entry.Time) })
+	l.entries = append(l.entries, leaderboardEntry{})
+	copy(l.entries[i+1:], l.entries[i:])
+	l.entries[i] = entry
+
+	l.prune(time.Now())
+}
+
+// RemoveCheck removes a previously added block (eg. before adding its complete re-check)
+func (l *MinerLeaderboard) RemoveCheck(check *BlockCheck) {
+	for i, entry := range l.entries {
+		if entry.Block == check.Number && entry.Miner == check.Miner {
+			l.entries = append(l.entries[:i], l.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+func (l *MinerLeaderboard) prune(now time.Time) {
+	i := sort.Search(len(l.entries), func(i int) bool { return now.Sub(l.entries[i].Time) <= l.MaxWindow })
+	l.entries = l.entries[i:]
+}
+
+// Stats returns the stats per miner for the blocks in the time window before now, sorted by error rate
+func (l *MinerLeaderboard) Stats(window time.Duration, now time.Time) (stats []*MinerWindowStats) {
+	statsByMiner := make(map[string]*MinerWindowStats)
+	for _, entry := range l.entries {
+		if now.Sub(entry.Time) > window {
+			continue
+		}
+
+		minerStats, found := statsByMiner[entry.Miner]
+		if !found {
+			minerStats = &MinerWindowStats{Miner: entry.Miner, MinerName: entry.MinerName}
+			statsByMiner[entry.Miner] = minerStats
+			stats = append(stats, minerStats)
+		}
+
+		minerStats.Blocks += 1
+		if entry.HasErrors {
+			minerStats.ErrorBlocks += 1
+			minerStats.ErrorCounts.Add(entry.Errors)
+		}
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].ErrorRate() == stats[j].ErrorRate() {
+			return stats[i].ErrorBlocks > stats[j].ErrorBlocks
+		}
+		return stats[i].ErrorRate() > stats[j].ErrorRate()
+	})
+	return stats
+}
+
+// ErrorBlocks returns the number of blocks with errors, and of all blocks, of all miners in the time window before now
+func (l *MinerLeaderboard) ErrorBlocks(window time.Duration, now time.Time) (errorBlocks uint64, blocks uint64) {
+	for _, entry := range l.entries {
+		if now.Sub(entry.Time) > window {
+			continue
+		}
+		blocks += 1
+		if entry.HasErrors {
+			errorBlocks += 1
+		}
+	}
+	return errorBlocks, blocks
+}
+
+// Summary returns the error blocks of all miners in each of the LeaderboardWindows, eg. "error blocks 1h: 2/300
+// (0.67%), 24h: ..."
+func (l *MinerLeaderboard) Summary(now time.Time) string {
+	ret := "error blocks"
+	for i, window := range LeaderboardWindows {
+		errorBlocks, blocks := l.ErrorBlocks(window, now)
+		rate := 0.0
+		if blocks > 0 {
+			rate = float64(errorBlocks) / float64(blocks) * 100
+		}
+		if i > 0 {
+			ret += ","
+		}
+		ret += fmt.Sprintf(" %s: %d/%d (%.2f%%)", formatWindow(window), errorBlocks, blocks, rate)
+	}
+	return ret
+}
+
+// formatWindow returns the window in hours, or in days if it's a multiple of days (eg. 1h, 24h, 7d)
+func formatWindow(window time.Duration) string {
+	if window > 24*time.Hour && window%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", window/(24*time.Hour))
+	}
+	return fmt.Sprintf("%dh", window/time.Hour)
+}
+
+// String returns the leaderboard of the miners with errors in the time window
+func (l *MinerLeaderboard) String(window time.Duration, now time.Time) (ret string) {
+	for i, s := range l.Stats(window, now) {
+		if s.ErrorBlocks == 0 {
+			break
+		}
+
+		minerId := s.Miner
+		if s.MinerName != "" {
+			minerId += fmt.Sprintf(" (%s)", s.MinerName)
+		}
+		ret += fmt.Sprintf("%2d. %-66s errorRate=%6.2f%% \t errorBlocks=%d/%d \t errorTypes=%v\n", i+1, minerId, s.ErrorRate()*100, s.ErrorBlocks, s.Blocks, s.ErrorCounts.Types())
+	}
+	return ret
+}
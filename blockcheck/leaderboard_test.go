@@ -0,0 +1,31 @@
+package blockcheck
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestMinerLeaderboardSummary(t *testing.T) {
+	now := time.Now()
+	leaderboard := NewMinerLeaderboard(7 * 24 * time.Hour)
+	add := func(number int64, age time.Duration, hasErrors bool) {
+		check := &BlockCheck{Number: number, Miner: "0xa", ManualHasSeriousError: hasErrors}
+		check.EthBlock = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number), Time: uint64(now.Add(-age).Unix())})
+		leaderboard.AddCheck(check)
+	}
+	add(1, 3*24*time.Hour, true)
+	add(2, 2*time.Hour, true)
+	add(3, 2*time.Hour, false)
+	add(4, time.Minute, true)
+
+	if errorBlocks, blocks := leaderboard.ErrorBlocks(time.Hour, now); errorBlocks != 1 || blocks != 1 {
+		t.Error("unexpected 1h error blocks", errorBlocks, blocks)
+	}
+	expected := "error blocks 1h: 1/1 (100.00%), 24h: 2/3 (66.67%), 7d: 3/4 (75.00%)"
+	if summary := leaderboard.Summary(now); summary != expected {
+		t.Errorf("unexpected summary: %s, expected %s", summary, expected)
+	}
+}
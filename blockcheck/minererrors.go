@@ -1,16 +1,23 @@
 package blockcheck
 
+import "math/big"
+
 type MinerErrors struct {
 	MinerHash string
 	MinerName string
 
 	Blocks      map[int64]bool // To avoid counting errors / blocks twice
 	ErrorCounts ErrorCounts
+
+	FailedTxCost      *big.Int           // gas burned by failed transactions
+	failedTxCostBlock map[int64]*big.Int // by block, to revert on reorg
 }
 
 func NewMinerErrorCounter() MinerErrors {
 	return MinerErrors{
-		Blocks: make(map[int64]bool),
+		Blocks:            make(map[int64]bool),
+		FailedTxCost:      new(big.Int),
+		failedTxCostBlock: make(map[int64]*big.Int),
 	}
 }
 
@@ -18,3 +25,29 @@ func (ec *MinerErrors) AddErrorCounts(block int64, counts ErrorCounts) {
 	ec.ErrorCounts.Add(counts)
 	ec.Blocks[block] = true
 }
+
+// RemoveErrorCounts reverts AddErrorCounts, eg. if the block was reorged
+func (ec *MinerErrors) RemoveErrorCounts(block int64, counts ErrorCounts) {
+	if !ec.Blocks[block] {
+		return
+	}
+	ec.ErrorCounts.Sub(counts)
+	delete(ec.Blocks, block)
+}
+
+// AddFailedTxCost adds the gas burned by the failed transactions of a block (only once per block)
+func (ec *MinerErrors) AddFailedTxCost(block int64, cost *big.Int) {
+	if _, found := ec.failedTxCostBlock[block]; found || cost.Sign() == 0 {
+		return
+	}
+	ec.failedTxCostBlock[block] = cost
+	ec.FailedTxCost.Add(ec.FailedTxCost, cost)
+}
+
+// RemoveFailedTxCost reverts AddFailedTxCost
+func (ec *MinerErrors) RemoveFailedTxCost(block int64) {
+	if cost, found := ec.failedTxCostBlock[block]; found {
+		ec.FailedTxCost.Sub(ec.FailedTxCost, cost)
+		delete(ec.failedTxCostBlock, block)
+	}
+}
@@ -0,0 +1,130 @@
+package blockcheck
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	flashbotsrpc "github.com/metachris/flashbots-rpc"
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/common"
+)
+
+// Name of the step which simulates blocks with bundle order errors with the correct order (see SimulationRpc)
+const StepSimulateBundleOrder = "simulate-bundle-order"
+
+// If set, CheckBlock re-simulates blocks with bundle order errors with the bundles in the correct order (mev-geth
+// eth_callBundle), and sets OrderingCost to the additional revenue the miner would have earned
+var SimulationRpc *flashbotsrpc.FlashbotsRPC
+
+// Key to sign the eth_callBundle requests (Flashbots signature header), not used for anything else. It's created
+// once, on the first simulation (checks can run concurrently).
+var (
+	simulationKey     *ecdsa.PrivateKey
+	simulationKeyErr  error
+	simulationKeyOnce sync.Once
+)
+
+// reorderedTxIndexes returns the tx indexes of the block in the correct bundle order: megabundle transactions first,
+// then the regular bundles sorted by miner reward per gas (highest first), then all other transactions in their
+// original order.
+func reorderedTxIndexes(bundles []*common.Bundle, numTx int) []int {
+	var megabundles, regular []*common.Bundle
+	for _, bundle := range bundles {
+		if bundle.IsMegabundle() {
+			megabundles = append(megabundles, bundle)
+		} else {
+			regular = append(regular, bundle)
+		}
+	}
+	sort.SliceStable(regular, func(i, j int) bool {
+		return regular[i].RewardDivGasUsed.Cmp(regular[j].RewardDivGasUsed) == 1
+	})
+
+	indexes := make([]int, 0, numTx)
+	isBundleTx := make(map[int]bool)
+	for _, bundle := range append(megabundles, regular...) {
+		txs := make([]api.FlashbotsTransaction, len(bundle.Transactions))
+		copy(txs, bundle.Transactions)
+		sort.SliceStable(txs, func(i, j int) bool { return txs[i].TxIndex < txs[j].TxIndex })
+		for _, tx := range txs {
+			if tx.TxIndex < 0 || int(tx.TxIndex) >= numTx || isBundleTx[int(tx.TxIndex)] {
+				continue
+			}
+			indexes = append(indexes, int(tx.TxIndex))
+			isBundleTx[int(tx.TxIndex)] = true
+		}
+	}
+
+	for i := 0; i < numTx; i++ {
+		if !isBundleTx[i] {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// ReorderedBlock returns the block with the bundles in the correct order (see reorderedTxIndexes)
+func (b *BlockCheck) ReorderedBlock() *types.Block {
+	txs := b.EthBlock.Transactions()
+	reordered := make(types.Transactions, 0, len(txs))
+	for _, i := range reorderedTxIndexes(b.Bundles, len(txs)) {
+		reordered = append(reordered, txs[i])
+	}
+	return types.NewBlockWithHeader(b.EthBlock.Header()).WithBody(reordered, b.EthBlock.Uncles())
+}
+
+// simulateBlock simulates the transactions of the block on top of the parent block (eth_callBundle)
+func simulateBlock(block *types.Block) (result flashbotsrpc.FlashbotsCallBundleResponse, err error) {
+	simulationKeyOnce.Do(func() {
+		simulationKey, simulationKeyErr = crypto.GenerateKey()
+	})
+	if simulationKeyErr != nil {
+		return result, simulationKeyErr
+	}
+
+	result, err = SimulationRpc.FlashbotsSimulateBlock(simulationKey, block, 0)
+	if err != nil {
+		return result, fmt.Errorf("eth_callBundle error: %w", err)
+	}
+	return result, nil
+}
+
+// simulateCoinbaseDiff returns the miner revenue of the block, simulated on top of the parent block
+func simulateCoinbaseDiff(block *types.Block) (*big.Int, error) {
+	result, err := simulateBlock(block)
+	if err != nil {
+		return nil, err
+	}
+	coinbaseDiff, ok := new(big.Int).SetString(result.CoinbaseDiff, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid coinbaseDiff '%s'", result.CoinbaseDiff)
+	}
+	return coinbaseDiff, nil
+}
+
+// simulateBundleOrder simulates blocks with bundle order errors as mined and with the correct bundle order, and sets
+// OrderingCost. Simulation errors are only logged, the figure is optional.
+func (b *BlockCheck) simulateBundleOrder() error {
+	if b.ErrorCounter.BundlePaysMoreThanPrevBundle == 0 {
+		return nil
+	}
+
+	minedRevenue, err := simulateCoinbaseDiff(b.EthBlock)
+	if err != nil {
+		log.Warn("simulation of the mined block failed", "block", b.Number, "err", err)
+		return nil
+	}
+	reorderedRevenue, err := simulateCoinbaseDiff(b.ReorderedBlock())
+	if err != nil {
+		log.Warn("simulation of the reordered block failed", "block", b.Number, "err", err)
+		return nil
+	}
+
+	b.OrderingCost = new(big.Int).Sub(reorderedRevenue, minedRevenue)
+	return nil
+}
@@ -0,0 +1,159 @@
+package blockcheck
+
+import (
+	"container/list"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/flashbots/labels"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+// Senders of the Flashbots transactions seen in the public API, the least recently seen are evicted
+var KnownPublicSenders = NewSenderSet(100000)
+
+// SenderSet is a set of addresses, bounded like api.Cache: beyond MaxEntries, the least recently added addresses are
+// evicted. It's safe for concurrent use.
+type SenderSet struct {
+	MaxEntries int // 0 = unlimited
+
+	lock    sync.Mutex
+	lru     *list.List // front = most recently added
+	entries map[string]*list.Element
+}
+
+func NewSenderSet(maxEntries int) *SenderSet {
+	return &SenderSet{
+		MaxEntries: maxEntries,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Add adds the address, or marks it as recently added
+func (s *SenderSet) Add(address string) {
+	address = strings.ToLower(address)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if el, ok := s.entries[address]; ok {
+		s.lru.MoveToFront(el)
+		return
+	}
+
+	s.entries[address] = s.lru.PushFront(address)
+	for s.MaxEntries > 0 && s.lru.Len() > s.MaxEntries {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(string))
+	}
+}
+
+// Contains returns true if the address was added and not evicted since
+func (s *SenderSet) Contains(address string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.entries[strings.ToLower(address)]
+	return ok
+}
+
+// Len returns the number of addresses
+func (s *SenderSet) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.lru.Len()
+}
+
+// PrivateOrderFlowBundle is a group of consecutive transactions which are not in the public API, pay only via coinbase
+// transfer (0 priority fee for all tx), and whose senders were never seen in the public API. These are possibly
+// private order flow deals with the miner, tagged for research tracking (informational, not counted as error).
+type PrivateOrderFlowBundle struct {
+	StartIndex       int
+	TxHashes         []string
+	Senders          []string
+	CoinbaseTransfer *big.Int
+	IsTraced         bool // CoinbaseTransfer includes internal transfers (else only direct tx value transfers)
+}
+
+func (p *PrivateOrderFlowBundle) String() string {
+	return fmt.Sprintf("possible private order flow: %d tx at index %d, coinbase transfer: %s, senders: %s", len(p.TxHashes), p.StartIndex, common.FormatEth(p.CoinbaseTransfer), strings.Join(p.DisclosedSenders(), ", "))
+}
+
+// DisclosedSenders returns the senders as disclosed by labels.SearcherDisclosure
+func (p *PrivateOrderFlowBundle) DisclosedSenders() []string {
+	ret := make([]string, 0, len(p.Senders))
+	for _, address := range p.Senders {
+		ret = append(ret, labels.SearcherDisclosure.Address(address))
+	}
+	return ret
+}
+
+func (b *BlockCheck) addKnownPublicSenders() {
+	for _, tx := range b.FlashbotsTransactions {
+		KnownPublicSenders.Add(tx.EoaAddress)
+	}
+}
+
+// checkPrivateOrderFlow finds the groups of 0-priority-fee tx outside of the public bundles which pay the miner via
+// coinbase transfers. transfers are the traced coinbase transfers by tx hash, or nil if the block wasn't traced.
+func (b *BlockCheck) checkPrivateOrderFlow(transfers map[ethcommon.Hash]*big.Int) {
+	publicTxs := make(map[string]bool)
+	for _, tx := range b.FlashbotsTransactions {
+		publicTxs[strings.ToLower(tx.Hash)] = true
+	}
+
+	var current *PrivateOrderFlowBundle
+	hasUnknownSendersOnly := true
+	finishGroup := func() {
+		if current != nil && current.CoinbaseTransfer.Sign() == 1 && hasUnknownSendersOnly {
+			b.PrivateOrderFlowBundles = append(b.PrivateOrderFlowBundles, current)
+		}
+		current = nil
+		hasUnknownSendersOnly = true
+	}
+
+	baseFee := b.EthBlock.BaseFee()
+	for i, tx := range b.EthBlock.Transactions() {
+		if publicTxs[strings.ToLower(tx.Hash().Hex())] || common.TxPriorityFee(tx, baseFee).Sign() != 0 {
+			finishGroup()
+			continue
+		}
+
+		if current == nil {
+			current = &PrivateOrderFlowBundle{StartIndex: i, CoinbaseTransfer: new(big.Int), IsTraced: transfers != nil}
+		}
+
+		current.TxHashes = append(current.TxHashes, tx.Hash().Hex())
+		current.CoinbaseTransfer.Add(current.CoinbaseTransfer, b.txCoinbaseTransfer(tx, transfers))
+
+		sender, err := utils.GetTxSender(tx)
+		if err != nil {
+			continue
+		}
+		if KnownPublicSenders.Contains(sender.Hex()) {
+			hasUnknownSendersOnly = false
+		}
+		current.Senders = append(current.Senders, sender.Hex())
+	}
+	finishGroup()
+}
+
+func (b *BlockCheck) txCoinbaseTransfer(tx *types.Transaction, transfers map[ethcommon.Hash]*big.Int) *big.Int {
+	if transfers != nil {
+		if transfer, found := transfers[tx.Hash()]; found {
+			return transfer
+		}
+		return new(big.Int)
+	}
+
+	// Not traced: only direct transfers of successful tx
+	if receipt := b.BlockWithTxReceipts.TxReceipts[tx.Hash()]; receipt != nil && receipt.Status == 1 && common.IsCoinbaseTransferTx(tx, b.EthBlock, nil) {
+		return tx.Value()
+	}
+	return new(big.Int)
+}
@@ -0,0 +1,37 @@
+package blockcheck
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/metachris/flashbots/labels"
+)
+
+func TestSenderSet(t *testing.T) {
+	s := NewSenderSet(2)
+	s.Add("0xAAAA")
+	s.Add("0xbbbb")
+	s.Add("0xaaaa") // most recently added again
+	s.Add("0xcccc") // evicts 0xbbbb
+
+	if s.Len() != 2 {
+		t.Fatal("expected 2 addresses, got", s.Len())
+	}
+	for address, expected := range map[string]bool{"0xaaaa": true, "0xAAAA": true, "0xbbbb": false, "0xCCCC": true} {
+		if s.Contains(address) != expected {
+			t.Errorf("%s: expected Contains %v", address, expected)
+		}
+	}
+}
+
+func TestPrivateOrderFlowDisclosure(t *testing.T) {
+	defer func(d *labels.Disclosure) { labels.SearcherDisclosure = d }(labels.SearcherDisclosure)
+	labels.SearcherDisclosure = labels.NewDisclosure(labels.DisclosurePseudonym, "secret", labels.Default)
+
+	sender := "0x1111111111111111111111111111111111111111"
+	p := &PrivateOrderFlowBundle{TxHashes: []string{"0x01"}, Senders: []string{sender}, CoinbaseTransfer: big.NewInt(1e18)}
+	if s := p.String(); strings.Contains(strings.ToLower(s), sender) || !strings.Contains(s, "searcher-") {
+		t.Error("Expected the sender to be redacted:", s)
+	}
+}
@@ -0,0 +1,158 @@
+package blockcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+// If set (with RelayClients), CheckBlock audits the proposer payment of post-merge blocks: the proposer of the slot
+// comes from the beacon node, its fee recipient from the validator registrations at the relays
+var BeaconClient *api.BeaconClient
+
+// ProposerAudit is the proposer of a post-merge block, where it asked to be paid, and what it was paid
+type ProposerAudit struct {
+	Slot           int64
+	ProposerIndex  string
+	ProposerPubkey string
+	FeeRecipient   string   // registered at the relays, or of the delivered payload if the validator isn't registered
+	Registered     bool     // FeeRecipient is from a validator registration
+	Relays         []string // relays which delivered the payload
+	BidValue       *big.Int // highest value of the delivered payloads, nil if no relay delivered it
+	Payment        *big.Int // on-chain payment to FeeRecipient
+}
+
+func (a *ProposerAudit) String() string {
+	bid := "-"
+	if a.BidValue != nil {
+		bid = common.FormatEth(a.BidValue)
+	}
+	return fmt.Sprintf("slot %d, proposer %s (%.18s...), fee recipient %s, relays %v, bid %s, payment %s", a.Slot, a.ProposerIndex, a.ProposerPubkey, a.FeeRecipient, a.Relays, bid, common.FormatEth(a.Payment))
+}
+
+// auditProposerPayment cross-references the proposer's fee recipient with the delivered payloads of the relays and
+// the payment in the block. A serious error is added if a relay delivered the payload with another fee recipient
+// (misdirected), or if the block was built by someone else (coinbase is not the fee recipient) and the fee recipient
+// received nothing (missed). Pre-merge blocks are skipped, and blocks for which the beacon node is unavailable (the
+// audit is logged as unavailable, ProposerAudit stays nil).
+func (b *BlockCheck) auditProposerPayment(transfers map[ethcommon.Hash]*big.Int) {
+	if b.EthBlock.Difficulty().Sign() != 0 {
+		return
+	}
+
+	ctx := context.Background()
+	audit, err := b.proposerAudit(ctx)
+	if err != nil {
+		log.Warn("proposer audit unavailable, beacon node error", "block", b.Number, "err", err)
+		return
+	}
+	if audit == nil {
+		return
+	}
+
+	// The fee recipient of the validator registration is authoritative
+	var bids []*RelayBid
+	for _, relay := range RelayClients {
+		delivered, err := relay.GetProposerPayloadsDelivered(ctx, &api.GetBidTracesOptions{Slot: audit.Slot})
+		if err != nil {
+			continue // the relay-payment check reports unreachable relays
+		}
+		for _, bid := range delivered {
+			if strings.EqualFold(bid.BlockHash, b.EthBlock.Hash().Hex()) {
+				bids = append(bids, &RelayBid{Relay: relay.Name, Bid: bid})
+			}
+		}
+	}
+	for _, bid := range bids {
+		if audit.FeeRecipient == "" {
+			audit.FeeRecipient = bid.Bid.ProposerFeeRecipient
+		}
+		audit.Relays = append(audit.Relays, bid.Relay)
+		if value := common.StrToBigInt(bid.Bid.Value); audit.BidValue == nil || value.Cmp(audit.BidValue) == 1 {
+			audit.BidValue = value
+		}
+	}
+	if audit.FeeRecipient == "" {
+		return // neither registered nor delivered by a relay, the fee recipient is unknown
+	}
+
+	feeRecipient := ethcommon.HexToAddress(audit.FeeRecipient)
+	audit.Payment = b.ProposerPayment(feeRecipient, transfers)
+	b.ProposerAudit = audit
+
+	for _, bid := range bids {
+		if !strings.EqualFold(bid.Bid.ProposerFeeRecipient, audit.FeeRecipient) {
+			msg := fmt.Sprintf("misdirected proposer payment in slot %d: relay %s delivered the payload with fee recipient [%s](<%s>), but the proposer registered [%s](<%s>)\n", audit.Slot, bid.Relay, bid.Bid.ProposerFeeRecipient, common.AddressUrl(bid.Bid.ProposerFeeRecipient), audit.FeeRecipient, common.AddressUrl(audit.FeeRecipient))
+			b.addError(&CheckError{Check: CheckProposerPayment, Kind: ErrorProposerPaymentMisdirected, Severity: SeveritySerious, BundleIndex: -1, Message: msg})
+			b.ErrorCounter.ProposerPaymentMisdirected += 1
+		}
+	}
+
+	if b.EthBlock.Coinbase() != feeRecipient && audit.Payment.Sign() == 0 {
+		paid := ""
+		if to, value := b.builderPayment(); to != nil {
+			paid = fmt.Sprintf(", the builder paid %s to [%s](<%s>)", common.FormatEth(value), to.Hex(), common.AddressUrl(to.Hex()))
+		}
+		msg := fmt.Sprintf("missed proposer payment in slot %d: fee recipient [%s](<%s>) of proposer %s received nothing from builder [%s](<%s>)%s\n", audit.Slot, audit.FeeRecipient, common.AddressUrl(audit.FeeRecipient), audit.ProposerIndex, b.EthBlock.Coinbase().Hex(), common.AddressUrl(b.EthBlock.Coinbase().Hex()), paid)
+		b.addError(&CheckError{Check: CheckProposerPayment, Kind: ErrorProposerPaymentMissed, Severity: SeveritySerious, BundleIndex: -1, Message: msg})
+		b.ErrorCounter.ProposerPaymentMissed += 1
+	}
+}
+
+// proposerAudit returns the slot and proposer of the block from the beacon node, with the fee recipient of the first
+// relay which has a validator registration. Returns nil if the beacon node has another block in the slot (reorg).
+func (b *BlockCheck) proposerAudit(ctx context.Context) (*ProposerAudit, error) {
+	slot, err := BeaconClient.SlotAt(ctx, b.EthBlock.Time())
+	if err != nil {
+		return nil, err
+	}
+	beaconBlock, err := BeaconClient.GetBlock(ctx, slot)
+	if err != nil {
+		return nil, fmt.Errorf("beacon block of slot %d: %w", slot, err)
+	}
+	if !strings.EqualFold(beaconBlock.BlockHash, b.EthBlock.Hash().Hex()) {
+		return nil, nil
+	}
+
+	audit := &ProposerAudit{Slot: slot, ProposerIndex: beaconBlock.ProposerIndex}
+	audit.ProposerPubkey, err = BeaconClient.GetValidatorPubkey(ctx, beaconBlock.ProposerIndex)
+	if err != nil {
+		return nil, fmt.Errorf("proposer %s of slot %d: %w", beaconBlock.ProposerIndex, slot, err)
+	}
+
+	for _, relay := range RelayClients {
+		registration, err := relay.GetValidatorRegistration(ctx, audit.ProposerPubkey)
+		if err == nil && registration.Message.FeeRecipient != "" {
+			audit.FeeRecipient = registration.Message.FeeRecipient
+			audit.Registered = true
+			break
+		} else if err != nil && !errors.Is(err, api.ErrNotFound) && !errors.Is(err, api.ErrClientError) {
+			return nil, err
+		}
+	}
+	return audit, nil
+}
+
+// builderPayment returns the recipient and value of the last tx if it's a transfer from the coinbase (the builder's
+// payment to the proposer), else nil
+func (b *BlockCheck) builderPayment() (*ethcommon.Address, *big.Int) {
+	txs := b.EthBlock.Transactions()
+	if len(txs) == 0 {
+		return nil, nil
+	}
+	lastTx := txs[len(txs)-1]
+	if lastTx.To() == nil || lastTx.Value().Sign() == 0 {
+		return nil, nil
+	}
+	if sender, err := utils.GetTxSender(lastTx); err != nil || sender != b.EthBlock.Coinbase() {
+		return nil, nil
+	}
+	return lastTx.To(), lastTx.Value()
+}
@@ -0,0 +1,111 @@
+package blockcheck
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+// newProposerAuditCheck returns a post-merge block of slot 100 (genesis 1000) whose last tx is the builder's payment
+// of 1 ETH to the recipient
+func newProposerAuditCheck(t *testing.T, recipient ethcommon.Address) *BlockCheck {
+	key, err := crypto.ToECDSA(ethcommon.LeftPadBytes([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder := crypto.PubkeyToAddress(key.PublicKey)
+	tx, err := types.SignTx(types.NewTransaction(0, recipient, gwei(1e9), 21000, gwei(1), nil), types.HomesteadSigner{}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: 21000, GasUsed: 21000, TxHash: tx.Hash(), Logs: []*types.Log{}}
+
+	header := &types.Header{Number: big.NewInt(15537940), GasLimit: 30000000, Difficulty: big.NewInt(0), BaseFee: gwei(1), Coinbase: builder, Time: 1000 + 100*12}
+	block := types.NewBlock(header, []*types.Transaction{tx}, nil, []*types.Receipt{receipt}, trie.NewStackTrie(nil))
+	return &BlockCheck{
+		Number:              15537940,
+		EthBlock:            block,
+		BlockWithTxReceipts: &blockswithtx.BlockWithTxReceipts{Block: block, TxReceipts: map[ethcommon.Hash]*types.Receipt{tx.Hash(): receipt}},
+	}
+}
+
+// testBeaconAndRelay serves the beacon node and relay endpoints of the audit, with the fee recipient of the proposer's
+// registration and of the delivered payload
+func testBeaconAndRelay(blockHash string, registered string, delivered string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/eth/v1/beacon/genesis":
+			fmt.Fprint(w, `{"data": {"genesis_time": "1000"}}`)
+		case "/eth/v2/beacon/blocks/100":
+			fmt.Fprintf(w, `{"data": {"message": {"slot": "100", "proposer_index": "7", "body": {"execution_payload": {"block_number": "15537940", "block_hash": "%s"}}}}}`, blockHash)
+		case "/eth/v1/beacon/states/head/validators/7":
+			fmt.Fprint(w, `{"data": {"validator": {"pubkey": "0xpk"}}}`)
+		case "/relay/v1/data/validator_registration":
+			fmt.Fprintf(w, `{"message": {"fee_recipient": "%s", "pubkey": "0xpk"}}`, registered)
+		case "/relay/v1/data/bidtraces/proposer_payload_delivered":
+			fmt.Fprintf(w, `[{"slot": "100", "block_hash": "%s", "proposer_pubkey": "0xpk", "proposer_fee_recipient": "%s", "value": "1000000000000000000"}]`, blockHash, delivered)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestAuditProposerPayment(t *testing.T) {
+	defer func() { BeaconClient, RelayClients = nil, nil }()
+	feeRecipient := ethcommon.HexToAddress("0x00000000000000000000000000000000000fee01")
+	other := ethcommon.HexToAddress("0x00000000000000000000000000000000000bad01")
+
+	// Paid to the registered fee recipient
+	check := newProposerAuditCheck(t, feeRecipient)
+	server := testBeaconAndRelay(check.EthBlock.Hash().Hex(), feeRecipient.Hex(), feeRecipient.Hex())
+	BeaconClient = api.NewBeaconClient(server.URL)
+	RelayClients = []*api.RelayClient{api.NewRelayClient("test", server.URL)}
+	check.auditProposerPayment(nil)
+	audit := check.ProposerAudit
+	if len(check.Errors) != 0 || audit == nil || audit.Slot != 100 || !audit.Registered || audit.Payment.Cmp(gwei(1e9)) != 0 || len(audit.Relays) != 1 {
+		t.Errorf("Unexpected audit: %v %v", audit, check.Errors)
+	}
+	server.Close()
+
+	// The relay delivered the payload with another fee recipient, which the builder paid
+	check = newProposerAuditCheck(t, other)
+	server = testBeaconAndRelay(check.EthBlock.Hash().Hex(), feeRecipient.Hex(), other.Hex())
+	defer server.Close()
+	BeaconClient = api.NewBeaconClient(server.URL)
+	RelayClients = []*api.RelayClient{api.NewRelayClient("test", server.URL)}
+	check.auditProposerPayment(nil)
+	if check.ErrorCounter.ProposerPaymentMisdirected != 1 || check.ErrorCounter.ProposerPaymentMissed != 1 || !check.HasSeriousErrors() {
+		t.Errorf("Expected a misdirected and missed payment: %+v %v", check.ErrorCounter, check.Errors)
+	}
+
+	// The audit is unavailable if the beacon node fails, the check goes on
+	check = newProposerAuditCheck(t, feeRecipient)
+	BeaconClient = api.NewBeaconClient(server.URL + "/unavailable")
+	check.auditProposerPayment(nil)
+	if check.ProposerAudit != nil || len(check.Errors) != 0 {
+		t.Errorf("Expected no audit without the beacon node: %v %v", check.ProposerAudit, check.Errors)
+	}
+}
+
+func TestCheckRelayPaymentsUnavailable(t *testing.T) {
+	defer func() { RelayClients = nil }()
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	// All relays fail: no error, the check goes on
+	check := newProposerAuditCheck(t, ethcommon.HexToAddress("0x00000000000000000000000000000000000fee01"))
+	RelayClients = []*api.RelayClient{api.NewRelayClient("test", server.URL)}
+	check.checkRelayPayments(nil)
+	if len(check.RelayBids) != 0 || len(check.Errors) != 0 {
+		t.Errorf("Expected no relay bids and errors: %v %v", check.RelayBids, check.Errors)
+	}
+}
@@ -0,0 +1,37 @@
+package blockcheck
+
+import (
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/metachris/flashbots/protocols"
+)
+
+// Registry used to decode the protocols of bundle transactions (nil to disable). Can be extended with
+// ProtocolRegistry.LoadFile.
+var ProtocolRegistry = protocols.DefaultRegistry()
+
+// decodeBundleProtocols counts the protocols the transactions of each bundle interact with
+func (b *BlockCheck) decodeBundleProtocols() {
+	if ProtocolRegistry == nil {
+		return
+	}
+
+	for _, bundle := range b.Bundles {
+		if bundle.Protocols == nil {
+			bundle.Protocols = make(map[string]int)
+		}
+
+		for _, fbTx := range bundle.Transactions {
+			tx := b.EthBlock.Transaction(ethcommon.HexToHash(fbTx.Hash))
+			if tx == nil {
+				continue
+			}
+
+			to := ""
+			if tx.To() != nil {
+				to = tx.To().Hex()
+			}
+			match := ProtocolRegistry.Decode(to, tx.Data())
+			bundle.Protocols[match.Protocol] += 1
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package blockcheck
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+// If set, CheckBlock compares the bids which the relays delivered for the block with the on-chain proposer payment
+var RelayClients []*api.RelayClient
+
+// RelayBid is a bid delivered by a relay for the checked block, with the on-chain payment to the proposer
+type RelayBid struct {
+	Relay           string
+	Bid             api.BidTrace
+	ProposerPayment *big.Int
+}
+
+// ProposerPayment returns the on-chain payment to the proposer's fee recipient. If the fee recipient is the block's
+// coinbase, these are the priority fees and the coinbase transfers (direct transfers only, unless traced transfers
+// are given). Else it's the value of the last tx, if it's a transfer from the coinbase (builder) to the fee recipient.
+func (b *BlockCheck) ProposerPayment(feeRecipient ethcommon.Address, transfers map[ethcommon.Hash]*big.Int) *big.Int {
+	payment := new(big.Int)
+	txs := b.EthBlock.Transactions()
+
+	if feeRecipient == b.EthBlock.Coinbase() {
+		for _, tx := range txs {
+			receipt := b.BlockWithTxReceipts.TxReceipts[tx.Hash()]
+			if receipt == nil {
+				continue
+			}
+			gasFees := new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), common.TxPriorityFee(tx, b.EthBlock.BaseFee()))
+			payment.Add(payment, gasFees)
+			payment.Add(payment, b.txCoinbaseTransfer(tx, transfers))
+		}
+		return payment
+	}
+
+	if len(txs) == 0 {
+		return payment
+	}
+
+	lastTx := txs[len(txs)-1]
+	receipt := b.BlockWithTxReceipts.TxReceipts[lastTx.Hash()]
+	if receipt == nil || receipt.Status != 1 || lastTx.To() == nil || *lastTx.To() != feeRecipient {
+		return payment
+	}
+
+	sender, err := utils.GetTxSender(lastTx)
+	if err != nil || sender != b.EthBlock.Coinbase() {
+		return payment
+	}
+	return payment.Set(lastTx.Value())
+}
+
+// checkRelayPayments queries the relays for the bids they delivered for this block, and adds an error if the on-chain
+// payment to the proposer is lower than the bid value. If all relays return an error, the check is logged as
+// unavailable (a relay outage doesn't fail the block check).
+func (b *BlockCheck) checkRelayPayments(transfers map[ethcommon.Hash]*big.Int) {
+	var lastErr error
+	numErrors := 0
+	for _, relay := range RelayClients {
+		bids, err := relay.GetProposerPayloadsDelivered(context.Background(), &api.GetBidTracesOptions{BlockNumber: b.Number})
+		if err != nil {
+			lastErr = err
+			numErrors += 1
+			continue
+		}
+
+		for _, bid := range bids {
+			if !strings.EqualFold(bid.BlockHash, b.EthBlock.Hash().Hex()) {
+				continue
+			}
+
+			payment := b.ProposerPayment(ethcommon.HexToAddress(bid.ProposerFeeRecipient), transfers)
+			b.RelayBids = append(b.RelayBids, &RelayBid{Relay: relay.Name, Bid: bid, ProposerPayment: payment})
+
+			bidValue := common.StrToBigInt(bid.Value)
+			if payment.Cmp(bidValue) == -1 {
+				msg := fmt.Sprintf("relay %s bid value %s, but on-chain payment to the proposer [%s](<%s>) is %s (builder %.18s...)\n", relay.Name, common.FormatEth(bidValue), bid.ProposerFeeRecipient, common.AddressUrl(bid.ProposerFeeRecipient), common.FormatEth(payment), bid.BuilderPubkey)
+				b.addError(&CheckError{Check: CheckRelayPayment, Kind: ErrorRelayPaymentMismatch, Severity: SeveritySerious, BundleIndex: -1, Message: msg})
+				b.ErrorCounter.RelayPaymentMismatch += 1
+			}
+		}
+	}
+
+	if numErrors > 0 && numErrors == len(RelayClients) {
+		log.Warn("relay payment check unavailable, relay error", "block", b.Number, "err", lastErr)
+	}
+}
@@ -0,0 +1,286 @@
+package blockcheck
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+// Report periods (calendar days and weeks, UTC, weeks start on Monday)
+const (
+	ReportDaily  = "daily"
+	ReportWeekly = "weekly"
+)
+
+// Report aggregates the checked blocks of a calendar day or week
+type Report struct {
+	Period string    `json:"period"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"` // exclusive
+
+	StartBlock      int64    `json:"start_block"`
+	EndBlock        int64    `json:"end_block"`
+	Blocks          uint64   `json:"blocks"`
+	FlashbotsBlocks uint64   `json:"flashbots_blocks"` // blocks with bundles
+	Bundles         uint64   `json:"bundles"`
+	MinerReward     *big.Int `json:"miner_reward"` // total miner reward of the bundles (wei)
+	DustBundles     uint64   `json:"dust_bundles"` // bundles below common.MinBundleRewardEth (included in Bundles)
+	DustMinerReward *big.Int `json:"dust_miner_reward"`
+	ErrorBlocks     uint64   `json:"error_blocks"`
+
+	FailedTxCost        *big.Int            `json:"failed_tx_cost"`          // gas burned by failed Flashbots and 0-gas tx (wei)
+	FailedTxCostByMiner map[string]*big.Int `json:"failed_tx_cost_by_miner"` // by miner address
+
+	Sandwiches            uint64  `json:"sandwiches"`
+	PricedSandwiches      uint64  `json:"priced_sandwiches"`        // with the victim loss in USD (see SandwichPrices)
+	SandwichVictimLossUSD float64 `json:"sandwich_victim_loss_usd"` // of the priced sandwiches, at the block times
+
+	Errors ErrorCounts `json:"-"`
+}
+
+// ReportPeriodStart returns the start of the calendar day or week of t (UTC)
+func ReportPeriodStart(period string, t time.Time) time.Time {
+	day := t.UTC().Truncate(24 * time.Hour)
+	if period == ReportWeekly {
+		daysSinceMonday := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -daysSinceMonday)
+	}
+	return day
+}
+
+// NewReport returns an empty report for the day or week of t
+func NewReport(period string, t time.Time) *Report {
+	start := ReportPeriodStart(period, t)
+	end := start.AddDate(0, 0, 1)
+	if period == ReportWeekly {
+		end = start.AddDate(0, 0, 7)
+	}
+	return &Report{Period: period, Start: start, End: end, MinerReward: new(big.Int), DustMinerReward: new(big.Int), FailedTxCost: new(big.Int), FailedTxCostByMiner: make(map[string]*big.Int)}
+}
+
+// Name is the period, eg. "daily 2021-08-20" or "weekly 2021-08-16"
+func (r *Report) Name() string {
+	return fmt.Sprintf("%s %s", r.Period, r.Start.Format("2006-01-02"))
+}
+
+// AddCheck adds a checked block (should be called for every block, not only blocks with errors)
+func (r *Report) AddCheck(check *BlockCheck) {
+	if r.StartBlock == 0 || check.Number < r.StartBlock {
+		r.StartBlock = check.Number
+	}
+	if check.Number > r.EndBlock {
+		r.EndBlock = check.Number
+	}
+
+	r.Blocks += 1
+	if len(check.Bundles) > 0 {
+		r.FlashbotsBlocks += 1
+	}
+	r.Bundles += uint64(len(check.Bundles))
+	for _, bundle := range check.Bundles {
+		r.MinerReward.Add(r.MinerReward, bundle.TotalMinerReward)
+		if bundle.IsDust() {
+			r.DustBundles += 1
+			r.DustMinerReward.Add(r.DustMinerReward, bundle.TotalMinerReward)
+		}
+	}
+
+	if check.HasSeriousErrors() || check.HasLessSeriousErrors() {
+		r.ErrorBlocks += 1
+		r.Errors.Add(check.ErrorCounter)
+	}
+
+	if cost := check.FailedTxCost(); cost.Sign() > 0 {
+		r.FailedTxCost.Add(r.FailedTxCost, cost)
+		if r.FailedTxCostByMiner[check.Miner] == nil {
+			r.FailedTxCostByMiner[check.Miner] = new(big.Int)
+		}
+		r.FailedTxCostByMiner[check.Miner].Add(r.FailedTxCostByMiner[check.Miner], cost)
+	}
+
+	r.Sandwiches += uint64(len(check.Sandwiches))
+	for _, sandwich := range check.Sandwiches {
+		if sandwich.EstimatedVictimLossUSD > 0 {
+			r.PricedSandwiches += 1
+			r.SandwichVictimLossUSD += sandwich.EstimatedVictimLossUSD
+		}
+	}
+}
+
+// TopFailedTxCostMiners returns up to n miners with the most gas burned by failed transactions, most first
+func (r *Report) TopFailedTxCostMiners(n int) []string {
+	miners := make([]string, 0, len(r.FailedTxCostByMiner))
+	for miner := range r.FailedTxCostByMiner {
+		miners = append(miners, miner)
+	}
+	sort.Slice(miners, func(i, j int) bool {
+		return r.FailedTxCostByMiner[miners[i]].Cmp(r.FailedTxCostByMiner[miners[j]]) == 1
+	})
+	if len(miners) > n {
+		miners = miners[:n]
+	}
+	return miners
+}
+
+// FlashbotsBlockShare returns the share of blocks with bundles
+func (r *Report) FlashbotsBlockShare() float64 {
+	if r.Blocks == 0 {
+		return 0
+	}
+	return float64(r.FlashbotsBlocks) / float64(r.Blocks)
+}
+
+func (r *Report) String() (ret string) {
+	ret = fmt.Sprintf("%s ... %s, blocks %d ... %d\n", r.Start.Format("2006-01-02"), r.End.Add(-time.Second).Format("2006-01-02"), r.StartBlock, r.EndBlock)
+	ret += fmt.Sprintf("blocks: %d, flashbots blocks: %d (%.2f%%), bundles: %d, miner reward: %s ETH\n", r.Blocks, r.FlashbotsBlocks, r.FlashbotsBlockShare()*100, r.Bundles, utils.WeiBigIntToEthString(r.MinerReward, 4))
+	if common.MinBundleRewardEth > 0 {
+		ret += fmt.Sprintf("without dust (< %v ETH): bundles: %d, miner reward: %s ETH\n", common.MinBundleRewardEth, r.Bundles-r.DustBundles, utils.WeiBigIntToEthString(new(big.Int).Sub(r.MinerReward, r.DustMinerReward), 4))
+	}
+	ret += fmt.Sprintf("error blocks: %d\n", r.ErrorBlocks)
+	for _, c := range r.Errors.namedCounts() {
+		if c.count > 0 {
+			ret += fmt.Sprintf("- %-26s %d\n", c.name, c.count)
+		}
+	}
+	if r.FailedTxCost.Sign() > 0 {
+		ret += fmt.Sprintf("ETH wasted on failed tx: %s ETH\n", utils.WeiBigIntToEthString(r.FailedTxCost, 4))
+		for _, miner := range r.TopFailedTxCostMiners(5) {
+			ret += fmt.Sprintf("- %s %s ETH\n", miner, utils.WeiBigIntToEthString(r.FailedTxCostByMiner[miner], 4))
+		}
+	}
+	if r.Sandwiches > 0 {
+		ret += fmt.Sprintf("sandwiches: %d", r.Sandwiches)
+		if r.PricedSandwiches > 0 {
+			ret += fmt.Sprintf(", victim loss: $%.2f (%d priced)", r.SandwichVictimLossUSD, r.PricedSandwiches)
+		}
+		ret += "\n"
+	}
+	return ret
+}
+
+// MarshalJSON adds the error counts by type
+func (r *Report) MarshalJSON() ([]byte, error) {
+	type report Report // without the MarshalJSON method
+	return json.Marshal(struct {
+		*report
+		ErrorCounts map[string]uint64 `json:"error_counts"`
+	}{(*report)(r), r.Errors.Map()})
+}
+
+// WriteJSON writes the report to <dir>/report-<period>-<yyyy-mm-dd>.json
+func (r *Report) WriteJSON(dir string) (filename string, err error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	filename = filepath.Join(dir, fmt.Sprintf("report-%s-%s.json", r.Period, r.Start.Format("2006-01-02")))
+	return filename, os.WriteFile(filename, data, 0644)
+}
+
+// AppendCSV appends the report as a row to <dir>/report-<period>.csv (with a header if the file is new). If the
+// columns changed (eg. new error types), the existing file is renamed with a timestamp (eg.
+// report-daily-20211016T120000.000000000.csv) and a new file is started.
+func (r *Report) AppendCSV(dir string) (filename string, err error) {
+	filename = filepath.Join(dir, fmt.Sprintf("report-%s.csv", r.Period))
+	header := []string{"start", "end", "start_block", "end_block", "blocks", "flashbots_blocks", "bundles", "miner_reward_eth", "dust_bundles", "dust_miner_reward_eth", "error_blocks", "failed_tx_cost_eth", "sandwiches", "sandwich_victim_loss_usd"}
+	row := []string{r.Start.Format("2006-01-02"), r.End.Format("2006-01-02"), fmt.Sprint(r.StartBlock), fmt.Sprint(r.EndBlock), fmt.Sprint(r.Blocks), fmt.Sprint(r.FlashbotsBlocks), fmt.Sprint(r.Bundles), utils.WeiBigIntToEthString(r.MinerReward, 6), fmt.Sprint(r.DustBundles), utils.WeiBigIntToEthString(r.DustMinerReward, 6), fmt.Sprint(r.ErrorBlocks), utils.WeiBigIntToEthString(r.FailedTxCost, 6), fmt.Sprint(r.Sandwiches), fmt.Sprintf("%.2f", r.SandwichVictimLossUSD)}
+	for _, c := range r.Errors.namedCounts() {
+		header = append(header, c.name)
+		row = append(row, fmt.Sprint(c.count))
+	}
+
+	existingHeader, err := readCSVHeader(filename)
+	if err != nil {
+		return filename, err
+	}
+	isNew := existingHeader == nil
+	if !isNew && strings.Join(existingHeader, ",") != strings.Join(header, ",") {
+		backup := filepath.Join(dir, fmt.Sprintf("report-%s-%s.csv", r.Period, time.Now().UTC().Format("20060102T150405.000000000")))
+		if err := os.Rename(filename, backup); err != nil {
+			return filename, err
+		}
+		isNew = true
+	}
+
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return filename, err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if isNew {
+		w.Write(header)
+	}
+	w.Write(row)
+	w.Flush()
+	return filename, w.Error()
+}
+
+// readCSVHeader returns the first row of the CSV file, nil if the file doesn't exist or is empty
+func readCSVHeader(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header, err := csv.NewReader(f).Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	return header, err
+}
+
+// Reports maintains the reports of the current day and week. When a block of the next period is added, the
+// finished reports are returned, and new ones started.
+type Reports struct {
+	Daily  *Report
+	Weekly *Report
+}
+
+func NewReports() *Reports {
+	return &Reports{}
+}
+
+// AddCheck adds a checked block, and returns the reports of the periods which ended before this block
+func (r *Reports) AddCheck(check *BlockCheck) (finished []*Report) {
+	t := time.Unix(int64(check.EthBlock.Time()), 0)
+
+	var done *Report
+	if r.Daily, done = addToReport(r.Daily, ReportDaily, t, check); done != nil {
+		finished = append(finished, done)
+	}
+	if r.Weekly, done = addToReport(r.Weekly, ReportWeekly, t, check); done != nil {
+		finished = append(finished, done)
+	}
+	return finished
+}
+
+// addToReport adds the check to the report, or to a new one if the block is after the report period
+func addToReport(report *Report, period string, t time.Time, check *BlockCheck) (current *Report, finished *Report) {
+	current = report
+	if current != nil && !t.Before(current.End) {
+		finished = current
+		current = nil
+	}
+	if current == nil {
+		current = NewReport(period, t)
+	}
+	current.AddCheck(check)
+	return current, finished
+}
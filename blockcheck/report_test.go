@@ -0,0 +1,135 @@
+package blockcheck
+
+import (
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/common"
+)
+
+func newReportTestCheck(number int64, t time.Time, numBundles int) *BlockCheck {
+	check := &BlockCheck{
+		Number:   number,
+		EthBlock: types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number), Time: uint64(t.Unix())}),
+	}
+	for i := 0; i < numBundles; i++ {
+		bundle := newTestBundle(api.BundleTypeFlashbots, int64(i), int64(i))
+		bundle.TotalMinerReward = big.NewInt(1e18)
+		check.AddBundle(bundle)
+	}
+	return check
+}
+
+func TestReportPeriodStart(t *testing.T) {
+	friday := time.Date(2021, 8, 20, 15, 30, 0, 0, time.UTC)
+	if start := ReportPeriodStart(ReportDaily, friday); !start.Equal(time.Date(2021, 8, 20, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Wrong day start:", start)
+	}
+	if start := ReportPeriodStart(ReportWeekly, friday); !start.Equal(time.Date(2021, 8, 16, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Wrong week start:", start)
+	}
+	sunday := time.Date(2021, 8, 22, 23, 0, 0, 0, time.UTC)
+	if start := ReportPeriodStart(ReportWeekly, sunday); !start.Equal(time.Date(2021, 8, 16, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Wrong week start for sunday:", start)
+	}
+}
+
+func TestReports(t *testing.T) {
+	day := time.Date(2021, 8, 20, 0, 0, 0, 0, time.UTC) // friday
+	reports := NewReports()
+
+	if finished := reports.AddCheck(newReportTestCheck(100, day.Add(time.Hour), 2)); len(finished) != 0 {
+		t.Fatal("Unexpected finished reports:", finished)
+	}
+	errorCheck := newReportTestCheck(101, day.Add(2*time.Hour), 0)
+	errorCheck.ManualHasSeriousError = true
+	errorCheck.ErrorCounter.BundlePaysMoreThanPrevBundle = 1
+	reports.AddCheck(errorCheck)
+
+	// Next day: the daily report is finished
+	finished := reports.AddCheck(newReportTestCheck(102, day.Add(25*time.Hour), 1))
+	if len(finished) != 1 || finished[0].Period != ReportDaily {
+		t.Fatal("Expected the daily report, got:", finished)
+	}
+	daily := finished[0]
+	if daily.Blocks != 2 || daily.FlashbotsBlocks != 1 || daily.Bundles != 2 || daily.ErrorBlocks != 1 || daily.StartBlock != 100 || daily.EndBlock != 101 {
+		t.Error("Unexpected daily report:", daily)
+	}
+	if daily.MinerReward.Cmp(big.NewInt(2e18)) != 0 || daily.Errors.BundlePaysMoreThanPrevBundle != 1 {
+		t.Error("Unexpected reward or errors:", daily.MinerReward, daily.Errors)
+	}
+
+	// Next monday: the daily and the weekly report are finished
+	finished = reports.AddCheck(newReportTestCheck(103, day.Add(72*time.Hour), 0))
+	if len(finished) != 2 || finished[1].Period != ReportWeekly || finished[1].Blocks != 3 {
+		t.Fatal("Expected the daily and weekly report, got:", finished)
+	}
+
+	// Write the files
+	dir := t.TempDir()
+	if _, err := daily.WriteJSON(dir); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := daily.AppendCSV(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "report-daily-2021-08-20.json"))
+	if err != nil || !strings.Contains(string(data), `"bundlePaysMore": 1`) || !strings.Contains(string(data), `"flashbots_blocks": 1`) {
+		t.Error("Unexpected JSON report:", string(data), err)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "report-daily.csv"))
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if err != nil || len(lines) != 3 || !strings.HasPrefix(lines[0], "start,end") || !strings.HasPrefix(lines[1], "2021-08-20,2021-08-21,100,101,2,1,2,") {
+		t.Error("Unexpected CSV report:", string(data), err)
+	}
+}
+
+func TestReportCSVColumnsChanged(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "report-daily.csv")
+	if err := os.WriteFile(filename, []byte("start,end,blocks\n2021-08-19,2021-08-20,5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	report := NewReport(ReportDaily, time.Date(2021, 8, 20, 12, 0, 0, 0, time.UTC))
+	report.AddCheck(newReportTestCheck(100, time.Date(2021, 8, 20, 12, 0, 0, 0, time.UTC), 1))
+	if _, err := report.AppendCSV(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := filepath.Glob(filepath.Join(dir, "report-daily-*.csv"))
+	if err != nil || len(backups) != 1 {
+		t.Fatal("expected the old file to be renamed", backups, err)
+	}
+	data, err := os.ReadFile(filename)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if err != nil || len(lines) != 2 || !strings.HasPrefix(lines[0], "start,end,start_block") {
+		t.Error("expected a new file with the current columns:", string(data), err)
+	}
+}
+
+func TestReportDustBundles(t *testing.T) {
+	common.MinBundleRewardEth = 2
+	defer func() { common.MinBundleRewardEth = 0 }()
+
+	report := NewReport(ReportDaily, time.Now())
+	check := newReportTestCheck(100, time.Now(), 2)
+	check.Bundles[1].TotalMinerReward = big.NewInt(3e18)
+	report.AddCheck(check)
+
+	if report.Bundles != 2 || report.DustBundles != 1 || report.DustMinerReward.Cmp(big.NewInt(1e18)) != 0 || report.MinerReward.Cmp(big.NewInt(4e18)) != 0 {
+		t.Error("Unexpected dust stats:", report.Bundles, report.DustBundles, report.DustMinerReward, report.MinerReward)
+	}
+	if !strings.Contains(report.String(), "without dust (< 2 ETH): bundles: 1, miner reward: 3") {
+		t.Error("Unexpected report:", report.String())
+	}
+}
@@ -0,0 +1,99 @@
+package blockcheck
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/metachris/flashbots/api"
+)
+
+// ResultHashVersion is the prefix of the result hashes, it changes when the hashed fields change
+const ResultHashVersion = "v1"
+
+// hashInputs are the inputs of a check which determine its results
+type hashInputs struct {
+	BlockHash             string                     `json:"block_hash"`
+	FlashbotsTransactions []api.FlashbotsTransaction `json:"flashbots_transactions"` // in block order
+	Steps                 []string                   `json:"steps"`                  // steps which ran (enabled checks, with their requirements)
+	Thresholds            map[string]float64         `json:"thresholds"`
+}
+
+// hashOutput is an error found by the check, without the message (it contains links of the configured explorer)
+type hashOutput struct {
+	Check       string `json:"check"`
+	Kind        string `json:"kind"`
+	Severity    string `json:"severity"`
+	BundleIndex int64  `json:"bundle_index"`
+	TxHash      string `json:"tx_hash"`
+	Value       string `json:"value"`
+}
+
+// InputHash returns a deterministic hash of the inputs of the check: the block, the Flashbots API data, the steps
+// which ran and the thresholds. It's "v1:" followed by the hex sha256.
+func (b *BlockCheck) InputHash() string {
+	inputs := hashInputs{
+		BlockHash:             b.EthBlock.Hash().Hex(),
+		FlashbotsTransactions: append([]api.FlashbotsTransaction{}, b.FlashbotsTransactions...),
+		Steps:                 make([]string, 0),
+		Thresholds: map[string]float64{
+			"bundle_percent_price_diff":                float64(ThresholdBiggestBundlePercentPriceDiff),
+			"bundle_lower_than_lowest_tx_percent_diff": float64(ThresholdBundleIsPayingLessThanLowestTxPercentDiff),
+			"less_serious_bundle_percent_price_diff":   float64(ThresholdLessSeriousBiggestBundlePercentPriceDiff),
+			"less_serious_bundle_lower_than_lowest_tx": float64(ThresholdLessSeriousBundleIsPayingLessThanLowestTxPercentDiff),
+			"builder_kept_share_percent":               float64(ThresholdBuilderKeptSharePercent),
+			"builder_profit_min_block_value_eth":       ThresholdBuilderProfitMinBlockValue,
+			"bundle_low_fee_max_percentile":            ThresholdBundleLowFeeMaxPercentile,
+			"bundle_fee_reference_percentile":          ThresholdBundleFeeReferencePercentile,
+			"dust_transfer_max_eth":                    DustTransferMaxEth,
+			"simulation_reward_diff_percent":           ThresholdSimulationRewardDiffPercent,
+		},
+	}
+	sort.SliceStable(inputs.FlashbotsTransactions, func(i, j int) bool {
+		return inputs.FlashbotsTransactions[i].TxIndex < inputs.FlashbotsTransactions[j].TxIndex
+	})
+
+	shed := make(map[string]bool)
+	for _, name := range b.ShedSteps {
+		shed[name] = true
+	}
+	if !b.IsLowActivity {
+		for _, step := range b.Steps() {
+			if step.Enabled && !shed[step.Name] {
+				inputs.Steps = append(inputs.Steps, step.Name)
+			}
+		}
+	}
+	return resultHash(inputs)
+}
+
+// OutputHash returns a deterministic hash of the errors found by the check (independent of their order and of the
+// explorer links in the messages). It's "v1:" followed by the hex sha256.
+func (b *BlockCheck) OutputHash() string {
+	outputs := make([]hashOutput, 0, len(b.CheckErrors))
+	for _, err := range b.CheckErrors {
+		outputs = append(outputs, hashOutput{
+			Check:       err.Check,
+			Kind:        err.Kind,
+			Severity:    err.Severity,
+			BundleIndex: err.BundleIndex,
+			TxHash:      err.TxHash,
+			Value:       fmt.Sprintf("%.4f", err.Value),
+		})
+	}
+	sort.Slice(outputs, func(i, j int) bool {
+		return fmt.Sprint(outputs[i]) < fmt.Sprint(outputs[j])
+	})
+	return resultHash(outputs)
+}
+
+func resultHash(v interface{}) string {
+	data, err := json.Marshal(v) // map keys are sorted
+	if err != nil {
+		panic(err) // only plain types
+	}
+	sum := sha256.Sum256(data)
+	return ResultHashVersion + ":" + hex.EncodeToString(sum[:])
+}
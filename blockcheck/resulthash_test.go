@@ -0,0 +1,48 @@
+package blockcheck
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultHash(t *testing.T) {
+	newCheck := func() *BlockCheck {
+		check := newReportTestCheck(100, time.Unix(1629900000, 0), 2)
+		check.addError(&CheckError{Check: CheckFailedTx, Kind: ErrorFailedFlashbotsTx, Severity: SeveritySerious, BundleIndex: 1, TxHash: "0x1", Message: "failed tx [0x1](<https://etherscan.io/tx/0x1>)"})
+		check.addError(&CheckError{Check: CheckBundleOrder, Kind: ErrorBundlePaysMore, Severity: SeverityLessSerious, BundleIndex: 0, Value: 30.123456, Message: "bundle 0 pays more"})
+		return check
+	}
+
+	a, b := newCheck(), newCheck()
+	if !strings.HasPrefix(a.InputHash(), ResultHashVersion+":") || a.InputHash() != b.InputHash() || a.OutputHash() != b.OutputHash() {
+		t.Fatal("expected the same hashes for the same check", a.InputHash(), b.InputHash(), a.OutputHash(), b.OutputHash())
+	}
+
+	// Independent of the error order and the messages (explorer links)
+	b.CheckErrors[0], b.CheckErrors[1] = b.CheckErrors[1], b.CheckErrors[0]
+	b.CheckErrors[0].Message = "bundle 0 pays more (other explorer)"
+	if a.OutputHash() != b.OutputHash() {
+		t.Error("expected the same output hash for reordered errors and other messages")
+	}
+
+	// Different results
+	b.CheckErrors[1].Severity = SeverityLessSerious
+	if a.OutputHash() == b.OutputHash() {
+		t.Error("expected a different output hash for a different severity")
+	}
+
+	// Different inputs
+	inputHash := a.InputHash()
+	ThresholdBiggestBundlePercentPriceDiff += 1
+	defer func() { ThresholdBiggestBundlePercentPriceDiff -= 1 }()
+	if a.InputHash() == inputHash {
+		t.Error("expected a different input hash for a different threshold")
+	}
+	inputHash = a.InputHash()
+	DisabledChecks[CheckSandwich] = true
+	defer delete(DisabledChecks, CheckSandwich)
+	if a.InputHash() == inputHash {
+		t.Error("expected a different input hash with a disabled check")
+	}
+}
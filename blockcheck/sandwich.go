@@ -0,0 +1,260 @@
+package blockcheck
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/flashbots/labels"
+	"github.com/metachris/flashbots/prices"
+)
+
+// Swap event topics of Uniswap V2 (and forks like Sushiswap) and Uniswap V3 pools
+var (
+	TopicSwapUniswapV2 = ethcommon.HexToHash("0xd78ad95fa46c994b6551d0da85fc275fe613ce37657fb8d5e3d130840159d822")
+	TopicSwapUniswapV3 = ethcommon.HexToHash("0xc42079f94a6350d7e6235f29174924f928cc2ac818eb64fed8004e115fbcca67")
+)
+
+var tt256 = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// If both are set, the victim losses of the sandwiches are priced in USD at the block time (see StepPriceSandwiches)
+var (
+	SandwichTokens TokenLookup   // eg. prices.NewTokens
+	SandwichPrices prices.Source // eg. prices.NewCoinGecko
+)
+
+// TokenLookup returns the tokens of a pool and the token metadata (see prices.Tokens)
+type TokenLookup interface {
+	PoolTokens(ctx context.Context, pool string) (token0 string, token1 string, err error)
+	Token(ctx context.Context, address string) (prices.Token, error)
+}
+
+// Sandwich is a likely sandwich attack inside a bundle: the frontrun and backrun transactions swap in opposite directions
+// on the same pool, around a victim transaction that swaps in the same direction as the frontrun.
+type Sandwich struct {
+	BundleIndex int64
+	BundleHash  string
+	Pool        string
+	FrontrunTx  string
+	VictimTx    string
+	BackrunTx   string
+	Searchers   []string // senders of the frontrun and backrun transactions
+
+	// Estimated loss of the victim (= gross profit of the searcher), in units of the token the frontrun sells
+	EstimatedVictimLoss *big.Int
+	LossToken           string // "token0" or "token1" of the pool
+
+	// Set by the price-sandwiches step (see SandwichPrices)
+	LossTokenAddress       string
+	LossTokenSymbol        string
+	LossTokenDecimals      int
+	EstimatedVictimLossUSD float64 // at the block time, 0 if the token has no price
+}
+
+func (s *Sandwich) String() string {
+	loss := common.BigIntToEString(s.EstimatedVictimLoss, 4) + " " + s.LossToken
+	if s.LossTokenSymbol != "" {
+		loss = formatTokenAmount(s.EstimatedVictimLoss, s.LossTokenDecimals) + " " + s.LossTokenSymbol
+	}
+	if s.EstimatedVictimLossUSD > 0 {
+		loss += fmt.Sprintf(" (~$%.2f)", s.EstimatedVictimLossUSD)
+	}
+	return fmt.Sprintf("bundle %d (%.10s) sandwiches [%s](<%s>) on pool [%s](<%s>), estimated victim loss: %s (searchers: %s)", s.BundleIndex, s.BundleHash, s.VictimTx, common.TxUrl(s.VictimTx), s.Pool, common.AddressUrl(s.Pool), loss, strings.Join(s.DisclosedSearchers(), ", "))
+}
+
+// DisclosedSearchers returns the searchers as disclosed by labels.SearcherDisclosure
+func (s *Sandwich) DisclosedSearchers() []string {
+	ret := make([]string, 0, len(s.Searchers))
+	for _, address := range s.Searchers {
+		ret = append(ret, labels.SearcherDisclosure.Address(address))
+	}
+	return ret
+}
+
+// formatTokenAmount returns the amount in whole tokens with 4 decimals
+func formatTokenAmount(amount *big.Int, decimals int) string {
+	value := new(big.Float).SetInt(amount)
+	value.Quo(value, new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+	return value.Text('f', 4)
+}
+
+type swap struct {
+	Pool       ethcommon.Address
+	ZeroForOne bool // token0 in, token1 out
+	AmountIn   *big.Int
+	AmountOut  *big.Int
+}
+
+func toInt256(data []byte) *big.Int {
+	i := new(big.Int).SetBytes(data)
+	if len(data) > 0 && data[0]&0x80 != 0 {
+		i.Sub(i, tt256)
+	}
+	return i
+}
+
+// parseSwaps returns the Uniswap V2 and V3 swaps of a transaction receipt
+func parseSwaps(receipt *types.Receipt) (swaps []swap) {
+	for _, log := range receipt.Logs {
+		if len(log.Topics) == 0 {
+			continue
+		}
+
+		switch log.Topics[0] {
+		case TopicSwapUniswapV2: // amount0In, amount1In, amount0Out, amount1Out
+			if len(log.Data) < 128 {
+				continue
+			}
+			amount0In := new(big.Int).SetBytes(log.Data[0:32])
+			amount1In := new(big.Int).SetBytes(log.Data[32:64])
+			amount0Out := new(big.Int).SetBytes(log.Data[64:96])
+			amount1Out := new(big.Int).SetBytes(log.Data[96:128])
+			if amount0In.Sign() > 0 {
+				swaps = append(swaps, swap{Pool: log.Address, ZeroForOne: true, AmountIn: amount0In, AmountOut: amount1Out})
+			} else {
+				swaps = append(swaps, swap{Pool: log.Address, ZeroForOne: false, AmountIn: amount1In, AmountOut: amount0Out})
+			}
+
+		case TopicSwapUniswapV3: // amount0, amount1 (positive: into the pool), sqrtPriceX96, liquidity, tick
+			if len(log.Data) < 64 {
+				continue
+			}
+			amount0 := toInt256(log.Data[0:32])
+			amount1 := toInt256(log.Data[32:64])
+			if amount0.Sign() > 0 {
+				swaps = append(swaps, swap{Pool: log.Address, ZeroForOne: true, AmountIn: amount0, AmountOut: new(big.Int).Neg(amount1)})
+			} else {
+				swaps = append(swaps, swap{Pool: log.Address, ZeroForOne: false, AmountIn: amount1, AmountOut: new(big.Int).Neg(amount0)})
+			}
+		}
+	}
+	return swaps
+}
+
+// checkSandwiches looks for sandwich patterns inside the bundles (informational, not counted as error)
+func (b *BlockCheck) checkSandwiches() {
+	// Swaps of all transactions by tx index
+	txs := b.EthBlock.Transactions()
+	swapsByTxIndex := make(map[int64][]swap)
+	for i, tx := range txs {
+		if receipt := b.BlockWithTxReceipts.TxReceipts[tx.Hash()]; receipt != nil && receipt.Status == 1 {
+			swapsByTxIndex[int64(i)] = parseSwaps(receipt)
+		}
+	}
+
+	for _, bundle := range b.Bundles {
+		bundleSenders := make(map[int64]string) // by tx index
+		for _, tx := range bundle.Transactions {
+			bundleSenders[tx.TxIndex] = strings.ToLower(tx.EoaAddress)
+		}
+
+		sortedTxs := make([]api.FlashbotsTransaction, len(bundle.Transactions))
+		copy(sortedTxs, bundle.Transactions)
+		sort.SliceStable(sortedTxs, func(i, j int) bool { return sortedTxs[i].TxIndex < sortedTxs[j].TxIndex })
+
+		for i, front := range sortedTxs {
+			for _, back := range sortedTxs[i+1:] {
+				sandwich := b.findSandwich(front, back, swapsByTxIndex, bundleSenders)
+				if sandwich == nil {
+					continue
+				}
+
+				sandwich.BundleIndex = bundle.Index
+				sandwich.BundleHash = bundle.Hash
+				sandwich.FrontrunTx = front.Hash
+				sandwich.BackrunTx = back.Hash
+				sandwich.Searchers = []string{front.EoaAddress}
+				if back.EoaAddress != front.EoaAddress {
+					sandwich.Searchers = append(sandwich.Searchers, back.EoaAddress)
+				}
+				b.Sandwiches = append(b.Sandwiches, sandwich)
+			}
+		}
+	}
+}
+
+// findSandwich returns a sandwich if the front and back transactions swap in opposite directions on the same pool,
+// and a transaction in between swaps in the same direction as the front transaction. Sandwich bundles usually include
+// the victim tx ([front, victim, back]), so bundle tx are victims too, unless sent by the sender of the front or back tx.
+func (b *BlockCheck) findSandwich(front api.FlashbotsTransaction, back api.FlashbotsTransaction, swapsByTxIndex map[int64][]swap, bundleSenders map[int64]string) *Sandwich {
+	searchers := map[string]bool{strings.ToLower(front.EoaAddress): true, strings.ToLower(back.EoaAddress): true}
+	for _, frontSwap := range swapsByTxIndex[front.TxIndex] {
+		for _, backSwap := range swapsByTxIndex[back.TxIndex] {
+			if frontSwap.Pool != backSwap.Pool || frontSwap.ZeroForOne == backSwap.ZeroForOne {
+				continue
+			}
+
+			for victimIndex := front.TxIndex + 1; victimIndex < back.TxIndex; victimIndex++ {
+				if sender, inBundle := bundleSenders[victimIndex]; inBundle && searchers[sender] {
+					continue
+				}
+
+				for _, victimSwap := range swapsByTxIndex[victimIndex] {
+					if victimSwap.Pool != frontSwap.Pool || victimSwap.ZeroForOne != frontSwap.ZeroForOne {
+						continue
+					}
+
+					loss := new(big.Int).Sub(backSwap.AmountOut, frontSwap.AmountIn)
+					if loss.Sign() < 0 {
+						loss = big.NewInt(0)
+					}
+
+					lossToken := "token1"
+					if frontSwap.ZeroForOne {
+						lossToken = "token0"
+					}
+
+					return &Sandwich{
+						Pool:                frontSwap.Pool.Hex(),
+						VictimTx:            b.EthBlock.Transactions()[victimIndex].Hash().Hex(),
+						EstimatedVictimLoss: loss,
+						LossToken:           lossToken,
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// priceSandwiches sets the loss token and the USD value of the victim losses. Lookup errors are logged and leave the
+// loss unpriced, they don't fail the check.
+func (b *BlockCheck) priceSandwiches() error {
+	ctx := context.Background()
+	blockTime := time.Unix(int64(b.EthBlock.Time()), 0)
+	for _, sandwich := range b.Sandwiches {
+		token0, token1, err := SandwichTokens.PoolTokens(ctx, sandwich.Pool)
+		if err != nil {
+			log.Warn("sandwich pool lookup error", "block", b.Number, "pool", sandwich.Pool, "err", err)
+			continue
+		}
+		address := token1
+		if sandwich.LossToken == "token0" {
+			address = token0
+		}
+
+		token, err := SandwichTokens.Token(ctx, address)
+		if err != nil {
+			log.Warn("sandwich token lookup error", "block", b.Number, "token", address, "err", err)
+			continue
+		}
+		sandwich.LossTokenAddress = token.Address
+		sandwich.LossTokenSymbol = token.Symbol
+		sandwich.LossTokenDecimals = token.Decimals
+
+		price, err := SandwichPrices.PriceUSD(ctx, token.Address, blockTime)
+		if err != nil {
+			log.Warn("sandwich token price error", "block", b.Number, "token", token.Address, "err", err)
+			continue
+		}
+		sandwich.EstimatedVictimLossUSD = prices.ValueUSD(sandwich.EstimatedVictimLoss, token.Decimals, price)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package blockcheck
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/prices"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+func int256Bytes(i int64) []byte {
+	v := big.NewInt(i)
+	if v.Sign() < 0 {
+		v.Add(v, tt256)
+	}
+	return ethcommon.LeftPadBytes(v.Bytes(), 32)
+}
+
+func TestParseSwaps(t *testing.T) {
+	pool := ethcommon.HexToAddress("0x0d4a11d5EEaaC28EC3F61d100daF4d40471f1852")
+
+	// Uniswap V2: 100 token1 in, 90 token0 out
+	dataV2 := append(append(append(int256Bytes(0), int256Bytes(100)...), int256Bytes(90)...), int256Bytes(0)...)
+
+	// Uniswap V3: 50 token0 in, 40 token1 out (negative amount)
+	dataV3 := append(append(append(append(int256Bytes(50), int256Bytes(-40)...), int256Bytes(0)...), int256Bytes(0)...), int256Bytes(0)...)
+
+	receipt := &types.Receipt{Logs: []*types.Log{
+		{Address: pool, Topics: []ethcommon.Hash{TopicSwapUniswapV2}, Data: dataV2},
+		{Address: pool, Topics: []ethcommon.Hash{ethcommon.HexToHash("0x01")}, Data: dataV2},
+		{Address: pool, Topics: []ethcommon.Hash{TopicSwapUniswapV3}, Data: dataV3},
+	}}
+
+	swaps := parseSwaps(receipt)
+	if len(swaps) != 2 {
+		t.Fatal("Expected 2 swaps, got", len(swaps))
+	}
+
+	if swaps[0].ZeroForOne || swaps[0].AmountIn.Int64() != 100 || swaps[0].AmountOut.Int64() != 90 {
+		t.Errorf("Wrong V2 swap: %+v", swaps[0])
+	}
+
+	if !swaps[1].ZeroForOne || swaps[1].AmountIn.Int64() != 50 || swaps[1].AmountOut.Int64() != 40 {
+		t.Errorf("Wrong V3 swap: %+v", swaps[1])
+	}
+}
+
+// swapV2Log is a Uniswap V2 swap: amountIn of token0 for amountOut of token1 (zeroForOne), or the reverse
+func swapV2Log(pool ethcommon.Address, zeroForOne bool, amountIn int64, amountOut int64) *types.Log {
+	data := append(append(append(int256Bytes(amountIn), int256Bytes(0)...), int256Bytes(0)...), int256Bytes(amountOut)...)
+	if !zeroForOne {
+		data = append(append(append(int256Bytes(0), int256Bytes(amountIn)...), int256Bytes(amountOut)...), int256Bytes(0)...)
+	}
+	return &types.Log{Address: pool, Topics: []ethcommon.Hash{TopicSwapUniswapV2}, Data: data}
+}
+
+func TestCheckSandwiches(t *testing.T) {
+	pool := ethcommon.HexToAddress("0x0d4a11d5EEaaC28EC3F61d100daF4d40471f1852")
+	searcher := "0x00000000000000000000000000000000000000aa"
+	victim := "0x00000000000000000000000000000000000000bb"
+
+	// Bundle [front, victim, back]: the searcher buys token1 before the victim, and sells it after
+	logs := [][]*types.Log{
+		{swapV2Log(pool, true, 100, 50)},
+		{swapV2Log(pool, true, 1000, 450)},
+		{swapV2Log(pool, false, 50, 110)},
+	}
+	txs := make([]*types.Transaction, len(logs))
+	receipts := make(map[ethcommon.Hash]*types.Receipt)
+	bundle := newTestBundle(api.BundleTypeFlashbots, 0)
+	for i := range logs {
+		txs[i] = types.NewTransaction(uint64(i), pool, big.NewInt(0), 200000, big.NewInt(1), nil)
+		receipts[txs[i].Hash()] = &types.Receipt{Status: 1, TxHash: txs[i].Hash(), Logs: logs[i]}
+		sender := searcher
+		if i == 1 {
+			sender = victim
+		}
+		bundle.Transactions = append(bundle.Transactions, api.FlashbotsTransaction{TxIndex: int64(i), Hash: txs[i].Hash().Hex(), EoaAddress: sender})
+	}
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(100)}).WithBody(txs, nil)
+	check := &BlockCheck{EthBlock: block, BlockWithTxReceipts: &blockswithtx.BlockWithTxReceipts{Block: block, TxReceipts: receipts}}
+	check.AddBundle(bundle)
+
+	check.checkSandwiches()
+	if len(check.Sandwiches) != 1 {
+		t.Fatal("Expected 1 sandwich, got", len(check.Sandwiches))
+	}
+	sandwich := check.Sandwiches[0]
+	if sandwich.VictimTx != txs[1].Hash().Hex() || sandwich.FrontrunTx != txs[0].Hash().Hex() || sandwich.BackrunTx != txs[2].Hash().Hex() || sandwich.EstimatedVictimLoss.Int64() != 10 || len(sandwich.Searchers) != 1 {
+		t.Errorf("Wrong sandwich: %+v", sandwich)
+	}
+
+	// A tx of the searcher between the legs is no victim
+	check.Sandwiches = nil
+	bundle.Transactions[1].EoaAddress = searcher
+	check.checkSandwiches()
+	if len(check.Sandwiches) != 0 {
+		t.Error("Expected no sandwich, got", len(check.Sandwiches))
+	}
+}
+
+type testTokenLookup struct{}
+
+func (testTokenLookup) PoolTokens(ctx context.Context, pool string) (string, string, error) {
+	return "0xusdc", "0xweth", nil
+}
+
+func (testTokenLookup) Token(ctx context.Context, address string) (prices.Token, error) {
+	if address == "0xusdc" {
+		return prices.Token{Address: address, Symbol: "USDC", Decimals: 6}, nil
+	}
+	return prices.Token{}, errors.New("unknown token")
+}
+
+func TestPriceSandwiches(t *testing.T) {
+	SandwichTokens = testTokenLookup{}
+	SandwichPrices = prices.SourceFunc(func(ctx context.Context, token string, t time.Time) (float64, error) {
+		return 0.99, nil
+	})
+	defer func() { SandwichTokens, SandwichPrices = nil, nil }()
+
+	blockTime := time.Date(2021, 8, 20, 12, 0, 0, 0, time.UTC)
+	check := newReportTestCheck(100, blockTime, 0)
+	check.Sandwiches = []*Sandwich{
+		{Pool: "0xpool", EstimatedVictimLoss: big.NewInt(1500e6), LossToken: "token0"},
+		{Pool: "0xpool", EstimatedVictimLoss: big.NewInt(1e18), LossToken: "token1"}, // token lookup fails
+	}
+	if err := check.priceSandwiches(); err != nil {
+		t.Fatal(err)
+	}
+
+	priced := check.Sandwiches[0]
+	if priced.LossTokenSymbol != "USDC" || priced.EstimatedVictimLossUSD < 1484.99 || priced.EstimatedVictimLossUSD > 1485.01 {
+		t.Errorf("Wrong priced sandwich: %+v", priced)
+	}
+	if s := priced.String(); !strings.Contains(s, "estimated victim loss: 1500.0000 USDC (~$1485.00)") {
+		t.Error("Wrong sandwich string:", s)
+	}
+	if check.Sandwiches[1].EstimatedVictimLossUSD != 0 || !strings.Contains(check.Sandwiches[1].String(), "1.0000e+18 token1") {
+		t.Error("Expected the sandwich to stay unpriced:", check.Sandwiches[1].String())
+	}
+
+	report := NewReport(ReportDaily, blockTime)
+	report.AddCheck(check)
+	if report.Sandwiches != 2 || report.PricedSandwiches != 1 || !strings.Contains(report.String(), "sandwiches: 2, victim loss: $1485.00 (1 priced)") {
+		t.Error("Unexpected report:", report.String())
+	}
+}
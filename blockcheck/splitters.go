@@ -0,0 +1,131 @@
+package blockcheck
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+// Max. nested splitters followed by SplitterRegistry.Resolve
+var MaxSplitterDepth = 8
+
+// PaymentSplitter is a contract set as coinbase by a pool, which forwards the block rewards to its payees
+type PaymentSplitter struct {
+	Address string          `json:"address"`
+	Name    string          `json:"name,omitempty"` // of the pool, used if the resolved pool has no label
+	Payees  []SplitterPayee `json:"payees"`
+}
+
+type SplitterPayee struct {
+	Address string  `json:"address"` // can be another splitter
+	Share   float64 `json:"share"`   // of the payments, eg. 0.9
+}
+
+type splittersFile struct {
+	Splitters []PaymentSplitter `json:"splitters"`
+}
+
+// SplitterRegistry of known payment splitters, safe for concurrent use. Addresses are stored lowercase.
+type SplitterRegistry struct {
+	lock      sync.RWMutex
+	splitters map[string]PaymentSplitter
+}
+
+func NewSplitterRegistry() *SplitterRegistry {
+	return &SplitterRegistry{splitters: make(map[string]PaymentSplitter)}
+}
+
+// PaymentSplitters are used to resolve the pool of the coinbase of checked blocks (BlockCheck.Miner)
+var PaymentSplitters = NewSplitterRegistry()
+
+// Add adds a splitter, a known address is overwritten
+func (r *SplitterRegistry) Add(splitter PaymentSplitter) error {
+	if !ethcommon.IsHexAddress(splitter.Address) {
+		return fmt.Errorf("invalid splitter address %s", splitter.Address)
+	}
+	if len(splitter.Payees) == 0 {
+		return fmt.Errorf("splitter %s: no payees", splitter.Address)
+	}
+	for _, payee := range splitter.Payees {
+		if !ethcommon.IsHexAddress(payee.Address) {
+			return fmt.Errorf("splitter %s: invalid payee address %s", splitter.Address, payee.Address)
+		}
+		if payee.Share <= 0 || payee.Share > 1 {
+			return fmt.Errorf("splitter %s: share of payee %s must be > 0 and <= 1", splitter.Address, payee.Address)
+		}
+	}
+
+	splitter.Address = strings.ToLower(splitter.Address)
+	r.lock.Lock()
+	r.splitters[splitter.Address] = splitter
+	r.lock.Unlock()
+	return nil
+}
+
+// Load adds the splitters of a JSON file: {"splitters": [{"address": "0x...", "name": "Pool", "payees": [{"address": "0x...", "share": 1}]}]}
+func (r *SplitterRegistry) Load(data []byte) error {
+	var file splittersFile
+	err := json.Unmarshal(data, &file)
+	if err != nil {
+		return err
+	}
+
+	for _, splitter := range file.Splitters {
+		err = r.Add(splitter)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFile adds the splitters of a JSON file (see Load)
+func (r *SplitterRegistry) LoadFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if err = r.Load(data); err != nil {
+		return fmt.Errorf("error loading splitters %s: %w", filename, err)
+	}
+	return nil
+}
+
+// Get returns the splitter of the address
+func (r *SplitterRegistry) Get(address string) (splitter PaymentSplitter, found bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	splitter, found = r.splitters[strings.ToLower(address)]
+	return splitter, found
+}
+
+// Resolve follows the payments of the coinbase through the known splitters (to the payee with the largest share, at
+// most MaxSplitterDepth splitters deep), and returns the pool (checksummed) with the name of the first splitter.
+// Returns the coinbase if it isn't a known splitter.
+func (r *SplitterRegistry) Resolve(coinbase string) (pool string, name string) {
+	pool = coinbase
+	visited := make(map[string]bool)
+	for depth := 0; depth < MaxSplitterDepth; depth++ {
+		splitter, found := r.Get(pool)
+		if !found || visited[splitter.Address] {
+			break
+		}
+		visited[splitter.Address] = true
+		if name == "" {
+			name = splitter.Name
+		}
+
+		largest := splitter.Payees[0]
+		for _, payee := range splitter.Payees[1:] {
+			if payee.Share > largest.Share {
+				largest = payee
+			}
+		}
+		pool = ethcommon.HexToAddress(largest.Address).Hex()
+	}
+	return pool, name
+}
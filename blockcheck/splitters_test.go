@@ -0,0 +1,37 @@
+package blockcheck
+
+import "testing"
+
+func TestSplitterRegistry(t *testing.T) {
+	r := NewSplitterRegistry()
+	err := r.Load([]byte(`{"splitters": [
+		{"address": "0x00000000000000000000000000000000000000a1", "name": "SplitPool", "payees": [
+			{"address": "0x00000000000000000000000000000000000000f1", "share": 0.1},
+			{"address": "0x00000000000000000000000000000000000000a2", "share": 0.9}
+		]},
+		{"address": "0x00000000000000000000000000000000000000A2", "payees": [{"address": "0x0000000000000000000000000000000000000099", "share": 1}]},
+		{"address": "0x00000000000000000000000000000000000000c1", "payees": [{"address": "0x00000000000000000000000000000000000000c2", "share": 1}]},
+		{"address": "0x00000000000000000000000000000000000000c2", "payees": [{"address": "0x00000000000000000000000000000000000000c1", "share": 1}]}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Followed through both splitters to the largest payee
+	if pool, name := r.Resolve("0x00000000000000000000000000000000000000A1"); pool != "0x0000000000000000000000000000000000000099" || name != "SplitPool" {
+		t.Error("unexpected pool", pool, name)
+	}
+	if pool, name := r.Resolve("0x00000000000000000000000000000000000000dd"); pool != "0x00000000000000000000000000000000000000dd" || name != "" {
+		t.Error("expected the coinbase for an unknown address", pool, name)
+	}
+	if pool, _ := r.Resolve("0x00000000000000000000000000000000000000c1"); pool != "0x00000000000000000000000000000000000000c1" {
+		t.Error("expected a loop to end at the first splitter", pool)
+	}
+
+	if err := r.Add(PaymentSplitter{Address: "0x00000000000000000000000000000000000000e1", Payees: []SplitterPayee{{Address: "0x01", Share: 1}}}); err == nil {
+		t.Error("expected an error for an invalid payee")
+	}
+	if err := r.Add(PaymentSplitter{Address: "0x00000000000000000000000000000000000000e1", Payees: []SplitterPayee{{Address: "0x00000000000000000000000000000000000000e2", Share: 2}}}); err == nil {
+		t.Error("expected an error for an invalid share")
+	}
+}
@@ -0,0 +1,77 @@
+package blockcheck
+
+// Name of the step which traces the coinbase transfers (not a check by itself, see TraceRpcClient)
+const StepTraceCoinbaseTransfers = "trace-coinbase-transfers"
+
+// Name of the step which remembers the senders of public transactions (see KnownPublicSenders)
+const StepKnownPublicSenders = "known-public-senders"
+
+// Name of the step which traces failed tx to decode their revert reason (part of the failed-tx check)
+const StepTraceRevertReason = "trace-revert-reason"
+
+// Name of the step which prices the sandwich victim losses in USD (see SandwichPrices)
+const StepPriceSandwiches = "price-sandwiches"
+
+// Step is a stage of CheckBlock. Name is the check name (see AllChecks and RegisterCheck) or one of the Step* constants.
+type Step struct {
+	Name      string
+	Enabled   bool // false if the check is disabled or its requirements are missing (eg. TraceRpcClient)
+	Expensive bool // traces and simulations, skipped by CheckBlockFast
+	Run       func() error
+}
+
+// bundleSteps are the checks which only need the block and the Flashbots API data (see Check), followed by the
+// enabled custom checks
+func (b *BlockCheck) bundleSteps() []Step {
+	steps := []Step{
+		{CheckFailedTx, IsCheckEnabled(CheckFailedTx), false, func() error { b.checkBlockForFailedTx(); return nil }},
+		{CheckMissingBundle, IsCheckEnabled(CheckMissingBundle), false, func() error { b.checkMissingBundles(); return nil }},
+		{CheckBundleOrder, IsCheckEnabled(CheckBundleOrder), false, func() error { b.checkBundleOrder(); return nil }},
+		{CheckBundleFee, IsCheckEnabled(CheckBundleFee), false, func() error { b.checkBundleFees(); return nil }},
+		{CheckSandwich, IsCheckEnabled(CheckSandwich), false, func() error { b.checkSandwiches(); return nil }},
+		{CheckTemplateSource, IsCheckEnabled(CheckTemplateSource), false, func() error { b.checkTemplateSource(); return nil }},
+		{CheckWatchlist, WatchedAddresses.Len() > 0 && IsCheckEnabled(CheckWatchlist), false, func() error { b.checkWatchlist(); return nil }},
+	}
+	return append(steps, b.customSteps()...)
+}
+
+// Steps returns all stages of the check of this block, in the order CheckBlock runs them. Running them one by one
+// allows inspecting the intermediate state of the BlockCheck (eg. in a debugger).
+func (b *BlockCheck) Steps() []Step {
+	hasFlashbotsTx := len(b.FlashbotsTransactions) > 0
+
+	// Traced coinbase transfers (see CoinbaseTransfers), traced first so the bundle fee check also knows the tx paying
+	// the miner with internal calls. If the trace fails (eg. a node without the debug API, or a timeout), they stay
+	// nil: the coinbase transfer check is skipped, and the other checks use the direct transfers.
+	traceTransfers := func() error {
+		traced, err := TraceCoinbaseTransfers(TraceRpcClient, b.EthBlock)
+		if err != nil {
+			log.Warn("coinbase transfer trace failed", "block", b.Number, "err", err)
+			return nil
+		}
+		b.CoinbaseTransfers = traced
+		return nil
+	}
+
+	steps := []Step{
+		{StepTraceCoinbaseTransfers, TraceRpcClient != nil && ((IsCheckEnabled(CheckCoinbaseTransfers) && hasFlashbotsTx) || IsCheckEnabled(CheckPrivateOrderFlow)), true, traceTransfers},
+	}
+	steps = append(steps, b.bundleSteps()...)
+	steps = append(steps, []Step{
+		{StepPriceSandwiches, SandwichTokens != nil && SandwichPrices != nil && IsCheckEnabled(CheckSandwich), true, b.priceSandwiches},
+		{StepSimulateBundleOrder, SimulationRpc != nil && IsCheckEnabled(CheckBundleOrder), true, b.simulateBundleOrder},
+		{CheckCoinbaseTransfers, TraceRpcClient != nil && IsCheckEnabled(CheckCoinbaseTransfers) && hasFlashbotsTx, true, func() error {
+			if b.CoinbaseTransfers != nil {
+				b.checkCoinbaseTransfers(b.CoinbaseTransfers)
+			}
+			return nil
+		}},
+		{CheckPrivateOrderFlow, IsCheckEnabled(CheckPrivateOrderFlow), false, func() error { b.checkPrivateOrderFlow(b.CoinbaseTransfers); return nil }},
+		{CheckRelayPayment, len(RelayClients) > 0 && IsCheckEnabled(CheckRelayPayment), false, func() error { b.checkRelayPayments(b.CoinbaseTransfers); return nil }},
+		{CheckProposerPayment, BeaconClient != nil && len(RelayClients) > 0 && IsCheckEnabled(CheckProposerPayment), false, func() error { b.auditProposerPayment(b.CoinbaseTransfers); return nil }},
+		{CheckBuilderProfit, IsCheckEnabled(CheckBuilderProfit), false, func() error { b.checkBuilderProfit(b.CoinbaseTransfers); return nil }},
+		{StepVerifyBundles, SimulationRpc != nil && VerifyBundles, true, b.verifyBundles},
+		{StepKnownPublicSenders, true, false, func() error { b.addKnownPublicSenders(); return nil }},
+	}...)
+	return steps
+}
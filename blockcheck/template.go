@@ -0,0 +1,123 @@
+package blockcheck
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+// Inferred sources of the block template (see checkTemplateSource)
+const (
+	TemplateSourceFlashbots = "flashbots" // placed like the Flashbots template: bundles on top, then the tx by gas price
+	TemplateSourceModified  = "modified"  // the miner changed the ordering locally
+)
+
+// templateTx is a transaction of the block, for the template inference
+type templateTx struct {
+	isBundleTx  bool
+	sender      string
+	priorityFee *big.Int
+	gasUsed     int64 // of the receipt
+	apiGasUsed  int64 // of the Flashbots API (bundle tx only)
+}
+
+// inferTemplateSource compares the block with the Flashbots template (mev-geth): the bundles are placed at the top of
+// the block, directly after each other and ordered by gas price, followed by the other tx ordered by priority fee
+// (tx of the same sender by nonce). Returns the inferred source and the deviations, or "" if there are no bundle tx.
+func inferTemplateSource(txs []templateTx, bundlesOutOfOrder bool) (source string, signals []string) {
+	firstBundleTx, lastBundleTx, numBundleTx := -1, -1, 0
+	gasMismatches := 0
+	for i, tx := range txs {
+		if !tx.isBundleTx {
+			continue
+		}
+		if firstBundleTx == -1 {
+			firstBundleTx = i
+		}
+		lastBundleTx = i
+		numBundleTx += 1
+		if tx.apiGasUsed > 0 && tx.gasUsed != tx.apiGasUsed {
+			gasMismatches += 1
+		}
+	}
+	if numBundleTx == 0 {
+		return "", nil
+	}
+
+	if firstBundleTx > 0 {
+		signals = append(signals, fmt.Sprintf("bundles start at tx %d, not at the top", firstBundleTx))
+	}
+	if inserted := lastBundleTx - firstBundleTx + 1 - numBundleTx; inserted > 0 {
+		signals = append(signals, fmt.Sprintf("%d other tx between the bundles", inserted))
+	}
+	if bundlesOutOfOrder {
+		signals = append(signals, "bundles not ordered by gas price")
+	}
+
+	// In the tail, a tx can only pay more than the previous one if it's the next tx of the same sender
+	inversions := 0
+	for i := lastBundleTx + 2; i < len(txs); i++ {
+		previous, tx := txs[i-1], txs[i]
+		if previous.isBundleTx || tx.isBundleTx || previous.sender == tx.sender {
+			continue
+		}
+		if tx.priorityFee.Cmp(previous.priorityFee) > 0 {
+			inversions += 1
+		}
+	}
+	if inversions > 0 {
+		signals = append(signals, fmt.Sprintf("%d tx after the bundles not ordered by gas price", inversions))
+	}
+	if gasMismatches > 0 {
+		signals = append(signals, fmt.Sprintf("%d bundle tx used other gas than in the Flashbots API", gasMismatches))
+	}
+
+	if len(signals) > 0 {
+		return TemplateSourceModified, signals
+	}
+	return TemplateSourceFlashbots, nil
+}
+
+// checkTemplateSource infers whether the miner used the Flashbots ordering or modified the block locally, by the
+// placement of the bundles, the ordering of the other tx and the gas used by the bundle tx (informational, not
+// counted as error). Uses the bundle order of the bundle-order check.
+func (b *BlockCheck) checkTemplateSource() {
+	apiGasUsed := make(map[string]int64)
+	for _, tx := range b.FlashbotsTransactions {
+		apiGasUsed[strings.ToLower(tx.Hash)] = tx.GasUsed
+	}
+
+	bundlesOutOfOrder := false
+	for _, bundle := range b.Bundles {
+		bundlesOutOfOrder = bundlesOutOfOrder || bundle.IsOutOfOrder
+	}
+
+	baseFee := b.EthBlock.BaseFee()
+	txs := make([]templateTx, 0, len(b.EthBlock.Transactions()))
+	for _, tx := range b.EthBlock.Transactions() {
+		ttx := templateTx{priorityFee: common.TxPriorityFee(tx, baseFee)}
+		ttx.apiGasUsed, ttx.isBundleTx = apiGasUsed[strings.ToLower(tx.Hash().Hex())]
+		if receipt := b.BlockWithTxReceipts.TxReceipts[tx.Hash()]; receipt != nil {
+			ttx.gasUsed = int64(receipt.GasUsed)
+		} else {
+			ttx.apiGasUsed = 0 // can't compare
+		}
+		if sender, err := utils.GetTxSender(tx); err == nil {
+			ttx.sender = sender.Hex()
+		}
+		txs = append(txs, ttx)
+	}
+
+	b.TemplateSource, b.TemplateSignals = inferTemplateSource(txs, bundlesOutOfOrder)
+}
+
+// TemplateString describes the inferred template source (empty for blocks without bundles)
+func (b *BlockCheck) TemplateString() string {
+	if b.TemplateSource == TemplateSourceModified {
+		return "block template modified by the miner: " + strings.Join(b.TemplateSignals, ", ")
+	}
+	return b.TemplateSource
+}
@@ -0,0 +1,43 @@
+package blockcheck
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestInferTemplateSource(t *testing.T) {
+	bundleTx := func(gasUsed, apiGasUsed int64) templateTx {
+		return templateTx{isBundleTx: true, sender: "searcher", priorityFee: big.NewInt(0), gasUsed: gasUsed, apiGasUsed: apiGasUsed}
+	}
+	tx := func(sender string, priorityFee int64) templateTx {
+		return templateTx{sender: sender, priorityFee: big.NewInt(priorityFee), gasUsed: 21000}
+	}
+
+	if source, _ := inferTemplateSource([]templateTx{tx("a", 3), tx("b", 2)}, false); source != "" {
+		t.Error("expected no source for a block without bundles, got", source)
+	}
+
+	// Bundles on top, then the tail by priority fee (same sender in nonce order)
+	txs := []templateTx{bundleTx(100, 100), bundleTx(200, 200), tx("a", 5), tx("b", 3), tx("b", 4), tx("c", 1)}
+	if source, signals := inferTemplateSource(txs, false); source != TemplateSourceFlashbots || len(signals) != 0 {
+		t.Error("expected the flashbots template", source, signals)
+	}
+
+	tests := []struct {
+		name       string
+		txs        []templateTx
+		outOfOrder bool
+	}{
+		{"bundles not at the top", []templateTx{tx("a", 5), bundleTx(100, 100), tx("b", 3)}, false},
+		{"tx between the bundles", []templateTx{bundleTx(100, 100), tx("a", 5), bundleTx(100, 100)}, false},
+		{"bundles out of order", []templateTx{bundleTx(100, 100), bundleTx(100, 100)}, true},
+		{"tail not ordered", []templateTx{bundleTx(100, 100), tx("a", 3), tx("b", 5)}, false},
+		{"gas used differs", []templateTx{bundleTx(100, 120), tx("a", 3)}, false},
+	}
+	for _, test := range tests {
+		source, signals := inferTemplateSource(test.txs, test.outOfOrder)
+		if source != TemplateSourceModified || len(signals) != 1 {
+			t.Errorf("%s: expected one signal, got %s %v", test.name, source, signals)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package blockcheck
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/metachris/flashbots/common"
+)
+
+// Roles of a watched address in a Flashbots transaction
+const (
+	WatchRoleSender                      = "sender"
+	WatchRoleRecipient                   = "recipient"
+	WatchRoleCoinbaseTransferBeneficiary = "coinbase-transfer beneficiary"
+)
+
+// WatchedAddresses are reported when they appear in Flashbots transactions (see the watchlist check)
+var WatchedAddresses = NewWatchlist()
+
+// Watchlist of addresses with optional names, safe for concurrent use. Addresses are stored lowercase.
+type Watchlist struct {
+	lock      sync.RWMutex
+	addresses map[string]string // address -> name
+}
+
+func NewWatchlist() *Watchlist {
+	return &Watchlist{addresses: make(map[string]string)}
+}
+
+// Add adds an address, name can be empty
+func (w *Watchlist) Add(address string, name string) error {
+	address = strings.ToLower(strings.TrimSpace(address))
+	if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+		return fmt.Errorf("invalid address %s", address)
+	}
+
+	w.lock.Lock()
+	w.addresses[address] = strings.TrimSpace(name)
+	w.lock.Unlock()
+	return nil
+}
+
+// AddList adds comma-separated addresses (eg. of a command line flag)
+func (w *Watchlist) AddList(addresses string) error {
+	for _, address := range strings.Split(addresses, ",") {
+		if err := w.Add(address, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load adds one address per line, optionally followed by a comma and a name. Empty lines and lines starting with
+// # are skipped.
+func (w *Watchlist) Load(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		address, name := text, ""
+		if i := strings.Index(text, ","); i >= 0 {
+			address, name = text[:i], text[i+1:]
+		}
+		if err := w.Add(address, name); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// LoadFile adds the addresses of a file (see Load)
+func (w *Watchlist) LoadFile(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err = w.Load(f); err != nil {
+		return fmt.Errorf("error loading watchlist %s: %w", filename, err)
+	}
+	return nil
+}
+
+// Get returns the name of the address (can be empty), and whether it's watched
+func (w *Watchlist) Get(address string) (name string, found bool) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	name, found = w.addresses[strings.ToLower(address)]
+	return name, found
+}
+
+func (w *Watchlist) Len() int {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return len(w.addresses)
+}
+
+// WatchlistMatch is a watched address in a Flashbots transaction
+type WatchlistMatch struct {
+	Address     string
+	Name        string
+	Role        string // see the WatchRole* constants
+	TxHash      string
+	BundleIndex int64
+}
+
+func (m *WatchlistMatch) String() string {
+	address := m.Address
+	if m.Name != "" {
+		address = fmt.Sprintf("%s (%s)", m.Name, m.Address)
+	}
+	return fmt.Sprintf("watchlist: %s is %s of tx [%s](<%s>) in bundle %d", address, m.Role, m.TxHash, common.TxUrl(m.TxHash), m.BundleIndex)
+}
+
+// checkWatchlist finds the watched addresses among the senders, recipients and coinbase transfer beneficiaries (the
+// block's coinbase) of the Flashbots transactions (informational, not counted as error)
+func (b *BlockCheck) checkWatchlist() {
+	for _, tx := range b.FlashbotsTransactions {
+		candidates := map[string]string{WatchRoleSender: tx.EoaAddress, WatchRoleRecipient: tx.ToAddress}
+		if coinbaseTransfer, ok := new(big.Int).SetString(tx.CoinbaseTransfer, 10); ok && coinbaseTransfer.Sign() > 0 {
+			candidates[WatchRoleCoinbaseTransferBeneficiary] = b.Coinbase
+		}
+
+		for _, role := range []string{WatchRoleSender, WatchRoleRecipient, WatchRoleCoinbaseTransferBeneficiary} {
+			address, isCandidate := candidates[role]
+			if !isCandidate {
+				continue
+			}
+			if name, found := WatchedAddresses.Get(address); found {
+				b.WatchlistMatches = append(b.WatchlistMatches, &WatchlistMatch{Address: address, Name: name, Role: role, TxHash: tx.Hash, BundleIndex: tx.BundleIndex})
+			}
+		}
+	}
+}
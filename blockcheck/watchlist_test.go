@@ -0,0 +1,53 @@
+package blockcheck
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/metachris/flashbots/api"
+)
+
+func TestWatchlist(t *testing.T) {
+	watchlist := NewWatchlist()
+	err := watchlist.Load(strings.NewReader("# my contracts\n0x00000000000000000000000000000000000000AA, Router\n\n0x00000000000000000000000000000000000000bb\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = watchlist.AddList("0x00000000000000000000000000000000000000cc"); err != nil {
+		t.Fatal(err)
+	}
+	if name, found := watchlist.Get("0x00000000000000000000000000000000000000aa"); !found || name != "Router" {
+		t.Error("unexpected name", name, found)
+	}
+	if watchlist.Len() != 3 {
+		t.Error("expected 3 addresses, got", watchlist.Len())
+	}
+	if err = watchlist.Load(strings.NewReader("0x1234\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Error("expected an error for an invalid address", err)
+	}
+
+	WatchedAddresses = watchlist
+	defer func() { WatchedAddresses = NewWatchlist() }()
+
+	check := &BlockCheck{
+		Coinbase: "0x00000000000000000000000000000000000000CC",
+		FlashbotsTransactions: []api.FlashbotsTransaction{
+			{Hash: "0x1", BundleIndex: 0, EoaAddress: "0x00000000000000000000000000000000000000bb", ToAddress: "0x00000000000000000000000000000000000000aa", CoinbaseTransfer: "0"},
+			{Hash: "0x2", BundleIndex: 1, EoaAddress: "0x0000000000000000000000000000000000000001", ToAddress: "0x0000000000000000000000000000000000000002", CoinbaseTransfer: "1000"},
+		},
+	}
+	check.checkWatchlist()
+
+	if len(check.WatchlistMatches) != 3 {
+		t.Fatal("expected 3 matches, got", len(check.WatchlistMatches))
+	}
+	if m := check.WatchlistMatches[0]; m.Role != WatchRoleSender || m.TxHash != "0x1" {
+		t.Error("unexpected match", m)
+	}
+	if m := check.WatchlistMatches[1]; m.Role != WatchRoleRecipient || m.Name != "Router" {
+		t.Error("unexpected match", m)
+	}
+	if m := check.WatchlistMatches[2]; m.Role != WatchRoleCoinbaseTransferBeneficiary || m.BundleIndex != 1 {
+		t.Error("unexpected match", m)
+	}
+}
@@ -0,0 +1,63 @@
+package blocksource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+// Archive reads exported blocks with their receipts (blockcheck.CheckInput): a directory of <block>.json files (see
+// the dump subcommand of block-watch), which are read when they are requested, or a JSON lines file (eg. the
+// inputs.jsonl archive of watcher.JSONLSink), which is loaded on open.
+type Archive struct {
+	dir    string                           // directory of <block>.json files
+	inputs map[int64]*blockcheck.CheckInput // of the JSON lines file
+}
+
+// OpenArchive opens a directory of fixtures, or loads a JSON lines file
+func OpenArchive(path string) (*Archive, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &Archive{dir: path}, nil
+	}
+
+	inputs, err := blockcheck.LoadCheckInputs(path)
+	if err != nil {
+		return nil, err
+	}
+	archive := &Archive{inputs: make(map[int64]*blockcheck.CheckInput, len(inputs))}
+	for _, input := range inputs {
+		archive.inputs[input.BlockNumber] = input // the last one after re-checks
+	}
+	log.Info("archive loaded", "file", path, "blocks", len(archive.inputs))
+	return archive, nil
+}
+
+func (s *Archive) GetBlockWithTxReceipts(ctx context.Context, height int64) (*blockswithtx.BlockWithTxReceipts, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	input := s.inputs[height]
+	if s.dir != "" {
+		inputs, err := blockcheck.LoadCheckInputs(filepath.Join(s.dir, fmt.Sprintf("%d.json", height)))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if len(inputs) > 0 {
+			input = inputs[0]
+		}
+	}
+	if input == nil {
+		return nil, fmt.Errorf("block %d: %w", height, ErrNotFound)
+	}
+	return input.BlockWithTxReceipts()
+}
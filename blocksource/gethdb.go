@@ -0,0 +1,87 @@
+package blocksource
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+// Cache size and max. open files of the database
+var (
+	GethDBCacheMB = 512
+	GethDBHandles = 256
+)
+
+// GethDB reads the canonical blocks and their receipts from the LevelDB database of a geth node, including the
+// ancient blocks of the freezer (<chaindata>/ancient). The node has to be stopped (or use a copy of the chaindata),
+// LevelDB allows only one process. Erigon's database has another format, use the RPC source with Erigon's rpcdaemon
+// on the same machine instead (it serves eth_getBlockReceipts from the local database).
+type GethDB struct {
+	db     ethdb.Database
+	config *params.ChainConfig // to derive the receipt fields
+}
+
+// OpenGethDB opens the chaindata directory read-only. Erigon databases (MDBX) are rejected, see GethDB.
+func OpenGethDB(chaindata string) (*GethDB, error) {
+	if _, err := os.Stat(filepath.Join(chaindata, "mdbx.dat")); err == nil {
+		return nil, fmt.Errorf("%s is an Erigon database, which is not supported: use the RPC source with Erigon's rpcdaemon instead", chaindata)
+	}
+
+	db, err := rawdb.NewLevelDBDatabaseWithFreezer(chaindata, GethDBCacheMB, GethDBHandles, filepath.Join(chaindata, "ancient"), "", true)
+	if err != nil {
+		return nil, fmt.Errorf("error opening geth database %s: %w", chaindata, err)
+	}
+
+	genesisHash := rawdb.ReadCanonicalHash(db, 0)
+	config := rawdb.ReadChainConfig(db, genesisHash)
+	if config == nil {
+		db.Close()
+		return nil, fmt.Errorf("geth database %s: no chain config for genesis %s", chaindata, genesisHash.Hex())
+	}
+	if head := rawdb.ReadHeaderNumber(db, rawdb.ReadHeadBlockHash(db)); head != nil {
+		log.Info("geth database opened", "dir", chaindata, "chain", config.ChainID, "head", *head)
+	}
+	return &GethDB{db: db, config: config}, nil
+}
+
+func (s *GethDB) GetBlockWithTxReceipts(ctx context.Context, height int64) (*blockswithtx.BlockWithTxReceipts, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	number := uint64(height)
+	hash := rawdb.ReadCanonicalHash(s.db, number)
+	if hash == (ethcommon.Hash{}) {
+		return nil, fmt.Errorf("block %d: %w", height, ErrNotFound)
+	}
+	block := rawdb.ReadBlock(s.db, hash, number)
+	if block == nil {
+		return nil, fmt.Errorf("block %d (%s): %w", height, hash.Hex(), ErrNotFound)
+	}
+
+	receipts := rawdb.ReadReceipts(s.db, hash, number, s.config)
+	if len(receipts) != len(block.Transactions()) {
+		return nil, fmt.Errorf("block %d: %d receipts for %d transactions (pruned?)", height, len(receipts), len(block.Transactions()))
+	}
+	txReceipts := make(map[ethcommon.Hash]*types.Receipt, len(receipts))
+	for _, receipt := range receipts {
+		txReceipts[receipt.TxHash] = receipt
+	}
+	return &blockswithtx.BlockWithTxReceipts{Block: block, TxReceipts: txReceipts}, nil
+}
+
+func (s *GethDB) ChainID(ctx context.Context) (*big.Int, error) {
+	return new(big.Int).Set(s.config.ChainID), nil
+}
+
+func (s *GethDB) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,129 @@
+// Package blocksource abstracts where blocks and the receipts of their transactions come from: a node over JSON-RPC,
+// the database of a geth node, or archive files of exported blocks. Historical analyses over many blocks can read the
+// database or archive files directly instead of going through slow RPC.
+//
+// Usage:
+//
+//	source, err := blocksource.Open(ctx, "gethdb:/data/geth/chaindata")
+//	defer blocksource.Close(source)
+//	blocks, err := blocksource.GetBlocks(ctx, source, []int64{13100622, 13100623}, 8)
+package blocksource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/metachris/flashbots/logging"
+	"github.com/metachris/flashbots/receipts"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+var log = logging.Module("blocksource")
+
+var (
+	ErrNotFound     = errors.New("block not found")
+	ErrUnknownChain = errors.New("the source doesn't know its chain")
+)
+
+// URI prefixes of the sources which aren't nodes (see Open)
+const (
+	PrefixGethDB  = "gethdb:"
+	PrefixArchive = "archive:"
+)
+
+// Source returns a block with the receipts of all its transactions (eg. receipts.Fetcher)
+type Source interface {
+	GetBlockWithTxReceipts(ctx context.Context, height int64) (*blockswithtx.BlockWithTxReceipts, error)
+}
+
+// Open returns the source of the uri:
+//
+//   - gethdb:<chaindata dir> reads the database of a stopped geth node (see GethDB, Erigon databases aren't supported)
+//   - archive:<file or dir> reads exported blocks (see Archive)
+//   - anything else is the URI of a node (http, ws or IPC), with batched receipt requests (see receipts.Fetcher)
+//
+// Release the source with Close.
+func Open(ctx context.Context, uri string) (Source, error) {
+	switch {
+	case strings.HasPrefix(uri, PrefixGethDB):
+		return OpenGethDB(strings.TrimPrefix(uri, PrefixGethDB))
+	case strings.HasPrefix(uri, PrefixArchive):
+		return OpenArchive(strings.TrimPrefix(uri, PrefixArchive))
+	}
+
+	rpcClient, err := rpc.DialContext(ctx, uri)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", uri, err)
+	}
+	return &RPC{Fetcher: receipts.NewFetcher(rpcClient), rpcClient: rpcClient}, nil
+}
+
+// Close releases the database or connection of the source, if it has one
+func Close(source Source) error {
+	if closer, ok := source.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// ChainID returns the chain id of the source, ErrUnknownChain if the source doesn't know it (archive files)
+func ChainID(ctx context.Context, source Source) (*big.Int, error) {
+	if chain, ok := source.(interface {
+		ChainID(ctx context.Context) (*big.Int, error)
+	}); ok {
+		return chain.ChainID(ctx)
+	}
+	return nil, ErrUnknownChain
+}
+
+// GetBlocks returns the blocks (in the order of heights), with up to workers blocks in parallel. Returns the first
+// error.
+func GetBlocks(ctx context.Context, source Source, heights []int64, workers int) ([]*blockswithtx.BlockWithTxReceipts, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	blocks := make([]*blockswithtx.BlockWithTxReceipts, len(heights))
+	errs := make([]error, len(heights))
+
+	var wg sync.WaitGroup
+	sem := make(chan bool, workers)
+	for i := range heights {
+		wg.Add(1)
+		sem <- true
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			blocks[i], errs[i] = source.GetBlockWithTxReceipts(ctx, heights[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return blocks, nil
+}
+
+// RPC is a node, with batched receipt requests
+type RPC struct {
+	*receipts.Fetcher
+	rpcClient *rpc.Client
+}
+
+func (s *RPC) ChainID(ctx context.Context) (*big.Int, error) {
+	return ethclient.NewClient(s.rpcClient).ChainID(ctx)
+}
+
+func (s *RPC) Close() error {
+	s.rpcClient.Close()
+	return nil
+}
@@ -0,0 +1,94 @@
+package blocksource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+func newTestInput(t *testing.T, number int64) *blockcheck.CheckInput {
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number), Difficulty: big.NewInt(1)})
+	input, err := blockcheck.NewCheckInput(&blockcheck.BlockCheck{Number: number, EthBlock: block, BlockWithTxReceipts: &blockswithtx.BlockWithTxReceipts{Block: block}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input
+}
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	if err := newTestInput(t, 100).WriteFile(filepath.Join(dir, "100.json")); err != nil {
+		t.Fatal(err)
+	}
+	var lines []byte
+	for _, number := range []int64{101, 102} {
+		data, _ := json.Marshal(newTestInput(t, number))
+		lines = append(append(lines, data...), '\n')
+	}
+	file := filepath.Join(t.TempDir(), "inputs.jsonl")
+	if err := os.WriteFile(file, lines, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, heights := range map[string][]int64{dir: {100}, file: {101, 102}} {
+		source, err := Open(context.Background(), PrefixArchive+path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks, err := GetBlocks(context.Background(), source, heights, 2)
+		if err != nil || len(blocks) != len(heights) || blocks[0].Block.Number().Int64() != heights[0] {
+			t.Error("unexpected blocks", path, blocks, err)
+		}
+		if _, err = source.GetBlockWithTxReceipts(context.Background(), 99); !errors.Is(err, ErrNotFound) {
+			t.Error("expected ErrNotFound", path, err)
+		}
+		if _, err = ChainID(context.Background(), source); !errors.Is(err, ErrUnknownChain) {
+			t.Error("expected ErrUnknownChain", err)
+		}
+		if err = Close(source); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+type testSource map[int64]*blockswithtx.BlockWithTxReceipts
+
+func (s testSource) GetBlockWithTxReceipts(ctx context.Context, height int64) (*blockswithtx.BlockWithTxReceipts, error) {
+	if block := s[height]; block != nil {
+		return block, nil
+	}
+	return nil, ErrNotFound
+}
+
+func TestGetBlocks(t *testing.T) {
+	source := make(testSource)
+	for _, number := range []int64{1, 2, 3} {
+		source[number] = &blockswithtx.BlockWithTxReceipts{Block: types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number)})}
+	}
+
+	blocks, err := GetBlocks(context.Background(), source, []int64{3, 1, 2}, 0)
+	if err != nil || len(blocks) != 3 || blocks[0] != source[3] || blocks[1] != source[1] {
+		t.Error("expected the blocks in the order of the heights", err)
+	}
+	if _, err = GetBlocks(context.Background(), source, []int64{1, 4}, 2); !errors.Is(err, ErrNotFound) {
+		t.Error("expected ErrNotFound", err)
+	}
+}
+
+func TestOpenErigonDB(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "mdbx.dat"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(context.Background(), PrefixGethDB+dir); err == nil {
+		t.Error("expected an error for an Erigon database")
+	}
+}
@@ -0,0 +1,197 @@
+// Package chaos injects failures into HTTP requests (Flashbots API, relays, HTTP RPC nodes), to verify that retries,
+// failover and alerting behave correctly. For testing only, never enable it in production.
+package chaos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrInjectedTimeout = errors.New("chaos: injected timeout")
+
+// Rates are the probabilities (0..1) of the injected failures, per request
+type Rates struct {
+	Errors    float64 // respond with an error status (429, 500, 502 or 503) without sending the request
+	Timeouts  float64 // hang until Timeout or the request context is done, then fail
+	Malformed float64 // send the request, but truncate the response body
+}
+
+// ParseRates parses a comma-separated list like "errors=0.1,timeouts=0.05,malformed=0.05"
+func ParseRates(s string) (rates Rates, err error) {
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return rates, fmt.Errorf("invalid chaos rate '%s', expected name=rate", part)
+		}
+		rate, err := strconv.ParseFloat(kv[1], 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return rates, fmt.Errorf("invalid chaos rate '%s', must be between 0 and 1", part)
+		}
+
+		switch kv[0] {
+		case "errors":
+			rates.Errors = rate
+		case "timeouts":
+			rates.Timeouts = rate
+		case "malformed":
+			rates.Malformed = rate
+		default:
+			return rates, fmt.Errorf("unknown chaos failure '%s' (errors, timeouts, malformed)", kv[0])
+		}
+	}
+	return rates, nil
+}
+
+func (r Rates) String() string {
+	return fmt.Sprintf("errors=%.2f, timeouts=%.2f, malformed=%.2f", r.Errors, r.Timeouts, r.Malformed)
+}
+
+// Stats are the number of requests and injected failures of a Transport
+type Stats struct {
+	Requests  uint64
+	Errors    uint64
+	Timeouts  uint64
+	Malformed uint64
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("requests: %d, injected errors: %d, timeouts: %d, malformed: %d", s.Requests, s.Errors, s.Timeouts, s.Malformed)
+}
+
+// Transport is a http.RoundTripper which injects failures at the configured rates
+type Transport struct {
+	Base    http.RoundTripper // nil = http.DefaultTransport
+	Rates   Rates
+	Timeout time.Duration // max. duration of an injected timeout (0 = until the request context is done)
+
+	lock  sync.Mutex
+	rand  *rand.Rand
+	stats Stats
+}
+
+// NewTransport returns a Transport with the given rates. The seed makes the failure sequence reproducible.
+func NewTransport(rates Rates, seed int64) *Transport {
+	return &Transport{
+		Rates:   rates,
+		Timeout: 30 * time.Second,
+		rand:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Client returns a http.Client using this transport
+func (t *Transport) Client(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: t, Timeout: timeout}
+}
+
+// Stats returns the request and failure counts
+func (t *Transport) Stats() Stats {
+	return Stats{
+		Requests:  atomic.LoadUint64(&t.stats.Requests),
+		Errors:    atomic.LoadUint64(&t.stats.Errors),
+		Timeouts:  atomic.LoadUint64(&t.stats.Timeouts),
+		Malformed: atomic.LoadUint64(&t.stats.Malformed),
+	}
+}
+
+// pick returns the failure to inject for a request ("" for none)
+func (t *Transport) pick() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	x := t.rand.Float64()
+	switch {
+	case x < t.Rates.Errors:
+		return "errors"
+	case x < t.Rates.Errors+t.Rates.Timeouts:
+		return "timeouts"
+	case x < t.Rates.Errors+t.Rates.Timeouts+t.Rates.Malformed:
+		return "malformed"
+	}
+	return ""
+}
+
+func (t *Transport) errorStatus() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	statusCodes := []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}
+	return statusCodes[t.rand.Intn(len(statusCodes))]
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddUint64(&t.stats.Requests, 1)
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	switch t.pick() {
+	case "errors":
+		atomic.AddUint64(&t.stats.Errors, 1)
+		status := t.errorStatus()
+		resp := &http.Response{
+			Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			StatusCode: status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     make(http.Header),
+			Body:       io.NopCloser(strings.NewReader("chaos: injected error\n")),
+			Request:    req,
+		}
+		if status == http.StatusTooManyRequests {
+			resp.Header.Set("Retry-After", "1")
+		}
+		return resp, nil
+
+	case "timeouts":
+		atomic.AddUint64(&t.stats.Timeouts, 1)
+		ctx := req.Context()
+		if t.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+			defer cancel()
+		}
+		<-ctx.Done()
+		if req.Context().Err() != nil {
+			return nil, req.Context().Err()
+		}
+		return nil, ErrInjectedTimeout
+
+	case "malformed":
+		atomic.AddUint64(&t.stats.Malformed, 1)
+		resp, err := base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		// Cut the body in half, which breaks any JSON document
+		body = append(body[:len(body)/2], []byte("<chaos>")...)
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		resp.ContentLength = int64(len(body))
+		resp.Header.Del("Content-Length")
+		return resp, nil
+	}
+
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,125 @@
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRates(t *testing.T) {
+	rates, err := ParseRates("errors=0.1, timeouts=0.05,malformed=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rates.Errors != 0.1 || rates.Timeouts != 0.05 || rates.Malformed != 1 {
+		t.Error("Wrong rates:", rates)
+	}
+
+	for _, s := range []string{"errors", "errors=2", "errors=x", "crashes=0.1"} {
+		if _, err := ParseRates(s); err == nil {
+			t.Error("Expected an error for", s)
+		}
+	}
+}
+
+func newTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"latest_block_number": 123}`)
+	}))
+}
+
+func TestTransportNoFailures(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	transport := NewTransport(Rates{}, 1)
+	resp, err := transport.Client(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var v map[string]int64
+	if err = json.NewDecoder(resp.Body).Decode(&v); err != nil || v["latest_block_number"] != 123 {
+		t.Error("Unexpected response:", v, err)
+	}
+	if stats := transport.Stats(); stats.Requests != 1 || stats.Errors+stats.Timeouts+stats.Malformed != 0 {
+		t.Error("Unexpected stats:", stats)
+	}
+}
+
+func TestTransportErrors(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	transport := NewTransport(Rates{Errors: 1}, 1)
+	resp, err := transport.Client(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 400 {
+		t.Error("Expected an error status, got", resp.Status)
+	}
+	if transport.Stats().Errors != 1 {
+		t.Error("Unexpected stats:", transport.Stats())
+	}
+}
+
+func TestTransportTimeouts(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	transport := NewTransport(Rates{Timeouts: 1}, 1)
+	transport.Timeout = 10 * time.Millisecond
+	_, err := transport.Client(time.Second).Get(server.URL)
+	if !errors.Is(err, ErrInjectedTimeout) {
+		t.Error("Expected an injected timeout, got", err)
+	}
+
+	// The request context ends the timeout
+	transport.Timeout = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	_, err = transport.Client(0).Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("Expected a context error, got", err)
+	}
+}
+
+func TestTransportMalformed(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	transport := NewTransport(Rates{Malformed: 1}, 1)
+	resp, err := transport.Client(time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var v map[string]int64
+	if err = json.NewDecoder(resp.Body).Decode(&v); err == nil {
+		t.Error("Expected a decode error, got", v)
+	}
+}
+
+func TestTransportRates(t *testing.T) {
+	transport := NewTransport(Rates{Errors: 0.2, Timeouts: 0.1, Malformed: 0.1}, 42)
+	counts := make(map[string]int)
+	for i := 0; i < 10_000; i++ {
+		counts[transport.pick()] += 1
+	}
+
+	for name, want := range map[string]int{"errors": 2000, "timeouts": 1000, "malformed": 1000, "": 6000} {
+		if counts[name] < want*8/10 || counts[name] > want*12/10 {
+			t.Errorf("%s: got %d, want about %d", name, counts[name], want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/metachris/flashbots/schema"
+)
+
+// The badge shows "API lagging" if more blocks than this (plus the confirmations) are waiting to be checked
+var BadgeMaxLag int64 = 15
+
+const badgeLabel = "block-watch"
+
+// Badge colors (shields.io, hex without #)
+const (
+	badgeGreen  = "4c1"
+	badgeOrange = "fe7d37"
+	badgeRed    = "e05d44"
+	badgeGrey   = "9f9f9f"
+)
+
+// badgeStatus returns the message and color of the badge: OK, N serious errors today, or API lagging
+func badgeStatus(status ServiceStatus, confirmations int64) (message string, color string) {
+	switch {
+	case status.State != "watching":
+		return status.State, badgeGrey
+	case status.Lag > confirmations+BadgeMaxLag:
+		return fmt.Sprintf("API lagging (%d blocks)", status.Lag), badgeOrange
+	case status.SeriousErrorsToday == 1:
+		return "1 serious error today", badgeRed
+	case status.SeriousErrorsToday > 1:
+		return fmt.Sprintf("%d serious errors today", status.SeriousErrorsToday), badgeRed
+	}
+	return "OK", badgeGreen
+}
+
+// renderBadge returns a flat shields.io style SVG badge. Text widths are estimated (Verdana 11px).
+func renderBadge(label string, message string, color string) []byte {
+	textWidth := func(s string) int { return len(s)*7 + 10 }
+	labelWidth, messageWidth := textWidth(label), textWidth(message)
+	width := labelWidth + messageWidth
+	title := html.EscapeString(label + ": " + message)
+
+	return []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s">`+
+		`<title>%s</title>`+
+		`<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`+
+		`<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`+
+		`<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="#%s"/><rect width="%d" height="20" fill="url(#s)"/></g>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%d" y="14">%s</text><text x="%d" y="14">%s</text></g></svg>`,
+		width, title, title, width, labelWidth, labelWidth, messageWidth, color, width,
+		labelWidth/2, html.EscapeString(label), labelWidth+messageWidth/2, html.EscapeString(message)))
+}
+
+// statusHandler serves the monitoring status:
+//
+//	GET /badge.svg    - SVG badge
+//	GET /badge.json   - shields.io endpoint badge (https://shields.io/endpoint)
+//	GET /status.json  - the full status, as with the status subcommand
+//	GET /healthz      - liveness: 200 while the watcher receives new blocks, else 503 (see watcher.HealthHandler)
+//	GET /readyz       - readiness: 200 if the node and API are reachable and the head lag is below -maxheadlag, else 503
+//	GET /census.json  - top protocols and contracts touched by bundles, with -census (?days=1&top=20&by=bundles|reward)
+//	GET /stream       - check results and alerts as Server-Sent Events (see watcher.StreamHandler)
+//	GET /dashboard    - charts of the errors and bundles per hour, the API lag, the miner leaderboard and the latest checks
+//	GET /dashboard.json - the hourly chart data and the miner leaderboard, from the rollups (?hours=24)
+//	GET /notifications.json - the notifications sent, newest first, with -notificationlog (?since=&until=&channel=&status=&block=&incident=&limit=100)
+//	GET /v1/blocks    - blocks in the format of the mev-blocks API, annotated with the check results, with -explorer
+//	GET /v1/transactions - transactions in the format of the mev-blocks API, with -explorer
+//	GET /replica      - signed dumps of the check results and incidents, with -replicakey (see watcher.ReplicaHandler)
+//	POST /discord/interactions - Discord slash commands, with -discordbotkey (see discordbot.Bot)
+//	GET /schema/v1/   - names of the JSON schemas of the check results, incidents, bundles and miner stats
+//	GET /schema/v1/check-result.json - a JSON schema (see package schema)
+func statusHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/badge.svg", func(w http.ResponseWriter, r *http.Request) {
+		message, color := badgeStatus(currentServiceStatus(), blockWatcher.Confirmations)
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Header().Set("Cache-Control", "no-cache, max-age=0")
+		w.Write(renderBadge(badgeLabel, message, color))
+	})
+	mux.HandleFunc("/badge.json", func(w http.ResponseWriter, r *http.Request) {
+		message, color := badgeStatus(currentServiceStatus(), blockWatcher.Confirmations)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache, max-age=0")
+		json.NewEncoder(w).Encode(map[string]interface{}{"schemaVersion": 1, "label": badgeLabel, "message": message, "color": color})
+	})
+	mux.HandleFunc("/status.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(currentServiceStatus())
+	})
+	if census != nil {
+		mux.HandleFunc("/census.json", censusHandler)
+	}
+	mux.Handle("/stream", blockWatcher.StreamHandler())
+	mux.HandleFunc("/dashboard", dashboardPageHandler)
+	mux.HandleFunc("/dashboard.json", dashboardHandler)
+	if notificationLog != nil {
+		mux.HandleFunc("/notifications.json", notificationsHandler)
+	}
+	mux.Handle("/healthz", blockWatcher.HealthHandler())
+	mux.Handle("/readyz", blockWatcher.HealthHandler())
+	if explorerServer != nil {
+		mux.Handle("/v1/", explorerServer.Handler())
+	}
+	if replicaHandler != nil {
+		mux.Handle("/replica", replicaHandler)
+	}
+	if discordBot != nil {
+		mux.Handle("/discord/interactions", discordBot)
+	}
+	mux.Handle("/schema/", http.StripPrefix("/schema", schema.Handler()))
+	return mux
+}
+
+// serveStatus serves the badge, status and stream on the address (eg. ":8080") until the returned server is closed
+func serveStatus(addr string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on %s: %w", addr, err)
+	}
+
+	server := &http.Server{Handler: statusHandler(), ReadHeaderTimeout: 10 * time.Second} // no write timeout, streams are long-lived
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Error("http server error", "err", err)
+		}
+	}()
+	return server, nil
+}
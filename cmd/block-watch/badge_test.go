@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/metachris/flashbots/watcher"
+)
+
+func TestSchemaEndpoint(t *testing.T) {
+	defer func(w *watcher.Watcher) { blockWatcher = w }(blockWatcher)
+	blockWatcher = watcher.New(nil)
+	handler := statusHandler()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/schema/v1/check-result.json", nil))
+	var schema map[string]interface{}
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/schema+json" {
+		t.Fatal("expected the schema", w.Code, w.Header())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &schema); err != nil || schema["$schema"] == nil {
+		t.Error("expected a JSON schema", err, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/schema/v1/unknown.json", nil))
+	if w.Code != http.StatusNotFound {
+		t.Error("expected not found for an unknown schema", w.Code)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/metachris/flashbots/analytics"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/uncles"
+)
+
+var bidHistory *analytics.BidHistory // only with -bidhistory
+var bidHistoryFile string
+var bidHistorySaved time.Time
+
+// addToBidHistory adds the bundles of a checked block as won or failed bids, and saves the history if due
+func addToBidHistory(check *blockcheck.BlockCheck) {
+	if bidHistory == nil {
+		return
+	}
+
+	bidHistory.AddCheck(check)
+	if time.Since(bidHistorySaved) >= rollupsSaveInterval {
+		saveBidHistory()
+	}
+}
+
+// addUncleBundleToBidHistory adds a bundle of an uncle block as lost bid (see uncles.Detector.OnBundle)
+func addUncleBundleToBidHistory(bundle *uncles.UncleBundle) {
+	bidHistory.AddUncleBundle(bundle)
+}
+
+func saveBidHistory() {
+	if bidHistory == nil {
+		return
+	}
+
+	if err := bidHistory.Save(bidHistoryFile); err != nil {
+		log.Error("error saving bid history", "file", bidHistoryFile, "err", err)
+	}
+	bidHistorySaved = time.Now()
+}
+
+// printBidHistory prints the hourly bid series of a searcher over the last n hours as JSON (eg. for charts).
+// Usage: block-watch -bidhistory bids.json bids <searcher> <hours>
+func printBidHistory(searcher string, n string) error {
+	hours, err := strconv.Atoi(n)
+	if err != nil || hours <= 0 {
+		return fmt.Errorf("invalid number of hours '%s'", n)
+	}
+
+	to := time.Now().Add(time.Hour) // include the current hour
+	from := to.Add(-time.Duration(hours) * time.Hour)
+	data, err := json.MarshalIndent(bidHistory.Series(searcher, from, to, time.Hour), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/metachris/flashbots/analytics"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+var census *analytics.Census // only with -census
+var censusFile string
+var censusSaved time.Time
+
+// addToCensus adds the contracts touched by the bundles of a checked block to the census, and saves it if due
+func addToCensus(check *blockcheck.BlockCheck) {
+	if census == nil {
+		return
+	}
+
+	census.AddCheck(check)
+	if time.Since(censusSaved) >= rollupsSaveInterval {
+		saveCensus()
+	}
+}
+
+func saveCensus() {
+	if census == nil {
+		return
+	}
+
+	if err := census.Save(censusFile); err != nil {
+		log.Error("error saving census", "file", censusFile, "err", err)
+	}
+	censusSaved = time.Now()
+}
+
+// queryCensus returns the census of the last n days (including today)
+func queryCensus(n string) (*analytics.CensusDay, error) {
+	count, err := strconv.Atoi(n)
+	if err != nil || count <= 0 {
+		return nil, fmt.Errorf("invalid number of days '%s'", n)
+	}
+	to := time.Now().Add(24 * time.Hour) // include today
+	return census.Query(to.Add(-time.Duration(count)*24*time.Hour), to), nil
+}
+
+// printCensus prints the top protocols and contracts touched by bundles in the last n days.
+// Usage: block-watch -census census.json census <n> [bundles|reward]
+func printCensus(n string, by string) error {
+	if by != analytics.CensusByBundles && by != analytics.CensusByReward {
+		return fmt.Errorf("invalid order '%s' (bundles, reward)", by)
+	}
+	day, err := queryCensus(n)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Last %s days: bundles: %d, contracts: %d, protocols: %d\n", n, day.Bundles, len(day.Contracts), len(day.Protocols))
+	fmt.Printf("\nTop protocols by %s:\n", by)
+	for _, p := range day.TopProtocols(20, by) {
+		fmt.Printf("- %-30s bundles=%-6d tx=%-6d minerReward=%10s ETH\n", p.Name, p.Bundles, p.Tx, utils.WeiBigIntToEthString(p.MinerReward, 4))
+	}
+	fmt.Printf("\nTop contracts by %s:\n", by)
+	for _, c := range day.TopContracts(20, by) {
+		name := c.Name
+		if c.Contract != "" {
+			name += fmt.Sprintf(" (%s %s)", c.Protocol, c.Contract)
+		}
+		fmt.Printf("- %-66s bundles=%-6d tx=%-6d minerReward=%10s ETH\n", name, c.Bundles, c.Tx, utils.WeiBigIntToEthString(c.MinerReward, 4))
+	}
+	return nil
+}
+
+// censusHandler serves the census as JSON: /census.json?days=<n>&top=<n>&by=<bundles|reward> (defaults: 1 day, top 20
+// by bundles)
+func censusHandler(w http.ResponseWriter, r *http.Request) {
+	days := r.URL.Query().Get("days")
+	if days == "" {
+		days = "1"
+	}
+	by := r.URL.Query().Get("by")
+	if by == "" {
+		by = analytics.CensusByBundles
+	}
+	top := 20
+	if s := r.URL.Query().Get("top"); s != "" {
+		if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+			top = n
+		}
+	}
+	if by != analytics.CensusByBundles && by != analytics.CensusByReward {
+		http.Error(w, fmt.Sprintf("invalid order '%s' (bundles, reward)", by), http.StatusBadRequest)
+		return
+	}
+	day, err := queryCensus(days)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, max-age=0")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"start":         day.Start,
+		"bundles":       day.Bundles,
+		"contracts":     len(day.Contracts),
+		"protocols":     len(day.Protocols),
+		"top_contracts": day.TopContracts(top, by),
+		"top_protocols": day.TopProtocols(top, by),
+	})
+}
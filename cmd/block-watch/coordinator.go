@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/metachris/flashbots/audit"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/coordinator"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+// The results of the analysis workers are polled at this interval, and pruned after coordinatorRetention
+var (
+	coordinatorPollInterval = 30 * time.Second
+	coordinatorRetention    = 7 * 24 * time.Hour
+)
+
+// runAnalysisWorker runs the complete checks of the jobs of a coordinator until the context is cancelled
+// (block-watch -coordinator <dir> analysis-worker <stream url>)
+func runAnalysisWorker(ctx context.Context, client *ethclient.Client, store *coordinator.Store, streamUrl string) {
+	hostname, _ := os.Hostname()
+	id := fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	worker := coordinator.NewWorker(id, store, streamUrl, func(ctx context.Context, job coordinator.Job) (*blockcheck.BlockCheck, error) {
+		block, err := blockswithtx.GetBlockWithTxReceipts(client, job.BlockNumber)
+		if err != nil {
+			return nil, err
+		}
+		return blockcheck.CheckBlock(block, false)
+	})
+
+	log.Info("analysis worker started", "worker", id, "dir", store.Dir, "stream", streamUrl)
+	worker.Run(ctx)
+}
+
+// watchAnalysisResults polls the results of the analysis workers until the context is cancelled, and alerts the
+// errors which only the complete checks found
+func watchAnalysisResults(ctx context.Context, store *coordinator.Store) {
+	seen := make(map[string]bool)
+	since := time.Now()
+	lastPrune := time.Time{}
+	ticker := time.NewTicker(coordinatorPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			// Overlap the polls, the file times of a shared filesystem can lag behind
+			results, err := store.Results(since.Add(-coordinatorPollInterval))
+			if err != nil {
+				log.Error("error reading the analysis results", "err", err)
+				continue
+			}
+			since = now
+			current := make(map[string]bool)
+			for _, result := range results {
+				name := fmt.Sprintf("%d-%s", result.BlockNumber, strings.ToLower(result.BlockHash))
+				current[name] = true
+				if !seen[name] {
+					notifyAnalysisResult(result)
+				}
+			}
+			seen = current
+
+			if now.Sub(lastPrune) > time.Hour {
+				lastPrune = now
+				if removed, err := store.Prune(coordinatorRetention, now); err != nil {
+					log.Error("error pruning the coordinator dir", "err", err)
+				} else if removed > 0 {
+					log.Info("coordinator dir pruned", "files", removed)
+				}
+			}
+		}
+	}
+}
+
+// notifyAnalysisResult alerts the errors of a complete check which the cheap checks didn't find
+func notifyAnalysisResult(result coordinator.Result) {
+	log.Debug("analysis result", "block", result.BlockNumber, "worker", result.Worker, "new_errors", len(result.NewErrors))
+	if len(result.NewErrors) == 0 {
+		return
+	}
+
+	severity := blockcheck.SeverityLessSerious
+	if result.Result.HasSeriousErrors {
+		severity = blockcheck.SeveritySerious
+	}
+	msg := fmt.Sprintf("Block %d: the complete check (worker %s) found additional errors:\n", result.BlockNumber, result.Worker)
+	for _, errorMsg := range result.NewErrors {
+		msg += "- " + errorMsg + "\n"
+	}
+
+	if config.HasNotifier(severity, "terminal") {
+		printToTerminal(msg)
+	}
+	if sendErrorsToDiscord && config.HasNotifier(severity, "discord") {
+		notifications.Add(result.BlockNumber, "", msg)
+		auditLog.Add(audit.Event{Block: result.BlockNumber, Type: audit.EventAlertQueued, Notifier: "discord", Severity: severity, Message: "complete check"})
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/metachris/flashbots/audit"
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+var correlator *blockcheck.Correlator // only with -correlate
+
+// Error messages shown in the alert of a multi-block incident
+var incidentAlertErrors = 10
+
+// correlateCheck adds the errors of the check to the multi-block incidents, alerts the incidents which reached
+// -correlateblocks blocks, and closes the incidents without new errors
+func correlateCheck(check *blockcheck.BlockCheck) {
+	if correlator == nil {
+		return
+	}
+
+	for _, incident := range correlator.Add(check) {
+		notifyIncident(incident, fmt.Sprintf("Multi-block %s\n", incident.Sprint(incidentAlertErrors)))
+	}
+
+	for _, incident := range correlator.Expire(check.Number) {
+		log.Info("incident closed", "incident", incident.ID(), "blocks", len(incident.Blocks), "first", incident.FirstBlock, "last", incident.LastBlock)
+		fileInfo := ""
+		if incidentDir != "" {
+			filename, err := incident.WriteFile(incidentDir)
+			if err != nil {
+				log.Error("error writing incident file", "incident", incident.ID(), "err", err)
+			} else {
+				fileInfo = fmt.Sprintf("evidence: %s\n", filename)
+			}
+		}
+		if incident.Alerted {
+			notifyIncident(incident, fmt.Sprintf("Closed: %s, no new errors for %d blocks\n%s", incident.String(), correlator.Window, fileInfo))
+		}
+	}
+}
+
+// notifyIncident sends a message about a multi-block incident to the notifiers configured for its severity
+func notifyIncident(incident *blockcheck.MultiBlockIncident, msg string) {
+	if config.HasNotifier(incident.Severity, "terminal") {
+		printToTerminal(msg)
+		auditLog.Add(audit.Event{Block: incident.LastBlock, Type: audit.EventAlertSent, Notifier: "terminal", Severity: incident.Severity, Message: incident.ID()})
+	}
+	if sendErrorsToDiscord && config.HasNotifier(incident.Severity, "discord") {
+		notifications.AddIncident(incident.LastBlock, incident.ID(), msg)
+		auditLog.Add(audit.Event{Block: incident.LastBlock, Type: audit.EventAlertQueued, Notifier: "discord", Severity: incident.Severity, Message: incident.ID()})
+	}
+}
+
+// coveredByIncident returns true if all errors of the check belong to multi-block incidents which were already
+// alerted, so the alert of the block is skipped (the errors are part of the incident evidence)
+func coveredByIncident(check *blockcheck.BlockCheck, severity string) bool {
+	if correlator == nil {
+		return false
+	}
+
+	incident := correlator.Covers(check)
+	if incident == nil {
+		return false
+	}
+	log.Info("alert skipped (part of an incident)", "block", check.Number, "incident", incident.ID())
+	auditLog.Add(audit.Event{Block: check.Number, Type: audit.EventAlertDropped, Severity: severity, Message: "part of incident " + incident.ID()})
+	return true
+}
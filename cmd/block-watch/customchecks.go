@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/common"
+)
+
+// Name of the custom check for bundles calling blacklisted contracts (see -blacklist)
+const CheckBlacklistedContract = "blacklisted-contract"
+
+// registerCustomChecks registers the custom checks of block-watch. They only run if enabled with custom_checks in the
+// config.
+func registerCustomChecks(blacklistFile string) error {
+	if blacklistFile == "" {
+		return nil
+	}
+
+	blacklist := blockcheck.NewWatchlist()
+	if err := blacklist.LoadFile(blacklistFile); err != nil {
+		return err
+	}
+	return blockcheck.RegisterCheck(blockcheck.CheckFunc{
+		CheckName:     CheckBlacklistedContract,
+		CheckSeverity: blockcheck.SeveritySerious,
+		RunFunc: func(check *blockcheck.BlockCheck, fbBlock *api.FlashbotsBlock) (errors []*blockcheck.CheckError) {
+			if fbBlock == nil {
+				return nil
+			}
+			for _, tx := range fbBlock.Transactions {
+				name, found := blacklist.Get(tx.ToAddress)
+				if !found {
+					continue
+				}
+				contract := tx.ToAddress
+				if name != "" {
+					contract = fmt.Sprintf("%s (%s)", name, tx.ToAddress)
+				}
+				msg := fmt.Sprintf("bundle %d calls blacklisted contract %s: tx [%s](<%s>)\n", tx.BundleIndex, contract, tx.Hash, common.TxUrl(tx.Hash))
+				errors = append(errors, &blockcheck.CheckError{BundleIndex: tx.BundleIndex, TxHash: tx.Hash, Message: msg})
+			}
+			return errors
+		},
+	})
+}
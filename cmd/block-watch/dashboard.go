@@ -0,0 +1,69 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/metachris/flashbots/analytics"
+)
+
+// The dashboard page, with the charts drawn in the browser from /dashboard.json, /healthz, /status.json and /stream
+//
+//go:embed dashboard.html
+var dashboardHtml []byte
+
+// Hours of the dashboard charts by default, and at most
+const (
+	dashboardHours    = 24
+	dashboardMaxHours = 24 * 7
+)
+
+// dashboardHour is one point of the charts
+type dashboardHour struct {
+	Start       time.Time `json:"start"`
+	Blocks      int64     `json:"blocks"`
+	ErrorBlocks int64     `json:"error_blocks"`
+	Bundles     int64     `json:"bundles"`
+}
+
+func dashboardPageHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHtml)
+}
+
+// dashboardHandler serves the chart data from the rollups: /dashboard.json?hours=<n> (default 24). Empty without
+// -rollups.
+func dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	hours := dashboardHours
+	if s := r.URL.Query().Get("hours"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > dashboardMaxHours {
+			http.Error(w, "invalid hours '"+s+"' (1 to "+strconv.Itoa(dashboardMaxHours)+")", http.StatusBadRequest)
+			return
+		}
+		hours = n
+	}
+
+	to := time.Now().Add(time.Hour) // include the current hour
+	from := to.Add(-time.Duration(hours) * time.Hour)
+	series := []dashboardHour{}
+	miners := []analytics.RollupMiner{}
+	if rollups != nil {
+		for _, rollup := range rollups.Series(analytics.RollupHour, from, to) {
+			series = append(series, dashboardHour{Start: rollup.Start, Blocks: rollup.Blocks, ErrorBlocks: rollup.ErrorBlocks, Bundles: rollup.Bundles})
+		}
+		miners = rollups.Query(analytics.RollupHour, from, to).TopMiners(10)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, max-age=0")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"hours":   hours,
+		"rollups": rollups != nil,
+		"series":  series,
+		"miners":  miners,
+	})
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/go-ethutils/blockswithtx"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+const debugHelp = `Commands:
+  n, next          show the next transaction (also: empty line)
+  tx <index>       show a transaction
+  s, step          run the next check step and show its findings
+  run              run all remaining check steps
+  steps            list the check steps
+  bundles          list the bundles with their tx index range
+  bundle <index>   show the transactions of a bundle
+  mega <index>     show the transactions of a megabundle
+  lowest           show the lowest gas price of the non-Flashbots transactions
+  state            show the errors and error counts so far
+  q, quit          exit
+`
+
+// debugger walks through a block tx-by-tx and check-by-check (block-watch debug block <n>)
+type debugger struct {
+	check    *blockcheck.BlockCheck
+	steps    []blockcheck.Step
+	nextStep int
+	nextTx   int
+	out      io.Writer
+
+	fbTxs map[string]*fbTxInfo // by tx hash
+}
+
+type fbTxInfo struct {
+	bundle  *common.Bundle
+	isFirst bool
+	isLast  bool
+}
+
+func newDebugger(check *blockcheck.BlockCheck, out io.Writer) *debugger {
+	d := &debugger{
+		check: check,
+		steps: check.Steps(),
+		out:   out,
+		fbTxs: make(map[string]*fbTxInfo),
+	}
+
+	for _, bundle := range check.Bundles {
+		min, max := bundle.TxIndexRange()
+		for _, tx := range bundle.Transactions {
+			d.fbTxs[tx.Hash] = &fbTxInfo{bundle: bundle, isFirst: tx.TxIndex == min, isLast: tx.TxIndex == max}
+		}
+	}
+	return d
+}
+
+// runDebugger prepares the check of a block (without running the checks), and reads debugger commands from in
+func runDebugger(client *ethclient.Client, blockNumber int64, in io.Reader, out io.Writer) error {
+	block, err := blockswithtx.GetBlockWithTxReceipts(client, blockNumber)
+	if err != nil {
+		return err
+	}
+
+	check, err := blockcheck.NewBlockCheck(block, false)
+	if err != nil {
+		return err
+	}
+
+	d := newDebugger(check, out)
+	fmt.Fprintln(out, check.SprintHeader(false, false))
+	fmt.Fprintf(out, "base fee: %s, %d check steps. Type 'help' for the commands.\n", baseFeeString(check), len(d.steps))
+
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "(debug) ")
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && (fields[0] == "q" || fields[0] == "quit") {
+			return nil
+		}
+
+		err = d.exec(fields)
+		if err != nil {
+			fmt.Fprintln(out, "error:", err)
+		}
+	}
+}
+
+func (d *debugger) exec(fields []string) error {
+	cmd := "next"
+	if len(fields) > 0 {
+		cmd = fields[0]
+	}
+
+	arg := int64(-1)
+	if len(fields) > 1 {
+		var err error
+		arg, err = strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid index: %s", fields[1])
+		}
+	}
+
+	switch cmd {
+	case "help", "h":
+		fmt.Fprint(d.out, debugHelp)
+	case "n", "next":
+		if d.nextTx >= len(d.check.EthBlock.Transactions()) {
+			fmt.Fprintln(d.out, "no more transactions")
+			return nil
+		}
+		d.printTx(d.nextTx)
+		d.nextTx += 1
+	case "tx":
+		if arg < 0 || arg >= int64(len(d.check.EthBlock.Transactions())) {
+			return fmt.Errorf("tx index out of range")
+		}
+		d.printTx(int(arg))
+		d.nextTx = int(arg) + 1
+	case "s", "step":
+		return d.step()
+	case "run":
+		for d.nextStep < len(d.steps) {
+			err := d.step()
+			if err != nil {
+				return err
+			}
+		}
+	case "steps":
+		for i, step := range d.steps {
+			status := "pending"
+			if i < d.nextStep {
+				status = "done"
+			}
+			if !step.Enabled {
+				status += ", disabled"
+			}
+			fmt.Fprintf(d.out, "%2d %-26s %s\n", i, step.Name, status)
+		}
+	case "bundles":
+		for _, bundle := range d.check.Bundles {
+			d.printBundle(bundle)
+		}
+	case "bundle", "mega":
+		bundle := d.check.FindBundle(arg, cmd == "mega")
+		if bundle == nil {
+			return fmt.Errorf("%s %d not found", cmd, arg)
+		}
+		d.printBundle(bundle)
+		for _, tx := range bundle.Transactions {
+			d.printTx(int(tx.TxIndex))
+		}
+	case "lowest":
+		d.printReferenceGasPrice()
+	case "state":
+		d.printState()
+	default:
+		return fmt.Errorf("unknown command: %s (see 'help')", cmd)
+	}
+	return nil
+}
+
+// step runs the next check step, and prints the errors it added and the values it is based on
+func (d *debugger) step() error {
+	if d.nextStep >= len(d.steps) {
+		fmt.Fprintln(d.out, "all steps done")
+		return nil
+	}
+
+	step := d.steps[d.nextStep]
+	d.nextStep += 1
+	if !step.Enabled {
+		fmt.Fprintf(d.out, "step %s: skipped (disabled or requirements missing)\n", step.Name)
+		return nil
+	}
+
+	numErrors := len(d.check.CheckErrors)
+	err := step.Run()
+	if err != nil {
+		return fmt.Errorf("step %s: %w", step.Name, err)
+	}
+
+	fmt.Fprintf(d.out, "step %s: %d new errors\n", step.Name, len(d.check.CheckErrors)-numErrors)
+	for _, err := range d.check.CheckErrors[numErrors:] {
+		fmt.Fprintf(d.out, "- error (%s, %s): %s", err.Kind, severityName(err.Severity), err)
+	}
+
+	switch step.Name {
+	case blockcheck.CheckBundleOrder:
+		for _, bundle := range d.check.Bundles {
+			d.printBundle(bundle)
+		}
+	case blockcheck.CheckBundleFee:
+		d.printReferenceGasPrice()
+	case blockcheck.CheckSandwich:
+		for _, sandwich := range d.check.Sandwiches {
+			fmt.Fprintln(d.out, "- info:", sandwich)
+		}
+	case blockcheck.CheckTemplateSource:
+		if d.check.TemplateSource != "" {
+			fmt.Fprintln(d.out, "template source:", d.check.TemplateString())
+		}
+	case blockcheck.CheckPrivateOrderFlow:
+		for _, bundle := range d.check.PrivateOrderFlowBundles {
+			fmt.Fprintln(d.out, "- info:", bundle)
+		}
+	case blockcheck.CheckRelayPayment:
+		for _, bid := range d.check.RelayBids {
+			fmt.Fprintf(d.out, "- relay %s: bid %s, proposer payment %s\n", bid.Relay, bid.Bid.Value, bid.ProposerPayment)
+		}
+	case blockcheck.CheckProposerPayment:
+		if d.check.ProposerAudit != nil {
+			fmt.Fprintln(d.out, "proposer:", d.check.ProposerAudit)
+		}
+	case blockcheck.CheckBuilderProfit:
+		if d.check.BlockValue != nil && d.check.ProposerPaymentValue != nil {
+			fmt.Fprintf(d.out, "block value: %s, proposer payment: %s, builder kept: %.2f%%\n", common.FormatEth(d.check.BlockValue), common.FormatEth(d.check.ProposerPaymentValue), d.check.BuilderKeptSharePercent)
+		}
+	}
+	return nil
+}
+
+func (d *debugger) printTx(index int) {
+	tx := d.check.EthBlock.Transactions()[index]
+	from, _ := utils.GetTxSender(tx)
+	to := "contract creation"
+	if tx.To() != nil {
+		to = tx.To().Hex()
+	}
+
+	status := "-"
+	if receipt := d.check.BlockWithTxReceipts.TxReceipts[tx.Hash()]; receipt != nil {
+		status = "ok"
+		if receipt.Status == 0 {
+			status = "failed"
+		}
+	}
+
+	fmt.Fprintf(d.out, "tx %d %s\n  from %s to %s, status %s, priority fee %s\n", index, tx.Hash().Hex(), from.Hex(), to, status, common.FormatGwei(common.TxPriorityFee(tx, d.check.EthBlock.BaseFee())))
+
+	if info, found := d.fbTxs[tx.Hash().Hex()]; found {
+		boundary := ""
+		if info.isFirst {
+			boundary += " <-- bundle start"
+		}
+		if info.isLast {
+			boundary += " <-- bundle end"
+		}
+		fmt.Fprintf(d.out, "  %s bundle %d (%s)%s\n", info.bundle.BundleType, info.bundle.Index, info.bundle.ShortHash(), boundary)
+	} else if common.IsFlashbotsLikeTx(tx, d.check.EthBlock, d.check.CoinbaseTransfers) {
+		fmt.Fprintln(d.out, "  Flashbots-like (not in the Flashbots API)")
+	}
+
+	if blockcheck.ProtocolRegistry != nil {
+		match := blockcheck.ProtocolRegistry.Decode(to, tx.Data())
+		fmt.Fprintf(d.out, "  protocol %s %s %s\n", match.Protocol, match.Contract, match.Method)
+	}
+}
+
+func (d *debugger) printBundle(bundle *common.Bundle) {
+	min, max := bundle.TxIndexRange()
+	fmt.Fprintf(d.out, "bundle %d (%s, %s): tx %d to %d, coinbase/gasused: %s, reward/gasused: %s, price diff to previous: %s%%, out of order: %t, paying less than lowest tx: %t\n", bundle.Index, bundle.ShortHash(), bundle.BundleType, min, max, common.FormatGwei(bundle.CoinbaseDivGasUsed), common.FormatGwei(bundle.RewardDivGasUsed), bundle.PercentPriceDiff.Text('f', 2), bundle.IsOutOfOrder, bundle.IsPayingLessThanLowestTx)
+}
+
+func (d *debugger) printReferenceGasPrice() {
+	gasPrice, txHash := d.check.ReferenceGasPrice()
+	if gasPrice.Sign() < 0 {
+		fmt.Fprintln(d.out, "no non-Flashbots transactions")
+		return
+	}
+
+	tx := d.check.EthBlock.Transaction(ethcommon.HexToHash(txHash))
+	index := -1
+	for i, blockTx := range d.check.EthBlock.Transactions() {
+		if blockTx == tx {
+			index = i
+		}
+	}
+	fmt.Fprintf(d.out, "reference non-Flashbots gas price (priority fee after London, p%g without dust and self-transfers): %s in tx %d %s\n", blockcheck.ThresholdBundleFeeReferencePercentile, common.FormatGwei(gasPrice), index, txHash)
+}
+
+func (d *debugger) printState() {
+	fmt.Fprintf(d.out, "steps done: %d/%d, errors: %d, serious: %t, less serious: %t\n", d.nextStep, len(d.steps), len(d.check.CheckErrors), d.check.HasSeriousErrors(), d.check.HasLessSeriousErrors())
+	for _, err := range d.check.CheckErrors {
+		fmt.Fprintf(d.out, "- error (%s, %s): %s", err.Kind, severityName(err.Severity), err)
+	}
+	counts := d.check.ErrorCounter.Map()
+	for _, name := range d.check.ErrorCounter.Types() {
+		fmt.Fprintf(d.out, "- %s: %d\n", name, counts[name])
+	}
+}
+
+func baseFeeString(check *blockcheck.BlockCheck) string {
+	baseFee := check.EthBlock.BaseFee()
+	if baseFee == nil {
+		return "- (pre-London)"
+	}
+	return common.FormatGwei(baseFee)
+}
+
+// severityName is the severity of an error, "below thresholds" if it doesn't trigger alerts
+func severityName(severity string) string {
+	if severity == "" {
+		return "below thresholds"
+	}
+	return severity
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/metachris/flashbots/audit"
+	"github.com/metachris/flashbots/watcher"
+)
+
+var delivery *AlertDelivery = NewAlertDelivery()
+
+// AlertSender is one notifier of the delivery chain
+type AlertSender struct {
+	Name string
+	Send func(msg string) error
+}
+
+// DeliveryStats counts the results of one sender
+type DeliveryStats struct {
+	Delivered int `json:"delivered"`
+	Failed    int `json:"failed"` // failed attempts, including retries
+}
+
+// AlertDelivery sends each alert to the first sender, retrying on errors. If it still fails (eg. webhook down), the
+// next sender is tried. Alerts which no sender could deliver are saved in Storage, and can be resent later with the
+// resend subcommand.
+type AlertDelivery struct {
+	Senders    []AlertSender
+	Retries    int           // retries per sender, after the first attempt
+	RetryDelay time.Duration // doubled after every retry
+	Storage    watcher.AlertStorage
+
+	lock        sync.Mutex
+	stats       map[string]*DeliveryStats // by sender name
+	undelivered int
+}
+
+func NewAlertDelivery() *AlertDelivery {
+	return &AlertDelivery{
+		Retries:    2,
+		RetryDelay: 2 * time.Second,
+		stats:      make(map[string]*DeliveryStats),
+	}
+}
+
+// Send delivers the alert of the blocks and incidents, or saves it as undelivered and returns the error of the last
+// sender
+func (d *AlertDelivery) Send(blocks []int64, incidents []string, msg string) error {
+	err := d.deliver(blocks, incidents, msg)
+	if err == nil {
+		return nil
+	}
+
+	d.lock.Lock()
+	d.undelivered += 1
+	d.lock.Unlock()
+
+	for _, block := range blocks {
+		auditLog.Add(audit.Event{Block: block, Type: audit.EventAlertUndelivered, Error: err.Error()})
+	}
+	if d.Storage != nil {
+		if saveErr := d.Storage.SaveUndeliveredAlert(watcher.UndeliveredAlert{Time: time.Now().UTC(), Message: msg, Error: err.Error(), Blocks: blocks, Incidents: incidents}); saveErr != nil {
+			log.Error("error saving undelivered alert", "err", saveErr)
+		}
+	}
+	return err
+}
+
+// deliver tries all senders in order, returns nil as soon as one succeeds. Every attempt is added to the audit log,
+// the result of each sender to the notification log.
+func (d *AlertDelivery) deliver(blocks []int64, incidents []string, msg string) (err error) {
+	if len(d.Senders) == 0 {
+		return fmt.Errorf("no notifier configured")
+	}
+
+	for _, sender := range d.Senders {
+		delay := d.RetryDelay
+		attempt := 0
+		for ; attempt <= d.Retries; attempt++ {
+			if attempt > 0 {
+				time.Sleep(delay)
+				delay *= 2
+			}
+
+			err = sender.Send(msg)
+			d.count(sender.Name, err == nil)
+			for _, block := range blocks {
+				event := audit.Event{Block: block, Type: audit.EventAlertSent, Notifier: sender.Name}
+				if err != nil {
+					event.Type, event.Error = audit.EventAlertFailed, err.Error()
+				}
+				auditLog.Add(event)
+			}
+			if err == nil {
+				break
+			}
+			log.Warn("alert delivery failed", "notifier", sender.Name, "attempt", attempt+1, "err", err)
+		}
+
+		notification := audit.Notification{Channel: sender.Name, Status: audit.NotificationDelivered, Attempts: attempt + 1, Blocks: blocks, Incidents: incidents, Payload: msg}
+		if err != nil {
+			notification.Status, notification.Attempts, notification.Error = audit.NotificationFailed, attempt, err.Error()
+		}
+		if logErr := notificationLog.Add(notification); logErr != nil {
+			log.Error("error writing the notification log", "err", logErr)
+		}
+		if err == nil {
+			return nil
+		}
+		err = fmt.Errorf("%s: %w", sender.Name, err)
+	}
+	return err
+}
+
+func (d *AlertDelivery) count(sender string, delivered bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	stats, found := d.stats[sender]
+	if !found {
+		stats = &DeliveryStats{}
+		d.stats[sender] = stats
+	}
+	if delivered {
+		stats.Delivered += 1
+	} else {
+		stats.Failed += 1
+	}
+}
+
+// Stats returns a copy of the delivery stats by sender, and the number of undelivered alerts
+func (d *AlertDelivery) Stats() (stats map[string]DeliveryStats, undelivered int) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	stats = make(map[string]DeliveryStats, len(d.stats))
+	for name, s := range d.stats {
+		stats[name] = *s
+	}
+	return stats, d.undelivered
+}
+
+// Resend tries to deliver the saved undelivered alerts again (oldest first). The alerts which still fail are kept.
+func (d *AlertDelivery) Resend() (sent int, failed int, err error) {
+	if d.Storage == nil {
+		return 0, 0, fmt.Errorf("no storage for undelivered alerts")
+	}
+
+	alerts, err := d.Storage.LoadUndeliveredAlerts()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var remaining []watcher.UndeliveredAlert
+	for _, alert := range alerts {
+		if err := d.deliver(alert.Blocks, alert.Incidents, alert.Message); err != nil {
+			alert.Error = err.Error()
+			remaining = append(remaining, alert)
+			continue
+		}
+		sent += 1
+	}
+
+	return sent, len(remaining), d.Storage.ReplaceUndeliveredAlerts(remaining)
+}
+
+// discordSenders returns the delivery chain: DISCORD_WEBHOOK, then DISCORD_FALLBACK_WEBHOOK (if set)
+func discordSenders() []AlertSender {
+	senders := []AlertSender{{Name: "discord", Send: SendToDiscord}}
+	if discordFallbackUrl != "" {
+		senders = append(senders, AlertSender{Name: "discord-fallback", Send: SendToDiscordFallback})
+	}
+	return senders
+}
@@ -6,8 +6,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"os"
 	"strings"
@@ -19,15 +19,41 @@ type DiscordWebhookPayload struct {
 
 var discordUrl string = os.Getenv("DISCORD_WEBHOOK")
 
+// Operational alerts (crashes, restarts) are sent to this webhook, or to DISCORD_WEBHOOK if not set
+var discordOpsUrl string = os.Getenv("DISCORD_OPS_WEBHOOK")
+
+// Alerts which can't be delivered to DISCORD_WEBHOOK are sent to this webhook, if set
+var discordFallbackUrl string = os.Getenv("DISCORD_FALLBACK_WEBHOOK")
+
 // SendToDiscord splits one message into multiple if necessary (max size is 2k characters)
 func SendToDiscord(msg string) error {
+	return sendToDiscordWebhook(discordUrl, msg)
+}
+
+// SendToDiscordOps sends a message to the ops channel, and adds it to the notification log
+func SendToDiscordOps(msg string) (err error) {
+	if discordOpsUrl == "" {
+		err = SendToDiscord(msg)
+	} else {
+		err = sendToDiscordWebhook(discordOpsUrl, msg)
+	}
+	logNotification("discord-ops", msg, err)
+	return err
+}
+
+// SendToDiscordFallback sends a message to the fallback webhook
+func SendToDiscordFallback(msg string) error {
+	return sendToDiscordWebhook(discordFallbackUrl, msg)
+}
+
+func sendToDiscordWebhook(url string, msg string) error {
 	if msg == "" {
 		return nil
 	}
 
 	for {
 		if len(msg) < 2000 {
-			return _SendToDiscord(msg)
+			return _SendToDiscord(url, msg)
 		}
 
 		// Extract 2k of message and send those
@@ -40,16 +66,16 @@ func SendToDiscord(msg string) error {
 			msg = "..." + msg[1997:]
 		}
 
-		err := _SendToDiscord(smallMsg)
+		err := _SendToDiscord(url, smallMsg)
 		if err != nil {
 			return err
 		}
 	}
 }
 
-// _SendToDiscord sends to discord without any error checks
-func _SendToDiscord(msg string) error {
-	if len(discordUrl) == 0 {
+// _SendToDiscord sends to discord without splitting the message, status codes >= 300 are errors
+func _SendToDiscord(url string, msg string) error {
+	if len(url) == 0 {
 		return errors.New("no DISCORD_WEBHOOK env variable found")
 	}
 
@@ -59,18 +85,17 @@ func _SendToDiscord(msg string) error {
 		return err
 	}
 
-	res, err := http.Post(discordUrl, "application/json", bytes.NewBuffer(payloadBytes))
+	res, err := http.Post(url, "application/json", bytes.NewBuffer(payloadBytes))
 	if err != nil {
 		return err
 	}
 
 	defer res.Body.Close()
-	log.Println("Discord response status:", res.Status)
+	log.Debug("discord response", "status", res.Status)
 
 	if res.StatusCode >= 300 {
 		bodyBytes, _ := ioutil.ReadAll(res.Body)
-		bodyString := string(bodyBytes)
-		log.Println(bodyString)
+		return fmt.Errorf("discord error response: %s - %s", res.Status, strings.TrimSpace(string(bodyBytes)))
 	}
 	return nil
 }
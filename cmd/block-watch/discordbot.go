@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/metachris/flashbots/discordbot"
+)
+
+var discordBot *discordbot.Bot // only with -discordbotkey, served on /discord/interactions with -http
+
+// registerDiscordCommands registers the slash commands of the bot with DISCORD_APPLICATION_ID and DISCORD_BOT_TOKEN,
+// in one guild if guildID is set
+func registerDiscordCommands(guildID string) error {
+	applicationID, botToken := os.Getenv("DISCORD_APPLICATION_ID"), os.Getenv("DISCORD_BOT_TOKEN")
+	if applicationID == "" || botToken == "" {
+		return errors.New("DISCORD_APPLICATION_ID and DISCORD_BOT_TOKEN env variables are required")
+	}
+	if err := discordbot.RegisterCommands(context.Background(), applicationID, botToken, guildID); err != nil {
+		return err
+	}
+	log.Info("discord commands registered", "commands", len(discordbot.Commands), "guild", guildID)
+	return nil
+}
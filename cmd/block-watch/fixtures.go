@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+// dumpFixtures saves the blocks with their receipts and the Flashbots API data as fixtures for -fixtures, one
+// <block>.json file per block (block-watch dump <dir> <block>...)
+func dumpFixtures(client *ethclient.Client, dir string, blockArgs []string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, blockArg := range blockArgs {
+		blockNumber, err := strconv.ParseInt(blockArg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid block number '%s'", blockArg)
+		}
+
+		block, err := blockswithtx.GetBlockWithTxReceipts(client, blockNumber)
+		if err != nil {
+			return err
+		}
+		// Only the inputs are saved, the expensive steps are left to the replay
+		check, err := blockcheck.CheckBlockFast(block, false)
+		if err != nil {
+			return fmt.Errorf("check error at block %d: %w", blockNumber, err)
+		}
+		input, err := blockcheck.NewCheckInput(check)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("%d.json", blockNumber))
+		if err = input.WriteFile(path); err != nil {
+			return err
+		}
+		fmt.Printf("block %d: %s (%d tx, %d bundles, errors: %v)\n", blockNumber, path, len(block.Block.Transactions()), len(check.Bundles), check.ErrorCounter.Types())
+	}
+	return nil
+}
+
+// replayFixtures feeds the fixtures through the alert pipeline of watch mode (blockWatcher has to be set up), sends
+// the queued Discord alerts and prints the session summary (block-watch [flags] -fixtures <path>)
+func replayFixtures(ctx context.Context, path string) error {
+	inputs, err := blockcheck.LoadCheckInputs(path)
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		return fmt.Errorf("no fixtures in %s", path)
+	}
+	log.Info("replaying fixtures", "blocks", len(inputs), "from", inputs[0].BlockNumber, "to", inputs[len(inputs)-1].BlockNumber)
+
+	replayed, err := blockWatcher.Replay(ctx, inputs)
+	notifications.Flush(time.Now())
+	sendSessionSummary(false, fmt.Sprintf("replay of %d of %d fixtures", replayed, len(inputs)))
+	return err
+}
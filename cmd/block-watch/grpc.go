@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/grpcapi"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+var grpcServer *grpcapi.Server // only with -grpc or the Discord bot (-discordbotkey)
+
+// newGrpcServer returns the gRPC service, it checks blocks which weren't checked before with the current node (one at a
+// time with the checks of the watcher)
+func newGrpcServer(nodes *NodePool) *grpcapi.Server {
+	server := grpcapi.NewServer()
+	server.Subscribe = blockWatcher.SubscribeChecksFiltered
+	server.CheckBlock = func(ctx context.Context, number int64) (*blockcheck.BlockCheck, error) {
+		client := nodes.Client()
+		if client == nil {
+			return nil, errNoNode
+		}
+		block, err := blockswithtx.GetBlockWithTxReceipts(client, number)
+		if err != nil {
+			return nil, err
+		}
+		return blockWatcher.CheckBlock(block)
+	}
+	return server
+}
+
+// serveGrpc serves the gRPC service on the address with the certificate files, or a self-signed certificate if
+// certFile is empty, until the returned server is closed
+func serveGrpc(addr string, certFile string, keyFile string) (*http.Server, error) {
+	var cert tls.Certificate
+	var err error
+	if certFile != "" {
+		cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+	} else {
+		cert, err = grpcapi.SelfSignedCertificate("localhost", "127.0.0.1")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("grpc certificate error: %w", err)
+	}
+	if certFile == "" {
+		log.Info("grpc: using a self-signed certificate", "sha256", grpcapi.Fingerprint(cert))
+	}
+
+	return grpcServer.Serve(addr, cert, func(err error) {
+		log.Error("grpc server error", "err", err)
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/metachris/flashbots/audit"
+	"github.com/metachris/flashbots/watcher"
+)
+
+var auditLog *audit.Log // nil if disabled
+
+// printIncident prints the timeline of a block from the audit log, and its check results and incidents from the
+// JSON lines files (if jsonlSink is set)
+func printIncident(blockArg string, jsonlSink *watcher.JSONLSink) error {
+	block, err := strconv.ParseInt(blockArg, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid block number '%s'", blockArg)
+	}
+
+	events, err := auditLog.Events(block)
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		fmt.Printf("No audit events for block %d\n", block)
+	} else {
+		fmt.Printf("Timeline of block %d:\n\n%s", block, audit.Timeline(events))
+	}
+
+	if jsonlSink == nil {
+		return nil
+	}
+	checks, incidents, err := jsonlSink.Find(block)
+	if err != nil {
+		return err
+	}
+	for _, check := range checks {
+		miner := check.Miner
+		if check.MinerName != "" {
+			miner = fmt.Sprintf("%s (%s)", check.MinerName, check.Miner)
+		}
+		fmt.Printf("\nCheck result (block hash %s, miner %s):\n", check.BlockHash, miner)
+		for _, msg := range check.Errors {
+			fmt.Println("- " + strings.TrimSpace(msg))
+		}
+	}
+	for _, incident := range incidents {
+		fmt.Printf("\nIncident: %d transactions, %d addresses\n", len(incident.TxHashes), len(incident.Addresses))
+		for _, hash := range incident.TxHashes {
+			fmt.Println("- " + hash)
+		}
+	}
+	return nil
+}
+
+// ackIncident adds an acknowledgement of the block (eg. "miner contacted") to the audit log
+func ackIncident(blockArg string, note string) error {
+	block, err := strconv.ParseInt(blockArg, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid block number '%s'", blockArg)
+	}
+	return auditLog.Add(audit.Event{Block: block, Type: audit.EventAck, User: os.Getenv("USER"), Message: note})
+}
@@ -10,40 +10,327 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	flashbotsrpc "github.com/metachris/flashbots-rpc"
+	"github.com/metachris/flashbots/analytics"
 	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/audit"
 	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/chaos"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/flashbots/coordinator"
+	"github.com/metachris/flashbots/discordbot"
+	"github.com/metachris/flashbots/explorer"
+	"github.com/metachris/flashbots/export"
+	"github.com/metachris/flashbots/labels"
+	"github.com/metachris/flashbots/logging"
+	"github.com/metachris/flashbots/metrics"
+	"github.com/metachris/flashbots/prices"
+	"github.com/metachris/flashbots/receipts"
+	"github.com/metachris/flashbots/uncles"
+	"github.com/metachris/flashbots/watcher"
 	"github.com/metachris/go-ethutils/blockswithtx"
 	"github.com/metachris/go-ethutils/utils"
 	"github.com/pkg/errors"
 )
 
+var log = logging.Module("block-watch")
+
 var silent bool
 var sendErrorsToDiscord bool
+var incidentDir string // tx lists of serious incidents are written to this directory, if set
+var incidentUrl string // base url under which incidentDir is served, used for links in alerts
 
-// Backlog of new blocks that are not yet present in the mev-blocks API (it has ~5 blocks delay)
-var BlockBacklog map[int64]*blockswithtx.BlockWithTxReceipts = make(map[int64]*blockswithtx.BlockWithTxReceipts)
+var blockWatcher *watcher.Watcher
+var errorCountSerious int
+var errorCountNonSerious int
+var numLowActivityBlocks int // blocks without Flashbots or 0-gas tx (only counted with skip_low_activity_blocks)
+var config *blockcheck.Config = blockcheck.DefaultConfig()
+var alertRateLimiter *AlertRateLimiter = NewAlertRateLimiter(0)
+var summaryFile string // summaries are appended to this file, if set
 
 var dailyErrorSummary blockcheck.ErrorSummary = blockcheck.NewErrorSummary()
 var weeklyErrorSummary blockcheck.ErrorSummary = blockcheck.NewErrorSummary()
+var dailyCapacityStats blockcheck.CapacityStats = blockcheck.NewCapacityStats()
+var weeklyPayouts *analytics.PayoutReconciler // only with -payouts
+var chaosTransport *chaos.Transport           // only with -chaos (testing)
+var reports *blockcheck.Reports               // only with -reports
+var shareTracker *metrics.ShareTracker        // Flashbots gas share and bundles per block, in watch mode
+var rewardTracker *metrics.RewardTracker      // bundle reward per block of each miner, in watch mode
+var reportDir string                          // reports are also written to this directory, if set
+var explorerServer *explorer.Server           // only with -explorer
 
 func main() {
-	log.SetOutput(os.Stdout)
+	var err error
 
-	ethUri := flag.String("eth", os.Getenv("ETH_NODE"), "Ethereum node URI")
+	ethUri := flag.String("eth", os.Getenv("ETH_NODE"), "Ethereum node URI (comma-separated for failover nodes)")
 	// recentBundleOrdersPtr := flag.Bool("recentBundleOrder", false, "check recent bundle orders blocks")
 	blockHeightPtr := flag.Int64("block", 0, "specific block to check")
 	watchPtr := flag.Bool("watch", false, "watch and process new blocks")
 	silentPtr := flag.Bool("silent", false, "don't print info about every block")
 	discordPtr := flag.Bool("discord", false, "send errors to Discord")
+	tracePtr := flag.Bool("trace", false, "trace blocks to verify the coinbase transfers of the API (requires debug_traceBlockByNumber)")
+	lowFeePercentilePtr := flag.Float64("lowfeepercentile", 0, "only alert on bundles paying less than the reference non-Flashbots tx (see bundle_fee_reference_percentile) if they are below this percentile of the gas prices of the block's non-Flashbots tx (0 = always)")
+	sandwichUsdPtr := flag.Bool("sandwichusd", false, "price the victim losses of sandwiches in USD at the block time, with the token prices of CoinGecko (set COINGECKO_API_KEY for a demo API key)")
+	simulatePtr := flag.String("simulate", "", "mev-geth node URI: re-simulate blocks with bundle order errors in the correct order, and include the miner's lost revenue in the alert (requires eth_callBundle)")
+	verifyBundlesPtr := flag.Bool("verifybundles", false, "re-simulate the bundles with errors on top of the parent block (with the -simulate node or relay), and report whether the on-chain outcome matches: searcher or miner error")
+	configPtr := flag.String("config", "", "JSON config file (thresholds, enabled checks, notifiers)")
+	warmStartPtr := flag.Int64("warmstart", 0, "in watch mode, first check this many recent blocks from the Flashbots API (for baseline stats)")
+	summaryFilePtr := flag.String("summaryfile", "", "append daily and weekly summaries to this file")
+	checkpointPtr := flag.String("checkpoint", "", "checkpoint file: save the last processed block, and continue from there on restart")
+	incidentDirPtr := flag.String("incidentdir", "", "write tx lists of serious incidents to this directory")
+	incidentUrlPtr := flag.String("incidenturl", "", "base url of the incident directory (for links in alerts)")
+	confirmationsPtr := flag.Int64("confirmations", 0, "number of confirmations before a block is checked and reported")
+	networkPtr := flag.String("network", "", "network: mainnet, goerli, sepolia or holesky (sets the API, relays and block explorer; default: by the chain ID of the node, with the mainnet API and relays)")
+	apiUrlPtr := flag.String("api", "", "mev-blocks API url (default: the one of the network)")
+	relaysPtr := flag.String("relays", "", "compare the bids of these mev-boost relays with the on-chain proposer payment (comma-separated names or urls, or 'all')")
+	beaconPtr := flag.String("beacon", "", "beacon node url (eg. http://localhost:5052), with -relays: audit that the proposer's registered fee recipient was paid")
+	protocolsPtr := flag.String("protocols", "", "JSON file with additional protocol addresses and selectors, to decode the protocols of bundle tx (see protocols/registry.json)")
+	watchlistPtr := flag.String("watchlist", "", "file with watched addresses (one per line, optionally followed by a comma and a name): alert when they appear as sender, recipient or coinbase transfer beneficiary of a Flashbots tx")
+	watchAddrPtr := flag.String("watchaddr", "", "watched addresses, comma-separated (see -watchlist)")
+	blacklistPtr := flag.String("blacklist", "", "file with blacklisted contracts (same format as -watchlist) for the custom check 'blacklisted-contract', enabled with custom_checks in the config")
+	splittersPtr := flag.String("splitters", "", "JSON file with the payment splitter contracts of pools: blocks with a splitter as coinbase are counted for the pool it pays (see blockcheck.SplitterRegistry)")
+	labelsPtr := flag.String("labels", "", "JSON or CSV file with additional miner, builder and searcher labels (see labels/labels.json)")
+	apiCacheDirPtr := flag.String("apicachedir", "", "also cache the Flashbots API responses on disk in this directory (kept across restarts)")
+	payoutsPtr := flag.Bool("payouts", false, "in watch mode, reconcile the weekly miner rewards with the coinbase balance growth (weekly summary)")
+	reportsPtr := flag.Bool("reports", false, "in watch mode, send daily and weekly reports (calendar days and weeks, UTC)")
+	reportDirPtr := flag.String("reportdir", "", "also write the reports as CSV and JSON files to this directory")
+	tuiPtr := flag.Bool("tui", false, "in watch mode, show a terminal dashboard instead of the scrolling output")
+	httpPtr := flag.String("http", "", "in watch mode, serve a status badge (/badge.svg, /badge.json), the status (/status.json) and a stream of the check results (/stream) on this address (eg. ':8080')")
+	adminSocketPtr := flag.String("adminsocket", "", "in watch mode, serve the status as JSON on this unix socket (see the status subcommand)")
+	bidHistoryPtr := flag.String("bidhistory", "", "keep the won, failed and uncled bundle gas prices of each searcher in this JSON file (see the bids subcommand)")
+	rollupsPtr := flag.String("rollups", "", "maintain hourly and daily rollups (stats by miner, searcher and error type) in this JSON file")
+	censusPtr := flag.String("census", "", "keep the contracts and protocols touched by bundles per day in this JSON file (see the census subcommand, and /census.json with -http)")
+	chaosPtr := flag.String("chaos", "", "TESTING ONLY: inject failures into Flashbots API, relay and HTTP RPC requests at these rates (eg. 'errors=0.1,timeouts=0.05,malformed=0.05')")
+	parquetDirPtr := flag.String("parquet", "", "in watch mode, also write the checks and bundles as Parquet files to this directory, partitioned by date (for pandas, DuckDB)")
+	pushUrlPtr := flag.String("push", "", "in watch mode, push a measurement per block to InfluxDB (write URL, eg. http://localhost:8086/write?db=flashbots) or Graphite (graphite://host:2003)")
+	pushTokenPtr := flag.String("pushtoken", os.Getenv("INFLUX_TOKEN"), "InfluxDB API token (see -push)")
+	pushTagsPtr := flag.String("pushtags", "", "tags of the pushed measurements, eg. instance=eu-1,network=mainnet (see -push)")
+	pushIntervalPtr := flag.Duration("pushinterval", 10*time.Second, "interval of the pushes (see -push)")
+	grpcPtr := flag.String("grpc", "", "in watch mode, serve the gRPC service (CheckBlock, GetMinerStats, StreamChecks, GetFailedTxHistory, see grpcapi/blockwatch.proto) on this address (eg. ':9090'), over TLS")
+	grpcCertPtr := flag.String("grpccert", "", "TLS certificate file of the gRPC service (default: a self-signed certificate, its fingerprint is logged)")
+	grpcKeyPtr := flag.String("grpckey", "", "TLS key file of -grpccert")
+	discordBotKeyPtr := flag.String("discordbotkey", os.Getenv("DISCORD_PUBLIC_KEY"), "in watch mode with -http, answer the Discord slash commands /check, /miner and /failedtx on /discord/interactions, verified with this public key (hex) of the Discord application (see the discord-register subcommand)")
+	explorerPtr := flag.Bool("explorer", false, "in watch mode with -http, serve /v1/blocks and /v1/transactions in the format of the mev-blocks API, annotated with the check results, as backend for explorer frontends")
+	replicaKeyPtr := flag.String("replicakey", "", "in watch mode with -jsonl and -http, serve signed incremental dumps of the check results and incidents on /replica with this key file, for public read replicas (see the replica-keygen and replica-sync subcommands)")
+	replicaPubKeyPtr := flag.String("replicapubkey", "", "public key (hex) of the replica source, for the replica-sync subcommand")
+	jsonlDirPtr := flag.String("jsonl", "", "in watch mode, append all check results and incidents as JSON lines to checks.jsonl and incidents.jsonl in this directory")
+	jsonlMaxSizePtr := flag.Int64("jsonlmaxsize", 100, "rotate the JSON lines files at this size (MB, 0 = never)")
+	jsonlMaxFilesPtr := flag.Int("jsonlmaxfiles", 0, "keep this many rotated JSON lines files each (0 = all)")
+	jsonlColdPtr := flag.String("jsonlcold", "", "with -jsonl, move the rotated JSON lines files older than -jsonlcoldage (gzip-compressed) to this cold storage: a directory (eg. a mounted bucket) or an http(s) URL for PUT and GET requests (with the COLD_STORAGE_AUTHORIZATION header, if set). They are read back transparently.")
+	jsonlColdAgePtr := flag.Duration("jsonlcoldage", 30*24*time.Hour, "age of the rotated JSON lines files which are moved to -jsonlcold")
+	jsonlInputsPtr := flag.Bool("jsonlinputs", false, "with -jsonl, also archive the inputs of every check (block, receipts, API data) in inputs.jsonl, to check the blocks again with cmd/flashbots-replay")
+	auditLogPtr := flag.String("auditlog", "", "append what happened to every block (received, published by the API, checked, alerts sent, acks) to this JSON lines file (see the incident and ack subcommands)")
+	undeliveredPtr := flag.String("undelivered", "", "save alerts which couldn't be delivered to Discord (after retries and the fallback webhook) to this file (see the resend subcommand)")
+	notificationLogPtr := flag.String("notificationlog", "", "append every notification sent (channel, message, blocks, incident IDs, delivery status) to this JSON lines file (rotated as set by -jsonlmaxsize and -jsonlmaxfiles), served as /notifications.json with -http")
+	shedLagPtr := flag.Int64("shedlag", 0, "in watch mode, skip the expensive checks (traces, simulations) while blocks are checked more than this many blocks behind the head, and re-check them completely later (0 = disabled)")
+	priorityMinersPtr := flag.String("priorityminers", "", "in watch mode, check the blocks of these miners (comma-separated coinbase addresses) before the backlog, always with the expensive checks (also while shedding, see -shedlag)")
+	shedAfterPtr := flag.Duration("shedafter", 2*time.Minute, "shed the expensive checks only if the lag lasts this long (see -shedlag)")
+	fetchWorkersPtr := flag.Int("fetchworkers", 4, "in watch mode, number of blocks fetched with their receipts in parallel")
+	alertLookAheadPtr := flag.Duration("alertlookahead", 30*time.Second, "in watch mode, serious alerts wait this long for the check of the next block, to show the previous and next block (0 = send immediately, with the previous block only)")
+	maxHeadLagPtr := flag.Int64("maxheadlag", 30, "in watch mode, send an ops alert and report not ready (/readyz) when more blocks than this are received but not checked (0 = disabled)")
+	apiWaitTimeoutPtr := flag.Duration("apiwaittimeout", 10*time.Minute, "in watch mode, skip blocks which the Flashbots API didn't index this long with a warning, they are checked once the API has them (0 = wait forever)")
+	backlogMemoryPtr := flag.Int("backlogmemory", 500, "in watch mode, max. number of backlog blocks kept in memory, the others are written to -spilldir or fetched from the node again (0 = no limit)")
+	spillDirPtr := flag.String("spilldir", "", "in watch mode, directory for the backlog blocks above -backlogmemory (default: fetch them from the node again)")
+	maxApiDowntimePtr := flag.Duration("maxapidowntime", 10*time.Minute, "in watch mode, send an ops alert when the Flashbots API is unreachable this long (0 = disabled)")
+	correlatePtr := flag.Int64("correlate", 0, "in watch mode, group errors of the same type by the same miner or searcher within this many blocks into multi-block incidents: one alert per incident instead of one per block (0 = disabled)")
+	correlateBlocksPtr := flag.Int("correlateblocks", 3, "alert a multi-block incident when its errors span this many blocks (see -correlate)")
+	zeroShareBlocksPtr := flag.Int("zeroshareblocks", 25, "in watch mode, send an ops alert when no Flashbots bundles landed for this many consecutive blocks, eg. a relay outage (0 = disabled)")
+	rewardDeviationPtr := flag.Float64("rewarddeviation", 3, "in watch mode, send an ops alert when the average bundle reward per block of a miner is this factor below or above the network average over the last 1000 blocks, eg. a misconfigured miner or off-protocol deals (0 = disabled)")
+	exitSummaryPtr := flag.Bool("exitsummary", false, "in watch mode, also send the session summary on exit (duration, blocks, errors by type and miner, API outages) to Discord and the summary file (it's always printed)")
+	fixturesPtr := flag.String("fixtures", "", "dry run: check the blocks of these fixtures (a file or directory of the dump subcommand, or an inputs.jsonl archive of -jsonlinputs) instead of watching the node, through the alerts of watch mode (config, thresholds, notifiers), then print the session summary")
+	coordinatorPtr := flag.String("coordinator", "", "shared directory of an alert-only instance and its analysis workers: in watch mode (with -http), run only the cheap checks and leave the complete checks to the workers (see the analysis-worker subcommand)")
+	unclesPtr := flag.Bool("uncles", false, "in watch mode, fetch uncles and report bundles replayed by another party (uncle-bandit)")
+	mevSharePtr := flag.Bool("mevshare", false, "in watch mode, subscribe to the MEV-Share hint stream of the network, and report how many hinted transactions land in a Flashbots bundle (daily summary, /status.json)")
+	mevShareWindowPtr := flag.Int64("mevsharewindow", watcher.DefaultMevShareWindow, "count a MEV-Share hint as expired if its transaction isn't in the next this many blocks (see -mevshare)")
+	logLevelPtr := flag.String("loglevel", "info", "log level: debug, info, warn or error")
+	logFormatPtr := flag.String("logformat", logging.FormatText, "log format: text or json")
+	logFilePtr := flag.String("logfile", "", "also append the logs to this file")
 	flag.Parse()
 
+	err = logging.Configure(*logLevelPtr, *logFormatPtr, *logFilePtr)
+	utils.Perror(err)
+
 	silent = *silentPtr
+	summaryFile = *summaryFilePtr
+	incidentDir = *incidentDirPtr
+	incidentUrl = strings.TrimSuffix(*incidentUrlPtr, "/")
+	// Custom checks have to be registered before the config enables them
+	utils.Perror(registerCustomChecks(*blacklistPtr))
+	if *configPtr != "" {
+		config, err = blockcheck.LoadConfig(*configPtr)
+		utils.Perror(err)
+		config.Apply()
+		alertRateLimiter.MaxAlertsPerHour = config.MaxAlertsPerMinerErrorPerHour
+	}
+	blockcheck.ThresholdBundleLowFeeMaxPercentile = *lowFeePercentilePtr
+	notifications.DedupWindow = time.Duration(config.AlertDedupWindowSec) * time.Second
+	notifications.MaxPerMinute = config.MaxAlertsPerMinute
+
+	var chainConfig *common.ChainConfig
+	if *networkPtr != "" {
+		chainConfig, err = common.GetChainConfig(*networkPtr)
+		utils.Perror(err)
+		utils.Perror(common.ApplyChainConfig(chainConfig))
+	}
+	if *apiUrlPtr != "" {
+		api.DefaultClient.BaseUrl = strings.TrimSuffix(*apiUrlPtr, "/")
+	}
+	if api.DefaultClient.BaseUrl == "" {
+		log.Fatal(fmt.Sprintf("There is no mev-blocks API for %s, set one with -api", chainConfig.Name))
+	}
+
+	if *apiCacheDirPtr != "" {
+		api.DefaultClient.Cache.Dir = *apiCacheDirPtr
+	}
+
+	// Query the status of a running instance: block-watch -adminsocket <path> status
+	adminSocket = *adminSocketPtr
+	if flag.Arg(0) == "status" {
+		if adminSocket == "" {
+			log.Fatal("Usage: block-watch -adminsocket <path> status")
+		}
+		utils.Perror(printStatus(adminSocket))
+		return
+	}
+
+	if *rollupsPtr != "" {
+		rollupsFile = *rollupsPtr
+		rollups, err = analytics.LoadRollups(rollupsFile)
+		utils.Perror(err)
+	}
+
+	// Query the rollups: block-watch -rollups rollups.json rollups <hour|day> <n>
+	if flag.Arg(0) == "rollups" {
+		if rollups == nil || flag.NArg() != 3 {
+			log.Fatal("Usage: block-watch -rollups <file> rollups <hour|day> <n>")
+		}
+		utils.Perror(printRollups(flag.Arg(1), flag.Arg(2)))
+		return
+	}
+
+	if *censusPtr != "" {
+		censusFile = *censusPtr
+		census, err = analytics.LoadCensus(censusFile)
+		utils.Perror(err)
+	}
+
+	// Query the census: block-watch -census census.json census <days> [bundles|reward]
+	if flag.Arg(0) == "census" {
+		if census == nil || flag.NArg() < 2 || flag.NArg() > 3 {
+			log.Fatal("Usage: block-watch -census <file> census <days> [bundles|reward]")
+		}
+		by := analytics.CensusByBundles
+		if flag.NArg() == 3 {
+			by = flag.Arg(2)
+		}
+		utils.Perror(printCensus(flag.Arg(1), by))
+		return
+	}
+
+	if *bidHistoryPtr != "" {
+		bidHistoryFile = *bidHistoryPtr
+		bidHistory, err = analytics.LoadBidHistory(bidHistoryFile)
+		utils.Perror(err)
+	}
+
+	// Query the bid history of a searcher: block-watch -bidhistory bids.json bids <searcher> <hours>
+	if flag.Arg(0) == "bids" {
+		if bidHistory == nil || flag.NArg() != 3 {
+			log.Fatal("Usage: block-watch -bidhistory <file> bids <searcher> <hours>")
+		}
+		utils.Perror(printBidHistory(flag.Arg(1), flag.Arg(2)))
+		return
+	}
+
+	delivery.Senders = discordSenders()
+	if *undeliveredPtr != "" {
+		delivery.Storage = &watcher.FileStorage{UndeliveredFile: *undeliveredPtr}
+	}
+
+	if *auditLogPtr != "" {
+		auditLog = audit.NewLog(*auditLogPtr)
+	}
+	if *notificationLogPtr != "" {
+		notificationLog = audit.NewNotificationLog(*notificationLogPtr, *jsonlMaxSizePtr*1024*1024, *jsonlMaxFilesPtr)
+	}
+
+	// Timeline of an incident: block-watch -auditlog audit.jsonl [-jsonl data/] incident <block>
+	if flag.Arg(0) == "incident" {
+		if auditLog == nil || flag.NArg() != 2 {
+			log.Fatal("Usage: block-watch -auditlog <file> [-jsonl <dir>] incident <block>")
+		}
+		var sink *watcher.JSONLSink
+		if *jsonlDirPtr != "" {
+			sink, err = openJSONLSink(*jsonlDirPtr, 0, 0, *jsonlColdPtr, *jsonlColdAgePtr)
+			utils.Perror(err)
+		}
+		utils.Perror(printIncident(flag.Arg(1), sink))
+		return
+	}
+
+	// Acknowledge an incident: block-watch -auditlog audit.jsonl ack <block> [note]
+	if flag.Arg(0) == "ack" {
+		if auditLog == nil || flag.NArg() < 2 {
+			log.Fatal("Usage: block-watch -auditlog <file> ack <block> [note]")
+		}
+		utils.Perror(ackIncident(flag.Arg(1), strings.Join(flag.Args()[2:], " ")))
+		return
+	}
+
+	// Resend the undelivered alerts: block-watch -undelivered undelivered.jsonl resend
+	if flag.Arg(0) == "resend" {
+		if delivery.Storage == nil {
+			log.Fatal("Usage: block-watch -undelivered <file> resend")
+		}
+		sent, failed, err := delivery.Resend()
+		utils.Perror(err)
+		log.Info("undelivered alerts resent", "sent", sent, "failed", failed)
+		return
+	}
+
+	// Generate a signing key for the replica dumps: block-watch replica-keygen <keyfile>
+	if flag.Arg(0) == "replica-keygen" {
+		if flag.NArg() != 2 {
+			log.Fatal("Usage: block-watch replica-keygen <keyfile>")
+		}
+		utils.Perror(generateReplicaKey(flag.Arg(1)))
+		return
+	}
+
+	// Mirror the check results and incidents of a public instance: block-watch -jsonl <dir> -replicapubkey <hex> replica-sync <url>
+	if flag.Arg(0) == "replica-sync" {
+		if *jsonlDirPtr == "" || *replicaPubKeyPtr == "" || flag.NArg() != 2 {
+			log.Fatal("Usage: block-watch -jsonl <dir> -replicapubkey <hex> replica-sync <url>")
+		}
+		utils.Perror(syncReplica(context.Background(), flag.Arg(1), *replicaPubKeyPtr, *jsonlDirPtr))
+		return
+	}
+
+	// Register the slash commands of the Discord bot: block-watch discord-register [guild]
+	if flag.Arg(0) == "discord-register" {
+		if flag.NArg() > 2 {
+			log.Fatal("Usage: block-watch discord-register [guild]")
+		}
+		utils.Perror(registerDiscordCommands(flag.Arg(1)))
+		return
+	}
+
+	if *tuiPtr && !*watchPtr {
+		log.Fatal("-tui requires -watch")
+	}
+
+	if *confirmationsPtr < 0 || *confirmationsPtr >= watcher.ReorgTrackerDepth {
+		log.Fatal(fmt.Sprintf("confirmations must be between 0 and %d", watcher.ReorgTrackerDepth-1))
+	}
 
 	if *discordPtr {
 		if len(os.Getenv("DISCORD_WEBHOOK")) == 0 {
@@ -52,15 +339,164 @@ func main() {
 		sendErrorsToDiscord = true
 	}
 
+	if *protocolsPtr != "" {
+		err = blockcheck.ProtocolRegistry.LoadFile(*protocolsPtr)
+		utils.Perror(err)
+	}
+
+	if *labelsPtr != "" {
+		err = labels.Default.LoadFile(*labelsPtr)
+		utils.Perror(err)
+	}
+
+	if *splittersPtr != "" {
+		err = blockcheck.PaymentSplitters.LoadFile(*splittersPtr)
+		utils.Perror(err)
+	}
+
+	if *watchlistPtr != "" {
+		err = blockcheck.WatchedAddresses.LoadFile(*watchlistPtr)
+		utils.Perror(err)
+	}
+	if *watchAddrPtr != "" {
+		err = blockcheck.WatchedAddresses.AddList(*watchAddrPtr)
+		utils.Perror(err)
+	}
+
+	// Stop gracefully on SIGINT and SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Dry run of the alerts with stored blocks instead of the node: block-watch [flags] -fixtures <path>
+	if *fixturesPtr != "" {
+		shareTracker = metrics.NewShareTracker(*zeroShareBlocksPtr)
+		rewardTracker = metrics.NewRewardTracker(*rewardDeviationPtr)
+		if *correlatePtr > 0 {
+			correlator = blockcheck.NewCorrelator(*correlatePtr, *correlateBlocksPtr)
+		}
+		if *reportsPtr {
+			reports = blockcheck.NewReports()
+			reportDir = *reportDirPtr
+		}
+
+		blockWatcher = watcher.New(nil)
+		blockWatcher.Audit = auditLog
+		blockWatcher.AlertLookAhead = *alertLookAheadPtr
+		blockWatcher.Notifiers = append(blockWatcher.Notifiers, watcher.NotifierFunc(notify))
+		blockWatcher.OnBlockChecked = processCheck
+		blockWatcher.ErrorHandler = handleWatcherError
+		blockWatcher.Session = watcher.NewSession()
+		utils.Perror(replayFixtures(ctx, *fixturesPtr))
+		return
+	}
+
 	// Connect to the geth node and start the BlockCheckService
 	if *ethUri == "" {
 		log.Fatal("Pass a valid eth node with -eth argument or ETH_NODE env var.")
 	}
 
-	fmt.Printf("Connecting to %s ...", *ethUri)
-	client, err := ethclient.Dial(*ethUri)
+	nodes := NewNodePool(*ethUri)
+	if *chaosPtr != "" {
+		rates, err := chaos.ParseRates(*chaosPtr)
+		utils.Perror(err)
+		chaosTransport = chaos.NewTransport(rates, time.Now().UnixNano())
+		api.DefaultClient.HttpClient = chaosTransport.Client(30 * time.Second)
+		nodes.HttpClient = chaosTransport.Client(0)
+		log.Warn("CHAOS MODE - injecting failures into API and HTTP RPC requests", "rates", rates)
+	}
+
+	log.Info("connecting", "node", nodes.CurrentUri())
+	client, err := nodes.Connect(ctx)
 	utils.Perror(err)
-	fmt.Printf(" ok\n")
+	log.Info("connected", "node", nodes.CurrentUri())
+
+	// Links to the block explorer of the connected chain (see "explorers" in the config), or of the -network
+	chainID, err := client.ChainID(ctx)
+	utils.Perror(err)
+	if chainConfig != nil {
+		utils.Perror(chainConfig.CheckChainID(chainID.Int64()))
+	} else if err = common.SetExplorerForChainID(chainID.Int64()); err != nil {
+		log.Warn("unknown chain, using the default block explorer for links", "err", err, "explorer", common.CurrentExplorer.BaseUrl)
+	}
+
+	if *tracePtr {
+		blockcheck.TraceRpcClient, err = nodes.DialRpc(ctx, nodes.CurrentUri())
+		utils.Perror(err)
+	}
+
+	if *sandwichUsdPtr {
+		tokenRpcClient, err = nodes.DialRpc(ctx, nodes.CurrentUri())
+		utils.Perror(err)
+		blockcheck.SandwichTokens = prices.NewTokens(tokenRpcClient)
+		blockcheck.SandwichPrices = prices.NewCoinGecko(os.Getenv("COINGECKO_API_KEY"))
+	}
+
+	if *simulatePtr != "" {
+		blockcheck.SimulationRpc = flashbotsrpc.NewFlashbotsRPC(*simulatePtr)
+	}
+	if *verifyBundlesPtr && *simulatePtr == "" {
+		log.Fatal("-verifybundles requires -simulate")
+	}
+	blockcheck.VerifyBundles = *verifyBundlesPtr
+
+	if *relaysPtr != "" {
+		blockcheck.RelayClients, err = api.NewRelayClients(*relaysPtr)
+		utils.Perror(err)
+		if chaosTransport != nil {
+			for _, relay := range blockcheck.RelayClients {
+				relay.SetHttpClient(chaosTransport.Client(30 * time.Second))
+			}
+		}
+	}
+	if *beaconPtr != "" {
+		if *relaysPtr == "" {
+			log.Fatal("-beacon requires -relays")
+		}
+		blockcheck.BeaconClient = api.NewBeaconClient(*beaconPtr)
+	}
+
+	// Interactive debugger: block-watch [flags] debug block <n>
+	if flag.Arg(0) == "debug" {
+		if flag.NArg() != 3 || flag.Arg(1) != "block" {
+			log.Fatal("Usage: block-watch [flags] debug block <number>")
+		}
+		blockNumber, err := strconv.ParseInt(flag.Arg(2), 10, 64)
+		utils.Perror(err)
+		err = runDebugger(client, blockNumber, os.Stdin, os.Stdout)
+		utils.Perror(err)
+		return
+	}
+
+	// Re-check blocks and compare the hashes with the stored results: block-watch -jsonl <dir> verify <block>...
+	if flag.Arg(0) == "verify" {
+		if *jsonlDirPtr == "" || flag.NArg() < 2 {
+			log.Fatal("Usage: block-watch [flags] -jsonl <dir> verify <block>...")
+		}
+		sink, err := openJSONLSink(*jsonlDirPtr, 0, 0, *jsonlColdPtr, *jsonlColdAgePtr)
+		utils.Perror(err)
+		utils.Perror(verifyBlocks(client, sink, flag.Args()[1:]))
+		return
+	}
+
+	// Save blocks as fixtures for -fixtures: block-watch [flags] dump <dir> <block>...
+	if flag.Arg(0) == "dump" {
+		if flag.NArg() < 3 {
+			log.Fatal("Usage: block-watch [flags] dump <dir> <block>...")
+		}
+		utils.Perror(dumpFixtures(client, flag.Arg(1), flag.Args()[2:]))
+		return
+	}
+
+	// Run the complete checks for an alert-only instance: block-watch -coordinator <dir> [flags] analysis-worker <stream url>
+	if flag.Arg(0) == "analysis-worker" {
+		if *coordinatorPtr == "" || flag.NArg() != 2 {
+			log.Fatal("Usage: block-watch -coordinator <dir> [flags] analysis-worker <stream url>")
+		}
+		store, err := coordinator.NewStore(*coordinatorPtr)
+		utils.Perror(err)
+		runAnalysisWorker(ctx, client, store, flag.Arg(1))
+		return
+	}
 
 	if *blockHeightPtr != 0 {
 		// get block with receipts
@@ -70,169 +506,559 @@ func main() {
 		// check the block
 		check, err := blockcheck.CheckBlock(block, false)
 		if err != nil {
-			fmt.Println("Check at height error:", err)
+			log.Error("check error", "block", *blockHeightPtr, "err", err)
 		}
 		msg := check.Sprint(true, false, true)
 		print(msg)
 	}
 
 	if *watchPtr {
-		log.Println("Start watching...")
-		watch(client)
-	}
-}
+		if *unclesPtr {
+			uncleDetector = uncles.NewDetector(client)
+			if bidHistory != nil {
+				uncleDetector.OnBundle = addUncleBundleToBidHistory
+			}
+		}
 
-func watch(client *ethclient.Client) {
-	headers := make(chan *types.Header)
-	sub, err := client.SubscribeNewHead(context.Background(), headers)
-	utils.Perror(err)
+		if *mevSharePtr {
+			if common.CurrentChain.MevShareUrl == "" {
+				log.Fatal("-mevshare: no MEV-Share stream on " + common.CurrentChain.Name)
+			}
+			mevShareTracker = watcher.NewMevShareTracker(*mevShareWindowPtr)
+			go watchMevShare(ctx, api.NewMevShareClient(common.CurrentChain.MevShareUrl))
+		}
 
-	var errorCountSerious int
-	var errorCountNonSerious int
+		if *reportsPtr {
+			reports = blockcheck.NewReports()
+			reportDir = *reportDirPtr
+		}
 
-	for {
-		select {
-		case err := <-sub.Err():
-			log.Fatal(err)
-		case header := <-headers:
-			// New block header received. Download block with tx-receipts
-			b, err := blockswithtx.GetBlockWithTxReceipts(client, header.Number.Int64())
-			if err != nil {
-				err = errors.Wrap(err, "error in GetBlockWithTxReceipts")
-				log.Printf("%+v\n", err)
-				continue
+		if *payoutsPtr {
+			weeklyPayouts = analytics.NewPayoutReconciler(nodes)
+		}
+
+		go notifications.Run(ctx)
+		shareTracker = metrics.NewShareTracker(*zeroShareBlocksPtr)
+		rewardTracker = metrics.NewRewardTracker(*rewardDeviationPtr)
+		if *correlatePtr > 0 {
+			correlator = blockcheck.NewCorrelator(*correlatePtr, *correlateBlocksPtr)
+		}
+
+		blockWatcher = watcher.New(client)
+		blockWatcher.Confirmations = *confirmationsPtr
+		blockWatcher.Blocks = receipts.NewFetcher(nodes.RpcClient())
+		blockWatcher.Audit = auditLog
+		blockWatcher.FetchWorkers = *fetchWorkersPtr
+		blockWatcher.AlertLookAhead = *alertLookAheadPtr
+		blockWatcher.MaxHeadLag = *maxHeadLagPtr
+		blockWatcher.MaxApiDowntime = *maxApiDowntimePtr
+		blockWatcher.ApiWaitTimeout = *apiWaitTimeoutPtr
+		blockWatcher.MaxBacklogMemory = *backlogMemoryPtr
+		blockWatcher.SpillDir = *spillDirPtr
+		blockWatcher.OnHealthAlert = handleHealthAlert
+		blockWatcher.Notifiers = append(blockWatcher.Notifiers, watcher.NotifierFunc(notify))
+		blockWatcher.OnNewBlock = func(b *blockswithtx.BlockWithTxReceipts) {
+			nodes.BlockReceived()
+			processNewBlock(nodes.Client(), b)
+		}
+		blockWatcher.OnBlockChecked = processCheck
+		blockWatcher.OnReorg = handleReorgedBlock
+		for _, miner := range strings.Split(*priorityMinersPtr, ",") {
+			if miner = strings.TrimSpace(miner); miner != "" {
+				blockWatcher.PriorityMiners = append(blockWatcher.PriorityMiners, miner)
+			}
+		}
+		if *shedLagPtr > 0 {
+			blockWatcher.LoadShedder = watcher.NewLoadShedder(*shedLagPtr, *shedAfterPtr)
+			blockWatcher.OnLoadShed = handleLoadShed
+			blockWatcher.OnRecheck = handleRecheck
+		}
+		blockWatcher.ErrorHandler = handleWatcherError
+		blockWatcher.Session = watcher.NewSession()
+		if *checkpointPtr != "" {
+			blockWatcher.Storage = watcher.NewFileStorage(*checkpointPtr)
+		}
+		if mevShareTracker != nil {
+			blockWatcher.Sinks = append(blockWatcher.Sinks, mevShareTracker)
+		}
+		if *coordinatorPtr != "" {
+			if *httpPtr == "" {
+				log.Fatal("-coordinator requires -http, the analysis workers consume /stream")
+			}
+			store, err := coordinator.NewStore(*coordinatorPtr)
+			utils.Perror(err)
+			blockWatcher.AlertOnly = true
+			blockWatcher.Sinks = append(blockWatcher.Sinks, &coordinator.JobSink{Store: store})
+			go watchAnalysisResults(ctx, store)
+		}
+		if *explorerPtr {
+			explorerServer = explorer.NewServer(api.DefaultClient)
+			blockWatcher.Sinks = append(blockWatcher.Sinks, explorerServer)
+		}
+		if *grpcPtr != "" || (*discordBotKeyPtr != "" && *httpPtr != "") { // the bot answers with the queries of the gRPC service
+			grpcServer = newGrpcServer(nodes)
+			blockWatcher.Sinks = append(blockWatcher.Sinks, grpcServer)
+		}
+		if *discordBotKeyPtr != "" && *httpPtr != "" {
+			discordBot, err = discordbot.NewBot(*discordBotKeyPtr, grpcServer)
+			utils.Perror(err)
+		}
+		if *jsonlDirPtr != "" {
+			sink, err := openJSONLSink(*jsonlDirPtr, *jsonlMaxSizePtr*1024*1024, *jsonlMaxFilesPtr, *jsonlColdPtr, *jsonlColdAgePtr)
+			utils.Perror(err)
+			defer sink.Close()
+			if *jsonlColdPtr != "" {
+				go sink.Checks.Cold.Run(ctx, time.Hour, sink.Files(), handleWatcherError)
+			}
+			if *jsonlInputsPtr {
+				sink.ArchiveInputs()
+			}
+			blockWatcher.Sinks = append(blockWatcher.Sinks, sink)
+			if explorerServer != nil {
+				explorerServer.Results = sink
+			}
+			if grpcServer != nil {
+				grpcServer.Results = sink
 			}
 
-			if !silent {
-				fmt.Println("Queueing new block", b.Block.Number())
+			if *replicaKeyPtr != "" {
+				key, err := watcher.LoadReplicaKey(*replicaKeyPtr)
+				utils.Perror(err)
+				replicaHandler = watcher.ReplicaHandler(sink, key)
 			}
+		}
 
-			// Add to backlog, because it can only be processed when the Flashbots API has caught up
-			BlockBacklog[header.Number.Int64()] = b
+		if *parquetDirPtr != "" {
+			exporter := export.NewExporter(*parquetDirPtr)
+			defer func() {
+				if err := exporter.Close(); err != nil {
+					log.Error("parquet export error", "err", err)
+				}
+			}()
+			blockWatcher.Sinks = append(blockWatcher.Sinks, exporter)
+		}
 
-			// Query flashbots API to get latest block it has processed
-			opts := api.GetBlocksOptions{BlockNumber: header.Number.Int64()}
-			flashbotsResponse, err := api.GetBlocks(&opts)
+		if *pushUrlPtr != "" {
+			backend, err := metrics.OpenPushBackend(*pushUrlPtr, *pushTokenPtr)
+			utils.Perror(err)
+			tags, err := metrics.ParseTags(*pushTagsPtr)
+			utils.Perror(err)
+			pusher := metrics.NewPusher(backend, tags)
+			pusher.ApiLag = func() int64 { return blockWatcher.Health().ApiLag }
+			blockWatcher.Sinks = append(blockWatcher.Sinks, pusher)
+			pushCtx, stopPush := context.WithCancel(context.Background())
+			pushDone := make(chan bool)
+			go func() {
+				pusher.Run(pushCtx, *pushIntervalPtr, func(err error) { log.Error("push error", "err", err) })
+				close(pushDone)
+			}()
+			defer func() { // the last points are pushed after the watcher stopped
+				stopPush()
+				<-pushDone
+			}()
+		}
+
+		resumed, err := blockWatcher.Resume(ctx)
+		if err != nil {
+			log.Error("resume from checkpoint error", "err", err)
+		}
+		if resumed {
+			log.Info("resuming from checkpoint", "backlog", blockWatcher.BacklogSize())
+		} else if *warmStartPtr > 0 {
+			err = warmStart(client, *warmStartPtr)
 			if err != nil {
-				log.Println("Flashbots API error:", err)
-				continue
-			}
-
-			// Go through block-backlog, and process those within Flashbots API range
-			for height, blockFromBacklog := range BlockBacklog {
-				if height <= flashbotsResponse.LatestBlockNumber {
-					if !silent {
-						utils.PrintBlock(blockFromBacklog.Block)
-					}
-
-					check, err := blockcheck.CheckBlock(blockFromBacklog, false)
-					if err != nil {
-						log.Println("CheckBlock from backlog error:", err, "block:", blockFromBacklog.Block.Number())
-						break
-					}
-
-					// no checking error, can process and remove from backlog
-					delete(BlockBacklog, blockFromBacklog.Block.Number().Int64())
-
-					// Handle errors in the bundle (print, Discord, etc.)
-					if check.HasErrors() {
-						if check.HasSeriousErrors() { // only serious errors are printed and sent to Discord
-							errorCountSerious += 1
-							msg := check.Sprint(true, false, true)
-							fmt.Println(msg)
-
-							// if sendErrorsToDiscord {
-							// 	if len(check.Errors) == 1 && check.HasBundleWith0EffectiveGasPrice {
-							// 		// Short message if only 1 error and that is a 0-effective-gas-price
-							// 		msg := check.SprintHeader(false, true)
-							// 		msg += " - Error: " + check.Errors[0]
-							// 		SendToDiscord(msg)
-							// 	} else {
-							// 		SendToDiscord(check.Sprint(false, true))
-							// 	}
-							// }
-							fmt.Println("")
-						} else if check.HasLessSeriousErrors() { // less serious errors are only counted
-							errorCountNonSerious += 1
-						}
-
-						// Send failed TX to Discord
-						// if sendErrorsToDiscord && check.TriggerAlertOnFailedTx {
-						// 	SendToDiscord(check.Sprint(false, true, false))
-						// }
-
-						// Count errors
-						if check.HasSeriousErrors() || check.HasLessSeriousErrors() { // update and print miner error count on serious and less-serious errors
-							log.Printf("stats - 50p_errors: %d, 25p_errors: %d\n", errorCountSerious, errorCountNonSerious)
-							weeklyErrorSummary.AddCheckErrors(check)
-							dailyErrorSummary.AddCheckErrors(check)
-							fmt.Println(dailyErrorSummary.String())
-						}
-					}
-
-					// IS IT TIME TO RESET DAILY & WEEKLY ERRORS?
-					now := time.Now()
-
-					// Daily summary at 3pm ET
-					dailySummaryTriggerHourUtc := 19 // 3pm ET
-					// log.Println(now.UTC().Hour(), dailySummaryTriggerHourUtc, time.Since(dailyErrorSummary.TimeStarted).Hours())
-					if now.UTC().Hour() == dailySummaryTriggerHourUtc && time.Since(dailyErrorSummary.TimeStarted).Hours() >= 2 {
-						log.Println("trigger daily summary")
-						if sendErrorsToDiscord {
-							msg := dailyErrorSummary.String()
-							if msg != "" {
-								fmt.Println(msg)
-								SendToDiscord("Daily miner summary: ```" + msg + "```")
-							}
-						}
-
-						// reset daily summery
-						dailyErrorSummary.Reset()
-					}
-
-					// Weekly summary on Friday at 10am ET
-					weeklySummaryTriggerHourUtc := 14 // 10am ET
-					if now.UTC().Weekday() == time.Friday && now.UTC().Hour() == weeklySummaryTriggerHourUtc && time.Since(weeklyErrorSummary.TimeStarted).Hours() >= 2 {
-						log.Println("trigger weekly summary")
-						if sendErrorsToDiscord {
-							msg := weeklyErrorSummary.String()
-							if msg != "" {
-								fmt.Println(msg)
-								SendToDiscord("Weekly miner summary: ```" + msg + "```")
-							}
-						}
-
-						// reset weekly summery
-						weeklyErrorSummary.Reset()
-					}
-
-					// // -------- Send daily summary to Discord ---------
-					// if sendErrorsToDiscord {
-					// 	// Check if it's time to send to Discord: first block after 3pm ET (7pm UTC)
-					// 	// triggerHourUtc := 19
-
-					// 	// dateLastSent := lastSummarySentToDiscord.Format("01-02-2006")
-					// 	// dateToday := now.Format("01-02-2006")
-
-					// 	// For testing, send at specific interval
-					// 	if time.Since(dailyErrorSummary.TimeStarted).Hours() >= 3 {
-					// 		// if dateToday != dateLastSent && now.UTC().Hour() == triggerHourUtc {
-					// 		log.Println("Sending summary to Discord:")
-					// 		msg := dailyErrorSummary.String()
-					// 		if msg != "" {
-					// 			fmt.Println(msg)
-					// 			SendToDiscord("```" + msg + "```")
-					// 		}
-
-					// 		// Reset errors
-					// 		dailyErrorSummary.Reset()
-					// 		log.Println("Done, errors are reset.")
-					// 	}
-					// }
-
-					time.Sleep(1 * time.Second)
+				log.Error("warm start error", "err", err)
+			}
+		}
+
+		// Recover panics in the watch loop and restart it, the backlog and stats are preserved
+		watchdog := NewWatchdog()
+		watchdog.OnPanic = reportPanic
+
+		if adminSocket != "" {
+			listener, err := serveAdminSocket(adminSocket)
+			utils.Perror(err)
+			defer listener.Close()
+		}
+
+		if *httpPtr != "" {
+			server, err := serveStatus(*httpPtr)
+			utils.Perror(err)
+			defer server.Close()
+		}
+		if *grpcPtr != "" {
+			server, err := serveGrpc(*grpcPtr, *grpcCertPtr, *grpcKeyPtr)
+			utils.Perror(err)
+			defer server.Close()
+		}
+
+		// The dashboard replaces the block output, and shows the log lines and alerts
+		if *tuiPtr {
+			silent = true
+			dashboard = NewDashboard(os.Stdout)
+			logging.SetConsole(dashboard)
+			defer dashboard.Close()
+		}
+
+		updateServiceStatus(func(status *ServiceStatus) {
+			status.State = "watching"
+			status.Node = nodes.CurrentUri()
+			status.Backlog = blockWatcher.BacklogSize()
+		})
+		sdNotify("READY=1")
+
+		log.Info("start watching")
+		exitReason := "shutdown"
+		for {
+			err = watchdog.Run(func() error { return blockWatcher.Run(ctx) })
+			if ctx.Err() != nil {
+				log.Info("shutting down")
+				break
+			}
+
+			if errors.Is(err, ErrCrashLoop) {
+				msg := fmt.Sprintf("block-watch stopped: %v (%d restarts)", err, watchdog.Restarts)
+				if sendErrorsToDiscord {
+					SendToDiscordOps(msg)
 				}
+				sendSessionSummary(*exitSummaryPtr, "crash loop")
+				log.Fatal(msg)
+			}
+
+			// Subscription failed or stalled: switch to the next node
+			log.Warn("watch error, failing over", "node", nodes.CurrentUri(), "err", err)
+			client, err = nodes.Failover(ctx)
+			if err != nil {
+				exitReason = fmt.Sprintf("node failover failed: %v", err)
+				break
+			}
+			log.Info("connected", "node", nodes.CurrentUri())
+			blockWatcher.Session.AddFailover()
+			updateServiceStatus(func(status *ServiceStatus) {
+				status.Node = nodes.CurrentUri()
+				status.FailoverCount += 1
+			})
+			blockWatcher.SetClient(client)
+			blockWatcher.Blocks = receipts.NewFetcher(nodes.RpcClient())
+			if *tracePtr {
+				redialTraceClient(ctx, nodes)
 			}
+			if *sandwichUsdPtr {
+				redialTokenClient(ctx, nodes)
+			}
+			if uncleDetector != nil {
+				uncleDetector.SetClient(client)
+			}
+		}
+
+		updateServiceStatus(func(status *ServiceStatus) { status.State = "stopping" })
+		sdNotify("STOPPING=1")
+
+		if blockWatcher.BacklogSize() > 0 {
+			log.Warn("blocks in backlog were not processed, will continue from the checkpoint on restart", "backlog", blockWatcher.BacklogSize(), "checkpoint", blockWatcher.CheckpointHeight())
+		}
+		blockWatcher.Stop()
+		notifications.Flush(time.Now())
+		saveRollups()
+		saveCensus()
+		saveBidHistory()
+		if chaosTransport != nil {
+			log.Info("chaos stats", "stats", chaosTransport.Stats())
+		}
+		sendSessionSummary(*exitSummaryPtr, exitReason)
+	}
+}
+
+// processNewBlock is called for every new block, before it's queued for checking
+func processNewBlock(client *ethclient.Client, b *blockswithtx.BlockWithTxReceipts) {
+	serviceBlockReceived(b.Block.Number().Int64())
+	if dashboard != nil {
+		dashboard.BlockReceived(b.Block.Number().Int64())
+	}
+	if !silent {
+		log.Info("queueing new block", "block", b.Block.Number())
+	}
+
+	if uncleDetector != nil {
+		checkUncles(b.Block)
+	}
+
+	// Every mined block is added to the payout reconciliation, also those which are never checked
+	if weeklyPayouts != nil {
+		if err := weeklyPayouts.AddBlock(b); err != nil {
+			log.Error("payout reconciliation error", "block", b.Block.Number(), "err", err)
+		}
+	}
+
+	// Sample the pending pool size for the gas limit pressure stats
+	if pendingTxCount, err := client.PendingTransactionCount(context.Background()); err == nil {
+		dailyCapacityStats.AddPendingTxCount(time.Now(), pendingTxCount)
+	}
+}
+
+// processCheck handles the result of a block check (stats and summaries, alerts are sent by notify)
+func processCheck(check *blockcheck.BlockCheck) {
+	defer serviceBlockChecked(check)
+	if dashboard != nil {
+		dashboard.AddCheck(check)
+	}
+	if !silent && !check.IsLowActivity {
+		utils.PrintBlock(check.EthBlock)
+	}
+
+	dailyCapacityStats.AddCheck(check)
+	share := metrics.NewBlockShare(check)
+	if alert := shareTracker.Add(share); alert != nil {
+		sendOpsAlert(alert.String(), alert.Resolved)
+	}
+	if alert := rewardTracker.Add(share); alert != nil {
+		sendOpsAlert(alert.String(), alert.Resolved)
+	}
+	correlateCheck(check)
+	addToRollups(check)
+	addToCensus(check)
+	addToBidHistory(check)
+	if reports != nil {
+		for _, report := range reports.AddCheck(check) {
+			sendReport(report)
 		}
 	}
+	if weeklyPayouts != nil {
+		if err := weeklyPayouts.AddCheck(check); err != nil {
+			log.Error("payout reconciliation error", "block", check.Number, "err", err)
+		}
+	}
+
+	if len(check.WatchlistMatches) > 0 {
+		notifyWatchlist(check)
+	}
+
+	// Fast path for blocks without Flashbots or 0-gas transactions: only counted
+	if check.IsLowActivity {
+		numLowActivityBlocks += 1
+		sendSummariesIfDue(time.Now())
+		return
+	}
+
+	// Handle errors in the bundle (print, Discord, etc.)
+	if check.HasErrors() {
+		if check.HasSeriousErrors() { // by default, only serious errors are printed
+			errorCountSerious += 1
+
+			// if sendErrorsToDiscord {
+			// 	if len(check.CheckErrors) == 1 && check.CheckErrors[0].Kind == blockcheck.ErrorBundleHas0Fee {
+			// 		// Short message if only 1 error and that is a 0-effective-gas-price
+			// 		msg := check.SprintHeader(false, true)
+			// 		msg += " - Error: " + check.CheckErrors[0].String()
+			// 		SendToDiscord(msg)
+			// 	} else {
+			// 		SendToDiscord(check.Sprint(false, true))
+			// 	}
+			// }
+		} else if check.HasLessSeriousErrors() { // by default, less serious errors are only counted
+			errorCountNonSerious += 1
+		}
+
+		// Send failed TX to Discord
+		// if sendErrorsToDiscord && check.TriggerAlertOnFailedTx {
+		// 	SendToDiscord(check.Sprint(false, true, false))
+		// }
+
+		// Count errors
+		if check.HasSeriousErrors() || check.HasLessSeriousErrors() { // update and print miner error count on serious and less-serious errors
+			log.Info("stats", "50p_errors", errorCountSerious, "25p_errors", errorCountNonSerious, "low_activity_blocks", numLowActivityBlocks)
+			check.AddedToSummary = true
+			weeklyErrorSummary.AddCheckErrors(check)
+			dailyErrorSummary.AddCheckErrors(check)
+			if dashboard == nil {
+				fmt.Println(dailyErrorSummary.String())
+			}
+		}
+	}
+
+	// IS IT TIME TO RESET DAILY & WEEKLY ERRORS?
+	sendSummariesIfDue(time.Now())
+}
+
+// notify sends the check to the notifiers configured for the severity
+func notify(check *blockcheck.BlockCheck, severity string) error {
+	if coveredByIncident(check, severity) {
+		return nil
+	}
+	if !alertRateLimiter.Allow(check) {
+		log.Info("alert suppressed (rate limit)", "block", check.Number, "miner", check.Miner)
+		auditLog.Add(audit.Event{Block: check.Number, Type: audit.EventAlertDropped, Severity: severity, Message: "rate limit per miner and error type"})
+		return nil
+	}
+
+	incidentLink := ""
+	if incidentDir != "" && severity == blockcheck.SeveritySerious {
+		_, txFilename, err := check.Incident().WriteFiles(incidentDir)
+		if err != nil {
+			log.Error("error writing incident files", "block", check.Number, "err", err)
+		} else if incidentUrl != "" {
+			incidentLink = fmt.Sprintf("tx list: <%s/%s>\n", incidentUrl, filepath.Base(txFilename))
+		} else {
+			incidentLink = fmt.Sprintf("tx list: %s\n", txFilename)
+		}
+	}
+
+	incidentLink += failedTxCostInfo(check)
+
+	if config.HasNotifier(severity, "terminal") {
+		printToTerminal(check.Sprint(true, false, true) + "\n" + incidentLink)
+		auditLog.Add(audit.Event{Block: check.Number, Type: audit.EventAlertSent, Notifier: "terminal", Severity: severity})
+	}
+
+	if sendErrorsToDiscord && config.HasNotifier(severity, "discord") {
+		if notifications.Add(check.Number, checkDedupKey(check), check.Sprint(false, true, true)+"\n"+incidentLink) {
+			auditLog.Add(audit.Event{Block: check.Number, Type: audit.EventAlertQueued, Notifier: "discord", Severity: severity})
+		} else {
+			log.Info("alert not sent to Discord (duplicate)", "block", check.Number, "miner", check.Miner, "window", notifications.DedupWindow)
+			auditLog.Add(audit.Event{Block: check.Number, Type: audit.EventAlertDropped, Notifier: "discord", Severity: severity, Message: "duplicate"})
+		}
+	}
+	return nil
+}
+
+// failedTxCostInfo returns the gas burned by failed tx in the block and by the miner today, or "" if there are none
+func failedTxCostInfo(check *blockcheck.BlockCheck) string {
+	cost := check.FailedTxCost()
+	if cost.Sign() == 0 {
+		return ""
+	}
+
+	today := dailyErrorSummary.MinerFailedTxCost(check.Miner)
+	if !check.AddedToSummary { // alerts are sent before the check is counted
+		today.Add(today, cost)
+	}
+	return fmt.Sprintf("ETH wasted on failed tx: %s in this block, %s by this miner today\n", common.FormatEth(cost), common.FormatEth(today))
+}
+
+// notifyWatchlist sends the watched addresses found in the Flashbots transactions of the block, independent of errors
+func notifyWatchlist(check *blockcheck.BlockCheck) {
+	msg := check.SprintHeader(false, true) + "\n"
+	for _, match := range check.WatchlistMatches {
+		msg += "- " + match.String() + "\n"
+	}
+
+	printToTerminal(msg)
+	if sendErrorsToDiscord {
+		notifications.Add(check.Number, "", msg)
+		auditLog.Add(audit.Event{Block: check.Number, Type: audit.EventAlertQueued, Notifier: "discord", Message: "watchlist"})
+	}
+}
+
+// handleReorgedBlock invalidates the results of a replaced block (the watcher queues the new canonical block)
+func handleReorgedBlock(reorged watcher.ReorgedBlock) {
+	log.Warn(reorged.String(), "block", reorged.Height)
+
+	// The cached mev-blocks response is of the replaced block
+	if api.DefaultClient.Cache != nil {
+		if err := api.DefaultClient.Cache.Remove(reorged.Height); err != nil {
+			log.Error("error removing the reorged block from the api cache", "block", reorged.Height, "err", err)
+		}
+	}
+
+	// The replaced block was added to the payout reconciliation when it was received, also if it wasn't checked
+	if weeklyPayouts != nil {
+		weeklyPayouts.RemoveBlock(reorged.OldHash)
+	}
+
+	if reorged.ReportedCheck != nil {
+		removeCheckStats(reorged.ReportedCheck)
+		if sendErrorsToDiscord && reorged.ReportedCheck.HasSeriousErrors() {
+			notifications.Add(reorged.Height, "", reorged.String())
+		}
+	}
+}
+
+// handleRecheck removes the stats of a partial check (see -shedlag), its complete re-check is processed next
+func handleRecheck(previous *blockcheck.BlockCheck) {
+	log.Info("re-checking partially checked block", "block", previous.Number, "shed", previous.ShedSteps)
+	removeCheckStats(previous)
+}
+
+// handleLoadShed logs when the expensive checks are shed and when they run again
+func handleLoadShed(shedding bool, lag int64) {
+	if shedding {
+		log.Warn("sustained lag, shedding expensive checks (traces, simulations) until the lag is back to normal", "lag", lag)
+	} else {
+		log.Info("lag back to normal, running all checks and re-checking the partially checked blocks", "lag", lag, "pending", len(blockWatcher.LoadShedder.PendingRechecks()))
+	}
+}
+
+// handleHealthAlert sends operational alerts about the watcher itself
+func handleHealthAlert(alert watcher.HealthAlert) {
+	sendOpsAlert(alert.Message, alert.Resolved)
+}
+
+// sendOpsAlert sends an operational alert (or that its condition ended) to the terminal and the ops Discord channel
+func sendOpsAlert(message string, resolved bool) {
+	msg := "block-watch: " + message
+	if resolved {
+		log.Info("resolved: " + message)
+		msg = "block-watch (resolved): " + message
+	} else {
+		log.Warn(message)
+	}
+	printToTerminal(msg)
+	if sendErrorsToDiscord {
+		if err := SendToDiscordOps(msg); err != nil {
+			log.Error("error sending ops alert to Discord", "err", err)
+		}
+	}
+}
+
+// removeCheckStats removes a check from the rollups, census, bid history, payout reconciliation and error summaries
+func removeCheckStats(check *blockcheck.BlockCheck) {
+	if rollups != nil {
+		rollups.RemoveCheck(check)
+	}
+	if census != nil {
+		census.RemoveCheck(check)
+	}
+	if bidHistory != nil {
+		bidHistory.RemoveBlock(check.Number)
+	}
+	if weeklyPayouts != nil {
+		weeklyPayouts.RemoveBlock(check.EthBlock.Hash())
+	}
+
+	if check.AddedToSummary {
+		dailyErrorSummary.RemoveCheckErrors(check)
+		weeklyErrorSummary.RemoveCheckErrors(check)
+	}
+}
+
+// openJSONLSink opens the JSON lines files of -jsonl, with the cold storage of the old rotated files if set
+func openJSONLSink(dir string, maxSize int64, maxFiles int, cold string, coldAge time.Duration) (*watcher.JSONLSink, error) {
+	sink, err := watcher.NewJSONLSink(dir, maxSize, maxFiles)
+	if err != nil || cold == "" {
+		return sink, err
+	}
+	store := watcher.OpenObjectStore(cold)
+	if httpStore, ok := store.(*watcher.HTTPStore); ok && os.Getenv("COLD_STORAGE_AUTHORIZATION") != "" {
+		httpStore.Header.Set("Authorization", os.Getenv("COLD_STORAGE_AUTHORIZATION"))
+	}
+	sink.SetColdStorage(watcher.NewTiering(store, coldAge))
+	return sink, nil
+}
+
+// handleWatcherError logs errors of the watcher. Temporary Flashbots API and relay errors (rate limits, server errors)
+// are only warnings, the block is checked again later.
+func handleWatcherError(err error) {
+	var statusErr *api.StatusError
+	switch {
+	case errors.Is(err, api.ErrRateLimited):
+		log.Warn("rate limited by the api", "err", err)
+	case errors.Is(err, api.ErrServerError) && errors.As(err, &statusErr):
+		log.Warn("api server error", "err", err, "status", statusErr.StatusCode, "body", statusErr.Body)
+	case errors.Is(err, api.ErrNotFound):
+		log.Warn("api endpoint not found", "err", err)
+	case errors.Is(err, watcher.ErrApiWaitTimeout), errors.Is(err, watcher.ErrCheckRetries):
+		sendOpsAlert(err.Error(), false)
+	default:
+		log.Error("watcher error", "err", err)
+	}
 }
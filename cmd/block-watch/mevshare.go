@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/watcher"
+)
+
+var mevShareTracker *watcher.MevShareTracker // only with -mevshare
+
+// Wait times before reconnecting to the MEV-Share stream, doubled on every failed connection
+var (
+	mevShareMinBackoff = 5 * time.Second
+	mevShareMaxBackoff = 2 * time.Minute
+)
+
+// watchMevShare adds the hints of the MEV-Share stream to the tracker until the context is cancelled, and reconnects
+// when the stream fails
+func watchMevShare(ctx context.Context, client *api.MevShareClient) {
+	backoff := mevShareMinBackoff
+	for {
+		connected := time.Now()
+		err := client.Subscribe(ctx, mevShareTracker.AddHint)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(connected) > mevShareMaxBackoff { // the stream was up for a while
+			backoff = mevShareMinBackoff
+		}
+		log.Warn("mev-share stream error, reconnecting", "url", client.StreamUrl, "backoff", backoff, "err", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > mevShareMaxBackoff {
+			backoff = mevShareMaxBackoff
+		}
+	}
+}
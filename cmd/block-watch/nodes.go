@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/prices"
+)
+
+var errNoNode = errors.New("no eth node connected")
+
+// tokenRpcClient is the connection of blockcheck.SandwichTokens, replaced by redialTokenClient
+var tokenRpcClient *rpc.Client
+
+// NodePool connects to one of several Ethereum nodes, and fails over to the next one if the current one is unhealthy.
+// The current node and its clients can be read from other goroutines during a failover.
+type NodePool struct {
+	Uris []string
+
+	lock      sync.RWMutex
+	current   int
+	client    *ethclient.Client
+	rpcClient *rpc.Client
+	failovers int // consecutive failovers without a received block (see BlockReceived)
+
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	HttpClient *http.Client // used for http(s) nodes if set (eg. with a chaos transport)
+}
+
+// NewNodePool accepts a comma-separated list of node URIs, the first one is the primary node
+func NewNodePool(uris string) *NodePool {
+	pool := &NodePool{
+		MinBackoff: 2 * time.Second,
+		MaxBackoff: 2 * time.Minute,
+	}
+	for _, uri := range strings.Split(uris, ",") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			pool.Uris = append(pool.Uris, uri)
+		}
+	}
+	return pool
+}
+
+func (p *NodePool) CurrentUri() string {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.Uris[p.current]
+}
+
+// Client returns the client of the current node (nil during a failover)
+func (p *NodePool) Client() *ethclient.Client {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.client
+}
+
+// RpcClient returns the rpc client of the current node (eg. for a receipts.Fetcher)
+func (p *NodePool) RpcClient() *rpc.Client {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.rpcClient
+}
+
+// BalanceAt queries the balance from the current node (see analytics.BalanceReader)
+func (p *NodePool) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	client := p.Client()
+	if client == nil {
+		return nil, errNoNode
+	}
+	return client.BalanceAt(ctx, account, blockNumber)
+}
+
+// Connect tries all nodes (starting with the current one) until a connection succeeds. Between rounds
+// it waits with exponential backoff. Only returns an error if the context is cancelled.
+func (p *NodePool) Connect(ctx context.Context) (*ethclient.Client, error) {
+	if len(p.Uris) == 0 {
+		return nil, errors.New("no eth node uri")
+	}
+
+	for round := 1; ; round++ {
+		for i := 0; i < len(p.Uris); i++ {
+			uri := p.CurrentUri()
+			client, rpcClient, err := p.dial(ctx, uri)
+			if err == nil {
+				p.lock.Lock()
+				p.client, p.rpcClient = client, rpcClient
+				p.lock.Unlock()
+				return client, nil
+			}
+
+			log.Warn("eth node unavailable", "node", uri, "err", err)
+			p.lock.Lock()
+			p.current = (p.current + 1) % len(p.Uris)
+			p.lock.Unlock()
+		}
+
+		backoff := p.backoff(round)
+		log.Warn("no eth node available, retrying", "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// backoff returns the wait after n consecutive failures: 0 without failures, then MinBackoff, doubling up to
+// MaxBackoff
+func (p *NodePool) backoff(n int) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	backoff := p.MinBackoff
+	for i := 1; i < n && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	return backoff
+}
+
+// dial connects to the node and checks that it responds
+func (p *NodePool) dial(ctx context.Context, uri string) (*ethclient.Client, *rpc.Client, error) {
+	rpcClient, err := p.DialRpc(ctx, uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	client := ethclient.NewClient(rpcClient)
+
+	healthCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if _, err = client.BlockNumber(healthCtx); err != nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("health check failed: %w", err)
+	}
+
+	return client, rpcClient, nil
+}
+
+// DialRpc connects to the node, using HttpClient for http(s) nodes if set
+func (p *NodePool) DialRpc(ctx context.Context, uri string) (*rpc.Client, error) {
+	if p.HttpClient != nil && (strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://")) {
+		return rpc.DialHTTPWithClient(uri, p.HttpClient)
+	}
+	return rpc.DialContext(ctx, uri)
+}
+
+// Failover closes the current connection and connects to the next node. Consecutive failovers without a block
+// received in between wait with exponential backoff first, so a node which connects but fails the subscription (eg. an
+// http-only node) isn't retried in a tight loop.
+func (p *NodePool) Failover(ctx context.Context) (*ethclient.Client, error) {
+	p.lock.Lock()
+	if p.client != nil {
+		p.client.Close()
+		p.client, p.rpcClient = nil, nil
+	}
+	p.current = (p.current + 1) % len(p.Uris)
+	backoff := p.backoff(p.failovers)
+	p.failovers += 1
+	p.lock.Unlock()
+
+	if backoff > 0 {
+		log.Warn("repeated watch errors, waiting before connecting", "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+	return p.Connect(ctx)
+}
+
+// BlockReceived resets the backoff of Failover, the current node works
+func (p *NodePool) BlockReceived() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.failovers = 0
+}
+
+// redialTraceClient connects blockcheck.TraceRpcClient to the current node after a failover. On error, the previous
+// client is kept (the checks run without the traces until the next failover).
+func redialTraceClient(ctx context.Context, nodes *NodePool) {
+	traceClient, err := nodes.DialRpc(ctx, nodes.CurrentUri())
+	if err != nil {
+		log.Error("error connecting the trace client", "node", nodes.CurrentUri(), "err", err)
+		return
+	}
+	blockWatcher.PauseChecks(func() {
+		if blockcheck.TraceRpcClient != nil {
+			blockcheck.TraceRpcClient.Close()
+		}
+		blockcheck.TraceRpcClient = traceClient
+	})
+}
+
+// redialTokenClient replaces blockcheck.SandwichTokens with token lookups on the current node after a failover. On
+// error, the previous lookups are kept (the sandwich prices fail until the next failover).
+func redialTokenClient(ctx context.Context, nodes *NodePool) {
+	tokenRpc, err := nodes.DialRpc(ctx, nodes.CurrentUri())
+	if err != nil {
+		log.Error("error connecting the token client", "node", nodes.CurrentUri(), "err", err)
+		return
+	}
+	blockWatcher.PauseChecks(func() {
+		if tokenRpcClient != nil {
+			tokenRpcClient.Close()
+		}
+		tokenRpcClient = tokenRpc
+		blockcheck.SandwichTokens = prices.NewTokens(tokenRpc)
+	})
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNodePoolBackoff(t *testing.T) {
+	nodes := NewNodePool("ws://a,ws://b")
+	nodes.MinBackoff, nodes.MaxBackoff = time.Second, 5*time.Second
+
+	for n, expected := range []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if backoff := nodes.backoff(n); backoff != expected {
+			t.Errorf("%d failures: expected backoff %s, got %s", n, expected, backoff)
+		}
+	}
+
+	nodes.failovers = 3
+	nodes.BlockReceived()
+	if nodes.backoff(nodes.failovers) != 0 {
+		t.Error("expected no backoff after a received block")
+	}
+}
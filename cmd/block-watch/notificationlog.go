@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/metachris/flashbots/audit"
+)
+
+var notificationLog *audit.NotificationLog // nil if disabled
+
+// Notifications returned by /notifications.json by default, and at most
+const (
+	notificationsLimit    = 100
+	notificationsMaxLimit = 10000
+)
+
+// logNotification adds a message which was sent once, without the delivery chain (eg. ops alerts, summaries), to the
+// notification log
+func logNotification(channel string, msg string, err error) {
+	notification := audit.Notification{Channel: channel, Status: audit.NotificationDelivered, Attempts: 1, Payload: msg}
+	if err != nil {
+		notification.Status, notification.Error = audit.NotificationFailed, err.Error()
+	}
+	if logErr := notificationLog.Add(notification); logErr != nil {
+		log.Error("error writing the notification log", "err", logErr)
+	}
+}
+
+// notificationsHandler serves the notification log, newest first:
+// /notifications.json?since=<RFC3339>&until=<RFC3339>&channel=discord&status=failed&block=<n>&incident=<id>&limit=100
+func notificationsHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := audit.NotificationQuery{
+		Channel:  params.Get("channel"),
+		Status:   params.Get("status"),
+		Incident: params.Get("incident"),
+		Limit:    notificationsLimit,
+	}
+
+	var err error
+	for name, t := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if s := params.Get(name); s != "" {
+			if *t, err = time.Parse(time.RFC3339, s); err != nil {
+				http.Error(w, "invalid "+name+" '"+s+"' (RFC3339, eg. 2021-10-16T00:00:00Z)", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if s := params.Get("block"); s != "" {
+		if query.Block, err = strconv.ParseInt(s, 10, 64); err != nil || query.Block <= 0 {
+			http.Error(w, "invalid block '"+s+"'", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := params.Get("limit"); s != "" {
+		if query.Limit, err = strconv.Atoi(s); err != nil || query.Limit <= 0 || query.Limit > notificationsMaxLimit {
+			http.Error(w, "invalid limit '"+s+"' (1 to "+strconv.Itoa(notificationsMaxLimit)+")", http.StatusBadRequest)
+			return
+		}
+	}
+
+	notifications, err := notificationLog.Query(query)
+	if err != nil {
+		log.Error("error reading the notification log", "err", err)
+		http.Error(w, "error reading the notification log", http.StatusInternalServerError)
+		return
+	}
+	if notifications == nil {
+		notifications = []audit.Notification{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache, max-age=0")
+	json.NewEncoder(w).Encode(notifications)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metachris/flashbots/audit"
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+var notifications *NotificationManager = NewNotificationManager(0, 0, delivery.Send)
+
+// Alerts of a block are collected this long before they are sent as one message
+var NotificationBatchDelay = 5 * time.Second
+
+// NotificationManager sits between the alerts and Discord, so a miner which repeatedly produces the same errors
+// doesn't spam the channel:
+//
+// - identical alerts (same miner and error types) within DedupWindow are dropped
+// - all alerts of a block are batched into one message
+// - at most MaxPerMinute messages are sent per minute, the rest is counted and reported in one overflow message
+type NotificationManager struct {
+	DedupWindow  time.Duration // 0 = no deduplication
+	MaxPerMinute int           // 0 = unlimited
+	Send         func(blocks []int64, incidents []string, msg string) error
+	Now          func() time.Time // clock of the dedup window, time.Now if nil
+
+	lock         sync.Mutex
+	lastSent     map[string]time.Time // by dedup key
+	duplicates   map[string]int       // dropped duplicates since the last digest, by dedup key
+	pending      map[int64][]string   // alerts by block, until the next Flush
+	incidents    map[int64][]string   // IDs of the multi-block incidents of the pending alerts, by block
+	windowStart  time.Time
+	sentInWindow int
+	overflow     []int64 // blocks whose messages were dropped in the current window
+}
+
+func NewNotificationManager(dedupWindow time.Duration, maxPerMinute int, send func(blocks []int64, incidents []string, msg string) error) *NotificationManager {
+	return &NotificationManager{
+		DedupWindow:  dedupWindow,
+		MaxPerMinute: maxPerMinute,
+		Send:         send,
+		lastSent:     make(map[string]time.Time),
+		duplicates:   make(map[string]int),
+		pending:      make(map[int64][]string),
+		incidents:    make(map[int64][]string),
+	}
+}
+
+func (m *NotificationManager) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
+
+// checkDedupKey identifies identical alerts of a check: same miner and same error types
+func checkDedupKey(check *blockcheck.BlockCheck) string {
+	return check.Miner + " " + strings.Join(check.ErrorCounter.Types(), ",")
+}
+
+// Add queues an alert for the block. Returns false if it was dropped as a duplicate. An empty dedupKey disables
+// deduplication for this alert (eg. for reorgs).
+func (m *NotificationManager) Add(blockNumber int64, dedupKey string, msg string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := m.now()
+	if dedupKey != "" && m.DedupWindow > 0 {
+		if last, found := m.lastSent[dedupKey]; found && now.Sub(last) < m.DedupWindow {
+			m.duplicates[dedupKey] += 1
+			return false
+		}
+		m.lastSent[dedupKey] = now
+
+		// Forget expired keys
+		for key, t := range m.lastSent {
+			if now.Sub(t) >= m.DedupWindow {
+				delete(m.lastSent, key)
+			}
+		}
+	}
+
+	m.pending[blockNumber] = append(m.pending[blockNumber], msg)
+	return true
+}
+
+// AddIncident queues an alert of a multi-block incident, sent with the alerts of its last block
+func (m *NotificationManager) AddIncident(blockNumber int64, incidentID string, msg string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.pending[blockNumber] = append(m.pending[blockNumber], msg)
+	m.incidents[blockNumber] = append(m.incidents[blockNumber], incidentID)
+}
+
+// Flush sends the queued alerts, one message per block (in block order), within the per-minute limit. When a new
+// minute starts and messages were dropped in the last one, an overflow summary is sent first.
+func (m *NotificationManager) Flush(now time.Time) {
+	m.lock.Lock()
+	messages := m.nextMessages(now)
+	m.lock.Unlock()
+
+	for _, msg := range messages {
+		if err := m.Send(msg.blocks, msg.incidents, msg.text); err != nil {
+			log.Error("alert not delivered", "blocks", msg.blocks, "err", err)
+		}
+	}
+}
+
+// notification is a message with the alerts of one or more blocks
+type notification struct {
+	blocks    []int64
+	incidents []string
+	text      string
+}
+
+func (m *NotificationManager) nextMessages(now time.Time) (messages []notification) {
+	if now.Sub(m.windowStart) >= time.Minute {
+		if len(m.overflow) > 0 {
+			messages = append(messages, notification{blocks: m.overflow, text: m.overflowSummary()})
+		}
+		m.windowStart = now
+		m.sentInWindow = 0
+		m.overflow = nil
+	}
+
+	blocks := make([]int64, 0, len(m.pending))
+	for blockNumber := range m.pending {
+		blocks = append(blocks, blockNumber)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i] < blocks[j] })
+
+	for _, blockNumber := range blocks {
+		if m.MaxPerMinute > 0 && m.sentInWindow >= m.MaxPerMinute {
+			m.overflow = append(m.overflow, blockNumber)
+			auditLog.Add(audit.Event{Block: blockNumber, Type: audit.EventAlertDropped, Notifier: "discord", Message: "rate limit, in the overflow summary"})
+			continue
+		}
+		m.sentInWindow += 1
+		messages = append(messages, notification{[]int64{blockNumber}, m.incidents[blockNumber], strings.Join(m.pending[blockNumber], "\n")})
+	}
+
+	m.pending = make(map[int64][]string)
+	m.incidents = make(map[int64][]string)
+	return messages
+}
+
+func (m *NotificationManager) overflowSummary() string {
+	blocks := make([]string, len(m.overflow))
+	for i, blockNumber := range m.overflow {
+		blocks[i] = fmt.Sprint(blockNumber)
+	}
+	return fmt.Sprintf("%d more alerts in the last minute were not sent (rate limit of %d per minute), blocks: %s", len(m.overflow), m.MaxPerMinute, strings.Join(blocks, ", "))
+}
+
+// Run flushes the queued alerts every NotificationBatchDelay, until the context is cancelled
+func (m *NotificationManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(NotificationBatchDelay)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			m.Flush(now)
+		}
+	}
+}
+
+// Digest returns a summary of the dropped duplicates since the last call, and resets the counts
+func (m *NotificationManager) Digest() (ret string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	keys := make([]string, 0, len(m.duplicates))
+	for key := range m.duplicates {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return m.duplicates[keys[i]] > m.duplicates[keys[j]]
+	})
+
+	for _, key := range keys {
+		ret += fmt.Sprintf("%-90s duplicates=%d\n", key, m.duplicates[key])
+	}
+
+	m.duplicates = make(map[string]int)
+	return ret
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type sentNotification struct {
+	blocks    []int64
+	incidents []string
+	msg       string
+}
+
+func newTestNotificationManager(dedupWindow time.Duration, maxPerMinute int) (*NotificationManager, *time.Time, *[]sentNotification) {
+	now := time.Date(2021, 10, 16, 12, 0, 0, 0, time.UTC)
+	sent := make([]sentNotification, 0)
+	m := NewNotificationManager(dedupWindow, maxPerMinute, func(blocks []int64, incidents []string, msg string) error {
+		sent = append(sent, sentNotification{blocks, incidents, msg})
+		return nil
+	})
+	m.Now = func() time.Time { return now }
+	return m, &now, &sent
+}
+
+func TestNotificationDedup(t *testing.T) {
+	m, now, sent := newTestNotificationManager(time.Hour, 0)
+
+	if !m.Add(100, "miner failedFbTx", "alert 100") {
+		t.Fatal("expected the first alert to be queued")
+	}
+	*now = now.Add(30 * time.Minute)
+	if m.Add(101, "miner failedFbTx", "alert 101") {
+		t.Error("expected the identical alert within the window to be dropped")
+	}
+	if !m.Add(101, "", "reorg 101") {
+		t.Error("expected the alert without dedup key to be queued")
+	}
+	if digest := m.Digest(); !strings.Contains(digest, "miner failedFbTx") || !strings.Contains(digest, "duplicates=1") {
+		t.Error("unexpected digest", digest)
+	}
+	if digest := m.Digest(); digest != "" {
+		t.Error("expected the digest to be reset", digest)
+	}
+
+	// The window expired
+	*now = now.Add(31 * time.Minute)
+	if !m.Add(102, "miner failedFbTx", "alert 102") {
+		t.Error("expected the alert after the window to be queued")
+	}
+
+	m.Flush(*now)
+	if len(*sent) != 3 || (*sent)[0].msg != "alert 100" || (*sent)[1].msg != "reorg 101" || (*sent)[2].msg != "alert 102" {
+		t.Error("unexpected messages", *sent)
+	}
+}
+
+func TestNotificationBatching(t *testing.T) {
+	m, now, sent := newTestNotificationManager(0, 0)
+
+	m.Add(101, "b", "second block")
+	m.Add(100, "a", "first")
+	m.Add(100, "a", "first again") // no dedup without a window
+	m.AddIncident(100, "incident-1", "incident")
+
+	// One message per block, in block order
+	m.Flush(*now)
+	if len(*sent) != 2 {
+		t.Fatal("expected one message per block", *sent)
+	}
+	first := (*sent)[0]
+	if len(first.blocks) != 1 || first.blocks[0] != 100 || first.msg != "first\nfirst again\nincident" || len(first.incidents) != 1 || first.incidents[0] != "incident-1" {
+		t.Error("unexpected message of block 100", first)
+	}
+	if second := (*sent)[1]; second.blocks[0] != 101 || second.msg != "second block" || len(second.incidents) != 0 {
+		t.Error("unexpected message of block 101", second)
+	}
+
+	// Nothing left after the flush
+	m.Flush(*now)
+	if len(*sent) != 2 {
+		t.Error("expected no messages after the flush", *sent)
+	}
+}
+
+func TestNotificationOverflow(t *testing.T) {
+	m, now, sent := newTestNotificationManager(0, 2)
+
+	for block := int64(100); block < 104; block++ {
+		m.Add(block, "", "alert")
+	}
+	m.Flush(*now)
+	if len(*sent) != 2 || (*sent)[1].blocks[0] != 101 {
+		t.Fatal("expected 2 messages within the limit", *sent)
+	}
+
+	// Still in the same minute: dropped as well
+	m.Add(104, "", "alert")
+	m.Flush(now.Add(30 * time.Second))
+	if len(*sent) != 2 {
+		t.Fatal("expected no messages above the limit", *sent)
+	}
+
+	// The next minute starts with the overflow summary
+	m.Add(105, "", "alert")
+	m.Flush(now.Add(time.Minute))
+	if len(*sent) != 4 {
+		t.Fatal("expected the overflow summary and the new message", *sent)
+	}
+	summary := (*sent)[2]
+	if len(summary.blocks) != 3 || !strings.Contains(summary.msg, "3 more alerts") || !strings.Contains(summary.msg, "102, 103, 104") {
+		t.Error("unexpected overflow summary", summary)
+	}
+	if (*sent)[3].blocks[0] != 105 {
+		t.Error("expected the message of block 105", (*sent)[3])
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+// AlertRateLimiter limits the number of alerts per miner and error type per hour, so a single misbehaving
+// miner doesn't drown out everything else. Suppressed alerts are counted for the next digest.
+type AlertRateLimiter struct {
+	MaxAlertsPerHour int // 0 = unlimited
+
+	sent       map[string][]time.Time // alert times of the last hour, by miner and error type
+	suppressed map[string]int         // suppressed alerts since the last digest, by miner and error type
+}
+
+func NewAlertRateLimiter(maxAlertsPerHour int) *AlertRateLimiter {
+	return &AlertRateLimiter{
+		MaxAlertsPerHour: maxAlertsPerHour,
+		sent:             make(map[string][]time.Time),
+		suppressed:       make(map[string]int),
+	}
+}
+
+// Allow returns true if an alert should be sent for the check, which is the case if at least one of its
+// error types is still below the limit for the miner.
+func (rl *AlertRateLimiter) Allow(check *blockcheck.BlockCheck) bool {
+	if rl.MaxAlertsPerHour <= 0 {
+		return true
+	}
+
+	minerId := check.Miner
+	if check.MinerName != "" {
+		minerId += fmt.Sprintf(" (%s)", check.MinerName)
+	}
+
+	errorTypes := check.ErrorCounter.Types()
+	if len(errorTypes) == 0 {
+		return true
+	}
+
+	now := time.Now()
+	allow := false
+	for _, errorType := range errorTypes {
+		key := minerId + " " + errorType
+
+		// Only keep the alerts of the last hour
+		recent := make([]time.Time, 0, len(rl.sent[key]))
+		for _, t := range rl.sent[key] {
+			if now.Sub(t) < time.Hour {
+				recent = append(recent, t)
+			}
+		}
+
+		if len(recent) < rl.MaxAlertsPerHour {
+			recent = append(recent, now)
+			allow = true
+		} else {
+			rl.suppressed[key] += 1
+		}
+		rl.sent[key] = recent
+	}
+
+	return allow
+}
+
+// Digest returns a summary of the suppressed alerts since the last call, and resets the counts
+func (rl *AlertRateLimiter) Digest() (ret string) {
+	keys := make([]string, 0, len(rl.suppressed))
+	for key := range rl.suppressed {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return rl.suppressed[keys[i]] > rl.suppressed[keys[j]]
+	})
+
+	for _, key := range keys {
+		ret += fmt.Sprintf("%-90s suppressed=%d\n", key, rl.suppressed[key])
+	}
+
+	rl.suppressed = make(map[string]int)
+	return ret
+}
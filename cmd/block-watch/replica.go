@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/metachris/flashbots/watcher"
+)
+
+// replicaHandler serves the dumps of the JSONL sink on /replica (with -replicakey, -jsonl and -http)
+var replicaHandler http.Handler
+
+// generateReplicaKey writes a new signing key to the file, and prints the public key for the replicas
+func generateReplicaKey(filename string) error {
+	if _, err := os.Stat(filename); err == nil {
+		return fmt.Errorf("%s exists already", filename)
+	}
+
+	privateKey, publicKey, err := watcher.GenerateReplicaKey()
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(filename, []byte(privateKey+"\n"), 0600); err != nil {
+		return err
+	}
+	fmt.Printf("Key written to %s, public key for the replicas (-replicapubkey):\n%s\n", filename, publicKey)
+	return nil
+}
+
+// syncReplica fetches the new check results and incidents of the source into the directory
+func syncReplica(ctx context.Context, sourceUrl string, publicKeyHex string, dir string) error {
+	publicKey, err := watcher.ParseReplicaPublicKey(publicKeyHex)
+	if err != nil {
+		return err
+	}
+	replica, err := watcher.NewReplica(sourceUrl, publicKey, dir)
+	if err != nil {
+		return err
+	}
+	defer replica.Sink.Close()
+
+	added, err := replica.Sync(ctx)
+	if errors.Is(err, watcher.ErrReplicaAnchorMismatch) {
+		return fmt.Errorf("the history of the source changed (eg. old files were removed), remove %s and sync again: %w", dir, err)
+	} else if err != nil {
+		return err
+	}
+	log.Info("replica synced", "added", added, "dir", dir)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/metachris/flashbots/analytics"
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+var rollups *analytics.Rollups // only with -rollups
+var rollupsFile string
+var rollupsSaved time.Time
+
+// Rollups are saved at most this often (and on shutdown)
+var rollupsSaveInterval = 5 * time.Minute
+
+// addToRollups adds a checked block to the hourly and daily rollups, and saves them if due
+func addToRollups(check *blockcheck.BlockCheck) {
+	if rollups == nil {
+		return
+	}
+
+	rollups.AddCheck(check)
+	if time.Since(rollupsSaved) >= rollupsSaveInterval {
+		saveRollups()
+	}
+}
+
+func saveRollups() {
+	if rollups == nil {
+		return
+	}
+
+	if err := rollups.Save(rollupsFile); err != nil {
+		log.Error("error saving rollups", "file", rollupsFile, "err", err)
+	}
+	rollupsSaved = time.Now()
+}
+
+// printRollups prints the totals of the last n hours or days, and the stats of each hour or day.
+// Usage: block-watch -rollups rollups.json rollups <hour|day> <n>
+func printRollups(granularity string, n string) error {
+	if granularity != analytics.RollupHour && granularity != analytics.RollupDay {
+		return fmt.Errorf("invalid granularity '%s' (hour, day)", granularity)
+	}
+	count, err := strconv.Atoi(n)
+	if err != nil || count <= 0 {
+		return fmt.Errorf("invalid number of %ss '%s'", granularity, n)
+	}
+
+	period := time.Hour
+	if granularity == analytics.RollupDay {
+		period = 24 * time.Hour
+	}
+	to := time.Now().Add(period) // include the current hour or day
+	from := to.Add(-time.Duration(count) * period)
+
+	for _, rollup := range rollups.Series(granularity, from, to) {
+		fmt.Printf("%s \t blocks=%-5d errorBlocks=%-4d bundles=%-5d miners=%-3d searchers=%d\n", rollup.Start.Format("2006-01-02 15:04"), rollup.Blocks, rollup.ErrorBlocks, rollup.Bundles, len(rollup.Miners), len(rollup.Searchers))
+	}
+	fmt.Printf("\nLast %d %ss: %s", count, granularity, rollups.Query(granularity, from, to).String())
+	return nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/metrics"
+	"github.com/metachris/flashbots/watcher"
+)
+
+var adminSocket string // the status is served on this unix socket, if set (-adminsocket)
+
+// ServiceStatus is the machine-readable status of the running instance (see the status subcommand)
+type ServiceStatus struct {
+	State              string    `json:"state"` // starting, watching, stopping
+	Node               string    `json:"node"`
+	StartedAt          time.Time `json:"started_at"`
+	LatestBlock        int64     `json:"latest_block"`    // latest block received from the node
+	LastBlock          int64     `json:"last_block"`      // last checked block
+	LastBlockTime      time.Time `json:"last_block_time"` // time when the last block was checked
+	Lag                int64     `json:"lag"`             // blocks received but not yet checked
+	Backlog            int       `json:"backlog"`         // blocks queued for checking
+	SeriousErrors      int       `json:"serious_errors"`  // since the start
+	LessSeriousErrors  int       `json:"less_serious_errors"`
+	SeriousErrorsToday int       `json:"serious_errors_today"` // blocks with serious errors since midnight UTC
+	LowActivity        int       `json:"low_activity_blocks"`
+	FailoverCount      int       `json:"failovers"`
+	UptimeSeconds      int64     `json:"uptime_sec"`
+	LastCheckAgeMs     int64     `json:"last_check_age_ms"` // time since the last block was checked
+
+	LoadShedding    bool `json:"load_shedding"`    // expensive checks are skipped (see -shedlag)
+	PendingRechecks int  `json:"pending_rechecks"` // partially checked blocks
+
+	FlashbotsShare  []metrics.ShareAverages       `json:"flashbots_share,omitempty"`  // rolling averages of the bundle gas share and bundles per block
+	MevShare        *watcher.MevShareStats        `json:"mev_share,omitempty"`        // hints and how many landed in a bundle (only with -mevshare)
+	RewardAnomalies []*metrics.RewardAnomalyAlert `json:"reward_anomalies,omitempty"` // miners whose bundle reward per block deviates from the network average
+
+	Notifications     map[string]DeliveryStats `json:"notifications"`      // alert delivery by notifier
+	UndeliveredAlerts int                      `json:"undelivered_alerts"` // since the start
+
+	today string // day of SeriousErrorsToday, YYYY-MM-DD (UTC)
+}
+
+// String is the status line for systemd (sd_notify STATUS)
+func (s ServiceStatus) String() string {
+	return fmt.Sprintf("%s - last block %d, lag %d, backlog %d, node %s", s.State, s.LastBlock, s.Lag, s.Backlog, s.Node)
+}
+
+// serviceState is updated by the watch loop, and read by the admin socket
+var serviceState = struct {
+	sync.Mutex
+	status ServiceStatus
+}{status: ServiceStatus{State: "starting", StartedAt: time.Now()}}
+
+// updateServiceStatus modifies the status and sends it to systemd
+func updateServiceStatus(update func(status *ServiceStatus)) {
+	serviceState.Lock()
+	update(&serviceState.status)
+	status := serviceState.status
+	serviceState.Unlock()
+
+	sdNotify("STATUS=" + status.String())
+}
+
+func currentServiceStatus() ServiceStatus {
+	serviceState.Lock()
+	defer serviceState.Unlock()
+
+	status := serviceState.status
+	status.UptimeSeconds = int64(time.Since(status.StartedAt).Seconds())
+	status.Notifications, status.UndeliveredAlerts = delivery.Stats()
+	if shareTracker != nil {
+		status.FlashbotsShare = shareTracker.AllAverages()
+	}
+	if rewardTracker != nil {
+		status.RewardAnomalies = rewardTracker.Anomalies()
+	}
+	if mevShareTracker != nil {
+		stats := mevShareTracker.Stats()
+		status.MevShare = &stats
+	}
+	if !status.LastBlockTime.IsZero() {
+		status.LastCheckAgeMs = time.Since(status.LastBlockTime).Milliseconds()
+	}
+	return status
+}
+
+// serviceBlockReceived is called for every new block from the node, and notifies the systemd watchdog (blocks are
+// received while the checks wait for the Flashbots API, a stalled node fails over, see watcher.HeadStallTimeout)
+func serviceBlockReceived(blockNumber int64) {
+	updateServiceStatus(func(status *ServiceStatus) {
+		if blockNumber > status.LatestBlock {
+			status.LatestBlock = blockNumber
+		}
+		status.Lag = status.LatestBlock - status.LastBlock
+	})
+	sdNotify("WATCHDOG=1")
+}
+
+// serviceBlockChecked is called for every checked block (in the watch loop)
+func serviceBlockChecked(check *blockcheck.BlockCheck) {
+	updateServiceStatus(func(status *ServiceStatus) {
+		today := time.Now().UTC().Format("2006-01-02")
+		if status.today != today {
+			status.today = today
+			status.SeriousErrorsToday = 0
+		}
+		if check.HasSeriousErrors() {
+			status.SeriousErrorsToday += 1
+		}
+
+		if check.Number > status.LastBlock { // re-checks of older blocks don't change the lag
+			status.LastBlock = check.Number
+		}
+		status.LastBlockTime = time.Now()
+		status.Lag = status.LatestBlock - status.LastBlock
+		if status.Lag < 0 {
+			status.Lag = 0
+		}
+		status.Backlog = blockWatcher.BacklogSize()
+		if blockWatcher.LoadShedder != nil {
+			status.LoadShedding = blockWatcher.LoadShedder.Shedding()
+			status.PendingRechecks = len(blockWatcher.LoadShedder.PendingRechecks())
+		}
+		status.SeriousErrors = errorCountSerious
+		status.LessSeriousErrors = errorCountNonSerious
+		status.LowActivity = numLowActivityBlocks
+	})
+}
+
+// sdNotify sends a state to systemd (eg. "READY=1"), if started by systemd with Type=notify. See sd_notify(3).
+func sdNotify(state string) {
+	socketAddr := os.Getenv("NOTIFY_SOCKET")
+	if socketAddr == "" {
+		return
+	}
+	if strings.HasPrefix(socketAddr, "@") { // abstract socket
+		socketAddr = "\x00" + socketAddr[1:]
+	}
+
+	conn, err := net.Dial("unixgram", socketAddr)
+	if err != nil {
+		log.Warn("sd_notify error", "err", err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte(state)); err != nil {
+		log.Warn("sd_notify error", "err", err)
+	}
+}
+
+// serveAdminSocket answers every connection on the unix socket with the status as JSON
+func serveAdminSocket(path string) (io.Closer, error) {
+	os.Remove(path) // left over from a previous run
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on admin socket: %w", err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return // listener closed
+			}
+
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			err = json.NewEncoder(conn).Encode(currentServiceStatus())
+			if err != nil {
+				log.Warn("admin socket error", "err", err)
+			}
+			conn.Close()
+		}
+	}()
+	return listener, nil
+}
+
+// printStatus queries the status of the running instance over the admin socket.
+// Usage: block-watch -adminsocket <path> status
+func printStatus(path string) error {
+	conn, err := net.DialTimeout("unix", path, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("block-watch not running? %w", err)
+	}
+	defer conn.Close()
+
+	var status ServiceStatus
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	err = json.NewDecoder(conn).Decode(&status)
+	if err != nil {
+		return fmt.Errorf("invalid status response: %w", err)
+	}
+
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
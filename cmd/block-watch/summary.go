@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/logging"
+)
+
+// sendSummariesIfDue sends the daily summary at 3pm ET and the weekly summary on Friday at 10am ET, and resets the counters
+func sendSummariesIfDue(now time.Time) {
+	// Daily summary at 3pm ET
+	dailySummaryTriggerHourUtc := 19 // 3pm ET
+	if now.UTC().Hour() == dailySummaryTriggerHourUtc && time.Since(dailyErrorSummary.TimeStarted).Hours() >= 2 {
+		log.Info("trigger daily summary")
+		sendSummary("Daily miner summary", dailyErrorSummary.String())
+		sendSummary("Miner error leaderboard (24h)", blockWatcher.MinerLeaderboard.Summary(now)+"\n"+blockWatcher.MinerLeaderboard.String(24*time.Hour, now))
+		sendSummary("Suppressed alerts (rate limit)", alertRateLimiter.Digest())
+		sendSummary("Duplicate alerts (not sent to Discord)", notifications.Digest())
+		sendSummary("Daily gas limit pressure", dailyCapacityStats.String())
+		if mevShareTracker != nil {
+			sendSummary("MEV-Share hints (since the start)", mevShareTracker.Stats().String())
+		}
+		log.Info("flashbots api cache", "stats", api.DefaultClient.Cache.Stats())
+		if chaosTransport != nil {
+			log.Info("chaos stats", "stats", chaosTransport.Stats())
+		}
+
+		// reset daily summery
+		dailyErrorSummary.Reset()
+		dailyCapacityStats.Reset()
+	}
+
+	// Weekly summary on Friday at 10am ET
+	weeklySummaryTriggerHourUtc := 14 // 10am ET
+	if now.UTC().Weekday() == time.Friday && now.UTC().Hour() == weeklySummaryTriggerHourUtc && time.Since(weeklyErrorSummary.TimeStarted).Hours() >= 2 {
+		log.Info("trigger weekly summary")
+		sendSummary("Weekly miner summary", weeklyErrorSummary.String())
+		sendSummary("Miner error leaderboard (7d)", blockWatcher.MinerLeaderboard.Summary(now)+"\n"+blockWatcher.MinerLeaderboard.String(7*24*time.Hour, now))
+		if weeklyPayouts != nil {
+			if err := weeklyPayouts.Reconcile(context.Background()); err != nil {
+				log.Error("payout reconciliation error", "err", err)
+			} else {
+				sendSummary("Weekly miner payout reconciliation", weeklyPayouts.String())
+			}
+			weeklyPayouts.Reset()
+		}
+
+		// reset weekly summery
+		weeklyErrorSummary.Reset()
+	}
+}
+
+// sendSessionSummary prints the summary of the watch session on exit, and sends it like the daily summary if send is
+// set. The dashboard is closed first, the summary and the remaining log lines go to stdout.
+func sendSessionSummary(send bool, reason string) {
+	if dashboard != nil {
+		dashboard.Close()
+		dashboard = nil
+		logging.SetConsole(os.Stdout)
+	}
+
+	title := fmt.Sprintf("Session summary (%s)", reason)
+	msg := blockWatcher.Session.Summary(time.Now()).String()
+	if mevShareTracker != nil {
+		msg += "MEV-Share " + mevShareTracker.Stats().String() + "\n"
+	}
+	if send {
+		sendSummary(title, msg)
+	} else {
+		printToTerminal(title + ":\n" + msg)
+	}
+}
+
+// sendSummary prints a summary, sends it to Discord and appends it to the summary file (if enabled)
+func sendSummary(title string, msg string) {
+	if msg == "" {
+		return
+	}
+
+	printToTerminal(title + ":\n" + msg)
+
+	if sendErrorsToDiscord {
+		discordMsg := title + ": ```" + msg + "```"
+		logNotification("discord-summary", discordMsg, SendToDiscord(discordMsg))
+	}
+
+	if summaryFile != "" {
+		f, err := os.OpenFile(summaryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Error("error opening summary file", "err", err)
+			return
+		}
+		defer f.Close()
+
+		_, err = fmt.Fprintf(f, "%s - %s:\n%s\n", time.Now().UTC().Format(time.RFC3339), title, msg)
+		if err != nil {
+			log.Error("error writing summary file", "err", err)
+		}
+	}
+}
+
+// sendReport sends a finished daily or weekly report, and writes it to the report directory (if set)
+func sendReport(report *blockcheck.Report) {
+	sendSummary("Flashbots "+report.Name()+" report", report.String())
+	if reportDir == "" {
+		return
+	}
+
+	if err := os.MkdirAll(reportDir, 0755); err != nil {
+		log.Error("error creating report directory", "err", err)
+		return
+	}
+	if filename, err := report.WriteJSON(reportDir); err != nil {
+		log.Error("error writing report", "file", filename, "err", err)
+	}
+	if filename, err := report.AppendCSV(reportDir); err != nil {
+		log.Error("error writing report", "file", filename, "err", err)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+var dashboard *Dashboard // only with -tui
+
+// Number of rows of the dashboard panels
+const (
+	dashboardBlocks = 12
+	dashboardAlerts = 6
+	dashboardLogs   = 6
+)
+
+// ANSI escape sequences
+const (
+	ansiClear   = "\033[H\033[2J"
+	ansiBold    = "\033[1m"
+	ansiRed     = "\033[31m"
+	ansiYellow  = "\033[33m"
+	ansiReset   = "\033[0m"
+	ansiHideCur = "\033[?25l"
+	ansiShowCur = "\033[?25h"
+)
+
+type dashboardBlock struct {
+	Number   int64
+	Miner    string
+	Tx       int
+	Bundles  int
+	Severity string // empty without errors
+	Skipped  bool   // low activity block
+}
+
+// Dashboard renders a full-screen terminal dashboard with the live block stream, the backlog, rolling error
+// counters, the miner leaderboard, the latest alerts and log lines. It's redrawn on every update, and is also the
+// console sink of the logger (see logging.SetConsole).
+type Dashboard struct {
+	out io.Writer
+
+	lock        sync.Mutex
+	started     time.Time
+	latestBlock int64
+	backlog     int
+	blocks      []dashboardBlock // newest first
+	alerts      []string         // newest first
+	logs        []string         // newest first
+	errors1h    uint64
+	errors24h   uint64
+	leaderboard string
+	lastRender  time.Time
+	redraw      *time.Timer // trailing redraw of the updates within the throttle window
+	closed      bool
+}
+
+func NewDashboard(out io.Writer) *Dashboard {
+	fmt.Fprint(out, ansiHideCur)
+	return &Dashboard{out: out, started: time.Now()}
+}
+
+// Close restores the cursor and leaves the last frame on the screen
+func (d *Dashboard) Close() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.redraw != nil {
+		d.redraw.Stop()
+		d.redraw = nil
+	}
+	d.closed = true
+	fmt.Fprint(d.out, ansiShowCur)
+}
+
+func prependLimited(lines []string, line string, max int) []string {
+	lines = append([]string{line}, lines...)
+	if len(lines) > max {
+		lines = lines[:max]
+	}
+	return lines
+}
+
+// BlockReceived is called for new blocks from the node
+func (d *Dashboard) BlockReceived(blockNumber int64) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if blockNumber > d.latestBlock {
+		d.latestBlock = blockNumber
+	}
+	d.render(false)
+}
+
+// AddCheck adds a checked block to the stream, and updates the counters and the leaderboard (call from the watch loop)
+func (d *Dashboard) AddCheck(check *blockcheck.BlockCheck) {
+	now := time.Now()
+	errors1h, _ := blockWatcher.MinerLeaderboard.ErrorBlocks(time.Hour, now)
+	errors24h, _ := blockWatcher.MinerLeaderboard.ErrorBlocks(24*time.Hour, now)
+	leaderboard := blockWatcher.MinerLeaderboard.String(24*time.Hour, now)
+	backlog := blockWatcher.BacklogSize()
+
+	block := dashboardBlock{Number: check.Number, Miner: check.Miner, Tx: len(check.EthBlock.Transactions()), Bundles: len(check.Bundles), Skipped: check.IsLowActivity}
+	if check.MinerName != "" {
+		block.Miner = check.MinerName
+	}
+	if check.HasSeriousErrors() {
+		block.Severity = blockcheck.SeveritySerious
+	} else if check.HasLessSeriousErrors() {
+		block.Severity = blockcheck.SeverityLessSerious
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.blocks = append([]dashboardBlock{block}, d.blocks...)
+	if len(d.blocks) > dashboardBlocks {
+		d.blocks = d.blocks[:dashboardBlocks]
+	}
+	d.errors1h = errors1h
+	d.errors24h = errors24h
+	d.leaderboard = leaderboard
+	d.backlog = backlog
+	d.render(true)
+}
+
+// AddAlert shows the first line of an alert in the alerts panel
+func (d *Dashboard) AddAlert(msg string) {
+	line := strings.SplitN(strings.TrimSpace(msg), "\n", 2)[0]
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.alerts = prependLimited(d.alerts, time.Now().Format("15:04:05 ")+line, dashboardAlerts)
+	d.render(true)
+}
+
+// Write receives the log lines (io.Writer for logging.SetConsole)
+func (d *Dashboard) Write(p []byte) (n int, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		d.logs = prependLimited(d.logs, line, dashboardLogs)
+	}
+	d.render(false)
+	return len(p), nil
+}
+
+// render redraws the screen, at most every 200ms unless forced. Updates within the 200ms are drawn at the end of
+// them, so the last update isn't missing until the next one. Call with the lock held.
+func (d *Dashboard) render(force bool) {
+	if d.closed {
+		return
+	}
+	if wait := 200*time.Millisecond - time.Since(d.lastRender); !force && wait > 0 {
+		if d.redraw == nil {
+			d.redraw = time.AfterFunc(wait, func() {
+				d.lock.Lock()
+				defer d.lock.Unlock()
+				d.redraw = nil
+				d.render(true)
+			})
+		}
+		return
+	}
+	if d.redraw != nil {
+		d.redraw.Stop()
+		d.redraw = nil
+	}
+	d.lastRender = time.Now()
+
+	var b strings.Builder
+	b.WriteString(ansiClear)
+	fmt.Fprintf(&b, "%sblock-watch%s \t uptime %s \t latest block %d \t backlog %d \t error blocks 1h: %d, 24h: %d\n\n", ansiBold, ansiReset, time.Since(d.started).Round(time.Second), d.latestBlock, d.backlog, d.errors1h, d.errors24h)
+
+	fmt.Fprintf(&b, "%sBlocks%s\n", ansiBold, ansiReset)
+	for _, block := range d.blocks {
+		status := "ok"
+		color := ""
+		switch {
+		case block.Skipped:
+			status = "low activity"
+		case block.Severity == blockcheck.SeveritySerious:
+			status, color = "SERIOUS", ansiRed
+		case block.Severity == blockcheck.SeverityLessSerious:
+			status, color = "less serious", ansiYellow
+		}
+		fmt.Fprintf(&b, "%s%d  %-42s tx=%-4d bundles=%-3d %s%s\n", color, block.Number, block.Miner, block.Tx, block.Bundles, status, ansiReset)
+	}
+
+	fmt.Fprintf(&b, "\n%sMiner leaderboard (24h)%s\n", ansiBold, ansiReset)
+	leaderboard := strings.Split(strings.TrimRight(d.leaderboard, "\n"), "\n")
+	if len(leaderboard) > 5 {
+		leaderboard = leaderboard[:5]
+	}
+	b.WriteString(strings.Join(leaderboard, "\n") + "\n")
+
+	fmt.Fprintf(&b, "\n%sAlerts%s\n", ansiBold, ansiReset)
+	for _, alert := range d.alerts {
+		b.WriteString(alert + "\n")
+	}
+
+	fmt.Fprintf(&b, "\n%sLog%s\n", ansiBold, ansiReset)
+	for _, line := range d.logs {
+		b.WriteString(line + "\n")
+	}
+
+	io.WriteString(d.out, b.String())
+}
+
+// printToTerminal prints the output of the terminal notifier and the summaries, or adds it to the dashboard
+func printToTerminal(msg string) {
+	if dashboard != nil {
+		dashboard.AddAlert(msg)
+		return
+	}
+	fmt.Fprintln(os.Stdout, msg)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboardTrailingRedraw(t *testing.T) {
+	var out strings.Builder
+	d := NewDashboard(&out)
+	d.Write([]byte("first\n"))
+	d.Write([]byte("second\n")) // within the throttle window
+
+	d.lock.Lock()
+	frames := strings.Count(out.String(), ansiClear)
+	d.lock.Unlock()
+	if frames != 1 {
+		t.Fatal("expected one frame within the throttle window, got", frames)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	d.lock.Lock()
+	frames = strings.Count(out.String(), ansiClear)
+	lastFrame := out.String()[strings.LastIndex(out.String(), ansiClear):]
+	d.lock.Unlock()
+	if frames != 2 || !strings.Contains(lastFrame, "second") {
+		t.Fatalf("expected the trailing redraw with the second line, got %d frames", frames)
+	}
+
+	// No redraw after closing
+	d.Write([]byte("third\n"))
+	d.Close()
+	time.Sleep(300 * time.Millisecond)
+	if strings.Contains(out.String(), "third") {
+		t.Error("unexpected redraw after closing")
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/uncles"
+)
+
+var uncleDetector *uncles.Detector // set with -uncles
+
+// checkUncles fetches the uncles referenced by a new block, and reports possible uncle-bandit attacks
+func checkUncles(block *types.Block) {
+	reports, err := uncleDetector.AddBlock(block)
+	if err != nil {
+		log.Error("uncle detection error", "block", block.Number(), "err", err)
+	}
+
+	for _, report := range reports {
+		msg := report.String()
+		if config.HasNotifier(blockcheck.SeveritySerious, "terminal") {
+			printToTerminal(msg + "\n")
+		}
+
+		if sendErrorsToDiscord && config.HasNotifier(blockcheck.SeveritySerious, "discord") {
+			notifications.Add(block.Number().Int64(), "", msg)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/schema"
+	"github.com/metachris/flashbots/watcher"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+// verifyBlocks checks the blocks again and compares the input and output hashes with the stored check results
+// (block-watch -jsonl <dir> verify <block>...). Returns an error if a result couldn't be reproduced.
+func verifyBlocks(client *ethclient.Client, sink *watcher.JSONLSink, blockArgs []string) error {
+	failed := 0
+	for _, blockArg := range blockArgs {
+		blockNumber, err := strconv.ParseInt(blockArg, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid block number '%s'", blockArg)
+		}
+
+		ok, err := verifyBlock(client, sink, blockNumber)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			failed += 1
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d blocks not reproduced", failed, len(blockArgs))
+	}
+	return nil
+}
+
+func verifyBlock(client *ethclient.Client, sink *watcher.JSONLSink, blockNumber int64) (ok bool, err error) {
+	block, err := blockswithtx.GetBlockWithTxReceipts(client, blockNumber)
+	if err != nil {
+		return false, err
+	}
+	check, err := blockcheck.CheckBlock(block, false)
+	if err != nil {
+		return false, fmt.Errorf("check error at block %d: %w", blockNumber, err)
+	}
+
+	results, _, err := sink.Find(blockNumber)
+	if err != nil {
+		return false, err
+	}
+
+	// The latest result of the canonical block (there can be several after reorgs and re-checks)
+	var stored *schema.CheckResult
+	for i := range results {
+		if results[i].BlockHash == check.EthBlock.Hash().Hex() && results[i].InputHash != "" {
+			stored = &results[i]
+		}
+	}
+	if stored == nil {
+		fmt.Printf("block %d: no stored result with hashes for block hash %s\n", blockNumber, check.EthBlock.Hash())
+		return false, nil
+	}
+
+	inputHash, outputHash := check.InputHash(), check.OutputHash()
+	inputsOk := inputHash == stored.InputHash
+	outputsOk := outputHash == stored.OutputHash
+	switch {
+	case inputsOk && outputsOk:
+		fmt.Printf("block %d: reproduced (%s)\n", blockNumber, outputHash)
+	case !inputsOk:
+		fmt.Printf("block %d: inputs differ (Flashbots API data, enabled checks or thresholds changed): %s, stored %s\n", blockNumber, inputHash, stored.InputHash)
+		if !outputsOk {
+			fmt.Printf("block %d: outputs differ: %s, stored %s\n", blockNumber, outputHash, stored.OutputHash)
+		}
+	default:
+		fmt.Printf("block %d: NOT REPRODUCED, same inputs but outputs differ: %s, stored %s\n", blockNumber, outputHash, stored.OutputHash)
+		for _, msg := range stored.Errors {
+			fmt.Println("  stored: " + msg)
+		}
+		for _, msg := range check.ErrorMessages() {
+			fmt.Println("  now:    " + msg)
+		}
+	}
+	return inputsOk && outputsOk, nil
+}
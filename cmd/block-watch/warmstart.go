@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+// warmStart checks the most recent blocks of the Flashbots API before watching, so the summaries start with a
+// baseline instead of empty. No alerts are sent for these blocks.
+func warmStart(client *ethclient.Client, numBlocks int64) error {
+	flashbotsResponse, err := api.GetBlocks(&api.GetBlocksOptions{Limit: 1})
+	if err != nil {
+		return err
+	}
+
+	endBlock := flashbotsResponse.LatestBlockNumber
+	startBlock := endBlock - numBlocks + 1
+	log.Info("warm start: checking blocks", "start", startBlock, "end", endBlock)
+
+	err = blockcheck.CacheFlashbotsBlocks(startBlock, endBlock)
+	if err != nil {
+		return err
+	}
+
+	blockChan := make(chan *blockswithtx.BlockWithTxReceipts, 100)
+	done := make(chan bool)
+
+	numErrorBlocks := 0
+	go func() {
+		for block := range blockChan {
+			check, err := blockcheck.CheckBlock(block, true)
+			if err != nil {
+				log.Error("warm start: check error", "block", block.Block.Number(), "err", err)
+				continue
+			}
+
+			dailyCapacityStats.AddCheck(check)
+			blockWatcher.MinerLeaderboard.AddCheck(check)
+			if check.HasSeriousErrors() || check.HasLessSeriousErrors() {
+				numErrorBlocks += 1
+				check.AddedToSummary = true
+				weeklyErrorSummary.AddCheckErrors(check)
+				dailyErrorSummary.AddCheckErrors(check)
+			}
+		}
+		done <- true
+	}()
+
+	blockswithtx.GetBlocksWithTxReceipts(client, blockChan, startBlock, endBlock, 10)
+	close(blockChan)
+	<-done
+
+	log.Info("warm start: done", "error_blocks", numErrorBlocks)
+	if !silent {
+		fmt.Println(dailyErrorSummary.String())
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/metachris/flashbots/watcher"
+)
+
+var ErrCrashLoop = errors.New("too many restarts, crash loop")
+
+// Watchdog runs a function, recovers its panics and restarts it. State kept outside of the function (backlog,
+// summaries, stats) is preserved across restarts. Too many restarts in a short time are treated as crash loop.
+type Watchdog struct {
+	Restarts int // total number of restarts
+
+	RestartDelay        time.Duration
+	CrashLoopWindow     time.Duration
+	MaxRestartsInWindow int
+
+	// Called after every recovered panic, with the total number of restarts and the stack trace
+	OnPanic func(restarts int, recovered interface{}, stack []byte)
+
+	recentRestarts []time.Time
+}
+
+func NewWatchdog() *Watchdog {
+	return &Watchdog{
+		RestartDelay:        5 * time.Second,
+		CrashLoopWindow:     10 * time.Minute,
+		MaxRestartsInWindow: 5,
+	}
+}
+
+// Run calls fn until it returns without panic (then its error is returned), or until a crash loop is detected
+func (w *Watchdog) Run(fn func() error) error {
+	for {
+		err, panicked := w.runOnce(fn)
+		if !panicked {
+			return err
+		}
+
+		if w.isCrashLoop(time.Now()) {
+			return ErrCrashLoop
+		}
+		time.Sleep(w.RestartDelay)
+	}
+}
+
+func (w *Watchdog) runOnce(fn func() error) (err error, panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			w.Restarts += 1
+			w.recentRestarts = append(w.recentRestarts, time.Now())
+			if w.OnPanic != nil {
+				w.OnPanic(w.Restarts, r, debug.Stack())
+			}
+		}
+	}()
+
+	return fn(), false
+}
+
+func (w *Watchdog) isCrashLoop(now time.Time) bool {
+	recent := w.recentRestarts[:0]
+	for _, t := range w.recentRestarts {
+		if now.Sub(t) <= w.CrashLoopWindow {
+			recent = append(recent, t)
+		}
+	}
+	w.recentRestarts = recent
+	return len(w.recentRestarts) > w.MaxRestartsInWindow
+}
+
+// reportPanic logs the panic and sends it with the stack trace to the ops Discord channel. The block which was
+// being processed is removed from the backlog, so it can't crash the restarted pipeline again.
+func reportPanic(restarts int, recovered interface{}, stack []byte) {
+	if stagePanic, ok := recovered.(*watcher.StagePanic); ok { // the stack of the pipeline stage which panicked
+		stack = stagePanic.Stack
+	}
+
+	msg := fmt.Sprintf("block-watch recovered from panic (restart #%d): %v", restarts, recovered)
+	if height := blockWatcher.SkipProcessingBlock(); height > 0 {
+		msg += fmt.Sprintf("\nwhile processing block %d (removed from backlog)", height)
+	}
+
+	log.Error(msg, "stack", string(stack))
+	if sendErrorsToDiscord {
+		SendToDiscordOps(msg + "\n```" + string(stack) + "```")
+	}
+}
@@ -0,0 +1,269 @@
+// flashbots-backfill walks the mev-blocks API history (newest first), checks every Flashbots block against its
+// on-chain data, and appends the results to a JSON-lines file (one schema.CheckResult per line) and/or writes them as
+// Parquet files (see export.Exporter). The progress is saved in a checkpoint file after every page, a restarted
+// backfill continues where it stopped.
+//
+//	go run cmd/flashbots-backfill/main.go -out checks.jsonl                                  # entire history
+//	go run cmd/flashbots-backfill/main.go -out checks.jsonl -start 13000000 -end 13100000    # a range
+//	go run cmd/flashbots-backfill/main.go -parquet data/ -pagesize 1000                      # Parquet files by date
+//	go run cmd/flashbots-backfill/main.go -out checks.jsonl -eth gethdb:/data/geth/chaindata # from the geth database
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/blocksource"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/flashbots/export"
+	"github.com/metachris/flashbots/logging"
+	"github.com/metachris/flashbots/watcher"
+)
+
+var log = logging.Module("backfill")
+
+// Checkpoint of a backfill. The API is walked newest first: all Flashbots blocks from Cursor up to End are checked.
+type Checkpoint struct {
+	Start       int64     `json:"start"` // 0 = entire history
+	End         int64     `json:"end"`
+	Cursor      int64     `json:"cursor"`
+	Blocks      int64     `json:"blocks"` // checked blocks
+	ErrorBlocks int64     `json:"error_blocks"`
+	Time        time.Time `json:"time"`
+}
+
+func loadCheckpoint(filename string) (cp Checkpoint, found bool, err error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, false, nil
+	} else if err != nil {
+		return cp, false, err
+	}
+
+	err = json.Unmarshal(data, &cp)
+	if err != nil {
+		return cp, false, fmt.Errorf("checkpoint %s: %w", filename, err)
+	}
+	return cp, true, nil
+}
+
+func saveCheckpoint(filename string, cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so the checkpoint is never half-written
+	tmpFile := filename + ".tmp"
+	err = os.WriteFile(tmpFile, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, filename)
+}
+
+func main() {
+	ethUri := flag.String("eth", os.Getenv("ETH_NODE"), "Ethereum node URI, or another block source: gethdb:<chaindata dir> (database of a stopped geth node, not Erigon: use the URI of its rpcdaemon), archive:<file or dir> (exported blocks, see block-watch dump)")
+	outPtr := flag.String("out", "", "append the check results to this JSON-lines file")
+	parquetDirPtr := flag.String("parquet", "", "write the checks and bundles as Parquet files to this directory, partitioned by date (one file per page and date)")
+	checkpointPtr := flag.String("checkpoint", "", "checkpoint file (default: <out>.checkpoint, or backfill.checkpoint in the parquet directory)")
+	startPtr := flag.Int64("start", 0, "first block (0 = entire history)")
+	endPtr := flag.Int64("end", 0, "last block (0 = latest block of the API)")
+	errorsOnlyPtr := flag.Bool("errorsonly", false, "only save the checks with errors")
+	pageSizePtr := flag.Int64("pagesize", 100, "blocks per API page (the checkpoint is saved after every page)")
+	workersPtr := flag.Int("workers", 10, "max. parallel requests to the node (blocks and receipt batches)")
+	logLevelPtr := flag.String("loglevel", "info", "log level: debug, info, warn or error")
+	flag.Parse()
+
+	if err := logging.Configure(*logLevelPtr, logging.FormatText, ""); err != nil {
+		log.Fatal(err.Error())
+	}
+	if *ethUri == "" {
+		log.Fatal("Missing eth node uri")
+	}
+	if *outPtr == "" && *parquetDirPtr == "" {
+		log.Fatal("Missing output file (-out) or directory (-parquet)")
+	}
+	checkpointFile := *checkpointPtr
+	if checkpointFile == "" && *outPtr != "" {
+		checkpointFile = *outPtr + ".checkpoint"
+	} else if checkpointFile == "" {
+		if err := os.MkdirAll(*parquetDirPtr, 0755); err != nil {
+			log.Fatal(err.Error())
+		}
+		checkpointFile = filepath.Join(*parquetDirPtr, "backfill.checkpoint")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { // finish the current page on Ctrl+C
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		<-sigs
+		log.Info("stopping after the current page")
+		cancel()
+	}()
+
+	source, err := blocksource.Open(ctx, *ethUri)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer blocksource.Close(source)
+	if node, ok := source.(*blocksource.RPC); ok {
+		node.MaxConcurrency = *workersPtr
+	}
+	chainID, err := blocksource.ChainID(ctx, source)
+	if err == nil {
+		err = common.SetExplorerForChainID(chainID.Int64())
+	}
+	if err != nil {
+		log.Warn("unknown chain, using the default block explorer for links", "err", err)
+	}
+
+	cp, found, err := loadCheckpoint(checkpointFile)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if found {
+		if cp.Start != *startPtr || (*endPtr != 0 && cp.End != *endPtr) {
+			log.Fatal(fmt.Sprintf("checkpoint %s is for blocks %d ... %d, delete it to start over", checkpointFile, cp.Start, cp.End))
+		}
+		log.Info("resuming", "cursor", cp.Cursor, "checked", cp.Blocks)
+	} else {
+		cp = Checkpoint{Start: *startPtr, End: *endPtr}
+		if cp.End == 0 {
+			response, err := api.GetBlocksWithContext(ctx, &api.GetBlocksOptions{Limit: 1})
+			if err != nil {
+				log.Fatal(err.Error())
+			}
+			cp.End = response.LatestBlockNumber
+		}
+		cp.Cursor = cp.End + 1
+	}
+
+	var sinks []watcher.CheckSink
+	if *outPtr != "" {
+		sinks = append(sinks, &watcher.FileStorage{ChecksFile: *outPtr, AllChecks: !*errorsOnlyPtr})
+	}
+	if *parquetDirPtr != "" {
+		sinks = append(sinks, export.NewExporter(*parquetDirPtr))
+	}
+	err = backfill(ctx, source, *workersPtr, sinks, &cp, checkpointFile, *pageSizePtr)
+	if err != nil && ctx.Err() == nil {
+		log.Fatal(err.Error())
+	}
+	log.Info("backfill stopped", "cursor", cp.Cursor, "checked", cp.Blocks, "error_blocks", cp.ErrorBlocks, "done", ctx.Err() == nil)
+}
+
+// backfill checks all API blocks below the cursor, page by page, and saves the checkpoint after every page
+func backfill(ctx context.Context, source blocksource.Source, workers int, sinks []watcher.CheckSink, cp *Checkpoint, checkpointFile string, pageSize int64) error {
+	timeStart := time.Now()
+	startCursor := cp.Cursor
+
+	it := api.DefaultClient.IterateBlocks(&api.GetBlocksOptions{Before: cp.Cursor}, &api.PageOptions{PageSize: pageSize, StopBlock: cp.Start})
+	for ctx.Err() == nil && it.Next(ctx) {
+		page := it.Page()
+		if len(page) == 0 {
+			continue
+		}
+
+		checks, err := checkBlocks(source, workers, page)
+		if err != nil {
+			return err
+		}
+
+		// A crash between saving the checks and the checkpoint repeats the page on restart (duplicate lines)
+		if err = saveChecks(sinks, checks); err != nil {
+			return fmt.Errorf("error saving checks: %w", err)
+		}
+		for _, check := range checks {
+			cp.Blocks += 1
+			if check.HasErrors() {
+				cp.ErrorBlocks += 1
+			}
+		}
+		cp.Cursor = page[len(page)-1].BlockNumber
+		cp.Time = time.Now()
+		if err = saveCheckpoint(checkpointFile, *cp); err != nil {
+			return fmt.Errorf("error saving checkpoint: %w", err)
+		}
+
+		logProgress(cp, startCursor, timeStart)
+	}
+	return it.Err()
+}
+
+// saveChecks saves the checks of a page to all sinks. The Parquet rows are written with every page, so they are
+// covered by the checkpoint.
+func saveChecks(sinks []watcher.CheckSink, checks []*blockcheck.BlockCheck) error {
+	for _, sink := range sinks {
+		if storage, ok := sink.(*watcher.FileStorage); ok { // one write per page
+			if err := storage.SaveChecks(checks); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, check := range checks {
+			if err := sink.SaveCheck(check); err != nil {
+				return err
+			}
+		}
+		if exporter, ok := sink.(*export.Exporter); ok {
+			if err := exporter.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// logProgress logs the progress, and the estimated remaining time (by block height)
+func logProgress(cp *Checkpoint, startCursor int64, timeStart time.Time) {
+	elapsed := time.Since(timeStart)
+	heightsDone := startCursor - cp.Cursor
+	heightsTotal := cp.End + 1 - cp.Start
+	if heightsDone <= 0 || heightsTotal <= 0 {
+		return
+	}
+
+	remaining := time.Duration(float64(elapsed) / float64(heightsDone) * float64(cp.Cursor-cp.Start)).Round(time.Second)
+	percent := float64(cp.End+1-cp.Cursor) / float64(heightsTotal) * 100
+	log.Info("progress", "cursor", cp.Cursor, "percent", fmt.Sprintf("%.2f", percent), "checked", cp.Blocks, "error_blocks", cp.ErrorBlocks, "remaining", remaining)
+}
+
+// checkBlocks fetches the blocks with receipts from the source (in parallel), and checks them in block order with the
+// API data of the page. The page is always finished, also when stopping.
+func checkBlocks(source blocksource.Source, workers int, page []api.FlashbotsBlock) (checks []*blockcheck.BlockCheck, err error) {
+	heights := make([]int64, len(page))
+	for i, apiBlock := range page {
+		heights[i] = apiBlock.BlockNumber
+	}
+	blocks, err := blocksource.GetBlocks(context.Background(), source, heights, workers)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, apiBlock := range page {
+
+		blockcheck.FlashbotsBlockCache[apiBlock.BlockNumber] = apiBlock
+		check, err := blockcheck.CheckBlock(blocks[i], true)
+		delete(blockcheck.FlashbotsBlockCache, apiBlock.BlockNumber)
+		if err != nil {
+			return checks, fmt.Errorf("error checking block %d: %w", apiBlock.BlockNumber, err)
+		}
+		log.Debug("block checked", "block", check.Number, "errors", len(check.Errors))
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
@@ -0,0 +1,191 @@
+// flashbots-diff compares the bundles of two blocks, eg. a canonical block and its uncle, or the blocks of a height
+// before and after a reorg, and prints which bundles moved, vanished, appeared, or changed their price or transactions.
+// The blocks are given by number or hash, and are read from the archive of block-watch (-jsonl with -jsonlinputs) if
+// they are in it, else from the node and the mev-blocks API.
+//
+//	go run cmd/flashbots-diff/main.go -eth http://localhost:8545 <block a> <block b>
+//	go run cmd/flashbots-diff/main.go -jsonl data/ 0x<hash of the replaced block> 0x<hash of the new block>
+//
+// The mev-blocks API only has the canonical blocks. The bundles of other blocks (uncles, replaced blocks) are the
+// Flashbots transactions of their height which are in the block, with their rewards in the canonical block.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/flashbots/labels"
+	"github.com/metachris/flashbots/logging"
+	"github.com/metachris/flashbots/watcher"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+var log = logging.Module("diff")
+
+var client *ethclient.Client // only with -eth
+var sink *watcher.JSONLSink  // only with -jsonl
+
+func main() {
+	ethUriPtr := flag.String("eth", os.Getenv("ETH_NODE"), "Ethereum node URI")
+	jsonlDirPtr := flag.String("jsonl", "", "directory with the inputs.jsonl file of block-watch, read before asking the node")
+	labelsPtr := flag.String("labels", "", "JSON or CSV file with additional miner, builder and searcher labels")
+	verbosePtr := flag.Bool("v", false, "also print the unchanged bundles")
+	logLevelPtr := flag.String("loglevel", "warn", "log level: debug, info, warn or error")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <block a> <block b> (number or hash)\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := logging.Configure(*logLevelPtr, logging.FormatText, ""); err != nil {
+		log.Fatal(err.Error())
+	}
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *ethUriPtr == "" && *jsonlDirPtr == "" {
+		log.Fatal("Missing eth node uri (-eth) or archive (-jsonl)")
+	}
+	if *labelsPtr != "" {
+		if err := labels.Default.LoadFile(*labelsPtr); err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	var err error
+	if *jsonlDirPtr != "" {
+		sink, err = watcher.NewJSONLSink(*jsonlDirPtr, 0, 0)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		defer sink.Close()
+	}
+	if *ethUriPtr != "" {
+		client, err = ethclient.Dial(*ethUriPtr)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		if chainID, err := client.ChainID(context.Background()); err == nil {
+			common.SetExplorerForChainID(chainID.Int64())
+		}
+	}
+
+	a, err := loadBlock(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	b, err := loadBlock(flag.Arg(1))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	diff := blockcheck.DiffBundles(a, b)
+	fmt.Print(diff.Sprint(*verbosePtr))
+	if diff.HasChanges() {
+		os.Exit(1)
+	}
+}
+
+// loadBlock returns the block with its bundles, by number or hash (0x...), from the archive or the node
+func loadBlock(arg string) (*blockcheck.BlockCheck, error) {
+	var number int64
+	var hash string
+	if strings.HasPrefix(arg, "0x") {
+		hash = strings.ToLower(arg)
+	} else if n, err := strconv.ParseInt(arg, 10, 64); err == nil && n > 0 {
+		number = n
+	} else {
+		return nil, fmt.Errorf("invalid block '%s' (number or hash)", arg)
+	}
+
+	if sink != nil {
+		check, err := loadArchivedBlock(number, hash)
+		if err != nil || check != nil {
+			return check, err
+		}
+	}
+	if client == nil {
+		return nil, fmt.Errorf("block %s is not in the archive", arg)
+	}
+
+	var block *blockswithtx.BlockWithTxReceipts
+	if hash != "" {
+		ethBlock, err := client.BlockByHash(context.Background(), ethcommon.HexToHash(hash))
+		if err != nil {
+			return nil, fmt.Errorf("block %s: %w", hash, err)
+		}
+		block = &blockswithtx.BlockWithTxReceipts{Block: ethBlock, TxReceipts: make(map[ethcommon.Hash]*types.Receipt)} // receipts aren't needed for the bundles
+	} else {
+		var err error
+		if block, err = blockswithtx.GetBlockWithTxReceipts(client, number); err != nil {
+			return nil, fmt.Errorf("block %d: %w", number, err)
+		}
+	}
+
+	check, err := blockcheck.NewBlockCheck(block, false)
+	if err != nil {
+		return nil, fmt.Errorf("block %s: %w", arg, err)
+	}
+	projectBundles(check)
+	return check, nil
+}
+
+// loadArchivedBlock returns the last archived block with the number or hash, nil if it isn't archived
+func loadArchivedBlock(number int64, hash string) (check *blockcheck.BlockCheck, err error) {
+	err = sink.ReadInputs(number, number, func(input *blockcheck.CheckInput) error {
+		if hash != "" {
+			block, err := input.BlockWithTxReceipts()
+			if err != nil || strings.ToLower(block.Block.Hash().Hex()) != hash {
+				return err
+			}
+		}
+		check, err = blockcheck.Replay(input)
+		return err
+	})
+	if check != nil {
+		projectBundles(check)
+	}
+	return check, err
+}
+
+// projectBundles keeps the Flashbots transactions of the block's height which are in the block, at their index in the
+// block, and creates the bundles again. Only changes blocks which aren't the canonical block of the API.
+func projectBundles(check *blockcheck.BlockCheck) {
+	if check.FlashbotsApiBlock == nil {
+		return
+	}
+	txIndexes := make(map[string]int64)
+	for i, tx := range check.EthBlock.Transactions() {
+		txIndexes[tx.Hash().Hex()] = int64(i)
+	}
+
+	changed := false
+	txs := make([]api.FlashbotsTransaction, 0, len(check.FlashbotsApiBlock.Transactions))
+	for _, tx := range check.FlashbotsApiBlock.Transactions {
+		index, found := txIndexes[strings.ToLower(tx.Hash)]
+		if !found || index != tx.TxIndex {
+			changed = true
+		}
+		if found {
+			tx.TxIndex = index
+			txs = append(txs, tx)
+		}
+	}
+	if !changed {
+		return
+	}
+	check.FlashbotsApiBlock.Transactions = txs
+	check.FlashbotsTransactions = txs
+	check.CreateBundles()
+}
@@ -0,0 +1,195 @@
+// flashbots-replay checks the blocks archived by block-watch (-jsonl with -jsonlinputs) again with the current checks
+// and thresholds, without requests to the node or the mev-blocks API, and prints which errors are newly detected and
+// which aren't detected anymore compared to the saved check results. For trying out changed checks or thresholds on
+// past blocks before deploying them.
+//
+//	go run cmd/flashbots-replay/main.go -jsonl data/ -config new-thresholds.json
+//	go run cmd/flashbots-replay/main.go -jsonl data/ -from 13100000 -to 13100100 -out replayed.jsonl
+//	go run cmd/flashbots-replay/main.go -jsonl data/ -from 13100622 -to 13100622 -corpus blockcheck/testdata/corpus -description "..."
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/labels"
+	"github.com/metachris/flashbots/logging"
+	"github.com/metachris/flashbots/schema"
+	"github.com/metachris/flashbots/watcher"
+)
+
+var log = logging.Module("replay")
+
+// blockDiff are the errors of a replayed block which differ from the saved check result
+type blockDiff struct {
+	Number    int64
+	MinerName string
+	New       []string // only detected by the replay
+	Gone      []string // only in the saved check result
+}
+
+func main() {
+	jsonlDirPtr := flag.String("jsonl", "", "directory with the inputs.jsonl and checks.jsonl files of block-watch")
+	coldPtr := flag.String("cold", "", "cold storage of the old files (-jsonlcold of block-watch): a directory or an http(s) URL (with the COLD_STORAGE_AUTHORIZATION header, if set)")
+	configPtr := flag.String("config", "", "JSON config file (thresholds, enabled checks), as for block-watch")
+	labelsPtr := flag.String("labels", "", "JSON or CSV file with additional miner, builder and searcher labels")
+	splittersPtr := flag.String("splitters", "", "JSON file with the payment splitter contracts of pools")
+	fromPtr := flag.Int64("from", 0, "first block (0 = all archived blocks)")
+	toPtr := flag.Int64("to", 0, "last block (0 = all archived blocks)")
+	outPtr := flag.String("out", "", "append the replayed check results to this JSON-lines file")
+	verbosePtr := flag.Bool("v", false, "print the differing errors of every block (default: only the summary)")
+	corpusDirPtr := flag.String("corpus", "", "save the replayed blocks as cases of the regression corpus in this directory (eg. blockcheck/testdata/corpus), with the replayed outcome as expected outcome")
+	descriptionPtr := flag.String("description", "", "with -corpus, what makes the blocks interesting")
+	logLevelPtr := flag.String("loglevel", "warn", "log level: debug, info, warn or error")
+	flag.Parse()
+
+	if err := logging.Configure(*logLevelPtr, logging.FormatText, ""); err != nil {
+		log.Fatal(err.Error())
+	}
+	if *jsonlDirPtr == "" {
+		log.Fatal("Missing directory (-jsonl)")
+	}
+	if *configPtr != "" {
+		config, err := blockcheck.LoadConfig(*configPtr)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		config.Apply()
+	}
+	if *labelsPtr != "" {
+		if err := labels.Default.LoadFile(*labelsPtr); err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+	if *splittersPtr != "" {
+		if err := blockcheck.PaymentSplitters.LoadFile(*splittersPtr); err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	sink, err := watcher.NewJSONLSink(*jsonlDirPtr, 0, 0)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	defer sink.Close()
+	if *coldPtr != "" {
+		store := watcher.OpenObjectStore(*coldPtr)
+		if httpStore, ok := store.(*watcher.HTTPStore); ok && os.Getenv("COLD_STORAGE_AUTHORIZATION") != "" {
+			httpStore.Header.Set("Authorization", os.Getenv("COLD_STORAGE_AUTHORIZATION"))
+		}
+		sink.SetColdStorage(watcher.NewTiering(store, 0)) // only for reading
+	}
+
+	// The latest saved result of every block, by block hash (a height can have several blocks with reorgs)
+	saved := make(map[string]schema.CheckResult)
+	err = sink.ReadChecks(*fromPtr, *toPtr, func(check schema.CheckResult) error {
+		saved[check.BlockHash] = check
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	var out *watcher.RotatingFile
+	if *outPtr != "" {
+		out = watcher.NewRotatingFile(*outPtr, 0, 0)
+		defer out.Close()
+	}
+
+	var blocks, unsaved int
+	var diffs []blockDiff
+	newByType := make(map[string]int64) // difference of the error counts
+	err = sink.ReadInputs(*fromPtr, *toPtr, func(input *blockcheck.CheckInput) error {
+		check, err := blockcheck.Replay(input)
+		if err != nil {
+			return fmt.Errorf("error replaying block %d: %w", input.BlockNumber, err)
+		}
+		blocks += 1
+
+		if *corpusDirPtr != "" {
+			corpusCase := &blockcheck.CorpusCase{Name: fmt.Sprintf("block-%d", input.BlockNumber), Description: *descriptionPtr, Input: input, Expected: blockcheck.NewCorpusOutcome(check)}
+			if err = corpusCase.Save(*corpusDirPtr); err != nil {
+				return err
+			}
+		}
+
+		result := schema.NewCheckResult(check)
+		if out != nil {
+			line, err := json.Marshal(result)
+			if err != nil {
+				return err
+			}
+			if err = out.WriteLine(line); err != nil {
+				return err
+			}
+		}
+
+		previous, found := saved[result.BlockHash]
+		if !found {
+			unsaved += 1
+		}
+		for errorType, count := range result.ErrorCounts {
+			newByType[errorType] += int64(count)
+		}
+		for errorType, count := range previous.ErrorCounts {
+			newByType[errorType] -= int64(count)
+		}
+
+		diff := blockDiff{Number: result.BlockNumber, MinerName: result.MinerName, New: difference(result.Errors, previous.Errors), Gone: difference(previous.Errors, result.Errors)}
+		if len(diff.New) > 0 || len(diff.Gone) > 0 {
+			diffs = append(diffs, diff)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	printDiffs(diffs, *verbosePtr)
+	fmt.Printf("\nReplayed %d blocks (%d without saved check result), %d with differences\n", blocks, unsaved, len(diffs))
+	types := make([]string, 0, len(newByType))
+	for errorType, diff := range newByType {
+		if diff != 0 {
+			types = append(types, errorType)
+		}
+	}
+	sort.Strings(types)
+	for _, errorType := range types {
+		fmt.Printf("- %-40s %+d\n", errorType, newByType[errorType])
+	}
+}
+
+// difference returns the errors of a which aren't in b (each error of b matches once)
+func difference(a []string, b []string) (ret []string) {
+	counts := make(map[string]int)
+	for _, err := range b {
+		counts[err] += 1
+	}
+	for _, err := range a {
+		if counts[err] > 0 {
+			counts[err] -= 1
+			continue
+		}
+		ret = append(ret, err)
+	}
+	return ret
+}
+
+func printDiffs(diffs []blockDiff, verbose bool) {
+	for _, diff := range diffs {
+		fmt.Printf("Block %d %s: %d new, %d gone\n", diff.Number, diff.MinerName, len(diff.New), len(diff.Gone))
+		if !verbose {
+			continue
+		}
+		for _, err := range diff.New {
+			fmt.Printf("  + %s\n", err)
+		}
+		for _, err := range diff.Gone {
+			fmt.Printf("  - %s\n", err)
+		}
+	}
+}
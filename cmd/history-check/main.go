@@ -1,20 +1,28 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/metachris/flashbots/analytics"
 	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/flashbots/mevinspect"
 	"github.com/metachris/go-ethutils/blockswithtx"
 	"github.com/metachris/go-ethutils/utils"
 )
 
 var errorSummary blockcheck.ErrorSummary = blockcheck.NewErrorSummary()
+var capacityStats blockcheck.CapacityStats = blockcheck.NewCapacityStats()
+var mevInspectClassifications *mevinspect.Classifications
+var refundEstimator *analytics.RefundEstimator = analytics.NewRefundEstimator()
 
 func main() {
 	log.SetOutput(os.Stdout)
@@ -22,6 +30,7 @@ func main() {
 	ethUri := flag.String("eth", os.Getenv("ETH_NODE"), "Ethereum node URI")
 	startDate := flag.String("start", "", "date (yyyy-mm-dd)")
 	endDate := flag.String("end", "", "date (yyyy-mm-dd)")
+	mevInspectFiles := flag.String("mevinspect", "", "mev-inspect csv exports to merge (comma-separated, exported from its Postgres database with \\copy)")
 	flag.Parse()
 
 	if *startDate == "" || *endDate == "" {
@@ -32,11 +41,27 @@ func main() {
 		log.Fatal("Missing eth node uri")
 	}
 
+	if *mevInspectFiles != "" {
+		mevInspectClassifications = mevinspect.NewClassifications()
+		for _, filename := range strings.Split(*mevInspectFiles, ",") {
+			err := mevInspectClassifications.LoadCsvFile(filename)
+			utils.Perror(err)
+		}
+		fmt.Printf("Loaded mev-inspect classifications for %d transactions\n", len(mevInspectClassifications.ByTxHash))
+	}
+
 	fmt.Printf("Connecting to %s ... ", *ethUri)
 	client, err := ethclient.Dial(*ethUri)
 	utils.Perror(err)
 	fmt.Printf("ok\n")
 
+	// Links to the block explorer of the connected chain
+	chainID, err := client.ChainID(context.Background())
+	utils.Perror(err)
+	if err = common.SetExplorerForChainID(chainID.Int64()); err != nil {
+		log.Println(err)
+	}
+
 	startTime, err := utils.DateToTime(*startDate, 0, 0, 0)
 	utils.Perror(err)
 	startBlockHeader, err := utils.GetFirstBlockHeaderAtOrAfterTime(client, startTime)
@@ -86,6 +111,11 @@ func main() {
 	analyzeLock.Lock() // wait until all blocks have been processed
 
 	fmt.Println(errorSummary.String())
+	fmt.Println("Gas limit pressure:")
+	fmt.Println(capacityStats.String())
+	if len(refundEstimator.Searchers) > 0 {
+		fmt.Println(refundEstimator.String(20))
+	}
 
 	timeNeeded := time.Since(timestampMainStart)
 	fmt.Printf("Analysis of %s blocks, %s transactions finished in %.2fs\n", utils.NumberToHumanReadableString(numBlocksProcessed, 0), utils.NumberToHumanReadableString(numTxProcessed, 0), timeNeeded.Seconds())
@@ -95,6 +125,14 @@ func processBlockWithReceipts(block *blockswithtx.BlockWithTxReceipts, client *e
 	utils.PrintBlock(block.Block)
 	check, err := blockcheck.CheckBlock(block, true)
 	utils.Perror(err)
+	capacityStats.AddCheck(check)
+	refundEstimator.AddCheck(check)
+
+	if mevInspectClassifications != nil {
+		for _, record := range mevInspectClassifications.Merge(check) {
+			fmt.Println("-", record.String())
+		}
+	}
 
 	if check.HasSeriousErrors() || check.HasLessSeriousErrors() { // update and print miner error count on serious and less-serious errors
 		errorSummary.AddCheckErrors(check)
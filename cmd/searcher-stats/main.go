@@ -0,0 +1,87 @@
+// Top-N searcher report (miner payments, gas spend, bundle counts and success rates) over a range of blocks
+//
+// Example:
+//
+//	$ go run cmd/searcher-stats/main.go -start 13100000 -end 13100500 -top 20
+//	$ go run cmd/searcher-stats/main.go -start 13100000 -end 13100500 -by contract -eth $ETH_NODE  # with success rates
+//	$ go run cmd/searcher-stats/main.go -start 13100000 -end 13100500 -minbundlereward 0.01  # also without dust bundles
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/metachris/flashbots/analytics"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/flashbots/labels"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+func main() {
+	log.SetOutput(os.Stdout)
+
+	ethUri := flag.String("eth", "", "Ethereum node URI (optional, to get the success rates from tx receipts)")
+	startBlock := flag.Int64("start", 0, "first block")
+	endBlock := flag.Int64("end", 0, "last block")
+	topN := flag.Int("top", 20, "number of searchers in the report")
+	groupBy := flag.String("by", analytics.GroupByEoa, "identify searchers by 'eoa' or 'contract'")
+	minBundleReward := flag.Float64("minbundlereward", 0, "also show the report without dust bundles below this miner reward (ETH)")
+	labelsFile := flag.String("labels", "", "JSON or CSV file with additional address labels (see labels/labels.json)")
+	flag.Parse()
+
+	if *startBlock == 0 || *endBlock < *startBlock {
+		log.Fatal("Missing or invalid block range")
+	}
+
+	if *groupBy != analytics.GroupByEoa && *groupBy != analytics.GroupByContract {
+		log.Fatal("Invalid -by value, use 'eoa' or 'contract'")
+	}
+
+	common.MinBundleRewardEth = *minBundleReward
+
+	var client *ethclient.Client
+	var err error
+	if *ethUri != "" {
+		client, err = ethclient.Dial(*ethUri)
+		utils.Perror(err)
+	}
+
+	if *labelsFile != "" {
+		utils.Perror(labels.Default.LoadFile(*labelsFile))
+	}
+
+	fmt.Print("Fetching flashbots blocks... ")
+	err = blockcheck.CacheFlashbotsBlocks(*startBlock, *endBlock)
+	utils.Perror(err)
+	fmt.Print("done\n")
+
+	searcherAnalytics := analytics.NewSearcherAnalytics(*groupBy)
+	for height := *startBlock; height <= *endBlock; height++ {
+		block, found := blockcheck.FlashbotsBlockCache[height]
+		if !found {
+			continue
+		}
+
+		txStatus := make(map[string]bool)
+		if client != nil {
+			for _, tx := range block.Transactions {
+				receipt, err := client.TransactionReceipt(context.Background(), ethcommon.HexToHash(tx.Hash))
+				if err != nil {
+					log.Println("Error getting receipt:", tx.Hash, err)
+					continue
+				}
+				txStatus[tx.Hash] = receipt.Status == 1
+			}
+		}
+
+		searcherAnalytics.AddBlock(block, txStatus)
+	}
+
+	fmt.Println(searcherAnalytics.String(*topN))
+}
@@ -0,0 +1,56 @@
+// Bundle tip elasticity by miner: how much higher tips (miner reward per gas) move bundles to the top of the block,
+// estimated from the mev-blocks API over a range of blocks
+//
+// Example:
+//
+//	$ go run cmd/tip-elasticity/main.go -start 13100000 -end 13150000
+//	$ go run cmd/tip-elasticity/main.go -start 13100000 -end 13150000 -minbundles 100
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/metachris/flashbots/analytics"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/labels"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+func main() {
+	log.SetOutput(os.Stdout)
+
+	startBlock := flag.Int64("start", 0, "first block")
+	endBlock := flag.Int64("end", 0, "last block")
+	minBundles := flag.Int("minbundles", analytics.MinElasticityBundles, "min. number of ranked bundles of a miner")
+	labelsFile := flag.String("labels", "", "JSON or CSV file with additional address labels (see labels/labels.json)")
+	flag.Parse()
+
+	if *startBlock == 0 || *endBlock < *startBlock {
+		log.Fatal("Missing or invalid block range")
+	}
+	analytics.MinElasticityBundles = *minBundles
+
+	if *labelsFile != "" {
+		utils.Perror(labels.Default.LoadFile(*labelsFile))
+	}
+
+	fmt.Print("Fetching flashbots blocks... ")
+	err := blockcheck.CacheFlashbotsBlocks(*startBlock, *endBlock)
+	utils.Perror(err)
+	fmt.Print("done\n")
+
+	elasticity := analytics.NewTipElasticityAnalytics()
+	for height := *startBlock; height <= *endBlock; height++ {
+		if block, found := blockcheck.FlashbotsBlockCache[height]; found {
+			elasticity.AddBlock(block)
+		}
+	}
+
+	fmt.Println(elasticity.String())
+	fmt.Println("positionPerDoubling: change of the relative position among the bundles of a block (0 = first, 1 = last) when doubling the tip")
+	fmt.Println("elasticity: % change of the probability to be placed first per % change of the tip (lowest vs. highest tip quartile)")
+	fmt.Println("Only included bundles are known, so the inclusion probability itself can't be estimated.")
+}
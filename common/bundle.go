@@ -1,13 +1,20 @@
 package common
 
 import (
+	"fmt"
 	"math/big"
+	"sort"
+	"strings"
 
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/metachris/flashbots/api"
 )
 
 type Bundle struct {
 	Index                 int64
+	Hash                  string // deterministic hash of the ordered tx hashes, see BundleHash
+	BundleType            string // api.BundleTypeFlashbots, api.BundleTypeRogue or api.BundleTypeMegabundle
 	Transactions          []api.FlashbotsTransaction
 	TotalMinerReward      *big.Int
 	TotalCoinbaseTransfer *big.Int
@@ -17,6 +24,14 @@ type Bundle struct {
 	RewardDivGasUsed   *big.Int
 
 	PercentPriceDiff *big.Float // on order error, % difference to previous bundle
+	TailGasPrice     *big.Int   // reference gas price of the block's other tx (lowest, or a percentile), set by the fee check
+	FeePercentile    float64    // on fee error, % of the block's non-Flashbots tx paying a lower gas price (priority fee after London)
+
+	GroupIndex int // bundles placed as one unit (megabundle, merged bundles) share a group, see blockcheck.GroupBundles
+
+	Protocols map[string]int // protocol name -> number of tx interacting with it, see blockcheck.ProtocolRegistry
+
+	SearcherName string // label of the first labeled tx sender, see labels.Default
 
 	IsOutOfOrder                bool
 	IsPayingLessThanLowestTx    bool
@@ -24,6 +39,18 @@ type Bundle struct {
 	IsNegativeEffectiveGasPrice bool
 }
 
+// Bundles with a lower total miner reward (ETH) are dust: they are counted, but left out of the filtered stats and
+// leaderboards (0 = no filter)
+var MinBundleRewardEth float64
+
+// IsDustReward returns true if a bundle with this total miner reward is below MinBundleRewardEth
+func IsDustReward(reward *big.Int) bool {
+	if MinBundleRewardEth <= 0 {
+		return false
+	}
+	return WeiToEthFloat64(reward) < MinBundleRewardEth
+}
+
 func NewBundle() *Bundle {
 	return &Bundle{
 		TotalMinerReward:      new(big.Int),
@@ -32,5 +59,109 @@ func NewBundle() *Bundle {
 		CoinbaseDivGasUsed:    new(big.Int),
 		RewardDivGasUsed:      new(big.Int),
 		PercentPriceDiff:      new(big.Float),
+		Protocols:             make(map[string]int),
+	}
+}
+
+func (b *Bundle) IsMegabundle() bool {
+	return b.BundleType == api.BundleTypeMegabundle
+}
+
+// IsDust returns true if the total miner reward is below MinBundleRewardEth
+func (b *Bundle) IsDust() bool {
+	return IsDustReward(b.TotalMinerReward)
+}
+
+// ProtocolsString returns the protocols of the bundle, sorted by name (eg. "Aave:1, Uniswap V2:2")
+func (b *Bundle) ProtocolsString() string {
+	names := make([]string, 0, len(b.Protocols))
+	for name := range b.Protocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s:%d", name, b.Protocols[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// GasFees returns the part of the miner reward paid with the gas price (the reward without the coinbase transfers)
+func (b *Bundle) GasFees() *big.Int {
+	return new(big.Int).Sub(b.TotalMinerReward, b.TotalCoinbaseTransfer)
+}
+
+// CoinbaseShare returns the share of the miner reward paid by coinbase transfers (0 to 1, 0 without reward)
+func (b *Bundle) CoinbaseShare() float64 {
+	reward := WeiToEthFloat64(b.TotalMinerReward)
+	if reward == 0 {
+		return 0
+	}
+	return WeiToEthFloat64(b.TotalCoinbaseTransfer) / reward
+}
+
+// TailGasPriceMargin returns how many percent the effective gas price (reward/gasused) is above the tail gas price of
+// the block (negative if below). False if the tail gas price is unknown or 0.
+func (b *Bundle) TailGasPriceMargin() (float64, bool) {
+	if b.TailGasPrice == nil || b.TailGasPrice.Sign() <= 0 {
+		return 0, false
+	}
+	margin, _ := PercentDiff(b.RewardDivGasUsed, b.TailGasPrice).Float64()
+	return margin, true
+}
+
+// TxIndexRange returns the lowest and highest index of the bundle's transactions in the block
+func (b *Bundle) TxIndexRange() (min int64, max int64) {
+	for i, tx := range b.Transactions {
+		if i == 0 || tx.TxIndex < min {
+			min = tx.TxIndex
+		}
+		if i == 0 || tx.TxIndex > max {
+			max = tx.TxIndex
+		}
+	}
+	return min, max
+}
+
+// TxIndexes returns the indexes of the bundle's transactions in the block, sorted
+func (b *Bundle) TxIndexes() []int64 {
+	indexes := make([]int64, 0, len(b.Transactions))
+	for _, tx := range b.Transactions {
+		indexes = append(indexes, tx.TxIndex)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	return indexes
+}
+
+// BundleHash returns a deterministic identifier for a bundle: the keccak256 hash of the concatenated tx hashes (in order)
+func BundleHash(txHashes []string) string {
+	data := make([]byte, 0, len(txHashes)*ethcommon.HashLength)
+	for _, txHash := range txHashes {
+		data = append(data, ethcommon.HexToHash(txHash).Bytes()...)
+	}
+	return crypto.Keccak256Hash(data).Hex()
+}
+
+// UpdateHash sets the bundle hash from the transactions, ordered by their index in the block
+func (b *Bundle) UpdateHash() {
+	txs := make([]api.FlashbotsTransaction, len(b.Transactions))
+	copy(txs, b.Transactions)
+	sort.SliceStable(txs, func(i, j int) bool {
+		return txs[i].TxIndex < txs[j].TxIndex
+	})
+
+	txHashes := make([]string, len(txs))
+	for i, tx := range txs {
+		txHashes[i] = tx.Hash
+	}
+	b.Hash = BundleHash(txHashes)
+}
+
+// ShortHash returns the first bytes of the bundle hash, for logs and alerts
+func (b *Bundle) ShortHash() string {
+	if len(b.Hash) < 10 {
+		return b.Hash
 	}
+	return b.Hash[:10]
 }
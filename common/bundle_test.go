@@ -0,0 +1,49 @@
+package common
+
+import (
+	"math"
+	"testing"
+
+	"github.com/metachris/flashbots/api"
+)
+
+func TestBundleHash(t *testing.T) {
+	tx1 := "0x50aa84a35a999f7dbfed2d72c44712742edbfa12dfdeb33904e3fe7244791eed"
+	tx2 := "0x5b9f8480250b56e6e1a954c2db75551c104751133f48540c76afb9f290d34b79"
+
+	h1 := BundleHash([]string{tx1, tx2})
+	if h1 != BundleHash([]string{tx1, tx2}) {
+		t.Error("BundleHash should be deterministic")
+	}
+	if h1 == BundleHash([]string{tx2, tx1}) {
+		t.Error("BundleHash should depend on the tx order")
+	}
+
+	bundle := NewBundle()
+	bundle.Transactions = []api.FlashbotsTransaction{{Hash: tx2, TxIndex: 2}, {Hash: tx1, TxIndex: 1}}
+	bundle.UpdateHash()
+	if bundle.Hash != h1 {
+		t.Error("Unexpected bundle hash:", bundle.Hash, "wanted:", h1)
+	}
+	if bundle.ShortHash() != h1[:10] {
+		t.Error("Unexpected short hash:", bundle.ShortHash())
+	}
+}
+
+func TestBundleProfitability(t *testing.T) {
+	bundle := NewBundle()
+	bundle.TotalMinerReward = EthToWei(0.4)
+	bundle.TotalCoinbaseTransfer = EthToWei(0.3)
+	bundle.RewardDivGasUsed = GweiToWei(30)
+	if bundle.GasFees().Cmp(EthToWei(0.1)) != 0 || math.Abs(bundle.CoinbaseShare()-0.75) > 1e-9 {
+		t.Error("unexpected split:", bundle.GasFees(), bundle.CoinbaseShare())
+	}
+
+	if _, ok := bundle.TailGasPriceMargin(); ok {
+		t.Error("margin without a tail gas price")
+	}
+	bundle.TailGasPrice = GweiToWei(20)
+	if margin, ok := bundle.TailGasPriceMargin(); !ok || math.Abs(margin-50) > 1e-9 {
+		t.Error("unexpected margin:", margin, ok)
+	}
+}
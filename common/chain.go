@@ -0,0 +1,72 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/metachris/flashbots/api"
+)
+
+// ChainConfig holds the network specific settings: chain ID (which selects the block explorer, see Explorers), the
+// mev-blocks API, the mev-boost relays and the MEV-Share event stream
+type ChainConfig struct {
+	Name        string
+	ChainID     int64
+	ApiUrl      string            // mev-blocks API, empty if there is none for the network
+	Relays      map[string]string // mev-boost relays by name (see api.KnownRelays)
+	MevShareUrl string            // MEV-Share event stream, empty if there is none for the network
+}
+
+// Known networks by name
+var ChainConfigs = map[string]*ChainConfig{
+	"mainnet": {Name: "mainnet", ChainID: 1, ApiUrl: api.DefaultBaseUrl, Relays: api.KnownRelays, MevShareUrl: api.DefaultMevShareStreamUrl},
+	"goerli": {Name: "goerli", ChainID: 5, MevShareUrl: "https://mev-share-goerli.flashbots.net", Relays: map[string]string{
+		"flashbots": "https://boost-relay-goerli.flashbots.net",
+	}},
+	"sepolia": {Name: "sepolia", ChainID: 11155111, MevShareUrl: "https://mev-share-sepolia.flashbots.net", Relays: map[string]string{
+		"flashbots": "https://boost-relay-sepolia.flashbots.net",
+	}},
+	"holesky": {Name: "holesky", ChainID: 17000, MevShareUrl: "https://mev-share-holesky.flashbots.net", Relays: map[string]string{
+		"flashbots": "https://boost-relay-holesky.flashbots.net",
+	}},
+}
+
+// CurrentChain is the network set with ApplyChainConfig
+var CurrentChain = ChainConfigs["mainnet"]
+
+// ChainNames returns the names of the known networks, sorted
+func ChainNames() []string {
+	names := make([]string, 0, len(ChainConfigs))
+	for name := range ChainConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetChainConfig returns the config of a known network
+func GetChainConfig(name string) (*ChainConfig, error) {
+	config, found := ChainConfigs[strings.ToLower(name)]
+	if !found {
+		return nil, fmt.Errorf("unknown network '%s' (known: %s)", name, strings.Join(ChainNames(), ", "))
+	}
+	return config, nil
+}
+
+// ApplyChainConfig selects the network: the mev-blocks API url of api.DefaultClient, the relays for
+// api.NewRelayClients and the block explorer for all links
+func ApplyChainConfig(config *ChainConfig) error {
+	CurrentChain = config
+	api.DefaultClient.BaseUrl = strings.TrimSuffix(config.ApiUrl, "/")
+	api.KnownRelays = config.Relays
+	return SetExplorerForChainID(config.ChainID)
+}
+
+// CheckChainID returns an error if the connected node is on another chain
+func (c *ChainConfig) CheckChainID(chainID int64) error {
+	if chainID != c.ChainID {
+		return fmt.Errorf("node is on chain id %d, expected %d for %s", chainID, c.ChainID, c.Name)
+	}
+	return nil
+}
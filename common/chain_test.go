@@ -0,0 +1,32 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/metachris/flashbots/api"
+)
+
+func TestApplyChainConfig(t *testing.T) {
+	defer ApplyChainConfig(ChainConfigs["mainnet"])
+
+	if _, err := GetChainConfig("ropsten"); err == nil {
+		t.Error("Expected an error for an unknown network")
+	}
+
+	sepolia, err := GetChainConfig("Sepolia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ApplyChainConfig(sepolia); err != nil {
+		t.Fatal(err)
+	}
+	if CurrentChain.ChainID != 11155111 || api.DefaultClient.BaseUrl != "" || api.KnownRelays["flashbots"] != "https://boost-relay-sepolia.flashbots.net" {
+		t.Error("Unexpected api or relays:", api.DefaultClient.BaseUrl, api.KnownRelays)
+	}
+	if TxUrl("0x1") != "https://sepolia.etherscan.io/tx/0x1" || IsMainnet() {
+		t.Error("Unexpected explorer:", TxUrl("0x1"))
+	}
+	if sepolia.CheckChainID(1) == nil || sepolia.CheckChainID(11155111) != nil {
+		t.Error("Unexpected chain id check")
+	}
+}
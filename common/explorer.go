@@ -0,0 +1,176 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Explorer generates links to a block explorer: with the url templates if set, else Etherscan or Blockscout style
+// urls of BaseUrl
+type Explorer struct {
+	Name      string
+	ChainID   int64
+	BaseUrl   string // without trailing slash, empty if all templates are set
+	UnclePath string // "uncle" on Etherscan, "block" on explorers without uncle pages (Blockscout)
+	Templates ExplorerTemplates
+}
+
+// ExplorerTemplates are url templates of an explorer, eg. "https://explorer.example/transaction/{hash}"
+type ExplorerTemplates struct {
+	Tx      string `json:"tx"`      // {hash}
+	Block   string `json:"block"`   // {number}
+	Address string `json:"address"` // {address}
+	Uncle   string `json:"uncle"`   // {hash}, default: the block template with the hash
+	Bundle  string `json:"bundle"`  // {number}, the bundles of a block on a bundle explorer (no links if empty)
+}
+
+// Validate returns an error if a template misses its placeholder, or if the tx, block or address template is missing
+// without base url
+func (t ExplorerTemplates) Validate(baseUrl string) error {
+	templates := []struct {
+		name, template, placeholder string
+		required                    bool
+	}{
+		{"tx", t.Tx, "{hash}", baseUrl == ""},
+		{"block", t.Block, "{number}", baseUrl == ""},
+		{"address", t.Address, "{address}", baseUrl == ""},
+		{"uncle", t.Uncle, "{hash}", false},
+		{"bundle", t.Bundle, "{number}", false},
+	}
+	for _, template := range templates {
+		if template.template == "" {
+			if template.required {
+				return fmt.Errorf("%s template is required without base url", template.name)
+			}
+			continue
+		}
+		if !strings.HasPrefix(template.template, "http://") && !strings.HasPrefix(template.template, "https://") {
+			return fmt.Errorf("%s template must be a http(s) url", template.name)
+		}
+		if !strings.Contains(template.template, template.placeholder) {
+			return fmt.Errorf("%s template must contain %s", template.name, template.placeholder)
+		}
+	}
+	return nil
+}
+
+// NewExplorer returns an explorer with the base url (Etherscan urls get uncle links, all others link uncles as
+// blocks) and the templates, which are used instead of the base url where set
+func NewExplorer(chainID int64, name string, baseUrl string, templates ExplorerTemplates) Explorer {
+	baseUrl = strings.TrimSuffix(baseUrl, "/")
+	unclePath := "block"
+	if strings.Contains(baseUrl, "etherscan.io") {
+		unclePath = "uncle"
+	}
+	if name == "" {
+		name = baseUrl
+	}
+	return Explorer{Name: name, ChainID: chainID, BaseUrl: baseUrl, UnclePath: unclePath, Templates: templates}
+}
+
+func (e Explorer) TxUrl(hash string) string {
+	if e.Templates.Tx != "" {
+		return strings.ReplaceAll(e.Templates.Tx, "{hash}", hash)
+	}
+	return fmt.Sprintf("%s/tx/%s", e.BaseUrl, hash)
+}
+
+func (e Explorer) AddressUrl(address string) string {
+	if e.Templates.Address != "" {
+		return strings.ReplaceAll(e.Templates.Address, "{address}", address)
+	}
+	return fmt.Sprintf("%s/address/%s", e.BaseUrl, address)
+}
+
+func (e Explorer) BlockUrl(number int64) string {
+	if e.Templates.Block != "" {
+		return strings.ReplaceAll(e.Templates.Block, "{number}", fmt.Sprint(number))
+	}
+	return fmt.Sprintf("%s/block/%d", e.BaseUrl, number)
+}
+
+func (e Explorer) UncleUrl(hash string) string {
+	if e.Templates.Uncle != "" {
+		return strings.ReplaceAll(e.Templates.Uncle, "{hash}", hash)
+	} else if e.BaseUrl == "" {
+		return strings.ReplaceAll(e.Templates.Block, "{number}", hash)
+	}
+	return fmt.Sprintf("%s/%s/%s", e.BaseUrl, e.UnclePath, hash)
+}
+
+// BundleUrl returns the link to the bundles of the block on the bundle explorer, empty if there is none
+func (e Explorer) BundleUrl(number int64) string {
+	if e.Templates.Bundle == "" {
+		return ""
+	}
+	return strings.ReplaceAll(e.Templates.Bundle, "{number}", fmt.Sprint(number))
+}
+
+// Known explorers by chain ID
+var Explorers = map[int64]Explorer{
+	1:        {"Etherscan", 1, "https://etherscan.io", "uncle", ExplorerTemplates{Bundle: "https://flashbots-explorer.marto.lol/?block={number}"}},
+	5:        {"Goerli Etherscan", 5, "https://goerli.etherscan.io", "uncle", ExplorerTemplates{}},
+	11155111: {"Sepolia Etherscan", 11155111, "https://sepolia.etherscan.io", "uncle", ExplorerTemplates{}},
+	17000:    {"Holesky Etherscan", 17000, "https://holesky.etherscan.io", "uncle", ExplorerTemplates{}},
+	100:      {"Gnosis Blockscout", 100, "https://gnosis.blockscout.com", "block", ExplorerTemplates{}},
+}
+
+// CurrentExplorer is used for all generated links, see SetExplorerForChainID
+var CurrentExplorer = Explorers[1]
+
+// AddExplorer registers an explorer for a chain ID (eg. a Blockscout instance of a testnet). Etherscan urls get
+// uncle links, all others link uncles as blocks.
+func AddExplorer(chainID int64, baseUrl string) {
+	SetExplorer(NewExplorer(chainID, "", baseUrl, ExplorerTemplates{}))
+}
+
+// SetExplorer registers (or replaces) the explorer of its chain ID, and uses it for all links if it's the chain of
+// the current explorer
+func SetExplorer(explorer Explorer) {
+	Explorers[explorer.ChainID] = explorer
+	if CurrentExplorer.ChainID == explorer.ChainID {
+		CurrentExplorer = explorer
+	}
+}
+
+// SetExplorerForChainID selects the explorer of the connected chain. Returns an error for unknown chains, and
+// keeps the current explorer.
+func SetExplorerForChainID(chainID int64) error {
+	explorer, found := Explorers[chainID]
+	if !found {
+		return fmt.Errorf("no block explorer configured for chain id %d", chainID)
+	}
+	CurrentExplorer = explorer
+	return nil
+}
+
+// IsMainnet returns true if the current explorer is for Ethereum mainnet (eg. for mainnet-only links)
+func IsMainnet() bool {
+	return CurrentExplorer.ChainID == 1
+}
+
+// TxUrl returns the link to a transaction on the current explorer
+func TxUrl(hash string) string {
+	return CurrentExplorer.TxUrl(hash)
+}
+
+// AddressUrl returns the link to an address on the current explorer
+func AddressUrl(address string) string {
+	return CurrentExplorer.AddressUrl(address)
+}
+
+// BlockUrl returns the link to a block on the current explorer
+func BlockUrl(number int64) string {
+	return CurrentExplorer.BlockUrl(number)
+}
+
+// UncleUrl returns the link to an uncle block on the current explorer
+func UncleUrl(hash string) string {
+	return CurrentExplorer.UncleUrl(hash)
+}
+
+// BundleUrl returns the link to the bundles of a block on the bundle explorer of the current chain, empty if there is
+// none
+func BundleUrl(number int64) string {
+	return CurrentExplorer.BundleUrl(number)
+}
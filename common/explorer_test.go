@@ -0,0 +1,59 @@
+package common
+
+import "testing"
+
+func TestExplorer(t *testing.T) {
+	defer func() { CurrentExplorer = Explorers[1] }()
+
+	if TxUrl("0x1") != "https://etherscan.io/tx/0x1" || !IsMainnet() {
+		t.Error("Unexpected mainnet url:", TxUrl("0x1"))
+	}
+
+	AddExplorer(12345, "https://blockscout.example.com/")
+	if err := SetExplorerForChainID(12345); err != nil {
+		t.Fatal(err)
+	}
+	if AddressUrl("0xabc") != "https://blockscout.example.com/address/0xabc" || UncleUrl("0x2") != "https://blockscout.example.com/block/0x2" || IsMainnet() {
+		t.Error("Unexpected blockscout urls:", AddressUrl("0xabc"), UncleUrl("0x2"))
+	}
+
+	if err := SetExplorerForChainID(999); err == nil || CurrentExplorer.ChainID != 12345 {
+		t.Error("Unknown chain should return an error and keep the current explorer")
+	}
+}
+
+func TestExplorerTemplates(t *testing.T) {
+	defer func(mainnet Explorer) {
+		Explorers[1] = mainnet
+		CurrentExplorer = mainnet
+	}(Explorers[1])
+
+	if BundleUrl(13100622) != "https://flashbots-explorer.marto.lol/?block=13100622" {
+		t.Error("Unexpected mainnet bundle url:", BundleUrl(13100622))
+	}
+
+	templates := ExplorerTemplates{Tx: "https://scan.example/transaction/{hash}", Block: "https://scan.example/blocks/{number}", Address: "https://scan.example/accounts/{address}"}
+	if err := templates.Validate(""); err != nil {
+		t.Fatal(err)
+	}
+	SetExplorer(NewExplorer(1, "Scan", "", templates)) // replaces the current explorer
+	if TxUrl("0x1") != "https://scan.example/transaction/0x1" || BlockUrl(2) != "https://scan.example/blocks/2" || AddressUrl("0xabc") != "https://scan.example/accounts/0xabc" {
+		t.Error("Unexpected template urls:", TxUrl("0x1"), BlockUrl(2), AddressUrl("0xabc"))
+	}
+	if UncleUrl("0x3") != "https://scan.example/blocks/0x3" || BundleUrl(2) != "" {
+		t.Error("Unexpected uncle or bundle url:", UncleUrl("0x3"), BundleUrl(2))
+	}
+
+	// Templates only replace the urls they are set for
+	SetExplorer(NewExplorer(1, "", "https://etherscan.io/", ExplorerTemplates{Tx: "https://scan.example/transaction/{hash}"}))
+	if TxUrl("0x1") != "https://scan.example/transaction/0x1" || UncleUrl("0x3") != "https://etherscan.io/uncle/0x3" {
+		t.Error("Unexpected urls:", TxUrl("0x1"), UncleUrl("0x3"))
+	}
+
+	if err := (ExplorerTemplates{Tx: "https://scan.example/tx/"}).Validate("https://scan.example"); err == nil {
+		t.Error("Expected an error for a template without placeholder")
+	}
+	if err := (ExplorerTemplates{Tx: "https://scan.example/tx/{hash}"}).Validate(""); err == nil {
+		t.Error("Expected an error for missing templates without base url")
+	}
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"math/big"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TxEffectiveGasPrice returns the gas price a tx actually pays: min(gasFeeCap, baseFee + gasTipCap).
+// Works for legacy and EIP-1559 transactions, and for pre-London blocks (baseFee is nil).
+func TxEffectiveGasPrice(tx *types.Transaction, baseFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return tx.GasPrice()
+	}
+
+	price := new(big.Int).Add(baseFee, tx.GasTipCap())
+	if price.Cmp(tx.GasFeeCap()) == 1 {
+		return new(big.Int).Set(tx.GasFeeCap())
+	}
+	return price
+}
+
+// TxPriorityFee returns the part of the gas price that goes to the miner (effective gas price - baseFee)
+func TxPriorityFee(tx *types.Transaction, baseFee *big.Int) *big.Int {
+	if baseFee == nil {
+		return tx.GasPrice()
+	}
+	return new(big.Int).Sub(TxEffectiveGasPrice(tx, baseFee), baseFee)
+}
+
+// IsZeroPriorityFeeTx returns true for contract calls that don't pay the miner through the gas price. Before London
+// these were the "0-gas" transactions, after London they pay only the baseFee.
+func IsZeroPriorityFeeTx(tx *types.Transaction, baseFee *big.Int) bool {
+	return len(tx.Data()) > 0 && TxPriorityFee(tx, baseFee).Sign() == 0
+}
+
+// IsCoinbaseTransferTx returns true if the tx sends value to the block's miner. transfers are the traced coinbase
+// transfers of the block by tx hash, which include the payments of internal calls (eg. by a searcher contract). If
+// nil (not traced), only direct transfers are detected.
+func IsCoinbaseTransferTx(tx *types.Transaction, block *types.Block, transfers map[ethcommon.Hash]*big.Int) bool {
+	if transfers != nil {
+		transfer, found := transfers[tx.Hash()]
+		return found && transfer.Sign() == 1
+	}
+	return tx.To() != nil && *tx.To() == block.Coinbase() && tx.Value().Sign() == 1
+}
+
+// IsFlashbotsLikeTx returns true if the tx most likely pays the miner via a coinbase transfer instead of the gas price
+// (see IsCoinbaseTransferTx for transfers)
+func IsFlashbotsLikeTx(tx *types.Transaction, block *types.Block, transfers map[ethcommon.Hash]*big.Int) bool {
+	return IsZeroPriorityFeeTx(tx, block.BaseFee()) || IsCoinbaseTransferTx(tx, block, transfers)
+}
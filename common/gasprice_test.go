@@ -0,0 +1,33 @@
+package common
+
+import (
+	"math/big"
+	"testing"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestIsCoinbaseTransferTx(t *testing.T) {
+	coinbase := ethcommon.HexToAddress("0x000000000000000000000000000000000000c0b5")
+	contract := ethcommon.HexToAddress("0x00000000000000000000000000000000000c0de1")
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), Coinbase: coinbase})
+
+	direct := types.NewTransaction(0, coinbase, big.NewInt(1), 21000, big.NewInt(1), nil)
+	internal := types.NewTransaction(1, contract, big.NewInt(0), 100000, big.NewInt(0), []byte{1})
+
+	// Not traced: only the direct transfer
+	if !IsCoinbaseTransferTx(direct, block, nil) || IsCoinbaseTransferTx(internal, block, nil) {
+		t.Error("Expected only the direct coinbase transfer without a trace")
+	}
+
+	// Traced: the payment of the contract call
+	transfers := map[ethcommon.Hash]*big.Int{direct.Hash(): big.NewInt(1), internal.Hash(): big.NewInt(5)}
+	if !IsCoinbaseTransferTx(direct, block, transfers) || !IsCoinbaseTransferTx(internal, block, transfers) {
+		t.Error("Expected the traced coinbase transfers")
+	}
+	transfers[internal.Hash()] = new(big.Int)
+	if IsCoinbaseTransferTx(internal, block, transfers) {
+		t.Error("Expected no coinbase transfer for a traced tx without payment")
+	}
+}
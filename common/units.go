@@ -0,0 +1,172 @@
+package common
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Ether units in wei
+var (
+	Gwei  = big.NewInt(1e9)
+	Ether = big.NewInt(1e18)
+)
+
+// Below these values FormatEth shows gwei, and FormatGwei shows wei
+var (
+	minFormatEth  = big.NewInt(1e14) // 0.0001 ETH
+	minFormatGwei = big.NewInt(1e7)  // 0.01 gwei
+)
+
+// WeiToGwei converts wei to gwei (nil is 0)
+func WeiToGwei(wei *big.Int) *big.Float {
+	return weiToUnit(wei, Gwei)
+}
+
+// WeiToEth converts wei to ETH (nil is 0)
+func WeiToEth(wei *big.Int) *big.Float {
+	return weiToUnit(wei, Ether)
+}
+
+// WeiToGweiFloat64 converts wei to gwei as float64, for metrics and exports
+func WeiToGweiFloat64(wei *big.Int) float64 {
+	f, _ := WeiToGwei(wei).Float64()
+	return f
+}
+
+// WeiToEthFloat64 converts wei to ETH as float64, for metrics and exports
+func WeiToEthFloat64(wei *big.Int) float64 {
+	f, _ := WeiToEth(wei).Float64()
+	return f
+}
+
+func weiToUnit(wei *big.Int, unit *big.Int) *big.Float {
+	if wei == nil {
+		return new(big.Float)
+	}
+	return new(big.Float).Quo(new(big.Float).SetInt(wei), new(big.Float).SetInt(unit))
+}
+
+// GweiToWei converts gwei to wei, truncated to the wei
+func GweiToWei(gwei float64) *big.Int {
+	return unitToWei(gwei, Gwei)
+}
+
+// EthToWei converts ETH to wei, truncated to the wei
+func EthToWei(eth float64) *big.Int {
+	return unitToWei(eth, Ether)
+}
+
+// unitToWei converts the shortest decimal representation of the value, so 0.1 ETH is exactly 1e17 wei
+func unitToWei(value float64, unit *big.Int) *big.Int {
+	amount, _ := new(big.Rat).SetString(strconv.FormatFloat(value, 'f', -1, 64))
+	amount.Mul(amount, new(big.Rat).SetInt(unit))
+	return new(big.Int).Quo(amount.Num(), amount.Denom())
+}
+
+// ParseAmount parses an amount with an optional unit into wei: "1000", "1000 wei", "12.3 gwei", "0.5 eth" (the unit
+// is case-insensitive, and can follow the number without space). Decimals are parsed exactly, not as float64.
+func ParseAmount(s string) (*big.Int, error) {
+	value := strings.ToLower(strings.TrimSpace(s))
+	unit := big.NewInt(1)
+	for _, suffix := range []struct {
+		name string
+		unit *big.Int
+	}{{"gwei", Gwei}, {"wei", big.NewInt(1)}, {"ether", Ether}, {"eth", Ether}} {
+		if strings.HasSuffix(value, suffix.name) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, suffix.name))
+			unit = suffix.unit
+			break
+		}
+	}
+
+	amount, ok := new(big.Rat).SetString(value)
+	if !ok || value == "" {
+		return nil, fmt.Errorf("invalid amount: %s", s)
+	}
+	amount.Mul(amount, new(big.Rat).SetInt(unit))
+	if !amount.IsInt() {
+		return nil, fmt.Errorf("invalid amount: %s (fractions of a wei)", s)
+	}
+	return new(big.Int).Set(amount.Num()), nil
+}
+
+// FormatGwei formats a gas price: "12.3 gwei", and small values (below 0.01 gwei) in wei
+func FormatGwei(wei *big.Int) string {
+	if wei == nil {
+		return "0 gwei"
+	}
+	if wei.Sign() != 0 && new(big.Int).Abs(wei).Cmp(minFormatGwei) == -1 {
+		return wei.String() + " wei"
+	}
+	return formatDecimals(WeiToGwei(wei), 2) + " gwei"
+}
+
+// FormatEth formats an amount in ETH: "0.0512 ETH", and small values (below 0.0001 ETH) in gwei
+func FormatEth(wei *big.Int) string {
+	if wei == nil {
+		return "0 ETH"
+	}
+	if wei.Sign() != 0 && new(big.Int).Abs(wei).Cmp(minFormatEth) == -1 {
+		return FormatGwei(wei)
+	}
+	return formatDecimals(WeiToEth(wei), 4) + " ETH"
+}
+
+// formatDecimals formats the value with at most prec decimals (without trailing zeros), and groups the digits
+func formatDecimals(f *big.Float, prec int) string {
+	s := f.Text('f', prec)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	return GroupDigits(s)
+}
+
+// PercentDiff returns by how many percent a differs from b: (a / b - 1) * 100. If b is 0 it's infinite (with the
+// sign of a), or 0 if both are 0.
+func PercentDiff(a *big.Int, b *big.Int) *big.Float {
+	if b.Sign() == 0 {
+		if a.Sign() == 0 {
+			return new(big.Float)
+		}
+		return new(big.Float).SetInf(a.Sign() < 0)
+	}
+	ratio := new(big.Float).Quo(new(big.Float).SetInt(a), new(big.Float).SetInt(b))
+	return ratio.Sub(ratio, big.NewFloat(1)).Mul(ratio, big.NewFloat(100))
+}
+
+// FormatPercent formats a percentage with 2 decimals: "12.34%"
+func FormatPercent(f *big.Float) string {
+	return f.Text('f', 2) + "%"
+}
+
+// GroupDigits groups the digits of the integer part of a number in thousands with commas, independent of the
+// locale: "-1234567.891" -> "-1,234,567.891"
+func GroupDigits(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+	fraction := ""
+	if i := strings.Index(s, "."); i >= 0 {
+		s, fraction = s[:i], s[i:]
+	}
+
+	var grouped strings.Builder
+	for i, digit := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	return sign + grouped.String() + fraction
+}
+
+// FormatInt formats an integer with grouped digits: 1234567 -> "1,234,567"
+func FormatInt(i int64) string {
+	return GroupDigits(fmt.Sprint(i))
+}
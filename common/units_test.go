@@ -0,0 +1,69 @@
+package common
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	for s, expected := range map[string]string{
+		"1000":          "1000",
+		"1000 wei":      "1000",
+		"12.3 gwei":     "12300000000",
+		"12.3Gwei":      "12300000000",
+		"0.5 eth":       "500000000000000000",
+		"1 ETHER":       "1000000000000000000",
+		"-0.000001 eth": "-1000000000000",
+	} {
+		wei, err := ParseAmount(s)
+		if err != nil || wei.String() != expected {
+			t.Error("unexpected amount for", s, wei, err)
+		}
+	}
+	for _, s := range []string{"", "gwei", "abc", "0.5 wei", "1 btc"} {
+		if _, err := ParseAmount(s); err == nil {
+			t.Error("expected an error for", s)
+		}
+	}
+}
+
+func TestUnitConversion(t *testing.T) {
+	if wei := EthToWei(0.1); wei.String() != "100000000000000000" {
+		t.Error("unexpected wei:", wei)
+	}
+	if wei := GweiToWei(12.3); wei.String() != "12300000000" {
+		t.Error("unexpected wei:", wei)
+	}
+	if gwei := WeiToGweiFloat64(big.NewInt(12_300_000_000)); gwei != 12.3 {
+		t.Error("unexpected gwei:", gwei)
+	}
+	if eth := WeiToEthFloat64(nil); eth != 0 {
+		t.Error("unexpected eth:", eth)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for expected, s := range map[string]string{
+		"12.3 gwei":       FormatGwei(big.NewInt(12_300_000_000)),
+		"1,234 gwei":      FormatGwei(big.NewInt(1_234_000_000_000)),
+		"0 gwei":          FormatGwei(big.NewInt(0)),
+		"5000 wei":        FormatGwei(big.NewInt(5000)),
+		"-2 gwei":         FormatGwei(big.NewInt(-2_000_000_000)),
+		"0.0512 ETH":      FormatEth(big.NewInt(51_234_000_000_000_000)),
+		"1,000.5 ETH":     FormatEth(new(big.Int).Mul(big.NewInt(10005), big.NewInt(1e17))),
+		"21,000 gwei":     FormatEth(big.NewInt(21_000_000_000_000)),
+		"0 ETH":           FormatEth(nil),
+		"100.00%":         FormatPercent(PercentDiff(big.NewInt(2), big.NewInt(1))),
+		"-25.00%":         FormatPercent(PercentDiff(big.NewInt(3), big.NewInt(4))),
+		"0.00%":           FormatPercent(PercentDiff(big.NewInt(0), big.NewInt(0))),
+		"+Inf%":           FormatPercent(PercentDiff(big.NewInt(1), big.NewInt(0))),
+		"1,234,567":       FormatInt(1234567),
+		"-123":            FormatInt(-123),
+		"-1,234,567.891":  GroupDigits("-1234567.891"),
+		"123,456,789,012": GroupDigits("123456789012"),
+	} {
+		if s != expected {
+			t.Errorf("expected %s, got %s", expected, s)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// Package coordinator splits the checks between a lightweight alert-only block-watch instance (the coordinator) and
+// heavy analysis workers, which share a directory:
+//
+//   - The coordinator runs only the cheap checks (see watcher.Watcher.AlertOnly), sends the alerts, and writes a job
+//     for every checked block (JobSink): jobs/<block>-<hash>.json, with the result of the cheap checks.
+//   - Workers consume the check events of the coordinator's /stream, and scan the jobs for the ones they missed (eg.
+//     while disconnected). A worker claims a job by creating claims/<block>-<hash>.json exclusively, with a lease. A
+//     claim with an expired lease (eg. of a crashed worker) is taken over by the next worker.
+//   - The worker runs the complete check (traces, simulations, prices) and writes the enriched result to
+//     results/<block>-<hash>.json, then removes its claim.
+//   - The coordinator polls the results, and reports the errors which only the complete check found.
+//
+// The directory can be on any filesystem with atomic rename and exclusive create (eg. NFS v3 or later).
+package coordinator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/logging"
+	"github.com/metachris/flashbots/schema"
+)
+
+var log = logging.Module("coordinator")
+
+// Subdirectories of the shared directory
+const (
+	jobsDir    = "jobs"
+	claimsDir  = "claims"
+	resultsDir = "results"
+)
+
+// Job is a block for the analysis workers, with the result of the cheap checks of the coordinator
+type Job struct {
+	BlockNumber int64              `json:"block_number"`
+	BlockHash   string             `json:"block_hash"`
+	Created     time.Time          `json:"created"`
+	Fast        schema.CheckResult `json:"fast"`
+}
+
+func NewJob(check *blockcheck.BlockCheck, now time.Time) Job {
+	return Job{BlockNumber: check.Number, BlockHash: check.EthBlock.Hash().Hex(), Created: now, Fast: schema.NewCheckResult(check)}
+}
+
+// Name is the file name of the job, its claim and its result (without extension), eg. 13100622-0xab...
+func (j Job) Name() string {
+	return fmt.Sprintf("%d-%s", j.BlockNumber, strings.ToLower(j.BlockHash))
+}
+
+type claim struct {
+	Worker  string    `json:"worker"`
+	Expires time.Time `json:"expires"`
+}
+
+// Result is the enriched result of a job
+type Result struct {
+	Worker      string             `json:"worker"`
+	BlockNumber int64              `json:"block_number"`
+	BlockHash   string             `json:"block_hash"`
+	Finished    time.Time          `json:"finished"`
+	Result      schema.CheckResult `json:"result"`
+
+	// Errors of the complete check which the cheap checks didn't find, by error type and as messages
+	NewErrorCounts map[string]uint64 `json:"new_error_counts,omitempty"`
+	NewErrors      []string          `json:"new_errors,omitempty"`
+}
+
+// NewResult compares the complete check with the cheap checks of the job
+func NewResult(job Job, worker string, check *blockcheck.BlockCheck, now time.Time) Result {
+	result := Result{Worker: worker, BlockNumber: job.BlockNumber, BlockHash: job.BlockHash, Finished: now, Result: schema.NewCheckResult(check)}
+	for errorType, count := range check.ErrorCounter.Map() {
+		if count > job.Fast.ErrorCounts[errorType] {
+			if result.NewErrorCounts == nil {
+				result.NewErrorCounts = make(map[string]uint64)
+			}
+			result.NewErrorCounts[errorType] = count - job.Fast.ErrorCounts[errorType]
+		}
+	}
+	for _, checkError := range check.CheckErrors {
+		if result.NewErrorCounts[checkError.Kind] > 0 {
+			result.NewErrors = append(result.NewErrors, strings.TrimSpace(checkError.Message))
+		}
+	}
+	return result
+}
+
+// Store is the shared directory of the coordinator and the workers
+type Store struct {
+	Dir string
+}
+
+func NewStore(dir string) (*Store, error) {
+	for _, subdir := range []string{jobsDir, claimsDir, resultsDir} {
+		if err := os.MkdirAll(filepath.Join(dir, subdir), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &Store{Dir: dir}, nil
+}
+
+func (s *Store) path(subdir string, name string) string {
+	return filepath.Join(s.Dir, subdir, name+".json")
+}
+
+// AddJob writes a job for the workers
+func (s *Store) AddJob(job Job) error {
+	return writeJSON(s.path(jobsDir, job.Name()), job, "coordinator")
+}
+
+// Jobs returns the jobs without result which were created after since, oldest first
+func (s *Store) Jobs(since time.Time) (jobs []Job, err error) {
+	names, err := s.list(jobsDir, since)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if s.hasResult(name) {
+			continue
+		}
+		var job Job
+		if err := readJSON(s.path(jobsDir, name), &job); err != nil {
+			log.Warn("invalid job", "job", name, "err", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].BlockNumber < jobs[j].BlockNumber })
+	return jobs, nil
+}
+
+// Claim claims the job for the worker until now + lease. Returns false if the job has a result, or another worker
+// holds a claim which isn't expired. A worker can renew its own claim.
+func (s *Store) Claim(job Job, worker string, lease time.Duration, now time.Time) (bool, error) {
+	if s.hasResult(job.Name()) {
+		return false, nil
+	}
+	path := s.path(claimsDir, job.Name())
+	data, err := json.Marshal(claim{Worker: worker, Expires: now.Add(lease)})
+	if err != nil {
+		return false, err
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = f.Write(data)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			return err == nil, err
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return false, err
+		}
+
+		var existing claim
+		if err := readJSON(path, &existing); err != nil {
+			if errors.Is(err, os.ErrNotExist) { // released meanwhile
+				continue
+			}
+			return false, err
+		}
+		if existing.Worker == worker {
+			return true, writeJSON(path, claim{Worker: worker, Expires: now.Add(lease)}, worker)
+		}
+		if now.Before(existing.Expires) {
+			return false, nil
+		}
+
+		// Only one worker can move the expired claim away, the others get an error and leave the job to it
+		if err := os.Rename(path, path+".expired-"+worker); err != nil {
+			return false, nil
+		}
+		os.Remove(path + ".expired-" + worker)
+		log.Info("taking over expired claim", "job", job.Name(), "worker", existing.Worker, "expired", existing.Expires)
+	}
+	return false, nil
+}
+
+// Release removes the claim of the worker, so another worker can take the job
+func (s *Store) Release(job Job, worker string) error {
+	path := s.path(claimsDir, job.Name())
+	var existing claim
+	if err := readJSON(path, &existing); err != nil || existing.Worker != worker {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// SaveResult writes the enriched result and removes the claim
+func (s *Store) SaveResult(result Result) error {
+	job := Job{BlockNumber: result.BlockNumber, BlockHash: result.BlockHash}
+	if err := writeJSON(s.path(resultsDir, job.Name()), result, result.Worker); err != nil {
+		return err
+	}
+	if err := s.Release(job, result.Worker); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warn("error releasing claim", "job", job.Name(), "err", err)
+	}
+	return nil
+}
+
+func (s *Store) hasResult(name string) bool {
+	_, err := os.Stat(s.path(resultsDir, name))
+	return err == nil
+}
+
+// Results returns the results written after since, by block
+func (s *Store) Results(since time.Time) (results []Result, err error) {
+	names, err := s.list(resultsDir, since)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		var result Result
+		if err := readJSON(s.path(resultsDir, name), &result); err != nil {
+			log.Warn("invalid result", "result", name, "err", err)
+			continue
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].BlockNumber < results[j].BlockNumber })
+	return results, nil
+}
+
+// Prune removes the jobs, claims and results which are older than maxAge
+func (s *Store) Prune(maxAge time.Duration, now time.Time) (removed int, err error) {
+	for _, subdir := range []string{jobsDir, claimsDir, resultsDir} {
+		entries, err := os.ReadDir(filepath.Join(s.Dir, subdir))
+		if err != nil {
+			return removed, err
+		}
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || now.Sub(info.ModTime()) < maxAge {
+				continue
+			}
+			if err := os.Remove(filepath.Join(s.Dir, subdir, entry.Name())); err == nil {
+				removed += 1
+			}
+		}
+	}
+	return removed, nil
+}
+
+// list returns the names of the files in the subdirectory which were modified after since
+func (s *Store) list(subdir string, since time.Time) (names []string, err error) {
+	entries, err := os.ReadDir(filepath.Join(s.Dir, subdir))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue // temporary files
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().After(since) {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	return names, nil
+}
+
+// writeJSON writes the file atomically, the temporary file is unique per writer
+func writeJSON(path string, v interface{}, writer string) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmpFile := path + ".tmp-" + writer
+	if err := os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, path)
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,102 @@
+package coordinator
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/schema"
+)
+
+func TestClaim(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	job := Job{BlockNumber: 100, BlockHash: "0xAB"}
+
+	if ok, err := store.Claim(job, "w1", time.Minute, now); !ok || err != nil {
+		t.Fatal("expected the first claim to succeed", err)
+	}
+	if ok, _ := store.Claim(job, "w2", time.Minute, now); ok {
+		t.Error("expected the claim of another worker to fail")
+	}
+	if ok, _ := store.Claim(job, "w1", time.Minute, now); !ok {
+		t.Error("expected the worker to renew its claim")
+	}
+
+	// The expired claim is taken over
+	if ok, err := store.Claim(job, "w2", time.Minute, now.Add(2*time.Minute)); !ok || err != nil {
+		t.Error("expected the expired claim to be taken over", err)
+	}
+	if err := store.Release(job, "w1"); err != nil {
+		t.Error(err)
+	}
+	if ok, _ := store.Claim(job, "w3", time.Minute, now.Add(2*time.Minute)); ok {
+		t.Error("expected the release of the previous worker to keep the claim of the new one")
+	}
+
+	// Jobs with a result can't be claimed
+	if err := store.SaveResult(Result{Worker: "w2", BlockNumber: 100, BlockHash: "0xAB"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(store.path(claimsDir, job.Name())); !os.IsNotExist(err) {
+		t.Error("expected the claim to be removed with the result")
+	}
+	if ok, _ := store.Claim(job, "w3", time.Minute, now.Add(time.Hour)); ok {
+		t.Error("expected no claim for a job with result")
+	}
+}
+
+func TestJobsAndResults(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-time.Second)
+	for _, block := range []int64{102, 101} {
+		if err := store.AddJob(Job{BlockNumber: block, BlockHash: "0xab", Created: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.SaveResult(Result{Worker: "w1", BlockNumber: 102, BlockHash: "0xab"}); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := store.Jobs(start)
+	if err != nil || len(jobs) != 1 || jobs[0].BlockNumber != 101 {
+		t.Error("expected the job without result", jobs, err)
+	}
+	results, err := store.Results(start)
+	if err != nil || len(results) != 1 || results[0].Worker != "w1" {
+		t.Error("expected the result", results, err)
+	}
+	if results, _ := store.Results(time.Now().Add(time.Second)); len(results) != 0 {
+		t.Error("expected no newer results", results)
+	}
+
+	if removed, err := store.Prune(time.Minute, time.Now().Add(time.Hour)); err != nil || removed != 3 {
+		t.Error("expected the jobs and the result to be pruned", removed, err)
+	}
+}
+
+func TestNewResult(t *testing.T) {
+	job := Job{BlockNumber: 100, BlockHash: "0xab", Fast: schema.CheckResult{ErrorCounts: map[string]uint64{blockcheck.ErrorBundleHas0Fee: 1}}}
+	check := newTestCheck(100)
+	check.CheckErrors = []*blockcheck.CheckError{
+		{Kind: blockcheck.ErrorBundleHas0Fee, Message: "bundle 0 has 0 fee"},
+		{Kind: blockcheck.ErrorCoinbaseTransferMismatch, Message: "coinbase transfer mismatch\n"},
+	}
+	check.ErrorCounter.BundleHas0Fee = 1
+	check.ErrorCounter.CoinbaseTransferMismatch = 1
+
+	result := NewResult(job, "w1", check, time.Now())
+	if len(result.NewErrorCounts) != 1 || result.NewErrorCounts[blockcheck.ErrorCoinbaseTransferMismatch] != 1 {
+		t.Error("unexpected new error counts", result.NewErrorCounts)
+	}
+	if len(result.NewErrors) != 1 || result.NewErrors[0] != "coinbase transfer mismatch" {
+		t.Error("unexpected new errors", result.NewErrors)
+	}
+}
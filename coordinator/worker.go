@@ -0,0 +1,210 @@
+package coordinator
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/schema"
+)
+
+// JobSink writes a job for every check of the coordinator (it's a watcher.CheckSink)
+type JobSink struct {
+	Store *Store
+}
+
+func (s *JobSink) SaveCheck(check *blockcheck.BlockCheck) error {
+	return s.Store.AddJob(NewJob(check, time.Now()))
+}
+
+// Defaults of NewWorker
+var (
+	DefaultLease        = 10 * time.Minute
+	DefaultScanInterval = time.Minute
+	DefaultMaxJobAge    = time.Hour
+)
+
+// Worker runs the complete check of the coordinator's jobs and saves the enriched results
+type Worker struct {
+	ID           string
+	Store        *Store
+	StreamUrl    string        // /stream of the coordinator
+	Lease        time.Duration // how long a claim is valid, should be longer than a check takes
+	ScanInterval time.Duration // the jobs are scanned for missed ones at this interval
+	MaxJobAge    time.Duration // older jobs are left alone (eg. after a long downtime)
+
+	// Analyze runs the complete check of the block of the job (fetching the block with the receipts of the node)
+	Analyze func(ctx context.Context, job Job) (*blockcheck.BlockCheck, error)
+
+	// Optional, called for every saved result
+	OnResult func(result Result)
+
+	HttpClient *http.Client // for the stream, without timeout
+}
+
+func NewWorker(id string, store *Store, streamUrl string, analyze func(ctx context.Context, job Job) (*blockcheck.BlockCheck, error)) *Worker {
+	return &Worker{
+		ID:           id,
+		Store:        store,
+		StreamUrl:    streamUrl,
+		Lease:        DefaultLease,
+		ScanInterval: DefaultScanInterval,
+		MaxJobAge:    DefaultMaxJobAge,
+		Analyze:      analyze,
+		HttpClient:   &http.Client{},
+	}
+}
+
+// Run processes the jobs of the stream, and the missed jobs of the store, until the context is cancelled. The stream
+// is only the fast path, the jobs are read from the store (a check event can arrive before the job was written).
+func (w *Worker) Run(ctx context.Context) {
+	events := make(chan schema.CheckResult, 100)
+	go w.subscribe(ctx, events)
+
+	scan := time.NewTicker(w.ScanInterval)
+	defer scan.Stop()
+	w.scan(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-scan.C:
+			w.scan(ctx)
+		case result := <-events:
+			job := Job{BlockNumber: result.BlockNumber, BlockHash: result.BlockHash}
+			if err := readJSON(w.Store.path(jobsDir, job.Name()), &job); err != nil {
+				job.Fast = result // not written yet, the stream has the same result
+				job.Created = time.Now()
+			}
+			w.Process(ctx, job)
+		}
+	}
+}
+
+// scan processes the open jobs of the store
+func (w *Worker) scan(ctx context.Context) {
+	jobs, err := w.Store.Jobs(time.Now().Add(-w.MaxJobAge))
+	if err != nil {
+		log.Error("error reading the jobs", "err", err)
+		return
+	}
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		w.Process(ctx, job)
+	}
+}
+
+// Process claims the job and saves the result of the complete check. Returns false if the job was done by another
+// worker, or failed (the claim is released, so another worker can retry).
+func (w *Worker) Process(ctx context.Context, job Job) bool {
+	claimed, err := w.Store.Claim(job, w.ID, w.Lease, time.Now())
+	if err != nil {
+		log.Error("error claiming job", "job", job.Name(), "err", err)
+		return false
+	}
+	if !claimed {
+		return false
+	}
+
+	check, err := w.Analyze(ctx, job)
+	if err == nil && !strings.EqualFold(check.EthBlock.Hash().Hex(), job.BlockHash) {
+		err = fmt.Errorf("block %d was reorged, the node has %s", job.BlockNumber, check.EthBlock.Hash().Hex())
+	}
+	if err != nil {
+		log.Warn("analysis failed", "job", job.Name(), "err", err)
+		if err := w.Store.Release(job, w.ID); err != nil {
+			log.Error("error releasing claim", "job", job.Name(), "err", err)
+		}
+		return false
+	}
+
+	result := NewResult(job, w.ID, check, time.Now())
+	if err := w.Store.SaveResult(result); err != nil {
+		log.Error("error saving result", "job", job.Name(), "err", err)
+		return false
+	}
+	log.Info("block analyzed", "block", job.BlockNumber, "errors", len(result.Result.Errors), "new", len(result.NewErrors))
+	if w.OnResult != nil {
+		w.OnResult(result)
+	}
+	return true
+}
+
+// subscribe sends the check events of the coordinator's stream to the channel, and reconnects when the stream fails
+func (w *Worker) subscribe(ctx context.Context, events chan<- schema.CheckResult) {
+	for {
+		err := w.readStream(ctx, events)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn("coordinator stream error, reconnecting", "url", w.StreamUrl, "err", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
+
+func (w *Worker) readStream(ctx context.Context, events chan<- schema.CheckResult) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.StreamUrl, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := w.HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("stream status %s", resp.Status)
+	}
+	log.Info("connected to the coordinator stream", "url", w.StreamUrl)
+
+	return readCheckEvents(resp.Body, func(result schema.CheckResult) {
+		select {
+		case events <- result:
+		default: // the worker is busy, the scan picks the job up later
+		}
+	})
+}
+
+// readCheckEvents decodes the "check" events of the stream (see watcher.StreamHandler), other events and comments are
+// skipped. Returns when the stream ends.
+func readCheckEvents(r io.Reader, onCheck func(result schema.CheckResult)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	event, data := "", ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+		case line == "":
+			if event == "check" && data != "" {
+				var result schema.CheckResult
+				if err := json.Unmarshal([]byte(data), &result); err != nil {
+					log.Warn("invalid check event", "err", err)
+				} else {
+					onCheck(result)
+				}
+			}
+			event, data = "", ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
@@ -0,0 +1,77 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/schema"
+)
+
+func newTestCheck(number int64) *blockcheck.BlockCheck {
+	return &blockcheck.BlockCheck{Number: number, EthBlock: types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number)})}
+}
+
+func TestReadCheckEvents(t *testing.T) {
+	stream := ": connected\n\n" +
+		"event: alert\ndata: {\"block_number\":1}\n\n" +
+		"event: check\nid: 2\ndata: {\"block_number\":2,\"block_hash\":\"0xab\"}\n\n" +
+		"event: check\ndata: invalid\n\n" +
+		"event: check\ndata: {\"block_number\":3}\n\n"
+
+	var results []schema.CheckResult
+	err := readCheckEvents(strings.NewReader(stream), func(result schema.CheckResult) { results = append(results, result) })
+	if err != io.EOF {
+		t.Error("expected EOF at the end of the stream", err)
+	}
+	if len(results) != 2 || results[0].BlockNumber != 2 || results[0].BlockHash != "0xab" || results[1].BlockNumber != 3 {
+		t.Error("unexpected check events", results)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := newTestCheck(100)
+	job := NewJob(check, time.Now())
+
+	// A failed analysis releases the claim
+	worker := NewWorker("w1", store, "", func(ctx context.Context, job Job) (*blockcheck.BlockCheck, error) {
+		return nil, errors.New("node unavailable")
+	})
+	if worker.Process(context.Background(), job) {
+		t.Error("expected the failed analysis to return false")
+	}
+	if ok, _ := store.Claim(job, "w2", time.Minute, time.Now()); !ok {
+		t.Error("expected the claim to be released")
+	}
+	store.Release(job, "w2")
+
+	// A reorged block isn't saved
+	worker.Analyze = func(ctx context.Context, job Job) (*blockcheck.BlockCheck, error) {
+		return newTestCheck(101), nil
+	}
+	if worker.Process(context.Background(), job) {
+		t.Error("expected the reorged block to return false")
+	}
+
+	var saved []Result
+	worker.OnResult = func(result Result) { saved = append(saved, result) }
+	worker.Analyze = func(ctx context.Context, job Job) (*blockcheck.BlockCheck, error) {
+		return check, nil
+	}
+	if !worker.Process(context.Background(), job) || len(saved) != 1 || saved[0].Worker != "w1" {
+		t.Error("expected the result to be saved", saved)
+	}
+	if worker.Process(context.Background(), job) {
+		t.Error("expected the finished job to be skipped")
+	}
+}
@@ -0,0 +1,398 @@
+// Package discordbot answers Discord slash commands (/check, /miner, /failedtx) with embeds. It's an interactions
+// endpoint (https://discord.com/developers/docs/interactions/receiving-and-responding): Discord sends the commands as
+// signed HTTP requests to a public url, the answers are queried from a Backend (eg. grpcapi.Server, backed by the
+// watcher and the stored check results).
+package discordbot
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metachris/flashbots/grpcapi"
+	"github.com/metachris/flashbots/logging"
+	"github.com/metachris/flashbots/schema"
+)
+
+var log = logging.Module("discordbot")
+
+// Discord API base url, for the deferred answers and the command registration
+var ApiUrl = "https://discord.com/api/v10"
+
+// Commands which take longer than DeferAfter are answered later (Discord waits max. 3 seconds for the answer), they
+// are cancelled after CommandTimeout (interaction tokens are valid for 15 minutes)
+var (
+	DeferAfter     = 2 * time.Second
+	CommandTimeout = 5 * time.Minute
+)
+
+// Max. size of an interaction request
+var MaxRequestSize int64 = 64 * 1024
+
+// Max. number of /check commands running at once, in total and per user (a re-check runs the traces, simulations and
+// price lookups of a complete check). Commands above the limits are answered with an error.
+var (
+	MaxChecks        = 2
+	MaxChecksPerUser = 1
+)
+
+// Interaction and response types
+const (
+	InteractionPing               = 1
+	InteractionApplicationCommand = 2
+
+	ResponsePong                     = 1
+	ResponseChannelMessageWithSource = 4
+	ResponseDeferredChannelMessage   = 5
+
+	FlagEphemeral = 64 // the answer is only visible to the user
+)
+
+// Backend answers the queries of the commands, eg. grpcapi.Server
+type Backend interface {
+	GetCheckBlock(ctx context.Context, request grpcapi.CheckBlockRequest) (schema.CheckResult, error)
+	GetMinerStats(request grpcapi.GetMinerStatsRequest) ([]schema.MinerStats, error)
+	GetFailedTxHistory(request grpcapi.GetFailedTxHistoryRequest) ([]grpcapi.FailedTxRecord, error)
+}
+
+// Interaction is a request of Discord (only the fields used by the bot)
+type Interaction struct {
+	Type          int    `json:"type"`
+	ApplicationID string `json:"application_id"`
+	Token         string `json:"token"`
+	Member        *struct {
+		User User `json:"user"`
+	} `json:"member"` // in guilds
+	User *User `json:"user"` // in direct messages
+	Data struct {
+		Name    string   `json:"name"`
+		Options []Option `json:"options"`
+	} `json:"data"`
+}
+
+// User is the Discord user who sent the interaction
+type User struct {
+	ID string `json:"id"`
+}
+
+// UserID returns the id of the user who sent the interaction
+func (i *Interaction) UserID() string {
+	if i.Member != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+// Option is an argument of a command
+type Option struct {
+	Name  string          `json:"name"`
+	Type  int             `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// InteractionResponse is the answer to an interaction
+type InteractionResponse struct {
+	Type int      `json:"type"`
+	Data *Message `json:"data,omitempty"`
+}
+
+type Message struct {
+	Content string  `json:"content,omitempty"`
+	Embeds  []Embed `json:"embeds,omitempty"`
+	Flags   int     `json:"flags,omitempty"`
+}
+
+// Bot is the http.Handler of the interactions endpoint
+type Bot struct {
+	PublicKey  ed25519.PublicKey // of the Discord application, to verify the requests
+	Backend    Backend
+	HttpClient *http.Client // for the deferred answers
+
+	lock       sync.Mutex
+	checks     int            // /check commands running
+	userChecks map[string]int // /check commands running, by user id
+}
+
+// NewBot returns the bot for the public key of the Discord application (hex, see the application settings)
+func NewBot(publicKeyHex string, backend Backend) (*Bot, error) {
+	publicKey, err := hex.DecodeString(strings.TrimSpace(publicKeyHex))
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid Discord public key, expected 32 bytes hex")
+	}
+	return &Bot{PublicKey: publicKey, Backend: backend, HttpClient: &http.Client{Timeout: 10 * time.Second}, userChecks: make(map[string]int)}, nil
+}
+
+func (b *Bot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxRequestSize))
+	if err != nil {
+		http.Error(w, "error reading the request", http.StatusBadRequest)
+		return
+	}
+
+	// Discord checks that requests with invalid signatures are rejected
+	if !b.verify(r.Header.Get("X-Signature-Ed25519"), r.Header.Get("X-Signature-Timestamp"), body) {
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+
+	var interaction Interaction
+	if err = json.Unmarshal(body, &interaction); err != nil {
+		http.Error(w, "invalid interaction", http.StatusBadRequest)
+		return
+	}
+
+	switch interaction.Type {
+	case InteractionPing:
+		writeResponse(w, InteractionResponse{Type: ResponsePong})
+	case InteractionApplicationCommand:
+		b.answer(w, &interaction)
+	default:
+		http.Error(w, "unsupported interaction type", http.StatusBadRequest)
+	}
+}
+
+// verify checks the signature of the request (timestamp + body)
+func (b *Bot) verify(signatureHex string, timestamp string, body []byte) bool {
+	signature, err := hex.DecodeString(signatureHex)
+	if err != nil || len(signature) != ed25519.SignatureSize || timestamp == "" {
+		return false
+	}
+	return ed25519.Verify(b.PublicKey, append([]byte(timestamp), body...), signature)
+}
+
+// answer runs the command, and answers directly if it's done within DeferAfter, else later by editing the deferred
+// answer
+func (b *Bot) answer(w http.ResponseWriter, interaction *Interaction) {
+	if interaction.Data.Name == "check" {
+		if !b.startCheck(interaction.UserID()) {
+			writeResponse(w, InteractionResponse{Type: ResponseChannelMessageWithSource, Data: &Message{Content: "Error: too many checks running, try again later", Flags: FlagEphemeral}})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), CommandTimeout) // outlives the request if deferred
+	done := make(chan *Message, 1)
+	go func() {
+		defer cancel()
+		msg := b.Run(ctx, interaction.Data.Name, interaction.Data.Options)
+		if interaction.Data.Name == "check" {
+			b.checkDone(interaction.UserID())
+		}
+		done <- msg
+	}()
+
+	select {
+	case msg := <-done:
+		writeResponse(w, InteractionResponse{Type: ResponseChannelMessageWithSource, Data: msg})
+	case <-time.After(DeferAfter):
+		writeResponse(w, InteractionResponse{Type: ResponseDeferredChannelMessage})
+		go func() {
+			if err := b.editAnswer(interaction, <-done); err != nil {
+				log.Error("error sending the deferred answer", "command", interaction.Data.Name, "err", err)
+			}
+		}()
+	}
+}
+
+// startCheck returns true if the user can run a /check command (see MaxChecks, MaxChecksPerUser), it has to be
+// finished with checkDone
+func (b *Bot) startCheck(userID string) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.checks >= MaxChecks || b.userChecks[userID] >= MaxChecksPerUser {
+		return false
+	}
+	if b.userChecks == nil {
+		b.userChecks = make(map[string]int)
+	}
+	b.checks += 1
+	b.userChecks[userID] += 1
+	return true
+}
+
+func (b *Bot) checkDone(userID string) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.checks -= 1
+	if b.userChecks[userID] -= 1; b.userChecks[userID] <= 0 {
+		delete(b.userChecks, userID)
+	}
+}
+
+// Run runs a command, errors are answered with an ephemeral message
+func (b *Bot) Run(ctx context.Context, command string, options []Option) *Message {
+	var embed Embed
+	var err error
+	switch command {
+	case "check":
+		embed, err = b.check(ctx, options)
+	case "miner":
+		embed, err = b.miner(options)
+	case "failedtx":
+		embed, err = b.failedTx(options)
+	default:
+		err = fmt.Errorf("unknown command %s", command)
+	}
+	if err != nil {
+		var statusErr *grpcapi.Error
+		if errors.As(err, &statusErr) {
+			err = errors.New(statusErr.Message)
+		}
+		return &Message{Content: "Error: " + err.Error(), Flags: FlagEphemeral}
+	}
+	return &Message{Embeds: []Embed{embed}}
+}
+
+func (b *Bot) check(ctx context.Context, options []Option) (embed Embed, err error) {
+	var request grpcapi.CheckBlockRequest
+	if request.BlockNumber, err = intOption(options, "block"); err != nil {
+		return embed, err
+	}
+	if request.Recheck, err = boolOption(options, "recheck"); err != nil {
+		return embed, err
+	}
+	result, err := b.Backend.GetCheckBlock(ctx, request)
+	if err != nil {
+		return embed, err
+	}
+	return CheckEmbed(result), nil
+}
+
+func (b *Bot) miner(options []Option) (embed Embed, err error) {
+	var request grpcapi.GetMinerStatsRequest
+	hours, err := intOption(options, "hours")
+	if err != nil {
+		return embed, err
+	}
+	request.WindowSec = hours * 3600
+	miner, err := stringOption(options, "miner")
+	if err != nil {
+		return embed, err
+	}
+	if strings.HasPrefix(miner, "0x") {
+		request.Miner = miner
+	}
+
+	stats, err := b.Backend.GetMinerStats(request)
+	if err != nil {
+		return embed, err
+	}
+	if miner != "" && request.Miner == "" { // by name
+		matching := stats[:0]
+		for _, minerStats := range stats {
+			if strings.Contains(strings.ToLower(minerStats.MinerName), strings.ToLower(miner)) {
+				matching = append(matching, minerStats)
+			}
+		}
+		stats = matching
+	}
+	return MinerStatsEmbed(stats, miner), nil
+}
+
+func (b *Bot) failedTx(options []Option) (embed Embed, err error) {
+	var request grpcapi.GetFailedTxHistoryRequest
+	if request.Miner, err = stringOption(options, "miner"); err != nil {
+		return embed, err
+	}
+	if request.Address, err = stringOption(options, "address"); err != nil {
+		return embed, err
+	}
+	if request.Limit, err = intOption(options, "limit"); err != nil {
+		return embed, err
+	}
+	if request.Limit == 0 || request.Limit > MaxEmbedFields {
+		request.Limit = DefaultFailedTxLimit
+	}
+	txs, err := b.Backend.GetFailedTxHistory(request)
+	if err != nil {
+		return embed, err
+	}
+	return FailedTxEmbed(txs), nil
+}
+
+// editAnswer replaces the deferred answer ("bot is thinking...") with the message
+func (b *Bot) editAnswer(interaction *Interaction, msg *Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/webhooks/%s/%s/messages/@original", ApiUrl, interaction.ApplicationID, interaction.Token)
+	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doRequest(b.HttpClient, req)
+}
+
+// doRequest sends the request to the Discord API, status codes >= 300 are errors
+func doRequest(client *http.Client, req *http.Request) error {
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		bodyBytes, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("discord error response: %s - %s", res.Status, strings.TrimSpace(string(bodyBytes)))
+	}
+	return nil
+}
+
+func writeResponse(w http.ResponseWriter, response InteractionResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// option returns the value of the option, nil if it's not set
+func option(options []Option, name string) json.RawMessage {
+	for _, option := range options {
+		if option.Name == name {
+			return option.Value
+		}
+	}
+	return nil
+}
+
+func intOption(options []Option, name string) (value int64, err error) {
+	if raw := option(options, name); raw != nil {
+		if err = json.Unmarshal(raw, &value); err != nil {
+			return 0, fmt.Errorf("invalid %s: %s", name, raw)
+		}
+	}
+	return value, nil
+}
+
+func stringOption(options []Option, name string) (value string, err error) {
+	if raw := option(options, name); raw != nil {
+		if err = json.Unmarshal(raw, &value); err != nil {
+			return "", fmt.Errorf("invalid %s: %s", name, raw)
+		}
+	}
+	return strings.TrimSpace(value), nil
+}
+
+func boolOption(options []Option, name string) (value bool, err error) {
+	if raw := option(options, name); raw != nil {
+		if err = json.Unmarshal(raw, &value); err != nil {
+			return false, fmt.Errorf("invalid %s: %s", name, raw)
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,241 @@
+package discordbot
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metachris/flashbots/grpcapi"
+	"github.com/metachris/flashbots/schema"
+)
+
+type testBackend struct {
+	delay    time.Duration
+	results  map[int64]schema.CheckResult
+	stats    []schema.MinerStats
+	failedTx []grpcapi.FailedTxRecord
+	request  interface{} // the last request
+}
+
+func (b *testBackend) GetCheckBlock(ctx context.Context, request grpcapi.CheckBlockRequest) (schema.CheckResult, error) {
+	b.request = request
+	time.Sleep(b.delay)
+	result, found := b.results[request.BlockNumber]
+	if !found {
+		return result, &grpcapi.Error{Code: grpcapi.StatusNotFound, Message: "block wasn't checked"}
+	}
+	return result, nil
+}
+
+func (b *testBackend) GetMinerStats(request grpcapi.GetMinerStatsRequest) ([]schema.MinerStats, error) {
+	b.request = request
+	return b.stats, nil
+}
+
+func (b *testBackend) GetFailedTxHistory(request grpcapi.GetFailedTxHistoryRequest) ([]grpcapi.FailedTxRecord, error) {
+	b.request = request
+	return b.failedTx, nil
+}
+
+func newTestBot(t *testing.T, backend Backend) (*Bot, ed25519.PrivateKey) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot, err := NewBot(hex.EncodeToString(publicKey), backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bot, privateKey
+}
+
+// send posts the signed interaction to the bot
+func send(t *testing.T, bot *Bot, key ed25519.PrivateKey, interaction string) (int, InteractionResponse) {
+	timestamp := "1630000000"
+	req := httptest.NewRequest(http.MethodPost, "/discord/interactions", strings.NewReader(interaction))
+	req.Header.Set("X-Signature-Ed25519", hex.EncodeToString(ed25519.Sign(key, []byte(timestamp+interaction))))
+	req.Header.Set("X-Signature-Timestamp", timestamp)
+	w := httptest.NewRecorder()
+	bot.ServeHTTP(w, req)
+
+	var response InteractionResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, response
+}
+
+func TestSignature(t *testing.T) {
+	bot, key := newTestBot(t, &testBackend{})
+	if code, response := send(t, bot, key, `{"type":1}`); code != http.StatusOK || response.Type != ResponsePong {
+		t.Error("expected pong", code, response)
+	}
+
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	if code, _ := send(t, bot, otherKey, `{"type":1}`); code != http.StatusUnauthorized {
+		t.Error("expected the request with an invalid signature to be rejected", code)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/discord/interactions", strings.NewReader(`{"type":1}`))
+	w := httptest.NewRecorder()
+	bot.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Error("expected the unsigned request to be rejected", w.Code)
+	}
+
+	if _, err := NewBot("abc", nil); err == nil {
+		t.Error("expected an error for an invalid public key")
+	}
+}
+
+func TestCommands(t *testing.T) {
+	backend := &testBackend{
+		results: map[int64]schema.CheckResult{13500000: {BlockNumber: 13500000, Miner: "0xabc", MinerName: "Pool", Errors: []string{"- failed Flashbots tx"}, ErrorCounts: map[string]uint64{"failedFbTx": 1}, HasSeriousErrors: true}},
+		stats: []schema.MinerStats{
+			{Miner: "0xabc", MinerName: "Pool A", WindowSec: 3600, Blocks: 10, ErrorBlocks: 1, ErrorRate: 0.1, ErrorCounts: map[string]uint64{"failedFbTx": 1}},
+			{Miner: "0xdef", MinerName: "Pool B", WindowSec: 3600, Blocks: 10},
+		},
+		failedTx: []grpcapi.FailedTxRecord{{Hash: "0x1234567890abcdef1234567890abcdef", BlockNumber: 13500000, Miner: "0xabc", IsFlashbots: true, RevertReason: "too little received"}},
+	}
+	bot, key := newTestBot(t, backend)
+
+	_, response := send(t, bot, key, `{"type":2,"data":{"name":"check","options":[{"name":"block","type":4,"value":13500000}]}}`)
+	if response.Type != ResponseChannelMessageWithSource || response.Data == nil || len(response.Data.Embeds) != 1 {
+		t.Fatal("expected an embed", response)
+	}
+	embed := response.Data.Embeds[0]
+	if embed.Title != "Block 13500000" || embed.Color != ColorSerious || !strings.Contains(embed.Description, "failed Flashbots tx") {
+		t.Error("unexpected check embed", embed)
+	}
+
+	_, response = send(t, bot, key, `{"type":2,"data":{"name":"check","options":[{"name":"block","type":4,"value":1}]}}`)
+	if response.Data == nil || response.Data.Flags != FlagEphemeral || response.Data.Content != "Error: block wasn't checked" {
+		t.Error("expected an ephemeral error", response.Data)
+	}
+
+	_, response = send(t, bot, key, `{"type":2,"data":{"name":"miner","options":[{"name":"miner","type":3,"value":"pool b"},{"name":"hours","type":4,"value":1}]}}`)
+	if request := backend.request.(grpcapi.GetMinerStatsRequest); request.WindowSec != 3600 || request.Miner != "" {
+		t.Error("unexpected miner stats request", request)
+	}
+	if embed = response.Data.Embeds[0]; len(embed.Fields) != 1 || embed.Fields[0].Name != "Pool B (0xdef)" || embed.Title != "Miner stats (1h)" {
+		t.Error("expected the stats of the miner with the name", embed)
+	}
+
+	_, response = send(t, bot, key, `{"type":2,"data":{"name":"failedtx","options":[{"name":"limit","type":4,"value":100}]}}`)
+	if request := backend.request.(grpcapi.GetFailedTxHistoryRequest); request.Limit != DefaultFailedTxLimit {
+		t.Error("expected the default limit for a limit above the max. embed fields", request)
+	}
+	if embed = response.Data.Embeds[0]; len(embed.Fields) != 1 || !strings.Contains(embed.Fields[0].Value, "too little received") {
+		t.Error("unexpected failed tx embed", embed)
+	}
+
+	_, response = send(t, bot, key, `{"type":2,"data":{"name":"unknown"}}`)
+	if response.Data == nil || response.Data.Flags != FlagEphemeral {
+		t.Error("expected an error for an unknown command", response.Data)
+	}
+}
+
+func TestDeferredAnswer(t *testing.T) {
+	edited := make(chan Message, 1)
+	discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch || r.URL.Path != "/webhooks/app/token/messages/@original" {
+			t.Error("unexpected request", r.Method, r.URL.Path)
+		}
+		var msg Message
+		json.NewDecoder(r.Body).Decode(&msg)
+		edited <- msg
+	}))
+	defer discord.Close()
+	defer func(apiUrl string, deferAfter time.Duration) { ApiUrl, DeferAfter = apiUrl, deferAfter }(ApiUrl, DeferAfter)
+	ApiUrl, DeferAfter = discord.URL, 10*time.Millisecond
+
+	backend := &testBackend{delay: 100 * time.Millisecond, results: map[int64]schema.CheckResult{1: {BlockNumber: 1}}}
+	bot, key := newTestBot(t, backend)
+	_, response := send(t, bot, key, `{"type":2,"application_id":"app","token":"token","data":{"name":"check","options":[{"name":"block","type":4,"value":1}]}}`)
+	if response.Type != ResponseDeferredChannelMessage {
+		t.Fatal("expected a deferred answer", response)
+	}
+
+	select {
+	case msg := <-edited:
+		if len(msg.Embeds) != 1 || msg.Embeds[0].Title != "Block 1" || msg.Embeds[0].Color != ColorOK {
+			t.Error("unexpected deferred answer", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the deferred answer wasn't sent")
+	}
+}
+
+// blockingBackend answers the checks when release is closed
+type blockingBackend struct {
+	testBackend
+	release chan bool
+}
+
+func (b *blockingBackend) GetCheckBlock(ctx context.Context, request grpcapi.CheckBlockRequest) (schema.CheckResult, error) {
+	<-b.release
+	return schema.CheckResult{BlockNumber: request.BlockNumber}, nil
+}
+
+func TestCheckLimit(t *testing.T) {
+	edited := make(chan Message, 2)
+	discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg Message
+		json.NewDecoder(r.Body).Decode(&msg)
+		edited <- msg
+	}))
+	defer discord.Close()
+	defer func(apiUrl string, deferAfter time.Duration) { ApiUrl, DeferAfter = apiUrl, deferAfter }(ApiUrl, DeferAfter)
+	ApiUrl, DeferAfter = discord.URL, 10*time.Millisecond
+
+	backend := &blockingBackend{release: make(chan bool)}
+	bot, key := newTestBot(t, backend)
+	check := func(user string) InteractionResponse {
+		_, response := send(t, bot, key, `{"type":2,"application_id":"app","token":"token","member":{"user":{"id":"`+user+`"}},"data":{"name":"check","options":[{"name":"block","type":4,"value":1},{"name":"recheck","type":5,"value":true}]}}`)
+		return response
+	}
+
+	if response := check("a"); response.Type != ResponseDeferredChannelMessage {
+		t.Fatal("expected the first check to run", response)
+	}
+	if response := check("a"); response.Data == nil || response.Data.Flags != FlagEphemeral || !strings.Contains(response.Data.Content, "too many checks") {
+		t.Error("expected the second check of the user to be rejected", response)
+	}
+	if response := check("b"); response.Type != ResponseDeferredChannelMessage {
+		t.Fatal("expected the check of another user to run", response)
+	}
+	if response := check("c"); response.Data == nil || !strings.Contains(response.Data.Content, "too many checks") {
+		t.Error("expected the check above the global limit to be rejected", response)
+	}
+
+	close(backend.release)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-edited:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the deferred answer wasn't sent")
+		}
+	}
+	bot.lock.Lock()
+	checks := bot.checks
+	bot.lock.Unlock()
+	if checks != 0 {
+		t.Error("expected no running checks after the answers", checks)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if s := truncate("line 1\nline 2\nline 3", 16); s != "line 1\nline 2..." {
+		t.Error("expected the text to be cut at a line break", s)
+	}
+	if s := truncate(strings.Repeat("ä", 10), 8); s != "ää..." {
+		t.Error("expected the text to be cut between characters", s)
+	}
+}
@@ -0,0 +1,84 @@
+package discordbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Option types of the command definitions
+const (
+	OptionString  = 3
+	OptionInteger = 4
+	OptionBoolean = 5
+)
+
+// Command is the definition of a slash command
+type Command struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Options     []CommandOption `json:"options,omitempty"`
+}
+
+type CommandOption struct {
+	Type        int    `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required,omitempty"`
+	MinValue    *int64 `json:"min_value,omitempty"`
+}
+
+var one = int64(1)
+
+// Commands are the slash commands of the bot, see RegisterCommands
+var Commands = []Command{
+	{
+		Name:        "check",
+		Description: "Check result of a block (checked now if it wasn't checked before)",
+		Options: []CommandOption{
+			{Type: OptionInteger, Name: "block", Description: "block number", Required: true, MinValue: &one},
+			{Type: OptionBoolean, Name: "recheck", Description: "check the block again, even if it was checked before"},
+		},
+	},
+	{
+		Name:        "miner",
+		Description: "Error rates of the miners",
+		Options: []CommandOption{
+			{Type: OptionString, Name: "miner", Description: "coinbase address or name (default: all miners)"},
+			{Type: OptionInteger, Name: "hours", Description: "time window (default: 24)", MinValue: &one},
+		},
+	},
+	{
+		Name:        "failedtx",
+		Description: "Latest failed Flashbots and 0 gas transactions",
+		Options: []CommandOption{
+			{Type: OptionString, Name: "miner", Description: "only of this miner (coinbase address)"},
+			{Type: OptionString, Name: "address", Description: "only from or to this address"},
+			{Type: OptionInteger, Name: "limit", Description: fmt.Sprintf("number of transactions (default: %d, max. %d)", DefaultFailedTxLimit, MaxEmbedFields), MinValue: &one},
+		},
+	},
+}
+
+// RegisterCommands registers (or updates) the slash commands of the application with the bot token, in one guild
+// (server) if guildID is set: guild commands are available immediately, global commands can take up to an hour.
+func RegisterCommands(ctx context.Context, applicationID string, botToken string, guildID string) error {
+	url := fmt.Sprintf("%s/applications/%s/commands", ApiUrl, applicationID)
+	if guildID != "" {
+		url = fmt.Sprintf("%s/applications/%s/guilds/%s/commands", ApiUrl, applicationID, guildID)
+	}
+	payload, err := json.Marshal(Commands)
+	if err != nil {
+		return err
+	}
+
+	// PUT replaces all commands of the application
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bot "+botToken)
+	return doRequest(http.DefaultClient, req)
+}
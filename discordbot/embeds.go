@@ -0,0 +1,172 @@
+package discordbot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/flashbots/grpcapi"
+	"github.com/metachris/flashbots/schema"
+)
+
+// Embed limits of Discord (https://discord.com/developers/docs/resources/channel#embed-object-embed-limits)
+const (
+	MaxEmbedFields           = 25
+	MaxEmbedDescriptionChars = 4096
+	MaxEmbedFieldChars       = 1024
+)
+
+// Number of failed transactions of /failedtx without limit
+var DefaultFailedTxLimit int64 = 10
+
+// Embed colors by severity
+const (
+	ColorSerious     = 0xe74c3c
+	ColorLessSerious = 0xe67e22
+	ColorOK          = 0x2ecc71
+	ColorInfo        = 0x3498db
+)
+
+type Embed struct {
+	Title       string       `json:"title,omitempty"`
+	Description string       `json:"description,omitempty"`
+	URL         string       `json:"url,omitempty"`
+	Color       int          `json:"color,omitempty"`
+	Fields      []EmbedField `json:"fields,omitempty"`
+}
+
+type EmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+func (e *Embed) addField(name string, value string, inline bool) {
+	if len(e.Fields) < MaxEmbedFields {
+		e.Fields = append(e.Fields, EmbedField{Name: name, Value: truncate(value, MaxEmbedFieldChars), Inline: inline})
+	}
+}
+
+// CheckEmbed shows the errors of a check result
+func CheckEmbed(result schema.CheckResult) Embed {
+	embed := Embed{Title: fmt.Sprintf("Block %d", result.BlockNumber), URL: common.BlockUrl(result.BlockNumber), Color: ColorOK}
+	if result.HasSeriousErrors {
+		embed.Color = ColorSerious
+	} else if result.HasLessSeriousErrors {
+		embed.Color = ColorLessSerious
+	}
+
+	if len(result.Errors) == 0 {
+		embed.Description = "No errors"
+	} else {
+		embed.Description = truncate(strings.Join(result.Errors, "\n"), MaxEmbedDescriptionChars)
+	}
+	embed.addField("Miner", minerName(result.Miner, result.MinerName), true)
+	embed.addField("Bundles", fmt.Sprint(len(result.Bundles)), true)
+	if result.TemplateSource != "" {
+		embed.addField("Template", result.TemplateSource, true)
+	}
+	if len(result.ErrorCounts) > 0 {
+		embed.addField("Error counts", formatCounts(result.ErrorCounts), false)
+	}
+	return embed
+}
+
+// MinerStatsEmbed shows the error rates of the miners (sorted by error rate), query is the miner of the command
+func MinerStatsEmbed(stats []schema.MinerStats, query string) Embed {
+	window := grpcapi.DefaultMinerStatsWindow
+	if len(stats) > 0 {
+		window = time.Duration(stats[0].WindowSec) * time.Second
+	}
+	embed := Embed{Title: fmt.Sprintf("Miner stats (%.0fh)", window.Hours()), Color: ColorInfo}
+	if len(stats) == 0 {
+		embed.Description = "No checked blocks"
+		if query != "" {
+			embed.Description += " of " + query
+		}
+		return embed
+	}
+
+	for _, minerStats := range stats {
+		value := fmt.Sprintf("%d blocks, %d with errors (%.1f%%)", minerStats.Blocks, minerStats.ErrorBlocks, minerStats.ErrorRate*100)
+		if len(minerStats.ErrorCounts) > 0 {
+			value += "\n" + formatCounts(minerStats.ErrorCounts)
+		}
+		embed.addField(minerName(minerStats.Miner, minerStats.MinerName), value, false)
+	}
+	if len(stats) > MaxEmbedFields {
+		embed.Description = fmt.Sprintf("%d miners, the %d with the highest error rate:", len(stats), MaxEmbedFields)
+	}
+	return embed
+}
+
+// FailedTxEmbed shows the failed transactions, newest first
+func FailedTxEmbed(txs []grpcapi.FailedTxRecord) Embed {
+	embed := Embed{Title: "Failed transactions", Color: ColorInfo}
+	if len(txs) == 0 {
+		embed.Description = "No failed transactions"
+		return embed
+	}
+
+	for _, tx := range txs {
+		kind := "0 gas"
+		if tx.IsFlashbots {
+			kind = "Flashbots"
+		}
+		value := fmt.Sprintf("[%s](%s) by %s\nfrom %s to %s, %d gas", shortHash(tx.Hash), common.TxUrl(tx.Hash), minerName(tx.Miner, tx.MinerName), tx.From, tx.To, tx.GasUsed)
+		if tx.RevertReason != "" {
+			value += "\nrevert: " + tx.RevertReason
+		}
+		embed.addField(fmt.Sprintf("Block %d · %s", tx.BlockNumber, kind), value, false)
+	}
+	return embed
+}
+
+func minerName(address string, name string) string {
+	if name == "" {
+		return address
+	}
+	return fmt.Sprintf("%s (%s)", name, address)
+}
+
+// formatCounts returns the counts sorted by type, eg. "failedFbTx: 2, has0fee: 1"
+func formatCounts(counts map[string]uint64) string {
+	types := make([]string, 0, len(counts))
+	for errorType, count := range counts {
+		if count > 0 {
+			types = append(types, errorType)
+		}
+	}
+	sort.Strings(types)
+	ret := make([]string, len(types))
+	for i, errorType := range types {
+		ret[i] = fmt.Sprintf("%s: %d", errorType, counts[errorType])
+	}
+	return strings.Join(ret, ", ")
+}
+
+func shortHash(hash string) string {
+	if len(hash) <= 14 {
+		return hash
+	}
+	return hash[:10] + "..." + hash[len(hash)-4:]
+}
+
+// truncate cuts the text at max. n bytes, at a line break if possible
+func truncate(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	n -= 3
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	text = text[:n]
+	if i := strings.LastIndex(text, "\n"); i > n/2 {
+		text = text[:i]
+	}
+	return text + "..."
+}
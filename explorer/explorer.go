@@ -0,0 +1,281 @@
+// Package explorer serves blocks and transactions in the format of the mev-blocks API (/v1/blocks and
+// /v1/transactions), so frontends built for it (eg. flashbots-explorer) can use block-watch as backend. Requests are
+// read through to the mev-blocks API, and the blocks and transactions are annotated with the check results.
+package explorer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/labels"
+	"github.com/metachris/flashbots/schema"
+)
+
+// CheckAnnotation are the check results of a block
+type CheckAnnotation struct {
+	HasSeriousErrors     bool              `json:"has_serious_errors"`
+	HasLessSeriousErrors bool              `json:"has_less_serious_errors"`
+	Errors               []string          `json:"errors"`
+	ErrorCounts          map[string]uint64 `json:"error_counts"`
+	TemplateSource       string            `json:"template_source,omitempty"`
+}
+
+// Transaction is a transaction of the mev-blocks API, with the check results of its bundle
+type Transaction struct {
+	api.FlashbotsTransaction
+	BundleIsOutOfOrder       bool           `json:"bundle_is_out_of_order,omitempty"`
+	BundleIsPayingLessThanTx bool           `json:"bundle_is_paying_less_than_lowest_tx,omitempty"`
+	Searcher                 string         `json:"searcher,omitempty"`  // label of the searcher, if known
+	Protocols                map[string]int `json:"protocols,omitempty"` // of the bundle
+}
+
+// Block is a block of the mev-blocks API, with the check results (Check is nil if the block wasn't checked)
+type Block struct {
+	api.FlashbotsBlock
+	MinerName    string           `json:"miner_name,omitempty"`
+	Transactions []Transaction    `json:"transactions"`
+	Check        *CheckAnnotation `json:"check,omitempty"`
+}
+
+// BlocksResponse is the response of /v1/blocks
+type BlocksResponse struct {
+	LatestBlockNumber int64   `json:"latest_block_number"`
+	Blocks            []Block `json:"blocks"`
+}
+
+// TransactionsResponse is the response of /v1/transactions
+type TransactionsResponse struct {
+	LatestBlockNumber int64         `json:"latest_block_number"`
+	Transactions      []Transaction `json:"transactions"`
+}
+
+// ResultStore finds stored check results of older blocks, eg. watcher.JSONLSink
+type ResultStore interface {
+	Find(block int64) ([]schema.CheckResult, []schema.Incident, error)
+}
+
+// Default number of recently checked blocks kept in memory
+var DefaultMaxBlocks = 1000
+
+// Server keeps the recently checked blocks, and serves the annotated blocks and transactions. It's a
+// watcher.CheckSink, and safe for concurrent use.
+type Server struct {
+	Client    *api.Client // the mev-blocks API
+	Results   ResultStore // optional: check results of blocks which are no longer in memory (single-block requests only)
+	MaxBlocks int
+
+	lock    sync.Mutex
+	results map[int64]schema.CheckResult
+	blocks  map[int64]api.FlashbotsBlock // API data of the checked blocks
+	order   []int64                      // block numbers in the order they were added
+}
+
+func NewServer(client *api.Client) *Server {
+	return &Server{
+		Client:    client,
+		MaxBlocks: DefaultMaxBlocks,
+		results:   make(map[int64]schema.CheckResult),
+		blocks:    make(map[int64]api.FlashbotsBlock),
+	}
+}
+
+// SaveCheck keeps the check results and API data of a checked block
+func (s *Server) SaveCheck(check *blockcheck.BlockCheck) error {
+	var fbBlock *api.FlashbotsBlock
+	if check.FlashbotsApiBlock != nil && check.FlashbotsApiBlock.BlockNumber == check.Number {
+		fbBlock = check.FlashbotsApiBlock
+	}
+	s.add(schema.NewCheckResult(check), fbBlock)
+	return nil
+}
+
+func (s *Server) add(result schema.CheckResult, fbBlock *api.FlashbotsBlock) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, found := s.results[result.BlockNumber]; !found {
+		s.order = append(s.order, result.BlockNumber)
+	}
+	s.results[result.BlockNumber] = result // a re-check replaces the result
+	if fbBlock != nil {
+		s.blocks[result.BlockNumber] = *fbBlock
+	}
+
+	for s.MaxBlocks > 0 && len(s.order) > s.MaxBlocks {
+		delete(s.results, s.order[0])
+		delete(s.blocks, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// result returns the check result of the block, from memory or (if readStore is set) from Results
+func (s *Server) result(number int64, readStore bool) (result schema.CheckResult, found bool) {
+	s.lock.Lock()
+	result, found = s.results[number]
+	s.lock.Unlock()
+	if found || !readStore || s.Results == nil {
+		return result, found
+	}
+
+	results, _, err := s.Results.Find(number)
+	if err != nil || len(results) == 0 {
+		return result, false
+	}
+	return results[len(results)-1], true // the latest check of the block
+}
+
+// annotateTx adds the check results of the bundle of the transaction
+func annotateTx(tx api.FlashbotsTransaction, result *schema.CheckResult) Transaction {
+	ret := Transaction{FlashbotsTransaction: tx}
+	ret.EoaAddress = labels.SearcherDisclosure.Address(tx.EoaAddress)
+	if result == nil {
+		return ret
+	}
+	for _, bundle := range result.Bundles {
+		for _, hash := range bundle.TxHashes {
+			if hash == tx.Hash {
+				ret.BundleIsOutOfOrder = bundle.IsOutOfOrder
+				ret.BundleIsPayingLessThanTx = bundle.IsPayingLessThanLowestTx
+				ret.Searcher = bundle.Searcher
+				ret.Protocols = bundle.Protocols
+				return ret
+			}
+		}
+	}
+	return ret
+}
+
+func annotateBlock(fbBlock api.FlashbotsBlock, result *schema.CheckResult) Block {
+	block := Block{FlashbotsBlock: fbBlock, Transactions: make([]Transaction, 0, len(fbBlock.Transactions))}
+	for _, tx := range fbBlock.Transactions {
+		block.Transactions = append(block.Transactions, annotateTx(tx, result))
+	}
+	if result != nil {
+		block.MinerName = result.MinerName
+		block.Check = &CheckAnnotation{
+			HasSeriousErrors:     result.HasSeriousErrors,
+			HasLessSeriousErrors: result.HasLessSeriousErrors,
+			Errors:               result.Errors,
+			ErrorCounts:          result.ErrorCounts,
+			TemplateSource:       result.TemplateSource,
+		}
+	}
+	return block
+}
+
+// Blocks returns the annotated blocks. A single block which was checked recently is served from memory, everything
+// else is read through to the mev-blocks API.
+func (s *Server) Blocks(ctx context.Context, options *api.GetBlocksOptions) (response BlocksResponse, err error) {
+	singleBlock := options != nil && options.BlockNumber > 0 && options.Miner == "" && options.From == "" && options.Before == 0
+	if singleBlock {
+		s.lock.Lock()
+		fbBlock, found := s.blocks[options.BlockNumber]
+		result := s.results[options.BlockNumber]
+		latest := int64(0)
+		for _, number := range s.order {
+			if number > latest {
+				latest = number
+			}
+		}
+		s.lock.Unlock()
+		if found {
+			return BlocksResponse{LatestBlockNumber: latest, Blocks: []Block{annotateBlock(fbBlock, &result)}}, nil
+		}
+	}
+
+	upstream, err := s.Client.GetBlocks(ctx, options)
+	if err != nil {
+		return response, err
+	}
+	response = BlocksResponse{LatestBlockNumber: upstream.LatestBlockNumber, Blocks: make([]Block, 0, len(upstream.Blocks))}
+	for _, fbBlock := range upstream.Blocks {
+		var resultPtr *schema.CheckResult
+		if result, found := s.result(fbBlock.BlockNumber, singleBlock); found {
+			resultPtr = &result
+		}
+		response.Blocks = append(response.Blocks, annotateBlock(fbBlock, resultPtr))
+	}
+	return response, nil
+}
+
+// Transactions returns the annotated transactions, read through to the mev-blocks API
+func (s *Server) Transactions(ctx context.Context, options *api.GetTransactionsOptions) (response TransactionsResponse, err error) {
+	upstream, err := s.Client.GetTransactions(ctx, options)
+	if err != nil {
+		return response, err
+	}
+	response = TransactionsResponse{LatestBlockNumber: upstream.LatestBlockNumber, Transactions: make([]Transaction, 0, len(upstream.Transactions))}
+	for _, tx := range upstream.Transactions {
+		var resultPtr *schema.CheckResult
+		if result, found := s.result(tx.BlockNumber, false); found {
+			resultPtr = &result
+		}
+		response.Transactions = append(response.Transactions, annotateTx(tx, resultPtr))
+	}
+	return response, nil
+}
+
+// Handler serves /v1/blocks (query parameters block_number, miner, from, before, limit) and /v1/transactions (before,
+// limit), like the mev-blocks API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/blocks", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		options := &api.GetBlocksOptions{Miner: query.Get("miner"), From: query.Get("from")}
+		var err error
+		if options.BlockNumber, err = intParam(query.Get("block_number")); err != nil {
+			http.Error(w, "invalid block_number", http.StatusBadRequest)
+			return
+		}
+		if options.Before, err = intParam(query.Get("before")); err != nil {
+			http.Error(w, "invalid before", http.StatusBadRequest)
+			return
+		}
+		if options.Limit, err = intParam(query.Get("limit")); err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+
+		response, err := s.Blocks(r.Context(), options)
+		writeResponse(w, response, err)
+	})
+	mux.HandleFunc("/v1/transactions", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		options := &api.GetTransactionsOptions{}
+		var err error
+		if options.Before, err = intParam(query.Get("before")); err != nil {
+			http.Error(w, "invalid before", http.StatusBadRequest)
+			return
+		}
+		if options.Limit, err = intParam(query.Get("limit")); err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+
+		response, err := s.Transactions(r.Context(), options)
+		writeResponse(w, response, err)
+	})
+	return mux
+}
+
+func intParam(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func writeResponse(w http.ResponseWriter, response interface{}, err error) {
+	if err != nil {
+		http.Error(w, "mev-blocks API: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*") // explorer frontends are served from other origins
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,83 @@
+package explorer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/schema"
+)
+
+func TestServer(t *testing.T) {
+	upstreamRequests := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamRequests += 1
+		if r.URL.Path == "/v1/transactions" {
+			fmt.Fprint(w, `{"latest_block_number": 102, "transactions": [{"transaction_hash": "0x1", "block_number": 101}, {"transaction_hash": "0x2", "block_number": 102}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"latest_block_number": 102, "blocks": [{"block_number": 102, "transactions": [{"transaction_hash": "0x2", "block_number": 102}]}, {"block_number": 101, "transactions": [{"transaction_hash": "0x1", "block_number": 101}]}]}`)
+	}))
+	defer upstream.Close()
+
+	client := api.NewClient()
+	client.BaseUrl = upstream.URL
+	client.MaxAttempts = 1
+	s := NewServer(client)
+	s.MaxBlocks = 2
+
+	result := schema.CheckResult{BlockNumber: 101, MinerName: "Miner", HasSeriousErrors: true, Errors: []string{"bundle 0 pays more"}, Bundles: []schema.Bundle{
+		{Index: 0, TxHashes: []string{"0x1"}, IsOutOfOrder: true, Searcher: "Searcher"},
+	}}
+	s.add(result, &api.FlashbotsBlock{BlockNumber: 101, Transactions: []api.FlashbotsTransaction{{Hash: "0x1", BlockNumber: 101}}})
+
+	get := func(url string, response interface{}) {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatal("unexpected status", rec.Code, rec.Body.String())
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), response); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A recently checked block is served from memory
+	var blocks BlocksResponse
+	get("/v1/blocks?block_number=101", &blocks)
+	if upstreamRequests != 0 || len(blocks.Blocks) != 1 || blocks.Blocks[0].Check == nil || !blocks.Blocks[0].Check.HasSeriousErrors || blocks.Blocks[0].MinerName != "Miner" {
+		t.Fatalf("unexpected response %+v", blocks)
+	}
+	if tx := blocks.Blocks[0].Transactions[0]; tx.Hash != "0x1" || !tx.BundleIsOutOfOrder || tx.Searcher != "Searcher" {
+		t.Errorf("unexpected tx %+v", tx)
+	}
+
+	// Lists are read through, only checked blocks are annotated
+	blocks = BlocksResponse{}
+	get("/v1/blocks?limit=2", &blocks)
+	if upstreamRequests != 1 || len(blocks.Blocks) != 2 || blocks.Blocks[0].Check != nil || blocks.Blocks[1].Check == nil || blocks.LatestBlockNumber != 102 {
+		t.Fatalf("unexpected response %+v", blocks)
+	}
+
+	var txs TransactionsResponse
+	get("/v1/transactions", &txs)
+	if len(txs.Transactions) != 2 || !txs.Transactions[0].BundleIsOutOfOrder || txs.Transactions[1].BundleIsOutOfOrder {
+		t.Fatalf("unexpected response %+v", txs)
+	}
+
+	// Only MaxBlocks blocks are kept
+	s.add(schema.CheckResult{BlockNumber: 102}, nil)
+	s.add(schema.CheckResult{BlockNumber: 103}, nil)
+	if _, found := s.result(101, true); found {
+		t.Error("expected block 101 to be dropped")
+	}
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/blocks?limit=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Error("expected 400 for an invalid limit, got", rec.Code)
+	}
+}
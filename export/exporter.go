@@ -0,0 +1,190 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/common"
+)
+
+// Columns of the exported tables: one row per check, and one row per bundle
+var (
+	CheckColumns = []Column{
+		{"block_number", ColumnInt64},
+		{"block_time", ColumnTimestamp},
+		{"block_hash", ColumnString},
+		{"miner", ColumnString},
+		{"miner_name", ColumnString},
+		{"coinbase", ColumnString}, // differs from miner for payment splitters
+		{"num_tx", ColumnInt64},
+		{"num_bundles", ColumnInt64},
+		{"num_errors", ColumnInt64},
+		{"error_types", ColumnString}, // comma-separated, sorted
+		{"has_serious_errors", ColumnBool},
+		{"has_less_serious_errors", ColumnBool},
+		{"failed_tx_cost_eth", ColumnFloat64},
+		{"template_source", ColumnString}, // empty for blocks without bundles
+		{"input_hash", ColumnString},
+		{"output_hash", ColumnString},
+	}
+
+	BundleColumns = []Column{
+		{"block_number", ColumnInt64},
+		{"block_time", ColumnTimestamp},
+		{"miner", ColumnString},
+		{"miner_name", ColumnString},
+		{"bundle_index", ColumnInt64},
+		{"bundle_hash", ColumnString},
+		{"bundle_type", ColumnString},
+		{"group_index", ColumnInt64},
+		{"searcher", ColumnString},
+		{"protocols", ColumnString},
+		{"num_tx", ColumnInt64},
+		{"total_miner_reward_eth", ColumnFloat64},
+		{"total_coinbase_transfer_eth", ColumnFloat64},
+		{"total_gas_used", ColumnInt64},
+		{"reward_div_gas_used_gwei", ColumnFloat64},
+		{"gas_fees_eth", ColumnFloat64},
+		{"coinbase_share", ColumnFloat64},
+		{"tail_gas_price_gwei", ColumnFloat64},
+		{"tail_gas_price_margin", ColumnFloat64}, // % above the tail gas price, 0 if unknown
+		{"is_out_of_order", ColumnBool},
+		{"is_paying_less_than_lowest_tx", ColumnBool},
+		{"fee_percentile", ColumnFloat64},
+	}
+)
+
+// Exporter writes the checks (CheckColumns) and their bundles (BundleColumns) as Parquet files, partitioned by the
+// UTC date of the blocks:
+//
+//	<dir>/checks/date=2021-10-16/part-13430000-13436500.parquet
+//	<dir>/bundles/date=2021-10-16/part-13430000-13436500.parquet
+//
+// The rows are buffered and written when the date changes, when MaxRows checks are buffered, and on Flush and Close.
+// It's a watcher.CheckSink, and safe for concurrent use.
+type Exporter struct {
+	Dir     string
+	MaxRows int // checks per file
+
+	lock               sync.Mutex
+	date               string // of the buffered rows
+	checks             *Table
+	bundles            *Table
+	minBlock, maxBlock int64
+
+	NumFiles uint64 // written files, of both tables
+}
+
+func NewExporter(dir string) *Exporter {
+	return &Exporter{Dir: dir, MaxRows: 10000}
+}
+
+// SaveCheck adds the rows of the check
+func (e *Exporter) SaveCheck(check *blockcheck.BlockCheck) error {
+	blockTime := time.Unix(int64(check.EthBlock.Time()), 0).UTC()
+	date := blockTime.Format("2006-01-02")
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.checks != nil && (date != e.date || e.checks.Len() >= e.MaxRows) {
+		if err := e.flush(); err != nil {
+			return err
+		}
+	}
+	if e.checks == nil {
+		e.date = date
+		e.checks = NewTable(CheckColumns...)
+		e.bundles = NewTable(BundleColumns...)
+		e.minBlock, e.maxBlock = check.Number, check.Number
+	}
+	if check.Number < e.minBlock {
+		e.minBlock = check.Number
+	}
+	if check.Number > e.maxBlock {
+		e.maxBlock = check.Number
+	}
+
+	errorTypes := check.ErrorCounter.Types()
+	sort.Strings(errorTypes)
+	err := e.checks.Append(check.Number, blockTime, check.EthBlock.Hash().Hex(), check.Miner, check.MinerName, check.Coinbase,
+		int64(len(check.EthBlock.Transactions())), int64(len(check.Bundles)), int64(len(check.Errors)),
+		strings.Join(errorTypes, ","), check.HasSeriousErrors(), check.HasLessSeriousErrors(),
+		common.WeiToEthFloat64(check.FailedTxCost()), check.TemplateSource, check.InputHash(), check.OutputHash())
+	if err != nil {
+		return err
+	}
+
+	for _, bundle := range check.Bundles {
+		margin, _ := bundle.TailGasPriceMargin()
+		err = e.bundles.Append(check.Number, blockTime, check.Miner, check.MinerName, bundle.Index, bundle.Hash,
+			bundle.BundleType, int64(bundle.GroupIndex), bundle.SearcherName, bundle.ProtocolsString(),
+			int64(len(bundle.Transactions)), common.WeiToEthFloat64(bundle.TotalMinerReward), common.WeiToEthFloat64(bundle.TotalCoinbaseTransfer),
+			bundle.TotalGasUsed.Int64(), common.WeiToGweiFloat64(bundle.RewardDivGasUsed), common.WeiToEthFloat64(bundle.GasFees()),
+			bundle.CoinbaseShare(), common.WeiToGweiFloat64(bundle.TailGasPrice), margin, bundle.IsOutOfOrder,
+			bundle.IsPayingLessThanLowestTx, bundle.FeePercentile)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes the buffered rows
+func (e *Exporter) Flush() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.flush()
+}
+
+func (e *Exporter) flush() error {
+	if e.checks == nil {
+		return nil
+	}
+
+	filename := fmt.Sprintf("part-%d-%d.parquet", e.minBlock, e.maxBlock)
+	if err := e.writeTable("checks", filename, e.checks); err != nil {
+		return err
+	}
+	if e.bundles.Len() > 0 {
+		if err := e.writeTable("bundles", filename, e.bundles); err != nil {
+			return err
+		}
+	}
+	e.checks, e.bundles = nil, nil
+	return nil
+}
+
+// writeTable writes to a temporary file first, so readers never see partial files
+func (e *Exporter) writeTable(table string, filename string, t *Table) error {
+	dir := filepath.Join(e.Dir, table, "date="+e.date)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, filename)
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err = t.WriteParquet(f); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	e.NumFiles += 1
+	return os.Rename(path+".tmp", path)
+}
+
+// Close writes the buffered rows
+func (e *Exporter) Close() error {
+	return e.Flush()
+}
@@ -0,0 +1,303 @@
+// Package export writes check results as Parquet files for data-science tools (pandas, DuckDB, Spark), see Exporter.
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Column types of a Table
+const (
+	ColumnInt64 = iota
+	ColumnFloat64
+	ColumnString
+	ColumnBool
+	ColumnTimestamp // time.Time, stored in milliseconds (UTC)
+)
+
+type Column struct {
+	Name string
+	Type int
+}
+
+// Table collects rows column by column, and writes them as a Parquet file (see WriteParquet). It only supports the
+// flat required columns of the ColumnTypes, which is enough for the exported rows, and doesn't need a Parquet library.
+type Table struct {
+	Columns []Column
+	values  [][]interface{} // by column
+	numRows int
+}
+
+func NewTable(columns ...Column) *Table {
+	return &Table{Columns: columns, values: make([][]interface{}, len(columns))}
+}
+
+// Append adds a row, with one value per column of the column's type
+func (t *Table) Append(values ...interface{}) error {
+	if len(values) != len(t.Columns) {
+		return fmt.Errorf("expected %d values, got %d", len(t.Columns), len(values))
+	}
+	for i, value := range values {
+		ok := false
+		switch t.Columns[i].Type {
+		case ColumnInt64:
+			_, ok = value.(int64)
+		case ColumnFloat64:
+			_, ok = value.(float64)
+		case ColumnString:
+			_, ok = value.(string)
+		case ColumnBool:
+			_, ok = value.(bool)
+		case ColumnTimestamp:
+			_, ok = value.(time.Time)
+		}
+		if !ok {
+			return fmt.Errorf("invalid value %v (%T) for column %s", value, value, t.Columns[i].Name)
+		}
+	}
+
+	for i, value := range values {
+		t.values[i] = append(t.values[i], value)
+	}
+	t.numRows += 1
+	return nil
+}
+
+func (t *Table) Len() int {
+	return t.numRows
+}
+
+// Parquet format constants (see https://github.com/apache/parquet-format/blob/master/src/main/thrift/parquet.thrift)
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired        = 0
+	parquetUtf8            = 0 // converted types
+	parquetTimestampMillis = 9
+	parquetPlain           = 0 // encodings
+	parquetRle             = 3
+	parquetUncompressed    = 0
+	parquetDataPage        = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+// WriteParquet writes the table as Parquet file: one row group with one uncompressed, plain-encoded data page per
+// column
+func (t *Table) WriteParquet(w io.Writer) error {
+	file := bytes.NewBuffer(nil)
+	file.Write(parquetMagic)
+
+	chunks := make([]*thriftStruct, len(t.Columns))
+	var totalSize int64
+	for i, column := range t.Columns {
+		page := t.encodeColumn(i)
+		header := newThriftStruct().
+			i32(1, parquetDataPage).
+			i32(2, int32(len(page))).
+			i32(3, int32(len(page))).
+			structField(5, newThriftStruct().
+				i32(1, int32(t.numRows)).
+				i32(2, parquetPlain).
+				i32(3, parquetRle).
+				i32(4, parquetRle))
+
+		offset := int64(file.Len())
+		file.Write(header.bytes())
+		file.Write(page)
+		size := int64(file.Len()) - offset
+		totalSize += size
+
+		metadata := newThriftStruct().
+			i32(1, column.parquetType()).
+			i32List(2, parquetPlain, parquetRle).
+			stringList(3, column.Name).
+			i32(4, parquetUncompressed).
+			i64(5, int64(t.numRows)).
+			i64(6, size).
+			i64(7, size).
+			i64(9, offset)
+		chunks[i] = newThriftStruct().i64(2, offset).structField(3, metadata)
+	}
+
+	schema := []*thriftStruct{newThriftStruct().binary(4, "schema").i32(5, int32(len(t.Columns)))}
+	for _, column := range t.Columns {
+		element := newThriftStruct().i32(1, column.parquetType()).i32(3, parquetRequired).binary(4, column.Name)
+		switch column.Type {
+		case ColumnString:
+			element.i32(6, parquetUtf8)
+		case ColumnTimestamp:
+			element.i32(6, parquetTimestampMillis)
+		}
+		schema = append(schema, element)
+	}
+
+	rowGroup := newThriftStruct().structList(1, chunks...).i64(2, totalSize).i64(3, int64(t.numRows))
+	footer := newThriftStruct().
+		i32(1, 1).
+		structList(2, schema...).
+		i64(3, int64(t.numRows)).
+		structList(4, rowGroup).
+		binary(6, "github.com/metachris/flashbots").
+		bytes()
+
+	file.Write(footer)
+	binary.Write(file, binary.LittleEndian, uint32(len(footer)))
+	file.Write(parquetMagic)
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+func (c Column) parquetType() int32 {
+	switch c.Type {
+	case ColumnFloat64:
+		return parquetDouble
+	case ColumnString:
+		return parquetByteArray
+	case ColumnBool:
+		return parquetBoolean
+	}
+	return parquetInt64
+}
+
+// encodeColumn returns the plain-encoded values of the column (required columns have no definition levels)
+func (t *Table) encodeColumn(i int) []byte {
+	buf := bytes.NewBuffer(nil)
+	if t.Columns[i].Type == ColumnBool { // bit-packed, least significant bit first
+		packed := make([]byte, (t.numRows+7)/8)
+		for row, value := range t.values[i] {
+			if value.(bool) {
+				packed[row/8] |= 1 << (row % 8)
+			}
+		}
+		return packed
+	}
+
+	for _, value := range t.values[i] {
+		switch v := value.(type) {
+		case int64:
+			binary.Write(buf, binary.LittleEndian, v)
+		case float64:
+			binary.Write(buf, binary.LittleEndian, math.Float64bits(v))
+		case string:
+			binary.Write(buf, binary.LittleEndian, uint32(len(v)))
+			buf.WriteString(v)
+		case time.Time:
+			binary.Write(buf, binary.LittleEndian, v.UnixNano()/int64(time.Millisecond))
+		}
+	}
+	return buf.Bytes()
+}
+
+// Thrift compact protocol types
+const (
+	thriftTypeI32    = 5
+	thriftTypeI64    = 6
+	thriftTypeBinary = 8
+	thriftTypeList   = 9
+	thriftTypeStruct = 12
+)
+
+// thriftStruct encodes a struct with the thrift compact protocol, fields in ascending order
+type thriftStruct struct {
+	buf       bytes.Buffer
+	lastField int16
+}
+
+func newThriftStruct() *thriftStruct {
+	return &thriftStruct{}
+}
+
+func (s *thriftStruct) fieldHeader(id int16, fieldType byte) {
+	if delta := id - s.lastField; delta > 0 && delta <= 15 {
+		s.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		s.buf.WriteByte(fieldType)
+		s.varint(int64(id))
+	}
+	s.lastField = id
+}
+
+// varint writes a zigzag varint
+func (s *thriftStruct) varint(v int64) {
+	s.uvarint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (s *thriftStruct) uvarint(v uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	s.buf.Write(buf[:binary.PutUvarint(buf, v)])
+}
+
+func (s *thriftStruct) listHeader(size int, elementType byte) {
+	if size < 15 {
+		s.buf.WriteByte(byte(size)<<4 | elementType)
+	} else {
+		s.buf.WriteByte(0xf0 | elementType)
+		s.uvarint(uint64(size))
+	}
+}
+
+func (s *thriftStruct) i32(id int16, v int32) *thriftStruct {
+	s.fieldHeader(id, thriftTypeI32)
+	s.varint(int64(v))
+	return s
+}
+
+func (s *thriftStruct) i64(id int16, v int64) *thriftStruct {
+	s.fieldHeader(id, thriftTypeI64)
+	s.varint(v)
+	return s
+}
+
+func (s *thriftStruct) binary(id int16, v string) *thriftStruct {
+	s.fieldHeader(id, thriftTypeBinary)
+	s.uvarint(uint64(len(v)))
+	s.buf.WriteString(v)
+	return s
+}
+
+func (s *thriftStruct) i32List(id int16, values ...int32) *thriftStruct {
+	s.fieldHeader(id, thriftTypeList)
+	s.listHeader(len(values), thriftTypeI32)
+	for _, v := range values {
+		s.varint(int64(v))
+	}
+	return s
+}
+
+func (s *thriftStruct) stringList(id int16, values ...string) *thriftStruct {
+	s.fieldHeader(id, thriftTypeList)
+	s.listHeader(len(values), thriftTypeBinary)
+	for _, v := range values {
+		s.uvarint(uint64(len(v)))
+		s.buf.WriteString(v)
+	}
+	return s
+}
+
+func (s *thriftStruct) structField(id int16, v *thriftStruct) *thriftStruct {
+	s.fieldHeader(id, thriftTypeStruct)
+	s.buf.Write(v.bytes())
+	return s
+}
+
+func (s *thriftStruct) structList(id int16, values ...*thriftStruct) *thriftStruct {
+	s.fieldHeader(id, thriftTypeList)
+	s.listHeader(len(values), thriftTypeStruct)
+	for _, v := range values {
+		s.buf.Write(v.bytes())
+	}
+	return s
+}
+
+// bytes returns the encoded struct, with the stop field
+func (s *thriftStruct) bytes() []byte {
+	return append(append([]byte{}, s.buf.Bytes()...), 0)
+}
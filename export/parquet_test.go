@@ -0,0 +1,128 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+// thriftReader decodes the thrift compact protocol into maps of field id -> value, to check the Parquet metadata
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(fieldType byte) interface{} {
+	switch fieldType {
+	case thriftTypeI32, thriftTypeI64:
+		return r.varint()
+	case thriftTypeBinary:
+		n := int(r.uvarint())
+		r.pos += n
+		return string(r.data[r.pos-n : r.pos])
+	case thriftTypeList:
+		header := r.data[r.pos]
+		r.pos += 1
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(header & 0x0f)
+		}
+		return list
+	case thriftTypeStruct:
+		return r.structValue()
+	}
+	panic("unsupported thrift type")
+}
+
+func (r *thriftReader) structValue() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var lastField int16
+	for {
+		header := r.data[r.pos]
+		r.pos += 1
+		if header == 0 {
+			return fields
+		}
+		id := lastField + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.varint())
+		}
+		fields[id] = r.value(header & 0x0f)
+		lastField = id
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	table := NewTable(Column{"number", ColumnInt64}, Column{"name", ColumnString}, Column{"ok", ColumnBool}, Column{"value", ColumnFloat64}, Column{"time", ColumnTimestamp})
+	blockTime := time.Date(2021, 10, 16, 12, 0, 0, 0, time.UTC)
+	for i := int64(0); i < 20; i++ {
+		if err := table.Append(i*1000, "block", i%3 == 0, float64(i)/2, blockTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := table.Append(int64(1), 2, true, 0.5, blockTime); err == nil {
+		t.Error("expected an error for a value of the wrong type")
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := table.WriteParquet(buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, parquetMagic) || !bytes.HasSuffix(data, parquetMagic) {
+		t.Fatal("missing magic bytes")
+	}
+
+	footerLength := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{data: data[len(data)-8-footerLength : len(data)-8]}
+	metadata := footer.structValue()
+	if footer.pos != footerLength {
+		t.Error("footer not read completely", footer.pos, footerLength)
+	}
+	if metadata[1] != int64(1) || metadata[3] != int64(20) {
+		t.Error("unexpected version or number of rows", metadata[1], metadata[3])
+	}
+
+	schema := metadata[2].([]interface{})
+	if len(schema) != 6 || schema[0].(map[int16]interface{})[5] != int64(5) || schema[2].(map[int16]interface{})[4] != "name" {
+		t.Error("unexpected schema", schema)
+	}
+
+	// Read the values of the int64, bool and double columns with the offsets of the metadata
+	columns := metadata[4].([]interface{})[0].(map[int16]interface{})[1].([]interface{})
+	pageValues := func(column int) []byte {
+		offset := columns[column].(map[int16]interface{})[3].(map[int16]interface{})[9].(int64)
+		page := &thriftReader{data: data, pos: int(offset)}
+		header := page.structValue()
+		return data[page.pos : page.pos+int(header[2].(int64))]
+	}
+	if values := pageValues(0); binary.LittleEndian.Uint64(values[8*7:]) != 7000 {
+		t.Error("unexpected int64 value", values[8*7:8*8])
+	}
+	if values := pageValues(2); len(values) != 3 || values[0] != 0b01001001 {
+		t.Errorf("unexpected bool values %08b", values)
+	}
+	if values := pageValues(3); math.Float64frombits(binary.LittleEndian.Uint64(values[8*3:])) != 1.5 {
+		t.Error("unexpected double value")
+	}
+	if values := pageValues(4); int64(binary.LittleEndian.Uint64(values)) != blockTime.UnixNano()/1e6 {
+		t.Error("unexpected timestamp value")
+	}
+}
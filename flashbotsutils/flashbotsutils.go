@@ -5,27 +5,16 @@ import (
 
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/common"
 )
 
 var (
 	ErrFlashbotsApiDoesntHaveThatBlockYet = errors.New("flashbots API latest height < requested block height")
 )
 
-// Cache for last Flashbots API call (avoids calling multiple times per block)
-type FlashbotsApiReqRes struct {
-	RequestBlock int64
-	Response     api.GetBlocksResponse
-}
-
-var flashbotsApiResponseCache FlashbotsApiReqRes
-
-// IsFlashbotsTx is a utility for confirming if a specific transactions is actually a Flashbots one
+// IsFlashbotsTx is a utility for confirming if a specific transactions is actually a Flashbots one. Repeated calls
+// for the same block are served from the API cache (see api.DefaultClient).
 func IsFlashbotsTx(block *types.Block, tx *types.Transaction) (isFlashbotsTx bool, response api.GetBlocksResponse, err error) {
-	if flashbotsApiResponseCache.RequestBlock == block.Number().Int64() {
-		isFlashbotsTx = flashbotsApiResponseCache.Response.HasTx(tx.Hash().String())
-		return isFlashbotsTx, flashbotsApiResponseCache.Response, nil
-	}
-
 	opts := api.GetBlocksOptions{BlockNumber: block.Number().Int64()}
 	flashbotsResponse, err := api.GetBlocks(&opts)
 	if err != nil {
@@ -36,10 +25,13 @@ func IsFlashbotsTx(block *types.Block, tx *types.Transaction) (isFlashbotsTx boo
 		return isFlashbotsTx, flashbotsResponse, ErrFlashbotsApiDoesntHaveThatBlockYet
 	}
 
-	flashbotsApiResponseCache.RequestBlock = block.Number().Int64()
-	flashbotsApiResponseCache.Response = flashbotsResponse
-
 	flashbotsTx := flashbotsResponse.GetTxMap()
 	_, exists := flashbotsTx[tx.Hash().String()]
 	return exists, flashbotsResponse, nil
 }
+
+// IsFlashbotsLikeTx is a heuristic that doesn't need the Flashbots API: returns true if the tx pays the miner via
+// a coinbase transfer instead of the gas price (0-gas tx before London, 0 priority fee after London).
+func IsFlashbotsLikeTx(block *types.Block, tx *types.Transaction) bool {
+	return common.IsFlashbotsLikeTx(tx, block, nil)
+}
@@ -0,0 +1,296 @@
+package grpcapi
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/metachris/flashbots/schema"
+	"github.com/metachris/flashbots/watcher"
+)
+
+// Minimal protobuf encoding of the messages in blockwatch.proto (proto3: fields with default values are omitted), so
+// no protobuf library and generated code are needed.
+
+const (
+	wireVarint = 0
+	wire64Bit  = 1
+	wireBytes  = 2
+	wire32Bit  = 5
+)
+
+var errInvalidMessage = errors.New("invalid protobuf message")
+
+type encoder struct {
+	buf []byte
+}
+
+func appendUvarint(buf []byte, value uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], value)
+	return append(buf, b[:n]...)
+}
+
+func (e *encoder) tag(field int, wireType int) {
+	e.buf = appendUvarint(e.buf, uint64(field)<<3|uint64(wireType))
+}
+
+func (e *encoder) uint(field int, value uint64) {
+	if value == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.buf = appendUvarint(e.buf, value)
+}
+
+func (e *encoder) int(field int, value int64) {
+	e.uint(field, uint64(value))
+}
+
+func (e *encoder) bool(field int, value bool) {
+	if value {
+		e.uint(field, 1)
+	}
+}
+
+func (e *encoder) double(field int, value float64) {
+	if value == 0 {
+		return
+	}
+	e.tag(field, wire64Bit)
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(value))
+	e.buf = append(e.buf, b[:]...)
+}
+
+func (e *encoder) bytes(field int, value []byte) {
+	e.tag(field, wireBytes)
+	e.buf = appendUvarint(e.buf, uint64(len(value)))
+	e.buf = append(e.buf, value...)
+}
+
+func (e *encoder) string(field int, value string) {
+	if value != "" {
+		e.bytes(field, []byte(value))
+	}
+}
+
+func (e *encoder) strings(field int, values []string) {
+	for _, value := range values {
+		e.bytes(field, []byte(value)) // empty entries are kept
+	}
+}
+
+// counts encodes a map<string, uint64>, sorted by key so the encoding is deterministic
+func (e *encoder) counts(field int, counts map[string]uint64) {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var entry encoder
+		entry.string(1, key)
+		entry.uint(2, counts[key])
+		e.bytes(field, entry.buf)
+	}
+}
+
+// decodeFields calls fn for every field of the message, with the varint or fixed value, or the bytes of
+// length-delimited fields
+func decodeFields(data []byte, fn func(field int, value uint64, bytes []byte) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errInvalidMessage
+		}
+		data = data[n:]
+
+		var value uint64
+		var bytes []byte
+		switch tag & 7 {
+		case wireVarint:
+			value, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errInvalidMessage
+			}
+			data = data[n:]
+		case wire64Bit:
+			if len(data) < 8 {
+				return errInvalidMessage
+			}
+			value, data = binary.LittleEndian.Uint64(data), data[8:]
+		case wire32Bit:
+			if len(data) < 4 {
+				return errInvalidMessage
+			}
+			value, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return errInvalidMessage
+			}
+			bytes, data = data[n:n+int(length)], data[n+int(length):]
+		default:
+			return errInvalidMessage
+		}
+
+		if err := fn(int(tag>>3), value, bytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type CheckBlockRequest struct {
+	BlockNumber int64
+	Recheck     bool
+}
+
+func (r *CheckBlockRequest) unmarshal(data []byte) error {
+	return decodeFields(data, func(field int, value uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			r.BlockNumber = int64(value)
+		case 2:
+			r.Recheck = value != 0
+		}
+		return nil
+	})
+}
+
+type GetMinerStatsRequest struct {
+	WindowSec int64
+	Miner     string
+}
+
+func (r *GetMinerStatsRequest) unmarshal(data []byte) error {
+	return decodeFields(data, func(field int, value uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			r.WindowSec = int64(value)
+		case 2:
+			r.Miner = string(bytes)
+		}
+		return nil
+	})
+}
+
+type GetFailedTxHistoryRequest struct {
+	Miner     string
+	Address   string
+	FromBlock int64
+	Limit     int64
+}
+
+func (r *GetFailedTxHistoryRequest) unmarshal(data []byte) error {
+	return decodeFields(data, func(field int, value uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			r.Miner = string(bytes)
+		case 2:
+			r.Address = string(bytes)
+		case 3:
+			r.FromBlock = int64(value)
+		case 4:
+			r.Limit = int64(value)
+		}
+		return nil
+	})
+}
+
+// unmarshalStreamChecksRequest returns the filter of a StreamChecksRequest (nil if no field is set)
+func unmarshalStreamChecksRequest(data []byte) (*watcher.Filter, error) {
+	filter := &watcher.Filter{}
+	err := decodeFields(data, func(field int, value uint64, bytes []byte) error {
+		switch field {
+		case 1:
+			filter.Miners = append(filter.Miners, string(bytes))
+		case 2:
+			filter.ErrorTypes = append(filter.ErrorTypes, string(bytes))
+		case 3:
+			filter.MinSeverity = string(bytes)
+		case 4:
+			filter.Searchers = append(filter.Searchers, string(bytes))
+		}
+		return nil
+	})
+	if err != nil || (len(filter.Miners) == 0 && len(filter.ErrorTypes) == 0 && filter.MinSeverity == "" && len(filter.Searchers) == 0) {
+		return nil, err
+	}
+	return filter, nil
+}
+
+func marshalBundle(bundle schema.Bundle) []byte {
+	var e encoder
+	e.int(1, bundle.Index)
+	e.string(2, bundle.Hash)
+	e.string(3, bundle.BundleType)
+	e.strings(4, bundle.TxHashes)
+	e.string(5, bundle.TotalMinerReward)
+	e.string(6, bundle.TotalCoinbaseTransfer)
+	e.string(7, bundle.TotalGasUsed)
+	e.string(8, bundle.RewardDivGasUsed)
+	e.bool(9, bundle.IsOutOfOrder)
+	e.bool(10, bundle.IsPayingLessThanLowestTx)
+	e.string(11, bundle.Searcher)
+	return e.buf
+}
+
+func marshalCheckResult(result schema.CheckResult) []byte {
+	var e encoder
+	e.string(1, result.SchemaVersion)
+	e.int(2, result.BlockNumber)
+	e.string(3, result.BlockHash)
+	e.string(4, result.Miner)
+	e.string(5, result.MinerName)
+	e.strings(6, result.Errors)
+	e.counts(7, result.ErrorCounts)
+	e.bool(8, result.HasSeriousErrors)
+	e.bool(9, result.HasLessSeriousErrors)
+	for _, bundle := range result.Bundles {
+		e.bytes(10, marshalBundle(bundle))
+	}
+	e.string(11, result.TemplateSource)
+	e.string(12, result.InputHash)
+	e.string(13, result.OutputHash)
+	e.string(14, result.Coinbase)
+	return e.buf
+}
+
+func marshalMinerStats(stats []schema.MinerStats) []byte {
+	var e encoder
+	for _, s := range stats {
+		var m encoder
+		m.string(1, s.Miner)
+		m.string(2, s.MinerName)
+		m.int(3, s.WindowSec)
+		m.uint(4, s.Blocks)
+		m.uint(5, s.ErrorBlocks)
+		m.double(6, s.ErrorRate)
+		m.counts(7, s.ErrorCounts)
+		e.bytes(1, m.buf)
+	}
+	return e.buf
+}
+
+func marshalFailedTxHistory(txs []FailedTxRecord) []byte {
+	var e encoder
+	for _, tx := range txs {
+		var m encoder
+		m.string(1, tx.Hash)
+		m.int(2, tx.BlockNumber)
+		m.string(3, tx.Miner)
+		m.string(4, tx.MinerName)
+		m.string(5, tx.From)
+		m.string(6, tx.To)
+		m.bool(7, tx.IsFlashbots)
+		m.string(8, tx.BundleHash)
+		m.uint(9, tx.GasUsed)
+		m.string(10, tx.GasCost)
+		m.string(11, tx.RevertReason)
+		e.bytes(1, m.buf)
+	}
+	return e.buf
+}
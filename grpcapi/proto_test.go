@@ -0,0 +1,374 @@
+package grpcapi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/metachris/flashbots/schema"
+	"github.com/metachris/flashbots/watcher"
+)
+
+// Conformance of the hand-written encoding with blockwatch.proto: the messages are decoded (and the requests
+// encoded) with the field numbers and types of the .proto file, and compared field by field with the Go values.
+
+// protoField is a field of a message in blockwatch.proto
+type protoField struct {
+	name     string
+	typ      string // scalar type or message name
+	repeated bool
+	mapValue string // value type of map<string, ...> fields
+}
+
+type protoDescriptor struct {
+	messages map[string]map[int]protoField
+	methods  map[string][2]string // request and response message, by method
+}
+
+var (
+	protoMessageRe = regexp.MustCompile(`^message (\w+) \{$`)
+	protoFieldRe   = regexp.MustCompile(`^(repeated )?(\w+) (\w+) = (\d+);$`)
+	protoMapRe     = regexp.MustCompile(`^map<string, (\w+)> (\w+) = (\d+);$`)
+	protoRpcRe     = regexp.MustCompile(`^rpc (\w+)\((\w+)\) returns \((?:stream )?(\w+)\);$`)
+)
+
+// parseProto reads the messages and methods of blockwatch.proto (the subset of the syntax it uses)
+func parseProto(t *testing.T) *protoDescriptor {
+	data, err := os.ReadFile("blockwatch.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &protoDescriptor{messages: make(map[string]map[int]protoField), methods: make(map[string][2]string)}
+	var message string
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.Join(strings.Fields(line), " ")
+		var number int
+		switch {
+		case line == "":
+		case protoMessageRe.MatchString(line):
+			message = protoMessageRe.FindStringSubmatch(line)[1]
+			d.messages[message] = make(map[int]protoField)
+		case line == "}":
+			message = ""
+		case protoRpcRe.MatchString(line):
+			m := protoRpcRe.FindStringSubmatch(line)
+			d.methods[m[1]] = [2]string{m[2], m[3]}
+		case message != "" && protoMapRe.MatchString(line):
+			m := protoMapRe.FindStringSubmatch(line)
+			fmt.Sscan(m[3], &number)
+			d.messages[message][number] = protoField{name: m[2], typ: message + "." + m[2], mapValue: m[1]}
+			d.messages[message+"."+m[2]] = map[int]protoField{1: {name: "key", typ: "string"}, 2: {name: "value", typ: m[1]}}
+		case message != "" && protoFieldRe.MatchString(line):
+			m := protoFieldRe.FindStringSubmatch(line)
+			fmt.Sscan(m[4], &number)
+			d.messages[message][number] = protoField{name: m[3], typ: m[2], repeated: m[1] != ""}
+		}
+	}
+	return d
+}
+
+func (d *protoDescriptor) wireType(typ string) int {
+	switch typ {
+	case "int64", "uint64", "bool":
+		return wireVarint
+	case "double":
+		return wire64Bit
+	}
+	return wireBytes // string, messages and map entries
+}
+
+// decode decodes a message into a map by field name, repeated fields are lists, map fields are maps
+func (d *protoDescriptor) decode(t *testing.T, message string, data []byte) map[string]interface{} {
+	fields, found := d.messages[message]
+	if !found {
+		t.Fatalf("message %s not in blockwatch.proto", message)
+	}
+	decoded := make(map[string]interface{})
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatalf("%s: invalid tag", message)
+		}
+		data = data[n:]
+		field, found := fields[int(tag>>3)]
+		if !found {
+			t.Fatalf("%s: field %d is not in blockwatch.proto", message, tag>>3)
+		}
+		if wireType := int(tag & 7); wireType != d.wireType(field.typ) {
+			t.Fatalf("%s.%s: wire type %d, expected %d", message, field.name, wireType, d.wireType(field.typ))
+		}
+
+		var value interface{}
+		switch d.wireType(field.typ) {
+		case wireVarint:
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				t.Fatalf("%s.%s: invalid varint", message, field.name)
+			}
+			data = data[n:]
+			switch field.typ {
+			case "int64":
+				value = int64(v)
+			case "bool":
+				value = v != 0
+			default:
+				value = v
+			}
+		case wire64Bit:
+			value, data = math.Float64frombits(binary.LittleEndian.Uint64(data)), data[8:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				t.Fatalf("%s.%s: invalid length", message, field.name)
+			}
+			bytes := data[n : n+int(length)]
+			data = data[n+int(length):]
+			if field.typ == "string" {
+				value = string(bytes)
+			} else {
+				value = d.decode(t, field.typ, bytes)
+			}
+		}
+
+		switch {
+		case field.mapValue != "":
+			entries, _ := decoded[field.name].(map[string]interface{})
+			if entries == nil {
+				entries = make(map[string]interface{})
+			}
+			entry := value.(map[string]interface{})
+			entries[entry["key"].(string)] = entry["value"]
+			decoded[field.name] = entries
+		case field.repeated:
+			list, _ := decoded[field.name].([]interface{})
+			decoded[field.name] = append(list, value)
+		default:
+			decoded[field.name] = value
+		}
+	}
+	return decoded
+}
+
+// encode encodes a message of scalar and repeated scalar fields (the requests) from a map by field name
+func (d *protoDescriptor) encode(t *testing.T, message string, values map[string]interface{}) []byte {
+	numbers := make([]int, 0)
+	for number := range d.messages[message] {
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+
+	var e encoder
+	for _, number := range numbers {
+		field := d.messages[message][number]
+		list := []interface{}{values[field.name]}
+		if field.repeated {
+			list = values[field.name].([]interface{})
+		}
+		for _, value := range list {
+			switch v := value.(type) {
+			case string:
+				e.bytes(number, []byte(v))
+			case int64:
+				e.tag(number, wireVarint)
+				e.buf = appendUvarint(e.buf, uint64(v))
+			case uint64:
+				e.tag(number, wireVarint)
+				e.buf = appendUvarint(e.buf, v)
+			case bool:
+				e.tag(number, wireVarint)
+				e.buf = appendUvarint(e.buf, 1)
+			default:
+				t.Fatalf("%s.%s: unsupported request field %T", message, field.name, value)
+			}
+		}
+	}
+	return e.buf
+}
+
+// goFieldName is the Go name of a proto field, eg. is_out_of_order -> IsOutOfOrder
+func goFieldName(name string) string {
+	parts := strings.Split(name, "_")
+	for i, part := range parts {
+		parts[i] = strings.ToUpper(part[:1]) + part[1:]
+	}
+	return strings.Join(parts, "")
+}
+
+// expected returns the fields of the message from the Go value, in the format of decode
+func (d *protoDescriptor) expected(t *testing.T, message string, v reflect.Value) map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, field := range d.messages[message] {
+		fv := v.FieldByName(goFieldName(field.name))
+		if !fv.IsValid() {
+			t.Errorf("%s.%s: no field %s in %s", message, field.name, goFieldName(field.name), v.Type())
+			continue
+		}
+		switch {
+		case field.mapValue != "":
+			entries := make(map[string]interface{})
+			iter := fv.MapRange()
+			for iter.Next() {
+				entries[iter.Key().String()] = d.expectedValue(t, field.mapValue, iter.Value())
+			}
+			values[field.name] = entries
+		case field.repeated:
+			list := make([]interface{}, 0)
+			for i := 0; i < fv.Len(); i++ {
+				list = append(list, d.expectedValue(t, field.typ, fv.Index(i)))
+			}
+			values[field.name] = list
+		default:
+			values[field.name] = d.expectedValue(t, field.typ, fv)
+		}
+	}
+	return values
+}
+
+func (d *protoDescriptor) expectedValue(t *testing.T, typ string, v reflect.Value) interface{} {
+	switch typ {
+	case "string":
+		return v.String()
+	case "int64":
+		return v.Int()
+	case "uint64":
+		return v.Uint()
+	case "bool":
+		return v.Bool()
+	case "double":
+		return v.Float()
+	}
+	return d.expected(t, typ, v)
+}
+
+// fill sets all fields of v to distinct non-zero values, slices get two entries
+func fill(v reflect.Value, seed *int) {
+	*seed++
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(fmt.Sprintf("value %d", *seed))
+	case reflect.Int, reflect.Int64:
+		v.SetInt(int64(*seed))
+	case reflect.Uint, reflect.Uint64:
+		v.SetUint(uint64(*seed))
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Float64:
+		v.SetFloat(float64(*seed) + 0.5)
+	case reflect.Ptr:
+		p := reflect.New(v.Type().Elem())
+		fill(p.Elem(), seed)
+		v.Set(p)
+	case reflect.Slice:
+		s := reflect.MakeSlice(v.Type(), 2, 2)
+		for i := 0; i < s.Len(); i++ {
+			fill(s.Index(i), seed)
+		}
+		v.Set(s)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		key, value := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+		fill(key, seed)
+		fill(value, seed)
+		m.SetMapIndex(key, value)
+		v.Set(m)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				fill(v.Field(i), seed)
+			}
+		}
+	}
+}
+
+func filled(v interface{}) interface{} {
+	seed := 0
+	fill(reflect.ValueOf(v).Elem(), &seed)
+	return reflect.ValueOf(v).Elem().Interface()
+}
+
+func TestProtoConformance(t *testing.T) {
+	d := parseProto(t)
+	tested := make(map[string]bool)
+
+	// Responses: encoded by the server, decoded with the descriptor
+	result := filled(&schema.CheckResult{}).(schema.CheckResult)
+	stats := filled(&struct{ Miners []schema.MinerStats }{}).(struct{ Miners []schema.MinerStats })
+	failedTxs := filled(&struct{ FailedTxs []FailedTxRecord }{}).(struct{ FailedTxs []FailedTxRecord })
+	responses := []struct {
+		message string
+		data    []byte
+		value   interface{}
+	}{
+		{"CheckResult", marshalCheckResult(result), result},
+		{"GetMinerStatsResponse", marshalMinerStats(stats.Miners), stats},
+		{"GetFailedTxHistoryResponse", marshalFailedTxHistory(failedTxs.FailedTxs), failedTxs},
+	}
+	for _, response := range responses {
+		tested[response.message] = true
+		expected := d.expected(t, response.message, reflect.ValueOf(response.value))
+		if decoded := d.decode(t, response.message, response.data); !reflect.DeepEqual(decoded, expected) {
+			t.Errorf("%s doesn't match blockwatch.proto:\n%v\nexpected:\n%v", response.message, decoded, expected)
+		}
+	}
+
+	// Requests: encoded with the descriptor, decoded by the server
+	requests := []struct {
+		message   string
+		value     interface{}
+		unmarshal func(data []byte) (interface{}, error)
+	}{
+		{"CheckBlockRequest", filled(&CheckBlockRequest{}), func(data []byte) (interface{}, error) {
+			var r CheckBlockRequest
+			err := r.unmarshal(data)
+			return r, err
+		}},
+		{"GetMinerStatsRequest", filled(&GetMinerStatsRequest{}), func(data []byte) (interface{}, error) {
+			var r GetMinerStatsRequest
+			err := r.unmarshal(data)
+			return r, err
+		}},
+		{"GetFailedTxHistoryRequest", filled(&GetFailedTxHistoryRequest{}), func(data []byte) (interface{}, error) {
+			var r GetFailedTxHistoryRequest
+			err := r.unmarshal(data)
+			return r, err
+		}},
+		{"StreamChecksRequest", filled(&watcher.Filter{}), func(data []byte) (interface{}, error) {
+			filter, err := unmarshalStreamChecksRequest(data)
+			if filter == nil {
+				return nil, err
+			}
+			return *filter, err
+		}},
+	}
+	for _, request := range requests {
+		tested[request.message] = true
+		expected := d.expected(t, request.message, reflect.ValueOf(request.value))
+		decoded, err := request.unmarshal(d.encode(t, request.message, expected))
+		if err != nil || decoded == nil {
+			t.Errorf("%s: decoding error %v", request.message, err)
+			continue
+		}
+		if values := d.expected(t, request.message, reflect.ValueOf(decoded)); !reflect.DeepEqual(values, expected) {
+			t.Errorf("%s doesn't match blockwatch.proto:\n%v\nexpected:\n%v", request.message, values, expected)
+		}
+	}
+
+	// All methods are covered
+	if len(d.methods) != 4 {
+		t.Error("expected 4 methods in blockwatch.proto", d.methods)
+	}
+	for method, messages := range d.methods {
+		if !tested[messages[0]] || !tested[messages[1]] {
+			t.Errorf("messages of %s aren't tested: %v", method, messages)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+// Package grpcapi is a gRPC server for monitoring stacks (see blockwatch.proto): CheckBlock, GetMinerStats,
+// StreamChecks and GetFailedTxHistory, backed by the watcher and the stored check results.
+//
+// It implements the gRPC protocol on net/http with a minimal protobuf encoding, without the grpc and protobuf
+// libraries. net/http only serves HTTP/2 over TLS, so the server needs a certificate (see SelfSignedCertificate).
+// Clients use the service definition in blockwatch.proto, eg. grpcurl -insecure -proto blockwatch.proto.
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/labels"
+	"github.com/metachris/flashbots/schema"
+	"github.com/metachris/flashbots/watcher"
+)
+
+// ServiceName is the full name of the service in blockwatch.proto
+const ServiceName = "blockwatch.v1.BlockWatch"
+
+// gRPC status codes
+const (
+	StatusOK                = 0
+	StatusInvalidArgument   = 3
+	StatusNotFound          = 5
+	StatusResourceExhausted = 8
+	StatusUnimplemented     = 12
+	StatusInternal          = 13
+	StatusUnavailable       = 14
+)
+
+// Max. size of a request message
+var MaxRequestSize = 64 * 1024
+
+// Defaults of the requests
+var (
+	DefaultMinerStatsWindow = 24 * time.Hour
+	DefaultFailedTxLimit    = 100
+)
+
+// Default number of failed transactions kept in memory
+var DefaultMaxFailedTx = 10000
+
+// Error is an error with a gRPC status code
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("grpc status %d: %s", e.Code, e.Message)
+}
+
+func statusError(code int, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// ResultStore finds stored check results, eg. watcher.JSONLSink
+type ResultStore interface {
+	Find(block int64) ([]schema.CheckResult, []schema.Incident, error)
+}
+
+// FailedTxRecord is a failed transaction of a checked block
+type FailedTxRecord struct {
+	Hash         string
+	BlockNumber  int64
+	Miner        string
+	MinerName    string
+	From         string
+	To           string
+	IsFlashbots  bool
+	BundleHash   string
+	GasUsed      uint64
+	GasCost      string // wei
+	RevertReason string
+}
+
+// Server serves the BlockWatch service. It's a watcher.CheckSink: it keeps the miner stats and failed transactions of
+// the saved checks. It's safe for concurrent use.
+type Server struct {
+	CheckBlock func(ctx context.Context, number int64) (*blockcheck.BlockCheck, error)                  // checks a block (CheckBlock)
+	Subscribe  func(ctx context.Context, filter *watcher.Filter) (<-chan *blockcheck.BlockCheck, error) // eg. Watcher.SubscribeChecksFiltered (StreamChecks)
+	Results    ResultStore                                                                              // optional: check results of blocks which were checked before
+
+	MaxFailedTx int
+
+	lock        sync.Mutex
+	leaderboard *blockcheck.MinerLeaderboard
+	failedTx    []FailedTxRecord // oldest first
+}
+
+func NewServer() *Server {
+	return &Server{
+		MaxFailedTx: DefaultMaxFailedTx,
+		leaderboard: blockcheck.NewMinerLeaderboard(7 * 24 * time.Hour),
+		failedTx:    make([]FailedTxRecord, 0),
+	}
+}
+
+// SaveCheck adds the block to the miner stats and keeps its failed transactions (a re-check replaces the block)
+func (s *Server) SaveCheck(check *blockcheck.BlockCheck) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.leaderboard.RemoveCheck(check)
+	s.leaderboard.AddCheck(check)
+
+	failedTx := s.failedTx[:0]
+	for _, tx := range s.failedTx {
+		if tx.BlockNumber != check.Number {
+			failedTx = append(failedTx, tx)
+		}
+	}
+	s.failedTx = failedTx
+	hashes := make([]string, 0, len(check.FailedTx))
+	for hash := range check.FailedTx {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		tx := check.FailedTx[hash]
+		record := FailedTxRecord{
+			Hash:         tx.Hash,
+			BlockNumber:  check.Number,
+			Miner:        check.Miner,
+			MinerName:    check.MinerName,
+			From:         labels.SearcherDisclosure.Address(tx.From), // the address filter matches the disclosed sender
+			To:           tx.To,
+			IsFlashbots:  tx.IsFlashbots,
+			BundleHash:   tx.BundleHash,
+			GasUsed:      tx.GasUsed,
+			RevertReason: tx.RevertReason,
+		}
+		if tx.GasCost != nil {
+			record.GasCost = tx.GasCost.String()
+		}
+		s.failedTx = append(s.failedTx, record)
+	}
+	if s.MaxFailedTx > 0 && len(s.failedTx) > s.MaxFailedTx {
+		s.failedTx = append([]FailedTxRecord(nil), s.failedTx[len(s.failedTx)-s.MaxFailedTx:]...)
+	}
+	return nil
+}
+
+// GetCheckBlock returns the stored check result of the block (unless recheck is set), else checks it
+func (s *Server) GetCheckBlock(ctx context.Context, request CheckBlockRequest) (result schema.CheckResult, err error) {
+	if request.BlockNumber <= 0 {
+		return result, statusError(StatusInvalidArgument, "block_number is required")
+	}
+
+	if s.Results != nil && !request.Recheck {
+		results, _, err := s.Results.Find(request.BlockNumber)
+		if err != nil {
+			return result, statusError(StatusInternal, "error reading the check results: %v", err)
+		}
+		if len(results) > 0 {
+			return results[len(results)-1], nil // the latest check of the block
+		}
+	}
+
+	if s.CheckBlock == nil {
+		return result, statusError(StatusNotFound, "block %d wasn't checked", request.BlockNumber)
+	}
+	check, err := s.CheckBlock(ctx, request.BlockNumber)
+	if err != nil {
+		return result, statusError(StatusUnavailable, "error checking block %d: %v", request.BlockNumber, err)
+	}
+	return schema.NewCheckResult(check), nil
+}
+
+// GetMinerStats returns the error rates per miner in the time window, sorted by error rate
+func (s *Server) GetMinerStats(request GetMinerStatsRequest) (stats []schema.MinerStats, err error) {
+	window := DefaultMinerStatsWindow
+	if request.WindowSec < 0 {
+		return nil, statusError(StatusInvalidArgument, "invalid window_sec")
+	} else if request.WindowSec > 0 {
+		window = time.Duration(request.WindowSec) * time.Second
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if window > s.leaderboard.MaxWindow {
+		return nil, statusError(StatusInvalidArgument, "window_sec is larger than %d", int64(s.leaderboard.MaxWindow.Seconds()))
+	}
+
+	stats = make([]schema.MinerStats, 0)
+	for _, minerStats := range s.leaderboard.Stats(window, time.Now()) {
+		if request.Miner == "" || strings.EqualFold(request.Miner, minerStats.Miner) {
+			stats = append(stats, schema.NewMinerStats(minerStats, window))
+		}
+	}
+	return stats, nil
+}
+
+// GetFailedTxHistory returns the failed transactions matching the request, newest first
+func (s *Server) GetFailedTxHistory(request GetFailedTxHistoryRequest) (txs []FailedTxRecord, err error) {
+	limit := int(request.Limit)
+	if request.Limit < 0 {
+		return nil, statusError(StatusInvalidArgument, "invalid limit")
+	} else if limit == 0 {
+		limit = DefaultFailedTxLimit
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	txs = make([]FailedTxRecord, 0)
+	for i := len(s.failedTx) - 1; i >= 0 && len(txs) < limit; i-- {
+		tx := s.failedTx[i]
+		if request.Miner != "" && !strings.EqualFold(request.Miner, tx.Miner) {
+			continue
+		}
+		if request.Address != "" && !strings.EqualFold(request.Address, tx.From) && !strings.EqualFold(request.Address, tx.To) {
+			continue
+		}
+		if tx.BlockNumber < request.FromBlock {
+			continue
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// Handler serves the gRPC methods of the service on /blockwatch.v1.BlockWatch/<method> (HTTP/2 only)
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/"+ServiceName+"/CheckBlock", s.unary(func(ctx context.Context, data []byte) ([]byte, error) {
+		var request CheckBlockRequest
+		if err := request.unmarshal(data); err != nil {
+			return nil, statusError(StatusInvalidArgument, "%v", err)
+		}
+		result, err := s.GetCheckBlock(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		return marshalCheckResult(result), nil
+	}))
+	mux.HandleFunc("/"+ServiceName+"/GetMinerStats", s.unary(func(ctx context.Context, data []byte) ([]byte, error) {
+		var request GetMinerStatsRequest
+		if err := request.unmarshal(data); err != nil {
+			return nil, statusError(StatusInvalidArgument, "%v", err)
+		}
+		stats, err := s.GetMinerStats(request)
+		if err != nil {
+			return nil, err
+		}
+		return marshalMinerStats(stats), nil
+	}))
+	mux.HandleFunc("/"+ServiceName+"/GetFailedTxHistory", s.unary(func(ctx context.Context, data []byte) ([]byte, error) {
+		var request GetFailedTxHistoryRequest
+		if err := request.unmarshal(data); err != nil {
+			return nil, statusError(StatusInvalidArgument, "%v", err)
+		}
+		txs, err := s.GetFailedTxHistory(request)
+		if err != nil {
+			return nil, err
+		}
+		return marshalFailedTxHistory(txs), nil
+	}))
+	mux.HandleFunc("/"+ServiceName+"/StreamChecks", s.streamChecks)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if !startResponse(w, r) {
+			return
+		}
+		finishResponse(w, statusError(StatusUnimplemented, "unknown method %s", r.URL.Path))
+	})
+	return mux
+}
+
+// unary serves a method with one request and one response message
+func (s *Server) unary(method func(ctx context.Context, data []byte) ([]byte, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !startResponse(w, r) {
+			return
+		}
+		data, err := readMessage(r.Body)
+		if err == nil {
+			data, err = method(r.Context(), data)
+		}
+		if err == nil {
+			err = writeMessage(w, data)
+		}
+		finishResponse(w, err)
+	}
+}
+
+// streamChecks serves StreamChecks: every check result matching the filter of the request, until the client cancels
+func (s *Server) streamChecks(w http.ResponseWriter, r *http.Request) {
+	if !startResponse(w, r) {
+		return
+	}
+	if s.Subscribe == nil {
+		finishResponse(w, statusError(StatusUnimplemented, "no checks to stream"))
+		return
+	}
+
+	data, err := readMessage(r.Body)
+	if err != nil {
+		finishResponse(w, err)
+		return
+	}
+	filter, err := unmarshalStreamChecksRequest(data)
+	if err != nil {
+		finishResponse(w, statusError(StatusInvalidArgument, "%v", err))
+		return
+	}
+	checks, err := s.Subscribe(r.Context(), filter)
+	if err != nil {
+		finishResponse(w, statusError(StatusUnavailable, "%v", err))
+		return
+	}
+
+	w.(http.Flusher).Flush()    // send the headers, clients wait for them before the first check
+	for check := range checks { // closed when the request is done or the watcher stopped
+		if err := writeMessage(w, marshalCheckResult(schema.NewCheckResult(check))); err != nil {
+			return
+		}
+		w.(http.Flusher).Flush()
+	}
+	if r.Context().Err() != nil {
+		return // cancelled by the client
+	}
+	finishResponse(w, statusError(StatusUnavailable, "watcher stopped"))
+}
+
+// startResponse checks the gRPC request and writes the response headers, the status is sent as trailers (see
+// finishResponse)
+func startResponse(w http.ResponseWriter, r *http.Request) bool {
+	if r.ProtoMajor != 2 || r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests only (HTTP/2 POST, application/grpc)", http.StatusUnsupportedMediaType)
+		return false
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+	return true
+}
+
+// finishResponse sets the gRPC status of the error in the trailers
+func finishResponse(w http.ResponseWriter, err error) {
+	var statusErr *Error
+	if err == nil {
+		statusErr = &Error{Code: StatusOK}
+	} else if !errors.As(err, &statusErr) {
+		statusErr = statusError(StatusInternal, "%v", err)
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(statusErr.Code))
+	w.Header().Set("Grpc-Message", percentEncode(statusErr.Message))
+}
+
+// readMessage reads the length-prefixed request message (uncompressed only)
+func readMessage(body io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(body, prefix[:]); err != nil {
+		return nil, statusError(StatusInvalidArgument, "missing request message")
+	}
+	if prefix[0] != 0 {
+		return nil, statusError(StatusUnimplemented, "compressed messages are not supported")
+	}
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if int64(length) > int64(MaxRequestSize) {
+		return nil, statusError(StatusResourceExhausted, "request message larger than %d bytes", MaxRequestSize)
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(body, data); err != nil {
+		return nil, statusError(StatusInvalidArgument, "incomplete request message")
+	}
+	return data, nil
+}
+
+// writeMessage writes a length-prefixed message
+func writeMessage(w io.Writer, data []byte) error {
+	var buf bytes.Buffer
+	buf.WriteByte(0) // uncompressed
+	binary.Write(&buf, binary.BigEndian, uint32(len(data)))
+	buf.Write(data)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// percentEncode encodes grpc-message values (printable ASCII except %)
+func percentEncode(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,176 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/schema"
+	"github.com/metachris/flashbots/watcher"
+)
+
+type testStore map[int64]schema.CheckResult
+
+func (s testStore) Find(block int64) ([]schema.CheckResult, []schema.Incident, error) {
+	if result, found := s[block]; found {
+		return []schema.CheckResult{result}, nil, nil
+	}
+	return nil, nil, nil
+}
+
+func newTestCheck(number int64, miner string, failedTx ...*blockcheck.FailedTx) *blockcheck.BlockCheck {
+	check := &blockcheck.BlockCheck{
+		Number:   number,
+		Miner:    miner,
+		EthBlock: types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number), Time: uint64(time.Now().Unix())}),
+		FailedTx: make(map[string]*blockcheck.FailedTx),
+	}
+	for _, tx := range failedTx {
+		check.FailedTx[tx.Hash] = tx
+		check.ErrorCounter.FailedFlashbotsTx += 1
+		check.HasFailedFlashbotsTx = true
+	}
+	return check
+}
+
+// call sends a gRPC request, and returns the response messages and the status
+func call(t *testing.T, server *httptest.Server, method string, request []byte) (messages [][]byte, status string) {
+	var body bytes.Buffer
+	if err := writeMessage(&body, request); err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/"+ServiceName+"/"+method, &body)
+	req.Header.Set("Content-Type", "application/grpc")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 || resp.StatusCode != http.StatusOK {
+		t.Fatal("unexpected response", resp.Proto, resp.Status)
+	}
+
+	for {
+		var prefix [5]byte
+		if _, err := io.ReadFull(resp.Body, prefix[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		message := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+		if _, err := io.ReadFull(resp.Body, message); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, message)
+	}
+	return messages, resp.Trailer.Get("Grpc-Status") // trailers are set after the body was read
+}
+
+// fields returns the varint and string fields of a message, by field number (the last value of repeated fields)
+func fields(t *testing.T, message []byte) (values map[int]uint64, strings map[int]string, messages map[int][][]byte) {
+	values, strings, messages = make(map[int]uint64), make(map[int]string), make(map[int][][]byte)
+	err := decodeFields(message, func(field int, value uint64, bytes []byte) error {
+		values[field] = value
+		strings[field] = string(bytes)
+		messages[field] = append(messages[field], bytes)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values, strings, messages
+}
+
+func TestServer(t *testing.T) {
+	checks := make(chan *blockcheck.BlockCheck, 1)
+	var streamFilter *watcher.Filter
+	s := NewServer()
+	s.Results = testStore{100: {BlockNumber: 100, Miner: "0xm1", Errors: []string{"failed tx"}}}
+	s.Subscribe = func(ctx context.Context, filter *watcher.Filter) (<-chan *blockcheck.BlockCheck, error) {
+		streamFilter = filter
+		return checks, nil
+	}
+
+	s.SaveCheck(newTestCheck(100, "0xM1", &blockcheck.FailedTx{Hash: "0xtx1", From: "0xS1", To: "0xC1", IsFlashbots: true, GasCost: big.NewInt(21000)}))
+	s.SaveCheck(newTestCheck(101, "0xM2"))
+	s.SaveCheck(newTestCheck(102, "0xM1", &blockcheck.FailedTx{Hash: "0xtx2", From: "0xS2", To: "0xC1"}))
+	s.SaveCheck(newTestCheck(102, "0xM1", &blockcheck.FailedTx{Hash: "0xtx2", From: "0xS2", To: "0xC1"})) // re-check
+
+	server := httptest.NewUnstartedServer(s.Handler())
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	// CheckBlock returns the stored result
+	var request encoder
+	request.int(1, 100)
+	messages, status := call(t, server, "CheckBlock", request.buf)
+	if status != "0" || len(messages) != 1 {
+		t.Fatal("unexpected response", status, messages)
+	}
+	if values, strings, _ := fields(t, messages[0]); values[2] != 100 || strings[4] != "0xm1" || strings[6] != "failed tx" {
+		t.Errorf("unexpected check result %v %v", values, strings)
+	}
+
+	request = encoder{}
+	request.int(1, 101)
+	if _, status := call(t, server, "CheckBlock", request.buf); status != "5" {
+		t.Error("expected not found without CheckBlock, got status", status)
+	}
+
+	// Miner stats, sorted by error rate
+	messages, status = call(t, server, "GetMinerStats", nil)
+	if status != "0" || len(messages) != 1 {
+		t.Fatal("unexpected response", status, messages)
+	}
+	_, _, miners := fields(t, messages[0])
+	if len(miners[1]) != 2 {
+		t.Fatal("expected 2 miners, got", len(miners[1]))
+	}
+	if values, strings, _ := fields(t, miners[1][0]); strings[1] != "0xM1" || values[4] != 2 || values[5] != 2 || values[3] != 86400 {
+		t.Errorf("unexpected miner stats %v %v", values, strings)
+	}
+
+	request = encoder{}
+	request.int(1, 30*24*3600)
+	if _, status := call(t, server, "GetMinerStats", request.buf); status != "3" {
+		t.Error("expected invalid argument for a window above 7 days, got status", status)
+	}
+
+	// Failed tx, newest first
+	request = encoder{}
+	request.string(2, "0xc1")
+	messages, _ = call(t, server, "GetFailedTxHistory", request.buf)
+	_, _, txs := fields(t, messages[0])
+	if len(txs[1]) != 2 {
+		t.Fatal("expected 2 failed tx, got", len(txs[1]))
+	}
+	if values, strings, _ := fields(t, txs[1][1]); strings[1] != "0xtx1" || values[2] != 100 || values[7] != 1 || strings[10] != "21000" {
+		t.Errorf("unexpected failed tx %v %v", values, strings)
+	}
+
+	// Streamed checks, until the subscription is closed
+	request = encoder{}
+	request.string(3, blockcheck.SeveritySerious)
+	checks <- newTestCheck(103, "0xM3")
+	close(checks)
+	messages, status = call(t, server, "StreamChecks", request.buf)
+	if status != "14" || len(messages) != 1 || streamFilter == nil || streamFilter.MinSeverity != blockcheck.SeveritySerious {
+		t.Fatal("unexpected stream", status, messages, streamFilter)
+	}
+	if values, _, _ := fields(t, messages[0]); values[2] != 103 {
+		t.Errorf("unexpected streamed check %v", values)
+	}
+
+	if _, status := call(t, server, "Unknown", nil); status != "12" {
+		t.Error("expected unimplemented for an unknown method, got status", status)
+	}
+}
@@ -0,0 +1,103 @@
+package labels
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Disclosure modes of searcher addresses in the public outputs (alerts, digests, check results, APIs)
+const (
+	DisclosureFull      = "full"      // address and label
+	DisclosureTruncated = "truncated" // 0x1234...abcd, labels are shown
+	DisclosurePseudonym = "pseudonym" // stable cluster ID like searcher-1a2b3c4d, the addresses of a label share one ID
+)
+
+var DisclosureModes = []string{DisclosureFull, DisclosureTruncated, DisclosurePseudonym}
+
+// Disclosure decides how searcher addresses are shown, based on the labels of a registry
+type Disclosure struct {
+	Mode     string
+	Salt     string // of the pseudonyms, keep it secret so they can't be reversed by hashing known addresses
+	Registry *Registry
+}
+
+// SearcherDisclosure is applied to the searcher addresses of all outputs, set it at startup (the default shows all)
+var SearcherDisclosure = NewDisclosure(DisclosureFull, "", Default)
+
+func NewDisclosure(mode string, salt string, registry *Registry) *Disclosure {
+	return &Disclosure{Mode: mode, Salt: salt, Registry: registry}
+}
+
+// IsValidDisclosureMode returns whether the mode is one of DisclosureModes
+func IsValidDisclosureMode(mode string) bool {
+	for _, m := range DisclosureModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFull returns whether addresses are shown unchanged (and can be linked)
+func (d *Disclosure) IsFull() bool {
+	return d.Mode == "" || d.Mode == DisclosureFull
+}
+
+// Address returns the address as it may be shown
+func (d *Disclosure) Address(address string) string {
+	if address == "" {
+		return ""
+	}
+
+	switch d.Mode {
+	case DisclosureTruncated:
+		if len(address) <= 10 {
+			return address
+		}
+		return address[:6] + "..." + address[len(address)-4:]
+	case DisclosurePseudonym:
+		return d.pseudonym(address)
+	default:
+		return address
+	}
+}
+
+// Name returns the label name as it may be shown, empty if unknown. In pseudonym mode names are hidden.
+func (d *Disclosure) Name(address string) string {
+	if d.Mode == DisclosurePseudonym {
+		return ""
+	}
+	return d.Registry.Name(address)
+}
+
+// Searcher returns the identity of the searcher as it may be shown: the label name (empty if unknown), in pseudonym
+// mode the cluster ID
+func (d *Disclosure) Searcher(address string) string {
+	if d.Mode == DisclosurePseudonym {
+		return d.Address(address)
+	}
+	return d.Registry.Name(address)
+}
+
+// Format returns "name (address)" for known addresses, else the address, both as they may be shown
+func (d *Disclosure) Format(address string) string {
+	if name := d.Name(address); name != "" {
+		return fmt.Sprintf("%s (%s)", name, d.Address(address))
+	}
+	return d.Address(address)
+}
+
+// pseudonym is derived from the label name (so all addresses of a searcher form one cluster), else the address
+func (d *Disclosure) pseudonym(address string) string {
+	key := strings.ToLower(address)
+	if name := d.Registry.Name(address); name != "" {
+		key = "label:" + strings.ToLower(name)
+	}
+
+	mac := hmac.New(sha256.New, []byte(d.Salt))
+	mac.Write([]byte(key))
+	return "searcher-" + hex.EncodeToString(mac.Sum(nil))[:8]
+}
@@ -0,0 +1,52 @@
+package labels
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDisclosure(t *testing.T) {
+	registry := NewRegistry()
+	for _, label := range []Label{
+		{Address: "0x0000000000000000000000000000000000000001", Name: "Searcher1", Category: CategorySearcher},
+		{Address: "0x0000000000000000000000000000000000000002", Name: "Searcher1", Category: CategorySearcher},
+	} {
+		if err := registry.Add(label); err != nil {
+			t.Fatal(err)
+		}
+	}
+	labeled1 := "0x0000000000000000000000000000000000000001"
+	labeled2 := "0x0000000000000000000000000000000000000002"
+	unknown := "0x00000000000000000000000000000000000000aB"
+
+	full := NewDisclosure(DisclosureFull, "", registry)
+	if s := full.Format(labeled1); s != "Searcher1 ("+labeled1+")" {
+		t.Error("unexpected full format", s)
+	}
+
+	truncated := NewDisclosure(DisclosureTruncated, "", registry)
+	if s := truncated.Format(labeled1); s != "Searcher1 (0x0000...0001)" {
+		t.Error("unexpected truncated format", s)
+	}
+	if s := truncated.Address(unknown); s != "0x0000...00aB" {
+		t.Error("unexpected truncated address", s)
+	}
+
+	pseudonym := NewDisclosure(DisclosurePseudonym, "secret", registry)
+	id := pseudonym.Address(labeled1)
+	if !strings.HasPrefix(id, "searcher-") || len(id) != 17 {
+		t.Error("unexpected pseudonym", id)
+	}
+	if pseudonym.Address(labeled2) != id || pseudonym.Searcher(labeled2) != id {
+		t.Error("expected the addresses of a label to share the pseudonym")
+	}
+	if pseudonym.Address(unknown) == id || pseudonym.Address(unknown) != pseudonym.Address(strings.ToLower(unknown)) {
+		t.Error("expected a stable pseudonym of its own for an unlabeled address")
+	}
+	if s := pseudonym.Format(labeled1); s != id {
+		t.Error("expected the label to be hidden", s)
+	}
+	if NewDisclosure(DisclosurePseudonym, "other", registry).Address(labeled1) == id {
+		t.Error("expected the pseudonym to depend on the salt")
+	}
+}
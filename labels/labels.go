@@ -0,0 +1,167 @@
+// Package labels maps addresses of miners, builders and searchers to names, for the terminal output, alerts and
+// JSON exports.
+//
+// The default labels are embedded (labels.json), and can be extended with JSON files in the same format or CSV files
+// with the columns address, name and category (an optional header row starts with "address"):
+//
+//	{"labels": [{"address": "0x...", "name": "MySearcher", "category": "searcher"}]}
+package labels
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Categories of the built-in labels, user labels can use others
+const (
+	CategoryMiner    = "miner"
+	CategoryBuilder  = "builder"
+	CategorySearcher = "searcher"
+)
+
+//go:embed labels.json
+var defaultLabelsJson []byte
+
+// Default is the registry used by all packages of this module (blockcheck, analytics), with the embedded labels
+var Default = DefaultRegistry()
+
+type Label struct {
+	Address  string `json:"address"`
+	Name     string `json:"name"`
+	Category string `json:"category,omitempty"`
+}
+
+type labelsFile struct {
+	Labels []Label `json:"labels"`
+}
+
+// Registry of address labels, safe for concurrent use. Addresses are stored lowercase.
+type Registry struct {
+	lock   sync.RWMutex
+	labels map[string]Label
+}
+
+func NewRegistry() *Registry {
+	return &Registry{labels: make(map[string]Label)}
+}
+
+// DefaultRegistry returns a new registry with the embedded labels
+func DefaultRegistry() *Registry {
+	registry := NewRegistry()
+	err := registry.Load(defaultLabelsJson)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded labels: %v", err))
+	}
+	return registry
+}
+
+// Add adds a label. A known address is overwritten, so user labels take precedence over the embedded ones.
+func (r *Registry) Add(label Label) error {
+	address := strings.ToLower(strings.TrimSpace(label.Address))
+	if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+		return fmt.Errorf("invalid address %s", label.Address)
+	}
+	if label.Name == "" {
+		return fmt.Errorf("%s: label without name", label.Address)
+	}
+
+	label.Address = address
+	r.lock.Lock()
+	r.labels[address] = label
+	r.lock.Unlock()
+	return nil
+}
+
+// Load adds the labels of a JSON file
+func (r *Registry) Load(data []byte) error {
+	var file labelsFile
+	err := json.Unmarshal(data, &file)
+	if err != nil {
+		return err
+	}
+
+	for _, label := range file.Labels {
+		err = r.Add(label)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadCSV adds the labels of a CSV file (address, name, category)
+func (r *Registry) LoadCSV(reader io.Reader) error {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+	for line := 1; ; line++ {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if line == 1 && strings.EqualFold(record[0], "address") { // header
+			continue
+		}
+		if len(record) < 2 {
+			return fmt.Errorf("line %d: expected address, name and category", line)
+		}
+
+		label := Label{Address: record[0], Name: record[1]}
+		if len(record) > 2 {
+			label.Category = record[2]
+		}
+		if err = r.Add(label); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+// LoadFile adds the labels of a JSON or CSV file (by extension)
+func (r *Registry) LoadFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(strings.ToLower(filename), ".csv") {
+		err = r.LoadCSV(bytes.NewReader(data))
+	} else {
+		err = r.Load(data)
+	}
+	if err != nil {
+		return fmt.Errorf("error loading labels %s: %w", filename, err)
+	}
+	return nil
+}
+
+// Get returns the label of the address
+func (r *Registry) Get(address string) (label Label, found bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	label, found = r.labels[strings.ToLower(address)]
+	return label, found
+}
+
+// Name returns the name of the address, empty if unknown
+func (r *Registry) Name(address string) string {
+	label, _ := r.Get(address)
+	return label.Name
+}
+
+// Format returns "name (address)" for known addresses, else the address
+func (r *Registry) Format(address string) string {
+	if name := r.Name(address); name != "" {
+		return fmt.Sprintf("%s (%s)", name, address)
+	}
+	return address
+}
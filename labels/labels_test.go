@@ -0,0 +1,56 @@
+package labels
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultRegistry(t *testing.T) {
+	registry := DefaultRegistry()
+	if name := registry.Name("0xea674fdde714fd979de3edf0f56aa9716b898ec8"); name != "Ethermine" {
+		t.Error("Expected Ethermine, got", name)
+	}
+	if label, found := registry.Get("0xDAFEA492D9c6733ae3d56b7Ed1ADB60692c98Bc5"); !found || label.Category != CategoryBuilder {
+		t.Error("Unexpected builder label:", label, found)
+	}
+	if name := registry.Name("0x0000000000000000000000000000000000000001"); name != "" {
+		t.Error("Expected no name, got", name)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	csvFile := filepath.Join(dir, "labels.csv")
+	err := os.WriteFile(csvFile, []byte("address,name,category\n0xEA674fdDe714fd979de3EdF0F56AA9716B898ec8, Ethermine (custom), miner\n0x0000000000000000000000000000000000000001,Searcher1\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonFile := filepath.Join(dir, "labels.json")
+	err = os.WriteFile(jsonFile, []byte(`{"labels": [{"address": "0x0000000000000000000000000000000000000002", "name": "Searcher2", "category": "searcher"}]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := DefaultRegistry()
+	if err = registry.LoadFile(csvFile); err != nil {
+		t.Fatal(err)
+	}
+	if err = registry.LoadFile(jsonFile); err != nil {
+		t.Fatal(err)
+	}
+
+	if name := registry.Name("0xEA674fdDe714fd979de3EdF0F56AA9716B898ec8"); name != "Ethermine (custom)" {
+		t.Error("User label should overwrite the default, got", name)
+	}
+	if s := registry.Format("0x0000000000000000000000000000000000000001"); s != "Searcher1 (0x0000000000000000000000000000000000000001)" {
+		t.Error("Unexpected format:", s)
+	}
+	if label, _ := registry.Get("0x0000000000000000000000000000000000000002"); label.Category != CategorySearcher {
+		t.Error("Unexpected label:", label)
+	}
+
+	if err = registry.Add(Label{Address: "0x123", Name: "invalid"}); err == nil {
+		t.Error("Expected error for invalid address")
+	}
+}
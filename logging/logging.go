@@ -0,0 +1,226 @@
+// Package logging is a small structured logger with levels, text or JSON output and key-value fields, eg.
+//
+//	var log = logging.Module("blockcheck")
+//	log.Info("block checked", "block", 13000000, "miner", "0x...")
+//
+// All loggers write to the sinks set with Configure (stdout by default).
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel parses debug, info, warn or error
+func ParseLevel(s string) (Level, error) {
+	for level, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return level, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("invalid log level '%s' (debug, info, warn, error)", s)
+}
+
+// Output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// output is shared by all loggers
+var output = struct {
+	sync.Mutex
+	writer  io.Writer
+	console io.Writer
+	level   Level
+	format  string
+	file    *os.File
+}{writer: os.Stdout, console: os.Stdout, level: LevelInfo, format: FormatText}
+
+// Configure sets the level and format of all loggers. With a filename, logs are also appended to this file.
+func Configure(level string, format string, filename string) error {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	if format != FormatText && format != FormatJSON {
+		return fmt.Errorf("invalid log format '%s' (text, json)", format)
+	}
+
+	var file *os.File
+	if filename != "" {
+		file, err = os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("error opening log file: %w", err)
+		}
+	}
+
+	output.Lock()
+	defer output.Unlock()
+	if output.file != nil {
+		output.file.Close()
+	}
+	output.level = lvl
+	output.format = format
+	output.file = file
+	output.writer = consoleAndFile()
+	return nil
+}
+
+func consoleAndFile() io.Writer {
+	if output.file != nil {
+		return io.MultiWriter(output.console, output.file)
+	}
+	return output.console
+}
+
+// SetConsole replaces stdout as console sink (eg. with a terminal dashboard), the log file is kept
+func SetConsole(w io.Writer) {
+	output.Lock()
+	defer output.Unlock()
+	output.console = w
+	output.writer = consoleAndFile()
+}
+
+// SetOutput replaces the sinks (eg. for tests)
+func SetOutput(w io.Writer) {
+	output.Lock()
+	defer output.Unlock()
+	output.writer = w
+}
+
+// Enabled returns true if messages of this level are logged
+func Enabled(level Level) bool {
+	output.Lock()
+	defer output.Unlock()
+	return level >= output.level
+}
+
+// Logger adds its fields to every message
+type Logger struct {
+	fields []interface{} // key-value pairs
+}
+
+// Module returns a logger with the module field set
+func Module(name string) *Logger {
+	return &Logger{fields: []interface{}{"module", name}}
+}
+
+// With returns a logger with additional key-value fields, eg. log.With("block", 13000000, "miner", "0x...")
+func (l *Logger) With(keysAndValues ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keysAndValues))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keysAndValues...)
+	return &Logger{fields: fields}
+}
+
+func (l *Logger) Debug(msg string, keysAndValues ...interface{}) {
+	l.log(LevelDebug, msg, keysAndValues)
+}
+
+func (l *Logger) Info(msg string, keysAndValues ...interface{}) {
+	l.log(LevelInfo, msg, keysAndValues)
+}
+
+func (l *Logger) Warn(msg string, keysAndValues ...interface{}) {
+	l.log(LevelWarn, msg, keysAndValues)
+}
+
+func (l *Logger) Error(msg string, keysAndValues ...interface{}) {
+	l.log(LevelError, msg, keysAndValues)
+}
+
+// Fatal logs the message as error and exits
+func (l *Logger) Fatal(msg string, keysAndValues ...interface{}) {
+	l.log(LevelError, msg, keysAndValues)
+	os.Exit(1)
+}
+
+func (l *Logger) log(level Level, msg string, keysAndValues []interface{}) {
+	output.Lock()
+	defer output.Unlock()
+	if level < output.level {
+		return
+	}
+
+	fields := make(map[string]interface{})
+	keys := []string{}
+	for _, kv := range [][]interface{}{l.fields, keysAndValues} {
+		for i := 0; i < len(kv); i += 2 {
+			key := fmt.Sprint(kv[i])
+			var value interface{} = "MISSING"
+			if i+1 < len(kv) {
+				value = kv[i+1]
+			}
+			if err, ok := value.(error); ok {
+				value = err.Error()
+			} else if s, ok := value.(fmt.Stringer); ok {
+				value = s.String()
+			}
+			if _, found := fields[key]; !found {
+				keys = append(keys, key)
+			}
+			fields[key] = value
+		}
+	}
+
+	now := time.Now().UTC()
+	var line string
+	if output.format == FormatJSON {
+		fields["time"] = now.Format(time.RFC3339Nano)
+		fields["level"] = level.String()
+		fields["msg"] = msg
+		data, err := json.Marshal(fields)
+		if err != nil {
+			data, _ = json.Marshal(map[string]string{"time": now.Format(time.RFC3339Nano), "level": level.String(), "msg": msg, "error": err.Error()})
+		}
+		line = string(data) + "\n"
+	} else {
+		line = fmt.Sprintf("%s %-5s %s", now.Format("2006/01/02 15:04:05"), strings.ToUpper(level.String()), msg)
+		sort.SliceStable(keys, func(i, j int) bool { return keys[i] == "module" && keys[j] != "module" })
+		for _, key := range keys {
+			line += fmt.Sprintf(" %s=%s", key, formatTextValue(fields[key]))
+		}
+		line += "\n"
+	}
+
+	io.WriteString(output.writer, line)
+}
+
+// formatTextValue quotes values with spaces, multi-line values are put on their own lines
+func formatTextValue(value interface{}) string {
+	s := fmt.Sprint(value)
+	if strings.Contains(s, "\n") {
+		return "\n" + s
+	}
+	if s == "" || strings.ContainsAny(s, " \t\"=") {
+		return fmt.Sprintf("%q", s)
+	}
+	return s
+}
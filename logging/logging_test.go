@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTextOutput(t *testing.T) {
+	if err := Configure("info", FormatText, ""); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	SetOutput(&buf)
+
+	log := Module("blockcheck").With("block", 13000000)
+	log.Debug("not logged")
+	log.Info("block checked", "miner", "0xabc", "check", "bundle order", "err", errors.New("boom"))
+
+	line := buf.String()
+	if strings.Count(line, "\n") != 1 {
+		t.Fatal("Expected one line:", line)
+	}
+	for _, s := range []string{"INFO", "block checked", "module=blockcheck", "block=13000000", "miner=0xabc", `check="bundle order"`, "err=boom"} {
+		if !strings.Contains(line, s) {
+			t.Errorf("Missing %s in %s", s, line)
+		}
+	}
+	if strings.Index(line, "module=") > strings.Index(line, "block=") {
+		t.Error("module should be the first field:", line)
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	if err := Configure("debug", FormatJSON, ""); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	SetOutput(&buf)
+
+	Module("api").Debug("retry", "attempt", 2)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err, buf.String())
+	}
+	if entry["level"] != "debug" || entry["msg"] != "retry" || entry["module"] != "api" || entry["attempt"] != float64(2) || entry["time"] == nil {
+		t.Error("Unexpected entry:", entry)
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	if err := Configure("verbose", FormatText, ""); err == nil {
+		t.Error("Expected an error for an invalid level")
+	}
+	if err := Configure("info", "xml", ""); err == nil {
+		t.Error("Expected an error for an invalid format")
+	}
+
+	filename := filepath.Join(t.TempDir(), "test.log")
+	if err := Configure("warn", FormatText, filename); err != nil {
+		t.Fatal(err)
+	}
+	Module("test").Info("not logged")
+	Module("test").Warn("logged")
+	defer Configure("info", FormatText, "")
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "logged") || strings.Contains(string(data), "not logged") {
+		t.Error("Unexpected log file:", string(data))
+	}
+}
+
+func TestSetConsole(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.log")
+	if err := Configure("info", FormatText, filename); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	SetConsole(&buf)
+	defer SetConsole(os.Stdout)
+	defer Configure("info", FormatText, "")
+
+	Module("test").Info("to console and file")
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "to console and file") || !strings.Contains(string(data), "to console and file") {
+		t.Error("Expected the message in both sinks:", buf.String(), string(data))
+	}
+}
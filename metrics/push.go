@@ -0,0 +1,330 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/common"
+)
+
+// Name of the per-block measurement (InfluxDB) or metric path prefix (Graphite)
+var PointName = "flashbots_block"
+
+// Points which couldn't be sent are kept up to this many, the oldest are dropped
+var MaxBufferedPoints = 10000
+
+// Point is a measurement of one block
+type Point struct {
+	Name   string
+	Tags   map[string]string
+	Fields map[string]float64
+	Time   time.Time
+}
+
+// NewPoint returns the measurement of a checked block: bundles, rewards, gas share, error flags and counts by type,
+// tagged with the miner. The API lag is added if >= 0.
+func NewPoint(check *blockcheck.BlockCheck, apiLag int64) Point {
+	share := NewBlockShare(check)
+	p := Point{
+		Name: PointName,
+		Tags: map[string]string{"miner": check.Miner},
+		Fields: map[string]float64{
+			"block":               float64(check.Number),
+			"tx":                  float64(len(check.EthBlock.Transactions())),
+			"gas_used":            float64(share.GasUsed),
+			"bundles":             float64(share.NumBundles),
+			"bundle_gas":          float64(share.BundleGas),
+			"gas_share":           share.GasShare(),
+			"bundle_reward_eth":   share.BundleReward,
+			"errors":              float64(len(check.Errors)),
+			"serious_errors":      boolField(check.HasSeriousErrors()),
+			"less_serious_errors": boolField(check.HasLessSeriousErrors()),
+			"failed_tx":           float64(len(check.FailedTx)),
+		},
+		Time: time.Unix(int64(check.EthBlock.Time()), 0),
+	}
+	if check.MinerName != "" {
+		p.Tags["miner_name"] = check.MinerName
+	}
+	for errorType, count := range check.ErrorCounter.Map() {
+		p.Fields["error_"+errorType] = float64(count)
+	}
+	if apiLag >= 0 {
+		p.Fields["api_lag"] = float64(apiLag)
+	}
+	if cost := check.FailedTxCost(); cost != nil && cost.Sign() > 0 {
+		p.Fields["failed_tx_cost_eth"] = common.WeiToEthFloat64(cost)
+	}
+	return p
+}
+
+func boolField(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// PushBackend sends points to a time-series database
+type PushBackend interface {
+	Write(ctx context.Context, points []Point) error
+}
+
+// OpenPushBackend returns the backend for an URL: http(s)://... is the write URL of InfluxDB (eg.
+// http://localhost:8086/write?db=flashbots for 1.x, or http://localhost:8086/api/v2/write?org=o&bucket=b for 2.x),
+// graphite://host:2003 (or tcp://) the plaintext port of Graphite (carbon). The token is sent to InfluxDB as
+// Authorization header ("Token <token>"), if set.
+func OpenPushBackend(uri string, token string) (PushBackend, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push url %s: %w", uri, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &InfluxBackend{URL: uri, Token: token}, nil
+	case "graphite", "tcp":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid push url %s: missing host", uri)
+		}
+		return &GraphiteBackend{Addr: u.Host}, nil
+	}
+	return nil, fmt.Errorf("invalid push url %s (http(s):// for InfluxDB, graphite:// for Graphite)", uri)
+}
+
+// InfluxBackend writes the points in the InfluxDB line protocol (second precision)
+type InfluxBackend struct {
+	URL    string // write URL, see OpenPushBackend
+	Token  string
+	Client *http.Client
+}
+
+func (b *InfluxBackend) Write(ctx context.Context, points []Point) error {
+	var body bytes.Buffer
+	for _, p := range points {
+		body.WriteString(InfluxLine(p))
+		body.WriteByte('\n')
+	}
+
+	writeUrl := b.URL
+	if strings.Contains(writeUrl, "?") {
+		writeUrl += "&precision=s"
+	} else {
+		writeUrl += "?precision=s"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, writeUrl, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if b.Token != "" {
+		req.Header.Set("Authorization", "Token "+b.Token)
+	}
+	client := b.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("influxdb write error: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("influxdb write error: %s", resp.Status)
+	}
+	return nil
+}
+
+var influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// InfluxLine returns the point in the line protocol, eg. "flashbots_block,miner=0x... bundles=2,gas_share=0.1 1634000000"
+func InfluxLine(p Point) string {
+	var line strings.Builder
+	line.WriteString(strings.NewReplacer(",", `\,`, " ", `\ `).Replace(p.Name))
+	for _, key := range sortedKeys(p.Tags) {
+		if p.Tags[key] != "" {
+			line.WriteString("," + influxEscaper.Replace(key) + "=" + influxEscaper.Replace(p.Tags[key]))
+		}
+	}
+	for i, key := range sortedKeys(p.Fields) {
+		if i == 0 {
+			line.WriteByte(' ')
+		} else {
+			line.WriteByte(',')
+		}
+		line.WriteString(influxEscaper.Replace(key) + "=" + strconv.FormatFloat(p.Fields[key], 'f', -1, 64))
+	}
+	line.WriteString(" " + strconv.FormatInt(p.Time.Unix(), 10))
+	return line.String()
+}
+
+// GraphiteBackend writes the points in the Graphite plaintext protocol, one tagged series per field (eg.
+// "flashbots_block.bundles;miner=0x... 2 1634000000"). The tags need Graphite 1.1 or later.
+type GraphiteBackend struct {
+	Addr string // host:port
+}
+
+func (b *GraphiteBackend) Write(ctx context.Context, points []Point) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", b.Addr)
+	if err != nil {
+		return fmt.Errorf("graphite write error: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var body bytes.Buffer
+	for _, p := range points {
+		for _, line := range GraphiteLines(p) {
+			body.WriteString(line)
+			body.WriteByte('\n')
+		}
+	}
+	if _, err = conn.Write(body.Bytes()); err != nil {
+		return fmt.Errorf("graphite write error: %w", err)
+	}
+	return nil
+}
+
+// Graphite doesn't allow these in tag values
+var graphiteEscaper = strings.NewReplacer(";", "_", "~", "_", " ", "_", "!", "_", "^", "_")
+
+// GraphiteLines returns one line per field of the point
+func GraphiteLines(p Point) (lines []string) {
+	var tags string
+	for _, key := range sortedKeys(p.Tags) {
+		if p.Tags[key] != "" {
+			tags += ";" + graphiteEscaper.Replace(key) + "=" + graphiteEscaper.Replace(p.Tags[key])
+		}
+	}
+	for _, key := range sortedKeys(p.Fields) {
+		lines = append(lines, fmt.Sprintf("%s.%s%s %s %d", p.Name, key, tags, strconv.FormatFloat(p.Fields[key], 'f', -1, 64), p.Time.Unix()))
+	}
+	return lines
+}
+
+// ParseTags parses the tags of the points, eg. "instance=eu-1,network=mainnet"
+func ParseTags(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid tag '%s' (key=value)", pair)
+		}
+		tags[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return tags, nil
+}
+
+func sortedKeys(m interface{}) (keys []string) {
+	switch m := m.(type) {
+	case map[string]string:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]float64:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Pusher sends a Point for every checked block to a PushBackend. It's a watcher.CheckSink: the points are buffered,
+// and sent by Run every interval (kept and sent again if the backend is unreachable). It's safe for concurrent use.
+type Pusher struct {
+	Backend PushBackend
+	Tags    map[string]string // added to every point, eg. instance=eu-1
+	ApiLag  func() int64      // optional, returns the lag of the mev-blocks API in blocks (< 0 if unknown)
+
+	lock    sync.Mutex
+	points  []Point
+	dropped int // points dropped since the last successful flush
+}
+
+func NewPusher(backend PushBackend, tags map[string]string) *Pusher {
+	return &Pusher{Backend: backend, Tags: tags}
+}
+
+// SaveCheck buffers the point of the block
+func (p *Pusher) SaveCheck(check *blockcheck.BlockCheck) error {
+	apiLag := int64(-1)
+	if p.ApiLag != nil {
+		apiLag = p.ApiLag()
+	}
+	point := NewPoint(check, apiLag)
+	for key, value := range p.Tags {
+		point.Tags[key] = value
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.points = append(p.points, point)
+	if len(p.points) > MaxBufferedPoints {
+		p.dropped += len(p.points) - MaxBufferedPoints
+		p.points = p.points[len(p.points)-MaxBufferedPoints:]
+	}
+	return nil
+}
+
+// Flush sends the buffered points. On error they are kept for the next flush.
+func (p *Pusher) Flush(ctx context.Context) error {
+	p.lock.Lock()
+	points := p.points
+	p.points = nil
+	p.lock.Unlock()
+	if len(points) == 0 {
+		return nil
+	}
+
+	if err := p.Backend.Write(ctx, points); err != nil {
+		p.lock.Lock()
+		p.points = append(points, p.points...)
+		dropped := p.dropped
+		p.lock.Unlock()
+		return fmt.Errorf("error pushing %d points (%d dropped): %w", len(points), dropped, err)
+	}
+	p.lock.Lock()
+	p.dropped = 0
+	p.lock.Unlock()
+	return nil
+}
+
+// Run flushes the points every interval until the context is cancelled, and a last time then. Errors are sent to
+// onError.
+func (p *Pusher) Run(ctx context.Context, interval time.Duration, onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := p.Flush(flushCtx); err != nil {
+				onError(err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			flushCtx, cancel := context.WithTimeout(ctx, interval)
+			if err := p.Flush(flushCtx); err != nil {
+				onError(err)
+			}
+			cancel()
+		}
+	}
+}
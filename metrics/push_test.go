@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPoint() Point {
+	return Point{
+		Name:   "flashbots_block",
+		Tags:   map[string]string{"miner": "0xabc", "miner_name": "Some Pool", "instance": "eu,1"},
+		Fields: map[string]float64{"bundles": 2, "gas_share": 0.25},
+		Time:   time.Unix(1634000000, 0),
+	}
+}
+
+func TestInfluxLine(t *testing.T) {
+	expected := `flashbots_block,instance=eu\,1,miner=0xabc,miner_name=Some\ Pool bundles=2,gas_share=0.25 1634000000`
+	if line := InfluxLine(testPoint()); line != expected {
+		t.Errorf("Expected %s, got %s", expected, line)
+	}
+}
+
+func TestGraphiteLines(t *testing.T) {
+	lines := GraphiteLines(testPoint())
+	expected := []string{
+		"flashbots_block.bundles;instance=eu,1;miner=0xabc;miner_name=Some_Pool 2 1634000000",
+		"flashbots_block.gas_share;instance=eu,1;miner=0xabc;miner_name=Some_Pool 0.25 1634000000",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected lines: %v", lines)
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tags, err := ParseTags("instance=eu-1, network=mainnet")
+	if err != nil || len(tags) != 2 || tags["instance"] != "eu-1" || tags["network"] != "mainnet" {
+		t.Error("Unexpected tags:", tags, err)
+	}
+	if _, err := ParseTags("instance"); err == nil {
+		t.Error("Expected an error for a tag without value")
+	}
+}
+
+func TestInfluxBackend(t *testing.T) {
+	var body, query, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body, query, auth = string(b), r.URL.RawQuery, r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	backend, err := OpenPushBackend(server.URL+"/write?db=flashbots", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Write(context.Background(), []Point{testPoint()}); err != nil {
+		t.Fatal(err)
+	}
+	if body != InfluxLine(testPoint())+"\n" || query != "db=flashbots&precision=s" || auth != "Token secret" {
+		t.Errorf("Unexpected request: %s %s %s", query, auth, body)
+	}
+}
+
+func TestGraphiteBackend(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan []string)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var lines []string
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		received <- lines
+	}()
+
+	backend, err := OpenPushBackend("graphite://"+listener.Addr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Write(context.Background(), []Point{testPoint()}); err != nil {
+		t.Fatal(err)
+	}
+	if lines := <-received; strings.Join(lines, "\n") != strings.Join(GraphiteLines(testPoint()), "\n") {
+		t.Errorf("Unexpected lines: %v", lines)
+	}
+}
+
+type testBackend struct {
+	err    error
+	points []Point
+}
+
+func (b *testBackend) Write(ctx context.Context, points []Point) error {
+	if b.err != nil {
+		return b.err
+	}
+	b.points = append(b.points, points...)
+	return nil
+}
+
+func TestPusherFlush(t *testing.T) {
+	backend := &testBackend{err: errors.New("unreachable")}
+	pusher := NewPusher(backend, nil)
+	pusher.points = []Point{testPoint(), testPoint()}
+
+	// The points are kept while the backend is unreachable
+	if err := pusher.Flush(context.Background()); err == nil || len(pusher.points) != 2 {
+		t.Fatal("Expected an error and the points to be kept", err, len(pusher.points))
+	}
+	backend.err = nil
+	if err := pusher.Flush(context.Background()); err != nil || len(pusher.points) != 0 || len(backend.points) != 2 {
+		t.Fatal("Expected the points to be sent", err, len(pusher.points), len(backend.points))
+	}
+}
@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/metachris/flashbots/labels"
+)
+
+// Defaults of NewRewardTracker
+var (
+	RewardWindow    = 1000 // blocks of the averages
+	RewardMinBlocks = 20   // blocks a miner needs in the window before it's compared
+)
+
+// RewardAnomalyAlert is sent when the average bundle reward per block of a miner deviates from the network average by
+// more than the factor of the RewardTracker, and again with Resolved when it's back in range
+type RewardAnomalyAlert struct {
+	Miner          string
+	Blocks         int     // of the miner in the window
+	MinerAverage   float64 // bundle reward per block (ETH)
+	NetworkAverage float64 // bundle reward per block of all blocks in the window (ETH)
+	Resolved       bool
+}
+
+// Ratio is the average of the miner relative to the network average
+func (a *RewardAnomalyAlert) Ratio() float64 {
+	if a.NetworkAverage == 0 {
+		return 0
+	}
+	return a.MinerAverage / a.NetworkAverage
+}
+
+func (a *RewardAnomalyAlert) String() string {
+	miner := a.Miner
+	if name := labels.Default.Name(a.Miner); name != "" {
+		miner = fmt.Sprintf("%s (%s)", a.Miner, name)
+	}
+	averages := fmt.Sprintf("%.4f ETH bundle reward per block in its last %d blocks, network average %.4f ETH", a.MinerAverage, a.Blocks, a.NetworkAverage)
+	switch {
+	case a.Resolved:
+		return fmt.Sprintf("bundle rewards of miner %s are back in range: %s", miner, averages)
+	case a.Ratio() < 1:
+		return fmt.Sprintf("miner %s earns %.0f%% less from bundles than the network: %s (possible misconfiguration leaving MEV on the table)", miner, (1-a.Ratio())*100, averages)
+	default:
+		return fmt.Sprintf("miner %s earns %.1fx more from bundles than the network: %s (possible off-protocol deals)", miner, a.Ratio(), averages)
+	}
+}
+
+// RewardTracker compares the average bundle reward per block of each miner with the network average, over the last
+// Window blocks. Adding a block again (re-check, reorg) replaces it. It's safe for concurrent use.
+type RewardTracker struct {
+	Factor    float64 // alert if a miner's average is below network / Factor or above network * Factor
+	Window    int
+	MinBlocks int
+
+	lock     sync.Mutex
+	blocks   []BlockShare // ascending by number
+	alerting map[string]bool
+}
+
+func NewRewardTracker(factor float64) *RewardTracker {
+	return &RewardTracker{
+		Factor:    factor,
+		Window:    RewardWindow,
+		MinBlocks: RewardMinBlocks,
+		alerting:  make(map[string]bool),
+	}
+}
+
+// Add adds the bundle reward of a block, and returns an alert when the average of its miner started or stopped
+// deviating from the network average
+func (t *RewardTracker) Add(share BlockShare) *RewardAnomalyAlert {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	i := sort.Search(len(t.blocks), func(i int) bool { return t.blocks[i].Number >= share.Number })
+	if i < len(t.blocks) && t.blocks[i].Number == share.Number {
+		t.blocks[i] = share
+	} else {
+		t.blocks = append(t.blocks, BlockShare{})
+		copy(t.blocks[i+1:], t.blocks[i:])
+		t.blocks[i] = share
+	}
+	if len(t.blocks) > t.Window {
+		t.blocks = t.blocks[len(t.blocks)-t.Window:]
+	}
+
+	miner := strings.ToLower(share.Miner)
+	alert := t.compare(miner)
+	if alert == nil {
+		return nil
+	}
+	deviates := t.deviates(alert)
+	switch {
+	case deviates && !t.alerting[miner]:
+		t.alerting[miner] = true
+		return alert
+	case !deviates && t.alerting[miner]:
+		delete(t.alerting, miner)
+		alert.Resolved = true
+		return alert
+	}
+	return nil
+}
+
+// compare returns the averages of the miner and the network, nil if the miner has less than MinBlocks blocks
+func (t *RewardTracker) compare(miner string) *RewardAnomalyAlert {
+	alert := &RewardAnomalyAlert{Miner: miner}
+	var minerTotal, networkTotal float64
+	for _, block := range t.blocks {
+		networkTotal += block.BundleReward
+		if strings.EqualFold(block.Miner, miner) {
+			alert.Blocks += 1
+			minerTotal += block.BundleReward
+		}
+	}
+	if alert.Blocks < t.MinBlocks {
+		return nil
+	}
+	alert.MinerAverage = minerTotal / float64(alert.Blocks)
+	alert.NetworkAverage = networkTotal / float64(len(t.blocks))
+	return alert
+}
+
+func (t *RewardTracker) deviates(alert *RewardAnomalyAlert) bool {
+	if t.Factor <= 1 || alert.NetworkAverage <= 0 {
+		return false
+	}
+	return alert.Ratio() < 1/t.Factor || alert.Ratio() > t.Factor
+}
+
+// Anomalies returns the miners whose averages currently deviate, by miner
+func (t *RewardTracker) Anomalies() (alerts []*RewardAnomalyAlert) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for miner := range t.alerting {
+		if alert := t.compare(miner); alert != nil {
+			alerts = append(alerts, alert)
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].Miner < alerts[j].Miner })
+	return alerts
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRewardTracker(t *testing.T) {
+	tracker := NewRewardTracker(2)
+	tracker.MinBlocks = 3
+	add := func(number int64, miner string, reward float64) *RewardAnomalyAlert {
+		return tracker.Add(BlockShare{Number: number, Miner: miner, BundleReward: reward})
+	}
+
+	// Miner A earns 1 ETH per block, miner B 0.1 ETH
+	var alert *RewardAnomalyAlert
+	for number := int64(1); number <= 6; number += 2 {
+		if alert := add(number, "0xA", 1); alert != nil {
+			t.Fatal("unexpected alert", alert)
+		}
+		alert = add(number+1, "0xB", 0.1)
+		if number < 5 && alert != nil {
+			t.Fatal("alert before MinBlocks", alert)
+		}
+	}
+	alert = tracker.Anomalies()[0]
+	if alert.Miner != "0xb" || alert.Blocks != 3 || alert.Ratio() > 0.5 || !strings.Contains(alert.String(), "less") {
+		t.Fatal("expected an anomaly of miner B", alert)
+	}
+	if alert := add(7, "0xB", 0.1); alert != nil {
+		t.Fatal("the alert is only sent once", alert)
+	}
+
+	// Re-checks of miner B's blocks with higher rewards bring it back in range
+	alert = nil
+	for _, number := range []int64{2, 4, 6, 7} {
+		if resolved := add(number, "0xB", 1); resolved != nil {
+			alert = resolved
+		}
+	}
+	if alert == nil || !alert.Resolved || alert.Miner != "0xb" {
+		t.Fatal("expected a resolved alert", alert)
+	}
+	if anomalies := tracker.Anomalies(); len(anomalies) != 0 {
+		t.Error("unexpected anomalies", anomalies)
+	}
+}
@@ -0,0 +1,172 @@
+// Package metrics tracks the share of the block gas used by Flashbots bundles and the number of bundles per block,
+// with rolling averages, and detects when no bundles land for many consecutive blocks (eg. a relay outage) or when the
+// bundle reward per block of a miner deviates from the network average.
+// The Pusher sends a measurement per block to InfluxDB or Graphite.
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/common"
+)
+
+// Windows of the rolling averages, in blocks
+var ShareWindows = []int{10, 100, 1000}
+
+// BlockShare is the Flashbots share of one block
+type BlockShare struct {
+	Number       int64
+	Miner        string
+	GasUsed      uint64 // of the block
+	BundleGas    uint64 // used by the bundle tx
+	NumBundles   int
+	BundleReward float64 // total miner reward of the bundles (ETH)
+}
+
+func NewBlockShare(check *blockcheck.BlockCheck) BlockShare {
+	share := BlockShare{
+		Number:     check.Number,
+		Miner:      check.Miner,
+		GasUsed:    check.EthBlock.GasUsed(),
+		NumBundles: len(check.Bundles),
+	}
+	for _, bundle := range check.Bundles {
+		share.BundleGas += bundle.TotalGasUsed.Uint64()
+		share.BundleReward += common.WeiToEthFloat64(bundle.TotalMinerReward)
+	}
+	return share
+}
+
+// GasShare returns the share of the block gas used by bundles (0 to 1)
+func (s BlockShare) GasShare() float64 {
+	if s.GasUsed == 0 {
+		return 0
+	}
+	return float64(s.BundleGas) / float64(s.GasUsed)
+}
+
+// ShareAverages are the rolling averages of the last Window blocks
+type ShareAverages struct {
+	Window          int     `json:"window"`            // blocks
+	Blocks          int     `json:"blocks"`            // in the window (fewer at the start)
+	GasShare        float64 `json:"gas_share"`         // mean share of the block gas used by bundles (0 to 1)
+	Bundles         float64 `json:"bundles"`           // mean bundles per block
+	ZeroShareBlocks int     `json:"zero_share_blocks"` // blocks without bundles
+}
+
+// ZeroShareAlert is sent when no bundles landed for ShareTracker.ZeroShareBlocks consecutive blocks, and again
+// with Resolved when the next bundle landed
+type ZeroShareAlert struct {
+	FirstBlock int64 // of the streak
+	LastBlock  int64
+	Blocks     int
+	Resolved   bool
+}
+
+func (a *ZeroShareAlert) String() string {
+	if a.Resolved {
+		return fmt.Sprintf("Flashbots bundles landed again in block %d, after %d blocks without bundles (since block %d)", a.LastBlock, a.Blocks, a.FirstBlock)
+	}
+	return fmt.Sprintf("no Flashbots bundles in the last %d blocks (%d to %d), possible relay outage", a.Blocks, a.FirstBlock, a.LastBlock)
+}
+
+// ShareTracker keeps the shares of the recent blocks. Adding a block again (re-check, reorg) replaces it. It's safe
+// for concurrent use.
+type ShareTracker struct {
+	ZeroShareBlocks int // alert after this many consecutive blocks without bundles (0 = disabled)
+
+	lock       sync.Mutex
+	blocks     []BlockShare // ascending by number
+	maxBlocks  int
+	alerting   bool
+	zeroStreak ZeroShareAlert // the current streak, while alerting
+}
+
+func NewShareTracker(zeroShareBlocks int) *ShareTracker {
+	maxBlocks := zeroShareBlocks
+	for _, window := range ShareWindows {
+		if window > maxBlocks {
+			maxBlocks = window
+		}
+	}
+	return &ShareTracker{ZeroShareBlocks: zeroShareBlocks, maxBlocks: maxBlocks}
+}
+
+// Add adds the share of a block, and returns an alert when a streak of blocks without bundles reached
+// ZeroShareBlocks, or ended
+func (t *ShareTracker) Add(share BlockShare) *ZeroShareAlert {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	i := sort.Search(len(t.blocks), func(i int) bool { return t.blocks[i].Number >= share.Number })
+	if i < len(t.blocks) && t.blocks[i].Number == share.Number {
+		t.blocks[i] = share
+	} else {
+		t.blocks = append(t.blocks, BlockShare{})
+		copy(t.blocks[i+1:], t.blocks[i:])
+		t.blocks[i] = share
+	}
+	if len(t.blocks) > t.maxBlocks {
+		t.blocks = t.blocks[len(t.blocks)-t.maxBlocks:]
+	}
+
+	if t.ZeroShareBlocks <= 0 {
+		return nil
+	}
+
+	// Blocks without bundles at the end
+	streak := 0
+	for j := len(t.blocks) - 1; j >= 0 && t.blocks[j].NumBundles == 0; j-- {
+		streak += 1
+	}
+
+	switch {
+	case !t.alerting && streak >= t.ZeroShareBlocks:
+		t.alerting = true
+		first := t.blocks[len(t.blocks)-streak].Number
+		t.zeroStreak = ZeroShareAlert{FirstBlock: first, LastBlock: t.blocks[len(t.blocks)-1].Number, Blocks: streak}
+		alert := t.zeroStreak
+		return &alert
+	case t.alerting && streak == 0:
+		t.alerting = false
+		return &ZeroShareAlert{FirstBlock: t.zeroStreak.FirstBlock, LastBlock: share.Number, Blocks: int(share.Number - t.zeroStreak.FirstBlock), Resolved: true}
+	}
+	return nil
+}
+
+// Averages returns the rolling averages of the last window blocks
+func (t *ShareTracker) Averages(window int) ShareAverages {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	blocks := t.blocks
+	if len(blocks) > window {
+		blocks = blocks[len(blocks)-window:]
+	}
+	averages := ShareAverages{Window: window, Blocks: len(blocks)}
+	if len(blocks) == 0 {
+		return averages
+	}
+	for _, block := range blocks {
+		averages.GasShare += block.GasShare()
+		averages.Bundles += float64(block.NumBundles)
+		if block.NumBundles == 0 {
+			averages.ZeroShareBlocks += 1
+		}
+	}
+	averages.GasShare /= float64(len(blocks))
+	averages.Bundles /= float64(len(blocks))
+	return averages
+}
+
+// AllAverages returns the averages of all ShareWindows
+func (t *ShareTracker) AllAverages() []ShareAverages {
+	averages := make([]ShareAverages, 0, len(ShareWindows))
+	for _, window := range ShareWindows {
+		averages = append(averages, t.Averages(window))
+	}
+	return averages
+}
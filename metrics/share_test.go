@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestShareTracker(t *testing.T) {
+	tracker := NewShareTracker(3)
+	add := func(number int64, bundles int) *ZeroShareAlert {
+		share := BlockShare{Number: number, GasUsed: 1000, NumBundles: bundles}
+		if bundles > 0 {
+			share.BundleGas = 250
+		}
+		return tracker.Add(share)
+	}
+
+	for number := int64(1); number <= 4; number++ {
+		if alert := add(number, 1); alert != nil {
+			t.Fatal("unexpected alert", alert)
+		}
+	}
+	add(5, 0)
+	if alert := add(6, 0); alert != nil {
+		t.Fatal("alert before 3 blocks without bundles", alert)
+	}
+	alert := add(7, 0)
+	if alert == nil || alert.Resolved || alert.FirstBlock != 5 || alert.LastBlock != 7 || alert.Blocks != 3 {
+		t.Fatal("expected an alert for blocks 5 to 7", alert)
+	}
+	if alert := add(8, 0); alert != nil {
+		t.Fatal("the alert is only sent once", alert)
+	}
+
+	// A re-check of block 8 with bundles ends the streak
+	alert = add(8, 2)
+	if alert == nil || !alert.Resolved || alert.FirstBlock != 5 || alert.LastBlock != 8 {
+		t.Fatal("expected a resolved alert", alert)
+	}
+
+	averages := tracker.Averages(4) // blocks 5 to 8
+	if averages.Blocks != 4 || averages.ZeroShareBlocks != 3 || averages.Bundles != 0.5 || math.Abs(averages.GasShare-0.0625) > 1e-9 {
+		t.Errorf("unexpected averages %+v", averages)
+	}
+	if averages := tracker.Averages(100); averages.Blocks != 8 || averages.ZeroShareBlocks != 3 {
+		t.Errorf("unexpected averages %+v", averages)
+	}
+	if all := tracker.AllAverages(); len(all) != len(ShareWindows) || all[0].Window != ShareWindows[0] {
+		t.Errorf("unexpected averages %+v", all)
+	}
+}
@@ -0,0 +1,184 @@
+// Import of mev-inspect-py classifications (https://github.com/flashbots/mev-inspect-py), to merge them with the block checks.
+//
+// Tables are imported as CSV only, reading the mev-inspect Postgres database directly is not supported (it would need
+// a database driver). Export the tables with:
+//
+//	\copy arbitrages TO 'arbitrages.csv' CSV HEADER
+//	\copy liquidations TO 'liquidations.csv' CSV HEADER
+//	\copy sandwiches TO 'sandwiches.csv' CSV HEADER
+package mevinspect
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+const (
+	TypeArbitrage   = "arbitrage"
+	TypeLiquidation = "liquidation"
+	TypeSandwich    = "sandwich"
+)
+
+var ErrUnknownCsvFormat = errors.New("unknown mev-inspect csv format")
+
+// Classification of a single transaction by mev-inspect
+type Classification struct {
+	Type        string
+	BlockNumber int64
+	TxHash      string
+	Account     string // searcher account (account_address, liquidator_user or sandwicher_address)
+}
+
+// Classifications holds the imported classifications, indexed by tx hash
+type Classifications struct {
+	ByTxHash map[string][]Classification
+}
+
+func NewClassifications() *Classifications {
+	return &Classifications{
+		ByTxHash: make(map[string][]Classification),
+	}
+}
+
+// LoadCsvFile imports a mev-inspect table export (arbitrages, liquidations or sandwiches). The type is detected by the header.
+func (c *Classifications) LoadCsvFile(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = c.LoadCsv(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
+}
+
+func (c *Classifications) LoadCsv(r io.Reader) error {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	// Detect the table, and the columns containing tx hashes and the searcher account
+	var classificationType, accountColumn string
+	var txHashColumns []string
+	switch {
+	case hasColumns(columns, "frontrun_swap_transaction_hash", "backrun_swap_transaction_hash"):
+		classificationType = TypeSandwich
+		txHashColumns = []string{"frontrun_swap_transaction_hash", "backrun_swap_transaction_hash"}
+		accountColumn = "sandwicher_address"
+	case hasColumns(columns, "liquidator_user", "transaction_hash"):
+		classificationType = TypeLiquidation
+		txHashColumns = []string{"transaction_hash"}
+		accountColumn = "liquidator_user"
+	case hasColumns(columns, "profit_token_address", "transaction_hash"):
+		classificationType = TypeArbitrage
+		txHashColumns = []string{"transaction_hash"}
+		accountColumn = "account_address"
+	default:
+		return ErrUnknownCsvFormat
+	}
+
+	if !hasColumns(columns, "block_number") {
+		return ErrUnknownCsvFormat
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		blockNumber, err := strconv.ParseInt(record[columns["block_number"]], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid block_number: %w", err)
+		}
+
+		account := ""
+		if i, found := columns[accountColumn]; found {
+			account = record[i]
+		}
+
+		for _, column := range txHashColumns {
+			txHash := strings.ToLower(record[columns[column]])
+			c.ByTxHash[txHash] = append(c.ByTxHash[txHash], Classification{
+				Type:        classificationType,
+				BlockNumber: blockNumber,
+				TxHash:      txHash,
+				Account:     account,
+			})
+		}
+	}
+}
+
+func hasColumns(columns map[string]int, names ...string) bool {
+	for _, name := range names {
+		if _, found := columns[name]; !found {
+			return false
+		}
+	}
+	return true
+}
+
+// BundleRecord is the unified record of a bundle: our check results plus the mev-inspect classifications
+type BundleRecord struct {
+	BlockNumber     int64
+	BundleIndex     int64
+	BundleHash      string
+	Classifications []Classification
+	BlockErrors     []string
+}
+
+func (r BundleRecord) Types() []string {
+	types := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, c := range r.Classifications {
+		if !seen[c.Type] {
+			types = append(types, c.Type)
+			seen[c.Type] = true
+		}
+	}
+	return types
+}
+
+func (r BundleRecord) String() string {
+	return fmt.Sprintf("block %d, bundle %d (%s): %s, errors: %d", r.BlockNumber, r.BundleIndex, r.BundleHash, strings.Join(r.Types(), ","), len(r.BlockErrors))
+}
+
+// Merge returns the unified records for all bundles of the check which have mev-inspect classifications
+func (c *Classifications) Merge(check *blockcheck.BlockCheck) (records []BundleRecord) {
+	for _, bundle := range check.Bundles {
+		record := BundleRecord{
+			BlockNumber: check.Number,
+			BundleIndex: bundle.Index,
+			BundleHash:  bundle.Hash,
+			BlockErrors: check.ErrorMessages(),
+		}
+
+		for _, tx := range bundle.Transactions {
+			record.Classifications = append(record.Classifications, c.ByTxHash[strings.ToLower(tx.Hash)]...)
+		}
+
+		if len(record.Classifications) > 0 {
+			records = append(records, record)
+		}
+	}
+	return records
+}
@@ -0,0 +1,38 @@
+package mevinspect
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadCsv(t *testing.T) {
+	arbitrages := `id,created_at,account_address,profit_token_address,block_number,transaction_hash,start_amount,end_amount,profit_amount
+1,2021-10-01,0xabc,0xc02a,13100622,0xAA11,1,2,1`
+	sandwiches := `id,created_at,block_number,sandwicher_address,frontrun_swap_transaction_hash,frontrun_swap_trace_address,backrun_swap_transaction_hash,backrun_swap_trace_address
+2,2021-10-01,13100622,0xdef,0xbb11,{},0xbb33,{}`
+
+	c := NewClassifications()
+	if err := c.LoadCsv(strings.NewReader(arbitrages)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoadCsv(strings.NewReader(sandwiches)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.ByTxHash) != 3 {
+		t.Error("Wrong amount of tx:", len(c.ByTxHash))
+	}
+
+	arb := c.ByTxHash["0xaa11"]
+	if len(arb) != 1 || arb[0].Type != TypeArbitrage || arb[0].BlockNumber != 13100622 || arb[0].Account != "0xabc" {
+		t.Error("Unexpected arbitrage classification:", arb)
+	}
+
+	if sw := c.ByTxHash["0xbb33"]; len(sw) != 1 || sw[0].Type != TypeSandwich {
+		t.Error("Unexpected sandwich classification:", sw)
+	}
+
+	if err := c.LoadCsv(strings.NewReader("a,b,c\n1,2,3")); err != ErrUnknownCsvFormat {
+		t.Error("Expected ErrUnknownCsvFormat, got:", err)
+	}
+}
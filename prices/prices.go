@@ -0,0 +1,149 @@
+// Package prices looks up historical token prices in USD, to express token amounts (eg. sandwich victim losses) in
+// USD at the time of a block.
+package prices
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metachris/flashbots/logging"
+)
+
+var log = logging.Module("prices")
+
+// ErrNoPrice is returned if the source has no price of the token around the time
+var ErrNoPrice = errors.New("no price")
+
+// Source returns the USD price of a token (contract address) at a time
+type Source interface {
+	PriceUSD(ctx context.Context, token string, t time.Time) (float64, error)
+}
+
+// SourceFunc is a function as Source
+type SourceFunc func(ctx context.Context, token string, t time.Time) (float64, error)
+
+func (f SourceFunc) PriceUSD(ctx context.Context, token string, t time.Time) (float64, error) {
+	return f(ctx, token, t)
+}
+
+const DefaultCoinGeckoUrl = "https://api.coingecko.com/api/v3"
+
+// Prices are looked up in this window around the time, the closest one is used (CoinGecko has 5-minute prices for
+// the last day, and hourly prices up to 90 days)
+var CoinGeckoPriceWindow = time.Hour
+
+// CoinGecko returns the historical prices of the CoinGecko API (https://www.coingecko.com/en/api). Prices are cached
+// per token and hour, the cache is reset when it reaches MaxCacheSize.
+type CoinGecko struct {
+	HttpClient   *http.Client
+	BaseUrl      string
+	Platform     string // asset platform of the token addresses
+	ApiKey       string
+	ApiKeyHeader string // x-cg-demo-api-key, or x-cg-pro-api-key for the pro API
+	MaxCacheSize int
+
+	lock  sync.Mutex
+	cache map[string]float64 // by token and hour
+}
+
+func NewCoinGecko(apiKey string) *CoinGecko {
+	return &CoinGecko{
+		HttpClient:   &http.Client{Timeout: 30 * time.Second},
+		BaseUrl:      DefaultCoinGeckoUrl,
+		Platform:     "ethereum",
+		ApiKey:       apiKey,
+		ApiKeyHeader: "x-cg-demo-api-key",
+		MaxCacheSize: 10000,
+		cache:        make(map[string]float64),
+	}
+}
+
+type marketChart struct {
+	Prices [][2]float64 `json:"prices"` // unix ms, price
+}
+
+// PriceUSD returns the price closest to the time, ErrNoPrice if there is none in CoinGeckoPriceWindow
+func (c *CoinGecko) PriceUSD(ctx context.Context, token string, t time.Time) (float64, error) {
+	token = strings.ToLower(token)
+	key := fmt.Sprintf("%s-%d", token, t.Unix()/3600)
+	c.lock.Lock()
+	price, found := c.cache[key]
+	c.lock.Unlock()
+	if found {
+		return price, nil
+	}
+
+	url := fmt.Sprintf("%s/coins/%s/contract/%s/market_chart/range?vs_currency=usd&from=%d&to=%d", c.BaseUrl, c.Platform, token, t.Add(-CoinGeckoPriceWindow).Unix(), t.Add(CoinGeckoPriceWindow).Unix())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	if c.ApiKey != "" {
+		req.Header.Set(c.ApiKeyHeader, c.ApiKey)
+	}
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("coingecko request error: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound { // unknown token
+		return 0, fmt.Errorf("%w: %s is unknown to coingecko", ErrNoPrice, token)
+	}
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("coingecko request error: %s - %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var chart marketChart
+	if err := json.NewDecoder(resp.Body).Decode(&chart); err != nil {
+		return 0, fmt.Errorf("coingecko response decode error: %w", err)
+	}
+	price, err = closestPrice(chart.Prices, t)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s at %s", err, token, t.UTC().Format(time.RFC3339))
+	}
+
+	c.lock.Lock()
+	if len(c.cache) >= c.MaxCacheSize {
+		c.cache = make(map[string]float64)
+	}
+	c.cache[key] = price
+	c.lock.Unlock()
+	return price, nil
+}
+
+// closestPrice returns the price of the point closest to the time
+func closestPrice(points [][2]float64, t time.Time) (float64, error) {
+	ms := float64(t.UnixNano() / int64(time.Millisecond))
+	price, minDiff := 0.0, -1.0
+	for _, point := range points {
+		diff := point[0] - ms
+		if diff < 0 {
+			diff = -diff
+		}
+		if minDiff < 0 || diff < minDiff {
+			price, minDiff = point[1], diff
+		}
+	}
+	if minDiff < 0 {
+		return 0, ErrNoPrice
+	}
+	return price, nil
+}
+
+// ValueUSD returns the USD value of an amount of a token with the decimals at the price
+func ValueUSD(amount *big.Int, decimals int, price float64) float64 {
+	value := new(big.Float).SetInt(amount)
+	value.Quo(value, new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)))
+	value.Mul(value, big.NewFloat(price))
+	ret, _ := value.Float64()
+	return ret
+}
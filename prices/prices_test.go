@@ -0,0 +1,61 @@
+package prices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCoinGecko(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests += 1
+		if r.Header.Get("x-cg-demo-api-key") != "key" {
+			t.Error("Missing api key")
+		}
+		if r.URL.Path == "/coins/ethereum/contract/0xunknown/market_chart/range" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Path != "/coins/ethereum/contract/0xtoken/market_chart/range" || r.URL.Query().Get("from") != "1629457200" || r.URL.Query().Get("to") != "1629464400" {
+			t.Error("Unexpected request:", r.URL.String())
+		}
+		fmt.Fprint(w, `{"prices": [[1629457200000, 1.0], [1629460500000, 2.0], [1629464400000, 3.0]]}`)
+	}))
+	defer server.Close()
+
+	source := NewCoinGecko("key")
+	source.BaseUrl = server.URL
+	blockTime := time.Unix(1629460800, 0)
+	for i := 0; i < 2; i++ { // the second lookup is cached
+		price, err := source.PriceUSD(context.Background(), "0xTOKEN", blockTime)
+		if err != nil || price != 2.0 {
+			t.Error("Wrong price:", price, err)
+		}
+	}
+	if requests != 1 {
+		t.Error("Expected the price to be cached, requests:", requests)
+	}
+
+	if _, err := source.PriceUSD(context.Background(), "0xunknown", blockTime); !errors.Is(err, ErrNoPrice) {
+		t.Error("Expected ErrNoPrice for an unknown token:", err)
+	}
+	if _, err := closestPrice(nil, blockTime); !errors.Is(err, ErrNoPrice) {
+		t.Error("Expected ErrNoPrice without prices:", err)
+	}
+}
+
+func TestValueUSD(t *testing.T) {
+	amount, _ := new(big.Int).SetString("2500000000000000000", 10)
+	if value := ValueUSD(amount, 18, 2000); value != 5000 {
+		t.Error("Wrong value:", value)
+	}
+	if value := ValueUSD(big.NewInt(1500e6), 6, 1); value != 1500 {
+		t.Error("Wrong value:", value)
+	}
+}
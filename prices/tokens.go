@@ -0,0 +1,139 @@
+package prices
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Function selectors of the pool and ERC20 calls
+var (
+	selectorToken0   = hexutil.MustDecode("0x0dfe1681")
+	selectorToken1   = hexutil.MustDecode("0xd21220a7")
+	selectorDecimals = hexutil.MustDecode("0x313ce567")
+	selectorSymbol   = hexutil.MustDecode("0x95d89b41")
+)
+
+// Token is an ERC20 token
+type Token struct {
+	Address  string // lowercase
+	Symbol   string // the shortened address if the token has no symbol
+	Decimals int
+}
+
+// Tokens looks up the tokens of Uniswap V2 and V3 pools and the ERC20 metadata with eth_call, and caches them (they
+// don't change)
+type Tokens struct {
+	client *rpc.Client
+
+	lock   sync.Mutex
+	pools  map[string][2]string // token0, token1 by pool address
+	tokens map[string]Token
+}
+
+func NewTokens(client *rpc.Client) *Tokens {
+	return &Tokens{
+		client: client,
+		pools:  make(map[string][2]string),
+		tokens: make(map[string]Token),
+	}
+}
+
+// PoolTokens returns the addresses of token0 and token1 of the pool
+func (l *Tokens) PoolTokens(ctx context.Context, pool string) (token0 string, token1 string, err error) {
+	pool = strings.ToLower(pool)
+	l.lock.Lock()
+	tokens, found := l.pools[pool]
+	l.lock.Unlock()
+	if found {
+		return tokens[0], tokens[1], nil
+	}
+
+	for i, selector := range [][]byte{selectorToken0, selectorToken1} {
+		result, err := l.call(ctx, pool, selector)
+		if err != nil {
+			return "", "", err
+		}
+		if len(result) < 32 {
+			return "", "", fmt.Errorf("pool %s: invalid token address %x", pool, result)
+		}
+		tokens[i] = strings.ToLower(ethcommon.BytesToAddress(result[:32]).Hex())
+	}
+
+	l.lock.Lock()
+	l.pools[pool] = tokens
+	l.lock.Unlock()
+	return tokens[0], tokens[1], nil
+}
+
+// Token returns the symbol and decimals of the token
+func (l *Tokens) Token(ctx context.Context, address string) (token Token, err error) {
+	address = strings.ToLower(address)
+	l.lock.Lock()
+	token, found := l.tokens[address]
+	l.lock.Unlock()
+	if found {
+		return token, nil
+	}
+
+	result, err := l.call(ctx, address, selectorDecimals)
+	if err != nil {
+		return token, err
+	}
+	if len(result) < 32 || new(big.Int).SetBytes(result[:32]).Cmp(big.NewInt(255)) > 0 {
+		return token, fmt.Errorf("token %s: invalid decimals %x", address, result)
+	}
+	token = Token{Address: address, Decimals: int(new(big.Int).SetBytes(result[:32]).Int64())}
+
+	// The symbol is optional, and some tokens return bytes32 instead of a string
+	result, err = l.call(ctx, address, selectorSymbol)
+	if err != nil {
+		log.Debug("token without symbol", "token", address, "err", err)
+	}
+	token.Symbol = decodeSymbol(result)
+	if token.Symbol == "" {
+		token.Symbol = address[:8] + "..."
+	}
+
+	l.lock.Lock()
+	l.tokens[address] = token
+	l.lock.Unlock()
+	return token, nil
+}
+
+func (l *Tokens) call(ctx context.Context, to string, data []byte) (hexutil.Bytes, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var result hexutil.Bytes
+	msg := map[string]interface{}{"to": to, "data": hexutil.Bytes(data)}
+	if err := l.client.CallContext(ctx, &result, "eth_call", msg, "latest"); err != nil {
+		return nil, fmt.Errorf("eth_call %s: %w", to, err)
+	}
+	return result, nil
+}
+
+// decodeSymbol decodes an ABI-encoded string, or a bytes32 padded with zeros
+func decodeSymbol(data []byte) string {
+	if len(data) >= 64 {
+		offset := new(big.Int).SetBytes(data[:32])
+		if offset.IsInt64() && offset.Int64()+32 <= int64(len(data)) {
+			start := offset.Int64() + 32
+			length := new(big.Int).SetBytes(data[offset.Int64():start])
+			if length.IsInt64() && start+length.Int64() <= int64(len(data)) {
+				return string(data[start : start+length.Int64()])
+			}
+		}
+	}
+	if len(data) == 32 {
+		return strings.TrimRight(string(data), "\x00")
+	}
+	return ""
+}
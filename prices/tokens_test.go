@@ -0,0 +1,25 @@
+package prices
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestDecodeSymbol(t *testing.T) {
+	// ABI-encoded string
+	data := hexutil.MustDecode("0x000000000000000000000000000000000000000000000000000000000000002000000000000000000000000000000000000000000000000000000000000000045745544800000000000000000000000000000000000000000000000000000000")
+	if symbol := decodeSymbol(data); symbol != "WETH" {
+		t.Error("Wrong string symbol:", symbol)
+	}
+
+	// bytes32 (eg. MKR)
+	data = hexutil.MustDecode("0x4d4b520000000000000000000000000000000000000000000000000000000000")
+	if symbol := decodeSymbol(data); symbol != "MKR" {
+		t.Error("Wrong bytes32 symbol:", symbol)
+	}
+
+	if symbol := decodeSymbol(nil); symbol != "" {
+		t.Error("Expected no symbol:", symbol)
+	}
+}
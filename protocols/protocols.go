@@ -0,0 +1,155 @@
+// Package protocols maps transactions to the protocols they interact with (Uniswap, Sushiswap, Aave, OpenSea, ...),
+// based on the "to" address and the 4-byte method selector of the calldata.
+//
+// The default registry is embedded (registry.json), and can be extended with a file in the same format:
+//
+//	{"protocols": [{"name": "MyDex", "addresses": {"0x...": "Router"}, "selectors": {"0x12345678": "swap"}}]}
+package protocols
+
+import (
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Unknown is the protocol of transactions which match neither a known address nor a known selector
+const Unknown = "unknown"
+
+//go:embed registry.json
+var defaultRegistryJson []byte
+
+type Protocol struct {
+	Name      string            `json:"name"`
+	Addresses map[string]string `json:"addresses,omitempty"` // address -> contract name
+	Selectors map[string]string `json:"selectors,omitempty"` // 0x-prefixed 4-byte selector -> method name
+}
+
+type registryFile struct {
+	Protocols []Protocol `json:"protocols"`
+}
+
+// Match is the result of decoding a transaction
+type Match struct {
+	Protocol string
+	Contract string // empty if matched only by selector
+	Method   string // empty if the selector is unknown
+}
+
+type entry struct {
+	protocol string
+	name     string
+}
+
+// Registry of known protocol addresses and selectors. Addresses and selectors are stored lowercase.
+type Registry struct {
+	addresses map[string]entry
+	selectors map[string]entry
+
+	// selectors by protocol, to resolve the method of a call to a known address
+	protocolSelectors map[string]map[string]string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		addresses:         make(map[string]entry),
+		selectors:         make(map[string]entry),
+		protocolSelectors: make(map[string]map[string]string),
+	}
+}
+
+// DefaultRegistry returns a new registry with the embedded protocols
+func DefaultRegistry() *Registry {
+	registry := NewRegistry()
+	err := registry.Load(defaultRegistryJson)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded protocol registry: %v", err))
+	}
+	return registry
+}
+
+// Add adds a protocol. Addresses and selectors which are already known are overwritten, so user entries take
+// precedence over the embedded ones.
+func (r *Registry) Add(protocol Protocol) error {
+	if protocol.Name == "" {
+		return fmt.Errorf("protocol without name")
+	}
+
+	if r.protocolSelectors[protocol.Name] == nil {
+		r.protocolSelectors[protocol.Name] = make(map[string]string)
+	}
+
+	for address, contract := range protocol.Addresses {
+		address = strings.ToLower(address)
+		if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+			return fmt.Errorf("%s: invalid address %s", protocol.Name, address)
+		}
+		r.addresses[address] = entry{protocol.Name, contract}
+	}
+
+	for selector, method := range protocol.Selectors {
+		selector = strings.ToLower(selector)
+		if len(selector) != 10 || !strings.HasPrefix(selector, "0x") {
+			return fmt.Errorf("%s: invalid selector %s", protocol.Name, selector)
+		}
+		r.selectors[selector] = entry{protocol.Name, method}
+		r.protocolSelectors[protocol.Name][selector] = method
+	}
+	return nil
+}
+
+// Load adds the protocols of a registry in JSON format
+func (r *Registry) Load(data []byte) error {
+	var file registryFile
+	err := json.Unmarshal(data, &file)
+	if err != nil {
+		return err
+	}
+
+	for _, protocol := range file.Protocols {
+		err = r.Add(protocol)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadFile adds the protocols of a registry file
+func (r *Registry) LoadFile(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	err = r.Load(data)
+	if err != nil {
+		return fmt.Errorf("error loading protocol registry %s: %w", filename, err)
+	}
+	return nil
+}
+
+// Decode returns the protocol of a call to the given address (empty for contract creation) with the given calldata.
+// A known address takes precedence over a known selector (eg. an ERC20 transfer to the WETH contract is WETH).
+func (r *Registry) Decode(to string, data []byte) Match {
+	selector := ""
+	if len(data) >= 4 {
+		selector = "0x" + hex.EncodeToString(data[:4])
+	}
+
+	if address, found := r.addresses[strings.ToLower(to)]; found {
+		return Match{
+			Protocol: address.protocol,
+			Contract: address.name,
+			Method:   r.protocolSelectors[address.protocol][selector],
+		}
+	}
+
+	if method, found := r.selectors[selector]; found {
+		return Match{Protocol: method.protocol, Method: method.name}
+	}
+
+	return Match{Protocol: Unknown}
+}
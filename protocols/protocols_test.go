@@ -0,0 +1,52 @@
+package protocols
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	registry := DefaultRegistry()
+
+	swapExactETHForTokens, _ := hex.DecodeString("7ff36ab50000")
+	transfer, _ := hex.DecodeString("a9059cbb0000")
+	unknown, _ := hex.DecodeString("deadbeef")
+
+	tests := []struct {
+		to       string
+		data     []byte
+		expected Match
+	}{
+		{"0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D", swapExactETHForTokens, Match{"Uniswap V2", "Router02", "swapExactETHForTokens"}},
+		{"0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D", unknown, Match{"Uniswap V2", "Router02", ""}},
+		{"0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2", transfer, Match{"WETH", "WETH9", ""}}, // address before selector
+		{"0x1f9840a85d5aF5bf1D1762F925BDADdC4201F984", transfer, Match{"ERC20", "", "transfer"}},
+		{"0x1f9840a85d5aF5bf1D1762F925BDADdC4201F984", unknown, Match{Unknown, "", ""}},
+		{"", nil, Match{Unknown, "", ""}},
+	}
+
+	for _, test := range tests {
+		match := registry.Decode(test.to, test.data)
+		if match != test.expected {
+			t.Errorf("Decode(%s, %x) = %+v, expected %+v", test.to, test.data, match, test.expected)
+		}
+	}
+}
+
+func TestUserEntriesOverride(t *testing.T) {
+	registry := DefaultRegistry()
+	err := registry.Load([]byte(`{"protocols": [{"name": "MyDex", "addresses": {"0x7a250d5630B4cF539739dF2C5dAcb4c659F2488D": "Router"}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match := registry.Decode("0x7a250d5630b4cf539739df2c5dacb4c659f2488d", nil)
+	if match.Protocol != "MyDex" {
+		t.Errorf("expected MyDex, got %s", match.Protocol)
+	}
+
+	err = registry.Load([]byte(`{"protocols": [{"name": "Invalid", "selectors": {"0x1234": "foo"}}]}`))
+	if err == nil {
+		t.Error("expected error for invalid selector")
+	}
+}
@@ -0,0 +1,221 @@
+// Package receipts fetches blocks with the receipts of all their transactions, with few RPC requests: one
+// eth_getBlockReceipts request per block if the node supports it, else batched eth_getTransactionReceipt requests.
+package receipts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/metachris/flashbots/logging"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+var log = logging.Module("receipts")
+
+// JSON-RPC error code of unknown methods
+const errCodeMethodNotFound = -32601
+
+// Fetcher gets blocks with receipts. All its requests share a concurrency limit, blocks can be fetched in parallel
+// from several goroutines.
+type Fetcher struct {
+	rpcClient *rpc.Client
+	client    *ethclient.Client
+
+	BatchSize      int  // receipts per eth_getTransactionReceipt batch request
+	MaxConcurrency int  // max. parallel requests
+	BlockReceipts  bool // try eth_getBlockReceipts first (disabled automatically if the node doesn't support it)
+
+	blockReceiptsUnsupported int32 // atomic
+	semOnce                  sync.Once
+	sem                      chan struct{}
+}
+
+func NewFetcher(rpcClient *rpc.Client) *Fetcher {
+	return &Fetcher{
+		rpcClient:      rpcClient,
+		client:         ethclient.NewClient(rpcClient),
+		BatchSize:      100,
+		MaxConcurrency: 8,
+		BlockReceipts:  true,
+	}
+}
+
+// acquire waits for a free request slot, release must be called after the request
+func (f *Fetcher) acquire(ctx context.Context) error {
+	f.semOnce.Do(func() { f.sem = make(chan struct{}, f.MaxConcurrency) })
+	select {
+	case f.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (f *Fetcher) release() {
+	<-f.sem
+}
+
+// GetBlockWithTxReceipts returns the block with the receipts of all its transactions
+func (f *Fetcher) GetBlockWithTxReceipts(ctx context.Context, height int64) (*blockswithtx.BlockWithTxReceipts, error) {
+	if err := f.acquire(ctx); err != nil {
+		return nil, err
+	}
+	block, err := f.client.BlockByNumber(ctx, big.NewInt(height))
+	f.release()
+	if err != nil {
+		return nil, fmt.Errorf("error getting block %d: %w", height, err)
+	}
+
+	receipts, err := f.GetReceipts(ctx, block)
+	if err != nil {
+		return nil, fmt.Errorf("error getting receipts of block %d: %w", height, err)
+	}
+	return &blockswithtx.BlockWithTxReceipts{Block: block, TxReceipts: receipts}, nil
+}
+
+// GetBlocksWithTxReceipts returns the blocks (in the order of heights), fetching up to MaxConcurrency blocks in
+// parallel. Returns the first error.
+func (f *Fetcher) GetBlocksWithTxReceipts(ctx context.Context, heights []int64) ([]*blockswithtx.BlockWithTxReceipts, error) {
+	blocks := make([]*blockswithtx.BlockWithTxReceipts, len(heights))
+	errs := make([]error, len(heights))
+
+	var wg sync.WaitGroup
+	sem := make(chan bool, f.MaxConcurrency) // blocks in progress; the requests are limited by the fetcher
+	for i := range heights {
+		wg.Add(1)
+		sem <- true
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			blocks[i], errs[i] = f.GetBlockWithTxReceipts(ctx, heights[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return blocks, nil
+}
+
+// GetReceipts returns the receipts of all transactions of the block, by tx hash
+func (f *Fetcher) GetReceipts(ctx context.Context, block *types.Block) (map[common.Hash]*types.Receipt, error) {
+	txs := block.Transactions()
+	if len(txs) == 0 {
+		return make(map[common.Hash]*types.Receipt), nil
+	}
+
+	var receipts []*types.Receipt
+	var err error
+	if f.BlockReceipts && atomic.LoadInt32(&f.blockReceiptsUnsupported) == 0 {
+		receipts, err = f.getBlockReceipts(ctx, block)
+		if err != nil {
+			log.Debug("eth_getBlockReceipts failed, falling back to batched requests", "block", block.NumberU64(), "err", err)
+		}
+	}
+	if receipts == nil {
+		receipts, err = f.getReceiptsBatched(ctx, txs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ret := make(map[common.Hash]*types.Receipt, len(receipts))
+	for _, receipt := range receipts {
+		if receipt.BlockHash != block.Hash() {
+			return nil, fmt.Errorf("receipt of tx %s is from block %s (reorg?)", receipt.TxHash, receipt.BlockHash)
+		}
+		ret[receipt.TxHash] = receipt
+	}
+	for _, tx := range txs {
+		if ret[tx.Hash()] == nil {
+			return nil, fmt.Errorf("missing receipt of tx %s", tx.Hash())
+		}
+	}
+	return ret, nil
+}
+
+// getBlockReceipts gets all receipts with one eth_getBlockReceipts request. If the node doesn't know the method,
+// it's not tried again.
+func (f *Fetcher) getBlockReceipts(ctx context.Context, block *types.Block) (receipts []*types.Receipt, err error) {
+	if err = f.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer f.release()
+
+	err = f.rpcClient.CallContext(ctx, &receipts, "eth_getBlockReceipts", hexutil.EncodeBig(block.Number()))
+	if err != nil {
+		var rpcErr rpc.Error
+		if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == errCodeMethodNotFound {
+			log.Info("node doesn't support eth_getBlockReceipts, using batched eth_getTransactionReceipt")
+			atomic.StoreInt32(&f.blockReceiptsUnsupported, 1)
+		}
+		return nil, err
+	}
+	if len(receipts) != len(block.Transactions()) {
+		return nil, fmt.Errorf("got %d receipts for %d transactions", len(receipts), len(block.Transactions()))
+	}
+	return receipts, nil
+}
+
+// getReceiptsBatched gets the receipts with eth_getTransactionReceipt batch requests of BatchSize, in parallel
+func (f *Fetcher) getReceiptsBatched(ctx context.Context, txs types.Transactions) ([]*types.Receipt, error) {
+	receipts := make([]*types.Receipt, len(txs))
+	elems := make([]rpc.BatchElem, len(txs))
+	for i, tx := range txs {
+		elems[i] = rpc.BatchElem{Method: "eth_getTransactionReceipt", Args: []interface{}{tx.Hash()}, Result: &receipts[i]}
+	}
+
+	batchSize := f.BatchSize
+	if batchSize <= 0 {
+		batchSize = len(elems)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, (len(elems)+batchSize-1)/batchSize)
+	for start := 0; start < len(elems); start += batchSize {
+		end := start + batchSize
+		if end > len(elems) {
+			end = len(elems)
+		}
+
+		if err := f.acquire(ctx); err != nil {
+			errs <- err
+			break
+		}
+		wg.Add(1)
+		go func(batch []rpc.BatchElem) {
+			defer wg.Done()
+			defer f.release()
+			if err := f.rpcClient.BatchCallContext(ctx, batch); err != nil {
+				errs <- err
+			}
+		}(elems[start:end])
+	}
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	for i, elem := range elems {
+		if elem.Error != nil {
+			return nil, fmt.Errorf("error getting receipt of tx %s: %w", txs[i].Hash(), elem.Error)
+		}
+		if receipts[i] == nil {
+			return nil, fmt.Errorf("receipt of tx %s not found", txs[i].Hash())
+		}
+	}
+	return receipts, nil
+}
@@ -0,0 +1,102 @@
+package receipts
+
+import (
+	"context"
+	"math/big"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// testNode serves the receipts of one block as eth_getTransactionReceipt
+type testNode struct {
+	receipts map[common.Hash]*types.Receipt
+	calls    int32 // requests can be concurrent
+}
+
+func (n *testNode) GetTransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	atomic.AddInt32(&n.calls, 1)
+	return n.receipts[hash], nil
+}
+
+// testBlockReceiptsNode also serves eth_getBlockReceipts
+type testBlockReceiptsNode struct {
+	testNode
+	block *types.Block
+}
+
+func (n *testBlockReceiptsNode) GetBlockReceipts(number hexutil.Big) ([]*types.Receipt, error) {
+	atomic.AddInt32(&n.calls, 1)
+	receipts := []*types.Receipt{}
+	for _, tx := range n.block.Transactions() {
+		receipts = append(receipts, n.receipts[tx.Hash()])
+	}
+	return receipts, nil
+}
+
+func newTestBlock(numTx int) (*types.Block, map[common.Hash]*types.Receipt) {
+	txs := types.Transactions{}
+	for i := 0; i < numTx; i++ {
+		txs = append(txs, types.NewTransaction(uint64(i), common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil))
+	}
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(100)}).WithBody(txs, nil)
+
+	receipts := make(map[common.Hash]*types.Receipt)
+	for i, tx := range txs {
+		receipts[tx.Hash()] = &types.Receipt{Status: 1, TxHash: tx.Hash(), BlockHash: block.Hash(), BlockNumber: block.Number(), TransactionIndex: uint(i), Logs: []*types.Log{}}
+	}
+	return block, receipts
+}
+
+func newTestFetcher(t *testing.T, service interface{}) *Fetcher {
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", service); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Stop)
+	return NewFetcher(rpc.DialInProc(server))
+}
+
+func TestFetcherBatched(t *testing.T) {
+	block, receipts := newTestBlock(25)
+	node := &testNode{receipts: receipts}
+	f := newTestFetcher(t, node)
+	f.BatchSize = 10
+
+	ret, err := f.GetReceipts(context.Background(), block)
+	if err != nil || len(ret) != 25 {
+		t.Fatal("unexpected receipts", len(ret), err)
+	}
+	if f.blockReceiptsUnsupported != 1 || node.calls != 25 {
+		t.Error("expected eth_getBlockReceipts to be disabled and one receipt request per tx", f.blockReceiptsUnsupported, node.calls)
+	}
+
+	// Missing receipt
+	delete(receipts, block.Transactions()[3].Hash())
+	if _, err = f.GetReceipts(context.Background(), block); err == nil {
+		t.Error("expected an error for a missing receipt")
+	}
+}
+
+func TestFetcherBlockReceipts(t *testing.T) {
+	block, receipts := newTestBlock(25)
+	node := &testBlockReceiptsNode{testNode: testNode{receipts: receipts}, block: block}
+	f := newTestFetcher(t, node)
+
+	ret, err := f.GetReceipts(context.Background(), block)
+	if err != nil || len(ret) != 25 || node.calls != 1 {
+		t.Fatal("expected all receipts with one request", len(ret), node.calls, err)
+	}
+
+	// Receipts of another block (reorg between the requests)
+	for _, receipt := range receipts {
+		receipt.BlockHash = common.Hash{1}
+	}
+	if _, err = f.GetReceipts(context.Background(), block); err == nil {
+		t.Error("expected an error for receipts of another block")
+	}
+}
@@ -0,0 +1,66 @@
+// Package schema defines the versioned JSON formats of the artifacts emitted by this module (check results,
+// incidents, bundles, miner stats), as Go structs and as JSON schemas, so non-Go consumers can validate and codegen.
+//
+// The formats are stable within a version: fields may be added, but are not renamed or removed.
+package schema
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const Version = "v1"
+
+// Names of the schemas, the files are <Version>/<name>.json
+const (
+	NameCheckResult = "check-result"
+	NameIncident    = "incident"
+	NameBundle      = "bundle"
+	NameMinerStats  = "miner-stats"
+)
+
+var Names = []string{NameCheckResult, NameIncident, NameBundle, NameMinerStats}
+
+//go:embed v1/*.json
+var files embed.FS
+
+// Get returns the JSON schema of the current version
+func Get(name string) ([]byte, error) {
+	data, err := files.ReadFile(Version + "/" + name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("unknown schema: %s", name)
+	}
+	return data, nil
+}
+
+// Handler serves the schemas, to be mounted on a server under a prefix (eg. with http.StripPrefix("/schema", ...)):
+//
+//	GET /v1/                   - list of schema names
+//	GET /v1/check-result.json  - JSON schema
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		if path == Version || path == Version+"/" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(Names)
+			return
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(path, Version+"/"), ".json")
+		data, err := Get(name)
+		if err != nil || !strings.HasPrefix(path, Version+"/") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/schema+json")
+		w.Write(data)
+	})
+}
@@ -0,0 +1,74 @@
+package schema
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// The properties of the JSON schemas must match the json tags of the Go structs
+func TestSchemasMatchStructs(t *testing.T) {
+	structs := map[string]interface{}{
+		NameCheckResult: CheckResult{},
+		NameIncident:    Incident{},
+		NameBundle:      Bundle{},
+		NameMinerStats:  MinerStats{},
+	}
+
+	for _, name := range Names {
+		data, err := Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var schema struct {
+			Properties map[string]interface{} `json:"properties"`
+		}
+		err = json.Unmarshal(data, &schema)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		schemaFields := make([]string, 0)
+		for field := range schema.Properties {
+			schemaFields = append(schemaFields, field)
+		}
+
+		structFields := make([]string, 0)
+		typ := reflect.TypeOf(structs[name])
+		for i := 0; i < typ.NumField(); i++ {
+			structFields = append(structFields, strings.Split(typ.Field(i).Tag.Get("json"), ",")[0])
+		}
+
+		sort.Strings(schemaFields)
+		sort.Strings(structFields)
+		if !reflect.DeepEqual(schemaFields, structFields) {
+			t.Errorf("%s: schema properties %v don't match struct fields %v", name, schemaFields, structFields)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v1/incident.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "application/schema+json" {
+		t.Error("Unexpected response:", resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	resp, err = http.Get(server.URL + "/v1/unknown.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 404 {
+		t.Error("Expected 404, got", resp.Status)
+	}
+}
@@ -0,0 +1,201 @@
+package schema
+
+import (
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/common"
+)
+
+// All big numbers (wei) are decimal strings
+
+type Bundle struct {
+	Index                    int64    `json:"index"`
+	Hash                     string   `json:"hash"`
+	BundleType               string   `json:"bundle_type"`
+	TxHashes                 []string `json:"tx_hashes"`
+	TotalMinerReward         string   `json:"total_miner_reward"`
+	TotalCoinbaseTransfer    string   `json:"total_coinbase_transfer"`
+	TotalGasUsed             string   `json:"total_gas_used"`
+	RewardDivGasUsed         string   `json:"reward_div_gas_used"` // effective gas price
+	GasFees                  string   `json:"gas_fees"`            // miner reward without the coinbase transfers
+	CoinbaseShare            float64  `json:"coinbase_share"`      // of the miner reward, 0 to 1
+	TailGasPrice             string   `json:"tail_gas_price,omitempty"`
+	TailGasPriceMargin       *float64 `json:"tail_gas_price_margin,omitempty"` // % of the effective gas price above the tail gas price
+	IsOutOfOrder             bool     `json:"is_out_of_order"`
+	IsPayingLessThanLowestTx bool     `json:"is_paying_less_than_lowest_tx"`
+	FeePercentile            float64  `json:"fee_percentile,omitempty"` // % of the block's non-Flashbots tx paying less, set with is_paying_less_than_lowest_tx
+
+	GroupIndex int            `json:"group_index"`         // bundles placed as one unit (megabundle, merged bundles) share a group
+	Protocols  map[string]int `json:"protocols,omitempty"` // protocol name -> number of tx
+	Searcher   string         `json:"searcher,omitempty"`  // label of the searcher, if known
+}
+
+// Sandwich is a likely sandwich attack inside a bundle
+type Sandwich struct {
+	BundleIndex            int64    `json:"bundle_index"`
+	Pool                   string   `json:"pool"`
+	FrontrunTx             string   `json:"frontrun_tx"`
+	VictimTx               string   `json:"victim_tx"`
+	BackrunTx              string   `json:"backrun_tx"`
+	Searchers              []string `json:"searchers"` // as disclosed by labels.SearcherDisclosure
+	EstimatedVictimLoss    string   `json:"estimated_victim_loss"`
+	LossToken              string   `json:"loss_token"` // token0 or token1 of the pool
+	LossTokenAddress       string   `json:"loss_token_address,omitempty"`
+	LossTokenSymbol        string   `json:"loss_token_symbol,omitempty"`
+	LossTokenDecimals      int      `json:"loss_token_decimals,omitempty"`
+	EstimatedVictimLossUSD float64  `json:"estimated_victim_loss_usd,omitempty"` // at the block time
+}
+
+type CheckResult struct {
+	SchemaVersion        string            `json:"schema_version"`
+	BlockNumber          int64             `json:"block_number"`
+	BlockHash            string            `json:"block_hash"`
+	Miner                string            `json:"miner"`
+	MinerName            string            `json:"miner_name"`
+	Coinbase             string            `json:"coinbase"` // differs from miner if the coinbase is a payment splitter of the pool
+	Errors               []string          `json:"errors"`
+	ErrorCounts          map[string]uint64 `json:"error_counts"`
+	HasSeriousErrors     bool              `json:"has_serious_errors"`
+	HasLessSeriousErrors bool              `json:"has_less_serious_errors"`
+	Bundles              []Bundle          `json:"bundles"`
+
+	GasPrices *GasPriceDistribution `json:"gas_prices,omitempty"` // set if the bundle fees were checked
+
+	TemplateSource  string   `json:"template_source,omitempty"`  // "flashbots" or "modified", set for blocks with bundles
+	TemplateSignals []string `json:"template_signals,omitempty"` // deviations from the Flashbots ordering
+
+	Sandwiches []Sandwich `json:"sandwiches,omitempty"`
+
+	InputHash  string `json:"input_hash"`  // see blockcheck.BlockCheck.InputHash
+	OutputHash string `json:"output_hash"` // see blockcheck.BlockCheck.OutputHash
+}
+
+// GasPriceDistribution of the non-Flashbots tx of a block (priority fees after London)
+type GasPriceDistribution struct {
+	Min    string `json:"min"`
+	P25    string `json:"p25"`
+	Median string `json:"median"`
+	P75    string `json:"p75"`
+	Max    string `json:"max"`
+}
+
+type Incident struct {
+	SchemaVersion string   `json:"schema_version"`
+	BlockNumber   int64    `json:"block_number"`
+	Miner         string   `json:"miner"`
+	MinerName     string   `json:"miner_name"`
+	Errors        []string `json:"errors"`
+	TxHashes      []string `json:"tx_hashes"`
+	Addresses     []string `json:"addresses"`
+}
+
+type MinerStats struct {
+	SchemaVersion string            `json:"schema_version"`
+	Miner         string            `json:"miner"`
+	MinerName     string            `json:"miner_name"`
+	WindowSec     int64             `json:"window_sec"`
+	Blocks        uint64            `json:"blocks"`
+	ErrorBlocks   uint64            `json:"error_blocks"`
+	ErrorRate     float64           `json:"error_rate"`
+	ErrorCounts   map[string]uint64 `json:"error_counts"`
+}
+
+func NewBundle(bundle *common.Bundle) Bundle {
+	ret := Bundle{
+		Index:                    bundle.Index,
+		Hash:                     bundle.Hash,
+		BundleType:               bundle.BundleType,
+		TxHashes:                 make([]string, 0, len(bundle.Transactions)),
+		TotalMinerReward:         bundle.TotalMinerReward.String(),
+		TotalCoinbaseTransfer:    bundle.TotalCoinbaseTransfer.String(),
+		TotalGasUsed:             bundle.TotalGasUsed.String(),
+		RewardDivGasUsed:         bundle.RewardDivGasUsed.String(),
+		GasFees:                  bundle.GasFees().String(),
+		CoinbaseShare:            bundle.CoinbaseShare(),
+		IsOutOfOrder:             bundle.IsOutOfOrder,
+		IsPayingLessThanLowestTx: bundle.IsPayingLessThanLowestTx,
+		FeePercentile:            bundle.FeePercentile,
+		GroupIndex:               bundle.GroupIndex,
+		Protocols:                bundle.Protocols,
+		Searcher:                 bundle.SearcherName,
+	}
+	if bundle.TailGasPrice != nil {
+		ret.TailGasPrice = bundle.TailGasPrice.String()
+	}
+	if margin, ok := bundle.TailGasPriceMargin(); ok {
+		ret.TailGasPriceMargin = &margin
+	}
+	for _, tx := range bundle.Transactions {
+		ret.TxHashes = append(ret.TxHashes, tx.Hash)
+	}
+	return ret
+}
+
+func NewCheckResult(check *blockcheck.BlockCheck) CheckResult {
+	ret := CheckResult{
+		SchemaVersion:        Version,
+		BlockNumber:          check.Number,
+		BlockHash:            check.EthBlock.Hash().Hex(),
+		Miner:                check.Miner,
+		MinerName:            check.MinerName,
+		Coinbase:             check.Coinbase,
+		Errors:               check.ErrorMessages(),
+		ErrorCounts:          check.ErrorCounter.Map(),
+		HasSeriousErrors:     check.HasSeriousErrors(),
+		HasLessSeriousErrors: check.HasLessSeriousErrors(),
+		Bundles:              make([]Bundle, 0, len(check.Bundles)),
+		TemplateSource:       check.TemplateSource,
+		TemplateSignals:      check.TemplateSignals,
+		InputHash:            check.InputHash(),
+		OutputHash:           check.OutputHash(),
+	}
+	for _, bundle := range check.Bundles {
+		ret.Bundles = append(ret.Bundles, NewBundle(bundle))
+	}
+	for _, sandwich := range check.Sandwiches {
+		ret.Sandwiches = append(ret.Sandwiches, Sandwich{
+			BundleIndex:            sandwich.BundleIndex,
+			Pool:                   sandwich.Pool,
+			FrontrunTx:             sandwich.FrontrunTx,
+			VictimTx:               sandwich.VictimTx,
+			BackrunTx:              sandwich.BackrunTx,
+			Searchers:              sandwich.DisclosedSearchers(),
+			EstimatedVictimLoss:    sandwich.EstimatedVictimLoss.String(),
+			LossToken:              sandwich.LossToken,
+			LossTokenAddress:       sandwich.LossTokenAddress,
+			LossTokenSymbol:        sandwich.LossTokenSymbol,
+			LossTokenDecimals:      sandwich.LossTokenDecimals,
+			EstimatedVictimLossUSD: sandwich.EstimatedVictimLossUSD,
+		})
+	}
+	if gp := check.GasPrices; gp != nil {
+		ret.GasPrices = &GasPriceDistribution{Min: gp.Min.String(), P25: gp.P25.String(), Median: gp.Median.String(), P75: gp.P75.String(), Max: gp.Max.String()}
+	}
+	return ret
+}
+
+func NewIncident(incident *blockcheck.Incident) Incident {
+	return Incident{
+		SchemaVersion: Version,
+		BlockNumber:   incident.BlockNumber,
+		Miner:         incident.Miner,
+		MinerName:     incident.MinerName,
+		Errors:        incident.Errors,
+		TxHashes:      incident.TxHashes,
+		Addresses:     incident.Addresses,
+	}
+}
+
+func NewMinerStats(stats *blockcheck.MinerWindowStats, window time.Duration) MinerStats {
+	return MinerStats{
+		SchemaVersion: Version,
+		Miner:         stats.Miner,
+		MinerName:     stats.MinerName,
+		WindowSec:     int64(window.Seconds()),
+		Blocks:        stats.Blocks,
+		ErrorBlocks:   stats.ErrorBlocks,
+		ErrorRate:     stats.ErrorRate(),
+		ErrorCounts:   stats.ErrorCounts.Map(),
+	}
+}
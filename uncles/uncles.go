@@ -0,0 +1,302 @@
+// Package uncles detects uncle-bandit attacks: bundles which were mined in an uncle block, and whose transactions
+// were later replayed in the canonical chain by another party (eg. a sandwich victim tx with someone else's frontrun
+// and backrun), while the original searcher transactions were not included.
+//
+// Usage:
+//
+//	detector := uncles.NewDetector(client)
+//	reports, err := detector.AddBlock(block) // for every new canonical block
+package uncles
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/metachris/flashbots/common"
+	"github.com/metachris/flashbots/labels"
+	"github.com/metachris/go-ethutils/utils"
+)
+
+// Uncles can be referenced up to 7 blocks after their height
+const maxUncleDepth = 7
+
+// Max. number of non-Flashbots-like tx between two Flashbots-like tx of the same bundle (eg. the victim of a sandwich)
+var MaxBundleGap = 2
+
+// UncleBundle is a group of transactions of an uncle block which looks like a bundle
+type UncleBundle struct {
+	UncleHash    string
+	UncleNumber  int64
+	UncleTime    uint64 // unix timestamp
+	BaseFee      *big.Int
+	Miner        string
+	StartIndex   int // tx index of the first tx in the uncle block
+	Transactions []*types.Transaction
+}
+
+// ReplayedTx is a transaction of an uncle bundle which was included in the canonical chain
+type ReplayedTx struct {
+	Hash        string
+	BlockNumber int64
+	TxIndex     int
+}
+
+// Report is an uncle bundle whose transactions were partially replayed in the canonical chain
+type Report struct {
+	Bundle          *UncleBundle
+	ReplayedTxs     []ReplayedTx
+	MissingTxs      []string // tx of the uncle bundle which were not included in the canonical chain
+	BanditAddresses []string // senders of the canonical tx around the replayed tx, which are not part of the uncle bundle (shown as disclosed by labels.SearcherDisclosure)
+}
+
+func (r *Report) String() string {
+	msg := fmt.Sprintf("possible uncle-bandit: bundle at tx %d of uncle [%d %.10s](<%s>) (miner %s) - %d/%d tx replayed in the canonical chain", r.Bundle.StartIndex, r.Bundle.UncleNumber, r.Bundle.UncleHash, common.UncleUrl(r.Bundle.UncleHash), labels.Default.Format(r.Bundle.Miner), len(r.ReplayedTxs), len(r.Bundle.Transactions))
+	for _, tx := range r.ReplayedTxs {
+		msg += fmt.Sprintf("\n- replayed [%s](<%s>) in block %d at index %d", tx.Hash, common.TxUrl(tx.Hash), tx.BlockNumber, tx.TxIndex)
+	}
+	for _, hash := range r.MissingTxs {
+		msg += fmt.Sprintf("\n- not included [%s](<%s>)", hash, common.TxUrl(hash))
+	}
+	for _, address := range r.BanditAddresses {
+		if labels.SearcherDisclosure.IsFull() {
+			msg += fmt.Sprintf("\n- bandit [%s](<%s>)", address, common.AddressUrl(address))
+		} else {
+			msg += "\n- bandit " + labels.SearcherDisclosure.Address(address) // without link, which would reveal the address
+		}
+	}
+	return msg
+}
+
+// ExtractBundles returns the groups of Flashbots-like transactions (0 priority fee, paying via coinbase transfer) of
+// an uncle block. Up to MaxBundleGap other transactions between them are included in the bundle.
+func ExtractBundles(uncle *types.Block) (bundles []*UncleBundle) {
+	var current *UncleBundle
+	lastFlashbotsLikeIndex := -1
+
+	txs := uncle.Transactions()
+	for i, tx := range txs {
+		if !common.IsFlashbotsLikeTx(tx, uncle, nil) {
+			continue
+		}
+
+		if current == nil || i-lastFlashbotsLikeIndex-1 > MaxBundleGap {
+			current = &UncleBundle{
+				UncleHash:   uncle.Hash().Hex(),
+				UncleNumber: uncle.Number().Int64(),
+				UncleTime:   uncle.Time(),
+				BaseFee:     uncle.BaseFee(),
+				Miner:       uncle.Coinbase().Hex(),
+				StartIndex:  i,
+			}
+			bundles = append(bundles, current)
+		} else {
+			current.Transactions = append(current.Transactions, txs[lastFlashbotsLikeIndex+1:i]...)
+		}
+
+		current.Transactions = append(current.Transactions, tx)
+		lastFlashbotsLikeIndex = i
+	}
+	return bundles
+}
+
+// EstimatedGasPrice returns the miner payment per gas of the bundle (direct coinbase transfers and priority fees, by
+// gas limit). Only an estimate: the receipts of uncle tx are not available, and internal transfers are not seen.
+func (b *UncleBundle) EstimatedGasPrice() *big.Int {
+	coinbase := ethcommon.HexToAddress(b.Miner)
+	payment := new(big.Int)
+	gas := new(big.Int)
+	for _, tx := range b.Transactions {
+		txGas := new(big.Int).SetUint64(tx.Gas())
+		gas.Add(gas, txGas)
+		payment.Add(payment, new(big.Int).Mul(txGas, common.TxPriorityFee(tx, b.BaseFee)))
+		if tx.To() != nil && *tx.To() == coinbase {
+			payment.Add(payment, tx.Value())
+		}
+	}
+	if gas.Sign() == 0 {
+		return gas
+	}
+	return payment.Div(payment, gas)
+}
+
+// Detector keeps the bundles of recent uncles, and compares them against the canonical blocks at and after the
+// uncle height
+type Detector struct {
+	client *ethclient.Client
+
+	// Number of canonical blocks after the uncle height which are searched for replayed tx
+	Lookahead int64
+
+	// Called for every bundle of a new uncle, if set (eg. to record the searcher's lost bids)
+	OnBundle func(bundle *UncleBundle)
+
+	canonical  map[int64]*types.Block
+	pending    []*UncleBundle
+	seenUncles map[ethcommon.Hash]int64 // uncle hash -> height
+}
+
+func NewDetector(client *ethclient.Client) *Detector {
+	return &Detector{
+		client:     client,
+		Lookahead:  2,
+		canonical:  make(map[int64]*types.Block),
+		seenUncles: make(map[ethcommon.Hash]int64),
+	}
+}
+
+// SetClient replaces the node client (eg. after a failover), keeping the pending bundles
+func (d *Detector) SetClient(client *ethclient.Client) {
+	d.client = client
+}
+
+// AddBlock adds a new canonical block: fetches the uncles it references, and returns reports for the uncle bundles
+// whose lookahead window is complete. Errors fetching uncles don't stop the comparison of the other bundles.
+func (d *Detector) AddBlock(block *types.Block) (reports []*Report, err error) {
+	height := block.Number().Int64()
+	d.canonical[height] = block
+
+	for _, uncleHeader := range block.Uncles() {
+		if _, seen := d.seenUncles[uncleHeader.Hash()]; seen {
+			continue
+		}
+
+		uncle, fetchErr := d.client.BlockByHash(context.Background(), uncleHeader.Hash())
+		if fetchErr != nil { // nodes only have the full uncle block if they received it
+			err = fmt.Errorf("error fetching uncle %s: %w", uncleHeader.Hash(), fetchErr)
+			continue
+		}
+
+		d.seenUncles[uncleHeader.Hash()] = uncleHeader.Number.Int64()
+		bundles := ExtractBundles(uncle)
+		if d.OnBundle != nil {
+			for _, bundle := range bundles {
+				d.OnBundle(bundle)
+			}
+		}
+		d.pending = append(d.pending, bundles...)
+	}
+
+	pending := d.pending[:0]
+	for _, bundle := range d.pending {
+		if bundle.UncleNumber+d.Lookahead > height {
+			pending = append(pending, bundle)
+			continue
+		}
+
+		report, compareErr := d.compare(bundle)
+		if compareErr != nil {
+			err = compareErr
+			continue
+		}
+		if report != nil {
+			reports = append(reports, report)
+		}
+	}
+	d.pending = pending
+
+	d.prune(height)
+	return reports, err
+}
+
+func (d *Detector) prune(height int64) {
+	for h := range d.canonical {
+		if h < height-maxUncleDepth-d.Lookahead {
+			delete(d.canonical, h)
+		}
+	}
+
+	// Keep the seen uncles only as long as they can still be referenced
+	for hash, h := range d.seenUncles {
+		if h < height-maxUncleDepth {
+			delete(d.seenUncles, hash)
+		}
+	}
+}
+
+func (d *Detector) getCanonicalBlock(height int64) (*types.Block, error) {
+	if block, found := d.canonical[height]; found {
+		return block, nil
+	}
+
+	block, err := d.client.BlockByNumber(context.Background(), big.NewInt(height))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching canonical block %d: %w", height, err)
+	}
+	d.canonical[height] = block
+	return block, nil
+}
+
+// compare searches the bundle tx in the canonical blocks, and returns a report if some of them were replayed
+// without the rest of the bundle
+func (d *Detector) compare(bundle *UncleBundle) (*Report, error) {
+	type txLocation struct {
+		block *types.Block
+		index int
+	}
+
+	bundleTxs := make(map[ethcommon.Hash]bool)
+	for _, tx := range bundle.Transactions {
+		bundleTxs[tx.Hash()] = true
+	}
+
+	locations := make(map[ethcommon.Hash]txLocation)
+	for height := bundle.UncleNumber; height <= bundle.UncleNumber+d.Lookahead; height++ {
+		block, err := d.getCanonicalBlock(height)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, tx := range block.Transactions() {
+			if bundleTxs[tx.Hash()] {
+				locations[tx.Hash()] = txLocation{block, i}
+			}
+		}
+	}
+
+	report := &Report{Bundle: bundle}
+	isContiguous := true
+	var prev *txLocation
+	for _, tx := range bundle.Transactions {
+		location, found := locations[tx.Hash()]
+		if !found {
+			report.MissingTxs = append(report.MissingTxs, tx.Hash().Hex())
+			continue
+		}
+
+		report.ReplayedTxs = append(report.ReplayedTxs, ReplayedTx{Hash: tx.Hash().Hex(), BlockNumber: location.block.Number().Int64(), TxIndex: location.index})
+		if prev != nil && (prev.block != location.block || prev.index+1 != location.index) {
+			isContiguous = false
+		}
+		prev = &location
+	}
+
+	// Not included at all, or re-included as the same bundle: no uncle-bandit
+	if len(report.ReplayedTxs) == 0 || (len(report.MissingTxs) == 0 && isContiguous) {
+		return nil, nil
+	}
+
+	// Senders of the canonical tx directly before and after the replayed tx
+	seenAddresses := make(map[string]bool)
+	for _, replayed := range report.ReplayedTxs {
+		block := locations[ethcommon.HexToHash(replayed.Hash)].block
+		txs := block.Transactions()
+		for _, i := range []int{replayed.TxIndex - 1, replayed.TxIndex + 1} {
+			if i < 0 || i >= len(txs) || bundleTxs[txs[i].Hash()] {
+				continue
+			}
+
+			sender, err := utils.GetTxSender(txs[i])
+			if err != nil || seenAddresses[sender.Hex()] {
+				continue
+			}
+			seenAddresses[sender.Hex()] = true
+			report.BanditAddresses = append(report.BanditAddresses, sender.Hex())
+		}
+	}
+
+	return report, nil
+}
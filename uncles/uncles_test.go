@@ -0,0 +1,23 @@
+package uncles
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/metachris/flashbots/labels"
+)
+
+func TestReportDisclosure(t *testing.T) {
+	defer func(d *labels.Disclosure) { labels.SearcherDisclosure = d }(labels.SearcherDisclosure)
+	bandit := "0x2222222222222222222222222222222222222222"
+	report := &Report{Bundle: &UncleBundle{UncleHash: "0xabc", UncleNumber: 100}, BanditAddresses: []string{bandit}}
+
+	if s := report.String(); !strings.Contains(s, bandit) {
+		t.Error("Expected the bandit address with full disclosure:", s)
+	}
+
+	labels.SearcherDisclosure = labels.NewDisclosure(labels.DisclosureTruncated, "", labels.Default)
+	if s := report.String(); strings.Contains(s, bandit) || !strings.Contains(s, "- bandit 0x2222...2222") {
+		t.Error("Expected the bandit address to be truncated:", s)
+	}
+}
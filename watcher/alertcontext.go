@@ -0,0 +1,93 @@
+package watcher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+// heldAlert is a serious alert which waits for the check of the next block (see AlertLookAhead)
+type heldAlert struct {
+	check    *blockcheck.BlockCheck
+	severity string
+	heldAt   time.Time
+}
+
+// alert sends the check to the notifiers. Serious alerts get the summary of the previous block, and wait up to
+// AlertLookAhead for the next block (see releaseAlerts).
+func (w *Watcher) alert(check *blockcheck.BlockCheck, severity string, isRecheck bool) {
+	if severity != blockcheck.SeveritySerious || isRecheck {
+		w.notify(check, severity)
+		return
+	}
+
+	w.alertLock.Lock()
+	if w.lastSummary != nil && w.lastSummary.Number == check.Number-1 {
+		check.PreviousBlock = w.lastSummary
+	}
+	hold := w.AlertLookAhead > 0
+	if hold {
+		w.heldAlerts = append(w.heldAlerts, &heldAlert{check: check, severity: severity, heldAt: time.Now()})
+	}
+	w.alertLock.Unlock()
+
+	if !hold {
+		w.notify(check, severity)
+	}
+}
+
+// setLastSummary remembers the last reported block, for the alert of the next one
+func (w *Watcher) setLastSummary(summary *blockcheck.BlockSummary) {
+	w.alertLock.Lock()
+	defer w.alertLock.Unlock()
+	w.lastSummary = summary
+}
+
+// releaseAlerts sends the held alerts when the next block was checked, with its summary if it follows directly
+func (w *Watcher) releaseAlerts(next *blockcheck.BlockSummary) {
+	w.alertLock.Lock()
+	held := w.heldAlerts
+	w.heldAlerts = nil
+	w.alertLock.Unlock()
+
+	for _, alert := range held {
+		if next != nil && alert.check.Number == next.Number-1 {
+			alert.check.NextBlock = next
+		}
+		w.notify(alert.check, alert.severity)
+	}
+}
+
+// releaseExpiredAlerts sends the alerts which waited AlertLookAhead for the next block, without its summary
+func (w *Watcher) releaseExpiredAlerts(now time.Time) {
+	w.alertLock.Lock()
+	var expired []*heldAlert
+	for len(w.heldAlerts) > 0 && now.Sub(w.heldAlerts[0].heldAt) >= w.AlertLookAhead {
+		expired = append(expired, w.heldAlerts[0])
+		w.heldAlerts = w.heldAlerts[1:]
+	}
+	w.alertLock.Unlock()
+
+	for _, alert := range expired {
+		w.notify(alert.check, alert.severity)
+	}
+}
+
+// alertDeadline returns when the oldest held alert has to be sent, or false if no alert is held
+func (w *Watcher) alertDeadline() (deadline time.Time, ok bool) {
+	w.alertLock.Lock()
+	defer w.alertLock.Unlock()
+	if len(w.heldAlerts) == 0 {
+		return time.Time{}, false
+	}
+	return w.heldAlerts[0].heldAt.Add(w.AlertLookAhead), true
+}
+
+func (w *Watcher) notify(check *blockcheck.BlockCheck, severity string) {
+	for _, notifier := range w.Notifiers {
+		if err := notifier.Notify(check, severity); err != nil {
+			w.handleError(fmt.Errorf("notifier error: %w", err))
+		}
+	}
+}
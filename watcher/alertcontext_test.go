@@ -0,0 +1,57 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+func TestAlertContext(t *testing.T) {
+	var sent []*blockcheck.BlockCheck
+	w := &Watcher{AlertLookAhead: time.Minute}
+	w.Notifiers = append(w.Notifiers, NotifierFunc(func(check *blockcheck.BlockCheck, severity string) error {
+		sent = append(sent, check)
+		return nil
+	}))
+
+	w.setLastSummary(&blockcheck.BlockSummary{Number: 9, MinerName: "miner"})
+	check := &blockcheck.BlockCheck{Number: 10}
+	w.alert(check, blockcheck.SeveritySerious, false)
+	if len(sent) != 0 || check.PreviousBlock == nil || check.PreviousBlock.Number != 9 {
+		t.Fatal("expected a held alert with the previous block", sent, check.PreviousBlock)
+	}
+
+	// Less serious alerts and re-checks are sent immediately
+	w.alert(&blockcheck.BlockCheck{Number: 10}, blockcheck.SeverityLessSerious, false)
+	w.alert(&blockcheck.BlockCheck{Number: 8}, blockcheck.SeveritySerious, true)
+	if len(sent) != 2 {
+		t.Fatal("expected 2 alerts sent immediately, got", len(sent))
+	}
+
+	w.releaseAlerts(&blockcheck.BlockSummary{Number: 11, Miner: "0xabc", ErrorTypes: []string{"failed-tx"}})
+	if len(sent) != 3 || sent[2] != check || check.NextBlock == nil || check.NextBlock.Number != 11 {
+		t.Fatal("expected the held alert with the next block", sent, check.NextBlock)
+	}
+	if got := check.NextBlock.String(); got != "block 11, miner 0xabc: 0 bundles, errors: failed-tx" {
+		t.Error("unexpected summary", got)
+	}
+
+	// Without the next block, the alert is sent after AlertLookAhead
+	w.alert(&blockcheck.BlockCheck{Number: 20}, blockcheck.SeveritySerious, false)
+	deadline, ok := w.alertDeadline()
+	if !ok {
+		t.Fatal("expected a held alert")
+	}
+	w.releaseExpiredAlerts(deadline.Add(-time.Second))
+	if len(sent) != 3 {
+		t.Error("alert sent before its deadline")
+	}
+	w.releaseExpiredAlerts(deadline)
+	if len(sent) != 4 || sent[3].PreviousBlock != nil || sent[3].NextBlock != nil {
+		t.Error("expected the alert without context at its deadline")
+	}
+	if _, ok := w.alertDeadline(); ok {
+		t.Error("expected no held alerts")
+	}
+}
@@ -0,0 +1,251 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+// Weight of a new observation in the moving average of the API lag
+const apiLagSmoothing = 0.1
+
+var ErrApiWaitTimeout = errors.New("the mev-blocks API didn't index the blocks in time")
+
+var ErrCheckRetries = errors.New("the check of the block failed too often")
+
+// blockQueue holds the blocks from the new head until their check is reported, in ascending height
+type blockQueue struct {
+	blocks  map[int64]*queuedBlock
+	heights []int64 // ascending
+}
+
+func newBlockQueue() *blockQueue {
+	return &blockQueue{blocks: make(map[int64]*queuedBlock)}
+}
+
+func (q *blockQueue) len() int {
+	return len(q.heights)
+}
+
+func (q *blockQueue) get(height int64) (*queuedBlock, bool) {
+	qb, found := q.blocks[height]
+	return qb, found
+}
+
+// put adds the block, or replaces the block at its height
+func (q *blockQueue) put(qb *queuedBlock) {
+	if _, found := q.blocks[qb.height]; !found {
+		i := sort.Search(len(q.heights), func(i int) bool { return q.heights[i] >= qb.height })
+		q.heights = append(q.heights, 0)
+		copy(q.heights[i+1:], q.heights[i:])
+		q.heights[i] = qb.height
+	}
+	q.blocks[qb.height] = qb
+}
+
+func (q *blockQueue) remove(height int64) {
+	if _, found := q.blocks[height]; !found {
+		return
+	}
+	delete(q.blocks, height)
+	i := sort.Search(len(q.heights), func(i int) bool { return q.heights[i] >= height })
+	q.heights = append(q.heights[:i], q.heights[i+1:]...)
+}
+
+// all returns the blocks in ascending height
+func (q *blockQueue) all() []*queuedBlock {
+	blocks := make([]*queuedBlock, len(q.heights))
+	for i, height := range q.heights {
+		blocks[i] = q.blocks[height]
+	}
+	return blocks
+}
+
+// oldest returns the lowest height, 0 if the queue is empty
+func (q *blockQueue) oldest() int64 {
+	if len(q.heights) == 0 {
+		return 0
+	}
+	return q.heights[0]
+}
+
+// ready returns the waiting blocks up to maxHeight, the blocks of priority miners first, else oldest first
+func (q *blockQueue) ready(maxHeight int64) (blocks []*queuedBlock) {
+	var others []*queuedBlock
+	for _, height := range q.heights {
+		if height > maxHeight {
+			break
+		}
+		if b := q.blocks[height]; b.state == blockWaiting && !b.waitForPoll {
+			if b.priority {
+				blocks = append(blocks, b)
+			} else {
+				others = append(others, b)
+			}
+		}
+	}
+	return append(blocks, others...)
+}
+
+// pending returns the number of blocks up to maxHeight which are not reported yet
+func (q *blockQueue) pending(maxHeight int64) int {
+	return sort.Search(len(q.heights), func(i int) bool { return q.heights[i] > maxHeight })
+}
+
+// spillBlocks moves the block data of the newest waiting blocks out of memory, until at most MaxBacklogMemory blocks
+// are in memory. They are written to SpillDir, or fetched again from the node if it isn't set.
+func (w *Watcher) spillBlocks() {
+	if w.MaxBacklogMemory <= 0 {
+		return
+	}
+	blocks := w.queue.all()
+	inMemory := 0
+	for _, qb := range blocks {
+		if qb.block != nil {
+			inMemory += 1
+		}
+	}
+
+	for i := len(blocks) - 1; i >= 0 && inMemory > w.MaxBacklogMemory; i-- {
+		qb := blocks[i]
+		if qb.block == nil || qb.state != blockWaiting {
+			continue
+		}
+		if w.SpillDir != "" {
+			if err := w.writeSpillFile(qb); err != nil {
+				w.handleError(fmt.Errorf("error spilling block %d to disk: %w", qb.height, err))
+				return
+			}
+		}
+		qb.block = nil
+		qb.spilled = true
+		inMemory -= 1
+	}
+}
+
+func (w *Watcher) spillFile(height int64) string {
+	return filepath.Join(w.SpillDir, fmt.Sprintf("%d.json", height))
+}
+
+func (w *Watcher) writeSpillFile(qb *queuedBlock) error {
+	if err := os.MkdirAll(w.SpillDir, 0755); err != nil {
+		return err
+	}
+	input, err := blockcheck.NewCheckInput(&blockcheck.BlockCheck{Number: qb.height, EthBlock: qb.block.Block, BlockWithTxReceipts: qb.block})
+	if err != nil {
+		return err
+	}
+	return input.WriteFile(w.spillFile(qb.height))
+}
+
+// unspill loads the data of a spilled block. Returns false if it has to be fetched from the node again.
+func (w *Watcher) unspill(qb *queuedBlock) bool {
+	qb.spilled = false
+	if w.SpillDir == "" {
+		return false
+	}
+	defer w.removeSpillFile(qb.height)
+
+	inputs, err := blockcheck.LoadCheckInputs(w.spillFile(qb.height))
+	if err == nil && len(inputs) == 1 {
+		qb.block, err = inputs[0].BlockWithTxReceipts()
+	}
+	if err != nil || qb.block == nil {
+		w.handleError(fmt.Errorf("error loading spilled block %d, fetching it again: %v", qb.height, err))
+		return false
+	}
+	return true
+}
+
+func (w *Watcher) removeSpillFile(height int64) {
+	if w.SpillDir == "" {
+		return
+	}
+	if err := os.Remove(w.spillFile(height)); err != nil && !os.IsNotExist(err) {
+		w.handleError(fmt.Errorf("error removing spilled block %d: %w", height, err))
+	}
+}
+
+// removeQueued removes a block from the queue, with its spill file
+func (w *Watcher) removeQueued(height int64) {
+	if qb, found := w.queue.get(height); found && qb.spilled {
+		w.removeSpillFile(height)
+	}
+	w.queue.remove(height)
+}
+
+// updateApiLag records the lag of the mev-blocks API behind the node
+func (w *Watcher) updateApiLag(apiLatest int64) {
+	if w.latestHeight == 0 || apiLatest == 0 {
+		return
+	}
+	lag := w.latestHeight - apiLatest
+	if lag < 0 {
+		lag = 0
+	}
+	if w.apiLag < 0 {
+		w.apiLagAverage = float64(lag)
+	} else {
+		w.apiLagAverage += apiLagSmoothing * (float64(lag) - w.apiLagAverage)
+	}
+	w.apiLag = lag
+}
+
+// skipTimedOutBlocks removes the blocks which wait for the API longer than ApiWaitTimeout from the queue, with a
+// warning. They are queued again once the API has them (see retrySkippedBlocks). Blocks which the API has, but whose
+// check failed, aren't skipped (see MaxCheckRetries).
+func (w *Watcher) skipTimedOutBlocks(p *pipeline, now time.Time) {
+	if w.ApiWaitTimeout <= 0 {
+		return
+	}
+
+	var skipped []int64
+	for _, qb := range w.queue.all() {
+		if qb.state == blockWaiting && qb.height > p.apiLatestHeight && now.Sub(qb.waitingSince) > w.ApiWaitTimeout {
+			skipped = append(skipped, qb.height)
+			w.skipped[qb.height] = true
+			w.removeQueued(qb.height)
+			delete(w.published, qb.height)
+		}
+	}
+	if len(skipped) > 0 {
+		w.handleError(fmt.Errorf("skipped %d blocks (%d to %d) after waiting %s, API lag %d blocks (average %.1f), they are checked once the API has them: %w",
+			len(skipped), skipped[0], skipped[len(skipped)-1], w.ApiWaitTimeout, w.apiLag, w.apiLagAverage, ErrApiWaitTimeout))
+	}
+}
+
+// retrySkippedBlocks queues the skipped blocks up to the latest block of the API for fetching
+func (w *Watcher) retrySkippedBlocks(p *pipeline) {
+	var retried []int64
+	for height := range w.skipped {
+		if height <= p.apiLatestHeight {
+			retried = append(retried, height)
+		}
+	}
+	sort.Slice(retried, func(i, j int) bool { return retried[i] < retried[j] })
+	for _, height := range retried {
+		delete(w.skipped, height)
+		if _, found := w.queue.get(height); !found {
+			w.queue.put(&queuedBlock{height: height, state: blockFetching})
+			p.toFetch = append(p.toFetch, height)
+		}
+	}
+}
+
+// SkippedBlocks returns the blocks which were skipped because the mev-blocks API didn't index them within
+// ApiWaitTimeout, and are waiting to be retried
+func (w *Watcher) SkippedBlocks() []int64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	skipped := make([]int64, 0, len(w.skipped))
+	for height := range w.skipped {
+		skipped = append(skipped, height)
+	}
+	sort.Slice(skipped, func(i, j int) bool { return skipped[i] < skipped[j] })
+	return skipped
+}
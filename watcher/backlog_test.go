@@ -0,0 +1,162 @@
+package watcher
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+func TestBlockQueue(t *testing.T) {
+	q := newBlockQueue()
+	q.put(&queuedBlock{height: 105, state: blockFetching})
+	q.put(&queuedBlock{height: 103, state: blockWaiting})
+	q.put(&queuedBlock{height: 101, state: blockWaiting})
+	q.put(&queuedBlock{height: 102, state: blockChecking})
+	q.put(&queuedBlock{height: 104, state: blockWaiting, waitForPoll: true})
+
+	// Waiting blocks up to the height, oldest first
+	ready := q.ready(104)
+	if len(ready) != 2 || ready[0].height != 101 || ready[1].height != 103 {
+		t.Error("unexpected ready blocks", ready)
+	}
+	if ready := q.ready(102); len(ready) != 1 || ready[0].height != 101 {
+		t.Error("unexpected ready blocks up to 102", ready)
+	}
+	if ready := q.ready(100); len(ready) != 0 {
+		t.Error("expected no ready blocks up to 100", ready)
+	}
+
+	// Pending counts all blocks up to the height, in any state
+	if pending := q.pending(104); pending != 4 {
+		t.Error("unexpected pending blocks", pending)
+	}
+
+	// Blocks of priority miners jump the queue
+	q.put(&queuedBlock{height: 106, state: blockWaiting, priority: true})
+	q.put(&queuedBlock{height: 107, state: blockWaiting, priority: true})
+	if ready := q.ready(107); len(ready) != 4 || ready[0].height != 106 || ready[1].height != 107 || ready[2].height != 101 {
+		t.Error("expected the priority blocks first", ready)
+	}
+
+	// Replacing a block keeps the order, removing it drops its height
+	q.put(&queuedBlock{height: 103, state: blockFetching})
+	q.remove(101)
+	q.remove(99)
+	if q.len() != 6 || q.oldest() != 102 || q.all()[1].state != blockFetching {
+		t.Error("unexpected queue", q.heights)
+	}
+}
+
+func newTestQueuedBlock(height int64) *queuedBlock {
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(height), Difficulty: big.NewInt(1)})
+	return &queuedBlock{height: height, state: blockWaiting, block: &blockswithtx.BlockWithTxReceipts{Block: block}, waitingSince: time.Now()}
+}
+
+func TestSpillBlocks(t *testing.T) {
+	for _, spillDir := range []string{t.TempDir(), ""} {
+		w := New(nil)
+		w.MaxBacklogMemory = 2
+		w.SpillDir = spillDir
+		w.ErrorHandler = func(err error) { t.Error(err) }
+		for height := int64(1); height <= 4; height++ {
+			w.queue.put(newTestQueuedBlock(height))
+		}
+		w.spillBlocks()
+
+		// The newest blocks are spilled, the oldest are checked first
+		p := &pipeline{apiLatestHeight: 10}
+		w.latestHeight = 10
+		blocks := w.queue.all()
+		if blocks[1].spilled || !blocks[2].spilled || !blocks[3].spilled || blocks[3].block != nil {
+			t.Fatal("expected blocks 3 and 4 to be spilled", spillDir)
+		}
+		w.queue.remove(1)
+		w.queue.remove(2)
+
+		item := w.nextCheckItem(p)
+		if spillDir != "" && (item == nil || item.height != 3 || item.block.Block.NumberU64() != 3) {
+			t.Error("expected block 3 from the spill file", item)
+		}
+		if spillDir == "" && (item != nil || len(p.toFetch) != 2 || p.toFetch[0] != 3) {
+			t.Error("expected the spilled blocks to be fetched again", item, p.toFetch)
+		}
+	}
+}
+
+func TestSkipTimedOutBlocks(t *testing.T) {
+	w := New(nil)
+	w.ApiWaitTimeout = time.Minute
+	var warnings []error
+	w.ErrorHandler = func(err error) { warnings = append(warnings, err) }
+
+	p := &pipeline{apiLatestHeight: 100}
+	w.latestHeight = 103
+	for height := int64(100); height <= 103; height++ {
+		w.queue.put(newTestQueuedBlock(height))
+	}
+	for _, qb := range w.queue.all()[:3] {
+		qb.waitingSince = time.Now().Add(-2 * time.Minute)
+	}
+
+	// Blocks which the API has aren't skipped, nor blocks within the timeout
+	w.skipTimedOutBlocks(p, time.Now())
+	if w.queue.len() != 2 || len(w.SkippedBlocks()) != 2 || len(warnings) != 1 || !errors.Is(warnings[0], ErrApiWaitTimeout) {
+		t.Fatal("expected blocks 101 and 102 to be skipped", w.queue.heights, w.SkippedBlocks(), warnings)
+	}
+
+	// The skipped blocks are checked again after a restart
+	w.lastProcessedHeight = 103
+	w.queue.remove(100)
+	w.queue.remove(103)
+	if checkpoint := w.CheckpointHeight(); checkpoint != 100 {
+		t.Error("unexpected checkpoint", checkpoint)
+	}
+
+	// Retried once the API has them
+	p.apiLatestHeight = 101
+	w.retrySkippedBlocks(p)
+	if skipped := w.SkippedBlocks(); len(skipped) != 1 || skipped[0] != 102 || len(p.toFetch) != 1 || p.toFetch[0] != 101 {
+		t.Error("expected block 101 to be fetched again", skipped, p.toFetch)
+	}
+
+	w.updateApiLag(98)
+	w.updateApiLag(101)
+	if w.apiLag != 2 || w.apiLagAverage <= 2 || w.apiLagAverage >= 5 {
+		t.Error("unexpected API lag", w.apiLag, w.apiLagAverage)
+	}
+}
+
+func TestCheckRetries(t *testing.T) {
+	w := New(nil)
+	w.ApiWaitTimeout = time.Minute
+	w.MaxCheckRetries = 2
+	var warnings []error
+	w.ErrorHandler = func(err error) { warnings = append(warnings, err) }
+
+	p := &pipeline{apiLatestHeight: 100}
+	qb := newTestQueuedBlock(100)
+	qb.waitingSince = time.Now().Add(-2 * time.Minute)
+	w.queue.put(qb)
+
+	// A failed check waits for the next poll, it isn't skipped as an API timeout
+	failCheck := func() {
+		qb.state = blockChecking
+		w.checkDone(p, reportEvent{item: &checkItem{qb: qb, height: 100}, err: errors.New("trace timeout")})
+	}
+	failCheck()
+	w.skipTimedOutBlocks(p, time.Now())
+	if w.queue.len() != 1 || !qb.waitForPoll || len(w.SkippedBlocks()) != 0 || len(warnings) != 0 {
+		t.Fatal("expected the block to wait for a retry", w.queue.heights, w.SkippedBlocks(), warnings)
+	}
+
+	// Dropped after MaxCheckRetries retries
+	failCheck()
+	failCheck()
+	if w.queue.len() != 0 || len(warnings) != 1 || !errors.Is(warnings[0], ErrCheckRetries) {
+		t.Error("expected the block to be dropped with an error", w.queue.heights, warnings)
+	}
+}
@@ -0,0 +1,98 @@
+package watcher
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+// Filter selects the checks a subscriber receives. Empty fields match all checks, all set fields must match.
+type Filter struct {
+	Miners      []string // coinbase addresses
+	ErrorTypes  []string // see blockcheck.ErrorCounts.Types, matches if the check has any of them
+	MinSeverity string   // blockcheck.SeveritySerious or blockcheck.SeverityLessSerious (includes serious)
+	Searchers   []string // matches if any Flashbots or failed tx was sent by one of these addresses
+}
+
+// Matches returns true if the check passes the filter (a nil filter matches all checks)
+func (f *Filter) Matches(check *blockcheck.BlockCheck) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.Miners) > 0 && !containsAddress(f.Miners, check.Miner) {
+		return false
+	}
+
+	switch f.MinSeverity {
+	case blockcheck.SeveritySerious:
+		if !check.HasSeriousErrors() {
+			return false
+		}
+	case blockcheck.SeverityLessSerious:
+		if !check.HasSeriousErrors() && !check.HasLessSeriousErrors() {
+			return false
+		}
+	}
+
+	if len(f.ErrorTypes) > 0 {
+		found := false
+		for _, errorType := range check.ErrorCounter.Types() {
+			for _, wanted := range f.ErrorTypes {
+				found = found || errorType == wanted
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(f.Searchers) > 0 {
+		found := false
+		for _, tx := range check.FlashbotsTransactions {
+			found = found || containsAddress(f.Searchers, tx.EoaAddress)
+		}
+		for _, tx := range check.FailedTx {
+			found = found || containsAddress(f.Searchers, tx.From)
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FilterFromQuery returns the filter of the URL query parameters miner, error_type, severity and searcher (lists are
+// comma-separated), nil if none is set
+func FilterFromQuery(query url.Values) *Filter {
+	split := func(key string) (values []string) {
+		for _, value := range strings.Split(query.Get(key), ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		return values
+	}
+
+	filter := &Filter{
+		Miners:      split("miner"),
+		ErrorTypes:  split("error_type"),
+		MinSeverity: query.Get("severity"),
+		Searchers:   split("searcher"),
+	}
+	if len(filter.Miners) == 0 && len(filter.ErrorTypes) == 0 && filter.MinSeverity == "" && len(filter.Searchers) == 0 {
+		return nil
+	}
+	return filter
+}
+
+func containsAddress(addresses []string, address string) bool {
+	for _, a := range addresses {
+		if strings.EqualFold(a, address) {
+			return true
+		}
+	}
+	return false
+}
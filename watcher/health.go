@@ -0,0 +1,166 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Kinds of HealthAlert
+const (
+	HealthAlertHeadLag        = "head-lag"        // more than MaxHeadLag blocks behind the head
+	HealthAlertApiUnreachable = "api-unreachable" // the mev-blocks API failed for MaxApiDowntime
+)
+
+// HealthAlert is an operational alert about the watcher itself, sent to OnHealthAlert when the condition starts,
+// and again with Resolved when it ends
+type HealthAlert struct {
+	Kind     string
+	Resolved bool
+	Message  string
+}
+
+// Health is the connectivity and progress of the watcher (see Health and HealthHandler)
+type Health struct {
+	Running          bool      `json:"running"`        // Run is active
+	NodeConnected    bool      `json:"node_connected"` // head subscription active, and a header received within HeadStallTimeout
+	LastHeaderAt     time.Time `json:"last_header_at"`
+	ApiReachable     bool      `json:"api_reachable"` // the last poll of the mev-blocks API succeeded
+	ApiLastSuccess   time.Time `json:"api_last_success"`
+	ApiError         string    `json:"api_error,omitempty"` // error of the last poll, while unreachable
+	LatestBlock      int64     `json:"latest_block"`        // latest block received from the node
+	LastCheckedBlock int64     `json:"last_checked_block"`
+	HeadLag          int64     `json:"head_lag"` // blocks behind the chain tip
+	Backlog          int       `json:"backlog"`  // blocks not yet checked
+	Skipped          int       `json:"skipped"`  // blocks skipped after ApiWaitTimeout, checked once the API has them
+	ApiLag           int64     `json:"api_lag"`  // blocks of the mev-blocks API behind the node at the last poll
+	ApiLagAverage    float64   `json:"api_lag_average"`
+
+	Ready    bool     `json:"ready"`
+	Problems []string `json:"problems,omitempty"` // why it isn't ready
+}
+
+// Health returns the current health. The watcher is ready if Run is active, receives new blocks, reaches the
+// mev-blocks API, and is at most MaxHeadLag blocks behind the head (if set).
+func (w *Watcher) Health() Health {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.health(time.Now())
+}
+
+func (w *Watcher) health(now time.Time) Health {
+	h := Health{
+		Running:          w.running,
+		NodeConnected:    w.running && now.Sub(w.lastHeaderAt) <= w.HeadStallTimeout,
+		LastHeaderAt:     w.lastHeaderAt,
+		ApiReachable:     !w.apiLastSuccess.IsZero() && w.apiFailingSince.IsZero(),
+		ApiLastSuccess:   w.apiLastSuccess,
+		ApiError:         w.apiError,
+		LatestBlock:      w.latestHeight,
+		LastCheckedBlock: w.lastProcessedHeight,
+		Backlog:          w.queue.len(),
+		Skipped:          len(w.skipped),
+		ApiLag:           w.apiLag,
+		ApiLagAverage:    w.apiLagAverage,
+	}
+	if w.lastProcessedHeight > 0 && w.latestHeight > w.lastProcessedHeight {
+		h.HeadLag = w.latestHeight - w.lastProcessedHeight
+	}
+
+	if !h.Running {
+		h.Problems = append(h.Problems, "not running")
+	} else if !h.NodeConnected {
+		h.Problems = append(h.Problems, fmt.Sprintf("no new block from the node since %s", w.lastHeaderAt.Format(time.RFC3339)))
+	}
+	if !h.ApiReachable {
+		h.Problems = append(h.Problems, "mev-blocks API not reachable")
+	}
+	if w.MaxHeadLag > 0 && h.HeadLag > w.MaxHeadLag {
+		h.Problems = append(h.Problems, fmt.Sprintf("%d blocks behind the head", h.HeadLag))
+	}
+	h.Ready = len(h.Problems) == 0
+	return h
+}
+
+// setRunning records the start and end of Run. The start counts as the last header, until the first one arrives.
+func (w *Watcher) setRunning(running bool, start time.Time) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.running = running
+	if running && w.lastHeaderAt.Before(start) {
+		w.lastHeaderAt = start
+	}
+}
+
+// setApiPolled records the result of a poll of the mev-blocks API
+func (w *Watcher) setApiPolled(err error) {
+	if w.Session != nil {
+		w.Session.apiPolled(err, time.Now())
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if err == nil {
+		w.apiLastSuccess = time.Now()
+		w.apiFailingSince = time.Time{}
+		w.apiError = ""
+		return
+	}
+	if w.apiFailingSince.IsZero() {
+		w.apiFailingSince = time.Now()
+	}
+	w.apiError = err.Error()
+}
+
+// healthAlerts returns the alerts for the conditions which started or ended since the last call (see MaxHeadLag
+// and MaxApiDowntime). Only called by the Run loop.
+func (w *Watcher) healthAlerts(now time.Time) (alerts []*HealthAlert) {
+	w.lock.Lock()
+	health := w.health(now)
+	apiFailingSince := w.apiFailingSince
+	w.lock.Unlock()
+
+	update := func(kind string, active bool, message string) {
+		if active == w.activeHealthAlerts[kind] {
+			return
+		}
+		w.activeHealthAlerts[kind] = active
+		alerts = append(alerts, &HealthAlert{Kind: kind, Resolved: !active, Message: message})
+	}
+
+	if w.MaxHeadLag > 0 {
+		if health.HeadLag > w.MaxHeadLag {
+			update(HealthAlertHeadLag, true, fmt.Sprintf("watcher is %d blocks behind the head (block %d checked, head %d, backlog %d)", health.HeadLag, health.LastCheckedBlock, health.LatestBlock, health.Backlog))
+		} else {
+			update(HealthAlertHeadLag, false, fmt.Sprintf("watcher caught up with the head (%d blocks behind)", health.HeadLag))
+		}
+	}
+	if w.MaxApiDowntime > 0 {
+		if !apiFailingSince.IsZero() && now.Sub(apiFailingSince) >= w.MaxApiDowntime {
+			update(HealthAlertApiUnreachable, true, fmt.Sprintf("mev-blocks API unreachable for %s: %s", now.Sub(apiFailingSince).Round(time.Second), health.ApiError))
+		} else if apiFailingSince.IsZero() {
+			update(HealthAlertApiUnreachable, false, "mev-blocks API reachable again")
+		}
+	}
+	return alerts
+}
+
+// HealthHandler serves the health as JSON: /healthz (liveness) answers 200 while Run is active and receives new
+// blocks, /readyz (readiness) answers 200 if the watcher is ready (see Health). Both answer 503 otherwise.
+func (w *Watcher) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		health := w.Health()
+		ok := health.Running && health.NodeConnected
+		if r.URL.Path == "/readyz" {
+			ok = health.Ready
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Cache-Control", "no-cache, max-age=0")
+		if !ok {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(rw).Encode(health)
+	})
+}
@@ -0,0 +1,79 @@
+package watcher
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthAlerts(t *testing.T) {
+	w := &Watcher{HeadStallTimeout: time.Minute, MaxHeadLag: 10, MaxApiDowntime: 5 * time.Minute, activeHealthAlerts: make(map[string]bool)}
+	now := time.Now()
+	w.setRunning(true, now)
+	w.setApiPolled(nil)
+	w.latestHeight, w.lastProcessedHeight = 100, 95
+
+	if health := w.Health(); !health.Ready || health.HeadLag != 5 {
+		t.Fatal("expected ready", health)
+	}
+	if alerts := w.healthAlerts(now); len(alerts) != 0 {
+		t.Fatal("unexpected alerts", alerts)
+	}
+
+	// Lagging, and the API fails (for less than MaxApiDowntime)
+	w.latestHeight = 120
+	w.setApiPolled(errors.New("timeout"))
+	health := w.Health()
+	if health.Ready || health.ApiReachable || health.ApiError != "timeout" || len(health.Problems) != 2 {
+		t.Fatal("expected not ready", health)
+	}
+	alerts := w.healthAlerts(now)
+	if len(alerts) != 1 || alerts[0].Kind != HealthAlertHeadLag || alerts[0].Resolved {
+		t.Fatal("expected a head lag alert", alerts)
+	}
+	if alerts := w.healthAlerts(now); len(alerts) != 0 {
+		t.Fatal("the alert is only sent once", alerts)
+	}
+
+	alerts = w.healthAlerts(now.Add(6 * time.Minute))
+	if len(alerts) != 1 || alerts[0].Kind != HealthAlertApiUnreachable || alerts[0].Resolved {
+		t.Fatal("expected an API alert", alerts)
+	}
+
+	// Both resolved
+	w.lastProcessedHeight = 118
+	w.setApiPolled(nil)
+	if alerts = w.healthAlerts(now.Add(7 * time.Minute)); len(alerts) != 2 || !alerts[0].Resolved || !alerts[1].Resolved {
+		t.Fatal("expected 2 resolved alerts", alerts)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	w := &Watcher{HeadStallTimeout: time.Minute}
+	w.setRunning(true, time.Now())
+
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		w.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	// Alive, but not ready until the API was reached
+	if code := get("/healthz"); code != http.StatusOK {
+		t.Error("expected /healthz 200, got", code)
+	}
+	if code := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Error("expected /readyz 503, got", code)
+	}
+	w.setApiPolled(nil)
+	if code := get("/readyz"); code != http.StatusOK {
+		t.Error("expected /readyz 200, got", code)
+	}
+
+	w.setRunning(false, time.Now())
+	if code := get("/healthz"); code != http.StatusServiceUnavailable {
+		t.Error("expected /healthz 503 when stopped, got", code)
+	}
+}
@@ -0,0 +1,337 @@
+package watcher
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/schema"
+)
+
+// CheckSink receives every check result (see Watcher.Sinks)
+type CheckSink interface {
+	SaveCheck(check *blockcheck.BlockCheck) error
+}
+
+// RotatingFile appends lines to a file, and rotates it when it exceeds MaxSize: the file is renamed with a timestamp
+// (eg. checks.jsonl -> checks-20211016T120000.000000000.jsonl) and a new file is started. Only the newest MaxBackups rotated
+// files are kept, or with Cold, the old rotated files are moved to the cold storage.
+type RotatingFile struct {
+	Filename   string
+	MaxSize    int64    // bytes, 0 = never rotate
+	MaxBackups int      // 0 = keep all rotated files, ignored with Cold
+	Cold       *Tiering // optional, the cold storage of the old rotated files
+
+	lock     sync.Mutex
+	tierLock sync.RWMutex // moving files to the cold storage, and reading
+	file     *os.File
+	size     int64
+}
+
+func NewRotatingFile(filename string, maxSize int64, maxBackups int) *RotatingFile {
+	return &RotatingFile{Filename: filename, MaxSize: maxSize, MaxBackups: maxBackups}
+}
+
+// WriteLine appends the data and a newline in one write
+func (f *RotatingFile) WriteLine(data []byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+
+	line := append(data, '\n')
+	if f.MaxSize > 0 && f.size > 0 && f.size+int64(len(line)) > f.MaxSize {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := f.file.Write(line)
+	f.size += int64(n)
+	return err
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.Filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// backupPrefix and backupSuffix of the rotated files, eg. "checks-" and ".jsonl"
+func (f *RotatingFile) backupPrefix() (prefix string, suffix string) {
+	suffix = filepath.Ext(f.Filename)
+	return strings.TrimSuffix(f.Filename, suffix) + "-", suffix
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	prefix, suffix := f.backupPrefix()
+	backup := prefix + time.Now().UTC().Format("20060102T150405.000000000") + suffix
+	if err := os.Rename(f.Filename, backup); err != nil {
+		return err
+	}
+	if err := f.removeOldBackups(); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+// Backups returns the rotated files, oldest first
+func (f *RotatingFile) Backups() ([]string, error) {
+	prefix, suffix := f.backupPrefix()
+	files, err := filepath.Glob(prefix + "*" + suffix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files) // timestamps sort chronologically
+	return files, nil
+}
+
+func (f *RotatingFile) removeOldBackups() error {
+	if f.MaxBackups <= 0 || f.Cold != nil {
+		return nil
+	}
+
+	backups, err := f.Backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > f.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// ReadLines calls fn for every line of the files in the cold storage, the rotated files (oldest first) and of the
+// current file
+func (f *RotatingFile) ReadLines(fn func(line []byte) error) error {
+	f.tierLock.RLock()
+	defer f.tierLock.RUnlock()
+	if err := f.readColdLines(fn); err != nil {
+		return err
+	}
+
+	files, err := f.Backups()
+	if err != nil {
+		return err
+	}
+	for _, filename := range append(files, f.Filename) {
+		file, err := os.Open(filename)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		err = scanLines(file, fn)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+	return nil
+}
+
+// scanLines calls fn for every line of r
+func scanLines(r io.Reader, fn func(line []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (f *RotatingFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+// JSONLSink appends all check results (schema.CheckResult) to checks.jsonl, and the incidents of checks with serious
+// errors (schema.Incident) to incidents.jsonl in a directory, with rotation by size. It only needs the file system,
+// eg. for air-gapped deployments without a database.
+type JSONLSink struct {
+	Checks    *RotatingFile
+	Incidents *RotatingFile
+	Inputs    *RotatingFile // the inputs of the checks (blockcheck.CheckInput) for replays, only with ArchiveInputs
+
+	archiveInputs bool
+}
+
+func NewJSONLSink(dir string, maxSize int64, maxBackups int) (*JSONLSink, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &JSONLSink{
+		Checks:    NewRotatingFile(filepath.Join(dir, "checks.jsonl"), maxSize, maxBackups),
+		Incidents: NewRotatingFile(filepath.Join(dir, "incidents.jsonl"), maxSize, maxBackups),
+		Inputs:    NewRotatingFile(filepath.Join(dir, "inputs.jsonl"), maxSize, maxBackups),
+	}, nil
+}
+
+// ArchiveInputs makes SaveCheck also append the inputs of every check to inputs.jsonl (the block with its receipts
+// and the Flashbots API data), so the blocks can be checked again later without the node (see ReadInputs)
+func (s *JSONLSink) ArchiveInputs() {
+	s.archiveInputs = true
+}
+
+// SetColdStorage moves the rotated files older than the MaxAge of the tiering to its cold storage (see Tiering.Run
+// with Files), and reads them back from there
+func (s *JSONLSink) SetColdStorage(tiering *Tiering) {
+	for _, file := range s.Files() {
+		file.Cold = tiering
+	}
+}
+
+// Files returns the checks, incidents and inputs files
+func (s *JSONLSink) Files() []*RotatingFile {
+	return []*RotatingFile{s.Checks, s.Incidents, s.Inputs}
+}
+
+func (s *JSONLSink) SaveCheck(check *blockcheck.BlockCheck) error {
+	line, err := json.Marshal(schema.NewCheckResult(check))
+	if err != nil {
+		return err
+	}
+	if err = s.Checks.WriteLine(line); err != nil {
+		return fmt.Errorf("error writing check: %w", err)
+	}
+
+	if s.archiveInputs {
+		input, err := blockcheck.NewCheckInput(check)
+		if err != nil {
+			return err
+		}
+		if line, err = json.Marshal(input); err != nil {
+			return err
+		}
+		if err = s.Inputs.WriteLine(line); err != nil {
+			return fmt.Errorf("error writing check input: %w", err)
+		}
+	}
+
+	if !check.HasSeriousErrors() {
+		return nil
+	}
+	line, err = json.Marshal(schema.NewIncident(check.Incident()))
+	if err != nil {
+		return err
+	}
+	if err = s.Incidents.WriteLine(line); err != nil {
+		return fmt.Errorf("error writing incident: %w", err)
+	}
+	return nil
+}
+
+// Find returns the check results and incidents of a block (several if it was checked again, eg. after a reorg)
+func (s *JSONLSink) Find(block int64) (checks []schema.CheckResult, incidents []schema.Incident, err error) {
+	key := []byte(fmt.Sprintf(`"block_number":%d,`, block)) // skips unmarshalling the other lines
+
+	err = s.Checks.ReadLines(func(line []byte) error {
+		if !bytes.Contains(line, key) {
+			return nil
+		}
+		var check schema.CheckResult
+		if err := json.Unmarshal(line, &check); err != nil {
+			return err
+		}
+		if check.BlockNumber == block {
+			checks = append(checks, check)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.Incidents.ReadLines(func(line []byte) error {
+		if !bytes.Contains(line, key) {
+			return nil
+		}
+		var incident schema.Incident
+		if err := json.Unmarshal(line, &incident); err != nil {
+			return err
+		}
+		if incident.BlockNumber == block {
+			incidents = append(incidents, incident)
+		}
+		return nil
+	})
+	return checks, incidents, err
+}
+
+// ReadChecks calls fn for every saved check result of the blocks from ... to (0 = no limit), oldest first
+func (s *JSONLSink) ReadChecks(from int64, to int64, fn func(check schema.CheckResult) error) error {
+	return s.Checks.ReadLines(func(line []byte) error {
+		var check schema.CheckResult
+		if err := json.Unmarshal(line, &check); err != nil {
+			return err
+		}
+		if check.BlockNumber < from || (to > 0 && check.BlockNumber > to) {
+			return nil
+		}
+		return fn(check)
+	})
+}
+
+// ReadInputs calls fn for every archived check input of the blocks from ... to (0 = no limit), oldest first
+func (s *JSONLSink) ReadInputs(from int64, to int64, fn func(input *blockcheck.CheckInput) error) error {
+	return s.Inputs.ReadLines(func(line []byte) error {
+		input := new(blockcheck.CheckInput)
+		if err := json.Unmarshal(line, input); err != nil {
+			return err
+		}
+		if input.BlockNumber < from || (to > 0 && input.BlockNumber > to) {
+			return nil
+		}
+		return fn(input)
+	})
+}
+
+func (s *JSONLSink) Close() error {
+	err := s.Checks.Close()
+	for _, file := range []*RotatingFile{s.Incidents, s.Inputs} {
+		if err2 := file.Close(); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "checks.jsonl")
+	f := NewRotatingFile(filename, 20, 2)
+	defer f.Close()
+
+	// 10 bytes per line: 2 lines per file
+	for i := 0; i < 7; i++ {
+		if err := f.WriteLine([]byte("{\"i\":123}")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	backups, err := f.Backups()
+	if err != nil || len(backups) != 2 {
+		t.Fatal("expected 2 rotated files", backups, err)
+	}
+	for _, fn := range append(backups, filename) {
+		data, err := os.ReadFile(fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := 20
+		if fn == filename {
+			expected = 10
+		}
+		if len(data) != expected {
+			t.Errorf("unexpected size of %s: %d", fn, len(data))
+		}
+	}
+
+	// Appends to the existing file after reopening
+	f.Close()
+	f = NewRotatingFile(filename, 0, 0)
+	if err = f.WriteLine([]byte("{\"i\":456}")); err != nil {
+		t.Fatal(err)
+	}
+	if f.size != 20 {
+		t.Error("unexpected size after reopening:", f.size)
+	}
+
+	// Rotated files first, the oldest two lines were pruned
+	lines := 0
+	if err = f.ReadLines(func(line []byte) error { lines += 1; return nil }); err != nil || lines != 6 {
+		t.Error("unexpected number of lines:", lines, err)
+	}
+	f.Close()
+}
@@ -0,0 +1,111 @@
+package watcher
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+// LoadShedder sheds the expensive checks (traces, simulations, see blockcheck.CheckBlockFast) while the watcher lags
+// behind the chain, so the fast checks and their alerts stay timely during congestion. The partially checked blocks
+// are re-checked completely once the lag is back to normal.
+//
+// The lag is the distance of the checked block to the latest block, which includes the confirmations and the delay
+// of the mev-blocks API (usually ~5 blocks). Re-checks run when the check stage has no other block to check.
+// LoadShedder is safe for concurrent use.
+type LoadShedder struct {
+	MaxLag    int64         // shed when blocks are checked more than this many blocks behind the head ...
+	Sustained time.Duration // ... for at least this long
+
+	lock     sync.Mutex
+	lagSince time.Time // since when the lag is above MaxLag
+	shedding bool
+	recheck  map[int64]*blockcheck.BlockCheck // partial checks, by height
+
+	NumShed      uint64 // partially checked blocks
+	NumRechecked uint64
+}
+
+func NewLoadShedder(maxLag int64, sustained time.Duration) *LoadShedder {
+	return &LoadShedder{
+		MaxLag:    maxLag,
+		Sustained: sustained,
+		recheck:   make(map[int64]*blockcheck.BlockCheck),
+	}
+}
+
+// Update sets the current lag, and returns whether expensive checks should be shed, and whether that changed
+func (s *LoadShedder) Update(lag int64, now time.Time) (shed bool, changed bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if lag <= s.MaxLag {
+		s.lagSince = time.Time{}
+	} else if s.lagSince.IsZero() {
+		s.lagSince = now
+	}
+
+	shed = !s.lagSince.IsZero() && now.Sub(s.lagSince) >= s.Sustained
+	changed = shed != s.shedding
+	s.shedding = shed
+	return shed, changed
+}
+
+// Shedding returns true while expensive checks are shed
+func (s *LoadShedder) Shedding() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.shedding
+}
+
+// AddRecheck queues a partially checked block for a complete check
+func (s *LoadShedder) AddRecheck(check *blockcheck.BlockCheck) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.recheck[check.Number] == nil {
+		s.NumShed += 1
+	}
+	s.recheck[check.Number] = check
+}
+
+// RemoveRecheck removes the block from the queue (eg. after it was re-checked, or reorged and queued again)
+func (s *LoadShedder) RemoveRecheck(height int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.recheck, height)
+}
+
+// PendingRechecks returns the heights of the blocks waiting for a complete check, oldest first
+func (s *LoadShedder) PendingRechecks() []int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	heights := make([]int64, 0, len(s.recheck))
+	for height := range s.recheck {
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights
+}
+
+// pendingChecks returns the partial checks waiting for a complete check, oldest first
+func (s *LoadShedder) pendingChecks() []*blockcheck.BlockCheck {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	checks := make([]*blockcheck.BlockCheck, 0, len(s.recheck))
+	for _, check := range s.recheck {
+		checks = append(checks, check)
+	}
+	sort.Slice(checks, func(i, j int) bool { return checks[i].Number < checks[j].Number })
+	return checks
+}
+
+// rechecked removes the partial check after its complete re-check (unless the block was reorged meanwhile)
+func (s *LoadShedder) rechecked(previous *blockcheck.BlockCheck) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.recheck[previous.Number] == previous {
+		delete(s.recheck, previous.Number)
+		s.NumRechecked += 1
+	}
+}
@@ -0,0 +1,54 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+func TestLoadShedder(t *testing.T) {
+	s := NewLoadShedder(10, time.Minute)
+	now := time.Now()
+
+	if shed, changed := s.Update(20, now); shed || changed {
+		t.Error("expected no shedding before the lag is sustained")
+	}
+	if shed, changed := s.Update(20, now.Add(time.Minute)); !shed || !changed {
+		t.Error("expected shedding after a minute of lag")
+	}
+
+	s.AddRecheck(&blockcheck.BlockCheck{Number: 102})
+	s.AddRecheck(&blockcheck.BlockCheck{Number: 100})
+	s.AddRecheck(&blockcheck.BlockCheck{Number: 101})
+	s.AddRecheck(&blockcheck.BlockCheck{Number: 101})
+	if s.NumShed != 3 || !s.Shedding() {
+		t.Error("expected 3 shed blocks while shedding", s.NumShed)
+	}
+
+	// Lag back to normal: the oldest blocks are re-checked first
+	if shed, changed := s.Update(5, now.Add(2*time.Minute)); shed || !changed {
+		t.Error("expected shedding to stop")
+	}
+	if rechecks := s.pendingChecks(); len(rechecks) != 3 || rechecks[0].Number != 100 || rechecks[1].Number != 101 {
+		t.Error("unexpected re-checks", rechecks)
+	}
+
+	// Re-checked blocks are removed, unless the partial check was replaced meanwhile (eg. after a reorg)
+	s.rechecked(s.pendingChecks()[0])
+	s.rechecked(&blockcheck.BlockCheck{Number: 101})
+	if pending := s.PendingRechecks(); len(pending) != 2 || pending[0] != 101 || s.NumRechecked != 1 {
+		t.Error("unexpected re-checks after re-checking", pending, s.NumRechecked)
+	}
+
+	// A short lag spike doesn't shed
+	if shed, _ := s.Update(20, now.Add(3*time.Minute)); shed {
+		t.Error("expected no shedding for a lag spike")
+	}
+	if shed, _ := s.Update(5, now.Add(3*time.Minute+30*time.Second)); shed {
+		t.Error("expected no shedding")
+	}
+	if shed, _ := s.Update(20, now.Add(4*time.Minute)); shed {
+		t.Error("expected the sustained time to restart after the lag was normal")
+	}
+}
@@ -0,0 +1,148 @@
+package watcher
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+// Default number of checked blocks after which a MEV-Share hint which didn't land is counted as expired
+const DefaultMevShareWindow = 25
+
+// MevShareTracker correlates the hints of the MEV-Share stream with the checked blocks (it's a CheckSink): a hint is
+// matched when its transaction lands in a Flashbots bundle (ie. it was backrun), landed when it's in a block outside
+// of a bundle, and expired when it's in none of the next Window blocks. It's safe for concurrent use.
+type MevShareTracker struct {
+	Window int64
+
+	lock    sync.Mutex
+	pending map[string]*mevShareHint // by tx hash, a bundle hint has an entry for each of its hashes
+	stats   MevShareStats
+}
+
+type mevShareHint struct {
+	hashes     []string
+	firstBlock int64 // the first block checked after the hint was received
+}
+
+// MevShareStats are the counts of the hints since the start
+type MevShareStats struct {
+	Hints     int64   `json:"hints"`   // received
+	Matched   int64   `json:"matched"` // landed in a Flashbots bundle
+	Landed    int64   `json:"landed"`  // landed outside of a bundle
+	Expired   int64   `json:"expired"` // didn't land within the window
+	Pending   int64   `json:"pending"`
+	AvgBlocks float64 `json:"avg_blocks"` // avg. number of blocks from the hint to the landed tx (matched and landed)
+
+	blocksTotal int64
+}
+
+func NewMevShareTracker(window int64) *MevShareTracker {
+	return &MevShareTracker{
+		Window:  window,
+		pending: make(map[string]*mevShareHint),
+	}
+}
+
+// AddHint starts tracking a hint, hints without a tx hash (eg. bundles without shared hashes) can't be matched and
+// are ignored
+func (t *MevShareTracker) AddHint(hint api.MevShareHint) {
+	hashes := hint.TxHashes()
+	if len(hashes) == 0 {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if _, found := t.pending[hashes[0]]; found { // a hint can be sent again, eg. with more logs
+		return
+	}
+	entry := &mevShareHint{hashes: hashes}
+	for _, hash := range hashes {
+		t.pending[hash] = entry
+	}
+	t.stats.Hints += 1
+}
+
+// SaveCheck matches the pending hints with the transactions of the block, and expires the hints older than the window
+func (t *MevShareTracker) SaveCheck(check *blockcheck.BlockCheck) error {
+	if check.EthBlock == nil {
+		return nil
+	}
+	inBundle := make(map[string]bool)
+	for _, tx := range check.FlashbotsTransactions {
+		if tx.BundleType != "" {
+			inBundle[strings.ToLower(tx.Hash)] = true
+		}
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, tx := range check.EthBlock.Transactions() {
+		hash := tx.Hash().Hex()
+		entry, found := t.pending[hash]
+		if !found {
+			continue
+		}
+		t.remove(entry)
+		if inBundle[hash] {
+			t.stats.Matched += 1
+		} else {
+			t.stats.Landed += 1
+		}
+		if entry.firstBlock > 0 && check.Number >= entry.firstBlock {
+			t.stats.blocksTotal += check.Number - entry.firstBlock + 1
+		} else {
+			t.stats.blocksTotal += 1
+		}
+	}
+
+	for _, entry := range t.pending {
+		if entry.firstBlock == 0 {
+			entry.firstBlock = check.Number
+		} else if check.Number-entry.firstBlock >= t.Window {
+			t.remove(entry)
+			t.stats.Expired += 1
+		}
+	}
+	return nil
+}
+
+func (t *MevShareTracker) remove(entry *mevShareHint) {
+	for _, hash := range entry.hashes {
+		delete(t.pending, hash)
+	}
+}
+
+// Stats returns the counts of the hints until now
+func (t *MevShareTracker) Stats() MevShareStats {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	stats := t.stats
+	pending := make(map[*mevShareHint]bool)
+	for _, entry := range t.pending {
+		pending[entry] = true
+	}
+	stats.Pending = int64(len(pending))
+	if landed := stats.Matched + stats.Landed; landed > 0 {
+		stats.AvgBlocks = float64(stats.blocksTotal) / float64(landed)
+	}
+	return stats
+}
+
+// MatchRate is the share of the resolved hints (matched, landed or expired) which landed in a bundle
+func (s MevShareStats) MatchRate() float64 {
+	resolved := s.Matched + s.Landed + s.Expired
+	if resolved == 0 {
+		return 0
+	}
+	return float64(s.Matched) / float64(resolved)
+}
+
+func (s MevShareStats) String() string {
+	return fmt.Sprintf("%d hints: %d matched (landed in a bundle), %d landed without bundle, %d expired, %d pending - match rate: %.1f%%, avg. %.1f blocks to land", s.Hints, s.Matched, s.Landed, s.Expired, s.Pending, s.MatchRate()*100, s.AvgBlocks)
+}
@@ -0,0 +1,48 @@
+package watcher
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+func TestMevShareTracker(t *testing.T) {
+	txs := make([]*types.Transaction, 3)
+	for i := range txs {
+		txs[i] = types.NewTransaction(uint64(i), common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	}
+	newCheck := func(number int64, txs []*types.Transaction, bundleTxs ...*types.Transaction) *blockcheck.BlockCheck {
+		check := &blockcheck.BlockCheck{Number: number, EthBlock: types.NewBlock(&types.Header{Number: big.NewInt(number)}, txs, nil, nil, trie.NewStackTrie(nil))}
+		for _, tx := range bundleTxs {
+			check.FlashbotsTransactions = append(check.FlashbotsTransactions, api.FlashbotsTransaction{Hash: tx.Hash().Hex(), BundleType: "flashbots"})
+		}
+		return check
+	}
+
+	tracker := NewMevShareTracker(2)
+	tracker.AddHint(api.MevShareHint{Hash: txs[0].Hash().Hex()})
+	tracker.AddHint(api.MevShareHint{Hash: txs[0].Hash().Hex()}) // sent again
+	tracker.AddHint(api.MevShareHint{Hash: "0xbundle", Txs: []api.MevShareHintTx{{Hash: txs[1].Hash().Hex()}}})
+	tracker.AddHint(api.MevShareHint{Hash: "0xexpires"})
+	tracker.AddHint(api.MevShareHint{}) // no hash
+
+	tracker.SaveCheck(newCheck(100, nil))
+	tracker.SaveCheck(newCheck(101, txs[:2], txs[0]))
+	if stats := tracker.Stats(); stats.Hints != 3 || stats.Matched != 1 || stats.Landed != 1 || stats.Pending != 1 || stats.AvgBlocks != 2 {
+		t.Error("unexpected stats", stats)
+	}
+
+	tracker.SaveCheck(newCheck(102, txs[2:]))
+	stats := tracker.Stats()
+	if stats.Expired != 1 || stats.Pending != 0 {
+		t.Error("expected the hint to expire", stats)
+	}
+	if rate := stats.MatchRate(); rate < 0.33 || rate > 0.34 {
+		t.Error("unexpected match rate", rate)
+	}
+}
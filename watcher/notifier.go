@@ -0,0 +1,25 @@
+package watcher
+
+import "github.com/metachris/flashbots/blockcheck"
+
+// Notifier receives the checks with errors, with severity blockcheck.SeveritySerious or blockcheck.SeverityLessSerious
+type Notifier interface {
+	Notify(check *blockcheck.BlockCheck, severity string) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface
+type NotifierFunc func(check *blockcheck.BlockCheck, severity string) error
+
+func (f NotifierFunc) Notify(check *blockcheck.BlockCheck, severity string) error {
+	return f(check, severity)
+}
+
+// CheckSeverity returns the severity with which the check is sent to the notifiers, empty if it isn't sent
+func CheckSeverity(check *blockcheck.BlockCheck) string {
+	if check.HasSeriousErrors() {
+		return blockcheck.SeveritySerious
+	} else if check.HasLessSeriousErrors() {
+		return blockcheck.SeverityLessSerious
+	}
+	return ""
+}
@@ -0,0 +1,673 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/api"
+	"github.com/metachris/flashbots/audit"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+// States of a block in the queue
+const (
+	blockFetching = iota // waiting for the block and receipts from the node
+	blockWaiting         // waiting for the confirmations and the mev-blocks API
+	blockChecking        // sent to the check stage, being checked or reported
+)
+
+type queuedBlock struct {
+	height       int64
+	block        *blockswithtx.BlockWithTxReceipts // nil while fetching
+	state        int
+	waitForPoll  bool // the check failed, retried after the next API poll
+	failedChecks int  // failed checks of the block, it's dropped after MaxCheckRetries
+	priority     bool // mined by one of the PriorityMiners
+
+	waitingSince time.Time // fetched, waiting for the confirmations and the API (see ApiWaitTimeout)
+	spilled      bool      // block data moved out of memory (see MaxBacklogMemory)
+}
+
+// checkItem is a block for the check stage
+type checkItem struct {
+	qb       *queuedBlock
+	height   int64
+	block    *blockswithtx.BlockWithTxReceipts // nil for re-checks, fetched by the check stage
+	previous *blockcheck.BlockCheck            // partial check, for re-checks (see LoadShedder)
+	priority bool                              // mined by one of the PriorityMiners
+}
+
+// reportEvent is delivered by the report stage, which runs all callbacks, notifiers and sinks one after the other
+type reportEvent struct {
+	item     *checkItem // result of the check stage
+	check    *blockcheck.BlockCheck
+	err      error
+	newBlock *blockswithtx.BlockWithTxReceipts
+	reorg    *ReorgedBlock
+	loadShed *loadShedChange
+	health   *HealthAlert
+}
+
+type loadShedChange struct {
+	shedding bool
+	lag      int64
+}
+
+type fetchResult struct {
+	height int64
+	block  *blockswithtx.BlockWithTxReceipts
+	err    error
+}
+
+// StagePanic is a panic in one of the pipeline stages, re-raised by Run (so it can be recovered by the caller)
+type StagePanic struct {
+	Stage     string
+	Height    int64 // block which was processed, 0 if none
+	Recovered interface{}
+	Stack     []byte // of the stage goroutine
+}
+
+func (p *StagePanic) String() string {
+	return fmt.Sprintf("%s stage, block %d: %v", p.Stage, p.Height, p.Recovered)
+}
+
+// pipeline are the channels between the stages of one Run:
+//
+//	header intake -> receipt fetch (FetchWorkers) -> API availability gate -> check -> report
+//
+// The intake and the gate are the Run loop, which owns the queue. It never blocks on sending to the other stages
+// (work is kept in the pending lists until a stage can take it), so the bounded channels can't deadlock.
+type pipeline struct {
+	fetchQueue  chan int64
+	fetched     chan fetchResult
+	checkQueue  chan *checkItem // unbuffered: blocks are sent when the check stage is free, with the latest state
+	reportQueue chan reportEvent
+	done        chan reportEvent // reported checks, back to the Run loop
+	pollTrigger chan bool
+	apiLatest   chan int64
+	panics      chan *StagePanic
+
+	toFetch         []int64
+	fetchFailed     []int64 // retried with the next head
+	events          []reportEvent
+	rechecking      map[int64]bool
+	recheckWait     map[int64]bool // failed re-checks, retried after the next API poll
+	apiLatestHeight int64
+}
+
+func (w *Watcher) startPipeline(ctx context.Context) *pipeline {
+	queueSize := w.QueueSize
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+	fetchWorkers := w.FetchWorkers
+	if fetchWorkers <= 0 {
+		fetchWorkers = 1
+	}
+
+	p := &pipeline{
+		fetchQueue:  make(chan int64, fetchWorkers),
+		fetched:     make(chan fetchResult, queueSize),
+		checkQueue:  make(chan *checkItem),
+		reportQueue: make(chan reportEvent, queueSize),
+		done:        make(chan reportEvent),
+		pollTrigger: make(chan bool, 1),
+		apiLatest:   make(chan int64, 1),
+		panics:      make(chan *StagePanic, 1),
+		rechecking:  make(map[int64]bool),
+		recheckWait: make(map[int64]bool),
+	}
+
+	for i := 0; i < fetchWorkers; i++ {
+		go w.fetchStage(ctx, p)
+	}
+	go w.apiPollStage(ctx, p)
+	go w.checkStage(ctx, p)
+	go w.reportStage(ctx, p)
+	return p
+}
+
+// recoverStage forwards a panic of a stage goroutine to the Run loop
+func (w *Watcher) recoverStage(p *pipeline, stage string, height *int64) {
+	if r := recover(); r != nil {
+		select {
+		case p.panics <- &StagePanic{Stage: stage, Height: *height, Recovered: r, Stack: debug.Stack()}:
+		default: // another stage panicked already
+		}
+	}
+}
+
+// fetchStage gets the blocks with receipts
+func (w *Watcher) fetchStage(ctx context.Context, p *pipeline) {
+	var height int64
+	defer w.recoverStage(p, "fetch", &height)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case height = <-p.fetchQueue:
+			b, err := w.getBlock(ctx, height)
+			select {
+			case p.fetched <- fetchResult{height, b, err}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// apiPollStage gets the latest block of the mev-blocks API when triggered
+func (w *Watcher) apiPollStage(ctx context.Context, p *pipeline) {
+	var height int64
+	defer w.recoverStage(p, "api-poll", &height)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.pollTrigger:
+			response, err := api.GetBlocks(&api.GetBlocksOptions{Limit: 1})
+			w.setApiPolled(err)
+			if err != nil {
+				w.handleError(fmt.Errorf("flashbots API error: %w", err))
+				continue
+			}
+			select {
+			case <-p.apiLatest: // replace an unread value
+			default:
+			}
+			p.apiLatest <- response.LatestBlockNumber
+		}
+	}
+}
+
+// checkStage checks one block at a time (the checks share package-level state of blockcheck, on-demand checks of
+// CheckBlock wait for the check in progress)
+func (w *Watcher) checkStage(ctx context.Context, p *pipeline) {
+	var height int64
+	defer w.recoverStage(p, "check", &height)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case item := <-p.checkQueue:
+			height = item.height
+			events := w.check(ctx, item)
+			for _, event := range events {
+				select {
+				case p.reportQueue <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			height = 0
+		}
+	}
+}
+
+// check runs the check of the item, and returns the events for the report stage
+func (w *Watcher) check(ctx context.Context, item *checkItem) (events []reportEvent) {
+	result := reportEvent{item: item}
+	if item.previous != nil { // complete re-check of a partially checked block
+		b, err := w.getBlock(ctx, item.height)
+		if err != nil {
+			result.err = fmt.Errorf("error fetching block %d for the re-check: %w", item.height, err)
+			return append(events, result)
+		}
+		item.block = b
+		result.check, result.err = w.runCheck(blockcheck.CheckBlock, b)
+		if result.err != nil {
+			result.err = fmt.Errorf("CheckBlock error at re-check of block %d: %w", item.height, result.err)
+		}
+		return append(events, result)
+	}
+
+	// Blocks of priority miners always get the complete check, and don't count for the lag of the backlog
+	checkBlock := blockcheck.CheckBlock
+	var shed bool
+	var change *loadShedChange
+	if !item.priority {
+		shed, change = w.shedExpensiveChecks(item.height)
+	}
+	if shed || w.AlertOnly {
+		checkBlock = blockcheck.CheckBlockFast
+	}
+	if change != nil {
+		events = append(events, reportEvent{loadShed: change})
+	}
+
+	result.check, result.err = w.runCheck(checkBlock, item.block)
+	if result.err != nil {
+		result.err = fmt.Errorf("CheckBlock error at block %d: %w", item.height, result.err)
+	}
+	return append(events, result)
+}
+
+// reportStage runs the callbacks, notifiers and sinks, one event after the other
+func (w *Watcher) reportStage(ctx context.Context, p *pipeline) {
+	var height int64
+	defer w.recoverStage(p, "report", &height)
+	for {
+		// Held alerts are sent when the next block was checked, or at their deadline
+		var alertTimer *time.Timer
+		var alertTimeout <-chan time.Time
+		if deadline, ok := w.alertDeadline(); ok {
+			alertTimer = time.NewTimer(time.Until(deadline))
+			alertTimeout = alertTimer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			w.releaseAlerts(nil)
+			return
+		case <-alertTimeout:
+			w.releaseExpiredAlerts(time.Now())
+		case event := <-p.reportQueue:
+			switch {
+			case event.newBlock != nil:
+				if w.OnNewBlock != nil {
+					w.OnNewBlock(event.newBlock)
+				}
+			case event.reorg != nil:
+				if w.Session != nil {
+					w.Session.AddReorg()
+				}
+				if w.OnReorg != nil {
+					w.OnReorg(*event.reorg)
+				}
+			case event.loadShed != nil:
+				if w.OnLoadShed != nil {
+					w.OnLoadShed(event.loadShed.shedding, event.loadShed.lag)
+				}
+			case event.health != nil:
+				if w.OnHealthAlert != nil {
+					w.OnHealthAlert(*event.health)
+				}
+			case event.item != nil:
+				height = event.item.height
+				w.report(&event)
+				height = 0
+				select {
+				case p.done <- event:
+				case <-ctx.Done():
+					w.releaseAlerts(nil)
+					return
+				}
+			}
+		}
+		if alertTimer != nil {
+			alertTimer.Stop()
+		}
+	}
+}
+
+// report delivers a check result. Checks of blocks which were replaced meanwhile are dropped, the new block is
+// checked instead.
+func (w *Watcher) report(event *reportEvent) {
+	if event.err != nil {
+		w.handleError(event.err)
+		return
+	}
+
+	w.lock.Lock()
+	canonical := w.reorgTracker.IsCanonical(event.check.EthBlock)
+	w.lock.Unlock()
+	if !canonical {
+		event.check = nil
+		return
+	}
+
+	if previous := event.item.previous; previous != nil {
+		w.MinerLeaderboard.RemoveCheck(previous)
+		if w.OnRecheck != nil {
+			w.OnRecheck(previous)
+		}
+	}
+	w.processCheck(event.check, event.item.previous)
+}
+
+// Run watches new blocks until the context is cancelled, the head subscription fails or stalls. On cancellation,
+// the remaining confirmed blocks of the queue are processed for up to 30 seconds. Run can be called again after
+// an error, eg. with a new client. Panics of the pipeline stages are re-raised as *StagePanic.
+func (w *Watcher) Run(ctx context.Context) error {
+	headers := make(chan *types.Header)
+	sub, err := w.client.SubscribeNewHead(ctx, headers)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	// The stages stop when Run returns, the blocks in progress are taken up by the next Run
+	stageCtx, stopStages := context.WithCancel(context.Background())
+	defer stopStages()
+	p := w.startPipeline(stageCtx)
+	w.requeue(p)
+
+	// Health check: detect a stalled head subscription, and send the health alerts
+	healthCheckTicker := time.NewTicker(30 * time.Second)
+	defer healthCheckTicker.Stop()
+	lastHeaderReceived := time.Now()
+	w.setRunning(true, lastHeaderReceived)
+	defer w.setRunning(false, lastHeaderReceived)
+
+	// While blocks wait for the API, it's polled in addition to every new head
+	pollInterval := w.ApiPollInterval
+	if pollInterval <= 0 {
+		pollInterval = 3 * time.Second
+	}
+	pollTicker := time.NewTicker(pollInterval)
+	defer pollTicker.Stop()
+	w.triggerPoll(p)
+
+	ctxDone := ctx.Done()
+	subErr := sub.Err()
+	var drainDeadline <-chan time.Time
+	draining := false
+
+	for {
+		if draining && w.numConfirmedBlocksInBacklog() == 0 {
+			w.saveCheckpoint()
+			return ctx.Err()
+		}
+
+		// Work for the stages, sent only when they can take it (sending on a nil channel is disabled)
+		var fetchQueue chan int64
+		var nextFetch int64
+		if len(p.toFetch) > 0 {
+			fetchQueue, nextFetch = p.fetchQueue, p.toFetch[0]
+		}
+		var checkQueue chan *checkItem
+		nextCheck := w.nextCheckItem(p)
+		if nextCheck != nil {
+			checkQueue = p.checkQueue
+		}
+		var reportQueue chan reportEvent
+		var nextEvent reportEvent
+		if len(p.events) > 0 {
+			reportQueue, nextEvent = p.reportQueue, p.events[0]
+		}
+
+		select {
+		case <-ctxDone:
+			// Stop the intake, and process the confirmed blocks for up to 30 seconds
+			draining = true
+			ctxDone, subErr, headers = nil, nil, nil
+			sub.Unsubscribe()
+			drainDeadline = time.After(30 * time.Second)
+		case <-drainDeadline:
+			w.saveCheckpoint()
+			return ctx.Err()
+		case err := <-subErr:
+			return err
+		case <-healthCheckTicker.C:
+			if !draining && time.Since(lastHeaderReceived) > w.HeadStallTimeout {
+				return ErrHeadStalled
+			}
+			for _, alert := range w.healthAlerts(time.Now()) {
+				p.events = append(p.events, reportEvent{health: alert})
+			}
+			w.lock.Lock()
+			w.skipTimedOutBlocks(p, time.Now())
+			w.lock.Unlock()
+		case header := <-headers:
+			lastHeaderReceived = time.Now()
+			w.addHeader(p, header)
+			w.triggerPoll(p)
+		case fetchQueue <- nextFetch:
+			p.toFetch = p.toFetch[1:]
+		case result := <-p.fetched:
+			w.addFetchedBlock(p, result)
+		case <-pollTicker.C:
+			if w.isWaitingForApi(p) {
+				w.triggerPoll(p)
+			}
+		case latest := <-p.apiLatest:
+			w.setApiLatest(p, latest)
+		case checkQueue <- nextCheck:
+			w.lock.Lock()
+			if nextCheck.qb != nil {
+				nextCheck.qb.state = blockChecking
+			} else {
+				p.rechecking[nextCheck.height] = true
+			}
+			w.lock.Unlock()
+		case reportQueue <- nextEvent:
+			p.events = p.events[1:]
+		case event := <-p.done:
+			w.checkDone(p, event)
+		case stagePanic := <-p.panics:
+			w.lock.Lock()
+			w.processingHeight = stagePanic.Height
+			w.lock.Unlock()
+			panic(stagePanic)
+		}
+	}
+}
+
+// requeue takes up the blocks of the queue at the start of Run (after a restart, blocks can be in any state)
+func (w *Watcher) requeue(p *pipeline) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for _, qb := range w.queue.all() {
+		if qb.block == nil && !qb.spilled {
+			qb.state = blockFetching
+			p.toFetch = append(p.toFetch, qb.height)
+		} else {
+			qb.state = blockWaiting
+		}
+	}
+}
+
+func (w *Watcher) triggerPoll(p *pipeline) {
+	select {
+	case p.pollTrigger <- true:
+	default: // already triggered
+	}
+}
+
+// addHeader detects reorgs, and queues the new block (and the replaced ones) for fetching
+func (w *Watcher) addHeader(p *pipeline, header *types.Header) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.lastHeaderAt = time.Now()
+
+	reorgedBlocks, err := w.reorgTracker.AddHeader(w.client, header)
+	if err != nil {
+		w.handleError(err)
+	}
+	for i := range reorgedBlocks {
+		reorged := reorgedBlocks[i]
+		p.events = append(p.events, reportEvent{reorg: &reorged})
+		if w.LoadShedder != nil { // the new block gets its own check
+			w.LoadShedder.RemoveRecheck(reorged.Height)
+		}
+		w.removeQueued(reorged.Height)
+		w.queue.put(&queuedBlock{height: reorged.Height, state: blockFetching})
+		p.toFetch = append(p.toFetch, reorged.Height)
+	}
+
+	height := header.Number.Int64()
+	w.removeQueued(height)
+	w.queue.put(&queuedBlock{height: height, state: blockFetching})
+	delete(w.skipped, height)
+	p.toFetch = append(p.toFetch, height)
+	if height > w.latestHeight {
+		w.latestHeight = height
+	}
+
+	// Blocks which couldn't be fetched are retried with every new head
+	p.toFetch = append(p.toFetch, p.fetchFailed...)
+	p.fetchFailed = nil
+}
+
+// addFetchedBlock moves a fetched block to the API gate
+func (w *Watcher) addFetchedBlock(p *pipeline, result fetchResult) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	qb, found := w.queue.get(result.height)
+	if !found || qb.state != blockFetching {
+		return
+	}
+	if result.err != nil {
+		w.handleError(fmt.Errorf("error in GetBlockWithTxReceipts: %w", result.err))
+		p.fetchFailed = append(p.fetchFailed, result.height)
+		return
+	}
+	if !w.reorgTracker.IsCanonical(result.block.Block) { // replaced while fetching
+		p.toFetch = append(p.toFetch, result.height)
+		return
+	}
+
+	qb.block = result.block
+	qb.state = blockWaiting
+	if qb.waitingSince.IsZero() { // not for spilled blocks which were fetched again
+		qb.waitingSince = time.Now()
+	}
+	qb.priority = containsAddress(w.PriorityMiners, result.block.Block.Coinbase().Hex())
+	w.audit(audit.Event{Time: time.Unix(int64(result.block.Block.Time()), 0), Block: result.height, Type: audit.EventMined})
+	w.audit(audit.Event{Block: result.height, Type: audit.EventReceived})
+	if result.height <= p.apiLatestHeight {
+		w.setPublished(result.height)
+	}
+	p.events = append(p.events, reportEvent{newBlock: result.block})
+	w.spillBlocks()
+}
+
+// setApiLatest opens the API gate up to the latest block of the API, and queues the skipped blocks it has now
+func (w *Watcher) setApiLatest(p *pipeline, latest int64) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if latest > p.apiLatestHeight {
+		p.apiLatestHeight = latest
+	}
+	w.updateApiLag(latest)
+	for _, qb := range w.queue.all() {
+		qb.waitForPoll = false
+		if qb.height <= p.apiLatestHeight && qb.state != blockFetching {
+			w.setPublished(qb.height)
+		}
+	}
+	p.recheckWait = make(map[int64]bool)
+	w.retrySkippedBlocks(p)
+}
+
+// setPublished records when a block was first seen in the API (see Audit)
+func (w *Watcher) setPublished(height int64) {
+	if !w.published[height] {
+		w.published[height] = true
+		w.audit(audit.Event{Block: height, Type: audit.EventPublished})
+	}
+}
+
+// isWaitingForApi returns true if fetched blocks wait for the API
+func (w *Watcher) isWaitingForApi(p *pipeline) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for _, qb := range w.queue.all() {
+		if qb.state == blockWaiting && (qb.height > p.apiLatestHeight || qb.waitForPoll) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextCheckItem returns the oldest block which passed the API gate and has enough confirmations (blocks of
+// PriorityMiners first). If there is none
+// and expensive checks aren't shed, it returns the oldest partially checked block for its re-check.
+func (w *Watcher) nextCheckItem(p *pipeline) *checkItem {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	maxHeight := w.latestHeight - w.Confirmations
+	if p.apiLatestHeight < maxHeight {
+		maxHeight = p.apiLatestHeight
+	}
+	for _, qb := range w.queue.ready(maxHeight) {
+		if qb.spilled && !w.unspill(qb) {
+			qb.state = blockFetching
+			p.toFetch = append(p.toFetch, qb.height)
+			continue
+		}
+		return &checkItem{qb: qb, height: qb.height, block: qb.block, priority: qb.priority}
+	}
+
+	if w.LoadShedder == nil || w.LoadShedder.Shedding() {
+		return nil
+	}
+	for _, previous := range w.LoadShedder.pendingChecks() {
+		if !p.rechecking[previous.Number] && !p.recheckWait[previous.Number] {
+			return &checkItem{height: previous.Number, previous: previous}
+		}
+	}
+	return nil
+}
+
+// checkDone removes a reported block from the queue, or queues it again after an error
+func (w *Watcher) checkDone(p *pipeline, event reportEvent) {
+	item := event.item
+	w.lock.Lock()
+
+	if item.qb == nil { // re-check
+		delete(p.rechecking, item.height)
+		if event.err != nil {
+			p.recheckWait[item.height] = true
+		} else {
+			w.LoadShedder.rechecked(item.previous)
+		}
+		w.lock.Unlock()
+		return
+	}
+
+	if qb, _ := w.queue.get(item.height); qb != item.qb { // reorged meanwhile, the new block is queued
+		w.lock.Unlock()
+		return
+	}
+	if event.err != nil {
+		item.qb.failedChecks += 1
+		if w.MaxCheckRetries > 0 && item.qb.failedChecks > w.MaxCheckRetries {
+			w.removeQueued(item.height)
+			delete(w.published, item.height)
+			w.lock.Unlock()
+			w.handleError(fmt.Errorf("block %d dropped after %d failed checks: %w", item.height, item.qb.failedChecks, ErrCheckRetries))
+			return
+		}
+		item.qb.state = blockWaiting
+		item.qb.waitForPoll = true
+		w.lock.Unlock()
+		return
+	}
+
+	w.queue.remove(item.height)
+	delete(w.published, item.height)
+	if item.height > w.lastProcessedHeight {
+		w.lastProcessedHeight = item.height
+	}
+	if event.check != nil && len(event.check.ShedSteps) > 0 {
+		w.LoadShedder.AddRecheck(event.check)
+	}
+	w.lock.Unlock()
+
+	w.saveCheckpoint()
+}
+
+// shedExpensiveChecks updates the load shedder with the lag of the block, and returns whether its expensive checks
+// should be skipped, and the change of the shedding state (nil if unchanged)
+func (w *Watcher) shedExpensiveChecks(height int64) (shed bool, change *loadShedChange) {
+	if w.LoadShedder == nil {
+		return false, nil
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	lag := w.latestHeight - height
+	shed, changed := w.LoadShedder.Update(lag, time.Now())
+	if changed {
+		change = &loadShedChange{shed, lag}
+	}
+	return shed, change
+}
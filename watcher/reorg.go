@@ -0,0 +1,116 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+// ReorgTracker remembers the hashes of recent blocks and detects when a new head replaces
+// blocks that were already seen (and possibly already checked and reported).
+type ReorgTracker struct {
+	MaxDepth int64 // how many blocks back a reorg is detected
+
+	hashByHeight  map[int64]common.Hash
+	reportedCheck map[int64]*blockcheck.BlockCheck // checks that were already processed, by height
+}
+
+// ReorgedBlock is a height at which the canonical block has changed
+type ReorgedBlock struct {
+	Height        int64
+	OldHash       common.Hash
+	NewHash       common.Hash
+	ReportedCheck *blockcheck.BlockCheck // check of the replaced block, if it was already processed
+}
+
+func NewReorgTracker(maxDepth int64) *ReorgTracker {
+	return &ReorgTracker{
+		MaxDepth:      maxDepth,
+		hashByHeight:  make(map[int64]common.Hash),
+		reportedCheck: make(map[int64]*blockcheck.BlockCheck),
+	}
+}
+
+// HeaderSource fetches the headers of the replaced chain, eg. *ethclient.Client
+type HeaderSource interface {
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+}
+
+// AddHeader stores the new head, walks back along the parent hashes and returns all heights where
+// the previously known block was replaced.
+func (t *ReorgTracker) AddHeader(client HeaderSource, header *types.Header) (reorged []ReorgedBlock, err error) {
+	height := header.Number.Int64()
+
+	// A new block at an already known height replaces the old one
+	if oldHash, found := t.hashByHeight[height]; found && oldHash != header.Hash() {
+		reorged = append(reorged, t.replace(height, oldHash, header.Hash()))
+	}
+	t.hashByHeight[height] = header.Hash()
+
+	// Follow the parent hashes until they match the known chain
+	parentHash := header.ParentHash
+	for h := height - 1; h > height-t.MaxDepth; h-- {
+		knownHash, found := t.hashByHeight[h]
+		if !found || knownHash == parentHash {
+			break
+		}
+
+		reorged = append(reorged, t.replace(h, knownHash, parentHash))
+		t.hashByHeight[h] = parentHash
+
+		parent, err := client.HeaderByHash(context.Background(), parentHash)
+		if err != nil {
+			return reorged, fmt.Errorf("reorg: error fetching header %s: %w", parentHash, err)
+		}
+		parentHash = parent.ParentHash
+	}
+
+	t.prune(height)
+	return reorged, nil
+}
+
+// SetReported remembers a processed check, so it can be invalidated if the block gets reorged
+func (t *ReorgTracker) SetReported(check *blockcheck.BlockCheck) {
+	t.reportedCheck[check.Number] = check
+}
+
+// IsCanonical returns false if a different block than the given one is known at its height
+func (t *ReorgTracker) IsCanonical(block *types.Block) bool {
+	hash, found := t.hashByHeight[block.Number().Int64()]
+	return !found || hash == block.Hash()
+}
+
+func (t *ReorgTracker) replace(height int64, oldHash common.Hash, newHash common.Hash) ReorgedBlock {
+	r := ReorgedBlock{
+		Height:        height,
+		OldHash:       oldHash,
+		NewHash:       newHash,
+		ReportedCheck: t.reportedCheck[height],
+	}
+	delete(t.reportedCheck, height)
+	return r
+}
+
+func (t *ReorgTracker) prune(latestHeight int64) {
+	for h := range t.hashByHeight {
+		if h <= latestHeight-t.MaxDepth {
+			delete(t.hashByHeight, h)
+		}
+	}
+	for h := range t.reportedCheck {
+		if h <= latestHeight-t.MaxDepth {
+			delete(t.reportedCheck, h)
+		}
+	}
+}
+
+func (r ReorgedBlock) String() string {
+	msg := fmt.Sprintf("Block %d reorged: %s was replaced by %s", r.Height, r.OldHash, r.NewHash)
+	if r.ReportedCheck != nil && r.ReportedCheck.HasErrors() {
+		msg += fmt.Sprintf(" - %d previously reported errors are invalid", len(r.ReportedCheck.Errors))
+	}
+	return msg
+}
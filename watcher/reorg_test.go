@@ -0,0 +1,108 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+// testHeaders is a HeaderSource of the headers by hash
+type testHeaders map[common.Hash]*types.Header
+
+func (h testHeaders) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	if header, found := h[hash]; found {
+		return header, nil
+	}
+	return nil, fmt.Errorf("unknown header %s", hash)
+}
+
+// testChain returns the headers of a chain from the parent up to the height, made unique by the fork name
+func testChain(headers testHeaders, parent common.Hash, from int64, to int64, fork string) (chain []*types.Header) {
+	for height := from; height <= to; height++ {
+		header := &types.Header{Number: big.NewInt(height), ParentHash: parent, Difficulty: big.NewInt(1), Extra: []byte(fork)}
+		headers[header.Hash()] = header
+		chain = append(chain, header)
+		parent = header.Hash()
+	}
+	return chain
+}
+
+func TestReorgTracker(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxDepth int64
+		forkAt   int64 // first height of the new chain
+		head     int64 // height of the new head
+		reorged  []int64
+	}{
+		{"same height replacement", 10, 12, 12, []int64{12}},
+		{"new head on a replaced parent", 10, 12, 13, []int64{12}},
+		{"multi-block reorg", 10, 10, 13, []int64{12, 11, 10}},
+		{"reorg deeper than max depth", 2, 10, 13, []int64{12}},
+		{"no reorg", 10, 13, 13, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			headers := make(testHeaders)
+			tracker := NewReorgTracker(test.maxDepth)
+			old := testChain(headers, common.Hash{}, 8, 12, "old")
+			for _, header := range old {
+				if reorged, err := tracker.AddHeader(headers, header); err != nil || len(reorged) != 0 {
+					t.Fatal("unexpected reorg of the old chain", reorged, err)
+				}
+				tracker.SetReported(&blockcheck.BlockCheck{Number: header.Number.Int64(), Errors: []string{"error"}})
+			}
+
+			fork := testChain(headers, old[test.forkAt-9].Hash(), test.forkAt, test.head, "new")
+			reorged, err := tracker.AddHeader(headers, fork[len(fork)-1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(reorged) != len(test.reorged) {
+				t.Fatalf("got %d reorged blocks, expected %v", len(reorged), test.reorged)
+			}
+			for i, r := range reorged {
+				oldHeader, newHeader := old[r.Height-8], fork[r.Height-test.forkAt]
+				if r.Height != test.reorged[i] || r.OldHash != oldHeader.Hash() || r.NewHash != newHeader.Hash() {
+					t.Errorf("unexpected reorged block %d: %s", test.reorged[i], r)
+				}
+				if r.ReportedCheck == nil || r.ReportedCheck.Number != r.Height {
+					t.Errorf("expected the reported check of block %d", r.Height)
+				}
+				if !strings.Contains(r.String(), "1 previously reported errors are invalid") {
+					t.Errorf("expected the invalidated errors in the notification: %s", r)
+				}
+				if tracker.IsCanonical(types.NewBlockWithHeader(oldHeader)) || !tracker.IsCanonical(types.NewBlockWithHeader(newHeader)) {
+					t.Errorf("expected the new block %d to be canonical", r.Height)
+				}
+			}
+		})
+	}
+}
+
+func TestReorgTrackerReportedOnce(t *testing.T) {
+	headers := make(testHeaders)
+	tracker := NewReorgTracker(10)
+	old := testChain(headers, common.Hash{}, 1, 1, "old")[0]
+	tracker.AddHeader(headers, old)
+	tracker.SetReported(&blockcheck.BlockCheck{Number: 1})
+
+	// The check is invalidated by the first replacement, the notification of a clean check mentions no errors
+	first := testChain(headers, common.Hash{}, 1, 1, "first")[0]
+	reorged, _ := tracker.AddHeader(headers, first)
+	if len(reorged) != 1 || reorged[0].ReportedCheck == nil || strings.Contains(reorged[0].String(), "invalid") {
+		t.Fatal("unexpected reorg", reorged)
+	}
+	second := testChain(headers, common.Hash{}, 1, 1, "second")[0]
+	reorged, _ = tracker.AddHeader(headers, second)
+	if len(reorged) != 1 || reorged[0].ReportedCheck != nil || reorged[0].OldHash != first.Hash() {
+		t.Fatal("unexpected second reorg", reorged)
+	}
+}
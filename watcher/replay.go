@@ -0,0 +1,30 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+// Replay checks the blocks of stored inputs (see blockcheck.LoadCheckInputs) instead of the blocks of the node, and
+// delivers the results like Run: to the sinks, notifiers, callbacks and subscribers. It's a dry run for the
+// configuration, the thresholds and the notifiers, without node or mev-blocks API. Held alerts are sent at the end.
+// Returns the number of replayed blocks; check errors go to the ErrorHandler.
+func (w *Watcher) Replay(ctx context.Context, inputs []*blockcheck.CheckInput) (replayed int, err error) {
+	defer w.releaseAlerts(nil)
+	for _, input := range inputs {
+		if err := ctx.Err(); err != nil {
+			return replayed, err
+		}
+
+		check, err := blockcheck.Replay(input)
+		if err != nil {
+			w.handleError(fmt.Errorf("replay error at block %d: %w", input.BlockNumber, err))
+			continue
+		}
+		w.processCheck(check, nil)
+		replayed += 1
+	}
+	return replayed, nil
+}
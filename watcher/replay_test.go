@@ -0,0 +1,40 @@
+package watcher
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+func TestReplay(t *testing.T) {
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(100), Difficulty: big.NewInt(1)})
+	input, err := blockcheck.NewCheckInput(&blockcheck.BlockCheck{Number: 100, EthBlock: block, BlockWithTxReceipts: &blockswithtx.BlockWithTxReceipts{Block: block}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := &blockcheck.CheckInput{BlockNumber: 101, Block: "0x00"}
+
+	var checked []int64
+	var errors []error
+	w := New(nil)
+	w.OnBlockChecked = func(check *blockcheck.BlockCheck) { checked = append(checked, check.Number) }
+	w.ErrorHandler = func(err error) { errors = append(errors, err) }
+
+	replayed, err := w.Replay(context.Background(), []*blockcheck.CheckInput{input, invalid})
+	if err != nil || replayed != 1 {
+		t.Fatal("expected one replayed block", replayed, err)
+	}
+	if len(checked) != 1 || checked[0] != 100 || len(errors) != 1 {
+		t.Error("unexpected replay", checked, errors)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if replayed, err := w.Replay(ctx, []*blockcheck.CheckInput{input}); err == nil || replayed != 0 {
+		t.Error("expected the cancelled replay to stop", replayed, err)
+	}
+}
@@ -0,0 +1,339 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Version of the replica dumps
+const ReplicaVersion = "v1"
+
+// Datasets of the replica dumps (the files of JSONLSink)
+const (
+	ReplicaDatasetChecks    = "checks"
+	ReplicaDatasetIncidents = "incidents"
+)
+
+var ReplicaDatasets = []string{ReplicaDatasetChecks, ReplicaDatasetIncidents}
+
+// Response header with the base64 ed25519 signature of the dump (the response body)
+const ReplicaSignatureHeader = "X-Replica-Signature"
+
+// Default and max. number of records per dump
+var (
+	ReplicaDumpLimit    = 1000
+	ReplicaMaxDumpLimit = 10000
+)
+
+// ErrReplicaAnchorMismatch means the history of the source changed before the replica's position (eg. old files were
+// removed by the rotation): the replica has to sync again from scratch.
+var ErrReplicaAnchorMismatch = errors.New("replica anchor mismatch, sync again from scratch")
+
+// ReplicaDump is an incremental dump of a dataset: the records (JSON lines) from position From up to Next. Positions
+// count the lines of all files of the dataset, the anchors are the hashes of the line before the position (see
+// LineHash), so a replica can't continue on a changed history.
+type ReplicaDump struct {
+	Version    string            `json:"version"`
+	Dataset    string            `json:"dataset"`
+	From       int64             `json:"from"`
+	Next       int64             `json:"next"`
+	Anchor     string            `json:"anchor"`      // hash of the line before From, empty at 0
+	NextAnchor string            `json:"next_anchor"` // hash of the line before Next, to continue the sync
+	Records    []json.RawMessage `json:"records"`
+	Complete   bool              `json:"complete"` // no more records at the time of the dump
+}
+
+// LineHash is the hex sha256 of a line, used as anchor of the dumps
+func LineHash(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+// file returns the file of a dataset
+func (s *JSONLSink) file(dataset string) (*RotatingFile, error) {
+	switch dataset {
+	case ReplicaDatasetChecks:
+		return s.Checks, nil
+	case ReplicaDatasetIncidents:
+		return s.Incidents, nil
+	}
+	return nil, fmt.Errorf("unknown dataset '%s'", dataset)
+}
+
+// errDumpFull stops reading the lines once the dump is full
+var errDumpFull = errors.New("dump full")
+
+// Dump returns up to limit records of the dataset from the position. The anchor must be the hash of the line before
+// the position (see ReplicaDump), else ErrReplicaAnchorMismatch is returned.
+func (s *JSONLSink) Dump(dataset string, from int64, anchor string, limit int) (*ReplicaDump, error) {
+	file, err := s.file(dataset)
+	if err != nil {
+		return nil, err
+	}
+
+	dump := &ReplicaDump{Version: ReplicaVersion, Dataset: dataset, From: from, Next: from, Anchor: anchor, NextAnchor: anchor, Records: make([]json.RawMessage, 0)}
+	var position int64
+	err = file.ReadLines(func(line []byte) error {
+		position += 1
+		switch {
+		case position == from && LineHash(line) != anchor:
+			return ErrReplicaAnchorMismatch
+		case position <= from:
+			return nil
+		case len(dump.Records) == limit:
+			return errDumpFull
+		case !json.Valid(line): // the current file is being appended to
+			return errDumpFull
+		}
+
+		dump.Records = append(dump.Records, append(json.RawMessage{}, line...))
+		dump.Next, dump.NextAnchor = position, LineHash(line)
+		return nil
+	})
+	if errors.Is(err, errDumpFull) {
+		return dump, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if position < from || (from == 0 && anchor != "") {
+		return nil, ErrReplicaAnchorMismatch
+	}
+	dump.Complete = true
+	return dump, nil
+}
+
+// ReplicaHandler serves signed incremental dumps of the JSONL sink, so third parties can mirror the check results
+// and incidents without access to the files (see Replica):
+//
+//	GET ?dataset=checks&from=<position>&anchor=<hash>&limit=<n>
+//
+// The response is a ReplicaDump, signed with the key in the X-Replica-Signature header. A changed history before
+// the position is answered with 409 Conflict.
+func ReplicaHandler(sink *JSONLSink, key ed25519.PrivateKey) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		from, err := strconv.ParseInt(query.Get("from"), 10, 64)
+		if err != nil && query.Get("from") != "" || from < 0 {
+			http.Error(rw, "invalid from", http.StatusBadRequest)
+			return
+		}
+		limit := ReplicaDumpLimit
+		if query.Get("limit") != "" {
+			limit, err = strconv.Atoi(query.Get("limit"))
+			if err != nil || limit <= 0 || limit > ReplicaMaxDumpLimit {
+				http.Error(rw, fmt.Sprintf("limit must be between 1 and %d", ReplicaMaxDumpLimit), http.StatusBadRequest)
+				return
+			}
+		}
+
+		dump, err := sink.Dump(query.Get("dataset"), from, query.Get("anchor"), limit)
+		if errors.Is(err, ErrReplicaAnchorMismatch) {
+			http.Error(rw, err.Error(), http.StatusConflict)
+			return
+		} else if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		body, err := json.Marshal(dump)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set(ReplicaSignatureHeader, base64.StdEncoding.EncodeToString(ed25519.Sign(key, body)))
+		rw.Write(body)
+	})
+}
+
+// GenerateReplicaKey returns a new signing key, and its public key (both hex)
+func GenerateReplicaKey() (privateKeyHex string, publicKeyHex string, err error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(privateKey.Seed()), hex.EncodeToString(publicKey), nil
+}
+
+// LoadReplicaKey reads a signing key (the hex seed of GenerateReplicaKey) from a file
+func LoadReplicaKey(filename string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid replica key in %s", filename)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// ParseReplicaPublicKey parses a hex public key (see GenerateReplicaKey)
+func ParseReplicaPublicKey(publicKeyHex string) (ed25519.PublicKey, error) {
+	publicKey, err := hex.DecodeString(strings.TrimSpace(publicKeyHex))
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid replica public key '%s'", publicKeyHex)
+	}
+	return publicKey, nil
+}
+
+// replicaPosition is the sync state of a dataset
+type replicaPosition struct {
+	Next   int64  `json:"next"`
+	Anchor string `json:"anchor"`
+}
+
+// Replica mirrors the datasets of a ReplicaHandler into a local JSONL sink (a read replica, which can be used like
+// the source directory). Only dumps with a valid signature of PublicKey are applied. The positions are saved in
+// replica-state.json in the directory.
+type Replica struct {
+	URL       string
+	PublicKey ed25519.PublicKey
+	Sink      *JSONLSink
+	StateFile string
+	Client    *http.Client
+	Limit     int // records per request
+}
+
+func NewReplica(sourceUrl string, publicKey ed25519.PublicKey, dir string) (*Replica, error) {
+	sink, err := NewJSONLSink(dir, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Replica{
+		URL:       sourceUrl,
+		PublicKey: publicKey,
+		Sink:      sink,
+		StateFile: filepath.Join(dir, "replica-state.json"),
+		Client:    http.DefaultClient,
+		Limit:     ReplicaDumpLimit,
+	}, nil
+}
+
+func (r *Replica) loadState() (map[string]replicaPosition, error) {
+	state := make(map[string]replicaPosition)
+	data, err := os.ReadFile(r.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", r.StateFile, err)
+	}
+	return state, nil
+}
+
+// saveState writes the state to a temporary file first, so it's never partially written
+func (r *Replica) saveState(state map[string]replicaPosition) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpFile := r.StateFile + ".tmp"
+	if err = os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, r.StateFile)
+}
+
+// Sync fetches the new records of all datasets until the replica has caught up, and returns the number of records
+// added. Returns ErrReplicaAnchorMismatch if the source history changed: the replica directory has to be removed
+// and synced again.
+func (r *Replica) Sync(ctx context.Context) (added int, err error) {
+	state, err := r.loadState()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, dataset := range ReplicaDatasets {
+		file, _ := r.Sink.file(dataset)
+		for {
+			position := state[dataset]
+			dump, err := r.fetchDump(ctx, dataset, position)
+			if err != nil {
+				return added, fmt.Errorf("%s: %w", dataset, err)
+			}
+
+			for _, record := range dump.Records {
+				if err = file.WriteLine(record); err != nil {
+					return added, err
+				}
+			}
+			added += len(dump.Records)
+
+			// The position is saved after the records, a crash in between re-applies the dump
+			state[dataset] = replicaPosition{Next: dump.Next, Anchor: dump.NextAnchor}
+			if err = r.saveState(state); err != nil {
+				return added, err
+			}
+			if dump.Complete || len(dump.Records) == 0 {
+				break
+			}
+		}
+	}
+	return added, nil
+}
+
+// fetchDump gets the dump from the position, and verifies its signature and position
+func (r *Replica) fetchDump(ctx context.Context, dataset string, position replicaPosition) (*ReplicaDump, error) {
+	query := url.Values{}
+	query.Set("dataset", dataset)
+	query.Set("from", strconv.FormatInt(position.Next, 10))
+	query.Set("anchor", position.Anchor)
+	query.Set("limit", strconv.Itoa(r.Limit))
+	sep := "?"
+	if strings.Contains(r.URL, "?") {
+		sep = "&"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URL+sep+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusConflict {
+		return nil, ErrReplicaAnchorMismatch
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("replica source error %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(resp.Header.Get(ReplicaSignatureHeader))
+	if err != nil || !ed25519.Verify(r.PublicKey, body, signature) {
+		return nil, errors.New("invalid signature of the replica dump")
+	}
+
+	dump := new(ReplicaDump)
+	if err = json.Unmarshal(body, dump); err != nil {
+		return nil, err
+	}
+	if dump.Version != ReplicaVersion || dump.Dataset != dataset || dump.From != position.Next || dump.Anchor != position.Anchor || dump.Next != dump.From+int64(len(dump.Records)) {
+		return nil, fmt.Errorf("unexpected replica dump %s %s from %d to %d", dump.Version, dump.Dataset, dump.From, dump.Next)
+	}
+	return dump, nil
+}
@@ -0,0 +1,125 @@
+package watcher
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func readAllLines(t *testing.T, f *RotatingFile) (lines []string) {
+	err := f.ReadLines(func(line []byte) error {
+		lines = append(lines, string(line))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lines
+}
+
+func TestReplicaSync(t *testing.T) {
+	source, err := NewJSONLSink(t.TempDir(), 200, 0) // rotated every few lines
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+	for i := 1; i <= 12; i++ {
+		source.Checks.WriteLine([]byte(fmt.Sprintf(`{"block_number":%d,"errors":[]}`, i)))
+	}
+	source.Incidents.WriteLine([]byte(`{"block_number":5}`))
+
+	privateKeyHex, publicKeyHex, err := GenerateReplicaKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := t.TempDir() + "/replica.key"
+	if err = os.WriteFile(keyFile, []byte(privateKeyHex), 0600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := LoadReplicaKey(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := ParseReplicaPublicKey(publicKeyHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(ReplicaHandler(source, key))
+	defer server.Close()
+
+	replica, err := NewReplica(server.URL, publicKey, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica.Limit = 5
+	if added, err := replica.Sync(context.Background()); err != nil || added != 13 {
+		t.Fatal("unexpected first sync", added, err)
+	}
+
+	// Incremental: only the new records are added
+	source.Checks.WriteLine([]byte(`{"block_number":13,"errors":[]}`))
+	if added, err := replica.Sync(context.Background()); err != nil || added != 1 {
+		t.Fatal("unexpected incremental sync", added, err)
+	}
+	if got, want := readAllLines(t, replica.Sink.Checks), readAllLines(t, source.Checks); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Error("replica differs from the source", got, want)
+	}
+	if got := readAllLines(t, replica.Sink.Incidents); len(got) != 1 {
+		t.Error("unexpected incidents", got)
+	}
+
+	// Dumps signed with another key are rejected
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	otherServer := httptest.NewServer(ReplicaHandler(source, otherKey))
+	defer otherServer.Close()
+	replica.URL = otherServer.URL
+	if _, err := replica.Sync(context.Background()); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Error("expected a signature error", err)
+	}
+}
+
+func TestReplicaDumpAnchor(t *testing.T) {
+	sink, err := NewJSONLSink(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	for i := 1; i <= 3; i++ {
+		sink.Checks.WriteLine([]byte(fmt.Sprintf(`{"block_number":%d}`, i)))
+	}
+
+	dump, err := sink.Dump(ReplicaDatasetChecks, 0, "", 2)
+	if err != nil || len(dump.Records) != 2 || dump.Next != 2 || dump.Complete {
+		t.Fatal("unexpected dump", dump, err)
+	}
+	dump, err = sink.Dump(ReplicaDatasetChecks, dump.Next, dump.NextAnchor, 2)
+	if err != nil || len(dump.Records) != 1 || dump.Next != 3 || !dump.Complete {
+		t.Fatal("unexpected second dump", dump, err)
+	}
+
+	// A different history before the position
+	if _, err = sink.Dump(ReplicaDatasetChecks, 2, LineHash([]byte(`{"block_number":1}`)), 2); !errors.Is(err, ErrReplicaAnchorMismatch) {
+		t.Error("expected an anchor mismatch", err)
+	}
+	if _, err = sink.Dump(ReplicaDatasetChecks, 10, "abc", 2); !errors.Is(err, ErrReplicaAnchorMismatch) {
+		t.Error("expected an anchor mismatch after the end", err)
+	}
+
+	server := httptest.NewServer(ReplicaHandler(sink, ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "?dataset=checks&from=2&anchor=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Error("expected 409 Conflict, got", resp.StatusCode)
+	}
+}
@@ -0,0 +1,183 @@
+package watcher
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+// Session accounts a watch session for the summary on exit (see Watcher.Session): the checked blocks, the errors by
+// type and miner, reorgs, the mev-blocks API outages, and the node failovers reported by the caller. It's safe for
+// concurrent use.
+type Session struct {
+	Started time.Time
+
+	lock          sync.Mutex
+	blocks        map[int64]sessionBlock // by block number, a re-check replaces the block
+	errorCounts   blockcheck.ErrorCounts
+	minerErrors   blockcheck.ErrorSummary
+	reorgs        int
+	failovers     int
+	apiOutages    int
+	apiDowntime   time.Duration // of the ended outages
+	apiDownSince  time.Time     // zero while the API is reachable
+	firstBlock    int64
+	lastBlock     int64
+	seriousBlocks int
+}
+
+// sessionBlock is what the session keeps of a check, to replace it with a re-check
+type sessionBlock struct {
+	miner       string
+	errorCounts blockcheck.ErrorCounts
+	hasErrors   bool
+	serious     bool
+}
+
+// SessionSummary is the summary of a session
+type SessionSummary struct {
+	Started       time.Time
+	Duration      time.Duration
+	Blocks        int
+	FirstBlock    int64
+	LastBlock     int64
+	ErrorBlocks   int
+	SeriousBlocks int
+	ErrorCounts   map[string]uint64
+	MinerErrors   string // errors by miner, see blockcheck.ErrorSummary
+	Reorgs        int
+	Failovers     int
+	ApiOutages    int
+	ApiDowntime   time.Duration // including an ongoing outage
+}
+
+func NewSession() *Session {
+	return &Session{
+		Started:     time.Now(),
+		blocks:      make(map[int64]sessionBlock),
+		minerErrors: blockcheck.NewErrorSummary(),
+	}
+}
+
+// AddCheck counts a checked block, a re-check of a block replaces its previous check
+func (s *Session) AddCheck(check *blockcheck.BlockCheck) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if previous, found := s.blocks[check.Number]; found {
+		s.errorCounts.Sub(previous.errorCounts)
+		s.minerErrors.RemoveCheckErrors(&blockcheck.BlockCheck{Number: check.Number, Miner: previous.miner, ErrorCounter: previous.errorCounts})
+		if previous.serious {
+			s.seriousBlocks -= 1
+		}
+	}
+
+	block := sessionBlock{miner: check.Miner, errorCounts: check.ErrorCounter, hasErrors: check.HasErrors(), serious: check.HasSeriousErrors()}
+	s.blocks[check.Number] = block
+	s.errorCounts.Add(check.ErrorCounter)
+	if block.hasErrors {
+		s.minerErrors.AddCheckErrors(check)
+	}
+	if block.serious {
+		s.seriousBlocks += 1
+	}
+	if s.firstBlock == 0 || check.Number < s.firstBlock {
+		s.firstBlock = check.Number
+	}
+	if check.Number > s.lastBlock {
+		s.lastBlock = check.Number
+	}
+}
+
+// AddReorg counts a reorged block
+func (s *Session) AddReorg() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.reorgs += 1
+}
+
+// AddFailover counts a switch to another node
+func (s *Session) AddFailover() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failovers += 1
+}
+
+// apiPolled records the result of a poll of the mev-blocks API, for the outages
+func (s *Session) apiPolled(err error, now time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err == nil {
+		if !s.apiDownSince.IsZero() {
+			s.apiDowntime += now.Sub(s.apiDownSince)
+			s.apiDownSince = time.Time{}
+		}
+		return
+	}
+	if s.apiDownSince.IsZero() {
+		s.apiDownSince = now
+		s.apiOutages += 1
+	}
+}
+
+// Summary returns the summary of the session until now
+func (s *Session) Summary(now time.Time) SessionSummary {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	summary := SessionSummary{
+		Started:       s.Started,
+		Duration:      now.Sub(s.Started),
+		Blocks:        len(s.blocks),
+		FirstBlock:    s.firstBlock,
+		LastBlock:     s.lastBlock,
+		SeriousBlocks: s.seriousBlocks,
+		ErrorCounts:   s.errorCounts.Map(),
+		MinerErrors:   s.minerErrors.String(),
+		Reorgs:        s.reorgs,
+		Failovers:     s.failovers,
+		ApiOutages:    s.apiOutages,
+		ApiDowntime:   s.apiDowntime,
+	}
+	for _, block := range s.blocks {
+		if block.hasErrors {
+			summary.ErrorBlocks += 1
+		}
+	}
+	if !s.apiDownSince.IsZero() {
+		summary.ApiDowntime += now.Sub(s.apiDownSince)
+	}
+	return summary
+}
+
+func (s SessionSummary) String() string {
+	ret := fmt.Sprintf("Duration: %s (since %s)\n", s.Duration.Round(time.Second), s.Started.UTC().Format(time.RFC3339))
+	if s.Blocks == 0 {
+		ret += "Blocks: 0\n"
+	} else {
+		ret += fmt.Sprintf("Blocks: %d (%d to %d), with errors: %d, with serious errors: %d\n", s.Blocks, s.FirstBlock, s.LastBlock, s.ErrorBlocks, s.SeriousBlocks)
+	}
+	ret += fmt.Sprintf("Reorgs: %d, node failovers: %d\n", s.Reorgs, s.Failovers)
+	ret += fmt.Sprintf("mev-blocks API outages: %d, downtime: %s\n", s.ApiOutages, s.ApiDowntime.Round(time.Second))
+
+	if len(s.ErrorCounts) > 0 {
+		types := make([]string, 0, len(s.ErrorCounts))
+		for errorType := range s.ErrorCounts {
+			types = append(types, errorType)
+		}
+		sort.Strings(types)
+		counts := make([]string, len(types))
+		for i, errorType := range types {
+			counts[i] = fmt.Sprintf("%s=%d", errorType, s.ErrorCounts[errorType])
+		}
+		ret += "Errors: " + strings.Join(counts, " ") + "\n"
+	}
+	if s.MinerErrors != "" {
+		ret += "Errors by miner:\n" + s.MinerErrors
+	}
+	return ret
+}
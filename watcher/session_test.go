@@ -0,0 +1,50 @@
+package watcher
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+func TestSession(t *testing.T) {
+	session := NewSession()
+	start := session.Started
+
+	session.AddCheck(&blockcheck.BlockCheck{Number: 100, Miner: "0xa"})
+	partial := &blockcheck.BlockCheck{Number: 101, Miner: "0xb", CheckErrors: []*blockcheck.CheckError{{Kind: blockcheck.ErrorBundleHas0Fee}}, ManualHasSeriousError: true}
+	partial.ErrorCounter.BundleHas0Fee = 1
+	session.AddCheck(partial)
+
+	// The complete re-check replaces the partial check
+	recheck := &blockcheck.BlockCheck{Number: 101, Miner: "0xb", CheckErrors: []*blockcheck.CheckError{{Kind: blockcheck.ErrorBundleHas0Fee}, {Kind: blockcheck.ErrorBundleNotAtTop}}, ManualHasSeriousError: true}
+	recheck.ErrorCounter.BundleHas0Fee = 1
+	recheck.ErrorCounter.BundleNotAtTop = 1
+	session.AddCheck(recheck)
+	session.AddReorg()
+	session.AddFailover()
+
+	// One ended and one ongoing API outage
+	session.apiPolled(errors.New("timeout"), start.Add(time.Minute))
+	session.apiPolled(errors.New("timeout"), start.Add(2*time.Minute))
+	session.apiPolled(nil, start.Add(3*time.Minute))
+	session.apiPolled(errors.New("timeout"), start.Add(9*time.Minute))
+
+	summary := session.Summary(start.Add(10 * time.Minute))
+	if summary.Blocks != 2 || summary.FirstBlock != 100 || summary.LastBlock != 101 || summary.ErrorBlocks != 1 || summary.SeriousBlocks != 1 {
+		t.Error("unexpected blocks", summary)
+	}
+	if len(summary.ErrorCounts) != 2 || summary.ErrorCounts[blockcheck.ErrorBundleHas0Fee] != 1 || summary.ErrorCounts[blockcheck.ErrorBundleNotAtTop] != 1 {
+		t.Error("unexpected error counts", summary.ErrorCounts)
+	}
+	if summary.ApiOutages != 2 || summary.ApiDowntime != 3*time.Minute || summary.Reorgs != 1 || summary.Failovers != 1 {
+		t.Error("unexpected outages", summary)
+	}
+
+	msg := summary.String()
+	if !strings.Contains(msg, "Duration: 10m0s") || !strings.Contains(msg, "bundleNotAtTop=1") || !strings.Contains(msg, "0xb") || strings.Contains(msg, "0xa ") {
+		t.Error("unexpected summary", msg)
+	}
+}
@@ -0,0 +1,182 @@
+package watcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/schema"
+)
+
+// Checkpoint is saved after processing blocks, so a restarted watcher continues where it left off
+type Checkpoint struct {
+	LastProcessedBlock int64     `json:"last_processed_block"` // all blocks up to this height have been processed
+	Time               time.Time `json:"time"`
+}
+
+// Storage persists the checkpoint and the check results
+type Storage interface {
+	SaveCheckpoint(checkpoint Checkpoint) error
+	LoadCheckpoint() (checkpoint Checkpoint, found bool, err error)
+	SaveCheck(check *blockcheck.BlockCheck) error
+}
+
+// UndeliveredAlert is a notification which none of the notifiers could deliver
+type UndeliveredAlert struct {
+	Time      time.Time `json:"time"`
+	Message   string    `json:"message"`
+	Error     string    `json:"error"`               // error of the last notifier
+	Blocks    []int64   `json:"blocks,omitempty"`    // blocks of the alert (for the audit log)
+	Incidents []string  `json:"incidents,omitempty"` // IDs of the multi-block incidents of the alert
+}
+
+// AlertStorage persists undelivered alerts, so they can be resent later
+type AlertStorage interface {
+	SaveUndeliveredAlert(alert UndeliveredAlert) error
+	LoadUndeliveredAlerts() ([]UndeliveredAlert, error)
+	ReplaceUndeliveredAlerts(alerts []UndeliveredAlert) error // eg. with the alerts which still couldn't be resent
+}
+
+// FileStorage saves the checkpoint as JSON file, and appends the checks with errors to a JSON-lines file (if set)
+type FileStorage struct {
+	CheckpointFile  string
+	ChecksFile      string // optional, one schema.CheckResult per line
+	AllChecks       bool   // also save the checks without errors (eg. for backfills)
+	UndeliveredFile string // optional, one UndeliveredAlert per line
+}
+
+func NewFileStorage(checkpointFile string) *FileStorage {
+	return &FileStorage{CheckpointFile: checkpointFile}
+}
+
+func (s *FileStorage) SaveCheckpoint(checkpoint Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so the checkpoint is never half-written
+	tmpFile := s.CheckpointFile + ".tmp"
+	err = os.WriteFile(tmpFile, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, s.CheckpointFile)
+}
+
+func (s *FileStorage) LoadCheckpoint() (checkpoint Checkpoint, found bool, err error) {
+	data, err := os.ReadFile(s.CheckpointFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint, false, nil
+	} else if err != nil {
+		return checkpoint, false, err
+	}
+
+	err = json.Unmarshal(data, &checkpoint)
+	if err != nil {
+		return checkpoint, false, fmt.Errorf("checkpoint %s: %w", s.CheckpointFile, err)
+	}
+	return checkpoint, true, nil
+}
+
+func (s *FileStorage) SaveCheck(check *blockcheck.BlockCheck) error {
+	return s.SaveChecks([]*blockcheck.BlockCheck{check})
+}
+
+// SaveChecks appends many checks at once (one write for all lines)
+func (s *FileStorage) SaveChecks(checks []*blockcheck.BlockCheck) error {
+	if s.ChecksFile == "" {
+		return nil
+	}
+
+	var data []byte
+	for _, check := range checks {
+		if !s.AllChecks && !check.HasErrors() {
+			continue
+		}
+
+		line, err := json.Marshal(schema.NewCheckResult(check))
+		if err != nil {
+			return err
+		}
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(s.ChecksFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(data)
+	return err
+}
+
+func (s *FileStorage) SaveUndeliveredAlert(alert UndeliveredAlert) error {
+	if s.UndeliveredFile == "" {
+		return errors.New("no file for undelivered alerts")
+	}
+
+	line, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.UndeliveredFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileStorage) LoadUndeliveredAlerts() (alerts []UndeliveredAlert, err error) {
+	data, err := os.ReadFile(s.UndeliveredFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return alerts, nil
+	} else if err != nil {
+		return alerts, err
+	}
+
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var alert UndeliveredAlert
+		if err = json.Unmarshal(line, &alert); err != nil {
+			return alerts, fmt.Errorf("%s line %d: %w", s.UndeliveredFile, i+1, err)
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+func (s *FileStorage) ReplaceUndeliveredAlerts(alerts []UndeliveredAlert) error {
+	var data []byte
+	for _, alert := range alerts {
+		line, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+
+	tmpFile := s.UndeliveredFile + ".tmp"
+	err := os.WriteFile(tmpFile, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, s.UndeliveredFile)
+}
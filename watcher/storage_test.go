@@ -0,0 +1,35 @@
+package watcher
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStorageUndeliveredAlerts(t *testing.T) {
+	s := &FileStorage{UndeliveredFile: filepath.Join(t.TempDir(), "undelivered.jsonl")}
+
+	alerts, err := s.LoadUndeliveredAlerts()
+	if err != nil || len(alerts) != 0 {
+		t.Fatal("expected no alerts without file", alerts, err)
+	}
+
+	for _, msg := range []string{"alert 1", "alert 2\nwith two lines"} {
+		if err = s.SaveUndeliveredAlert(UndeliveredAlert{Time: time.Now().UTC(), Message: msg, Error: "webhook down"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	alerts, err = s.LoadUndeliveredAlerts()
+	if err != nil || len(alerts) != 2 || alerts[1].Message != "alert 2\nwith two lines" || alerts[0].Error != "webhook down" {
+		t.Fatal("unexpected alerts", alerts, err)
+	}
+
+	if err = s.ReplaceUndeliveredAlerts(alerts[1:]); err != nil {
+		t.Fatal(err)
+	}
+	alerts, err = s.LoadUndeliveredAlerts()
+	if err != nil || len(alerts) != 1 || alerts[0].Message != "alert 2\nwith two lines" {
+		t.Fatal("unexpected alerts after replace", alerts, err)
+	}
+}
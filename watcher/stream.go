@@ -0,0 +1,97 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/schema"
+)
+
+// A comment is sent to idle streams at this interval, so proxies don't close the connection
+var StreamKeepAliveInterval = 15 * time.Second
+
+// Event types of the stream
+const (
+	StreamEventCheck = "check" // every check, data is a schema.CheckResult
+	StreamEventAlert = "alert" // checks with serious or less-serious errors, data is a StreamAlert
+)
+
+// StreamAlert is the data of an alert event
+type StreamAlert struct {
+	Severity string             `json:"severity"`
+	Message  string             `json:"message"` // the alert text, without markdown
+	Check    schema.CheckResult `json:"check"`
+}
+
+// StreamHandler streams the check results as Server-Sent Events (text/event-stream) the moment they are produced:
+// a "check" event for every block, and an additional "alert" event for checks with errors. The query parameters of
+// FilterFromQuery select the checks, eg. /stream?severity=serious&miner=0x... Each client is a subscriber of the
+// watcher (see SubscribeChecks), clients which don't keep up miss checks.
+func (w *Watcher) StreamHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		flusher, ok := rw.(http.Flusher)
+		if !ok {
+			http.Error(rw, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		checks, err := w.SubscribeChecksFiltered(r.Context(), FilterFromQuery(r.URL.Query()))
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "text/event-stream")
+		rw.Header().Set("Cache-Control", "no-cache")
+		rw.Header().Set("X-Accel-Buffering", "no") // nginx
+		rw.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(StreamKeepAliveInterval)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case check, ok := <-checks:
+				if !ok { // request done or watcher stopped
+					return
+				}
+				if err := writeStreamEvents(rw, check); err != nil {
+					return
+				}
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(rw, ": keep-alive\n\n"); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	})
+}
+
+// writeStreamEvents writes the check event, and the alert event if the check has errors
+func writeStreamEvents(rw http.ResponseWriter, check *blockcheck.BlockCheck) error {
+	result := schema.NewCheckResult(check)
+	if err := writeStreamEvent(rw, StreamEventCheck, check.Number, result); err != nil {
+		return err
+	}
+
+	severity := CheckSeverity(check)
+	if severity == "" {
+		return nil
+	}
+	alert := StreamAlert{Severity: severity, Message: check.Sprint(false, false, true), Check: result}
+	return writeStreamEvent(rw, StreamEventAlert, check.Number, alert)
+}
+
+func writeStreamEvent(rw http.ResponseWriter, event string, id int64, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(rw, "event: %s\nid: %d\ndata: %s\n\n", event, id, payload)
+	return err
+}
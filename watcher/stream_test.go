@@ -0,0 +1,55 @@
+package watcher
+
+import (
+	"bufio"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+func TestFilterFromQuery(t *testing.T) {
+	if filter := FilterFromQuery(url.Values{}); filter != nil {
+		t.Error("Expected nil filter without parameters:", filter)
+	}
+
+	query, _ := url.ParseQuery("miner=0xabc,%200xdef&severity=serious")
+	filter := FilterFromQuery(query)
+	if filter == nil || len(filter.Miners) != 2 || filter.Miners[1] != "0xdef" || filter.MinSeverity != blockcheck.SeveritySerious || len(filter.Searchers) != 0 {
+		t.Error("Unexpected filter:", filter)
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	w := New(nil)
+	server := httptest.NewServer(w.StreamHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?miner=0xabc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatal("Unexpected content type:", resp.Header.Get("Content-Type"))
+	}
+
+	block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(2)})
+	w.subscriptions.publish(&blockcheck.BlockCheck{Number: 1, Miner: "0xdef", EthBlock: block}) // filtered
+	w.subscriptions.publish(&blockcheck.BlockCheck{Number: 2, Miner: "0xabc", EthBlock: block})
+	w.Stop()
+
+	var lines []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if len(lines) != 4 || lines[0] != "event: check" || lines[1] != "id: 2" || !strings.HasPrefix(lines[2], `data: {"schema_version":"v1","block_number":2,`) {
+		t.Error("Unexpected stream:", lines)
+	}
+}
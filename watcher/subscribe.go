@@ -0,0 +1,112 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+var ErrWatcherStopped = errors.New("watcher is stopped")
+
+// checkSubscriptions delivers check results to any number of independent subscribers
+type checkSubscriptions struct {
+	lock        sync.Mutex
+	subscribers map[*checkSubscriber]bool
+	stopped     bool
+
+	BufferSize  int           // channel buffer per subscriber
+	SendTimeout time.Duration // how long to wait for a slow subscriber with a full buffer before dropping the check
+}
+
+type checkSubscriber struct {
+	ch      chan *blockcheck.BlockCheck
+	ctx     context.Context
+	filter  *Filter
+	dropped uint64
+}
+
+func newCheckSubscriptions() *checkSubscriptions {
+	return &checkSubscriptions{
+		subscribers: make(map[*checkSubscriber]bool),
+		BufferSize:  100,
+		SendTimeout: 5 * time.Second,
+	}
+}
+
+// subscribe returns a channel which receives all checks matching the filter until the context is cancelled (then the
+// channel is closed)
+func (s *checkSubscriptions) subscribe(ctx context.Context, filter *Filter) (<-chan *blockcheck.BlockCheck, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stopped {
+		return nil, ErrWatcherStopped
+	}
+
+	sub := &checkSubscriber{
+		ch:     make(chan *blockcheck.BlockCheck, s.BufferSize),
+		ctx:    ctx,
+		filter: filter,
+	}
+	s.subscribers[sub] = true
+
+	go func() {
+		<-ctx.Done()
+		s.remove(sub)
+	}()
+
+	return sub.ch, nil
+}
+
+func (s *checkSubscriptions) remove(sub *checkSubscriber) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.subscribers[sub] {
+		delete(s.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// publish sends the check to all subscribers whose filter matches. If a subscriber's buffer is full, it waits up to SendTimeout
+// for it (backpressure), and drops the check for this subscriber afterwards.
+func (s *checkSubscriptions) publish(check *blockcheck.BlockCheck) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for sub := range s.subscribers {
+		if !sub.filter.Matches(check) {
+			continue
+		}
+
+		select {
+		case sub.ch <- check:
+			continue
+		default:
+		}
+
+		timer := time.NewTimer(s.SendTimeout)
+		select {
+		case sub.ch <- check:
+		case <-sub.ctx.Done():
+		case <-timer.C:
+			sub.dropped += 1
+		}
+		timer.Stop()
+	}
+}
+
+// stop closes all subscriber channels
+func (s *checkSubscriptions) stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.stopped = true
+	for sub := range s.subscribers {
+		delete(s.subscribers, sub)
+		close(sub.ch)
+	}
+}
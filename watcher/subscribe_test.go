@@ -0,0 +1,73 @@
+package watcher
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/metachris/flashbots/blockcheck"
+)
+
+func TestCheckSubscriptions(t *testing.T) {
+	subs := newCheckSubscriptions()
+	subs.SendTimeout = 10 * time.Millisecond
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ch1, err := subs.subscribe(ctx1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch2, err := subs.subscribe(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subs.publish(&blockcheck.BlockCheck{Number: 1})
+	if check := <-ch1; check.Number != 1 {
+		t.Error("Wrong check for subscriber 1:", check.Number)
+	}
+	if check := <-ch2; check.Number != 1 {
+		t.Error("Wrong check for subscriber 2:", check.Number)
+	}
+
+	// Cancelled subscribers get their channel closed
+	cancel1()
+	if _, ok := <-ch1; ok {
+		t.Error("Channel should be closed after cancel")
+	}
+
+	subs.publish(&blockcheck.BlockCheck{Number: 2})
+	if check := <-ch2; check.Number != 2 {
+		t.Error("Wrong check for subscriber 2:", check.Number)
+	}
+
+	subs.stop()
+	if _, ok := <-ch2; ok {
+		t.Error("Channel should be closed after stop")
+	}
+	if _, err := subs.subscribe(context.Background(), nil); err != ErrWatcherStopped {
+		t.Error("Expected ErrWatcherStopped, got:", err)
+	}
+}
+
+func TestFilteredSubscription(t *testing.T) {
+	subs := newCheckSubscriptions()
+	filter := &Filter{Miners: []string{"0xAbC"}, ErrorTypes: []string{"failed0gas"}}
+	ch, err := subs.subscribe(context.Background(), filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subs.publish(&blockcheck.BlockCheck{Number: 1, Miner: "0xabc"})                                                        // no errors
+	subs.publish(&blockcheck.BlockCheck{Number: 2, Miner: "0xdef", ErrorCounter: blockcheck.ErrorCounts{Failed0GasTx: 1}}) // other miner
+	subs.publish(&blockcheck.BlockCheck{Number: 3, Miner: "0xabc", ErrorCounter: blockcheck.ErrorCounts{Failed0GasTx: 1}})
+	subs.stop()
+
+	received := []int64{}
+	for check := range ch {
+		received = append(received, check.Number)
+	}
+	if len(received) != 1 || received[0] != 3 {
+		t.Error("Unexpected checks:", received)
+	}
+}
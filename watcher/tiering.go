@@ -0,0 +1,256 @@
+package watcher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ObjectStore is the cold storage of old check data (see Tiering)
+type ObjectStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error) // the error wraps os.ErrNotExist if there is no such object
+}
+
+// OpenObjectStore returns an HTTPStore for http(s) URLs, else a DirStore
+func OpenObjectStore(uri string) ObjectStore {
+	if strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://") {
+		return &HTTPStore{BaseURL: strings.TrimSuffix(uri, "/"), Header: make(http.Header)}
+	}
+	return &DirStore{Dir: uri}
+}
+
+// DirStore keeps the objects as files in a directory, eg. a bucket mounted with s3fs or gcsfuse
+type DirStore struct {
+	Dir string
+}
+
+func (s *DirStore) Put(ctx context.Context, key string, data []byte) error {
+	filename := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(filename+".tmp", filename)
+}
+
+func (s *DirStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.Dir, filepath.FromSlash(key)))
+}
+
+// HTTPStore puts and gets the objects with HTTP PUT and GET requests to <BaseURL>/<key>, eg. an S3 or GCS bucket
+// behind an authenticating proxy, or a WebDAV server
+type HTTPStore struct {
+	BaseURL string
+	Header  http.Header // sent with every request, eg. Authorization
+	Client  *http.Client
+}
+
+func (s *HTTPStore) do(ctx context.Context, method string, key string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.BaseURL+"/"+key, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range s.Header {
+		req.Header[name] = values
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+func (s *HTTPStore) Put(ctx context.Context, key string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, key, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("error storing %s: %s", key, resp.Status)
+	}
+	return nil
+}
+
+func (s *HTTPStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", key, os.ErrNotExist)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		resp.Body.Close()
+		return nil, fmt.Errorf("error getting %s: %s", key, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// ColdObject is a rotated file which was moved to the cold storage, in the manifest of its RotatingFile
+type ColdObject struct {
+	Key     string    `json:"key"`
+	File    string    `json:"file"` // name of the rotated file
+	Size    int64     `json:"size"` // uncompressed
+	MovedAt time.Time `json:"moved_at"`
+}
+
+// Tiering moves the rotated files of RotatingFiles which are older than MaxAge to the cold storage (gzip-compressed),
+// keeping the local files small and fast. The moved files are recorded in a manifest next to the file (eg.
+// checks.cold.json for checks.jsonl), and ReadLines reads them back from the cold storage transparently (oldest
+// first, before the local files).
+type Tiering struct {
+	Store  ObjectStore
+	MaxAge time.Duration // of the last write to a rotated file
+	Prefix string        // of the object keys, eg. the name of the instance
+}
+
+func NewTiering(store ObjectStore, maxAge time.Duration) *Tiering {
+	return &Tiering{Store: store, MaxAge: maxAge}
+}
+
+// Run moves the old files every interval until the context is cancelled. Errors are sent to onError, the files are
+// retried in the next round.
+func (t *Tiering) Run(ctx context.Context, interval time.Duration, files []*RotatingFile, onError func(err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, f := range files {
+			if _, err := t.Move(ctx, f, time.Now()); err != nil {
+				onError(fmt.Errorf("cold storage: %w", err))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Move moves the rotated files of f which were last written before now - MaxAge, oldest first. Returns the number of
+// moved files.
+func (t *Tiering) Move(ctx context.Context, f *RotatingFile, now time.Time) (moved int, err error) {
+	backups, err := f.Backups()
+	if err != nil {
+		return 0, err
+	}
+	for _, backup := range backups {
+		info, err := os.Stat(backup)
+		if err != nil {
+			return moved, err
+		}
+		if now.Sub(info.ModTime()) <= t.MaxAge {
+			break // the newer files are younger
+		}
+		if err = t.moveFile(ctx, f, backup); err != nil {
+			return moved, err
+		}
+		moved += 1
+	}
+	return moved, nil
+}
+
+func (t *Tiering) moveFile(ctx context.Context, f *RotatingFile, filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err = zw.Write(data); err != nil {
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+
+	object := ColdObject{Key: t.Prefix + filepath.Base(filename) + ".gz", File: filepath.Base(filename), Size: int64(len(data)), MovedAt: time.Now().UTC()}
+	if err = t.Store.Put(ctx, object.Key, compressed.Bytes()); err != nil {
+		return fmt.Errorf("error storing %s: %w", filename, err)
+	}
+
+	// Readers see the file either locally or in the manifest
+	f.tierLock.Lock()
+	defer f.tierLock.Unlock()
+	objects, err := f.ColdObjects()
+	if err != nil {
+		return err
+	}
+	if err = f.writeManifest(append(objects, object)); err != nil {
+		return err
+	}
+	return os.Remove(filename)
+}
+
+// manifestFile lists the files which were moved to the cold storage, eg. checks.cold.json for checks.jsonl
+func (f *RotatingFile) manifestFile() string {
+	return strings.TrimSuffix(f.Filename, filepath.Ext(f.Filename)) + ".cold.json"
+}
+
+// ColdObjects returns the rotated files which were moved to the cold storage, oldest first
+func (f *RotatingFile) ColdObjects() (objects []ColdObject, err error) {
+	data, err := os.ReadFile(f.manifestFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &objects); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.manifestFile(), err)
+	}
+	return objects, nil
+}
+
+func (f *RotatingFile) writeManifest(objects []ColdObject) error {
+	data, err := json.MarshalIndent(objects, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpFile := f.manifestFile() + ".tmp"
+	if err = os.WriteFile(tmpFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, f.manifestFile())
+}
+
+// readColdLines calls fn for every line of the files in the cold storage
+func (f *RotatingFile) readColdLines(fn func(line []byte) error) error {
+	objects, err := f.ColdObjects()
+	if err != nil || len(objects) == 0 {
+		return err
+	}
+	if f.Cold == nil {
+		return fmt.Errorf("%s: %d files are in cold storage, but none is configured", f.Filename, len(objects))
+	}
+
+	for _, object := range objects {
+		r, err := f.Cold.Store.Get(context.Background(), object.Key)
+		if err != nil {
+			return fmt.Errorf("cold storage: %w", err)
+		}
+		zr, err := gzip.NewReader(r)
+		if err == nil {
+			err = scanLines(zr, fn)
+		}
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("%s (cold storage): %w", object.Key, err)
+		}
+	}
+	return nil
+}
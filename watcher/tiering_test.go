@@ -0,0 +1,88 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testObjectServer is an in-memory object store for HTTPStore
+func testObjectServer() *httptest.Server {
+	var lock sync.Mutex
+	objects := make(map[string][]byte)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path], _ = io.ReadAll(r.Body)
+		case http.MethodGet:
+			data, found := objects[r.URL.Path]
+			if !found {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data)
+		}
+	}))
+}
+
+func TestTiering(t *testing.T) {
+	server := testObjectServer()
+	defer server.Close()
+
+	for _, store := range []ObjectStore{OpenObjectStore(t.TempDir()), OpenObjectStore(server.URL)} {
+		filename := filepath.Join(t.TempDir(), "checks.jsonl")
+		f := NewRotatingFile(filename, 20, 1)
+		f.Cold = NewTiering(store, 24*time.Hour)
+		for i := 0; i < 7; i++ {
+			if err := f.WriteLine([]byte(fmt.Sprintf("{\"i\":%03d}", i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		f.Close()
+
+		// The rotated files are kept for the tiering, the two oldest are old enough
+		backups, _ := f.Backups()
+		if len(backups) != 3 {
+			t.Fatal("expected 3 rotated files", backups)
+		}
+		old := time.Now().Add(-48 * time.Hour)
+		for _, backup := range backups[:2] {
+			os.Chtimes(backup, old, old)
+		}
+		moved, err := f.Cold.Move(context.Background(), f, time.Now())
+		if err != nil || moved != 2 {
+			t.Fatal("expected 2 moved files", moved, err)
+		}
+		if backups, _ := f.Backups(); len(backups) != 1 {
+			t.Error("expected 1 local rotated file", backups)
+		}
+		if objects, err := f.ColdObjects(); err != nil || len(objects) != 2 || objects[0].File != filepath.Base(backups[0]) || objects[0].Size != 20 {
+			t.Error("unexpected cold objects", objects, err)
+		}
+
+		// All lines are read, the cold ones first
+		var lines []string
+		if err = f.ReadLines(func(line []byte) error { lines = append(lines, string(line)); return nil }); err != nil {
+			t.Fatal(err)
+		}
+		if len(lines) != 7 || lines[0] != `{"i":000}` || lines[6] != `{"i":006}` {
+			t.Error("unexpected lines", lines)
+		}
+
+		// Reading needs the cold storage
+		f.Cold = nil
+		if err = f.ReadLines(func(line []byte) error { return nil }); err == nil || !strings.Contains(err.Error(), "cold storage") {
+			t.Error("expected an error without the cold storage", err)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+// Package watcher checks new blocks as they arrive, and delivers the results to subscribers, callbacks and notifiers.
+// It handles the backlog of blocks which the mev-blocks API hasn't processed yet, confirmations, reorgs, checkpoints
+// and the miner stats, so other programs can embed the monitoring of cmd/block-watch.
+//
+// Blocks go through a pipeline of goroutines connected by bounded channels (see Run): the receipts of new blocks
+// are fetched in parallel, the blocks wait for confirmations and the mev-blocks API, then they are checked and
+// reported one at a time, in order.
+//
+// Usage:
+//
+//	w := watcher.New(client)
+//	w.Confirmations = 2
+//	w.Notifiers = append(w.Notifiers, watcher.NotifierFunc(func(check *blockcheck.BlockCheck, severity string) error {
+//		fmt.Println(severity, check.Sprint(false, false, true))
+//		return nil
+//	}))
+//	checks, err := w.SubscribeChecks(ctx)
+//	go w.Run(ctx)
+//	for check := range checks {
+//		fmt.Println(check.Sprint(false, false, true))
+//	}
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/metachris/flashbots/audit"
+	"github.com/metachris/flashbots/blockcheck"
+	"github.com/metachris/flashbots/blocksource"
+	"github.com/metachris/go-ethutils/blockswithtx"
+)
+
+var ErrHeadStalled = errors.New("no new block received for too long")
+
+// Number of recent blocks that are tracked for reorgs (Confirmations must be lower)
+const ReorgTrackerDepth = 64
+
+// Max. number of blocks to catch up on when resuming from a checkpoint
+const MaxResumeBlocks = 1000
+
+type Watcher struct {
+	client        *ethclient.Client
+	subscriptions *checkSubscriptions
+
+	// Backlog of new blocks until they are reported. Blocks wait for the mev-blocks API (it has ~5 blocks delay).
+	// The lock protects the state shared by the pipeline stages and the exported methods.
+	lock  sync.Mutex
+	queue *blockQueue
+
+	reorgTracker        *ReorgTracker
+	latestHeight        int64          // latest block received from the node
+	lastProcessedHeight int64          // highest block that was checked
+	processingHeight    int64          // block which was processed by a stage when it panicked
+	published           map[int64]bool // blocks of the backlog which are in the mev-blocks API (see Audit)
+	skipped             map[int64]bool // blocks which the API didn't index within ApiWaitTimeout, until it has them
+	apiLag              int64          // blocks of the mev-blocks API behind the node at the last poll, -1 before
+	apiLagAverage       float64        // moving average of apiLag
+
+	// Health (see Health), and the active health alerts (only used by the Run loop)
+	running            bool
+	lastHeaderAt       time.Time
+	apiLastSuccess     time.Time
+	apiFailingSince    time.Time // zero while the API is reachable
+	apiError           string
+	activeHealthAlerts map[string]bool
+
+	// Serializes the checks of the check stage and CheckBlock (the checks share package-level state of blockcheck)
+	checkLock sync.Mutex
+
+	// Alert context (see AlertLookAhead)
+	alertLock   sync.Mutex
+	lastSummary *blockcheck.BlockSummary // of the last reported block
+	heldAlerts  []*heldAlert             // serious alerts waiting for the next block
+
+	Confirmations    int64         // blocks are only checked once they have this many confirmations
+	HeadStallTimeout time.Duration // Run returns ErrHeadStalled if no new block is received for this long
+	FetchWorkers     int           // number of goroutines fetching new blocks with their receipts
+	QueueSize        int           // capacity of the channels between the stages
+	ApiPollInterval  time.Duration // the mev-blocks API is polled this often while blocks wait for it (and on each new block)
+	AlertLookAhead   time.Duration // serious alerts wait this long for the check of the next block, to include its summary (0 = sent immediately)
+	MaxHeadLag       int64         // health alert and not ready if more blocks than this are received but not checked (0 = disabled)
+	MaxApiDowntime   time.Duration // health alert if the mev-blocks API is unreachable this long (0 = disabled)
+	ApiWaitTimeout   time.Duration // blocks which the mev-blocks API didn't index this long are skipped with a warning, and checked once it has them (0 = wait forever)
+	MaxCheckRetries  int           // blocks whose check failed are retried after the next API poll, this many times before they are dropped with an ErrCheckRetries error (0 = retried forever)
+
+	// Blocks of the backlog kept in memory, the data of newer blocks is written to SpillDir until they are checked
+	// (or fetched from the node again if it isn't set). 0 = all in memory.
+	MaxBacklogMemory int
+	SpillDir         string
+
+	MinerLeaderboard *blockcheck.MinerLeaderboard // error rates of all checked blocks, per miner
+	Session          *Session                     // optional, accounts the checks, reorgs and API outages of the session
+
+	// Blocks of these miners (coinbase addresses) are checked before the other blocks of the backlog, and always with
+	// the expensive checks (they aren't shed under load, see LoadShedder)
+	PriorityMiners []string
+
+	Blocks    blocksource.Source // optional, fetches the blocks with receipts (eg. receipts.Fetcher, with batched requests), else one request per tx
+	Storage   Storage            // optional, for checkpoints and check results
+	Sinks     []CheckSink        // receive every check result (eg. JSONLSink)
+	Notifiers []Notifier         // receive the checks with serious or less-serious errors
+	Audit     *audit.Log         // optional, records when blocks were received, published by the API and checked
+
+	// Optional, sheds the expensive checks under sustained lag, the blocks are re-checked completely later
+	LoadShedder *LoadShedder
+
+	// Run only the cheap checks (see blockcheck.CheckBlockFast), the complete checks are left to analysis workers (see
+	// package coordinator). Blocks aren't re-checked.
+	AlertOnly bool
+
+	// Optional callbacks, they are called from one goroutine, one after the other
+	OnNewBlock     func(block *blockswithtx.BlockWithTxReceipts) // every new block, when it was fetched
+	OnBlockChecked func(check *blockcheck.BlockCheck)            // every checked block
+	OnReorg        func(reorged ReorgedBlock)                    // a block was replaced, the new one is queued again
+	OnRecheck      func(previous *blockcheck.BlockCheck)         // a partial check is replaced by its complete re-check (before OnBlockChecked)
+	OnLoadShed     func(shedding bool, lag int64)                // load shedding started or stopped
+	OnHealthAlert  func(alert HealthAlert)                       // a health condition started or ended (see MaxHeadLag, MaxApiDowntime)
+
+	// Errors which don't stop the watcher (eg. temporary API errors) are sent here, if set
+	ErrorHandler func(err error)
+}
+
+func New(client *ethclient.Client) *Watcher {
+	return &Watcher{
+		client:             client,
+		subscriptions:      newCheckSubscriptions(),
+		queue:              newBlockQueue(),
+		published:          make(map[int64]bool),
+		skipped:            make(map[int64]bool),
+		apiLag:             -1,
+		activeHealthAlerts: make(map[string]bool),
+		reorgTracker:       NewReorgTracker(ReorgTrackerDepth),
+		HeadStallTimeout:   3 * time.Minute,
+		FetchWorkers:       4,
+		QueueSize:          64,
+		ApiPollInterval:    3 * time.Second,
+		ApiWaitTimeout:     10 * time.Minute,
+		MaxCheckRetries:    5,
+		AlertLookAhead:     30 * time.Second,
+		MinerLeaderboard:   blockcheck.NewMinerLeaderboard(7 * 24 * time.Hour),
+	}
+}
+
+// SetClient replaces the node client (eg. after a failover), the backlog and stats are kept
+func (w *Watcher) SetClient(client *ethclient.Client) {
+	w.client = client
+}
+
+// getBlock returns the block with receipts, from the Blocks source if set
+func (w *Watcher) getBlock(ctx context.Context, height int64) (*blockswithtx.BlockWithTxReceipts, error) {
+	if w.Blocks != nil {
+		return w.Blocks.GetBlockWithTxReceipts(ctx, height)
+	}
+	return blockswithtx.GetBlockWithTxReceipts(w.client, height)
+}
+
+// CheckBlock checks a block outside of the pipeline (eg. on demand), one at a time with the checks of the check stage
+func (w *Watcher) CheckBlock(block *blockswithtx.BlockWithTxReceipts) (*blockcheck.BlockCheck, error) {
+	return w.runCheck(blockcheck.CheckBlock, block)
+}
+
+// PauseChecks runs f while no check runs (eg. to replace the clients of blockcheck after a failover)
+func (w *Watcher) PauseChecks(f func()) {
+	w.checkLock.Lock()
+	defer w.checkLock.Unlock()
+	f()
+}
+
+// runCheck runs a check function of blockcheck, while no other check runs
+func (w *Watcher) runCheck(checkBlock func(*blockswithtx.BlockWithTxReceipts, bool) (*blockcheck.BlockCheck, error), block *blockswithtx.BlockWithTxReceipts) (*blockcheck.BlockCheck, error) {
+	w.checkLock.Lock()
+	defer w.checkLock.Unlock()
+	return checkBlock(block, false)
+}
+
+// SubscribeChecks returns a channel which receives every check result. The channel is closed when the context
+// is cancelled or the watcher stops. Each subscriber has its own buffer; a subscriber which doesn't keep up
+// slows down delivery for a limited time, after which checks are dropped for it.
+func (w *Watcher) SubscribeChecks(ctx context.Context) (<-chan *blockcheck.BlockCheck, error) {
+	return w.subscriptions.subscribe(ctx, nil)
+}
+
+// SubscribeChecksFiltered is SubscribeChecks for the checks matching the filter only. Checks are filtered before
+// they are buffered, so high-volume consumers only have to keep up with the relevant checks.
+func (w *Watcher) SubscribeChecksFiltered(ctx context.Context, filter *Filter) (<-chan *blockcheck.BlockCheck, error) {
+	return w.subscriptions.subscribe(ctx, filter)
+}
+
+// Stop closes all subscriptions. Call it when the watcher isn't run again (eg. after a failover).
+func (w *Watcher) Stop() {
+	w.subscriptions.stop()
+}
+
+func (w *Watcher) audit(event audit.Event) {
+	if err := w.Audit.Add(event); err != nil {
+		w.handleError(fmt.Errorf("audit log error: %w", err))
+	}
+}
+
+func (w *Watcher) handleError(err error) {
+	if w.ErrorHandler != nil {
+		w.ErrorHandler(err)
+	}
+}
+
+// processCheck updates the stats, and delivers the check to storage, sinks, notifiers, callback and subscribers. For
+// the complete re-check of a partially checked block, previous is the partial check: the notifiers only receive the
+// re-check if it found additional error types. Serious alerts include the summaries of the previous and next block
+// (see AlertLookAhead).
+func (w *Watcher) processCheck(check *blockcheck.BlockCheck, previous *blockcheck.BlockCheck) {
+	w.lock.Lock()
+	w.reorgTracker.SetReported(check)
+	w.lock.Unlock()
+	w.MinerLeaderboard.AddCheck(check)
+	if w.Session != nil {
+		w.Session.AddCheck(check)
+	}
+
+	if w.Storage != nil {
+		if err := w.Storage.SaveCheck(check); err != nil {
+			w.handleError(fmt.Errorf("error saving check of block %d: %w", check.Number, err))
+		}
+	}
+	for _, sink := range w.Sinks {
+		if err := sink.SaveCheck(check); err != nil {
+			w.handleError(fmt.Errorf("error saving check of block %d: %w", check.Number, err))
+		}
+	}
+
+	severity := CheckSeverity(check)
+	w.audit(audit.Event{Block: check.Number, Type: audit.EventChecked, Severity: severity, Message: strings.Join(check.ErrorCounter.Types(), ", ")})
+	var summary *blockcheck.BlockSummary
+	if previous == nil {
+		summary = blockcheck.NewBlockSummary(check)
+		w.releaseAlerts(summary)
+	}
+	if severity != "" && (previous == nil || hasNewErrorTypes(previous, check)) {
+		w.alert(check, severity, previous != nil)
+	}
+	if summary != nil {
+		w.setLastSummary(summary)
+	}
+
+	if w.OnBlockChecked != nil {
+		w.OnBlockChecked(check)
+	}
+	w.subscriptions.publish(check)
+}
+
+// hasNewErrorTypes returns true if the check has error types which the previous check of the block didn't have
+func hasNewErrorTypes(previous *blockcheck.BlockCheck, check *blockcheck.BlockCheck) bool {
+	previousTypes := make(map[string]bool)
+	for _, errorType := range previous.ErrorCounter.Types() {
+		previousTypes[errorType] = true
+	}
+	for _, errorType := range check.ErrorCounter.Types() {
+		if !previousTypes[errorType] {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Watcher) numConfirmedBlocksInBacklog() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.queue.pending(w.latestHeight - w.Confirmations)
+}
+
+// BacklogSize returns the number of blocks which are not yet checked (without the skipped blocks, see SkippedBlocks)
+func (w *Watcher) BacklogSize() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.queue.len()
+}
+
+// SkipProcessingBlock removes the block which was processed by a pipeline stage when it panicked from the backlog
+// (so it doesn't crash the restarted watcher again). Returns its height, or 0 if no block was processed.
+func (w *Watcher) SkipProcessingBlock() int64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	height := w.processingHeight
+	if height > 0 {
+		w.removeQueued(height)
+		w.processingHeight = 0
+	}
+	return height
+}
+
+// CheckpointHeight returns the height up to which all received blocks have been processed completely (partially
+// checked and skipped blocks are checked again after a restart)
+func (w *Watcher) CheckpointHeight() int64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	height := w.lastProcessedHeight
+	if oldest := w.queue.oldest(); oldest > 0 && oldest-1 < height {
+		height = oldest - 1
+	}
+	for skippedHeight := range w.skipped {
+		if skippedHeight-1 < height {
+			height = skippedHeight - 1
+		}
+	}
+	if w.LoadShedder != nil {
+		if pending := w.LoadShedder.PendingRechecks(); len(pending) > 0 && pending[0]-1 < height {
+			height = pending[0] - 1
+		}
+	}
+	return height
+}
+
+func (w *Watcher) saveCheckpoint() {
+	w.lock.Lock()
+	lastProcessedHeight := w.lastProcessedHeight
+	w.lock.Unlock()
+	if w.Storage == nil || lastProcessedHeight == 0 {
+		return
+	}
+
+	checkpoint := Checkpoint{
+		LastProcessedBlock: w.CheckpointHeight(),
+		Time:               time.Now().UTC(),
+	}
+	if err := w.Storage.SaveCheckpoint(checkpoint); err != nil {
+		w.handleError(fmt.Errorf("checkpoint error: %w", err))
+	}
+}
+
+// Resume loads the checkpoint from the storage, and queues all blocks since then (they are checked once Run
+// started). Call it before Run. Returns false if there is no checkpoint.
+func (w *Watcher) Resume(ctx context.Context) (resumed bool, err error) {
+	if w.Storage == nil {
+		return false, nil
+	}
+
+	checkpoint, found, err := w.Storage.LoadCheckpoint()
+	if err != nil || !found {
+		return false, err
+	}
+
+	head, err := w.client.BlockNumber(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	startBlock := checkpoint.LastProcessedBlock + 1
+	if int64(head)-startBlock >= MaxResumeBlocks {
+		w.handleError(fmt.Errorf("checkpoint block %d is too old, only the last %d blocks are checked", checkpoint.LastProcessedBlock, MaxResumeBlocks))
+		startBlock = int64(head) - MaxResumeBlocks + 1
+	}
+
+	for height := startBlock; height <= int64(head); height++ {
+		if ctx.Err() != nil {
+			return true, ctx.Err()
+		}
+
+		b, err := w.getBlock(ctx, height)
+		if err != nil {
+			return true, err
+		}
+		w.lock.Lock()
+		w.queue.put(&queuedBlock{height: height, block: b, state: blockWaiting, waitingSince: time.Now()})
+		w.spillBlocks()
+		w.lock.Unlock()
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.latestHeight = int64(head)
+	w.lastProcessedHeight = checkpoint.LastProcessedBlock
+	return true, nil
+}